@@ -12,6 +12,8 @@ data "aws_iam_openid_connect_provider" "cluster" {
 
 locals {
   oidc_provider = replace(data.aws_eks_cluster.cluster.identity[0].oidc[0].issuer, "https://", "")
+
+  dst_repo_uri = var.ecr_public ? "public.ecr.aws/${var.ecr_public_alias}/${var.repo_name}" : aws_ecr_repository.chainguard.repository_url
 }
 
 resource "aws_ecr_repository" "chainguard" {
@@ -129,6 +131,85 @@ data "aws_iam_policy_document" "image_copy" {
     ]
     resources = ["*"]
   }
+
+  dynamic "statement" {
+    for_each = var.ecr_public ? [1] : []
+    content {
+      effect = "Allow"
+      actions = [
+        "ecr-public:CreateRepository",
+        "ecr-public:BatchCheckLayerAvailability",
+        "ecr-public:DescribeRepositories",
+        "ecr-public:DescribeImages",
+        "ecr-public:InitiateLayerUpload",
+        "ecr-public:UploadLayerPart",
+        "ecr-public:CompleteLayerUpload",
+        "ecr-public:PutImage"
+      ]
+      resources = [
+        "arn:aws:ecr-public::${data.aws_caller_identity.current.account_id}:repository/${var.repo_name}/*"
+      ]
+    }
+  }
+
+  dynamic "statement" {
+    for_each = var.ecr_public ? [1] : []
+    content {
+      effect = "Allow"
+      actions = [
+        "ecr-public:GetAuthorizationToken",
+        "sts:GetServiceBearerToken"
+      ]
+      resources = ["*"]
+    }
+  }
+}
+
+# The role that the pull secrets are created for, which can only pull the
+# copied images
+resource "aws_iam_role" "pull_secret" {
+  count = length(var.pull_secret_namespaces) > 0 ? 1 : 0
+
+  name               = "${var.cluster_name}-image-copy-pull"
+  assume_role_policy = data.aws_iam_policy_document.pull_secret_assume_role.json
+
+  inline_policy {
+    name   = "image-pull"
+    policy = data.aws_iam_policy_document.pull_secret.json
+  }
+}
+
+data "aws_iam_policy_document" "pull_secret_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+    effect  = "Allow"
+    principals {
+      type        = "AWS"
+      identifiers = [aws_iam_role.image_copy.arn]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "pull_secret" {
+  statement {
+    effect = "Allow"
+    actions = [
+      "ecr:BatchCheckLayerAvailability",
+      "ecr:GetDownloadUrlForLayer",
+      "ecr:BatchGetImage"
+    ]
+    resources = [
+      aws_ecr_repository.chainguard.arn,
+      "${aws_ecr_repository.chainguard.arn}/*"
+    ]
+  }
+  statement {
+    effect = "Allow"
+    actions = [
+      "ecr:GetAuthorizationToken",
+    ]
+    resources = ["*"]
+  }
 }
 
 provider "kubernetes" {
@@ -153,6 +234,46 @@ resource "kubernetes_service_account" "image_copy" {
   }
 }
 
+# Allow the job to apply the pull secret to each of the namespaces
+resource "kubernetes_role_v1" "pull_secret" {
+  for_each = toset(var.pull_secret_namespaces)
+
+  metadata {
+    name      = "image-copy-pull-secret"
+    namespace = each.value
+  }
+  rule {
+    api_groups = [""]
+    resources  = ["secrets"]
+    verbs      = ["create"]
+  }
+  rule {
+    api_groups     = [""]
+    resources      = ["secrets"]
+    resource_names = [var.pull_secret_name]
+    verbs          = ["get", "patch"]
+  }
+}
+
+resource "kubernetes_role_binding_v1" "pull_secret" {
+  for_each = toset(var.pull_secret_namespaces)
+
+  metadata {
+    name      = "image-copy-pull-secret"
+    namespace = each.value
+  }
+  role_ref {
+    api_group = "rbac.authorization.k8s.io"
+    kind      = "Role"
+    name      = kubernetes_role_v1.pull_secret[each.value].metadata[0].name
+  }
+  subject {
+    kind      = "ServiceAccount"
+    name      = kubernetes_service_account.image_copy.metadata[0].name
+    namespace = var.namespace
+  }
+}
+
 data "aws_region" "current" {}
 
 resource "kubernetes_cron_job_v1" "image_copy" {
@@ -193,7 +314,7 @@ resource "kubernetes_cron_job_v1" "image_copy" {
 
               env {
                 name  = "DST_REPO_URI"
-                value = aws_ecr_repository.chainguard.repository_url
+                value = local.dst_repo_uri
               }
 
               env {
@@ -201,6 +322,56 @@ resource "kubernetes_cron_job_v1" "image_copy" {
                 value = var.updated_within
               }
 
+              env {
+                name  = "ACTIVE_TAGS_ONLY"
+                value = var.active_tags_only
+              }
+
+              env {
+                name  = "ECR_PUBLIC"
+                value = var.ecr_public
+              }
+
+              env {
+                name  = "DOCKER_MEDIA_TYPES"
+                value = var.docker_media_types
+              }
+
+              env {
+                name  = "HOOK_URL"
+                value = var.hook_url
+              }
+
+              env {
+                name  = "HOOK_COMMAND"
+                value = var.hook_command
+              }
+
+              env {
+                name  = "MAX_IMAGE_SIZE"
+                value = var.max_image_size
+              }
+
+              env {
+                name  = "MAX_LAYERS"
+                value = var.max_layers
+              }
+
+              env {
+                name  = "PULL_SECRET_NAMESPACES"
+                value = join(",", var.pull_secret_namespaces)
+              }
+
+              env {
+                name  = "PULL_SECRET_NAME"
+                value = var.pull_secret_name
+              }
+
+              env {
+                name  = "PULL_SECRET_ROLE_ARN"
+                value = length(aws_iam_role.pull_secret) > 0 ? aws_iam_role.pull_secret[0].arn : ""
+              }
+
               env {
                 name  = "AWS_REGION"
                 value = data.aws_region.current.name
@@ -25,6 +25,66 @@ variable "updated_within" {
   default     = "72h"
 }
 
+variable "active_tags_only" {
+  type        = bool
+  description = "Only copy the tags that are active in the Chainguard catalog, so that deprecated tags aren't copied."
+  default     = false
+}
+
+variable "ecr_public" {
+  type        = bool
+  description = "Copy the images to an ECR Public repository, rather than a private ECR repository. Requires ecr_public_alias."
+  default     = false
+}
+
+variable "ecr_public_alias" {
+  type        = string
+  description = "The default alias of your ECR Public registry. The images are copied to 'public.ecr.aws/<alias>/<repo_name>/<image_name>' when ecr_public is true."
+  default     = ""
+}
+
+variable "docker_media_types" {
+  type        = bool
+  description = "Convert the OCI manifests of the copied images to Docker schema2 manifests, for tools that can't consume OCI media types."
+  default     = false
+}
+
+variable "hook_url" {
+  type        = string
+  description = "A URL that's sent a POST request with the source, destination and digest of each image after it's been copied."
+  default     = ""
+}
+
+variable "hook_command" {
+  type        = string
+  description = "A command, run by sh -c, that's run after each image has been copied, with the source, destination and digest as JSON on stdin."
+  default     = ""
+}
+
+variable "max_image_size" {
+  type        = string
+  description = "The largest image that's copied, across all of its platforms, like '5GB'. Larger images are logged and skipped, without failing the job, so that they aren't retried. Empty means no limit."
+  default     = ""
+}
+
+variable "max_layers" {
+  type        = number
+  description = "The most layers in an image that's copied. Images with more layers are logged and skipped, without failing the job, so that they aren't retried. 0 means no limit."
+  default     = 0
+}
+
+variable "pull_secret_namespaces" {
+  type        = list(string)
+  description = "Namespaces to apply a pull secret for the copied images to. The secret only allows pulling and it's refreshed every time the job runs."
+  default     = []
+}
+
+variable "pull_secret_name" {
+  type        = string
+  description = "The name of the pull secret applied to pull_secret_namespaces."
+  default     = "image-copy-pull"
+}
+
 variable "image_platform" {
   type        = string
   description = "The platform of the image to build."
@@ -11,9 +11,35 @@ set -o pipefail
 : "${DST_REPO_URI?DST_REPO_URI is required.}"
 : "${UPDATED_WITHIN?UPDATED_WITHIN is required.}"
 
+# Optional environment variables
+ACTIVE_TAGS_ONLY="${ACTIVE_TAGS_ONLY:-false}"
+CATALOG_URL="${CATALOG_URL:-https://data.chainguard.dev/query}"
+ECR_PUBLIC="${ECR_PUBLIC:-false}"
+DOCKER_MEDIA_TYPES="${DOCKER_MEDIA_TYPES:-false}"
+HOOK_URL="${HOOK_URL:-}"
+HOOK_COMMAND="${HOOK_COMMAND:-}"
+MAX_IMAGE_SIZE="${MAX_IMAGE_SIZE:-}"
+MAX_LAYERS="${MAX_LAYERS:-0}"
+PULL_SECRET_NAMESPACES="${PULL_SECRET_NAMESPACES:-}"
+PULL_SECRET_NAME="${PULL_SECRET_NAME:-image-copy-pull}"
+PULL_SECRET_DIR="${PULL_SECRET_DIR:-}"
+PULL_SECRET_ROLE_ARN="${PULL_SECRET_ROLE_ARN:-}"
+TOKEN_REFRESH_INTERVAL="${TOKEN_REFRESH_INTERVAL:-600}"
+
+# ECR Public has its own API, which is only available in us-east-1, and its
+# own registry, public.ecr.aws
+ecr=(aws ecr)
+if [[ "${ECR_PUBLIC}" == "true" ]]; then
+  if [[ "${DST_REPO_URI}" != public.ecr.aws/* ]]; then
+    echo "DST_REPO_URI must be a public.ecr.aws repository when ECR_PUBLIC is true." >&2
+    exit 1
+  fi
+  ecr=(aws ecr-public --region us-east-1)
+fi
+
 # Login to AWS ECR
 echo "Logging into AWS ECR..." >&2
-aws ecr get-login-password \
+"${ecr[@]}" get-login-password \
   | crane auth login --username AWS --password-stdin "${DST_REPO_URI%%/*}"
 
 # Login to Chainguard.
@@ -25,27 +51,109 @@ aws ecr get-login-password \
 # Alternatively, you could use a Kubernetes service account token as the
 # --identity-token, as described on this page:
 # https://edu.chainguard.dev/chainguard/administration/assumable-ids/identity-examples/kubernetes-identity/
-echo "Logging into Chainguard..." >&2
-eval "$(aws configure export-credentials --format env)"
-aws_token=$(curl -X POST "https://sts.amazonaws.com/?Action=GetCallerIdentity&Version=2011-06-15" \
-  --aws-sigv4 "aws:amz:us-east-1:sts" \
-  --user "${AWS_ACCESS_KEY_ID}:${AWS_SECRET_ACCESS_KEY}" \
-  -H "x-amz-security-token: ${AWS_SESSION_TOKEN}" \
-  -H "Chainguard-Identity: ${IDENTITY_ID}" \
-  -H "Chainguard-Audience: https://issuer.enforce.dev" \
-  -H "Accept: application/json" \
-  -v 2>&1 > /dev/null \
-  | grep '^> ' \
-  | sed 's/> //' \
-  | base64 -w0
-)
-chainctl auth login \
-  --identity "${IDENTITY_ID}" \
-  --identity-token "${aws_token}"
-chainctl auth configure-docker \
-  --identity "${IDENTITY_ID}" \
-  --identity-token "${aws_token}" \
-  --audience=cgr.dev
+#
+# The signed request is only valid for a few minutes and the Chainguard token
+# it's exchanged for doesn't last for the whole of a long run either, so the
+# login is repeated every TOKEN_REFRESH_INTERVAL seconds and when a copy fails.
+chainguard_login() {
+  echo "Logging into Chainguard..." >&2
+
+  # The exported credentials expire too, so they're exported again from the
+  # credentials of the service account rather than reused
+  unset AWS_ACCESS_KEY_ID AWS_SECRET_ACCESS_KEY AWS_SESSION_TOKEN AWS_CREDENTIAL_EXPIRATION
+  eval "$(aws configure export-credentials --format env)"
+
+  local aws_token
+  aws_token=$(curl -X POST "https://sts.amazonaws.com/?Action=GetCallerIdentity&Version=2011-06-15" \
+    --aws-sigv4 "aws:amz:us-east-1:sts" \
+    --user "${AWS_ACCESS_KEY_ID}:${AWS_SECRET_ACCESS_KEY}" \
+    -H "x-amz-security-token: ${AWS_SESSION_TOKEN}" \
+    -H "Chainguard-Identity: ${IDENTITY_ID}" \
+    -H "Chainguard-Audience: https://issuer.enforce.dev" \
+    -H "Accept: application/json" \
+    -v 2>&1 > /dev/null \
+    | grep '^> ' \
+    | sed 's/> //' \
+    | base64 -w0
+  )
+  chainctl auth login \
+    --identity "${IDENTITY_ID}" \
+    --identity-token "${aws_token}"
+  chainctl auth configure-docker \
+    --identity "${IDENTITY_ID}" \
+    --identity-token "${aws_token}" \
+    --audience=cgr.dev
+
+  last_login="${SECONDS}"
+}
+chainguard_login
+
+# Writes credentials for pulling from the destination registry, so that the
+# consumers of the mirror can pull from it. They're applied as a
+# dockerconfigjson Secret to each of PULL_SECRET_NAMESPACES and written to
+# PULL_SECRET_DIR as a Docker config.json and a containerd auth config.
+#
+# The credentials are for PULL_SECRET_ROLE_ARN, which should only be allowed to
+# pull, rather than for the job's role, which can push. ECR credentials expire
+# after 12 hours, so they're refreshed every time the job runs.
+write_pull_secrets() {
+  if [[ -z "${PULL_SECRET_NAMESPACES}" && -z "${PULL_SECRET_DIR}" ]]; then
+    return 0
+  fi
+  if [[ "${ECR_PUBLIC}" == "true" ]]; then
+    echo "Images in ECR Public can be pulled without credentials, so there are no pull secrets to write." >&2
+    return 0
+  fi
+
+  local registry="${DST_REPO_URI%%/*}" creds password config ns
+  local namespaces=()
+
+  if [[ -n "${PULL_SECRET_ROLE_ARN}" ]]; then
+    creds=$(
+      aws sts assume-role \
+        --role-arn "${PULL_SECRET_ROLE_ARN}" \
+        --role-session-name image-copy-pull-secret \
+        --query Credentials \
+        --output json
+    )
+    password=$(
+      AWS_ACCESS_KEY_ID=$(jq -r '.AccessKeyId' <<<"${creds}") \
+      AWS_SECRET_ACCESS_KEY=$(jq -r '.SecretAccessKey' <<<"${creds}") \
+      AWS_SESSION_TOKEN=$(jq -r '.SessionToken' <<<"${creds}") \
+        aws ecr get-login-password
+    )
+  else
+    echo "PULL_SECRET_ROLE_ARN isn't set, so the pull secrets have the job's permissions, which include pushing." >&2
+    password=$(aws ecr get-login-password)
+  fi
+  config=$(
+    jq -nc --arg registry "${registry}" --arg auth "$(printf 'AWS:%s' "${password}" | base64 -w0)" \
+      '{auths: {($registry): {auth: $auth}}}'
+  )
+
+  if [[ -n "${PULL_SECRET_DIR}" ]]; then
+    echo "Writing pull secrets to ${PULL_SECRET_DIR}..." >&2
+    mkdir -p "${PULL_SECRET_DIR}"
+    printf '%s\n' "${config}" >"${PULL_SECRET_DIR}/config.json"
+    cat >"${PULL_SECRET_DIR}/containerd-auth.toml" <<EOF
+[plugins."io.containerd.grpc.v1.cri".registry.configs."${registry}".auth]
+  username = "AWS"
+  password = "${password}"
+EOF
+  fi
+
+  IFS=',' read -ra namespaces <<<"${PULL_SECRET_NAMESPACES}"
+  for ns in "${namespaces[@]}"; do
+    echo "Applying pull secret ${PULL_SECRET_NAME} to namespace ${ns}..." >&2
+    kubectl create secret generic "${PULL_SECRET_NAME}" \
+      --namespace "${ns}" \
+      --type kubernetes.io/dockerconfigjson \
+      --from-file .dockerconfigjson=<(printf '%s' "${config}") \
+      --dry-run=client \
+      -o yaml \
+      | kubectl apply -f - >&2
+  done
+}
 
 # List every recently updated image.
 #
@@ -59,16 +167,226 @@ image_list=$(
     | jq -cr '.[] | .repo.name as $repo | .tags[] | {repo: $repo, tag: .name}'
 )
 
+# Only copy the tags that are still active.
+#
+# The catalog lists the active tags of each Chainguard image, which excludes
+# the tags that have been deprecated or retired. Repos that aren't in the
+# catalog, like custom images, are copied as they were before.
+if [[ "${ACTIVE_TAGS_ONLY}" == "true" ]]; then
+  echo "Fetching active tags..." >&2
+  active_tags=$(
+    curl -sSf -X POST "${CATALOG_URL}" \
+      -H "Content-Type: application/json" \
+      -d '{"query": "query ChainguardPrivateImageCatalog { repos(filter: {uidp: {childrenOf: \"ce2d1984a010471142503340d670612d63ffb9f6\"}}) { name activeTags } }"}' \
+      | jq -c '[.data.repos[] | {key: .name, value: .activeTags}] | from_entries'
+  )
+
+  if [[ -n "${image_list}" ]]; then
+    jq -r --argjson active "${active_tags}" \
+      'select($active[.repo] != null and (.tag as $t | $active[.repo] | index($t) | not)) | "Skipping inactive tag \(.repo):\(.tag)"' \
+      <<<"${image_list}" >&2
+    image_list=$(
+      jq -c --argjson active "${active_tags}" \
+        'select($active[.repo] == null or (.tag as $t | $active[.repo] | index($t)))' \
+        <<<"${image_list}"
+    )
+  fi
+
+  # Log the tags that have been copied before but aren't active anymore. They
+  # aren't deleted, in case something still pulls them.
+  mirrored_repos=$(
+    "${ecr[@]}" describe-repositories \
+      --query "repositories[?starts_with(repositoryName, '${DST_REPO_NAME}/')].repositoryName" \
+      --output json \
+      | jq -r '.[]'
+  )
+  while read -r mirrored; do
+    [[ -z "${mirrored}" ]] && continue
+    repo="${mirrored#"${DST_REPO_NAME}/"}"
+    "${ecr[@]}" describe-images \
+      --repository-name "${mirrored}" \
+      --query 'imageDetails[].imageTags[]' \
+      --output json \
+      | jq -r --arg repo "${repo}" --argjson active "${active_tags}" \
+        'select($active[$repo] != null) | .[] | select(. as $t | $active[$repo] | index($t) | not) | "Mirrored tag \($repo):\(.) has been deactivated"' >&2
+  done <<<"${mirrored_repos}"
+fi
+
 # If there haven't been any recent updates then the list will be
 # empty.
 if [[ -z "${image_list}" ]]; then
   echo "No recently updated images found. Exiting." >&2
+  write_pull_secrets
   exit 0
 fi
 
 # Track which repos exist in AWS ECR
 declare -A created
 
+# The Docker schema2 media types of the OCI layers that have an equivalent.
+# Other layers, like zstd compressed layers, can't be converted.
+docker_layer_types='{
+  "application/vnd.oci.image.layer.v1.tar+gzip": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+  "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip": "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+}'
+
+# Prints the reason that an OCI image manifest can't be converted to a Docker
+# schema2 manifest without losing something, or nothing when it can be.
+# Annotations aren't counted, because they don't change how the image runs, but
+# they're dropped.
+unconvertible_reason() {
+  jq -r --argjson types "${docker_layer_types}" '
+    if .subject != null or .artifactType != null then
+      "it refers to another manifest or is an artifact"
+    elif .config.mediaType != "application/vnd.oci.image.config.v1+json" then
+      "its config is \(.config.mediaType)"
+    else
+      (first(.layers[] | select($types[.mediaType] == null) | "it has \(.mediaType) layers") // "")
+    end'
+}
+
+# Converts an OCI image manifest to a Docker schema2 manifest
+docker_manifest() {
+  jq -cj --argjson types "${docker_layer_types}" '{
+    schemaVersion: 2,
+    mediaType: "application/vnd.docker.distribution.manifest.v2+json",
+    config: {
+      mediaType: "application/vnd.docker.container.image.v1+json",
+      size: .config.size,
+      digest: .config.digest
+    },
+    layers: [.layers[] | {mediaType: $types[.mediaType], size, digest} + (if .urls then {urls} else {} end)]
+  }'
+}
+
+# Rewrites the OCI manifests of an image that has been copied to their Docker
+# schema2 equivalents and pushes them in place of the originals. Images that
+# can't be converted are left as they are. The digests of converted images
+# change, so signatures and attestations don't refer to them anymore.
+convert_to_docker() {
+  local ref="$1"
+  local name="${ref%:*}"
+  local manifest reason
+
+  manifest=$(crane manifest "${ref}")
+  case "$(jq -r '.mediaType' <<<"${manifest}")" in
+    application/vnd.oci.image.manifest.v1+json)
+      reason=$(unconvertible_reason <<<"${manifest}")
+      if [[ -n "${reason}" ]]; then
+        echo "Can't convert ${ref} to Docker media types, because ${reason}" >&2
+        return 0
+      fi
+      docker_manifest <<<"${manifest}" | crane edit manifest "${ref}" >/dev/null
+      ;;
+    application/vnd.oci.image.index.v1+json)
+      # Check every manifest before pushing anything, so that the index is
+      # either converted completely or not at all
+      local digests=() children=() digest child i converted new size
+      mapfile -t digests < <(jq -r '.manifests[].digest' <<<"${manifest}")
+      for digest in "${digests[@]}"; do
+        child=$(crane manifest "${name}@${digest}")
+        if [[ "$(jq -r '.mediaType' <<<"${child}")" != "application/vnd.oci.image.manifest.v1+json" ]]; then
+          reason="it contains a $(jq -r '.mediaType' <<<"${child}")"
+        else
+          reason=$(unconvertible_reason <<<"${child}")
+        fi
+        if [[ -n "${reason}" ]]; then
+          echo "Can't convert ${ref} to Docker media types, because ${reason}" >&2
+          return 0
+        fi
+        children+=("${child}")
+      done
+
+      for i in "${!digests[@]}"; do
+        converted=$(docker_manifest <<<"${children[$i]}")
+        new=$(printf '%s' "${converted}" | crane edit manifest "${name}@${digests[$i]}")
+        size=$(printf '%s' "${converted}" | wc -c)
+        manifest=$(
+          jq -c --arg old "${digests[$i]}" --arg new "${new#*@}" --argjson size "${size}" \
+            '(.manifests[] | select(.digest == $old)) |= {mediaType: "application/vnd.docker.distribution.manifest.v2+json", size: $size, digest: $new} + (if .platform then {platform} else {} end)' \
+            <<<"${manifest}"
+        )
+      done
+      jq -cj '{schemaVersion: 2, mediaType: "application/vnd.docker.distribution.manifest.list.v2+json", manifests}' <<<"${manifest}" \
+        | crane edit manifest "${ref}" >/dev/null
+      ;;
+  esac
+}
+
+# Prints the number of bytes in a size like 5GB, 512MiB or 1000000
+parse_size() {
+  jq -rn --arg size "$1" '
+    {"TIB": 1099511627776, "GIB": 1073741824, "MIB": 1048576, "KIB": 1024,
+     "TB": 1e12, "GB": 1e9, "MB": 1e6, "KB": 1e3, "B": 1} as $units
+    | ($size | ascii_upcase | capture("^\\s*(?<n>[0-9.]+)\\s*(?<unit>[A-Z]*)\\s*$")) // error("invalid size: \($size)")
+    | (if .unit == "" then 1 else ($units[.unit] // error("invalid size: \($size)")) end) as $multiplier
+    | .n | tonumber * $multiplier | floor'
+}
+
+max_size=0
+if [[ -n "${MAX_IMAGE_SIZE}" ]]; then
+  max_size=$(parse_size "${MAX_IMAGE_SIZE}")
+fi
+
+# Prints the reason that an image exceeds MAX_IMAGE_SIZE or MAX_LAYERS, or
+# nothing when it doesn't. The size is the compressed layers and configs of all
+# the platforms of the image and the layers are counted per platform. Only the
+# manifests are read.
+limit_violation() {
+  local ref="$1" manifest digest
+  local manifests=()
+
+  manifest=$(crane manifest "${ref}")
+  if jq -e '.manifests' <<<"${manifest}" >/dev/null; then
+    while read -r digest; do
+      manifests+=("$(crane manifest "${ref%:*}@${digest}")")
+    done < <(jq -r '.manifests[] | select(.mediaType | test("manifest")) | .digest' <<<"${manifest}")
+  else
+    manifests+=("${manifest}")
+  fi
+
+  printf '%s\n' "${manifests[@]}" | jq -rs --argjson max_size "${max_size}" --argjson max_layers "${MAX_LAYERS}" '
+    (map(.config.size + ([.layers[].size] | add // 0)) | add) as $size
+    | (map(.layers | length) | max) as $layers
+    | if $max_size > 0 and $size > $max_size then
+        "it is \($size) bytes, which is more than \($max_size)"
+      elif $max_layers > 0 and $layers > $max_layers then
+        "it has \($layers) layers, which is more than \($max_layers)"
+      else
+        empty
+      end'
+}
+
+# Track the images that weren't copied because they exceed the limits
+exceeded=()
+
+# Notifies the hooks that an image has been copied. The URL is sent the image
+# as JSON and the command is run with the JSON on stdin and the fields in
+# HOOK_SOURCE, HOOK_DESTINATION and HOOK_DIGEST. The image has already been
+# copied, so failing hooks are logged rather than failing the job.
+run_hooks() {
+  local src="$1" dst="$2" digest payload
+
+  digest=$(crane digest "${dst}" || true)
+  payload=$(jq -nc --arg src "${src}" --arg dst "${dst}" --arg digest "${digest}" \
+    '{source: $src, destination: $dst} + (if $digest != "" then {digest: $digest} else {} end)')
+
+  if [[ -n "${HOOK_URL}" ]]; then
+    if ! curl -sSf -X POST "${HOOK_URL}" \
+      -H "Content-Type: application/json" \
+      -d "${payload}" >/dev/null; then
+      echo "Hook ${HOOK_URL} failed for ${dst}" >&2
+    fi
+  fi
+
+  if [[ -n "${HOOK_COMMAND}" ]]; then
+    if ! HOOK_SOURCE="${src}" HOOK_DESTINATION="${dst}" HOOK_DIGEST="${digest}" \
+      sh -c "${HOOK_COMMAND}" <<<"${payload}" >&2; then
+      echo "Hook command failed for ${dst}" >&2
+    fi
+  fi
+}
+
 # Iterate over each image
 echo "Copying images..." >&2
 while read -r item; do
@@ -77,17 +395,56 @@ while read -r item; do
   src="cgr.dev/${ORG_NAME}/${repo}:${tag}"
   dst="${DST_REPO_URI}/${repo}:${tag}"
 
+  if (( SECONDS - last_login >= TOKEN_REFRESH_INTERVAL )); then
+    chainguard_login
+  fi
+
   # Ensure the AWS ECR repository exists 
   if [[ -n created["${repo}"] ]]; then
-    if ! aws ecr describe-repositories --repository-names "${DST_REPO_NAME}/${repo}" >/dev/null 2>&1; then
+    if ! "${ecr[@]}" describe-repositories --repository-names "${DST_REPO_NAME}/${repo}" >/dev/null 2>&1; then
       echo "Creating repository ${DST_REPO_NAME}/${repo}..." >&2
-      aws ecr create-repository --repository-name "${DST_REPO_NAME}/${repo}"
+      "${ecr[@]}" create-repository --repository-name "${DST_REPO_NAME}/${repo}"
     fi
     created["${repo}"]=1
   fi
 
+  # Check the image against the limits before copying it, so that an image
+  # that's too big is reported, rather than timing out the job
+  if [[ "${max_size}" != "0" || "${MAX_LAYERS}" != "0" ]]; then
+    reason=$(limit_violation "${src}")
+    if [[ -n "${reason}" ]]; then
+      echo "Not copying ${src}, because ${reason}" >&2
+      exceeded+=("${src}")
+      continue
+    fi
+  fi
+
   # You could use `cosign copy` here if you wanted to also copy the
   # signatures/attestations
   echo "Copying ${src} to ${dst}..." >&2
-  crane copy "${src}" "${dst}"
+  if ! crane copy "${src}" "${dst}"; then
+    # The token may have expired during the copy, so login again and give
+    # it one more try
+    echo "Copying ${src} failed. Logging in again and retrying..." >&2
+    chainguard_login
+    crane copy "${src}" "${dst}"
+  fi
+
+  if [[ "${DOCKER_MEDIA_TYPES}" == "true" ]]; then
+    echo "Converting ${dst} to Docker media types..." >&2
+    convert_to_docker "${dst}"
+  fi
+
+  if [[ -n "${HOOK_URL}" || -n "${HOOK_COMMAND}" ]]; then
+    run_hooks "${src}" "${dst}"
+  fi
 done <<<"${image_list}"
+
+# The images over the limits don't fail the job, because they'd still be over
+# them when it's retried
+if [[ "${#exceeded[@]}" -gt 0 ]]; then
+  echo "${#exceeded[@]} images exceed the limits and weren't copied:" >&2
+  printf '  %s\n' "${exceeded[@]}" >&2
+fi
+
+write_pull_secrets
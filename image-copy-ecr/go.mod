@@ -8,6 +8,9 @@ require (
 	github.com/aws/aws-sdk-go-v2/config v1.29.7
 	github.com/aws/aws-sdk-go-v2/service/ecr v1.41.1
 	github.com/awslabs/amazon-ecr-credential-helper/ecr-login v0.9.1
+	github.com/chainguard-dev/platform-examples/internal/hook v0.0.0
+	github.com/chainguard-dev/platform-examples/internal/limits v0.0.0
+	github.com/chainguard-dev/platform-examples/pkg/auth v0.0.0
 	github.com/coreos/go-oidc v2.3.0+incompatible
 	github.com/google/go-containerregistry v0.20.7
 	github.com/kelseyhightower/envconfig v1.4.0
@@ -102,3 +105,9 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	k8s.io/apimachinery v0.35.0 // indirect
 )
+
+replace github.com/chainguard-dev/platform-examples/internal/hook => ../internal/hook
+
+replace github.com/chainguard-dev/platform-examples/internal/limits => ../internal/limits
+
+replace github.com/chainguard-dev/platform-examples/pkg/auth => ../pkg/auth
@@ -121,6 +121,9 @@ resource "aws_lambda_function" "lambda" {
       REGION           = data.aws_region.current.name
       IMMUTABLE_TAGS   = var.immutable_tags
       IGNORE_REFERRERS = var.ignore_referrers
+      HOOK_URL         = var.hook_url
+      MAX_IMAGE_SIZE   = var.max_image_size
+      MAX_LAYERS       = var.max_layers
     }
   }
 }
@@ -14,26 +14,33 @@ import (
 	"path/filepath"
 	"strings"
 
-	"chainguard.dev/sdk/auth/aws"
 	cgevents "chainguard.dev/sdk/events"
 	"chainguard.dev/sdk/events/registry"
 	iam "chainguard.dev/sdk/proto/platform/iam/v1"
 	v1 "chainguard.dev/sdk/proto/platform/registry/v1"
-	"chainguard.dev/sdk/sts"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 	ecrcreds "github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	cgaws "github.com/chainguard-dev/platform-examples/pkg/auth/aws"
+	"github.com/chainguard-dev/platform-examples/internal/hook"
+	"github.com/chainguard-dev/platform-examples/internal/limits"
 	"github.com/coreos/go-oidc"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/kelseyhightower/envconfig"
 )
 
 var amazonKeychain authn.Keychain = authn.NewKeychainFromHelper(ecrcreds.NewECRHelper(ecrcreds.WithLogger(log.Writer())))
 
+// chainguard assumes the Chainguard identity with the AWS identity of the Lambda
+// function
+var chainguard *cgauth.Client
+
 var env = struct {
 	APIEndpoint     string `envconfig:"API_ENDPOINT" required:"true"`
 	Issuer          string `envconfig:"ISSUER_URL" required:"true"`
@@ -45,12 +52,30 @@ var env = struct {
 	FullDstRepo     string `envconfig:"FULL_DST_REPO" required:"true"`
 	ImmutableTags   bool   `envconfig:"IMMUTABLE_TAGS" required:"true"`
 	IgnoreReferrers bool   `envconfig:"IGNORE_REFERRERS" required:"true"`
+	HookURL         string `envconfig:"HOOK_URL"`
+	HookCommand     string `envconfig:"HOOK_COMMAND"`
+	MaxImageSize    string `envconfig:"MAX_IMAGE_SIZE"`
+	MaxLayers       int    `envconfig:"MAX_LAYERS"`
 }{}
 
+// hooks run after each image has been copied
+var hooks hook.Hooks
+
+// imageLimits are the largest images that are copied
+var imageLimits limits.Limits
+
 func init() {
 	if err := envconfig.Process("", &env); err != nil {
 		log.Fatalf("failed to process env var: %s", err)
 	}
+	chainguard = cgauth.New(env.Issuer, env.Identity, cgaws.Source{})
+	hooks = hook.New(env.HookURL, env.HookCommand)
+
+	maxSize, err := limits.ParseSize(env.MaxImageSize)
+	if err != nil {
+		log.Fatalf("failed to parse MAX_IMAGE_SIZE: %s", err)
+	}
+	imageLimits = limits.Limits{MaxSize: maxSize, MaxLayers: env.MaxLayers}
 }
 
 func main() { lambda.Start(handler) }
@@ -152,9 +177,23 @@ func handler(ctx context.Context, levent events.LambdaFunctionURLRequest) (resp
 		// When pushing to CGR we want to try the Chainguard keychain first, since the ECR keychain
 		// logs a misleading error message when it's invoked for a non-ECR registry. The CGR keychain
 		// does not log such an error, so it's better to try it first.
-		cgKeychain{},
+		chainguard.Keychain(),
 		amazonKeychain,
 	)
+
+	// Check the image against the limits before copying it, so that an image
+	// that's too big is reported, rather than timing out the function
+	if err := imageLimits.Check(src, remote.WithContext(ctx), remote.WithAuthFromKeychain(kc)); err != nil {
+		// An image over the limits is logged and skipped. Failing the
+		// invocation would only have it retried, and the image would
+		// still be over the limits.
+		var violation *limits.Violation
+		if errors.As(err, &violation) {
+			log.Printf("Not copying %s: %v", src, err)
+			return "", nil
+		}
+		return "", fmt.Errorf("checking limits: %w", err)
+	}
 	if env.ImmutableTags {
 		dig, err := crane.Digest(src, crane.WithAuthFromKeychain(kc))
 		if err != nil {
@@ -167,12 +206,19 @@ func handler(ctx context.Context, levent events.LambdaFunctionURLRequest) (resp
 		return "", fmt.Errorf("copying image: %w", err)
 	}
 	log.Printf("Copied %s to %s", src, dst)
+
+	// The image has been copied, so a failing hook is logged, rather than
+	// failing the invocation and copying the image again
+	if len(hooks) > 0 {
+		runHooks(ctx, src, dst, crane.WithAuthFromKeychain(kc))
+	}
+
 	return "", nil
 }
 
 func resolveRepositoryName(ctx context.Context, repoID string) (string, error) {
 	// Generate a token for the Chainguard API
-	tok, err := newToken(ctx, env.APIEndpoint)
+	tok, err := chainguard.Token(ctx, env.APIEndpoint)
 	if err != nil {
 		return "", fmt.Errorf("getting token: %w", err)
 	}
@@ -232,50 +278,18 @@ func resolveRepositoryName(ctx context.Context, repoID string) (string, error) {
 	return strings.Join(path, "/"), nil
 }
 
-// newToken generates a token using the AWS identity of the Lambda function.
-//
-// It does this by first generating an AWS token, then exchanging it for a Chainguard token for the
-// specified Chainguard identity, which has been set up to be assumed by the AWS identity.
-func newToken(ctx context.Context, audience string) (*sts.TokenPair, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load configuration, %w", err)
-	}
-
-	creds, err := cfg.Credentials.Retrieve(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve credentials, %w", err)
-	}
-
-	awsTok, err := aws.GenerateToken(ctx, creds, env.Issuer, env.Identity)
+// runHooks runs the hooks for an image that has been copied and logs any that
+// fail
+func runHooks(ctx context.Context, src, dst string, opts ...crane.Option) {
+	event := hook.Event{Source: src, Destination: dst}
+	dig, err := crane.Digest(dst, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("generating AWS token: %w", err)
-	}
-
-	exch := sts.New(env.Issuer, audience, sts.WithIdentity(env.Identity))
-	cgTok, err := exch.Exchange(ctx, awsTok)
-	if err != nil {
-		return nil, fmt.Errorf("exchanging token: %w", err)
-	}
-
-	return &cgTok, nil
-}
-
-// cgKeychain is an authn.Keychain that provides a Chainguard token capable of pulling from cgr.dev.
-type cgKeychain struct{}
-
-func (k cgKeychain) Resolve(res authn.Resource) (authn.Authenticator, error) {
-	if res.RegistryStr() != "cgr.dev" {
-		return authn.Anonymous, nil
+		log.Printf("getting digest of %s for hooks: %v", dst, err)
+	} else {
+		event.Digest = dig
 	}
 
-	tok, err := newToken(context.Background(), res.RegistryStr())
-	if err != nil {
-		return nil, fmt.Errorf("getting token: %w", err)
+	if err := hooks.Run(ctx, event); err != nil {
+		log.Printf("running hooks for %s: %v", dst, err)
 	}
-
-	return &authn.Basic{
-		Username: "_token",
-		Password: tok.AccessToken,
-	}, nil
 }
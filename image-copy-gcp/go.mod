@@ -4,9 +4,18 @@ go 1.25.0
 
 replace github.com/chainguard-dev/platform-examples => ../
 
+replace github.com/chainguard-dev/platform-examples/internal/hook => ../internal/hook
+
+replace github.com/chainguard-dev/platform-examples/internal/limits => ../internal/limits
+
+replace github.com/chainguard-dev/platform-examples/pkg/auth => ../pkg/auth
+
 require (
 	chainguard.dev/sdk v0.1.49
 	cloud.google.com/go/compute/metadata v0.9.0
+	github.com/chainguard-dev/platform-examples/internal/hook v0.0.0
+	github.com/chainguard-dev/platform-examples/internal/limits v0.0.0
+	github.com/chainguard-dev/platform-examples/pkg/auth v0.0.0
 	github.com/cloudevents/sdk-go/v2 v2.16.2
 	github.com/google/go-containerregistry v0.20.7
 	github.com/kelseyhightower/envconfig v1.4.0
@@ -179,6 +188,7 @@ require (
 	google.golang.org/protobuf v1.36.11 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
+	k8s.io/apimachinery v0.35.0 // indirect
 	k8s.io/klog/v2 v2.130.1 // indirect
 	sigs.k8s.io/structured-merge-diff/v6 v6.3.1 // indirect
 )
@@ -99,6 +99,18 @@ resource "google_cloud_run_service" "image-copy" {
           name  = "VERIFY_SIGNATURES"
           value = var.verify_signatures
         }
+        env {
+          name  = "HOOK_URL"
+          value = var.hook_url
+        }
+        env {
+          name  = "MAX_IMAGE_SIZE"
+          value = var.max_image_size
+        }
+        env {
+          name  = "MAX_LAYERS"
+          value = var.max_layers
+        }
       }
     }
   }
@@ -40,3 +40,21 @@ variable "verify_signatures" {
   description = "Whether to verify signatures before copying images."
   default     = false
 }
+
+variable "hook_url" {
+  type        = string
+  description = "A URL that's sent a POST request with the source, destination and digest of each image after it's been copied."
+  default     = ""
+}
+
+variable "max_image_size" {
+  type        = string
+  description = "The largest image that's copied, across all of its platforms, like '5GB'. Larger images are logged and skipped, without failing, so that they aren't retried. Empty means no limit."
+  default     = ""
+}
+
+variable "max_layers" {
+  type        = number
+  description = "The most layers in an image that's copied. Images with more layers are logged and skipped, without failing, so that they aren't retried. 0 means no limit."
+  default     = 0
+}
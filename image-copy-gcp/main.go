@@ -7,6 +7,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -19,8 +20,11 @@ import (
 	iam "chainguard.dev/sdk/proto/platform/iam/v1"
 	v1 "chainguard.dev/sdk/proto/platform/registry/v1"
 
-	"chainguard.dev/sdk/sts"
 	"cloud.google.com/go/compute/metadata"
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	cggcp "github.com/chainguard-dev/platform-examples/pkg/auth/gcp"
+	"github.com/chainguard-dev/platform-examples/internal/hook"
+	"github.com/chainguard-dev/platform-examples/internal/limits"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -31,7 +35,6 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	"github.com/sigstore/cosign/v2/pkg/cosign"
 	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
-	"google.golang.org/api/idtoken"
 )
 
 type envConfig struct {
@@ -44,16 +47,26 @@ type envConfig struct {
 	DstRepo          string `envconfig:"DST_REPO" required:"true"` // Almost fully qualified at this point, just needs the final component.
 	IgnoreReferrers  bool   `envconfig:"IGNORE_REFERRERS" required:"true"`
 	VerifySignatures bool   `envconfig:"VERIFY_SIGNATURES" required:"true"`
+	HookURL          string `envconfig:"HOOK_URL"`
+	HookCommand      string `envconfig:"HOOK_COMMAND"`
+	MaxImageSize     string `envconfig:"MAX_IMAGE_SIZE"`
+	MaxLayers        int    `envconfig:"MAX_LAYERS"`
 }
 
 var location, sa string
 var srcRepo name.Repository
 var env envConfig
 
-var keychain = authn.NewMultiKeychain(
-	google.Keychain,
-	cgKeychain{},
-)
+// chainguard assumes the Chainguard identity with the service account of the
+// Cloud Run service
+var chainguard *cgauth.Client
+var keychain authn.Keychain
+
+// hooks run after each image has been copied
+var hooks hook.Hooks
+
+// imageLimits are the largest images that are copied
+var imageLimits limits.Limits
 
 func init() {
 	var err error
@@ -68,6 +81,18 @@ func init() {
 	if err := envconfig.Process("", &env); err != nil {
 		log.Panicf("failed to process env var: %s", err)
 	}
+	chainguard = cgauth.New(env.Issuer, env.Identity, cggcp.Source{})
+	keychain = authn.NewMultiKeychain(
+		google.Keychain,
+		chainguard.Keychain(),
+	)
+	hooks = hook.New(env.HookURL, env.HookCommand)
+
+	maxSize, err := limits.ParseSize(env.MaxImageSize)
+	if err != nil {
+		log.Panicf("failed to parse MAX_IMAGE_SIZE: %s", err)
+	}
+	imageLimits = limits.Limits{MaxSize: maxSize, MaxLayers: env.MaxLayers}
 }
 
 func main() {
@@ -124,11 +149,33 @@ func main() {
 			}
 		}
 
+		// Check the image against the limits before copying it, so that an
+		// image that's too big is reported, rather than timing out the
+		// request
+		if err := imageLimits.Check(src, remote.WithContext(ctx), remote.WithAuthFromKeychain(keychain)); err != nil {
+			// An image over the limits is logged and skipped. Failing
+			// the event would only have it redelivered, and the image
+			// would still be over the limits.
+			var violation *limits.Violation
+			if errors.As(err, &violation) {
+				log.Printf("Not copying %s: %v", src, err)
+				return nil
+			}
+			return fmt.Errorf("checking limits: %w", err)
+		}
+
 		log.Printf("Copying %s to %s...", src, dst)
 		if err := crane.Copy(src, dst, crane.WithAuthFromKeychain(keychain)); err != nil {
 			return fmt.Errorf("copying image: %w", err)
 		}
 		log.Println("Copied!")
+
+		// The image has been copied, so a failing hook is logged, rather
+		// than failing the event and copying the image again
+		if len(hooks) > 0 {
+			runHooks(ctx, src, dst)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -157,7 +204,7 @@ func main() {
 
 func resolveRepositoryName(ctx context.Context, repoID string) (string, error) {
 	// Generate a token for the Chainguard API
-	tok, err := newToken(ctx, env.APIEndpoint)
+	tok, err := chainguard.Token(ctx, env.APIEndpoint)
 	if err != nil {
 		return "", fmt.Errorf("getting token: %w", err)
 	}
@@ -217,42 +264,6 @@ func resolveRepositoryName(ctx context.Context, repoID string) (string, error) {
 	return strings.Join(path, "/"), nil
 }
 
-func newToken(ctx context.Context, audience string) (*sts.TokenPair, error) {
-	exch := sts.New(env.Issuer, audience, sts.WithIdentity(env.Identity))
-	ts, err := idtoken.NewTokenSource(ctx, env.Issuer)
-	if err != nil {
-		return nil, fmt.Errorf("getting token source: %w", err)
-	}
-	tok, err := ts.Token()
-	if err != nil {
-		return nil, fmt.Errorf("getting token: %w", err)
-	}
-	cgTok, err := exch.Exchange(ctx, tok.AccessToken)
-	if err != nil {
-		return nil, fmt.Errorf("exchanging token: %w", err)
-	}
-
-	return &cgTok, nil
-}
-
-type cgKeychain struct{}
-
-func (k cgKeychain) Resolve(res authn.Resource) (authn.Authenticator, error) {
-	if res.RegistryStr() != "cgr.dev" {
-		return authn.Anonymous, nil
-	}
-
-	tok, err := newToken(context.Background(), res.RegistryStr())
-	if err != nil {
-		return nil, fmt.Errorf("getting token: %w", err)
-	}
-
-	return &authn.Basic{
-		Username: "_token",
-		Password: tok.AccessToken,
-	}, nil
-}
-
 func verifyImageSignatures(ctx context.Context, src string) (string, error) {
 	ref, err := name.ParseReference(src)
 	if err != nil {
@@ -291,7 +302,7 @@ func resolveDigest(ctx context.Context, ref name.Reference) (name.Digest, error)
 
 func checkOpts(ctx context.Context) (*cosign.CheckOpts, error) {
 	// Generate a token for the Chainguard API
-	tok, err := newToken(ctx, env.APIEndpoint)
+	tok, err := chainguard.Token(ctx, env.APIEndpoint)
 	if err != nil {
 		return nil, fmt.Errorf("getting token: %w", err)
 	}
@@ -345,3 +356,19 @@ func checkOpts(ctx context.Context) (*cosign.CheckOpts, error) {
 
 	return co, nil
 }
+
+// runHooks runs the hooks for an image that has been copied and logs any that
+// fail
+func runHooks(ctx context.Context, src, dst string) {
+	event := hook.Event{Source: src, Destination: dst}
+	dig, err := crane.Digest(dst, crane.WithAuthFromKeychain(keychain))
+	if err != nil {
+		log.Printf("getting digest of %s for hooks: %v", dst, err)
+	} else {
+		event.Digest = dig
+	}
+
+	if err := hooks.Run(ctx, event); err != nil {
+		log.Printf("running hooks for %s: %v", dst, err)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		CatalogCommand(),
+	)
+}
+
+func CatalogCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "catalog",
+		Short: "Inspect the Chainguard catalog data used by the mapper.",
+	}
+
+	cmd.AddCommand(
+		CatalogListCommand(),
+	)
+
+	return cmd
+}
+
+func CatalogListCommand() *cobra.Command {
+	opts := struct {
+		Format           string
+		Tiers            []string
+		IgnoreIamguarded bool
+		Rules            string
+		Aliases          []string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the repositories in the catalog that the mapper matches against.",
+		Example: `
+  # List every repository
+  image-mapper catalog list
+
+  # List APPLICATION tier repositories as JSON
+  image-mapper catalog list --tier APPLICATION --format json
+
+  # See the effect of alias overrides on the catalog data
+  image-mapper catalog list --aliases=aliases.yaml
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := mapper.NewRepoOutput(opts.Format)
+			if err != nil {
+				return fmt.Errorf("constructing output: %w", err)
+			}
+
+			repos, err := mapper.ListRepos(cmd.Context(), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale))
+			if err != nil {
+				return fmt.Errorf("listing repos: %w", err)
+			}
+
+			var ignoreFns []mapper.IgnoreFn
+			if len(opts.Tiers) > 0 {
+				ignoreFns = append(ignoreFns, mapper.OnlyTiers(opts.Tiers))
+			}
+			if opts.IgnoreIamguarded {
+				ignoreFns = append(ignoreFns, mapper.IgnoreIamguarded())
+			}
+			if opts.Rules != "" {
+				rules, err := mapper.LoadRules(opts.Rules)
+				if err != nil {
+					return err
+				}
+				ignoreFns = append(ignoreFns, rules.IgnoreFn())
+			}
+			repos = mapper.FilterRepos(repos, ignoreFns...)
+
+			slices.SortFunc(repos, func(a, b mapper.Repo) int {
+				return strings.Compare(a.Name, b.Name)
+			})
+
+			return output(os.Stdout, repos)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "Output format (json, text)")
+	cmd.Flags().StringSliceVar(&opts.Tiers, "tier", []string{}, "Only list Chainguard repos of specific tiers (PREMIUM, APPLICATION, BASE, FIPS, AI)")
+	cmd.Flags().BoolVar(&opts.IgnoreIamguarded, "ignore-iamguarded", false, "Ignore iamguarded images")
+	cmd.Flags().StringVar(&opts.Rules, "rules", "", "A YAML file of include and exclude rules that decide which Chainguard repos are considered, by tier or name. Include rules are applied first, then exclude rules.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+
+	return cmd
+}
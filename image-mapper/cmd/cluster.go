@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/cluster"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/registry"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/vulns"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		ClusterCommand(),
+	)
+}
+
+func ClusterCommand() *cobra.Command {
+	opts := struct {
+		Kubeconfig         string
+		Context            string
+		Namespace          string
+		Selector           string
+		OutputFormat       string
+		GroupBy            string
+		CheckEntitlements  bool
+		Filters            filterOptions
+		Repo               string
+		RepoMap            []string
+		TagStrategy        string
+		Aliases            []string
+		Vulns              bool
+		Sizes              bool
+		InsecureRegistries []string
+		Keychains          []string
+		Strict             bool
+	}{}
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Map the images running in a Kubernetes cluster to Chainguard images.",
+		Example: `
+# Map the images in every namespace of the cluster in the current context
+image-mapper cluster
+
+# Map the images in a single namespace
+image-mapper cluster --namespace=default
+
+# Map the images in pods that match a label selector
+image-mapper cluster --selector=app.kubernetes.io/part-of=example
+
+# Use a specific kubeconfig and context
+image-mapper cluster --kubeconfig=./kubeconfig --context=staging
+
+# Output a CSV report
+image-mapper cluster -o csv
+
+# Compare the vulnerabilities in each image with the image it maps to
+image-mapper cluster --vulns
+
+# Compare the size of each image with the image it maps to
+image-mapper cluster --sizes
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := mapper.NewOutput(opts.OutputFormat, mapper.WithGroupBy(opts.GroupBy))
+			if err != nil {
+				return fmt.Errorf("constructing output: %w", err)
+			}
+
+			images, err := cluster.ListImages(cmd.Context(), cluster.Options{
+				Kubeconfig:    opts.Kubeconfig,
+				Context:       opts.Context,
+				Namespace:     opts.Namespace,
+				LabelSelector: opts.Selector,
+			})
+			if err != nil {
+				return fmt.Errorf("listing images: %w", err)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			m, err := mapper.NewMapper(cmd.Context(), mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("creating mapper: %w", err)
+			}
+
+			mappings, err := m.MapAll(mapper.NewArgsIterator(images))
+			if err != nil {
+				return fmt.Errorf("mapping images: %w", err)
+			}
+			logWarnings(mappings...)
+			if describesOutput(opts.OutputFormat) {
+				m.Describe(mappings...)
+			}
+			if opts.CheckEntitlements {
+				if err := checkEntitlements(cmd, opts.InsecureRegistries, mappings...); err != nil {
+					return err
+				}
+			}
+			if opts.Vulns {
+				vulns.NewAnnotator(&vulns.Grype{InsecureRegistries: opts.InsecureRegistries}).Annotate(cmd.Context(), mappings...)
+			}
+			if opts.Sizes {
+				sizer, err := registry.NewSizer(registry.SizeOptions{InsecureRegistries: opts.InsecureRegistries, Keychains: opts.Keychains, Identity: chainguardIdentity})
+				if err != nil {
+					return fmt.Errorf("creating sizer: %w", err)
+				}
+				sizer.Annotate(cmd.Context(), mappings...)
+			}
+
+			if err := output(os.Stdout, mappings); err != nil {
+				return err
+			}
+
+			return checkStrict(cmd, opts.Strict, mappings...)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Kubeconfig, "kubeconfig", "", "Path to the kubeconfig file. Defaults to $KUBECONFIG or ~/.kube/config.")
+	cmd.Flags().StringVar(&opts.Context, "context", "", "The kubeconfig context to use. Defaults to the current context.")
+	cmd.Flags().StringVarP(&opts.Namespace, "namespace", "n", "", "Only map images in this namespace. Defaults to all namespaces.")
+	cmd.Flags().StringVarP(&opts.Selector, "selector", "l", "", "Only map images in pods that match this label selector.")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", outputFormatUsage())
+	cmd.Flags().BoolVar(&opts.CheckEntitlements, "check-entitlements", false, "With -o entitlements, list the repos in the registry the images are mapped to, with the credentials in the Docker config, to check which of the Chainguard repos the organization is entitled to.")
+	cmd.Flags().StringVar(&opts.GroupBy, "group-by", mapper.GroupByNone, "Group the images in the text and markdown output. target groups them under the Chainguard repo they map to.")
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "Exit with code 2 if any of the images can't be mapped, so that CI pipelines can require a mapping for every image.")
+	cmd.Flags().BoolVar(&opts.Vulns, "vulns", false, "Scan each image and the image it maps to with grype and include the number of vulnerabilities in each. Requires grype on the PATH.")
+	cmd.Flags().BoolVar(&opts.Sizes, "sizes", false, "Fetch the manifests of each image and the image it maps to and include the compressed size and number of layers of each. The linux/amd64 image is measured for multi-platform images.")
+	cmd.Flags().StringSliceVar(&opts.InsecureRegistries, "insecure-registry", []string{}, "Registries, including the port if there is one, like registry.local:5000, that are accessed over HTTP rather than HTTPS when fetching sizes with --sizes or scanning with --vulns.")
+	cmd.Flags().StringSliceVar(&opts.Keychains, "keychain", []string{}, "Cloud keychains used for registries without credentials in the Docker config when fetching sizes with --sizes. One or more of: "+strings.Join(registry.Keychains, ", ")+".")
+
+	return cmd
+}
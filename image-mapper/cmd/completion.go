@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+// completeImages completes the images given to a command with the names and
+// aliases of the repositories in the catalog. The catalog data cached by
+// earlier runs is used, even when it has expired, so that completion is fast.
+// It's only fetched when nothing has been cached yet.
+func completeImages(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	// The config isn't applied before completion, like it is before the
+	// command runs
+	if err := applyConfig(cmd); err != nil {
+		cobra.CompDebugln(err.Error(), true)
+	}
+
+	repos, err := mapper.CachedRepos(mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption())
+	if err != nil {
+		cobra.CompDebugln(err.Error(), true)
+	}
+	if len(repos) == 0 {
+		repos, err = mapper.ListRepos(cmd.Context(), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithCacheDuration(time.Hour))
+		if err != nil {
+			cobra.CompErrorln(err.Error())
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+
+	return mapper.CompleteImages(repos, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
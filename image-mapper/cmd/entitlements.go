@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/registry"
+	"github.com/spf13/cobra"
+)
+
+// checkEntitlements lists the repositories in the organizations that the
+// images are mapped to, like cgr.dev/example for cgr.dev/example/nginx, and
+// records which of the results are in them. The organization only lists the
+// repositories it's entitled to.
+func checkEntitlements(cmd *cobra.Command, insecure []string, mappings ...*mapper.Mapping) error {
+	var orgs []string
+	for _, e := range mapper.Entitlements(mappings...) {
+		if org := path.Dir(e.Repository); !slices.Contains(orgs, org) {
+			orgs = append(orgs, org)
+		}
+	}
+
+	var entitled []string
+	for _, org := range orgs {
+		repos, err := registry.ListRepositories(cmd.Context(), org, registry.Options{InsecureRegistries: insecure, Identity: chainguardIdentity})
+		if err != nil {
+			return fmt.Errorf("checking entitlements: %s: %w", org, err)
+		}
+		entitled = append(entitled, repos...)
+	}
+	mapper.SetEntitled(entitled, mappings...)
+
+	return nil
+}
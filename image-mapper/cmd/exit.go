@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by image-mapper
+const (
+	// ExitCodeError is returned when a command fails
+	ExitCodeError = 1
+
+	// ExitCodeUnmapped is returned when --strict is set and some of the
+	// images couldn't be mapped
+	ExitCodeUnmapped = 2
+)
+
+// UnmappedError is returned when --strict is set and some of the images
+// couldn't be mapped
+type UnmappedError struct {
+	// Images are the images that couldn't be mapped
+	Images []string
+
+	// Total is the number of images that were mapped, or not
+	Total int
+}
+
+func (e *UnmappedError) Error() string {
+	return fmt.Sprintf("%d of %d images couldn't be mapped: %s", len(e.Images), e.Total, strings.Join(e.Images, ", "))
+}
+
+// ExitCode returns the code to exit with for an error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var unmapped *UnmappedError
+	if errors.As(err, &unmapped) {
+		return ExitCodeUnmapped
+	}
+
+	return ExitCodeError
+}
+
+// checkStrict returns an UnmappedError when strict is set and some of the
+// mappings don't have any results. Each image is only counted once.
+func checkStrict(cmd *cobra.Command, strict bool, mappings ...*mapper.Mapping) error {
+	if !strict {
+		return nil
+	}
+
+	unmapped, total := unmappedImages(mappings...)
+
+	return unmappedError(cmd, unmapped, total)
+}
+
+// unmappedImages returns the images in the mappings that don't have any
+// results and the number of distinct images in the mappings
+func unmappedImages(mappings ...*mapper.Mapping) ([]string, int) {
+	var unmapped []string
+	seen := map[string]struct{}{}
+	for _, m := range mappings {
+		if _, ok := seen[m.Image]; ok {
+			continue
+		}
+		seen[m.Image] = struct{}{}
+
+		if len(m.Results) == 0 {
+			unmapped = append(unmapped, m.Image)
+		}
+	}
+
+	return unmapped, len(seen)
+}
+
+// unmappedError returns an UnmappedError for the images that couldn't be
+// mapped, or nil if they all were
+func unmappedError(cmd *cobra.Command, unmapped []string, total int) error {
+	if len(unmapped) == 0 {
+		return nil
+	}
+
+	// The usage doesn't help when the command worked, but the images
+	// couldn't be mapped
+	cmd.SilenceUsage = true
+
+	return &UnmappedError{Images: unmapped, Total: total}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+// filterOptions are the flags that decide which Chainguard repos are
+// considered for the mappings and the order of the results. Every subcommand
+// that maps images registers them.
+type filterOptions struct {
+	IgnoreTiers      []string
+	TierOrder        []string
+	IgnoreIamguarded bool
+	Rules            string
+}
+
+func (o *filterOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&o.IgnoreTiers, "ignore-tiers", []string{}, "Ignore Chainguard repos of specific tiers (PREMIUM, APPLICATION, BASE, FIPS, AI)")
+	cmd.Flags().StringSliceVar(&o.TierOrder, "tier-order", []string{}, "The order of preference of the tiers, like APPLICATION,BASE,FIPS, when Chainguard repos in more than one tier match an image. Results in the first tier come first, and results in tiers that aren't listed come last.")
+	cmd.Flags().BoolVar(&o.IgnoreIamguarded, "ignore-iamguarded", false, "Ignore iamguarded images")
+	cmd.Flags().StringVar(&o.Rules, "rules", "", "A YAML file of include and exclude rules that decide which Chainguard repos are considered, by tier or name. Include rules are applied first, then exclude rules.")
+}
+
+// ignoreFns returns the functions that ignore the repos the flags exclude,
+// for mapper.WithIgnoreFns
+func (o *filterOptions) ignoreFns() ([]mapper.IgnoreFn, error) {
+	var ignoreFns []mapper.IgnoreFn
+	if len(o.IgnoreTiers) > 0 {
+		ignoreFns = append(ignoreFns, mapper.IgnoreTiers(o.IgnoreTiers))
+	}
+	if o.IgnoreIamguarded {
+		ignoreFns = append(ignoreFns, mapper.IgnoreIamguarded())
+	}
+	if o.Rules != "" {
+		rules, err := mapper.LoadRules(o.Rules)
+		if err != nil {
+			return nil, err
+		}
+		ignoreFns = append(ignoreFns, rules.IgnoreFn())
+	}
+
+	return ignoreFns, nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/helm"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		HelmCommand(),
+	)
+}
+
+func HelmCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "helm",
+		Short: "Integrate with Helm.",
+	}
+
+	cmd.AddCommand(
+		HelmPostRendererCommand(),
+	)
+
+	return cmd
+}
+
+func HelmPostRendererCommand() *cobra.Command {
+	opts := struct {
+		Filters     filterOptions
+		Repo        string
+		RepoMap     []string
+		TagStrategy string
+		Aliases     []string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "post-renderer",
+		Short: "Map the images in manifests rendered by Helm to Chainguard, as a Helm post-renderer.",
+		Example: `
+  # Install a chart with the images mapped to Chainguard
+  helm install argocd argocd/argo-cd --post-renderer image-mapper --post-renderer-args helm --post-renderer-args post-renderer
+
+  # Map the images to your own mirror or proxy
+  helm install argocd argocd/argo-cd --post-renderer image-mapper --post-renderer-args helm --post-renderer-args post-renderer --post-renderer-args --repository=registry.internal/cgr
+
+  # Preview the rewritten manifests
+  helm template argocd/argo-cd | image-mapper helm post-renderer
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("reading stdin: %w", err)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			output, err := helm.PostRender(cmd.Context(), input, mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("mapping manifests: %w", err)
+			}
+
+			if _, err := os.Stdout.Write(output); err != nil {
+				return fmt.Errorf("writing output: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+
+	return cmd
+}
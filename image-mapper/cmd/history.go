@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// recordHistory appends a summary of the run to the history file and posts
+// it to the endpoint, when either is set
+func recordHistory(cmd *cobra.Command, file, url, label string, total, unmapped int) error {
+	if file == "" && url == "" {
+		return nil
+	}
+
+	command := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+	entry := history.NewEntry(command, label, total, unmapped)
+
+	if file != "" {
+		if err := history.Append(file, entry); err != nil {
+			return fmt.Errorf("recording history: %s: %w", file, err)
+		}
+	}
+	if url != "" {
+		if err := history.Post(cmd.Context(), url, entry); err != nil {
+			return fmt.Errorf("posting history: %s: %w", url, err)
+		}
+	}
+
+	return nil
+}
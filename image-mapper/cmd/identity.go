@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	cgaws "github.com/chainguard-dev/platform-examples/pkg/auth/aws"
+	cgazure "github.com/chainguard-dev/platform-examples/pkg/auth/azure"
+	cggcp "github.com/chainguard-dev/platform-examples/pkg/auth/gcp"
+)
+
+// DefaultIssuer is the Chainguard issuer that identity tokens are exchanged
+// with
+const DefaultIssuer = "https://issuer.enforce.dev"
+
+// identityProviders are the sources of the tokens that are exchanged for the
+// Chainguard identity
+var identityProviders = []string{"aws", "gcp", "azure", "static"}
+
+var identityOpts = struct {
+	Identity string
+	Provider string
+	Token    string
+	ClientID string
+	Issuer   string
+}{}
+
+// chainguardIdentity assumes the Chainguard identity set with --identity, or
+// is nil when there isn't one. It pulls from cgr.dev, when there are no
+// credentials for it in the Docker config, and reads the catalog from the
+// platform API.
+var chainguardIdentity *cgauth.Client
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&identityOpts.Identity, "identity", "", "ID of a Chainguard assumable identity to pull from cgr.dev and read a chainguard:// --catalog-url as, rather than the credentials in the Docker config.")
+	rootCmd.PersistentFlags().StringVar(&identityOpts.Provider, "identity-provider", "", "Where the token that's exchanged for --identity comes from ("+strings.Join(identityProviders, ", ")+"). Defaults to static when --identity-token is set.")
+	rootCmd.PersistentFlags().StringVar(&identityOpts.Token, "identity-token", "", "The token that's exchanged for --identity with --identity-provider=static, like the OIDC token of a CI job.")
+	rootCmd.PersistentFlags().StringVar(&identityOpts.ClientID, "identity-client-id", "", "Client ID of the user-assigned managed identity that's used with --identity-provider=azure. The system-assigned identity is used when it's not set.")
+	rootCmd.PersistentFlags().StringVar(&identityOpts.Issuer, "issuer", DefaultIssuer, "Chainguard issuer that the token is exchanged with for --identity.")
+}
+
+// configureIdentity sets up the Chainguard identity when --identity is set
+func configureIdentity() error {
+	if identityOpts.Identity == "" {
+		if identityOpts.Provider != "" || identityOpts.Token != "" {
+			return fmt.Errorf("--identity-provider and --identity-token need --identity")
+		}
+		return nil
+	}
+
+	provider := identityOpts.Provider
+	if provider == "" && identityOpts.Token != "" {
+		provider = "static"
+	}
+
+	var source cgauth.IdentitySource
+	switch provider {
+	case "aws":
+		source = cgaws.Source{}
+	case "gcp":
+		source = cggcp.Source{}
+	case "azure":
+		source = cgazure.Source{ClientID: identityOpts.ClientID}
+	case "static":
+		if identityOpts.Token == "" {
+			return fmt.Errorf("--identity-provider=static needs --identity-token")
+		}
+		source = cgauth.Static(identityOpts.Token)
+	case "":
+		return fmt.Errorf("--identity needs --identity-provider or --identity-token")
+	default:
+		return fmt.Errorf("unsupported identity provider: %s (supported: %s)", provider, strings.Join(identityProviders, ", "))
+	}
+
+	chainguardIdentity = cgauth.New(identityOpts.Issuer, identityOpts.Identity, source)
+
+	return nil
+}
+
+// identityOption configures the mapper to read a chainguard:// catalog URL as
+// the Chainguard identity, when there is one
+func identityOption() mapper.Option {
+	if chainguardIdentity == nil {
+		return mapper.WithIdentity(nil)
+	}
+
+	return mapper.WithIdentity(chainguardIdentity)
+}
@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/registry"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/vulns"
 	"github.com/spf13/cobra"
 )
 
@@ -16,57 +20,213 @@ func init() {
 
 func MapCommand() *cobra.Command {
 	opts := struct {
-		OutputFormat     string
-		IgnoreTiers      []string
-		IgnoreIamguarded bool
-		Repo             string
+		OutputFormat       string
+		GroupBy            string
+		CheckEntitlements  bool
+		Filters            filterOptions
+		Repo               string
+		RepoMap            []string
+		TagStrategy        string
+		Aliases            []string
+		FromFiles          []string
+		Vulns              bool
+		Sizes              bool
+		ResolveDigests     bool
+		ResolveOrigins     bool
+		InsecureRegistries []string
+		Keychains          []string
+		Strict             bool
+		Schema             bool
+		HistoryFile        string
+		HistoryURL         string
+		HistoryLabel       string
 	}{}
 	cmd := &cobra.Command{
-		Use:   "map",
-		Short: "Map upstream image references to Chainguard images.",
-		Args:  cobra.MinimumNArgs(1),
+		Use:               "map",
+		Short:             "Map upstream image references to Chainguard images.",
+		ValidArgsFunction: completeImages,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && len(opts.FromFiles) == 0 && !opts.Schema {
+				return fmt.Errorf("requires at least 1 arg or --from-file")
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			output, err := mapper.NewOutput(opts.OutputFormat)
+			if opts.Schema {
+				_, err := os.Stdout.Write(mapper.Schema)
+				return err
+			}
+
+			var its []mapper.Iterator
+			switch {
+			case len(args) > 0 && args[0] == "-":
+				its = append(its, mapper.NewReaderIterator(os.Stdin))
+			case len(args) > 0:
+				its = append(its, mapper.NewArgsIterator(args))
+			}
+			for _, path := range opts.FromFiles {
+				f, err := os.Open(path)
+				if err != nil {
+					return fmt.Errorf("opening file: %s: %w", path, err)
+				}
+				defer f.Close()
+
+				its = append(its, mapper.NewListIterator(f))
+			}
+
+			output, err := mapper.NewOutput(opts.OutputFormat, mapper.WithGroupBy(opts.GroupBy))
 			if err != nil {
 				return fmt.Errorf("constructing output: %w", err)
 			}
 
-			var ignoreFns []mapper.IgnoreFn
-			if len(opts.IgnoreTiers) > 0 {
-				ignoreFns = append(ignoreFns, mapper.IgnoreTiers(opts.IgnoreTiers))
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			mapperOpts := []mapper.Option{mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...)}
+			if opts.ResolveDigests {
+				resolver, err := registry.NewDigestResolver(cmd.Context(), registry.DigestOptions{InsecureRegistries: opts.InsecureRegistries, Keychains: opts.Keychains, Identity: chainguardIdentity})
+				if err != nil {
+					return fmt.Errorf("creating digest resolver: %w", err)
+				}
+				mapperOpts = append(mapperOpts, mapper.WithDigestResolver(resolver))
 			}
-			if opts.IgnoreIamguarded {
-				ignoreFns = append(ignoreFns, mapper.IgnoreIamguarded())
+			if opts.ResolveOrigins {
+				resolver, err := registry.NewOriginResolver(cmd.Context(), registry.OriginOptions{InsecureRegistries: opts.InsecureRegistries, Keychains: opts.Keychains, Identity: chainguardIdentity})
+				if err != nil {
+					return fmt.Errorf("creating origin resolver: %w", err)
+				}
+				mapperOpts = append(mapperOpts, mapper.WithOriginResolver(resolver))
 			}
-			m, err := mapper.NewMapper(cmd.Context(), mapper.WithRepository(opts.Repo), mapper.WithIgnoreFns(ignoreFns...))
+			m, err := mapper.NewMapper(cmd.Context(), mapperOpts...)
 			if err != nil {
 				return fmt.Errorf("creating mapper: %w", err)
 			}
 
-			it := mapper.NewArgsIterator(args)
-			if args[0] == "-" {
-				it = mapper.NewReaderIterator(os.Stdin)
+			var annotator *vulns.Annotator
+			if opts.Vulns {
+				annotator = vulns.NewAnnotator(&vulns.Grype{InsecureRegistries: opts.InsecureRegistries})
 			}
+			var sizer *registry.Sizer
+			if opts.Sizes {
+				sizer, err = registry.NewSizer(registry.SizeOptions{InsecureRegistries: opts.InsecureRegistries, Keychains: opts.Keychains, Identity: chainguardIdentity})
+				if err != nil {
+					return fmt.Errorf("creating sizer: %w", err)
+				}
+			}
+
+			// Stream the mappings when the format supports it, so
+			// that very large inputs aren't held in memory
+			if stream, ok := mapper.NewStreamOutput(opts.OutputFormat); ok {
+				// Only the unmapped images are kept, so that --strict
+				// doesn't hold on to every mapping
+				var unmapped []string
+				total := 0
+				if err := m.MapEach(mapper.NewMultiIterator(its...), func(mapping *mapper.Mapping) error {
+					total++
+					if len(mapping.Results) == 0 {
+						unmapped = append(unmapped, mapping.Image)
+					}
+					logWarnings(mapping)
+					if annotator != nil {
+						annotator.Annotate(cmd.Context(), mapping)
+					}
+					if sizer != nil {
+						sizer.Annotate(cmd.Context(), mapping)
+					}
+					return stream(os.Stdout, mapping)
+				}); err != nil {
+					return fmt.Errorf("mapping images: %w", err)
+				}
+				if err := recordHistory(cmd, opts.HistoryFile, opts.HistoryURL, opts.HistoryLabel, total, len(unmapped)); err != nil {
+					return err
+				}
+				if opts.Strict {
+					return unmappedError(cmd, unmapped, total)
+				}
 
-			mappings, err := m.MapAll(it)
+				return nil
+			}
+
+			mappings, err := m.MapAll(mapper.NewMultiIterator(its...))
 			if err != nil {
 				return fmt.Errorf("mapping images: %w", err)
 			}
+			logWarnings(mappings...)
+			if describesOutput(opts.OutputFormat) {
+				m.Describe(mappings...)
+			}
+			if opts.CheckEntitlements {
+				if err := checkEntitlements(cmd, opts.InsecureRegistries, mappings...); err != nil {
+					return err
+				}
+			}
+			if annotator != nil {
+				annotator.Annotate(cmd.Context(), mappings...)
+			}
+			if sizer != nil {
+				sizer.Annotate(cmd.Context(), mappings...)
+			}
+
+			if err := output(os.Stdout, mappings); err != nil {
+				return err
+			}
+
+			unmapped, total := unmappedImages(mappings...)
+			if err := recordHistory(cmd, opts.HistoryFile, opts.HistoryURL, opts.HistoryLabel, total, len(unmapped)); err != nil {
+				return err
+			}
 
-			return output(os.Stdout, mappings)
+			return checkStrict(cmd, opts.Strict, mappings...)
 		},
 	}
 
-	rootCmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "Output format (csv, json, text, customer-yaml)")
-	rootCmd.Flags().StringSliceVar(&opts.IgnoreTiers, "ignore-tiers", []string{}, "Ignore Chainguard repos of specific tiers (PREMIUM, APPLICATION, BASE, FIPS, AI)")
-	rootCmd.Flags().BoolVar(&opts.IgnoreIamguarded, "ignore-iamguarded", false, "Ignore iamguarded images")
-	rootCmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", outputFormatUsage())
+	cmd.Flags().BoolVar(&opts.CheckEntitlements, "check-entitlements", false, "With -o entitlements, list the repos in the registry the images are mapped to, with the credentials in the Docker config, to check which of the Chainguard repos the organization is entitled to.")
+	cmd.Flags().StringVar(&opts.GroupBy, "group-by", mapper.GroupByNone, "Group the images in the text and markdown output. target groups them under the Chainguard repo they map to.")
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.FromFiles, "from-file", []string{}, "Files containing lists of images to map. Images can be separated by newlines or whitespace and lines starting with # are ignored.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "Exit with code 2 if any of the images can't be mapped, so that CI pipelines can require a mapping for every image.")
+	cmd.Flags().StringVar(&opts.HistoryFile, "history-file", "", "Append a summary of the run, with the number of images that were and weren't mapped and the coverage percentage, to this file as a line of JSON, so that migration progress can be charted over time.")
+	cmd.Flags().StringVar(&opts.HistoryURL, "history-url", "", "POST a JSON summary of the run, like the lines of --history-file, to this URL.")
+	cmd.Flags().StringVar(&opts.HistoryLabel, "history-label", "", "A label for the run in the history, like the name of the application or repository, so that the runs of different targets can be told apart.")
+	cmd.Flags().BoolVar(&opts.Schema, "schema", false, "Print the JSON schema of the json and jsonl outputs and exit.")
+	cmd.Flags().BoolVar(&opts.Vulns, "vulns", false, "Scan each image and the image it maps to with grype and include the number of vulnerabilities in each. Requires grype on the PATH.")
+	cmd.Flags().BoolVar(&opts.Sizes, "sizes", false, "Fetch the manifests of each image and the image it maps to and include the compressed size and number of layers of each. The linux/amd64 image is measured for multi-platform images.")
+	cmd.Flags().BoolVar(&opts.ResolveDigests, "resolve-digests", false, "Resolve images that are pinned to a digest without a tag, like nginx@sha256:..., to the tag in their repository with the same digest, so they're mapped by their version rather than as latest.")
+	cmd.Flags().BoolVar(&opts.ResolveOrigins, "resolve-origins", false, "Look for images that are pinned to a digest without a tag, and that don't match anything, in "+strings.Join(registry.DefaultOriginRegistries, " and ")+", so that images pulled through a proxy, like artifactory.internal/docker-remote/bitnami/nginx@sha256:..., are mapped like the repository they came from.")
+	cmd.Flags().StringSliceVar(&opts.InsecureRegistries, "insecure-registry", []string{}, "Registries, including the port if there is one, like registry.local:5000, that are accessed over HTTP rather than HTTPS when fetching sizes with --sizes, scanning with --vulns or resolving digests and origins with --resolve-digests and --resolve-origins.")
+	cmd.Flags().StringSliceVar(&opts.Keychains, "keychain", []string{}, "Cloud keychains used for registries without credentials in the Docker config when fetching sizes with --sizes or resolving digests and origins with --resolve-digests and --resolve-origins. One or more of: "+strings.Join(registry.Keychains, ", ")+".")
 
 	cmd.AddCommand(
+		MapComposeCommand(),
+		MapDevContainerCommand(),
+		MapDockerCommand(),
 		MapDockerfileCommand(),
 		MapHelmChartCommand(),
 		MapHelmValuesCommand(),
+		MapHelmfileCommand(),
+		MapIACCommand(),
+		MapKustomizeCommand(),
+		MapManifestsCommand(),
+		MapPipelinesCommand(),
+		MapRegistryCommand(),
+		MapSBOMCommand(),
+		MapSkaffoldCommand(),
 	)
 
 	return cmd
 }
+
+// logWarnings logs any warnings in the mappings
+func logWarnings(mappings ...*mapper.Mapping) {
+	for _, mapping := range mappings {
+		for _, warning := range mapping.Warnings {
+			slog.Warn(warning, "image", mapping.Image)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/devconfig"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func MapComposeCommand() *cobra.Command {
+	opts := struct {
+		Filters     filterOptions
+		Repo        string
+		RepoMap     []string
+		TagStrategy string
+		Aliases     []string
+		Write       bool
+		Diff        bool
+	}{}
+	cmd := &cobra.Command{
+		Use:   "compose",
+		Short: "Map the images of the services in Docker Compose files to their Chainguard equivalents.",
+		Example: `
+# Map the images in a Compose file
+image-mapper map compose compose.yaml
+
+# Map the images in a Compose file from stdin
+cat compose.yaml | image-mapper map compose -
+
+# Print a diff of the changes to the Compose files, which can be applied with 'patch -p1' or 'git apply'
+image-mapper map compose compose.yaml compose.override.yaml --diff
+
+# Update the Compose file in place
+image-mapper map compose compose.yaml --write
+
+# Map the Dockerfiles of the services that are built
+image-mapper map dockerfile . --diff
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The mapped files can't be concatenated like
+			// manifests can
+			if len(args) > 1 && !opts.Diff && !opts.Write {
+				return fmt.Errorf("more than one file can only be mapped with --diff or --write")
+			}
+
+			ropts := rewriteOptions{StdinName: "compose.yaml", Diff: opts.Diff, Write: opts.Write}
+			if err := ropts.validate(args); err != nil {
+				return err
+			}
+
+			m, err := newManifestMapper(cmd.Context(), opts.Repo, opts.RepoMap, opts.TagStrategy, opts.Aliases, opts.Filters)
+			if err != nil {
+				return err
+			}
+
+			return rewriteFiles(m, args, ropts, func(m mapper.Mapper, input []byte) ([]byte, error) {
+				return devconfig.EditComposeImages(input, mapImageFunc(m))
+			})
+		},
+	}
+
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().BoolVar(&opts.Write, "write", false, "Update the files in place, rather than writing the result to stdout.")
+	cmd.Flags().BoolVar(&opts.Diff, "diff", false, "Print a unified diff of the changes, rather than the mapped Compose file.")
+
+	return cmd
+}
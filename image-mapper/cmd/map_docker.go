@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/daemon"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func MapDockerCommand() *cobra.Command {
+	opts := struct {
+		Host         string
+		OutputFormat string
+		Filters      filterOptions
+		Repo         string
+		RepoMap      []string
+		TagStrategy  string
+		Aliases      []string
+		Strict       bool
+	}{}
+	cmd := &cobra.Command{
+		Use:     "docker",
+		Aliases: []string{"podman"},
+		Short:   "Map the images in the local Docker or Podman daemon to Chainguard images.",
+		Example: `
+# Map the images in the local Docker daemon
+image-mapper map docker
+
+# Map the images in a rootless Podman daemon
+image-mapper map podman --host=unix://$XDG_RUNTIME_DIR/podman/podman.sock
+
+# Output a CSV report
+image-mapper map docker -o csv
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := mapper.NewOutput(opts.OutputFormat)
+			if err != nil {
+				return fmt.Errorf("constructing output: %w", err)
+			}
+
+			images, err := daemon.ListImages(cmd.Context(), daemon.Options{
+				Host: opts.Host,
+			})
+			if err != nil {
+				return fmt.Errorf("listing images: %w", err)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			m, err := mapper.NewMapper(cmd.Context(), mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("creating mapper: %w", err)
+			}
+
+			mappings, err := m.MapAll(mapper.NewArgsIterator(images))
+			if err != nil {
+				return fmt.Errorf("mapping images: %w", err)
+			}
+			logWarnings(mappings...)
+			if describesOutput(opts.OutputFormat) {
+				m.Describe(mappings...)
+			}
+
+			if err := output(os.Stdout, mappings); err != nil {
+				return err
+			}
+
+			return checkStrict(cmd, opts.Strict, mappings...)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Host, "host", "H", "", "The address of the daemon, i.e unix:///var/run/docker.sock. Defaults to $DOCKER_HOST, or the first Docker or Podman socket that exists.")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", outputFormatUsage())
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "Exit with code 2 if any of the images can't be mapped, so that CI pipelines can require a mapping for every image.")
+
+	return cmd
+}
@@ -1,22 +1,39 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
 
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/diff"
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/dockerfile"
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/watch"
 	"github.com/spf13/cobra"
 )
 
 func MapDockerfileCommand() *cobra.Command {
 	opts := struct {
-		Repo string
+		Filters     filterOptions
+		Repo        string
+		RepoMap     []string
+		TagStrategy string
+		Aliases     []string
+		BuildArgs   []string
+		Annotate    bool
+		Write       bool
+		Watch       bool
+		Diff        bool
+		Workers     int
 	}{}
 	cmd := &cobra.Command{
 		Use:   "dockerfile",
-		Short: "Map image references in a Dockerfile to their Chainguard equivalents.",
+		Short: "Map image references in a Dockerfile, or a directory of Dockerfiles, to their Chainguard equivalents.",
 		Example: `
 # Map a Dockerfile
 image-mapper map dockerfile Dockerfile
@@ -26,40 +43,183 @@ cat Dockerfile | image-mapper map dockerfile -
 
 # Override the repository in the mappings with your own mirror or proxy. For instance, cgr.dev/chainguard/<image> would become registry.internal/cgr/<image> in the output.
 image-mapper map dockerfile Dockerfile --repository=registry.internal/cgr
+
+# Override the values of ARG instructions used in FROM instructions
+image-mapper map dockerfile Dockerfile --build-arg BASE_IMAGE=python:3.13
+
+# Add comments above FROM instructions that couldn't be mapped, or that need checking
+image-mapper map dockerfile Dockerfile --annotate
+
+# Print a diff of the changes to a Dockerfile, which can be applied with 'patch -p1' or 'git apply'
+image-mapper map dockerfile Dockerfile --diff
+
+# Map every Dockerfile and Containerfile in a directory and print a diff of the changes
+image-mapper map dockerfile .
+
+# Map every Dockerfile and Containerfile in a directory and update them in place
+image-mapper map dockerfile . --write
+
+# Map a Dockerfile again each time it changes and print a diff of the output
+image-mapper map dockerfile Dockerfile --watch
 `,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var (
-				input []byte
-				err   error
-			)
-			switch args[0] {
-			case "-":
-				input, err = io.ReadAll(os.Stdin)
+			buildArgs := map[string]string{}
+			for _, arg := range opts.BuildArgs {
+				key, value, ok := strings.Cut(arg, "=")
+				if !ok {
+					return fmt.Errorf("invalid build arg: %s: must be in the form KEY=VALUE", arg)
+				}
+				buildArgs[key] = value
+			}
+
+			if opts.Write && opts.Diff {
+				return fmt.Errorf("--diff can't be used with --write")
+			}
+
+			dopts := dockerfile.Options{BuildArgs: buildArgs, Annotate: opts.Annotate, Workers: opts.Workers}
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			mopts := []mapper.Option{mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...)}
+
+			if args[0] == "-" {
+				if opts.Write {
+					return fmt.Errorf("--write can't be used with stdin")
+				}
+				if opts.Watch {
+					return fmt.Errorf("--watch can't be used with stdin")
+				}
+
+				input, err := io.ReadAll(os.Stdin)
 				if err != nil {
 					return fmt.Errorf("reading stdin: %w", err)
 				}
-			default:
-				input, err = os.ReadFile(args[0])
+
+				output, err := dockerfile.Map(cmd.Context(), input, dopts, mopts...)
 				if err != nil {
-					return fmt.Errorf("reading file: %s: %w", args[0], err)
+					return fmt.Errorf("mapping dockerfile: %w", err)
+				}
+
+				if opts.Diff {
+					d, err := diff.File("Dockerfile", input, output)
+					if err != nil {
+						return err
+					}
+					output = []byte(d)
 				}
+
+				if _, err := os.Stdout.Write(output); err != nil {
+					return fmt.Errorf("writing output: %w", err)
+				}
+
+				return nil
 			}
 
-			output, err := dockerfile.Map(cmd.Context(), input, mapper.WithRepository(opts.Repo))
+			info, err := os.Stat(args[0])
 			if err != nil {
-				return fmt.Errorf("mapping dockerfile: %w", err)
+				return fmt.Errorf("reading file: %s: %w", args[0], err)
 			}
 
-			if _, err := os.Stdout.Write(output); err != nil {
-				return fmt.Errorf("writing output: %w", err)
+			// When we're given a directory, map all the
+			// Dockerfiles in it
+			findPaths := func() ([]string, error) {
+				if !info.IsDir() {
+					return []string{args[0]}, nil
+				}
+				paths, err := dockerfile.FindDockerfiles(args[0])
+				if err != nil {
+					return nil, fmt.Errorf("finding dockerfiles: %w", err)
+				}
+				return paths, nil
+			}
+
+			if opts.Watch {
+				if opts.Write {
+					return fmt.Errorf("--watch can't be used with --write")
+				}
+
+				return watch.Run(cmd.Context(), os.Stdout, []string{args[0]}, watch.Options{}, func(ctx context.Context) ([]byte, error) {
+					paths, err := findPaths()
+					if err != nil {
+						return nil, err
+					}
+					files, err := dockerfile.MapFiles(ctx, paths, dopts, mopts...)
+					if err != nil {
+						return nil, fmt.Errorf("mapping dockerfiles: %w", err)
+					}
+					return dockerfileOutput(files, info.IsDir() || opts.Diff)
+				})
+			}
+
+			paths, err := findPaths()
+			if err != nil {
+				return err
+			}
+
+			files, err := dockerfile.MapFiles(cmd.Context(), paths, dopts, mopts...)
+			if err != nil {
+				return fmt.Errorf("mapping dockerfiles: %w", err)
+			}
+
+			if !opts.Write {
+				output, err := dockerfileOutput(files, info.IsDir() || opts.Diff)
+				if err != nil {
+					return err
+				}
+				if _, err := os.Stdout.Write(output); err != nil {
+					return fmt.Errorf("writing output: %w", err)
+				}
+
+				return nil
+			}
+
+			for _, f := range files {
+				if !f.Changed() {
+					continue
+				}
+				if err := os.WriteFile(f.Path, f.Output, 0o644); err != nil {
+					return fmt.Errorf("writing file: %s: %w", f.Path, err)
+				}
+				slog.Info("updated file", "path", f.Path)
 			}
 
 			return nil
 		},
 	}
 
+	opts.Filters.addFlags(cmd)
 	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringArrayVar(&opts.BuildArgs, "build-arg", []string{}, "Override the value of an ARG instruction in the Dockerfile, in the form KEY=VALUE. Can be provided multiple times.")
+	cmd.Flags().BoolVar(&opts.Annotate, "annotate", false, "Add comments above FROM instructions that couldn't be mapped, or where there are warnings about the mapping.")
+	cmd.Flags().BoolVar(&opts.Write, "write", false, "Update the files in place, rather than writing the result to stdout.")
+	cmd.Flags().BoolVar(&opts.Diff, "diff", false, "Print a unified diff of the changes, rather than the mapped Dockerfile. This is the default for directories.")
+	cmd.Flags().BoolVar(&opts.Watch, "watch", false, "Watch the files for changes and print a diff of the output each time it changes.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().IntVar(&opts.Workers, "workers", runtime.NumCPU(), "The number of Dockerfiles that are mapped at once, when mapping a directory.")
 
 	return cmd
 }
+
+// dockerfileOutput returns the mapped Dockerfiles, or a diff of the changes to
+// each file
+func dockerfileOutput(files []dockerfile.File, asDiff bool) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, f := range files {
+		if !asDiff {
+			buf.Write(f.Output)
+			continue
+		}
+
+		diff, err := f.Diff()
+		if err != nil {
+			return nil, fmt.Errorf("diffing file: %s: %w", f.Path, err)
+		}
+		buf.WriteString(diff)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -1,24 +1,46 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"slices"
+	"strings"
 
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/diff"
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/helm"
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/watch"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func MapHelmChartCommand() *cobra.Command {
 	opts := struct {
-		Repo         string
-		ChartRepo    string
-		ChartVersion string
+		Filters        filterOptions
+		Repo           string
+		RepoMap        []string
+		TagStrategy    string
+		Aliases        []string
+		ChartRepo      string
+		ChartVersion   string
+		ValuesFiles    []string
+		Render         bool
+		ReleaseName    string
+		Keys           []string
+		KeysFile       string
+		GlobalRegistry bool
+		Watch          bool
+		OutputFormat   string
+		Namespace      string
+		ChartYAML      bool
 	}{}
 	cmd := &cobra.Command{
-		Use:   "helm-chart",
-		Short: "Extract image related values from a Helm chart and map them to Chainguard.",
+		Use:     "helm-chart",
+		Aliases: []string{"chart"},
+		Short:   "Extract image related values from a Helm chart and map them to Chainguard.",
 		Example: `
   # Map a Helm chart. This requires that the Chart repo has been added with 'helm repo add' beforehand.
   image-mapper map helm-chart argocd/argo-cd
@@ -34,17 +56,98 @@ func MapHelmChartCommand() *cobra.Command {
   
   # Specify a specific version of a remote Chart.
   image-mapper map helm-chart argo-cd --chart-repo=https://argoproj.github.io/argo-helm --chart-version=9.0.0
+
+  # Pull a chart from an OCI registry. Credentials are read from 'helm registry login'.
+  image-mapper map helm-chart oci://ghcr.io/argoproj/argo-helm/argo-cd --version=9.0.0
+
+  # Map a chart on disk, either a directory or a packaged chart.
+  image-mapper map helm-chart ./argo-cd
+  image-mapper map helm-chart argo-cd-9.0.0.tgz
+
+  # Render the chart's templates to find images that aren't set by the values. Images that can't be set with values are output as strategic merge patches.
+  image-mapper map helm-chart argocd/argo-cd --render
+
+  # Render the chart with your own values
+  image-mapper map helm-chart argocd/argo-cd --render -f values.yaml
+
+  # Map images in keys with other names, like controllerImage or sidecar.image.repositoryOverride.
+  image-mapper map helm-chart argocd/argo-cd --key 'image=*Image' --key repository=sidecar.image.repositoryOverride
+
+  # Map a chart on disk again each time it, or the values files, change and print a diff of the output.
+  image-mapper map helm-chart ./argo-cd --render -f values.yaml --watch
+
+  # Output the values as a patch for a Flux HelmRelease or an Argo CD Application.
+  image-mapper map helm-chart argocd/argo-cd --render -o flux --release-name=argo-cd --namespace=argocd
+  image-mapper map helm-chart argocd/argo-cd -o argocd --release-name=argo-cd --namespace=argocd
+
+  # Output the values as --set arguments for helm install.
+  image-mapper map helm-chart argocd/argo-cd -o set | xargs helm install argo-cd argocd/argo-cd
+
+  # Map the images listed in the artifacthub.io/images annotation of the chart's Chart.yaml, and its dependencies.
+  image-mapper map helm-chart argocd/argo-cd --chart-yaml
 `,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkHelmFormat(opts.OutputFormat); err != nil {
+				return err
+			}
+			if opts.ChartYAML && (opts.OutputFormat != helm.FormatValues || opts.Render) {
+				return fmt.Errorf("--chart-yaml can't be used with --render or -o %s", opts.OutputFormat)
+			}
+
 			chart := helm.ChartDescriptor{
 				Name:       args[0],
 				Repository: opts.ChartRepo,
 				Version:    opts.ChartVersion,
 			}
-			output, err := helm.MapChart(cmd.Context(), chart, mapper.WithRepository(opts.Repo))
+			ignoreFns, err := opts.Filters.ignoreFns()
 			if err != nil {
-				return fmt.Errorf("mapping values: %w", err)
+				return err
+			}
+			mopts := []mapper.Option{mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...)}
+			run := func(ctx context.Context) ([]byte, error) {
+				if opts.ChartYAML {
+					output, err := helm.MapChartYAML(ctx, chart, mopts...)
+					if err != nil {
+						return nil, fmt.Errorf("mapping Chart.yaml: %w", err)
+					}
+					return output, nil
+				}
+				keys, err := helmKeys(opts.Keys, opts.KeysFile)
+				if err != nil {
+					return nil, err
+				}
+				copts := helm.ChartOptions{
+					ValuesFiles:    opts.ValuesFiles,
+					Render:         opts.Render,
+					ReleaseName:    opts.ReleaseName,
+					Keys:           keys,
+					GlobalRegistry: opts.GlobalRegistry,
+				}
+				output, err := helm.MapChart(ctx, chart, copts, mopts...)
+				if err != nil {
+					return nil, fmt.Errorf("mapping values: %w", err)
+				}
+				return helm.WrapValues(output, opts.OutputFormat, helm.ReleaseOptions{
+					Name:      opts.ReleaseName,
+					Namespace: opts.Namespace,
+				})
+			}
+
+			if opts.Watch {
+				// Only files on disk can be watched, so a chart
+				// from a repository is only mapped again when
+				// the values change
+				paths := localPaths(slices.Concat([]string{args[0], opts.KeysFile}, opts.ValuesFiles)...)
+				if len(paths) == 0 {
+					return fmt.Errorf("--watch requires a chart on disk, values files or a keys file")
+				}
+				return watch.Run(cmd.Context(), os.Stdout, paths, watch.Options{}, run)
+			}
+
+			output, err := run(cmd.Context())
+			if err != nil {
+				return err
 			}
 
 			if _, err := os.Stdout.Write(output); err != nil {
@@ -55,16 +158,52 @@ func MapHelmChartCommand() *cobra.Command {
 		},
 	}
 
+	opts.Filters.addFlags(cmd)
 	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
 	cmd.Flags().StringVar(&opts.ChartRepo, "chart-repo", "", "The chart repository url to locate the requested chart.")
 	cmd.Flags().StringVar(&opts.ChartVersion, "chart-version", "", "A version constraint for the chart version.")
+	// Accept --version as well, like helm pull
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "version" {
+			name = "chart-version"
+		}
+		return pflag.NormalizedName(name)
+	})
+	cmd.Flags().StringSliceVarP(&opts.ValuesFiles, "values", "f", []string{}, "Values files to apply on top of the chart's default values.")
+	cmd.Flags().BoolVar(&opts.Render, "render", false, "Render the chart's templates to find images that aren't set by the values.")
+	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "release-name", "The release name to use when rendering the chart, and the name of the HelmRelease or Application in the flux and argocd output.")
+	cmd.Flags().StringArrayVar(&opts.Keys, "key", []string{}, "Additional keys that hold part of an image reference, as PART=PATTERN, where PART is one of image, name, repository, registry, tag or digest. For instance, image=*Image or repository=sidecar.image.repositoryOverride.")
+	cmd.Flags().StringVar(&opts.KeysFile, "keys-file", "", "A YAML file of additional key patterns for each part of an image reference.")
+	cmd.Flags().BoolVar(&opts.GlobalRegistry, "global-registry", false, "Set a single global registry value, like global.imageRegistry, instead of the registry of each image, when they're all mapped to the same registry.")
+	cmd.Flags().BoolVar(&opts.Watch, "watch", false, "Watch the chart and values files for changes and print a diff of the output each time it changes.")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", helm.FormatValues, "Output format (values, flux, argocd, set). flux and argocd wrap the values in a patch for a HelmRelease or Application, and set writes them as --set arguments for helm install.")
+	cmd.Flags().StringVar(&opts.Namespace, "namespace", "", "The namespace of the HelmRelease or Application in the flux and argocd output.")
+	cmd.Flags().BoolVar(&opts.ChartYAML, "chart-yaml", false, "Output the chart's Chart.yaml, and those of its dependencies, with the images in the artifacthub.io/images annotation mapped, instead of values.")
 
 	return cmd
 }
 
 func MapHelmValuesCommand() *cobra.Command {
 	opts := struct {
-		Repo string
+		Filters        filterOptions
+		Repo           string
+		RepoMap        []string
+		TagStrategy    string
+		Aliases        []string
+		Keys           []string
+		KeysFile       string
+		AppVersion     string
+		Full           bool
+		InPlace        bool
+		GlobalRegistry bool
+		Watch          bool
+		Diff           bool
+		OutputFormat   string
+		ReleaseName    string
+		Namespace      string
 	}{}
 	cmd := &cobra.Command{
 		Use:   "helm-values",
@@ -79,29 +218,127 @@ func MapHelmValuesCommand() *cobra.Command {
   
   # Override the repository in the mappings with your own mirror or proxy. For instance, cgr.dev/chainguard/<image> would become registry.internal/cgr/<image> in the output.
   image-mapper map helm-values values.yaml --repository=registry.internal/cgr
+
+  # Map empty tags as the chart's appVersion, like most charts do.
+  helm show values argocd/argo-cd | image-mapper map helm-values - --app-version=$(helm show chart argocd/argo-cd | yq .appVersion)
+
+  # Map images in keys with other names, like controllerImage or sidecar.image.repositoryOverride.
+  image-mapper map helm-values values.yaml --key 'image=*Image' --key repository=sidecar.image.repositoryOverride
+
+  # Output all of the values with the mapped images edited in, rather than just the image related values.
+  image-mapper map helm-values values.yaml --full
+
+  # Edit the mapped images into the values file.
+  image-mapper map helm-values values.yaml --in-place
+
+  # Print a diff of the changes to the values file, which can be applied with 'patch -p1' or 'git apply'.
+  image-mapper map helm-values values.yaml --diff
+
+  # Map the values file again each time it changes and print a diff of the output.
+  image-mapper map helm-values values.yaml --watch
+
+  # Output the mapped values as a patch for a Flux HelmRelease or an Argo CD Application.
+  image-mapper map helm-values values.yaml -o flux --release-name=argo-cd --namespace=argocd
+
+  # Map a JSON values file and output the mapped values as --set arguments.
+  image-mapper map helm-values values.json -o set
 `,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var (
-				input []byte
-				err   error
-			)
-			switch args[0] {
-			case "-":
-				input, err = io.ReadAll(os.Stdin)
+			if err := checkHelmFormat(opts.OutputFormat); err != nil {
+				return err
+			}
+			if args[0] == "-" {
+				if opts.InPlace {
+					return fmt.Errorf("--in-place can't be used with stdin")
+				}
+				if opts.Watch {
+					return fmt.Errorf("--watch can't be used with stdin")
+				}
+			}
+			if opts.Watch && opts.InPlace {
+				return fmt.Errorf("--watch can't be used with --in-place")
+			}
+			if opts.Diff && opts.InPlace {
+				return fmt.Errorf("--diff can't be used with --in-place")
+			}
+			if opts.OutputFormat != helm.FormatValues && (opts.Diff || opts.InPlace) {
+				return fmt.Errorf("--output=%s can't be used with --diff or --in-place", opts.OutputFormat)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			mopts := []mapper.Option{mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...)}
+			run := func(ctx context.Context) ([]byte, error) {
+				var (
+					input []byte
+					err   error
+				)
+				switch args[0] {
+				case "-":
+					input, err = io.ReadAll(os.Stdin)
+					if err != nil {
+						return nil, fmt.Errorf("reading stdin: %w", err)
+					}
+				default:
+					input, err = os.ReadFile(args[0])
+					if err != nil {
+						return nil, fmt.Errorf("reading file: %s: %w", args[0], err)
+					}
+				}
+
+				keys, err := helmKeys(opts.Keys, opts.KeysFile)
 				if err != nil {
-					return fmt.Errorf("reading stdin: %w", err)
+					return nil, err
+				}
+
+				vopts := helm.ValuesOptions{
+					Keys:           keys,
+					AppVersion:     opts.AppVersion,
+					Full:           opts.Full || opts.InPlace || opts.Diff,
+					GlobalRegistry: opts.GlobalRegistry,
 				}
-			default:
-				input, err = os.ReadFile(args[0])
+				output, err := helm.MapValues(ctx, input, vopts, mopts...)
 				if err != nil {
-					return fmt.Errorf("reading file: %s: %w", args[0], err)
+					return nil, fmt.Errorf("mapping values: %w", err)
+				}
+
+				if opts.Diff {
+					path := args[0]
+					if path == "-" {
+						path = "values.yaml"
+					}
+					d, err := diff.File(path, input, output)
+					if err != nil {
+						return nil, err
+					}
+					return []byte(d), nil
 				}
+
+				return helm.WrapValues(output, opts.OutputFormat, helm.ReleaseOptions{
+					Name:      opts.ReleaseName,
+					Namespace: opts.Namespace,
+				})
+			}
+
+			if opts.Watch {
+				return watch.Run(cmd.Context(), os.Stdout, localPaths(args[0], opts.KeysFile), watch.Options{}, run)
 			}
 
-			output, err := helm.MapValues(cmd.Context(), input, mapper.WithRepository(opts.Repo))
+			output, err := run(cmd.Context())
 			if err != nil {
-				return fmt.Errorf("mapping values: %w", err)
+				return err
+			}
+
+			if opts.InPlace {
+				if err := os.WriteFile(args[0], output, 0o644); err != nil {
+					return fmt.Errorf("writing file: %s: %w", args[0], err)
+				}
+				slog.Info("updated file", "path", args[0])
+
+				return nil
 			}
 
 			if _, err := os.Stdout.Write(output); err != nil {
@@ -112,7 +349,73 @@ func MapHelmValuesCommand() *cobra.Command {
 		},
 	}
 
+	opts.Filters.addFlags(cmd)
 	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().StringArrayVar(&opts.Keys, "key", []string{}, "Additional keys that hold part of an image reference, as PART=PATTERN, where PART is one of image, name, repository, registry, tag or digest. For instance, image=*Image or repository=sidecar.image.repositoryOverride.")
+	cmd.Flags().StringVar(&opts.KeysFile, "keys-file", "", "A YAML file of additional key patterns for each part of an image reference.")
+	cmd.Flags().BoolVar(&opts.GlobalRegistry, "global-registry", false, "Set a single global registry value, like global.imageRegistry, instead of the registry of each image, when they're all mapped to the same registry.")
+	cmd.Flags().StringVar(&opts.AppVersion, "app-version", "", "The appVersion of the chart, which is mapped in place of empty tags.")
+	cmd.Flags().BoolVar(&opts.Full, "full", false, "Output all of the values with the mapped images edited in, preserving comments and formatting.")
+	cmd.Flags().BoolVar(&opts.InPlace, "in-place", false, "Edit the mapped images into the values file, preserving comments and formatting.")
+	cmd.Flags().BoolVar(&opts.Diff, "diff", false, "Print a unified diff of the changes to the values file, rather than the mapped values.")
+	cmd.Flags().BoolVar(&opts.Watch, "watch", false, "Watch the values file for changes and print a diff of the output each time it changes.")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", helm.FormatValues, "Output format (values, flux, argocd, set). flux and argocd wrap the values in a patch for a HelmRelease or Application, and set writes them as --set arguments for helm install.")
+	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "release-name", "The name of the HelmRelease or Application in the flux and argocd output.")
+	cmd.Flags().StringVar(&opts.Namespace, "namespace", "", "The namespace of the HelmRelease or Application in the flux and argocd output.")
 
 	return cmd
 }
+
+// helmKeys parses the additional key patterns from the flags and the keys file
+func helmKeys(patterns []string, file string) (helm.Keys, error) {
+	var keys helm.Keys
+	if file != "" {
+		var err error
+		keys, err = helm.ReadKeysFile(file)
+		if err != nil {
+			return keys, fmt.Errorf("reading keys file: %s: %w", file, err)
+		}
+	}
+
+	for _, p := range patterns {
+		part, pattern, ok := strings.Cut(p, "=")
+		if !ok || pattern == "" {
+			return keys, fmt.Errorf("invalid key %q, must be PART=PATTERN", p)
+		}
+		if err := keys.Add(part, pattern); err != nil {
+			return keys, err
+		}
+	}
+
+	return keys, nil
+}
+
+// checkHelmFormat returns an error if the output format of the mapped values
+// isn't supported
+func checkHelmFormat(format string) error {
+	if !slices.Contains([]string{helm.FormatValues, helm.FormatFlux, helm.FormatArgoCD, helm.FormatSet}, format) {
+		return fmt.Errorf("unsupported output format: %s (supported: %s, %s, %s, %s)", format, helm.FormatValues, helm.FormatFlux, helm.FormatArgoCD, helm.FormatSet)
+	}
+
+	return nil
+}
+
+// localPaths returns the paths that exist on disk, ignoring empty paths and
+// references to remote charts
+func localPaths(paths ...string) []string {
+	var local []string
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		local = append(local, path)
+	}
+
+	return local
+}
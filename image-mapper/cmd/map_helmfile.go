@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/helm"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func MapHelmfileCommand() *cobra.Command {
+	opts := struct {
+		Filters        filterOptions
+		Repo           string
+		RepoMap        []string
+		TagStrategy    string
+		Aliases        []string
+		OutputDir      string
+		ValuesFiles    []string
+		Render         bool
+		Keys           []string
+		KeysFile       string
+		GlobalRegistry bool
+	}{}
+	cmd := &cobra.Command{
+		Use:   "helmfile",
+		Short: "Extract image related values from the charts in a helmfile and map them to Chainguard.",
+		Example: `
+  # Map the charts of the releases in a helmfile
+  image-mapper map helmfile helmfile.yaml
+
+  # Write the values for each release to a directory, as <release>.yaml
+  image-mapper map helmfile helmfile.yaml --output-dir=chainguard-values
+
+  # Render templated helmfiles, with the values embedded, before mapping them
+  helmfile build --embed-values | image-mapper map helmfile -
+
+  # Render the charts to find images that aren't set by the values
+  image-mapper map helmfile helmfile.yaml --render
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				input []byte
+				dir   string
+				err   error
+			)
+			switch args[0] {
+			case "-":
+				input, err = io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("reading stdin: %w", err)
+				}
+				dir = "."
+			default:
+				input, err = os.ReadFile(args[0])
+				if err != nil {
+					return fmt.Errorf("reading file: %s: %w", args[0], err)
+				}
+				dir = filepath.Dir(args[0])
+			}
+
+			hf, err := helm.ReadHelmfile(input)
+			if err != nil {
+				return fmt.Errorf("reading helmfile: %w", err)
+			}
+
+			keys, err := helmKeys(opts.Keys, opts.KeysFile)
+			if err != nil {
+				return err
+			}
+			copts := helm.ChartOptions{
+				ValuesFiles:    opts.ValuesFiles,
+				Render:         opts.Render,
+				Keys:           keys,
+				GlobalRegistry: opts.GlobalRegistry,
+			}
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			releases, err := helm.MapHelmfile(cmd.Context(), hf, dir, copts, mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("mapping helmfile: %w", err)
+			}
+
+			if opts.OutputDir != "" {
+				if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+					return fmt.Errorf("creating output directory: %w", err)
+				}
+				for _, release := range releases {
+					path := filepath.Join(opts.OutputDir, release.Name+".yaml")
+					if err := os.WriteFile(path, release.Values, 0o644); err != nil {
+						return fmt.Errorf("writing file: %s: %w", path, err)
+					}
+					slog.Info("wrote file", "path", path)
+				}
+
+				return nil
+			}
+
+			for _, release := range releases {
+				header := fmt.Sprintf("---\n# Release: %s\n", release.Name)
+				if release.Namespace != "" {
+					header = fmt.Sprintf("---\n# Release: %s (namespace: %s)\n", release.Name, release.Namespace)
+				}
+				if _, err := fmt.Fprint(os.Stdout, header); err != nil {
+					return fmt.Errorf("writing output: %w", err)
+				}
+				if _, err := os.Stdout.Write(release.Values); err != nil {
+					return fmt.Errorf("writing output: %w", err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", "", "Write the values for each release to <release>.yaml in this directory, rather than stdout.")
+	cmd.Flags().StringSliceVarP(&opts.ValuesFiles, "values", "f", []string{}, "Values files to apply to every release, beneath the release's own values.")
+	cmd.Flags().BoolVar(&opts.Render, "render", false, "Render the charts' templates to find images that aren't set by the values.")
+	cmd.Flags().StringArrayVar(&opts.Keys, "key", []string{}, "Additional keys that hold part of an image reference, as PART=PATTERN, where PART is one of image, name, repository, registry, tag or digest. For instance, image=*Image or repository=sidecar.image.repositoryOverride.")
+	cmd.Flags().StringVar(&opts.KeysFile, "keys-file", "", "A YAML file of additional key patterns for each part of an image reference.")
+	cmd.Flags().BoolVar(&opts.GlobalRegistry, "global-registry", false, "Set a single global registry value, like global.imageRegistry, instead of the registry of each image, when they're all mapped to the same registry.")
+
+	return cmd
+}
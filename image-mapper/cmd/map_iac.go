@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/iac"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func MapIACCommand() *cobra.Command {
+	opts := struct {
+		Filters      filterOptions
+		OutputFormat string
+		Repo         string
+		RepoMap      []string
+		TagStrategy  string
+		Aliases      []string
+	}{}
+	cmd := &cobra.Command{
+		Use:     "iac",
+		Aliases: []string{"terraform", "cloudformation", "bazel", "nomad"},
+		Short:   "Map the images in Terraform and CloudFormation templates, Bazel files and Nomad jobs to Chainguard and output the replacements.",
+		Example: `
+# Map the images in a Terraform configuration
+image-mapper map iac ./infra
+
+# Map the images in the templates synthesized by the CDK
+cdk synth && image-mapper map iac cdk.out
+
+# Map the images pulled by Bazel and the images in Nomad jobs
+image-mapper map iac MODULE.bazel ./jobs
+
+# Output the replacements as JSON
+image-mapper map iac ./infra -o json
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var output func(replacements []iac.Replacement) error
+			switch strings.ToLower(opts.OutputFormat) {
+			case "text":
+				output = func(replacements []iac.Replacement) error {
+					for _, r := range replacements {
+						if _, err := fmt.Fprintf(os.Stdout, "%s:%d: %s -> %s\n", r.Path, r.Line, r.Image, r.Mapped); err != nil {
+							return fmt.Errorf("writing output: %w", err)
+						}
+					}
+					return nil
+				}
+			case "json":
+				output = func(replacements []iac.Replacement) error {
+					return json.NewEncoder(os.Stdout).Encode(replacements)
+				}
+			default:
+				return fmt.Errorf("unsupported output format: %s (supported: json, text)", opts.OutputFormat)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			replacements, err := iac.Map(cmd.Context(), args, mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("mapping templates: %w", err)
+			}
+
+			return output(replacements)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "Output format (json, text)")
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+
+	return cmd
+}
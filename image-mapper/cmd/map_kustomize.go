@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/kustomize"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+func MapKustomizeCommand() *cobra.Command {
+	opts := struct {
+		Filters     filterOptions
+		Repo        string
+		RepoMap     []string
+		TagStrategy string
+		Aliases     []string
+		Watch       bool
+	}{}
+	cmd := &cobra.Command{
+		Use:   "kustomize",
+		Short: "Map the images in a kustomization to Chainguard and output an images transformer.",
+		Example: `
+# Map the images in a kustomization and output an images transformer for kustomization.yaml
+image-mapper map kustomize ./overlays/production
+
+# Override the repository in the mappings with your own mirror or proxy. For instance, cgr.dev/chainguard/<image> would become registry.internal/cgr/<image> in the output.
+image-mapper map kustomize ./overlays/production --repository=registry.internal/cgr
+
+# Map the kustomization again each time the files in the directory change and print a diff of the output
+image-mapper map kustomize ./overlays/production --watch
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			mopts := []mapper.Option{mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...)}
+			run := func(ctx context.Context) ([]byte, error) {
+				output, err := kustomize.Map(ctx, args[0], mopts...)
+				if err != nil {
+					return nil, fmt.Errorf("mapping kustomization: %w", err)
+				}
+				return output, nil
+			}
+
+			if opts.Watch {
+				return watch.Run(cmd.Context(), os.Stdout, []string{args[0]}, watch.Options{}, run)
+			}
+
+			output, err := run(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if _, err := os.Stdout.Write(output); err != nil {
+				return fmt.Errorf("writing output: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().BoolVar(&opts.Watch, "watch", false, "Watch the kustomization directory for changes and print a diff of the output each time it changes.")
+
+	return cmd
+}
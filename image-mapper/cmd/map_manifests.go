@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/helm"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+func MapManifestsCommand() *cobra.Command {
+	opts := struct {
+		Filters         filterOptions
+		Repo            string
+		RepoMap         []string
+		TagStrategy     string
+		Aliases         []string
+		CustomResources string
+		Write           bool
+		Diff            bool
+		Watch           bool
+	}{}
+	cmd := &cobra.Command{
+		Use:     "manifests",
+		Aliases: []string{"k8s", "kubernetes"},
+		Short:   "Map the images of the containers in Kubernetes manifests, or a directory of manifests, to their Chainguard equivalents.",
+		Example: `
+# Map the images in a manifest
+image-mapper map manifests deployment.yaml
+
+# Map the images in the manifests from stdin
+kubectl get deployments -o yaml | image-mapper map manifests -
+
+# Print a diff of the changes to a manifest, which can be applied with 'patch -p1' or 'git apply'
+image-mapper map manifests deployment.yaml --diff
+
+# Map every manifest in a directory and print a diff of the changes
+image-mapper map manifests ./deploy
+
+# Map every manifest in a directory and update them in place
+image-mapper map manifests ./deploy --write
+
+# Map the image fields of custom resources, as well as their containers
+image-mapper map manifests ./deploy --custom-resources=custom-resources.yaml
+
+# Map the manifests in a directory again each time they change and print a diff of the output
+image-mapper map manifests ./deploy --watch
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var mopts []manifest.Option
+			if opts.CustomResources != "" {
+				crs, err := manifest.ReadCustomResources(opts.CustomResources)
+				if err != nil {
+					return err
+				}
+				mopts = append(mopts, manifest.WithCustomResources(crs...))
+			}
+
+			if opts.Watch {
+				if slices.Contains(args, "-") {
+					return fmt.Errorf("--watch can't be used with stdin")
+				}
+				if opts.Write {
+					return fmt.Errorf("--watch can't be used with --write")
+				}
+			}
+
+			ropts := rewriteOptions{StdinName: "manifests.yaml", Separator: "---\n", Diff: opts.Diff, Write: opts.Write}
+			paths, ropts, err := manifestPaths(args, ropts)
+			if err != nil {
+				return err
+			}
+			if err := ropts.validate(paths); err != nil {
+				return err
+			}
+
+			m, err := newManifestMapper(cmd.Context(), opts.Repo, opts.RepoMap, opts.TagStrategy, opts.Aliases, opts.Filters)
+			if err != nil {
+				return err
+			}
+
+			rewrite := func(m mapper.Mapper, input []byte) ([]byte, error) {
+				return manifest.EditImages(input, func(img manifest.Image) (string, bool) {
+					return mapImageFunc(m)(img.Image)
+				}, mopts...)
+			}
+
+			if opts.Watch {
+				run := func(ctx context.Context) ([]byte, error) {
+					// The directories are searched again each
+					// time, so that new manifests are picked up
+					paths, ropts, err := manifestPaths(args, ropts)
+					if err != nil {
+						return nil, err
+					}
+					return rewritePaths(m, paths, ropts, rewrite)
+				}
+				return watch.Run(cmd.Context(), os.Stdout, args, watch.Options{}, run)
+			}
+
+			return rewriteFiles(m, paths, ropts, rewrite)
+		},
+	}
+
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().StringVar(&opts.CustomResources, "custom-resources", "", "A YAML file of custom resources, by apiVersion and kind, and the JSONPaths of the fields in them that hold images, like spec.kafka.image.")
+	cmd.Flags().BoolVar(&opts.Write, "write", false, "Update the files in place, rather than writing the result to stdout.")
+	cmd.Flags().BoolVar(&opts.Diff, "diff", false, "Print a unified diff of the changes, rather than the mapped manifests. This is the default for directories.")
+	cmd.Flags().BoolVar(&opts.Watch, "watch", false, "Watch the manifests for changes and print a diff of the output each time it changes.")
+
+	return cmd
+}
+
+// manifestPaths returns the paths of the manifests in the arguments, which are
+// files, directories or - for stdin. When we're given a directory, all the
+// YAML files in it are mapped and a diff is printed by default, like the
+// dockerfile subcommand.
+func manifestPaths(args []string, opts rewriteOptions) ([]string, rewriteOptions, error) {
+	var paths []string
+	for _, arg := range args {
+		if arg == "-" {
+			paths = append(paths, arg)
+			continue
+		}
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, opts, fmt.Errorf("reading file: %s: %w", arg, err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		found, err := findYAMLFiles(arg)
+		if err != nil {
+			return nil, opts, err
+		}
+		paths = append(paths, found...)
+		opts.SkipInvalid = true
+		opts.Diff = opts.Diff || !opts.Write
+	}
+
+	return paths, opts, nil
+}
+
+// newManifestMapper returns the mapper for the images that are run, rather
+// than built on, like the images in manifests and Compose files. It maps them
+// like the Helm post-renderer does, to the non-dev tags.
+func newManifestMapper(ctx context.Context, repo string, repoMap []string, tagStrategy string, aliases []string, filters filterOptions) (mapper.Mapper, error) {
+	ignoreFns, err := filters.ignoreFns()
+	if err != nil {
+		return nil, err
+	}
+	m, err := helm.NewMapper(ctx, mapper.WithRepository(repo), mapper.WithRepositoryMap(repoMap...), mapper.WithTagStrategy(tagStrategy), mapper.WithAliasOverrides(aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(filters.TierOrder...))
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return m, nil
+}
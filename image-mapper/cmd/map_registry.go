@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/registry"
+	"github.com/spf13/cobra"
+)
+
+func MapRegistryCommand() *cobra.Command {
+	opts := struct {
+		ReposOnly          bool
+		PlainHTTP          bool
+		InsecureRegistries []string
+		Keychains          []string
+		OutputFormat       string
+		Filters            filterOptions
+		Repo               string
+		RepoMap            []string
+		TagStrategy        string
+		Aliases            []string
+		Strict             bool
+	}{}
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Map the images hosted in a container registry, or a repository, to Chainguard images.",
+		Example: `
+# Map every tag of every repository in a registry
+image-mapper map registry registry.internal
+
+# Map every tag of a single repository
+image-mapper map registry registry.internal/team/app
+
+# Map each repository once, without listing the tags
+image-mapper map registry registry.internal --repos-only
+
+# Output a CSV report
+image-mapper map registry registry.internal -o csv
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := mapper.NewOutput(opts.OutputFormat)
+			if err != nil {
+				return fmt.Errorf("constructing output: %w", err)
+			}
+
+			images, err := registry.ListImages(cmd.Context(), args[0], registry.Options{
+				ReposOnly:          opts.ReposOnly,
+				PlainHTTP:          opts.PlainHTTP,
+				InsecureRegistries: opts.InsecureRegistries,
+				Keychains:          opts.Keychains,
+				Identity:           chainguardIdentity,
+			})
+			if err != nil {
+				return fmt.Errorf("listing images: %w", err)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			m, err := mapper.NewMapper(cmd.Context(), mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("creating mapper: %w", err)
+			}
+
+			mappings, err := m.MapAll(mapper.NewArgsIterator(images))
+			if err != nil {
+				return fmt.Errorf("mapping images: %w", err)
+			}
+			logWarnings(mappings...)
+			if describesOutput(opts.OutputFormat) {
+				m.Describe(mappings...)
+			}
+
+			if err := output(os.Stdout, mappings); err != nil {
+				return err
+			}
+
+			return checkStrict(cmd, opts.Strict, mappings...)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.ReposOnly, "repos-only", false, "Map each repository once, without listing its tags.")
+	cmd.Flags().BoolVar(&opts.PlainHTTP, "plain-http", false, "Access the registry over HTTP, rather than HTTPS.")
+	cmd.Flags().StringSliceVar(&opts.InsecureRegistries, "insecure-registry", []string{}, "Registries, including the port if there is one, like registry.local:5000, that are accessed over HTTP rather than HTTPS.")
+	cmd.Flags().StringSliceVar(&opts.Keychains, "keychain", []string{}, "Cloud keychains used when there are no credentials for the registry in the Docker config. One or more of: "+strings.Join(registry.Keychains, ", ")+".")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", outputFormatUsage())
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "Exit with code 2 if any of the images can't be mapped, so that CI pipelines can require a mapping for every image.")
+
+	return cmd
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/dockerfile"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		MCPCommand(),
+	)
+}
+
+func MCPCommand() *cobra.Command {
+	opts := struct {
+		Filters     filterOptions
+		Repo        string
+		RepoMap     []string
+		TagStrategy string
+		Aliases     []string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Run a Model Context Protocol server on stdio, so that AI assistants can map images and search the catalog.",
+		Example: `
+# Run the server. This is usually started by the assistant, rather than by hand.
+image-mapper mcp
+
+# Map to images in a mirror of the Chainguard registry
+image-mapper mcp --repository=registry.internal.dev/chainguard
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			mapperOpts := []mapper.Option{
+				mapper.WithRepository(opts.Repo),
+				mapper.WithRepositoryMap(opts.RepoMap...),
+				mapper.WithTagStrategy(opts.TagStrategy),
+				mapper.WithAliasOverrides(opts.Aliases...),
+				mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(),
+				mapper.WithResultCache(rootOpts.CacheResults),
+				mapper.WithCacheDuration(rootOpts.CacheDuration),
+				mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale),
+				mapper.WithIgnoreFns(ignoreFns...),
+				mapper.WithTierOrder(opts.Filters.TierOrder...),
+			}
+
+			m, err := mapper.NewMapper(ctx, mapperOpts...)
+			if err != nil {
+				return fmt.Errorf("creating mapper: %w", err)
+			}
+			dm, err := dockerfile.NewMapper(ctx, mapperOpts...)
+			if err != nil {
+				return fmt.Errorf("creating dockerfile mapper: %w", err)
+			}
+			repos, err := mapper.ListRepos(ctx, mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithCacheDuration(rootOpts.CacheDuration))
+			if err != nil {
+				return fmt.Errorf("listing repos: %w", err)
+			}
+
+			server := mcp.NewServer("image-mapper", buildVersion(),
+				mcp.MapImageTool(m),
+				mcp.SearchCatalogTool(mapper.FilterRepos(repos, ignoreFns...)),
+				mcp.MapDockerfileTool(dm),
+			)
+
+			return server.Serve(ctx, os.Stdin, os.Stdout)
+		},
+	}
+
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+
+	return cmd
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/github"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/pr"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/scan"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		PRCommand(),
+	)
+}
+
+func PRCommand() *cobra.Command {
+	opts := struct {
+		Branch         string
+		Base           string
+		Title          string
+		GitHubRepo     string
+		GitHubURL      string
+		Draft          bool
+		DryRun         bool
+		Repo           string
+		RepoMap        []string
+		TagStrategy    string
+		Aliases        []string
+		ScannerPlugins []string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "pr [path|git-url]",
+		Short: "Map the images in a repository's Dockerfiles and Kubernetes manifests to Chainguard and open a GitHub pull request with the changes.",
+		Example: `
+# Open a pull request for the repository in the current directory
+export GITHUB_TOKEN=<token>
+image-mapper pr
+
+# Clone a repository and open a pull request against it
+image-mapper pr https://github.com/example/app
+
+# Make the changes in the working tree and print the description, without creating a branch or pull request
+image-mapper pr --dry-run
+`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			token := os.Getenv("GITHUB_TOKEN")
+			if token == "" {
+				token = os.Getenv("GH_TOKEN")
+			}
+			if token == "" && !opts.DryRun {
+				return fmt.Errorf("a GitHub token is required in GITHUB_TOKEN or GH_TOKEN")
+			}
+
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			if scan.IsRemote(dir) {
+				tmp, err := os.MkdirTemp("", "image-mapper-pr-")
+				if err != nil {
+					return fmt.Errorf("creating temporary directory: %w", err)
+				}
+				defer os.RemoveAll(tmp)
+
+				if err := scan.Clone(ctx, dir, tmp); err != nil {
+					return err
+				}
+				dir = tmp
+			}
+
+			scanners, err := loadScanners(ctx, opts.ScannerPlugins)
+			if err != nil {
+				return err
+			}
+
+			summary, err := pr.Apply(ctx, dir, scanners, mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale))
+			if err != nil {
+				return fmt.Errorf("mapping images: %w", err)
+			}
+			if len(summary.Files) == 0 {
+				slog.Info("there aren't any images to change")
+				return nil
+			}
+
+			if opts.DryRun {
+				for _, path := range summary.Files {
+					slog.Info("updated file", "path", path)
+				}
+				fmt.Fprint(os.Stdout, summary.Markdown())
+				return nil
+			}
+
+			base := opts.Base
+			if base == "" {
+				base, err = pr.CurrentBranch(ctx, dir)
+				if err != nil {
+					return fmt.Errorf("finding base branch: %w", err)
+				}
+			}
+			repo := opts.GitHubRepo
+			if repo == "" {
+				remote, err := pr.RemoteURL(ctx, dir)
+				if err != nil {
+					return fmt.Errorf("finding repository: %w", err)
+				}
+				repo, err = github.ParseRemote(remote)
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := pr.Commit(ctx, dir, opts.Branch, opts.Title, summary.Files); err != nil {
+				return fmt.Errorf("committing changes: %w", err)
+			}
+			if err := pr.Push(ctx, dir, opts.Branch); err != nil {
+				return fmt.Errorf("pushing branch: %w", err)
+			}
+
+			client := &github.Client{URL: opts.GitHubURL, Token: token}
+			url, err := client.CreatePullRequest(ctx, repo, github.PullRequest{
+				Title: opts.Title,
+				Body:  summary.Markdown(),
+				Head:  opts.Branch,
+				Base:  base,
+				Draft: opts.Draft,
+			})
+			if err != nil {
+				return fmt.Errorf("creating pull request: %w", err)
+			}
+			fmt.Fprintln(os.Stdout, url)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Branch, "branch", "image-mapper/chainguard-images", "The branch to commit the changes to.")
+	cmd.Flags().StringVar(&opts.Base, "base", "", "The branch to open the pull request against. Defaults to the current branch.")
+	cmd.Flags().StringVar(&opts.Title, "title", "Use Chainguard images", "The title of the pull request and the commit message.")
+	cmd.Flags().StringVar(&opts.GitHubRepo, "github-repo", "", "The GitHub repository to open the pull request in, as owner/name. Defaults to the repository of the origin remote.")
+	cmd.Flags().StringVar(&opts.GitHubURL, "github-url", github.DefaultURL, "The address of the GitHub API. For GitHub Enterprise Server, use https://<host>/api/v3.")
+	cmd.Flags().BoolVar(&opts.Draft, "draft", false, "Open the pull request as a draft.")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Make the changes in the working tree and print the description of the pull request, without creating a branch or opening a pull request.")
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().StringSliceVar(&opts.ScannerPlugins, "scanner-plugin", []string{}, "Executables that find, and change, the images in formats of file that aren't built in, like the manifests of an internal platform. See docs/scan.md for the protocol they implement.")
+
+	return cmd
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/prometheus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		PrometheusCommand(),
+	)
+}
+
+func PrometheusCommand() *cobra.Command {
+	opts := struct {
+		URL             string
+		Query           string
+		Label           string
+		BearerTokenFile string
+		OutputFormat    string
+		Filters         filterOptions
+		Repo            string
+		RepoMap         []string
+		TagStrategy     string
+		Aliases         []string
+		Strict          bool
+	}{}
+	cmd := &cobra.Command{
+		Use:   "prometheus",
+		Short: "Map the images running in a Kubernetes cluster to Chainguard images, using the metrics in Prometheus.",
+		Example: `
+# Map the images of the containers reported by kube-state-metrics
+image-mapper prometheus --url=http://prometheus.monitoring:9090
+
+# Map the images in a single namespace
+image-mapper prometheus --url=http://prometheus.monitoring:9090 --query='kube_pod_container_info{namespace="default"}'
+
+# Authenticate with a bearer token
+image-mapper prometheus --url=https://prometheus.example.com --bearer-token-file=./token
+
+# Output a CSV report
+image-mapper prometheus --url=http://prometheus.monitoring:9090 -o csv
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := mapper.NewOutput(opts.OutputFormat)
+			if err != nil {
+				return fmt.Errorf("constructing output: %w", err)
+			}
+
+			var token string
+			if opts.BearerTokenFile != "" {
+				data, err := os.ReadFile(opts.BearerTokenFile)
+				if err != nil {
+					return fmt.Errorf("reading bearer token: %w", err)
+				}
+				token = strings.TrimSpace(string(data))
+			}
+
+			images, err := prometheus.ListImages(cmd.Context(), prometheus.Options{
+				URL:         opts.URL,
+				Query:       opts.Query,
+				Label:       opts.Label,
+				BearerToken: token,
+			})
+			if err != nil {
+				return fmt.Errorf("listing images: %w", err)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			m, err := mapper.NewMapper(cmd.Context(), mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("creating mapper: %w", err)
+			}
+
+			mappings, err := m.MapAll(mapper.NewArgsIterator(images))
+			if err != nil {
+				return fmt.Errorf("mapping images: %w", err)
+			}
+			logWarnings(mappings...)
+			if describesOutput(opts.OutputFormat) {
+				m.Describe(mappings...)
+			}
+
+			if err := output(os.Stdout, mappings); err != nil {
+				return err
+			}
+
+			return checkStrict(cmd, opts.Strict, mappings...)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.URL, "url", "", "The address of the Prometheus server, i.e http://prometheus.monitoring:9090.")
+	cmd.Flags().StringVar(&opts.Query, "query", prometheus.DefaultQuery, "The PromQL query that returns a series for each container.")
+	cmd.Flags().StringVar(&opts.Label, "label", "image", "The label of the series that holds the image.")
+	cmd.Flags().StringVar(&opts.BearerTokenFile, "bearer-token-file", "", "A file containing a bearer token to authenticate with Prometheus.")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", outputFormatUsage())
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "Exit with code 2 if any of the images can't be mapped, so that CI pipelines can require a mapping for every image.")
+
+	_ = cmd.MarkFlagRequired("url")
+
+	return cmd
+}
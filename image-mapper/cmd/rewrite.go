@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/diff"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+)
+
+// rewriteOptions configure how the mapped images are edited into files by the
+// subcommands that change files, rather than reporting on them
+type rewriteOptions struct {
+	// StdinName is the name of the file in the diff, when it's read from
+	// stdin
+	StdinName string
+
+	// Separator is written between the files, when more than one of them
+	// is output
+	Separator string
+
+	// SkipInvalid skips the files that can't be mapped, like YAML files
+	// that aren't valid, with a warning, rather than failing, for the
+	// files that were found in a directory
+	SkipInvalid bool
+
+	Diff  bool
+	Write bool
+}
+
+// validate returns an error if the options can't be used with the paths. It's
+// called before the mapper is constructed, so that the catalog isn't fetched
+// for nothing.
+func (o rewriteOptions) validate(paths []string) error {
+	if o.Diff && o.Write {
+		return fmt.Errorf("--diff can't be used with --write")
+	}
+	if o.Write && slices.Contains(paths, "-") {
+		return fmt.Errorf("--write can't be used with stdin")
+	}
+
+	return nil
+}
+
+// rewriteFunc edits the images mapped with m into the input
+type rewriteFunc func(m mapper.Mapper, input []byte) ([]byte, error)
+
+// mapImageFunc returns a function that maps an image with m, for the edit
+// functions, like manifest.EditImages. Images that can't be mapped are left as
+// they are, with a warning.
+func mapImageFunc(m mapper.Mapper) func(img string) (string, bool) {
+	return func(img string) (string, bool) {
+		ref, err := mapper.MapImage(m, img)
+		if err != nil {
+			slog.Warn("couldn't map image, leaving it as it is", "image", img, "err", err)
+			return "", false
+		}
+		return ref.String(), true
+	}
+}
+
+// rewriteFiles edits the mapped images into each of the files, or into stdin
+// when the path is -, and writes the result to stdout, a diff of the changes to
+// stdout or, with Write, the changes back to the files
+func rewriteFiles(m mapper.Mapper, paths []string, opts rewriteOptions, rewrite rewriteFunc) error {
+	output, err := rewritePaths(m, paths, opts, rewrite)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(output); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
+	return nil
+}
+
+// rewritePaths is like rewriteFiles, but it returns the output, rather than
+// writing it to stdout, so that it can be compared between runs by --watch
+func rewritePaths(m mapper.Mapper, paths []string, opts rewriteOptions, rewrite rewriteFunc) ([]byte, error) {
+	if err := opts.validate(paths); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for i, path := range paths {
+		var (
+			input []byte
+			err   error
+		)
+		if path == "-" {
+			input, err = io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("reading stdin: %w", err)
+			}
+		} else {
+			input, err = os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading file: %s: %w", path, err)
+			}
+		}
+
+		output, err := rewrite(m, input)
+		if err != nil && opts.SkipInvalid {
+			slog.Warn("skipping file", "path", path, "err", err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mapping file: %s: %w", path, err)
+		}
+
+		switch {
+		case opts.Write:
+			if bytes.Equal(input, output) {
+				continue
+			}
+			if err := os.WriteFile(path, output, 0o644); err != nil {
+				return nil, fmt.Errorf("writing file: %s: %w", path, err)
+			}
+			slog.Info("updated file", "path", path)
+		case opts.Diff:
+			name := path
+			if name == "-" {
+				name = opts.StdinName
+			}
+			d, err := diff.File(name, input, output)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(d)
+		default:
+			if i > 0 {
+				buf.WriteString(opts.Separator)
+			}
+			buf.Write(output)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// findYAMLFiles returns the paths of the YAML files in the directory and its
+// subdirectories. Helm charts are skipped, because their templates aren't
+// valid YAML until they're rendered.
+func findYAMLFiles(dir string) ([]string, error) {
+	var paths []string
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			switch d.Name() {
+			case ".git", "node_modules", "vendor":
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			paths = append(paths, path)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking directory: %s: %w", dir, err)
+	}
+
+	return paths, nil
+}
@@ -1,12 +1,179 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/config"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/fixture"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+var rootOpts = struct {
+	Config        string
+	CatalogURL    string
+	CacheResults  bool
+	CacheDuration time.Duration
+	RefreshCache  bool
+	AllowStale    bool
+	Verbose       bool
+	Quiet         bool
+	LogFormat     string
+	Record        string
+	Replay        string
+}{}
 
 var rootCmd = &cobra.Command{
 	Use:   "image-mapper",
 	Short: "Map upstream image references to Chainguard images.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+
+		if err := configureLogging(); err != nil {
+			return err
+		}
+
+		if err := configureIdentity(); err != nil {
+			return err
+		}
+
+		return configureFixtures()
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&rootOpts.Config, "config", "", "Path to a config file with default values for flags. Defaults to $IMAGE_MAPPER_CONFIG or ~/.config/image-mapper/config.yaml.")
+	rootCmd.PersistentFlags().BoolVarP(&rootOpts.Verbose, "verbose", "v", false, "Log debug messages, including why each image was, or wasn't, mapped.")
+	rootCmd.PersistentFlags().BoolVarP(&rootOpts.Quiet, "quiet", "q", false, "Only log errors, not warnings or progress.")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.LogFormat, "log-format", "text", "Format of the logs written to stderr (text, json).")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.CatalogURL, "catalog-url", "", "Where to read the catalog data from: a GraphQL endpoint, a file:// URL of a JSON file of repositories or a chainguard:// URL of the platform API, like chainguard://console-api.enforce.dev/<group>, which is read as --identity. Defaults to "+mapper.DefaultCatalogURL+".")
+	rootCmd.PersistentFlags().DurationVar(&rootOpts.CacheDuration, "cache-duration", time.Hour, "How long to cache the catalog data on disk. Set to 0 to disable the cache.")
+	rootCmd.PersistentFlags().BoolVar(&rootOpts.RefreshCache, "refresh-cache", false, "Fetch the catalog data, and cache it again, even when the cache hasn't expired.")
+	rootCmd.PersistentFlags().BoolVar(&rootOpts.AllowStale, "allow-stale", true, "Use the cached catalog data, even when it has expired, if the catalog can't be fetched, with a warning that says how old it is. Set to false to fail instead.")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.Record, "record", "", "Record the catalog queries and the registry requests, with their responses, to files in this directory, so that the run can be replayed with --replay. The catalog data and results aren't cached while recording or replaying, so every request is made.")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.Replay, "replay", "", "Answer the catalog queries and the registry requests with the responses recorded in this directory with --record, rather than making them. Requests that weren't recorded fail.")
+	rootCmd.PersistentFlags().BoolVar(&rootOpts.CacheResults, "cache-results", false, "Cache mappings on disk, so that repeated runs only map the images they haven't seen before. The cache is invalidated when the catalog data or the mapping flags change.")
 }
 
 func Execute() error {
 	return rootCmd.Execute()
 }
+
+// applyConfig sets the flags of the command that weren't set on the command
+// line from the environment and the config file
+func applyConfig(cmd *cobra.Command) error {
+	path := rootOpts.Config
+	mustExist := path != ""
+	if path == "" {
+		path = os.Getenv(config.EnvPrefix + "CONFIG")
+		mustExist = path != ""
+	}
+	if path == "" {
+		p, err := config.DefaultPath()
+		if err != nil {
+			return err
+		}
+		path = p
+	}
+
+	cfg, err := config.Load(path, mustExist)
+	if err != nil {
+		return err
+	}
+
+	command := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+	if err := cfg.Apply(cmd.Flags(), command, os.Getenv); err != nil {
+		return fmt.Errorf("applying config: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// configureLogging sets the level and format of the logs
+func configureLogging() error {
+	if rootOpts.Verbose && rootOpts.Quiet {
+		return fmt.Errorf("--verbose and --quiet can't be used together")
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case rootOpts.Verbose:
+		level = slog.LevelDebug
+	case rootOpts.Quiet:
+		level = slog.LevelError
+	}
+
+	switch rootOpts.LogFormat {
+	case "text":
+		// The default handler writes through the log package, which
+		// keeps the timestamps in the same format as before
+		slog.SetLogLoggerLevel(level)
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	default:
+		return fmt.Errorf("unsupported log format: %s (supported: text, json)", rootOpts.LogFormat)
+	}
+
+	return nil
+}
+
+// configureFixtures records the HTTP requests, or replays them, when --record
+// or --replay is set. The requests of every client that uses the default
+// transport, like the catalog client and the registry clients, go through it.
+//
+// The caches are turned off, so that every request is made, or replayed,
+// rather than some of the responses coming from a cache that differs from
+// one machine to the next.
+func configureFixtures() error {
+	if rootOpts.Record != "" && rootOpts.Replay != "" {
+		return fmt.Errorf("--record and --replay can't be used together")
+	}
+
+	var transport http.RoundTripper
+	switch {
+	case rootOpts.Record != "":
+		recorder, err := fixture.NewRecorder(rootOpts.Record, http.DefaultTransport)
+		if err != nil {
+			return err
+		}
+		transport = recorder
+	case rootOpts.Replay != "":
+		replayer, err := fixture.NewReplayer(rootOpts.Replay)
+		if err != nil {
+			return err
+		}
+		transport = replayer
+	default:
+		return nil
+	}
+
+	http.DefaultTransport = transport
+	rootOpts.CacheDuration = 0
+	rootOpts.CacheResults = false
+
+	return nil
+}
+
+// describesOutput returns true if the output format includes the catalog
+// details of the results, so the mappings must be described first
+func describesOutput(format string) bool {
+	switch strings.ToLower(format) {
+	case "customer-yaml", "entitlements":
+		return true
+	default:
+		return false
+	}
+}
+
+// outputFormatUsage describes the --output flag of the commands that write
+// mappings, with the formats that are registered when the command is built
+func outputFormatUsage() string {
+	return fmt.Sprintf("Output format (%s)", strings.Join(mapper.OutputFormats(), ", "))
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/scan"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/scanner"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		ScanCommand(),
+	)
+}
+
+func ScanCommand() *cobra.Command {
+	opts := struct {
+		OutputFormat      string
+		Filters           filterOptions
+		Repo              string
+		RepoMap           []string
+		TagStrategy       string
+		Aliases           []string
+		Strict            bool
+		CustomResources   string
+		HistoryFile       string
+		HistoryURL        string
+		HistoryLabel      string
+		Workers           int
+		Ignore            []string
+		CheckEntitlements bool
+		ScannerPlugins    []string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "scan <path|git-url>",
+		Short: "Find the images in a repository's Dockerfiles, compose files, Helm charts, manifests and CI configs and map them to Chainguard images.",
+		Example: `
+# Scan the current directory
+image-mapper scan .
+
+# Clone and scan a remote repository
+image-mapper scan https://github.com/example/app
+
+# Output a CSV report
+image-mapper scan . -o csv
+
+# List the Chainguard repos the images need and whether the organization is entitled to them
+image-mapper scan . -o entitlements --repository=cgr.dev/example --check-entitlements
+
+# Skip example and test manifests
+image-mapper scan . --ignore examples/ --ignore '**/testdata'
+
+# Find the images in the fields of custom resources too
+image-mapper scan . --custom-resources crds.yaml
+
+# Find the images in the formats that a plugin reads too
+image-mapper scan . --scanner-plugin ./paas-scanner
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var output func(w io.Writer, results []scan.Result) error
+			switch strings.ToLower(opts.OutputFormat) {
+			case "csv":
+				output = outputScanCSV
+			case "json":
+				output = func(w io.Writer, results []scan.Result) error {
+					return json.NewEncoder(w).Encode(results)
+				}
+			case "text":
+				output = outputScanText
+			case "entitlements":
+				output = outputScanEntitlements
+			default:
+				return fmt.Errorf("unsupported output format: %s (supported: csv, entitlements, json, text)", opts.OutputFormat)
+			}
+
+			dir := args[0]
+			if scan.IsRemote(dir) {
+				tmp, err := os.MkdirTemp("", "image-mapper-scan-")
+				if err != nil {
+					return fmt.Errorf("creating temporary directory: %w", err)
+				}
+				defer os.RemoveAll(tmp)
+
+				if err := scan.Clone(cmd.Context(), args[0], tmp); err != nil {
+					return err
+				}
+				dir = tmp
+			}
+
+			scanners, err := loadScanners(cmd.Context(), opts.ScannerPlugins)
+			if err != nil {
+				return err
+			}
+
+			scanOpts := []scan.Option{scan.WithWorkers(opts.Workers), scan.WithIgnorePatterns(opts.Ignore...), scan.WithScanners(scanners...)}
+			if opts.CustomResources != "" {
+				crs, err := manifest.ReadCustomResources(opts.CustomResources)
+				if err != nil {
+					return err
+				}
+				scanOpts = append(scanOpts, scan.WithManifestOptions(manifest.WithCustomResources(crs...)))
+			}
+
+			files, err := scan.Find(dir, scanOpts...)
+			if err != nil {
+				return fmt.Errorf("finding images: %w", err)
+			}
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			m, err := mapper.NewMapper(cmd.Context(), mapper.WithRepository(opts.Repo), mapper.WithRepositoryMap(opts.RepoMap...), mapper.WithTagStrategy(opts.TagStrategy), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithResultCache(rootOpts.CacheResults), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale), mapper.WithIgnoreFns(ignoreFns...), mapper.WithTierOrder(opts.Filters.TierOrder...))
+			if err != nil {
+				return fmt.Errorf("creating mapper: %w", err)
+			}
+
+			results, err := scan.Map(m, files)
+			if err != nil {
+				return fmt.Errorf("mapping images: %w", err)
+			}
+			var mappings []*mapper.Mapping
+			for _, result := range results {
+				logWarnings(result.Mappings...)
+				mappings = append(mappings, result.Mappings...)
+			}
+			if describesOutput(opts.OutputFormat) {
+				m.Describe(mappings...)
+			}
+			if opts.CheckEntitlements {
+				if err := checkEntitlements(cmd, nil, mappings...); err != nil {
+					return err
+				}
+			}
+
+			if err := output(os.Stdout, results); err != nil {
+				return err
+			}
+
+			label := opts.HistoryLabel
+			if label == "" {
+				label = args[0]
+			}
+			unmapped, total := unmappedImages(mappings...)
+			if err := recordHistory(cmd, opts.HistoryFile, opts.HistoryURL, label, total, len(unmapped)); err != nil {
+				return err
+			}
+
+			return checkStrict(cmd, opts.Strict, mappings...)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "Output format (csv, entitlements, json, text)")
+	cmd.Flags().BoolVar(&opts.CheckEntitlements, "check-entitlements", false, "With -o entitlements, list the repos in the registry the images are mapped to, with the credentials in the Docker config, to check which of the Chainguard repos the organization is entitled to.")
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().StringVar(&opts.HistoryFile, "history-file", "", "Append a summary of the run, with the number of images that were and weren't mapped and the coverage percentage, to this file as a line of JSON, so that migration progress can be charted over time.")
+	cmd.Flags().StringVar(&opts.HistoryURL, "history-url", "", "POST a JSON summary of the run, like the lines of --history-file, to this URL.")
+	cmd.Flags().StringVar(&opts.HistoryLabel, "history-label", "", "A label for the run in the history, like the name of the application or repository, so that the runs of different targets can be told apart. Defaults to the path or URL that was scanned.")
+	cmd.Flags().StringSliceVar(&opts.Ignore, "ignore", []string{}, "Skip the paths that match these patterns, which are in the format of a .gitignore file, like examples/ or **/testdata. Paths in .gitignore and .imagemapperignore files are always skipped.")
+	cmd.Flags().IntVar(&opts.Workers, "workers", runtime.NumCPU(), "The number of files that are read at once.")
+	cmd.Flags().StringVar(&opts.CustomResources, "custom-resources", "", "A YAML file of custom resources, by apiVersion and kind, and the JSONPaths of the fields in them that hold images, like spec.kafka.image.")
+	cmd.Flags().StringSliceVar(&opts.ScannerPlugins, "scanner-plugin", []string{}, "Executables that find the images in formats of file that aren't built in, like the manifests of an internal platform. See docs/scan.md for the protocol they implement.")
+	cmd.Flags().BoolVar(&opts.Strict, "strict", false, "Exit with code 2 if any of the images can't be mapped, so that CI pipelines can require a mapping for every image.")
+
+	return cmd
+}
+
+// loadScanners returns the scanners that are compiled in, followed by the
+// scanner plugins
+func loadScanners(ctx context.Context, plugins []string) ([]scanner.Scanner, error) {
+	scanners := scanner.Registered()
+	for _, plugin := range plugins {
+		s, err := scanner.LoadExec(ctx, plugin)
+		if err != nil {
+			return nil, err
+		}
+		scanners = append(scanners, s)
+	}
+
+	return scanners, nil
+}
+
+// outputScanText writes the mappings under a heading for each file
+func outputScanText(w io.Writer, results []scan.Result) error {
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\n", result.Path, result.Type)
+		for _, m := range result.Mappings {
+			for _, r := range m.Results {
+				fmt.Fprintf(w, "  %s -> %s\n", m.Image, r)
+			}
+			if len(m.Results) == 0 {
+				fmt.Fprintf(w, "  %s ->\n", m.Image)
+			}
+			for _, l := range m.Locations {
+				fmt.Fprintf(w, "    at: %s\n", l)
+			}
+		}
+	}
+
+	return nil
+}
+
+// outputScanEntitlements writes the Chainguard repos that are needed to cover
+// the images in every file, like the entitlements output of map
+func outputScanEntitlements(w io.Writer, results []scan.Result) error {
+	var mappings []*mapper.Mapping
+	for _, result := range results {
+		mappings = append(mappings, result.Mappings...)
+	}
+
+	output, err := mapper.NewOutput("entitlements")
+	if err != nil {
+		return err
+	}
+
+	return output(w, mappings)
+}
+
+// outputScanCSV writes a row for each image in each file. The locations of
+// the image in the file are joined by semicolons in the last column, when
+// they're known.
+func outputScanCSV(w io.Writer, results []scan.Result) error {
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	for _, result := range results {
+		for _, m := range result.Mappings {
+			record := []string{result.Path, string(result.Type), m.Image, fmt.Sprintf("%s", m.Results), strconv.Itoa(m.Occurrences)}
+			if len(m.Locations) > 0 {
+				locations := make([]string, len(m.Locations))
+				for i, l := range m.Locations {
+					locations[i] = l.String()
+				}
+				record = append(record, strings.Join(locations, ";"))
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("writing CSV record: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
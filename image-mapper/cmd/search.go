@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		SearchCommand(),
+	)
+}
+
+func SearchCommand() *cobra.Command {
+	opts := struct {
+		OutputFormat string
+		Aliases      []string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "search <term>",
+		Short: "Search the Chainguard catalog for repositories.",
+		Example: `
+  # Find repositories with a name or alias that contains 'nginx'
+  image-mapper search nginx
+
+  # Find the repositories an upstream image would map to
+  image-mapper search ghcr.io/stakater/reloader
+
+  # Output the results as JSON
+  image-mapper search redis -o json
+`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return completeImages(cmd, args, toComplete)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := mapper.NewRepoOutput(opts.OutputFormat)
+			if err != nil {
+				return fmt.Errorf("constructing output: %w", err)
+			}
+
+			repos, err := mapper.ListRepos(cmd.Context(), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale))
+			if err != nil {
+				return fmt.Errorf("listing repos: %w", err)
+			}
+
+			return output(os.Stdout, mapper.SearchRepos(repos, args[0]))
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "Output format (json, text)")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+
+	return cmd
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/cache"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/server"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		ServeCommand(),
+	)
+}
+
+func ServeCommand() *cobra.Command {
+	opts := struct {
+		Addr        string
+		Refresh     time.Duration
+		Filters     filterOptions
+		Repo        string
+		RepoMap     []string
+		TagStrategy string
+		Aliases     []string
+		CacheURL    string
+		Stale       time.Duration
+	}{}
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the mapper over HTTP, so that other tools can map images without running image-mapper.",
+		Example: `
+# Serve the API on port 8080
+image-mapper serve
+
+# Map an image
+curl -s -X POST localhost:8080/map -d '{"image": "nginx:1.27"}'
+
+# Map a Dockerfile
+curl -s -X POST localhost:8080/map/dockerfile --data-binary @Dockerfile
+
+# Map a Helm values file
+curl -s -X POST 'localhost:8080/map/helm-values?full=true' --data-binary @values.yaml
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			ignoreFns, err := opts.Filters.ignoreFns()
+			if err != nil {
+				return err
+			}
+			mapperOpts := []mapper.Option{
+				mapper.WithRepository(opts.Repo),
+				mapper.WithRepositoryMap(opts.RepoMap...),
+				mapper.WithTagStrategy(opts.TagStrategy),
+				mapper.WithAliasOverrides(opts.Aliases...),
+				mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(),
+				mapper.WithResultCache(rootOpts.CacheResults),
+				mapper.WithCacheDuration(rootOpts.CacheDuration),
+				mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale),
+				mapper.WithIgnoreFns(ignoreFns...),
+				mapper.WithTierOrder(opts.Filters.TierOrder...),
+			}
+			if opts.CacheURL != "" {
+				c, err := cache.New(opts.CacheURL)
+				if err != nil {
+					return err
+				}
+				mapperOpts = append(mapperOpts, mapper.WithCache(c), mapper.WithStaleWhileRevalidate(opts.Stale))
+			}
+
+			mappers, err := server.NewMappers(ctx, mapperOpts...)
+			if err != nil {
+				return err
+			}
+			srv := server.New(mappers)
+			if opts.Refresh > 0 {
+				// The disk cache is bypassed, because it may not have
+				// expired yet. The shared cache isn't, so that the
+				// replicas don't all fetch the catalog, but it's
+				// revalidated in the background once it expires.
+				refreshOpts := mapperOpts
+				if opts.CacheURL == "" {
+					refreshOpts = append(slices.Clone(mapperOpts), mapper.WithRefresh(true))
+				}
+				go srv.Refresh(ctx, opts.Refresh, refreshOpts...)
+			}
+
+			httpServer := &http.Server{
+				Addr:              opts.Addr,
+				Handler:           srv.Handler(),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			errCh := make(chan error, 1)
+			go func() {
+				slog.Info("listening", "addr", opts.Addr)
+				errCh <- httpServer.ListenAndServe()
+			}()
+
+			select {
+			case err := <-errCh:
+				return fmt.Errorf("serving: %w", err)
+			case <-ctx.Done():
+			}
+
+			slog.Info("shutting down")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				return fmt.Errorf("shutting down: %w", err)
+			}
+			if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("serving: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Addr, "addr", ":8080", "The address to listen on.")
+	cmd.Flags().DurationVar(&opts.Refresh, "refresh", time.Hour, "How often to fetch the catalog again, so that new images and tags are picked up. Set to 0 to only fetch it at startup.")
+	opts.Filters.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.Repo, "repository", "cgr.dev/chainguard", "Modifies the repository URI in the mappings. For instance, registry.internal.dev/chainguard would result in registry.internal.dev/chainguard/<image> in the output.")
+	cmd.Flags().StringSliceVar(&opts.RepoMap, "repository-map", []string{}, "Rules that set the repository URI in the mappings for particular Chainguard repos, in the form PATTERN=REPOSITORY. The pattern is a tier, like FIPS, or a glob that matches the name of the repo, like *-fips. The first rule that matches is used, and --repository is used for the repos that don't match any rule. For instance, FIPS=registry.internal.dev/chainguard-fips.")
+	cmd.Flags().StringVar(&opts.TagStrategy, "tag-strategy", mapper.TagStrategyMapSemver, "How the tags of the mapped images are chosen: map-semver maps to the equivalent or closest version, keep-upstream keeps the upstream tag, latest uses latest (or latest-dev for -dev images) and latest-dev uses latest-dev.")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+	cmd.Flags().StringVar(&opts.CacheURL, "cache-url", "", "Cache the catalog data in Redis (redis://host:6379/0) or memcached (memcache://host1:11211,host2:11211), rather than on disk, so that it's shared by the replicas of the server and only one of them fetches the catalog at a time. It's cached for --cache-duration.")
+	cmd.Flags().DurationVar(&opts.Stale, "stale-while-revalidate", 0, "How long after the catalog data in the --cache-url cache has expired it's still used, while one of the replicas fetches it again in the background.")
+
+	return cmd
+}
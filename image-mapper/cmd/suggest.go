@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(
+		SuggestAliasesCommand(),
+	)
+}
+
+func SuggestAliasesCommand() *cobra.Command {
+	opts := struct {
+		OutputFormat string
+		FromFiles    []string
+		MinImages    int
+		IgnoreTiers  []string
+		Aliases      []string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "suggest-aliases",
+		Short: "Suggest alias overrides for namespaces of images that follow the same naming rule.",
+		Long: `Suggest alias overrides for namespaces of images that follow the same naming rule.
+
+The images are grouped by namespace, like bitnami for docker.io/bitnami/nginx.
+For each namespace with enough images, the naming rule that matches the most
+Chainguard repositories, like {name}-bitnami, is applied to the images that
+aren't already mapped that way. The result is an alias overrides file for
+--aliases.`,
+		Example: `
+# Suggest aliases for the images in a list
+image-mapper suggest-aliases --from-file=images.txt > aliases.yaml
+
+# Review the suggestions, then apply them
+image-mapper map --from-file=images.txt --aliases=aliases.yaml
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && len(opts.FromFiles) == 0 {
+				return fmt.Errorf("requires at least 1 arg or --from-file")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var its []mapper.Iterator
+			switch {
+			case len(args) > 0 && args[0] == "-":
+				its = append(its, mapper.NewReaderIterator(os.Stdin))
+			case len(args) > 0:
+				its = append(its, mapper.NewArgsIterator(args))
+			}
+			for _, path := range opts.FromFiles {
+				f, err := os.Open(path)
+				if err != nil {
+					return fmt.Errorf("opening file: %s: %w", path, err)
+				}
+				defer f.Close()
+
+				its = append(its, mapper.NewListIterator(f))
+			}
+
+			it := mapper.NewMultiIterator(its...)
+			var images []string
+			for {
+				image, err := it.Next()
+				if err == mapper.ErrIteratorDone {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("iterating over images: %w", err)
+				}
+				images = append(images, image)
+			}
+
+			repos, err := mapper.ListRepos(cmd.Context(), mapper.WithAliasOverrides(opts.Aliases...), mapper.WithCatalogURL(rootOpts.CatalogURL), identityOption(), mapper.WithCacheDuration(rootOpts.CacheDuration), mapper.WithRefresh(rootOpts.RefreshCache), mapper.WithAllowStale(rootOpts.AllowStale))
+			if err != nil {
+				return fmt.Errorf("listing repos: %w", err)
+			}
+			if len(opts.IgnoreTiers) > 0 {
+				ignore := mapper.IgnoreTiers(opts.IgnoreTiers)
+				var filtered []mapper.Repo
+				for _, repo := range repos {
+					if !ignore(repo) {
+						filtered = append(filtered, repo)
+					}
+				}
+				repos = filtered
+			}
+
+			suggestions := mapper.SuggestAliases(repos, images, opts.MinImages)
+			if len(suggestions) == 0 {
+				slog.Info("no suggestions", "images", len(images), "min-images", opts.MinImages)
+			}
+
+			switch opts.OutputFormat {
+			case "yaml":
+				return mapper.WriteAliasSuggestions(os.Stdout, suggestions)
+			case "json":
+				if suggestions == nil {
+					suggestions = []mapper.AliasSuggestion{}
+				}
+				return json.NewEncoder(os.Stdout).Encode(suggestions)
+			default:
+				return fmt.Errorf("unsupported output format: %s (supported: json, yaml)", opts.OutputFormat)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "yaml", "Output format (json, yaml). yaml is an alias overrides file for --aliases and json includes how many images each rule matches.")
+	cmd.Flags().StringSliceVar(&opts.FromFiles, "from-file", []string{}, "Files containing lists of images. Images can be separated by newlines or whitespace and lines starting with # are ignored.")
+	cmd.Flags().IntVar(&opts.MinImages, "min-images", 5, "The number of images a namespace must have before a rule is suggested for it.")
+	cmd.Flags().StringSliceVar(&opts.IgnoreTiers, "ignore-tiers", []string{}, "Don't suggest Chainguard repos of specific tiers (PREMIUM, APPLICATION, BASE, FIPS, AI)")
+	cmd.Flags().StringSliceVar(&opts.Aliases, "aliases", []string{}, "Files or URLs of YAML alias overrides to apply over the catalog data.")
+
+	return cmd
+}
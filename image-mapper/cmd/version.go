@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/spf13/cobra"
+)
+
+// The build metadata of a release, which is set by the linker:
+//
+//	go build -ldflags "-X github.com/chainguard-dev/customer-success/scripts/image-mapper/cmd.version=v1.2.3"
+//
+// When it isn't set, it's read from the build info that go embeds in the
+// binary.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+func init() {
+	rootCmd.AddCommand(
+		VersionCommand(),
+	)
+	rootCmd.Version = buildVersion()
+}
+
+// BuildInfo describes the build of image-mapper
+type BuildInfo struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit,omitempty"`
+	Date          string `json:"date,omitempty"`
+	GoVersion     string `json:"goVersion"`
+	Platform      string `json:"platform"`
+	SchemaVersion string `json:"schemaVersion"`
+}
+
+func VersionCommand() *cobra.Command {
+	opts := struct {
+		OutputFormat string
+	}{}
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version of image-mapper and how it was built.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := buildInfo()
+			switch opts.OutputFormat {
+			case "json":
+				return json.NewEncoder(os.Stdout).Encode(info)
+			case "text":
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+				fmt.Fprintf(w, "version:\t%s\n", info.Version)
+				if info.Commit != "" {
+					fmt.Fprintf(w, "commit:\t%s\n", info.Commit)
+				}
+				if info.Date != "" {
+					fmt.Fprintf(w, "date:\t%s\n", info.Date)
+				}
+				fmt.Fprintf(w, "go:\t%s\n", info.GoVersion)
+				fmt.Fprintf(w, "platform:\t%s\n", info.Platform)
+				fmt.Fprintf(w, "schema:\t%s\n", info.SchemaVersion)
+				return w.Flush()
+			default:
+				return fmt.Errorf("unsupported output format: %s (supported: json, text)", opts.OutputFormat)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "Output format (json, text)")
+
+	return cmd
+}
+
+// buildInfo returns the build metadata set by the linker or, for builds
+// without it, like go install, the module version and the VCS details that
+// go embeds in the binary
+func buildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:       version,
+		Commit:        commit,
+		Date:          date,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		SchemaVersion: mapper.SchemaVersion,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		if info.Version == "" {
+			info.Version = "dev"
+		}
+		return info
+	}
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	if info.Version == "" || info.Version == "(devel)" {
+		info.Version = "dev"
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.Date == "":
+			info.Date = s.Value
+		}
+	}
+
+	return info
+}
+
+// buildVersion returns the version of image-mapper
+func buildVersion() string {
+	return buildInfo().Version
+}
@@ -0,0 +1,434 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-cmp/cmp"
+	"github.com/redis/go-redis/v9"
+)
+
+// backend is a cache that's backed by a fake server, and a function that
+// moves the clock of the server forward, so that TTLs can be tested without
+// waiting for them
+type backend struct {
+	cache   mapper.Cache
+	advance func(time.Duration)
+}
+
+func newRedisBackend(t *testing.T) backend {
+	t.Helper()
+
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return backend{
+		cache:   &Redis{Client: client},
+		advance: srv.FastForward,
+	}
+}
+
+func newMemcachedBackend(t *testing.T) backend {
+	t.Helper()
+
+	srv := newFakeMemcached(t)
+
+	return backend{
+		cache:   &Memcached{Client: memcache.New(srv.addr)},
+		advance: srv.advance,
+	}
+}
+
+func TestBackends(t *testing.T) {
+	backends := map[string]func(*testing.T) backend{
+		"redis":     newRedisBackend,
+		"memcached": newMemcachedBackend,
+	}
+
+	type step struct {
+		op      string
+		key     string
+		value   string
+		ttl     time.Duration
+		advance time.Duration
+
+		// want is the value that's expected from a get, and whether the
+		// value was found by a get or added by an add
+		want   string
+		wantOK bool
+	}
+	testCases := map[string][]step{
+		"get a missing key": {
+			{op: "get", key: "a", wantOK: false},
+		},
+		"set and get": {
+			{op: "set", key: "a", value: "1"},
+			{op: "get", key: "a", want: "1", wantOK: true},
+			{op: "get", key: "b", wantOK: false},
+		},
+		"set replaces the value": {
+			{op: "set", key: "a", value: "1"},
+			{op: "set", key: "a", value: "2"},
+			{op: "get", key: "a", want: "2", wantOK: true},
+		},
+		"add a missing key": {
+			{op: "add", key: "a", value: "1", wantOK: true},
+			{op: "get", key: "a", want: "1", wantOK: true},
+		},
+		"add when the key exists returns false": {
+			{op: "set", key: "a", value: "1"},
+			{op: "add", key: "a", value: "2", wantOK: false},
+			{op: "get", key: "a", want: "1", wantOK: true},
+		},
+		"add twice returns false the second time": {
+			{op: "add", key: "lock", value: "1", wantOK: true},
+			{op: "add", key: "lock", value: "2", wantOK: false},
+		},
+		"delete": {
+			{op: "set", key: "a", value: "1"},
+			{op: "delete", key: "a"},
+			{op: "get", key: "a", wantOK: false},
+		},
+		"delete a missing key": {
+			{op: "delete", key: "a"},
+		},
+		"add after delete": {
+			{op: "add", key: "lock", value: "1", wantOK: true},
+			{op: "delete", key: "lock"},
+			{op: "add", key: "lock", value: "2", wantOK: true},
+			{op: "get", key: "lock", want: "2", wantOK: true},
+		},
+		"set expires after the TTL": {
+			{op: "set", key: "a", value: "1", ttl: time.Minute},
+			{op: "get", key: "a", advance: 30 * time.Second, want: "1", wantOK: true},
+			{op: "get", key: "a", advance: time.Minute, wantOK: false},
+		},
+		"set without a TTL doesn't expire": {
+			{op: "set", key: "a", value: "1"},
+			{op: "get", key: "a", advance: 24 * time.Hour, want: "1", wantOK: true},
+		},
+		"add after the TTL of the key": {
+			{op: "add", key: "lock", value: "1", ttl: time.Minute, wantOK: true},
+			{op: "add", key: "lock", value: "2", ttl: time.Minute, advance: 30 * time.Second, wantOK: false},
+			{op: "add", key: "lock", value: "3", ttl: time.Minute, advance: time.Minute, wantOK: true},
+			{op: "get", key: "lock", want: "3", wantOK: true},
+		},
+	}
+	for backendName, newBackend := range backends {
+		for name, steps := range testCases {
+			t.Run(backendName+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+				b := newBackend(t)
+
+				for i, s := range steps {
+					if s.advance > 0 {
+						b.advance(s.advance)
+					}
+
+					switch s.op {
+					case "get":
+						got, ok, err := b.cache.Get(ctx, s.key)
+						if err != nil {
+							t.Fatalf("step %d: unexpected error: %s", i, err)
+						}
+						if ok != s.wantOK {
+							t.Errorf("step %d: expected found to be %t, got %t", i, s.wantOK, ok)
+						}
+						if diff := cmp.Diff(s.want, string(got)); diff != "" {
+							t.Errorf("step %d: unexpected value (-want +got):\n%s", i, diff)
+						}
+					case "set":
+						if err := b.cache.Set(ctx, s.key, []byte(s.value), s.ttl); err != nil {
+							t.Fatalf("step %d: unexpected error: %s", i, err)
+						}
+					case "add":
+						ok, err := b.cache.Add(ctx, s.key, []byte(s.value), s.ttl)
+						if err != nil {
+							t.Fatalf("step %d: unexpected error: %s", i, err)
+						}
+						if ok != s.wantOK {
+							t.Errorf("step %d: expected added to be %t, got %t", i, s.wantOK, ok)
+						}
+					case "delete":
+						if err := b.cache.Delete(ctx, s.key); err != nil {
+							t.Fatalf("step %d: unexpected error: %s", i, err)
+						}
+					default:
+						t.Fatalf("step %d: unknown op %q", i, s.op)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBackendErrors(t *testing.T) {
+	ctx := context.Background()
+
+	// Nothing is listening on the address, so every call fails
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	caches := map[string]mapper.Cache{
+		"redis":     &Redis{Client: client},
+		"memcached": &Memcached{Client: memcache.New(addr)},
+	}
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := c.Get(ctx, "a"); err == nil {
+				t.Errorf("expected an error from get")
+			}
+			if err := c.Set(ctx, "a", []byte("1"), time.Minute); err == nil {
+				t.Errorf("expected an error from set")
+			}
+			if _, err := c.Add(ctx, "a", []byte("1"), time.Minute); err == nil {
+				t.Errorf("expected an error from add")
+			}
+			if err := c.Delete(ctx, "a"); err == nil {
+				t.Errorf("expected an error from delete")
+			}
+		})
+	}
+}
+
+func TestListReposCacheUnavailable(t *testing.T) {
+	// Nothing is listening on the address, so every call fails
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	testCases := map[string]func(*testing.T) mapper.Cache{
+		"redis is down": func(t *testing.T) mapper.Cache {
+			client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+			t.Cleanup(func() { client.Close() })
+			return &Redis{Client: client}
+		},
+		"memcached is down": func(t *testing.T) mapper.Cache {
+			return &Memcached{Client: memcache.New(addr)}
+		},
+		// Like memcached's default limit of 1MB, which the catalog
+		// can be over
+		"memcached rejects the catalog": func(t *testing.T) mapper.Cache {
+			srv := newFakeMemcached(t)
+			srv.maxSize = 64
+			return &Memcached{Client: memcache.New(srv.addr)}
+		},
+	}
+	for name, newCache := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"data":{"repos":[{"name":"nginx","catalogTier":"APPLICATION","aliases":["nginx"],"activeTags":["latest"]}]}}`))
+			}))
+			defer srv.Close()
+
+			got, err := mapper.ListRepos(t.Context(), mapper.WithCatalogURL(srv.URL), mapper.WithCache(newCache(t)), mapper.WithCacheDuration(time.Hour))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			want := []mapper.Repo{
+				{
+					Name:        "nginx",
+					CatalogTier: "APPLICATION",
+					Aliases:     []string{"nginx"},
+					ActiveTags:  []string{"latest"},
+				},
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected repos (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeMemcached is a memcached server that understands the parts of the text
+// protocol that the client uses for get, set, add and delete
+type fakeMemcached struct {
+	addr string
+
+	// maxSize is the size of the largest value that's stored, when it's
+	// set
+	maxSize int
+
+	mu    sync.Mutex
+	now   time.Time
+	items map[string]fakeMemcachedItem
+}
+
+type fakeMemcachedItem struct {
+	value   []byte
+	flags   uint32
+	expires time.Time
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	m := &fakeMemcached{
+		addr:  lis.Addr().String(),
+		now:   time.Now(),
+		items: map[string]fakeMemcachedItem{},
+	}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+
+	return m
+}
+
+func (m *fakeMemcached) advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = m.now.Add(d)
+}
+
+func (m *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var resp string
+		switch fields[0] {
+		case "gets":
+			resp = m.get(fields[1:])
+		case "set", "add":
+			if len(fields) < 5 {
+				resp = "ERROR\r\n"
+				break
+			}
+			size, _ := strconv.Atoi(fields[4])
+			value := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, value); err != nil {
+				return
+			}
+			flags, _ := strconv.ParseUint(fields[2], 10, 32)
+			exptime, _ := strconv.ParseInt(fields[3], 10, 64)
+			resp = m.store(fields[0], fields[1], value[:size], uint32(flags), exptime)
+		case "delete":
+			resp = m.delete(fields[1])
+		default:
+			resp = "ERROR\r\n"
+		}
+
+		if _, err := rw.WriteString(resp); err != nil {
+			return
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// lookup returns the item of the key, if it's set and hasn't expired. It's
+// called with the lock held.
+func (m *fakeMemcached) lookup(key string) (fakeMemcachedItem, bool) {
+	item, ok := m.items[key]
+	if !ok {
+		return item, false
+	}
+	if !item.expires.IsZero() && !m.now.Before(item.expires) {
+		delete(m.items, key)
+		return item, false
+	}
+
+	return item, true
+}
+
+func (m *fakeMemcached) get(keys []string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var resp strings.Builder
+	for _, key := range keys {
+		if item, ok := m.lookup(key); ok {
+			fmt.Fprintf(&resp, "VALUE %s %d %d 0\r\n%s\r\n", key, item.flags, len(item.value), item.value)
+		}
+	}
+	resp.WriteString("END\r\n")
+
+	return resp.String()
+}
+
+func (m *fakeMemcached) store(verb, key string, value []byte, flags uint32, exptime int64) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.maxSize > 0 && len(value) > m.maxSize {
+		return "SERVER_ERROR object too large for cache\r\n"
+	}
+	if _, ok := m.lookup(key); ok && verb == "add" {
+		return "NOT_STORED\r\n"
+	}
+
+	// Expirations of up to 30 days are relative, and longer ones are a
+	// Unix time
+	var expires time.Time
+	switch {
+	case exptime <= 0:
+	case time.Duration(exptime)*time.Second <= memcachedMaxRelative:
+		expires = m.now.Add(time.Duration(exptime) * time.Second)
+	default:
+		expires = time.Unix(exptime, 0)
+	}
+	m.items[key] = fakeMemcachedItem{value: value, flags: flags, expires: expires}
+
+	return "STORED\r\n"
+}
+
+func (m *fakeMemcached) delete(key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lookup(key); !ok {
+		return "NOT_FOUND\r\n"
+	}
+	delete(m.items, key)
+
+	return "DELETED\r\n"
+}
@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Options configures how to connect to the cluster and which pods to scan
+type Options struct {
+	// Kubeconfig is the path to the kubeconfig file. If it's empty, the
+	// default loading rules are used (i.e $KUBECONFIG or ~/.kube/config).
+	Kubeconfig string
+
+	// Context is the kubeconfig context to use. If it's empty, the
+	// current context is used.
+	Context string
+
+	// Namespace limits the scan to a single namespace. If it's empty, all
+	// namespaces are scanned.
+	Namespace string
+
+	// LabelSelector limits the scan to pods that match the selector
+	LabelSelector string
+}
+
+// podLister lists pods in a namespace
+type podLister func(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.PodList, error)
+
+// ListImages returns the image of every container in the pods in the cluster,
+// including init and ephemeral containers. An image is returned once for each
+// container that uses it.
+func ListImages(ctx context.Context, opts Options) ([]string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.Kubeconfig
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: opts.Context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	config.UserAgent = "image-mapper"
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("constructing client: %w", err)
+	}
+
+	return listImages(ctx, func(ctx context.Context, namespace string, listOpts metav1.ListOptions) (*corev1.PodList, error) {
+		return client.CoreV1().Pods(namespace).List(ctx, listOpts)
+	}, opts)
+}
+
+// pageSize is the number of pods to request at once, so that we don't load
+// every pod in a large cluster in one response
+const pageSize = 500
+
+func listImages(ctx context.Context, list podLister, opts Options) ([]string, error) {
+	var (
+		images []string
+		next   string
+	)
+	for {
+		pods, err := list(ctx, opts.Namespace, metav1.ListOptions{
+			LabelSelector: opts.LabelSelector,
+			Limit:         pageSize,
+			Continue:      next,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing pods: %w", err)
+		}
+
+		for _, pod := range pods.Items {
+			images = append(images, podImages(pod)...)
+		}
+
+		next = pods.Continue
+		if next == "" {
+			break
+		}
+	}
+
+	return images, nil
+}
+
+// podImages returns the images of the containers in a pod
+func podImages(pod corev1.Pod) []string {
+	var images []string
+	for _, c := range pod.Spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		images = append(images, c.Image)
+	}
+
+	return images
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestListImages(t *testing.T) {
+	pages := map[string]*corev1.PodList{
+		"": {
+			ListMeta: metav1.ListMeta{Continue: "page-2"},
+			Items: []corev1.Pod{
+				{
+					Spec: corev1.PodSpec{
+						InitContainers: []corev1.Container{
+							{Image: "busybox"},
+						},
+						Containers: []corev1.Container{
+							{Image: "nginx:1.25"},
+							{Image: "prom/node-exporter:v1.8.0"},
+						},
+					},
+				},
+			},
+		},
+		"page-2": {
+			Items: []corev1.Pod{
+				{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{Image: "nginx:1.25"},
+						},
+						EphemeralContainers: []corev1.EphemeralContainer{
+							{
+								EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+									Image: "busybox:1.36",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var got []metav1.ListOptions
+	list := func(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.PodList, error) {
+		if namespace != "default" {
+			return nil, fmt.Errorf("unexpected namespace: %s", namespace)
+		}
+		got = append(got, opts)
+
+		return pages[opts.Continue], nil
+	}
+
+	images, err := listImages(t.Context(), list, Options{
+		Namespace:     "default",
+		LabelSelector: "app=example",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantImages := []string{
+		"busybox",
+		"nginx:1.25",
+		"prom/node-exporter:v1.8.0",
+		"nginx:1.25",
+		"busybox:1.36",
+	}
+	if diff := cmp.Diff(wantImages, images); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+
+	wantOpts := []metav1.ListOptions{
+		{LabelSelector: "app=example", Limit: pageSize},
+		{LabelSelector: "app=example", Limit: pageSize, Continue: "page-2"},
+	}
+	if diff := cmp.Diff(wantOpts, got); diff != "" {
+		t.Errorf("unexpected list options (-want +got):\n%s", diff)
+	}
+}
+
+func TestListImagesError(t *testing.T) {
+	list := func(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.PodList, error) {
+		return nil, fmt.Errorf("forbidden")
+	}
+
+	if _, err := listImages(t.Context(), list, Options{}); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables that set flags. For
+// instance, IMAGE_MAPPER_REPOSITORY sets --repository.
+const EnvPrefix = "IMAGE_MAPPER_"
+
+// Config holds default values for flags, keyed by the name of the flag:
+//
+//	repository: registry.internal/chainguard
+//	ignore-tiers:
+//	  - FIPS
+//	output: csv
+//	commands:
+//	  map helm-chart:
+//	    output: flux
+//
+// The top level values apply to every command with the flag. The values
+// under commands apply only to the command with that path, without the
+// image-mapper prefix, and take precedence over the top level values.
+type Config struct {
+	Values   map[string]any
+	Commands map[string]map[string]any
+}
+
+// DefaultPath returns the default location of the config file, which is
+// image-mapper/config.yaml in $XDG_CONFIG_HOME or ~/.config
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("finding home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "image-mapper", "config.yaml"), nil
+}
+
+// Load reads the config file at path. When the file doesn't exist and
+// mustExist is false, an empty config is returned.
+func Load(path string, mustExist bool) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !mustExist {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse parses a config file
+func Parse(data []byte) (*Config, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+
+	cfg := &Config{
+		Values:   map[string]any{},
+		Commands: map[string]map[string]any{},
+	}
+	for k, v := range raw {
+		if k != "commands" {
+			cfg.Values[k] = v
+			continue
+		}
+		commands, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("parsing config: commands must be a map")
+		}
+		for path, values := range commands {
+			m, ok := values.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("parsing config: commands: %s must be a map", path)
+			}
+			cfg.Commands[path] = m
+		}
+	}
+
+	return cfg, nil
+}
+
+// Apply sets the flags that weren't set on the command line. Environment
+// variables take precedence over the values for the command, which take
+// precedence over the top level values. Values for flags that the command
+// doesn't have are ignored, so that one config can serve every command.
+func (c *Config) Apply(flags *pflag.FlagSet, command string, getenv func(string) string) error {
+	var errs []error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+
+		if env := getenv(EnvName(f.Name)); env != "" {
+			if err := f.Value.Set(env); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", EnvName(f.Name), err))
+			}
+			return
+		}
+
+		v, ok := c.Commands[command][f.Name]
+		if !ok {
+			v, ok = c.Values[f.Name]
+		}
+		if !ok {
+			return
+		}
+		if err := setValue(f, v); err != nil {
+			errs = append(errs, fmt.Errorf("config: %s: %w", f.Name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// EnvName returns the name of the environment variable that sets the flag
+func EnvName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// setValue sets the flag to a value from the config. Lists replace the value
+// of flags that accept more than one value.
+func setValue(f *pflag.Flag, v any) error {
+	list, isList := v.([]any)
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		if !isList {
+			list = []any{v}
+		}
+		var values []string
+		for _, item := range list {
+			values = append(values, fmt.Sprint(item))
+		}
+		return sv.Replace(values)
+	}
+	if isList {
+		return fmt.Errorf("expected a single value")
+	}
+
+	return f.Value.Set(fmt.Sprint(v))
+}
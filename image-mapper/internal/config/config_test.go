@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/pflag"
+)
+
+const testConfig = `
+repository: registry.internal/chainguard
+ignore-tiers:
+  - FIPS
+  - AI
+output: csv
+cache-duration: 24h
+commands:
+  map helm-chart:
+    output: flux
+`
+
+type testFlags struct {
+	Repo          string
+	IgnoreTiers   []string
+	Output        string
+	CacheDuration time.Duration
+	Vulns         bool
+}
+
+func newTestFlags(f *testFlags) *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringVar(&f.Repo, "repository", "cgr.dev/chainguard", "")
+	fs.StringSliceVar(&f.IgnoreTiers, "ignore-tiers", []string{}, "")
+	fs.StringVarP(&f.Output, "output", "o", "text", "")
+	fs.DurationVar(&f.CacheDuration, "cache-duration", time.Hour, "")
+	fs.BoolVar(&f.Vulns, "vulns", false, "")
+
+	return fs
+}
+
+func TestApply(t *testing.T) {
+	cfg, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := map[string]struct {
+		command string
+		args    []string
+		env     map[string]string
+		want    testFlags
+	}{
+		"config": {
+			command: "map",
+			want: testFlags{
+				Repo:          "registry.internal/chainguard",
+				IgnoreTiers:   []string{"FIPS", "AI"},
+				Output:        "csv",
+				CacheDuration: 24 * time.Hour,
+			},
+		},
+		"command": {
+			command: "map helm-chart",
+			want: testFlags{
+				Repo:          "registry.internal/chainguard",
+				IgnoreTiers:   []string{"FIPS", "AI"},
+				Output:        "flux",
+				CacheDuration: 24 * time.Hour,
+			},
+		},
+		"flags": {
+			command: "map helm-chart",
+			args:    []string{"--repository=registry.example.com/cg", "--ignore-tiers=BASE", "-o", "json"},
+			want: testFlags{
+				Repo:          "registry.example.com/cg",
+				IgnoreTiers:   []string{"BASE"},
+				Output:        "json",
+				CacheDuration: 24 * time.Hour,
+			},
+		},
+		"env": {
+			command: "map helm-chart",
+			args:    []string{"-o", "json"},
+			env: map[string]string{
+				"IMAGE_MAPPER_OUTPUT":         "text",
+				"IMAGE_MAPPER_IGNORE_TIERS":   "BASE,PREMIUM",
+				"IMAGE_MAPPER_CACHE_DURATION": "0",
+				"IMAGE_MAPPER_VULNS":          "true",
+			},
+			want: testFlags{
+				Repo:          "registry.internal/chainguard",
+				IgnoreTiers:   []string{"BASE", "PREMIUM"},
+				Output:        "json",
+				CacheDuration: 0,
+				Vulns:         true,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var got testFlags
+			fs := newTestFlags(&got)
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatalf("unexpected error parsing flags: %s", err)
+			}
+
+			if err := cfg.Apply(fs, tc.command, func(k string) string { return tc.env[k] }); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected flags (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	testCases := map[string]string{
+		"duration": "cache-duration: forever\n",
+		"list":     "output: [csv, json]\n",
+	}
+	for name, input := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Parse([]byte(input))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var f testFlags
+			if err := cfg.Apply(newTestFlags(&f), "map", func(string) string { return "" }); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	if _, err := Load(path, true); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+
+	cfg, err := Load(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error for a missing file: %s", err)
+	}
+	if len(cfg.Values) > 0 || len(cfg.Commands) > 0 {
+		t.Errorf("expected an empty config, got: %v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte("commands: []\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	if _, err := Load(path, true); err == nil {
+		t.Errorf("expected an error for invalid commands")
+	}
+}
@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options configures how to connect to the daemon
+type Options struct {
+	// Host is the address of the daemon, i.e unix:///var/run/docker.sock
+	// or tcp://127.0.0.1:2375. If it's empty, $DOCKER_HOST is used, or
+	// the first Docker or Podman socket that exists.
+	Host string
+}
+
+// image is the subset of an image in the response from the images endpoint
+// of the Docker API
+type image struct {
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// ListImages returns the images in the local Docker or Podman daemon. Images
+// are returned by their tags, or by their digests if they don't have any tags.
+func ListImages(ctx context.Context, opts Options) ([]string, error) {
+	host := opts.Host
+	if host == "" {
+		host = defaultHost()
+	}
+
+	client, baseURL, err := newClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return listImages(ctx, client, baseURL)
+}
+
+// defaultHost returns the address of the daemon from $DOCKER_HOST, or the
+// first Docker or Podman socket that exists
+func defaultHost() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+
+	sockets := []string{"/var/run/docker.sock"}
+	if home, err := os.UserHomeDir(); err == nil {
+		// Docker Desktop
+		sockets = append(sockets, filepath.Join(home, ".docker", "run", "docker.sock"))
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		// Rootless Podman
+		sockets = append(sockets, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	sockets = append(sockets, "/run/podman/podman.sock")
+
+	for _, socket := range sockets {
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket
+		}
+	}
+
+	return "unix:///var/run/docker.sock"
+}
+
+// newClient returns a client for the daemon at the host and the base URL of
+// its API
+func newClient(host string) (*http.Client, string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing host: %s: %w", host, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &http.Client{Transport: transport}, "http://daemon", nil
+	case "tcp", "http":
+		return http.DefaultClient, "http://" + u.Host, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported host: %s: must be unix:// or tcp://", host)
+	}
+}
+
+func listImages(ctx context.Context, client *http.Client, baseURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/images/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var imgs []image
+	if err := json.NewDecoder(resp.Body).Decode(&imgs); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	var images []string
+	for _, img := range imgs {
+		var tags []string
+		for _, tag := range img.RepoTags {
+			// Dangling images are tagged <none>:<none>
+			if strings.HasPrefix(tag, "<none>") {
+				continue
+			}
+			tags = append(tags, tag)
+		}
+		if len(tags) == 0 {
+			for _, digest := range img.RepoDigests {
+				if strings.HasPrefix(digest, "<none>") {
+					continue
+				}
+				tags = append(tags, digest)
+			}
+		}
+		images = append(images, tags...)
+	}
+
+	return images, nil
+}
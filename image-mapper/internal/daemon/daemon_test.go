@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListImages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[
+  {"RepoTags": ["nginx:1.25", "nginx:latest"], "RepoDigests": ["nginx@sha256:abc"]},
+  {"RepoTags": ["<none>:<none>"], "RepoDigests": ["python@sha256:def"]},
+  {"RepoTags": null, "RepoDigests": ["<none>@<none>"]},
+  {"RepoTags": ["localhost/example:dev"], "RepoDigests": []}
+]`))
+	}))
+	defer srv.Close()
+
+	client, baseURL, err := newClient(strings.Replace(srv.URL, "http://", "tcp://", 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := listImages(context.Background(), client, baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"nginx:1.25",
+		"nginx:latest",
+		"python@sha256:def",
+		"localhost/example:dev",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestListImagesError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if _, err := listImages(context.Background(), http.DefaultClient, srv.URL); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	testCases := map[string]struct {
+		host    string
+		baseURL string
+		wantErr bool
+	}{
+		"unix": {
+			host:    "unix:///var/run/docker.sock",
+			baseURL: "http://daemon",
+		},
+		"tcp": {
+			host:    "tcp://127.0.0.1:2375",
+			baseURL: "http://127.0.0.1:2375",
+		},
+		"ssh": {
+			host:    "ssh://user@host",
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, baseURL, err := newClient(tc.host)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if baseURL != tc.baseURL {
+				t.Errorf("unexpected base url: %s, wanted %s", baseURL, tc.baseURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package devconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// dockerImageScheme is the prefix of the contexts that are images, rather than
+// directories or other targets
+const dockerImageScheme = "docker-image://"
+
+// bakeFile is the subset of a Bake file in JSON that refers to images
+type bakeFile struct {
+	Variable map[string]struct {
+		Default any `json:"default"`
+	} `json:"variable"`
+	Target map[string]struct {
+		Contexts map[string]string  `json:"contexts"`
+		Args     map[string]*string `json:"args"`
+	} `json:"target"`
+}
+
+// BakeImages returns the images that a Docker Bake file builds from:
+//
+//   - The named contexts that are images, like docker-image://alpine:3.20.
+//   - The args and variables named like images, like BASE_IMAGE, that are set
+//     to image references.
+//
+// Bake files are HCL, or JSON when their name ends in .json. The HCL isn't
+// evaluated, so only literal values are found, and values with interpolation,
+// like "${REGISTRY}/app", are skipped. The images in the Dockerfiles of the
+// targets aren't included.
+func BakeImages(path string) ([]string, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %s: %w", path, err)
+	}
+
+	var images []string
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		images, err = bakeJSONImages(input)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		images = bakeHCLImages(input)
+	}
+
+	var valid []string
+	for _, img := range images {
+		if isBakeImage(img) {
+			valid = append(valid, img)
+		}
+	}
+
+	return valid, nil
+}
+
+// bakeJSONImages returns the images in a Bake file in JSON, ordered by the
+// names of the variables and targets
+func bakeJSONImages(input []byte) ([]string, error) {
+	var f bakeFile
+	if err := json.Unmarshal(input, &f); err != nil {
+		return nil, fmt.Errorf("decoding bake file: %w", err)
+	}
+
+	var images []string
+	for _, name := range sortedKeys(f.Variable) {
+		if v, ok := f.Variable[name].Default.(string); ok && isImageArg(name) {
+			images = append(images, v)
+		}
+	}
+	for _, name := range sortedKeys(f.Target) {
+		target := f.Target[name]
+		for _, key := range sortedKeys(target.Contexts) {
+			if img, ok := strings.CutPrefix(target.Contexts[key], dockerImageScheme); ok {
+				images = append(images, img)
+			}
+		}
+		for _, key := range sortedKeys(target.Args) {
+			if v := target.Args[key]; v != nil && isImageArg(key) {
+				images = append(images, *v)
+			}
+		}
+	}
+
+	return images, nil
+}
+
+var (
+	// bakeBlockPattern matches the start of a block, like target "app" {
+	bakeBlockPattern = regexp.MustCompile(`^\s*([a-z]+)\s+"([^"]*)"\s*\{`)
+
+	// bakeAttributePattern matches an attribute with a string value, on
+	// its own line or in a map on one line, like args = { A = "a" }
+	bakeAttributePattern = regexp.MustCompile(`"?([A-Za-z_][A-Za-z0-9_-]*)"?\s*[=:]\s*"([^"]*)"`)
+)
+
+// bakeHCLImages returns the images in a Bake file in HCL, in the order they
+// appear. The file is scanned line by line, like the Terraform files in the
+// iac package.
+func bakeHCLImages(input []byte) []string {
+	var (
+		images []string
+		block  string
+		label  string
+	)
+	for _, line := range strings.Split(string(input), "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if match := bakeBlockPattern.FindStringSubmatch(line); match != nil {
+			block, label = match[1], match[2]
+		}
+
+		for _, match := range bakeAttributePattern.FindAllStringSubmatch(line, -1) {
+			key, value := match[1], match[2]
+			switch {
+			case strings.HasPrefix(value, dockerImageScheme):
+				images = append(images, strings.TrimPrefix(value, dockerImageScheme))
+			case block == "variable" && key == "default" && isImageArg(label):
+				images = append(images, value)
+			case block == "target" && isImageArg(key):
+				images = append(images, value)
+			}
+		}
+	}
+
+	return images
+}
+
+// isImageArg returns true if the name of an arg or variable suggests that it
+// holds an image, like BASE_IMAGE or builderImage
+func isImageArg(key string) bool {
+	return strings.Contains(strings.ToLower(key), "image")
+}
+
+// isBakeImage returns true if the value is a literal image reference
+func isBakeImage(value string) bool {
+	if value == "" || strings.Contains(value, "$") {
+		return false
+	}
+	_, err := name.ParseReference(value)
+
+	return err == nil
+}
+
+// sortedKeys returns the keys of a map in order, so that the output is stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,144 @@
+package devconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// compose is the subset of a Docker Compose file that refers to images
+type compose struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image string `yaml:"image"`
+
+	// Build is a path to the build context, or a map with the context,
+	// Dockerfile and args
+	Build any `yaml:"build"`
+}
+
+// ComposeImages returns the images that the services in a Docker Compose file
+// refer to, either directly or in the Dockerfiles they're built from. The
+// image of a service that is built is the name of the image it's tagged with,
+// so it isn't included. Images with variables are skipped, because they can't
+// be resolved.
+func ComposeImages(path string) ([]string, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+
+	var c compose
+	if err := yaml.Unmarshal(input, &c); err != nil {
+		return nil, fmt.Errorf("decoding compose file: %s: %w", path, err)
+	}
+
+	// Map iteration order is random, so sort the services to keep the
+	// output stable
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var images []string
+	for _, name := range names {
+		service := c.Services[name]
+
+		switch build := service.Build.(type) {
+		case string:
+			images = append(images, dockerfileImages(filepath.Join(dir, build, "Dockerfile"), nil)...)
+			continue
+		case map[string]any:
+			images = append(images, composeBuildImages(dir, build)...)
+			continue
+		}
+
+		if service.Image != "" && !strings.Contains(service.Image, "$") {
+			images = append(images, service.Image)
+		}
+	}
+
+	return images, nil
+}
+
+// EditComposeImages replaces the images of the services in a Docker Compose
+// file with the images returned by fn, editing them into the input so that
+// everything else, like comments and formatting, is left as it is. The images
+// of services that are built, and images with variables, are left alone, like
+// they're skipped by ComposeImages.
+func EditComposeImages(input []byte, fn func(image string) (string, bool)) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(input, &doc); err != nil {
+		return nil, fmt.Errorf("decoding compose file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return input, nil
+	}
+
+	services := yamlhelpers.LookupNode([]string{"services"}, doc.Content[0])
+	if services == nil || services.Kind != yaml.MappingNode {
+		return input, nil
+	}
+
+	var edits []yamlhelpers.Edit
+	for i := 1; i < len(services.Content); i += 2 {
+		service := yamlhelpers.Resolve(services.Content[i])
+		if service.Kind != yaml.MappingNode || yamlhelpers.LookupNode([]string{"build"}, service) != nil {
+			continue
+		}
+		image := yamlhelpers.LookupNode([]string{"image"}, service)
+		if image == nil || image.Kind != yaml.ScalarNode || image.Value == "" || strings.Contains(image.Value, "$") {
+			continue
+		}
+		if mapped, ok := fn(image.Value); ok && mapped != image.Value {
+			edits = append(edits, yamlhelpers.Edit{Node: image, Value: mapped})
+		}
+	}
+
+	return yamlhelpers.EditScalars(input, edits), nil
+}
+
+// composeBuildImages returns the images in the Dockerfile of a service's build
+// section
+func composeBuildImages(dir string, build map[string]any) []string {
+	context, _ := build["context"].(string)
+	if context == "" {
+		context = "."
+	}
+	path, _ := build["dockerfile"].(string)
+	if path == "" {
+		path = "Dockerfile"
+	}
+
+	// Args are a map, or a list of KEY=VALUE
+	args := map[string]string{}
+	switch a := build["args"].(type) {
+	case map[string]any:
+		for k, v := range a {
+			if s, ok := v.(string); ok {
+				args[k] = s
+			}
+		}
+	case []any:
+		for _, arg := range a {
+			s, ok := arg.(string)
+			if !ok {
+				continue
+			}
+			if k, v, ok := strings.Cut(s, "="); ok {
+				args[k] = v
+			}
+		}
+	}
+
+	return dockerfileImages(filepath.Join(dir, context, path), args)
+}
@@ -0,0 +1,177 @@
+package devconfig
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSkaffoldImages(t *testing.T) {
+	got, err := SkaffoldImages("testdata/skaffold/skaffold.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The example/app image is built by skaffold, so it isn't included
+	want := []string{
+		"python:3.13",
+		"busybox:1.37",
+		"paketobuildpacks/builder-jammy-base",
+		"paketobuildpacks/run-jammy-base",
+		"gcr.io/distroless/static:nonroot",
+		"redis:7.4",
+		"nicolaka/netshoot:v0.13",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestDevContainerImages(t *testing.T) {
+	path, err := FindDevContainer("testdata/devcontainer")
+	if err != nil {
+		t.Fatalf("unexpected error finding devcontainer.json: %s", err)
+	}
+
+	got, err := DevContainerImages(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The cache image has a variable, so it's skipped
+	want := []string{
+		"mcr.microsoft.com/devcontainers/go:1.23",
+		"node:22",
+		"postgres:16",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestEarthfileImages(t *testing.T) {
+	got, err := EarthfileImages("testdata/earthly/Earthfile")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Targets, DOCKERFILE and images with undeclared args are skipped
+	want := []string{
+		"golang:1.23",
+		"gcr.io/distroless/static",
+		"earthly/dind:alpine-3.20",
+		"postgres:16",
+		"redis:7",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestBakeImages(t *testing.T) {
+	testCases := map[string]struct {
+		path string
+		want []string
+	}{
+		"hcl": {
+			path: "testdata/bake/docker-bake.hcl",
+			want: []string{"python:3.13", "alpine:3.20", "golang:1.23", "node:22"},
+		},
+		"json": {
+			path: "testdata/bake/docker-bake.json",
+			want: []string{"python:3.13", "alpine:3.20", "golang:1.23"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := BakeImages(tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected images (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindDevContainerMissing(t *testing.T) {
+	if _, err := FindDevContainer("testdata/skaffold"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestStripJSONC(t *testing.T) {
+	input := `{
+  // A comment
+  "image": "mcr.microsoft.com/devcontainers/base:ubuntu", /* another */
+  "url": "https://example.com/a,/* not a comment */",
+  "quoted": "a \"//\" b",
+  "list": [1, 2,],
+}`
+	want := `{
+  
+  "image": "mcr.microsoft.com/devcontainers/base:ubuntu", 
+  "url": "https://example.com/a,/* not a comment */",
+  "quoted": "a \"//\" b",
+  "list": [1, 2]
+}`
+	if diff := cmp.Diff(want, string(stripJSONC([]byte(input)))); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestEditComposeImages(t *testing.T) {
+	input := `# The services of the app
+services:
+  web:
+    image: nginx:1.25 # the proxy
+  db:
+    image: "postgres:16"
+  cache:
+    image: ${CACHE_IMAGE:-redis:7}
+  app:
+    build: .
+    image: example/app
+  unmapped:
+    image: example/private:1.0
+volumes:
+  data: {}
+`
+	want := `# The services of the app
+services:
+  web:
+    image: cgr.dev/chainguard/nginx:1.25 # the proxy
+  db:
+    image: "cgr.dev/chainguard/postgres:16"
+  cache:
+    image: ${CACHE_IMAGE:-redis:7}
+  app:
+    build: .
+    image: example/app
+  unmapped:
+    image: example/private:1.0
+volumes:
+  data: {}
+`
+	mapped := map[string]string{
+		"nginx:1.25":  "cgr.dev/chainguard/nginx:1.25",
+		"postgres:16": "cgr.dev/chainguard/postgres:16",
+		"redis:7":     "cgr.dev/chainguard/redis:7",
+		"example/app": "cgr.dev/chainguard/app",
+	}
+	got, err := EditComposeImages([]byte(input), func(image string) (string, bool) {
+		m, ok := mapped[image]
+		return m, ok
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+
+	if _, err := EditComposeImages([]byte("services: [\n"), nil); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,129 @@
+package devconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// devContainer is the subset of a devcontainer.json that refers to images
+type devContainer struct {
+	Image string `json:"image"`
+	Build *struct {
+		Dockerfile string            `json:"dockerfile"`
+		Context    string            `json:"context"`
+		Args       map[string]string `json:"args"`
+	} `json:"build"`
+
+	// DockerComposeFile is a path, or a list of paths
+	DockerComposeFile any `json:"dockerComposeFile"`
+}
+
+// FindDevContainer returns the path to the devcontainer.json in a directory,
+// i.e .devcontainer/devcontainer.json or .devcontainer.json
+func FindDevContainer(dir string) (string, error) {
+	for _, path := range []string{
+		filepath.Join(dir, ".devcontainer", "devcontainer.json"),
+		filepath.Join(dir, ".devcontainer.json"),
+		filepath.Join(dir, "devcontainer.json"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no devcontainer.json found in %s", dir)
+}
+
+// DevContainerImages returns the images that a devcontainer.json refers to,
+// in its image, its Dockerfile or the services in its Docker Compose files.
+// Paths in the config are relative to the directory of the devcontainer.json.
+func DevContainerImages(path string) ([]string, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+
+	var config devContainer
+	if err := json.Unmarshal(stripJSONC(input), &config); err != nil {
+		return nil, fmt.Errorf("decoding devcontainer.json: %w", err)
+	}
+
+	var images []string
+	if config.Image != "" {
+		images = append(images, config.Image)
+	}
+
+	if config.Build != nil && config.Build.Dockerfile != "" {
+		images = append(images, dockerfileImages(filepath.Join(dir, config.Build.Dockerfile), config.Build.Args)...)
+	}
+
+	var composeFiles []string
+	switch v := config.DockerComposeFile.(type) {
+	case string:
+		composeFiles = append(composeFiles, v)
+	case []any:
+		for _, f := range v {
+			if s, ok := f.(string); ok {
+				composeFiles = append(composeFiles, s)
+			}
+		}
+	}
+	for _, f := range composeFiles {
+		imgs, err := ComposeImages(filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, imgs...)
+	}
+
+	return images, nil
+}
+
+// trailingCommaPattern matches the trailing commas that JSONC allows before
+// the end of an object or array
+var trailingCommaPattern = regexp.MustCompile(`,(\s*[}\]])`)
+
+// stripJSONC converts JSON with comments, which devcontainer.json allows, to
+// plain JSON by removing the comments and trailing commas
+func stripJSONC(input []byte) []byte {
+	var (
+		out      strings.Builder
+		inString bool
+	)
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case inString:
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(input) {
+				i++
+				out.WriteByte(input[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(input) && input[i+1] == '/':
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+			out.WriteByte('\n')
+		case c == '/' && i+1 < len(input) && input[i+1] == '*':
+			i += 2
+			for i+1 < len(input) && (input[i] != '*' || input[i+1] != '/') {
+				i++
+			}
+			i++
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	return trailingCommaPattern.ReplaceAll([]byte(out.String()), []byte("$1"))
+}
@@ -0,0 +1,124 @@
+package devconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// EarthfileImages returns the images that an Earthfile builds from, in the
+// order they appear:
+//
+//   - The image in FROM commands. FROM +target and FROM DOCKERFILE build
+//     from other targets, rather than images, so they're skipped.
+//   - The images pulled into WITH DOCKER --pull, which are loaded into the
+//     Docker daemon that the commands run against.
+//
+// Earthly copies artifacts from targets, rather than images, so COPY doesn't
+// refer to images. ARGs are resolved with their default values. Images with
+// args that can't be resolved are skipped.
+func EarthfileImages(path string) ([]string, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %s: %w", path, err)
+	}
+
+	var (
+		images []string
+		args   = map[string]string{}
+	)
+	for _, line := range earthfileLines(string(input)) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "ARG", "LET":
+			for _, field := range fields[1:] {
+				if strings.HasPrefix(field, "--") {
+					continue
+				}
+				if k, v, ok := strings.Cut(field, "="); ok {
+					args[k] = strings.Trim(v, `"'`)
+				}
+				break
+			}
+
+		case "FROM":
+			images = appendEarthlyImage(images, args, fields[1])
+
+		case "WITH":
+			if fields[1] != "DOCKER" {
+				continue
+			}
+			for i := 2; i < len(fields); i++ {
+				img, ok := strings.CutPrefix(fields[i], "--pull=")
+				if !ok && fields[i] == "--pull" && i+1 < len(fields) {
+					i++
+					img, ok = fields[i], true
+				}
+				if ok {
+					images = appendEarthlyImage(images, args, img)
+				}
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// appendEarthlyImage appends the image, with its args resolved, unless it's a
+// reference to a target or it can't be resolved
+func appendEarthlyImage(images []string, args map[string]string, img string) []string {
+	if img == "DOCKERFILE" || strings.Contains(img, "+") {
+		return images
+	}
+
+	img = os.Expand(img, func(key string) string {
+		if v, ok := args[key]; ok {
+			return v
+		}
+		return "$" + key
+	})
+	if strings.Contains(img, "$") {
+		return images
+	}
+	if _, err := name.ParseReference(img); err != nil {
+		return images
+	}
+
+	return append(images, img)
+}
+
+// earthfileLines returns the commands in an Earthfile, with comments removed
+// and lines that are continued with a backslash joined together
+func earthfileLines(input string) []string {
+	var (
+		lines   []string
+		current strings.Builder
+	)
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if continued, ok := strings.CutSuffix(line, "\\"); ok {
+			current.WriteString(continued)
+			current.WriteString(" ")
+			continue
+		}
+
+		current.WriteString(line)
+		lines = append(lines, current.String())
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+
+	return lines
+}
@@ -0,0 +1,200 @@
+package devconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/dockerfile"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+// skaffoldConfig is the subset of a skaffold.yaml that refers to images
+type skaffoldConfig struct {
+	skaffoldPipeline `yaml:",inline"`
+	Profiles         []skaffoldPipeline `yaml:"profiles"`
+}
+
+type skaffoldPipeline struct {
+	Build struct {
+		Artifacts []skaffoldArtifact `yaml:"artifacts"`
+	} `yaml:"build"`
+	Manifests struct {
+		RawYAML []string `yaml:"rawYaml"`
+	} `yaml:"manifests"`
+	Deploy struct {
+		Kubectl *struct {
+			Manifests []string `yaml:"manifests"`
+		} `yaml:"kubectl"`
+	} `yaml:"deploy"`
+}
+
+type skaffoldArtifact struct {
+	Image   string `yaml:"image"`
+	Context string `yaml:"context"`
+	Docker  *struct {
+		Dockerfile string             `yaml:"dockerfile"`
+		BuildArgs  map[string]*string `yaml:"buildArgs"`
+	} `yaml:"docker"`
+	Buildpacks *struct {
+		Builder  string `yaml:"builder"`
+		RunImage string `yaml:"runImage"`
+	} `yaml:"buildpacks"`
+	Ko *struct {
+		FromImage string `yaml:"fromImage"`
+	} `yaml:"ko"`
+
+	// The other builders don't refer to images in skaffold.yaml, but
+	// they mean the artifact isn't built from a Dockerfile
+	Jib    any `yaml:"jib"`
+	Bazel  any `yaml:"bazel"`
+	Custom any `yaml:"custom"`
+}
+
+// SkaffoldImages returns the images that a skaffold.yaml refers to, in the
+// Dockerfiles and builders of its artifacts and in the Kubernetes manifests
+// it deploys, including those in its profiles. Paths in the config are
+// relative to the directory of the skaffold.yaml.
+//
+// The images that skaffold builds are replaced in the manifests when they're
+// deployed, so they aren't included.
+func SkaffoldImages(path string) ([]string, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+
+	// A skaffold.yaml can contain several configs
+	var pipelines []skaffoldPipeline
+	dec := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var config skaffoldConfig
+		if err := dec.Decode(&config); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding skaffold config: %w", err)
+		}
+		pipelines = append(pipelines, config.skaffoldPipeline)
+		pipelines = append(pipelines, config.Profiles...)
+	}
+
+	var (
+		images    []string
+		artifacts []string
+		manifests []string
+	)
+	for _, p := range pipelines {
+		for _, artifact := range p.Build.Artifacts {
+			artifacts = append(artifacts, repository(artifact.Image))
+			images = append(images, artifactImages(dir, artifact)...)
+		}
+
+		manifests = append(manifests, p.Manifests.RawYAML...)
+		if p.Deploy.Kubectl != nil {
+			manifests = append(manifests, p.Deploy.Kubectl.Manifests...)
+		}
+	}
+
+	for _, pattern := range manifests {
+		paths, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			slog.Warn("skipping manifests", "pattern", pattern, "err", err)
+			continue
+		}
+		for _, path := range paths {
+			input, err := os.ReadFile(path)
+			if err != nil {
+				slog.Warn("skipping manifests", "path", path, "err", err)
+				continue
+			}
+			imgs, err := manifest.Images(input)
+			if err != nil {
+				slog.Warn("skipping manifests", "path", path, "err", err)
+				continue
+			}
+			for _, img := range imgs {
+				if slices.Contains(artifacts, repository(img.Image)) {
+					continue
+				}
+				images = append(images, img.Image)
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// artifactImages returns the images that an artifact is built from
+func artifactImages(dir string, artifact skaffoldArtifact) []string {
+	switch {
+	case artifact.Buildpacks != nil:
+		var images []string
+		for _, img := range []string{artifact.Buildpacks.Builder, artifact.Buildpacks.RunImage} {
+			if img != "" {
+				images = append(images, img)
+			}
+		}
+		return images
+	case artifact.Ko != nil:
+		if artifact.Ko.FromImage == "" {
+			return nil
+		}
+		return []string{artifact.Ko.FromImage}
+	case artifact.Jib != nil, artifact.Bazel != nil, artifact.Custom != nil:
+		return nil
+	}
+
+	// Artifacts are built from a Dockerfile by default
+	path := "Dockerfile"
+	buildArgs := map[string]string{}
+	if artifact.Docker != nil {
+		if artifact.Docker.Dockerfile != "" {
+			path = artifact.Docker.Dockerfile
+		}
+		for k, v := range artifact.Docker.BuildArgs {
+			if v != nil {
+				buildArgs[k] = *v
+			}
+		}
+	}
+
+	return dockerfileImages(filepath.Join(dir, artifact.Context, path), buildArgs)
+}
+
+// dockerfileImages returns the images in a Dockerfile. Dockerfiles that can't
+// be read are skipped with a warning.
+func dockerfileImages(path string, buildArgs map[string]string) []string {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		slog.Warn("skipping dockerfile", "err", err)
+		return nil
+	}
+
+	images, err := dockerfile.Images(input, buildArgs)
+	if err != nil {
+		slog.Warn("skipping dockerfile", "path", path, "err", err)
+		return nil
+	}
+
+	return images
+}
+
+// repository returns the repository of an image reference, without its tag or
+// digest, so that references to the same repository can be compared
+func repository(img string) string {
+	ref, err := name.ParseReference(img)
+	if err != nil {
+		return img
+	}
+
+	return ref.Context().String()
+}
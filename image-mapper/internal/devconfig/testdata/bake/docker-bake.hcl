@@ -0,0 +1,30 @@
+variable "REGISTRY" {
+  default = "registry.example.com"
+}
+
+variable "BASE_IMAGE" {
+  default = "python:3.13"
+}
+
+group "default" {
+  targets = ["app", "worker"]
+}
+
+target "app" {
+  contexts = {
+    alpine = "docker-image://alpine:3.20"
+  }
+  args = {
+    BUILDER_IMAGE = "golang:1.23"
+    VERSION       = "1.0.0"
+  }
+  tags = ["${REGISTRY}/app:latest"]
+}
+
+target "worker" {
+  // The base image is set by the variable
+  args = { BASE_IMAGE = "${BASE_IMAGE}", RUNTIME_IMAGE = "node:22" }
+  contexts = {
+    src = "./worker"
+  }
+}
@@ -0,0 +1,50 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Unified returns a unified diff between a and b, labelled with the provided
+// file names. It returns an empty string if they're the same.
+func Unified(fromFile, toFile string, a, b []byte) (string, error) {
+	if bytes.Equal(a, b) {
+		return "", nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a),
+		B:        splitLines(b),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("diffing %s: %w", toFile, err)
+	}
+
+	return diff, nil
+}
+
+// File returns a unified diff of the changes to the file at path, in the
+// same form as git diff, so that it can be applied with 'patch -p1' or
+// 'git apply'
+func File(path string, before, after []byte) (string, error) {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+
+	return Unified("a/"+path, "b/"+path, before, after)
+}
+
+// splitLines splits the input into lines, keeping the line endings
+func splitLines(input []byte) []string {
+	lines := strings.SplitAfter(string(input), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
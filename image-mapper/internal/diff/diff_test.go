@@ -0,0 +1,37 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFile(t *testing.T) {
+	before := []byte("FROM python:3.13\n\nWORKDIR /app\n")
+	after := []byte("FROM cgr.dev/chainguard/python:3.13-dev\n\nWORKDIR /app\n")
+
+	got, err := File("./app/Dockerfile", before, after)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `--- a/app/Dockerfile
++++ b/app/Dockerfile
+@@ -1,3 +1,3 @@
+-FROM python:3.13
++FROM cgr.dev/chainguard/python:3.13-dev
+ 
+ WORKDIR /app
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff (-want +got):\n%s", diff)
+	}
+
+	got, err = File("Dockerfile", before, before)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "" {
+		t.Errorf("expected no diff for an unchanged file, got:\n%s", got)
+	}
+}
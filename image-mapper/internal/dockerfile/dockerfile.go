@@ -4,28 +4,52 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
+// Options configures how a Dockerfile is mapped
+type Options struct {
+	// BuildArgs override the values of the ARG instructions in the
+	// Dockerfile, like --build-arg does for docker build
+	BuildArgs map[string]string
+
+	// Annotate adds comments above `FROM` instructions that couldn't be
+	// mapped, or where there are warnings about the mapping, so that
+	// whoever reviews the result knows what needs looking at
+	Annotate bool
+
+	// Workers is the number of files that MapFiles maps at once. It
+	// defaults to the number of CPUs.
+	Workers int
+}
+
 // Map images in a Dockerfile to their Chainguard equivalents
-func Map(ctx context.Context, input []byte, opts ...mapper.Option) ([]byte, error) {
+func Map(ctx context.Context, input []byte, dopts Options, opts ...mapper.Option) ([]byte, error) {
 	m, err := NewMapper(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("constructing mapper: %w", err)
 	}
 
-	return mapDockerfile(m, input)
+	return mapDockerfile(m, input, dopts)
 }
 
-func mapDockerfile(m mapper.Mapper, input []byte) ([]byte, error) {
-	res, err := parser.Parse(bytes.NewReader(input))
+// MapWith maps the images in a Dockerfile with the provided mapper, so that
+// one mapper can be shared by many files
+func MapWith(m mapper.Mapper, input []byte, dopts Options) ([]byte, error) {
+	return mapDockerfile(m, input, dopts)
+}
+
+func mapDockerfile(m mapper.Mapper, input []byte, opts Options) ([]byte, error) {
+	res, err := parse(input)
 	if err != nil {
-		return nil, fmt.Errorf("parse dockerfile: %w", err)
+		return nil, err
 	}
 
 	// Keep track of the name of the stages in the Dockerfile so we don't
@@ -36,20 +60,43 @@ func mapDockerfile(m mapper.Mapper, input []byte) ([]byte, error) {
 	// Keep track of args so we can resolve them in `FROM` instructions.
 	args := map[string]string{}
 
+	// Keep track of the ARG instruction that declares each arg, so we can
+	// update its value when a `FROM` instruction refers to it.
+	argDecls := map[string]int{}
+
+	// Keep track of the `FROM` instructions that refer to args. We can only
+	// decide whether to update the args once we've seen all of them.
+	var argFroms []argFrom
+
 	// Track when we hit the first `FROM` instruction, because any ARGs after that
 	// point aren't usable in `FROM` instructions.
 	beforeFrom := true
 
-	// We'll compose the output by replacing lines in the input
-	output := string(input)
-
-	// Track the number of lines we've removed so we can adjust the line
-	// numbers we modify accordingly
-	offset := 0
+	// We'll compose the output by rewriting the source of each instruction
+	// in place, so that everything apart from the image references we
+	// change (comments, line continuations, heredocs, casing) is
+	// preserved.
+	//
+	// We keep the source of each instruction, rather than writing as we
+	// go, so that we can go back and update ARG instructions after we've
+	// seen the `FROM` instructions that use them.
+	//
+	// The bodies of heredocs are kept apart from the instruction, so that
+	// the images in scripts and files, like `echo "FROM python"`, aren't
+	// mistaken for the image of the instruction.
+	lines := strings.Split(string(input), "\n")
+	sources := make([]string, len(res.AST.Children))
+	heredocs := make([][]string, len(res.AST.Children))
+	for i, child := range res.AST.Children {
+		end := max(child.EndLine-heredocLines(child), child.StartLine)
+		sources[i] = strings.Join(lines[child.StartLine-1:end], "\n")
+		heredocs[i] = lines[end:child.EndLine]
+	}
 
-	for _, child := range res.AST.Children {
-		var replacement string
+	// Comments to add above each instruction
+	annotations := make([][]string, len(res.AST.Children))
 
+	for i, child := range res.AST.Children {
 		switch strings.ToLower(child.Value) {
 
 		// ARG EXAMPLE=<image>
@@ -63,9 +110,18 @@ func mapDockerfile(m mapper.Mapper, input []byte) ([]byte, error) {
 
 			// Save the args, if there's a value
 			for n := child.Next; n != nil; n = n.Next {
-				parts := strings.Split(n.Value, "=")
-				if len(parts) == 2 {
-					args[parts[0]] = strings.Trim(parts[1], "\"")
+				key, value, ok := strings.Cut(n.Value, "=")
+
+				// Build args override the value in the file, so
+				// there's no point updating it
+				if override, overridden := opts.BuildArgs[key]; overridden {
+					args[key] = override
+					continue
+				}
+
+				argDecls[key] = i
+				if ok {
+					args[key] = strings.Trim(value, "\"'")
 				}
 			}
 
@@ -90,15 +146,52 @@ func mapDockerfile(m mapper.Mapper, input []byte) ([]byte, error) {
 
 			// Resolve args in the FROM line
 			from := resolveArgs(args, child.Next.Value)
+			usesArgs := argPattern.MatchString(child.Next.Value)
+
+			// Stages and scratch aren't images, so there's
+			// nothing to map or annotate
+			if _, ok := stages[from]; ok || from == "scratch" {
+				continue
+			}
 
 			// Map the image to Chainguard
-			img, err := mapper.MapImage(m, from)
+			img, warnings, err := mapper.MapImageWithWarnings(m, from)
 			if err != nil {
-				log.Printf("WARN: error mapping image: %s: %s", from, err)
+				slog.Warn("error mapping image", "image", from, "err", err)
+
+				if opts.Annotate {
+					annotations[i] = append(annotations[i], fmt.Sprintf("no Chainguard equivalent found for %s", from))
+				}
+
+				// Make sure we don't update the args used by
+				// this instruction
+				if usesArgs {
+					argFroms = append(argFroms, argFrom{index: i, node: child})
+				}
+				continue
+			}
+			for _, warning := range warnings {
+				slog.Warn(warning, "image", from)
+
+				if opts.Annotate {
+					annotations[i] = append(annotations[i], fmt.Sprintf("check the mapping for %s: %s", from, warning))
+				}
+			}
+
+			// If the image refers to args then we'll try to
+			// update the args, rather than the FROM instruction
+			if usesArgs {
+				values, _ := matchArgs(child.Next.Value, img.String())
+				argFroms = append(argFroms, argFrom{
+					index:  i,
+					node:   child,
+					image:  img.String(),
+					values: values,
+				})
 				continue
 			}
 
-			replacement = strings.ReplaceAll(child.Original, child.Next.Value, img.String())
+			sources[i] = replaceToken(sources[i], child.Next.Value, img.String())
 
 		// COPY --from=<image>
 		case "copy":
@@ -116,19 +209,17 @@ func mapDockerfile(m mapper.Mapper, input []byte) ([]byte, error) {
 
 				img, err := mapper.MapImage(m, from)
 				if err != nil {
-					log.Printf("WARN: error mapping image: %s: %s", from, err)
+					slog.Warn("error mapping image", "image", from, "err", err)
 					continue
 				}
 
-				replacement = strings.ReplaceAll(child.Original, flag, fmt.Sprintf("--from=%s", img))
+				sources[i] = replaceToken(sources[i], flag, fmt.Sprintf("--from=%s", img))
 
 				break
 			}
 
 		// RUN --mount=type=bind,target=/usr/bin,from=python
 		case "run":
-			original := child.Original
-
 			for _, flag := range child.Flags {
 				if !strings.HasPrefix(flag, "--mount=") {
 					continue
@@ -149,7 +240,7 @@ func mapDockerfile(m mapper.Mapper, input []byte) ([]byte, error) {
 
 				img, err := mapper.MapImage(m, from)
 				if err != nil {
-					log.Printf("WARN: error mapping image: %s: %s", from, err)
+					slog.Warn("error mapping image", "image", from, "err", err)
 					continue
 				}
 				// Replace the from= option in the flag
@@ -157,55 +248,107 @@ func mapDockerfile(m mapper.Mapper, input []byte) ([]byte, error) {
 				modifiedFlag := strings.ReplaceAll(flag, fmt.Sprintf("from=%s", from), fmt.Sprintf("from=%s", img))
 
 				// Replace the flag with the modified flag in
-				// the original line
-				original = strings.ReplaceAll(original, flag, modifiedFlag)
-
+				// the source
+				sources[i] = replaceToken(sources[i], flag, modifiedFlag)
 			}
+		}
+	}
 
-			if original != child.Original {
-				replacement = original
-			}
+	updateArgs(sources, argDecls, argFroms)
+
+	// Write the sources back over the lines they came from, along with
+	// any annotations. We work backwards so that adding lines doesn't
+	// change the line numbers of the instructions we've still to write.
+	for i := len(res.AST.Children) - 1; i >= 0; i-- {
+		child := res.AST.Children[i]
+		replacement := slices.Concat(strings.Split(sources[i], "\n"), heredocs[i])
+		if comments := annotate(lines[:child.StartLine-1], sources[i], annotations[i]); len(comments) > 0 {
+			replacement = append(comments, replacement...)
 		}
 
-		if replacement == "" {
-			continue
+		lines = slices.Replace(lines, child.StartLine-1, child.EndLine, replacement...)
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// parse parses a Dockerfile with the parser of the dockerfile frontend, which
+// understands the syntax of the latest releases, like heredocs. Files that
+// select another frontend with a `# syntax` directive are written in whatever
+// language that frontend understands, so they're rejected rather than parsed
+// as Dockerfiles.
+func parse(input []byte) (*parser.Result, error) {
+	if syntax, _, _, ok := parser.DetectSyntax(input); ok && !isDockerfileFrontend(syntax) {
+		return nil, fmt.Errorf("unsupported frontend: %s", syntax)
+	}
+
+	res, err := parser.Parse(bytes.NewReader(input))
+	if err != nil {
+		return nil, fmt.Errorf("parse dockerfile: %w", err)
+	}
+
+	return res, nil
+}
+
+// dockerfileFrontends are the repositories of the frontends that build
+// Dockerfiles
+var dockerfileFrontends = []string{"docker/dockerfile", "docker/dockerfile-upstream"}
+
+// isDockerfileFrontend returns true if the image in a `# syntax` directive is
+// a dockerfile frontend, like docker/dockerfile:1.7, or a mirror of one
+func isDockerfileFrontend(syntax string) bool {
+	ref, err := name.ParseReference(syntax)
+	if err != nil {
+		return false
+	}
+
+	repo := ref.Context().RepositoryStr()
+	for _, frontend := range dockerfileFrontends {
+		if repo == frontend || strings.HasSuffix(repo, "/"+frontend) {
+			return true
 		}
+	}
 
-		// If the original instruction was spread over multiple lines
-		// then we will flatten it onto one line and remove the other
-		// lines to keep things tidy.
-		//
-		// One consequence of this is that we need to adjust the line
-		// numbers we write to after that point to account for the
-		// offset.
-		output = replaceLines(output, child.StartLine-offset, child.EndLine-offset, replacement)
-		offset = offset + (child.EndLine - child.StartLine)
+	return false
+}
+
+// heredocLines returns the number of lines at the end of an instruction that
+// hold the bodies of its heredocs, including the lines that terminate them
+func heredocLines(node *parser.Node) int {
+	n := 0
+	for _, heredoc := range node.Heredocs {
+		n += strings.Count(heredoc.Content, "\n") + 1
 	}
 
-	return []byte(output), nil
+	return n
 }
 
 // fromPattern extracts images in `from=` options in `RUN --mount` instructions
 var fromPattern = regexp.MustCompile(`\bfrom=([^,]+)`)
 
-// argPattern identifies arguments like `${ARG_NAME}`
-var argPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+// argPattern identifies arguments like `${ARG_NAME}` or `$ARG_NAME`
+var argPattern = regexp.MustCompile(`\$(?:\{([^}]+)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// parseArg returns the name and default value of an argument like
+// `${ARG_NAME:-default}`
+func parseArg(match string) (string, string) {
+	content := strings.TrimPrefix(match, "$")
+
+	// Extract the inside of ${...}
+	if strings.HasPrefix(content, "{") {
+		content = content[1 : len(content)-1]
+	}
+
+	// Check for default syntax: VAR:-default
+	argName, argDefault, _ := strings.Cut(content, ":-")
+
+	return argName, argDefault
+}
 
 // resolveArgs resolves args in a Dockerfile line
 func resolveArgs(args map[string]string, line string) string {
 	return argPattern.ReplaceAllStringFunc(line, func(match string) string {
-		// Extract the inside of ${...}
-		content := match[2 : len(match)-1]
-
-		// Check for default syntax: VAR:-default
-		argName := content
-		argDefault := ""
-
-		parts := strings.Split(content, ":-")
-		if len(parts) > 1 {
-			argName = parts[0]
-			argDefault = parts[1]
-		}
+		argName, argDefault := parseArg(match)
 
 		// If the variable exists in map, use it
 		if val, ok := args[argName]; ok {
@@ -222,17 +365,171 @@ func resolveArgs(args map[string]string, line string) string {
 	})
 }
 
-// replaceLines replaces the indicated lines in the output with the replacement
-// value
-func replaceLines(output string, start, end int, replacement string) string {
-	lines := strings.Split(output, "\n")
-	if start < 0 || end >= len(lines) || start > end {
-		return output
+// argFrom is a `FROM` instruction that refers to args
+type argFrom struct {
+	index int
+	node  *parser.Node
+
+	// image is the mapped image, or empty if the image couldn't be
+	// mapped
+	image string
+
+	// values are the values the args need for the instruction to resolve
+	// to the mapped image, or nil if there aren't any
+	values map[string]string
+}
+
+// updateArgs updates the ARG instructions that declare the args used in `FROM`
+// instructions, so that the `FROM` instructions resolve to the mapped images.
+//
+// An arg is only updated if it's declared in the file and every `FROM`
+// instruction that refers to it agrees on the new value. Otherwise, the `FROM`
+// instruction is replaced with the mapped image instead.
+func updateArgs(sources []string, argDecls map[string]int, argFroms []argFrom) {
+	updates := map[string]string{}
+	blocked := map[string]struct{}{}
+	for _, f := range argFroms {
+		for _, match := range argPattern.FindAllString(f.node.Next.Value, -1) {
+			argName, _ := parseArg(match)
+
+			_, declared := argDecls[argName]
+			value, ok := f.values[argName]
+			if prev, seen := updates[argName]; !declared || !ok || (seen && prev != value) {
+				blocked[argName] = struct{}{}
+				continue
+			}
+
+			updates[argName] = value
+		}
+	}
+
+	for _, f := range argFroms {
+		if f.image == "" {
+			continue
+		}
+		for _, match := range argPattern.FindAllString(f.node.Next.Value, -1) {
+			argName, _ := parseArg(match)
+			if _, ok := blocked[argName]; !ok {
+				continue
+			}
+
+			sources[f.index] = replaceToken(sources[f.index], f.node.Next.Value, f.image)
+			break
+		}
 	}
 
-	lines[start-1] = replacement
+	for argName, value := range updates {
+		if _, ok := blocked[argName]; ok {
+			continue
+		}
+
+		i := argDecls[argName]
+		sources[i] = replaceArg(sources[i], argName, value)
+	}
+}
 
-	lines = append(lines[:start], lines[end:]...)
+// matchArgs works out the values that the args in the template must have for
+// it to resolve to the value. It returns false if there aren't any.
+func matchArgs(template, value string) (map[string]string, bool) {
+	var (
+		pattern  strings.Builder
+		argNames []string
+		last     int
+	)
+	pattern.WriteString("^")
+	for _, loc := range argPattern.FindAllStringIndex(template, -1) {
+		argName, _ := parseArg(template[loc[0]:loc[1]])
+		argNames = append(argNames, argName)
+
+		pattern.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		pattern.WriteString("(.*?)")
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	pattern.WriteString("$")
+
+	match := regexp.MustCompile(pattern.String()).FindStringSubmatch(value)
+	if match == nil {
+		return nil, false
+	}
+
+	values := map[string]string{}
+	for i, argName := range argNames {
+		// The same arg may appear more than once, in which case
+		// it must have the same value each time
+		if prev, ok := values[argName]; ok && prev != match[i+1] {
+			return nil, false
+		}
+		values[argName] = match[i+1]
+	}
+
+	return values, true
+}
+
+// replaceArg sets the value of an arg in an ARG instruction, keeping the
+// quotes around the original value, if there are any
+func replaceArg(line, argName, value string) string {
+	pattern := regexp.MustCompile(`\s(` + regexp.QuoteMeta(argName) + `)(=("[^"]*"|'[^']*'|\S*))?(\s|$)`)
+	loc := pattern.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return line
+	}
+
+	// The end of the name, or of the existing value, if there is one
+	end := loc[3]
+	if loc[4] >= 0 {
+		end = loc[5]
+	}
+
+	quote := ""
+	if loc[6] >= 0 && loc[6] < loc[7] && (line[loc[6]] == '"' || line[loc[6]] == '\'') {
+		quote = string(line[loc[6]])
+	}
+
+	return line[:loc[3]] + "=" + quote + value + quote + line[end:]
+}
+
+// annotationPrefix identifies the comments added by the mapper
+const annotationPrefix = "# image-mapper: "
+
+// annotate returns the comment lines to add above an instruction. It skips
+// annotations that are already in the comments directly above the instruction,
+// so mapping the same file again doesn't duplicate them.
+func annotate(before []string, source string, annotations []string) []string {
+	existing := map[string]struct{}{}
+	for j := len(before) - 1; j >= 0; j-- {
+		line := strings.TrimSpace(before[j])
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		existing[line] = struct{}{}
+	}
+
+	// Indent the comments to match the instruction
+	indent := source[:len(source)-len(strings.TrimLeft(source, " \t"))]
+
+	var comments []string
+	for _, annotation := range annotations {
+		comment := annotationPrefix + annotation
+		if _, ok := existing[comment]; ok {
+			continue
+		}
+		comments = append(comments, indent+comment)
+	}
+
+	return comments
+}
+
+// replaceToken replaces the first whitespace delimited token in the source that
+// matches old with new. It logs a warning and leaves the source untouched if it
+// can't find the token.
+func replaceToken(source, old, new string) string {
+	pattern := regexp.MustCompile(`(?:^|\s)(` + regexp.QuoteMeta(old) + `)(?:\s|$)`)
+	loc := pattern.FindStringSubmatchIndex(source)
+	if loc == nil {
+		slog.Warn("couldn't find the image in the instruction", "image", old, "instruction", source)
+		return source
+	}
 
-	return strings.Join(lines, "\n")
+	return source[:loc[2]] + new + source[loc[3]:]
 }
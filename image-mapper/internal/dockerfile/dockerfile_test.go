@@ -11,12 +11,14 @@ import (
 
 type mockMapper struct {
 	mappings map[string][]string
+	warnings map[string][]string
 }
 
 func (m *mockMapper) Map(img string) (*mapper.Mapping, error) {
 	return &mapper.Mapping{
-		Image:   img,
-		Results: m.mappings[img],
+		Image:    img,
+		Results:  m.mappings[img],
+		Warnings: m.warnings[img],
 	}, nil
 }
 
@@ -32,6 +34,12 @@ func TestMapDockerfile(t *testing.T) {
 			"python:3.13": {
 				"cgr.dev/chainguard/python:3.13-dev",
 			},
+			"python:3.13@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a": {
+				"cgr.dev/chainguard/python:3.13-dev",
+			},
+			"docker.io/nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25-dev",
+			},
 		},
 	}
 
@@ -39,8 +47,12 @@ func TestMapDockerfile(t *testing.T) {
 		"singlestage": {},
 		"multistage":  {},
 		"args":        {},
+		"argsupdate":  {},
 		"copyfrom":    {},
 		"runmount":    {},
+		"formatting":  {},
+		"platform":    {},
+		"heredocs":    {},
 	}
 
 	for name := range testCases {
@@ -55,7 +67,7 @@ func TestMapDockerfile(t *testing.T) {
 				t.Fatalf("unexpected error reading before file: %s", err)
 			}
 
-			result, err := mapDockerfile(m, before)
+			result, err := mapDockerfile(m, before, Options{})
 			if err != nil {
 				t.Fatalf("unexpected error mapping dockerfile: %s", err)
 			}
@@ -66,3 +78,236 @@ func TestMapDockerfile(t *testing.T) {
 		})
 	}
 }
+
+func TestMapDockerfileSyntax(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"python:3.13": {
+				"cgr.dev/chainguard/python:3.13-dev",
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		"dockerfile frontend": {
+			input: "# syntax=docker/dockerfile:1.7\nFROM python:3.13\n",
+			want:  "# syntax=docker/dockerfile:1.7\nFROM cgr.dev/chainguard/python:3.13-dev\n",
+		},
+		"upstream frontend": {
+			input: "# syntax=docker.io/docker/dockerfile-upstream:master-labs\nFROM python:3.13\n",
+			want:  "# syntax=docker.io/docker/dockerfile-upstream:master-labs\nFROM cgr.dev/chainguard/python:3.13-dev\n",
+		},
+		"mirrored frontend": {
+			input: "# syntax=mirror.example.com/docker/dockerfile:1\nFROM python:3.13\n",
+			want:  "# syntax=mirror.example.com/docker/dockerfile:1\nFROM cgr.dev/chainguard/python:3.13-dev\n",
+		},
+		"custom frontend": {
+			input:   "# syntax=example.com/custom/frontend:1\nFROM python:3.13\n",
+			wantErr: true,
+		},
+		"unterminated heredoc": {
+			input:   "FROM python:3.13\nRUN <<EOF\necho hello\n",
+			wantErr: true,
+		},
+		"heredoc without trailing newline": {
+			input: "FROM python:3.13\nRUN <<EOF\necho python:3.13\nEOF",
+			want:  "FROM cgr.dev/chainguard/python:3.13-dev\nRUN <<EOF\necho python:3.13\nEOF",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := mapDockerfile(m, []byte(tc.input), Options{})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error mapping dockerfile: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, string(result)); diff != "" {
+				t.Errorf("unexpected result:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMapDockerfileBuildArgs(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"python:3.13": {
+				"cgr.dev/chainguard/python:3.13-dev",
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		input     string
+		buildArgs map[string]string
+		want      string
+	}{
+		"override default": {
+			input:     "ARG IMAGE=python:3.12\nFROM ${IMAGE}\n",
+			buildArgs: map[string]string{"IMAGE": "python:3.13"},
+			want:      "ARG IMAGE=python:3.12\nFROM cgr.dev/chainguard/python:3.13-dev\n",
+		},
+		"no default": {
+			input:     "ARG TAG\nFROM python:$TAG\n",
+			buildArgs: map[string]string{"TAG": "3.13"},
+			want:      "ARG TAG\nFROM cgr.dev/chainguard/python:3.13-dev\n",
+		},
+		"undeclared": {
+			input:     "ARG IMAGE=python:3.13\nFROM ${IMAGE}\n",
+			buildArgs: map[string]string{"OTHER": "python:3.12"},
+			want:      "ARG IMAGE=cgr.dev/chainguard/python:3.13-dev\nFROM ${IMAGE}\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := mapDockerfile(m, []byte(tc.input), Options{BuildArgs: tc.buildArgs})
+			if err != nil {
+				t.Fatalf("unexpected error mapping dockerfile: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, string(result)); diff != "" {
+				t.Errorf("unexpected result:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMapDockerfileAnnotate(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"python:3.12": {
+				"cgr.dev/chainguard/python:3.13-dev",
+			},
+			"python:3.13": {
+				"cgr.dev/chainguard/python:3.13-dev",
+			},
+		},
+		warnings: map[string][]string{
+			"python:3.12": {
+				"cgr.dev/chainguard/python:3.13-dev: no tag equivalent to 3.12, using the nearest available version 3.13",
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		input string
+		want  string
+	}{
+		"no results": {
+			input: "FROM example/unknown:1.0\n",
+			want:  "# image-mapper: no Chainguard equivalent found for example/unknown:1.0\nFROM example/unknown:1.0\n",
+		},
+		"warnings": {
+			input: "FROM python:3.12 AS build\n",
+			want:  "# image-mapper: check the mapping for python:3.12: cgr.dev/chainguard/python:3.13-dev: no tag equivalent to 3.12, using the nearest available version 3.13\nFROM cgr.dev/chainguard/python:3.13-dev AS build\n",
+		},
+		"no annotations": {
+			input: "# Build stage\nFROM python:3.13 AS build\n",
+			want:  "# Build stage\nFROM cgr.dev/chainguard/python:3.13-dev AS build\n",
+		},
+		"already annotated": {
+			input: "# image-mapper: no Chainguard equivalent found for example/unknown:1.0\nFROM example/unknown:1.0\n",
+			want:  "# image-mapper: no Chainguard equivalent found for example/unknown:1.0\nFROM example/unknown:1.0\n",
+		},
+		"multiple stages": {
+			input: "FROM example/unknown:1.0 AS build\n\nFROM python:3.13\nCOPY --from=build /app /app\n",
+			want:  "# image-mapper: no Chainguard equivalent found for example/unknown:1.0\nFROM example/unknown:1.0 AS build\n\nFROM cgr.dev/chainguard/python:3.13-dev\nCOPY --from=build /app /app\n",
+		},
+		"stages and scratch": {
+			input: "FROM python:3.13 AS base\n\nFROM base AS build\nRUN make\n\nFROM scratch\nCOPY --from=build /app /app\n",
+			want:  "FROM cgr.dev/chainguard/python:3.13-dev AS base\n\nFROM base AS build\nRUN make\n\nFROM scratch\nCOPY --from=build /app /app\n",
+		},
+		"stage with args": {
+			input: "ARG BASE=base\nFROM python:3.13 AS base\n\nFROM ${BASE}\n",
+			want:  "ARG BASE=base\nFROM cgr.dev/chainguard/python:3.13-dev AS base\n\nFROM ${BASE}\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := mapDockerfile(m, []byte(tc.input), Options{Annotate: true})
+			if err != nil {
+				t.Fatalf("unexpected error mapping dockerfile: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, string(result)); diff != "" {
+				t.Errorf("unexpected result:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	testCases := map[string]struct {
+		file      string
+		buildArgs map[string]string
+		want      []string
+	}{
+		"copy from": {
+			file: "copyfrom",
+			want: []string{"python:3.13", "python:3.13", "python:3.13"},
+		},
+		"args": {
+			file: "args",
+			want: []string{"python", "python:3.13", "docker.io/python"},
+		},
+		"heredocs": {
+			file: "heredocs",
+			want: []string{"python:3.13", "docker.io/nginx:1.25", "python:3.13"},
+		},
+		"build args": {
+			file:      "args",
+			buildArgs: map[string]string{"IMAGE": "node", "UNDECLARED": "busybox"},
+			want:      []string{"node", "node:3.13", "docker.io/python"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(fmt.Sprintf("testdata/%s.before.Dockerfile", tc.file))
+			if err != nil {
+				t.Fatalf("unexpected error reading file: %s", err)
+			}
+
+			got, err := Images(input, tc.buildArgs)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected images (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	input, err := os.ReadFile("testdata/heredocs.before.Dockerfile")
+	if err != nil {
+		t.Fatalf("unexpected error reading file: %s", err)
+	}
+
+	got, err := References(input, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []Reference{
+		{Image: "python:3.13", Line: 3},
+		{Image: "docker.io/nginx:1.25", Line: 23},
+		{Image: "python:3.13", Line: 25},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected references (-want +got):\n%s", diff)
+	}
+}
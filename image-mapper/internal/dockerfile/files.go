@@ -0,0 +1,152 @@
+package dockerfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/diff"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"golang.org/x/sync/errgroup"
+)
+
+// File is a Dockerfile that has been mapped
+type File struct {
+	Path   string
+	Input  []byte
+	Output []byte
+}
+
+// Changed returns true if mapping the file changed it
+func (f File) Changed() bool {
+	return !bytes.Equal(f.Input, f.Output)
+}
+
+// Diff returns a unified diff between the input and output of the file. It
+// returns an empty string if the file hasn't changed.
+func (f File) Diff() (string, error) {
+	return diff.File(f.Path, f.Input, f.Output)
+}
+
+// MapFiles maps the images in the Dockerfiles at the provided paths. The files
+// are mapped concurrently, but they're returned in the order of the paths.
+// Files that can't be parsed are skipped with a warning.
+func MapFiles(ctx context.Context, paths []string, dopts Options, opts ...mapper.Option) ([]File, error) {
+	m, err := NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return mapFiles(m, paths, dopts)
+}
+
+func mapFiles(m mapper.Mapper, paths []string, opts Options) ([]File, error) {
+	var (
+		mapped = make([]*File, len(paths))
+		g      errgroup.Group
+	)
+	g.SetLimit(workers(opts.Workers))
+	for i, path := range paths {
+		g.Go(func() error {
+			input, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("reading file: %s: %w", path, err)
+			}
+
+			output, err := mapDockerfile(m, input, opts)
+			if err != nil {
+				slog.Warn("skipping file", "path", path, "err", err)
+				return nil
+			}
+
+			mapped[i] = &File{
+				Path:   path,
+				Input:  input,
+				Output: output,
+			}
+
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, f := range mapped {
+		if f != nil {
+			files = append(files, *f)
+		}
+	}
+
+	return files, nil
+}
+
+// workers returns the number of workers to use, which defaults to the number
+// of CPUs
+func workers(n int) int {
+	if n > 0 {
+		return n
+	}
+
+	return runtime.NumCPU()
+}
+
+// skipDirs are directories that won't contain Dockerfiles we're interested in
+var skipDirs = map[string]struct{}{
+	".git":         {},
+	"node_modules": {},
+}
+
+// FindDockerfiles returns the paths of the Dockerfiles and Containerfiles in
+// the directory and its subdirectories
+func FindDockerfiles(dir string) ([]string, error) {
+	var paths []string
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if _, ok := skipDirs[d.Name()]; ok && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if IsDockerfile(d.Name()) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking directory: %s: %w", dir, err)
+	}
+
+	return paths, nil
+}
+
+// IsDockerfile returns true if the file name looks like a Dockerfile, i.e
+// Dockerfile, Dockerfile.prod, Containerfile or app.Dockerfile
+func IsDockerfile(name string) bool {
+	name = strings.ToLower(name)
+
+	// Dockerfile specific ignore files, i.e Dockerfile.dockerignore
+	if strings.HasSuffix(name, ".dockerignore") {
+		return false
+	}
+
+	for _, s := range []string{"dockerfile", "containerfile"} {
+		if strings.HasPrefix(name, s) || strings.HasSuffix(name, "."+s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package dockerfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindDockerfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := []string{
+		"Dockerfile",
+		"Dockerfile.dockerignore",
+		"README.md",
+		".devcontainer/Dockerfile",
+		".git/Dockerfile",
+		"app/Containerfile",
+		"app/Dockerfile.prod",
+		"app/main.go",
+		"build/app.Dockerfile",
+		"node_modules/example/Dockerfile",
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("unexpected error creating directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte("FROM python\n"), 0o644); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+	}
+
+	got, err := FindDockerfiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		filepath.Join(dir, ".devcontainer/Dockerfile"),
+		filepath.Join(dir, "Dockerfile"),
+		filepath.Join(dir, "app/Containerfile"),
+		filepath.Join(dir, "app/Dockerfile.prod"),
+		filepath.Join(dir, "build/app.Dockerfile"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected paths (-want +got):\n%s", diff)
+	}
+}
+
+func TestMapFiles(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"python:3.13": {
+				"cgr.dev/chainguard/python:3.13-dev",
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"Dockerfile":       "FROM python:3.13\n\nCOPY run.py run.py\n",
+		"Dockerfile.other": "FROM example/unknown:1.0\n",
+		"Dockerfile.bad":   "RUN <<EOF\n",
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+	}
+
+	got, err := mapFiles(m, []string{
+		filepath.Join(dir, "Dockerfile"),
+		filepath.Join(dir, "Dockerfile.bad"),
+		filepath.Join(dir, "Dockerfile.other"),
+	}, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []File{
+		{
+			Path:   filepath.Join(dir, "Dockerfile"),
+			Input:  []byte("FROM python:3.13\n\nCOPY run.py run.py\n"),
+			Output: []byte("FROM cgr.dev/chainguard/python:3.13-dev\n\nCOPY run.py run.py\n"),
+		},
+		{
+			Path:   filepath.Join(dir, "Dockerfile.other"),
+			Input:  []byte("FROM example/unknown:1.0\n"),
+			Output: []byte("FROM example/unknown:1.0\n"),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+
+	if _, err := mapFiles(m, []string{filepath.Join(dir, "missing")}, Options{}); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestFileDiff(t *testing.T) {
+	f := File{
+		Path:   "app/Dockerfile",
+		Input:  []byte("FROM python:3.13\n\nCOPY run.py run.py\n"),
+		Output: []byte("FROM cgr.dev/chainguard/python:3.13-dev\n\nCOPY run.py run.py\n"),
+	}
+
+	got, err := f.Diff()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `--- a/app/Dockerfile
++++ b/app/Dockerfile
+@@ -1,3 +1,3 @@
+-FROM python:3.13
++FROM cgr.dev/chainguard/python:3.13-dev
+ 
+ COPY run.py run.py
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff (-want +got):\n%s", diff)
+	}
+
+	f.Output = f.Input
+	got, err = f.Diff()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "" {
+		t.Errorf("expected empty diff for unchanged file, got:\n%s", got)
+	}
+}
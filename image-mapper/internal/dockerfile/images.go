@@ -0,0 +1,103 @@
+package dockerfile
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Reference is an image that a Dockerfile refers to and the line of the
+// instruction that refers to it
+type Reference struct {
+	Image string
+	Line  int
+}
+
+// Images returns the images that a Dockerfile refers to in `FROM` and
+// `COPY --from` instructions, in the order they appear. Args are resolved
+// like they are when the Dockerfile is mapped, with the build args taking
+// precedence over the values in the file. References to stages, scratch and
+// args that can't be resolved aren't included.
+func Images(input []byte, buildArgs map[string]string) ([]string, error) {
+	refs, err := References(input, buildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	for _, ref := range refs {
+		images = append(images, ref.Image)
+	}
+
+	return images, nil
+}
+
+// References returns the same images as Images, with the lines they're
+// referred to on
+func References(input []byte, buildArgs map[string]string) ([]Reference, error) {
+	res, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		refs       []Reference
+		stages     = map[string]struct{}{}
+		args       = map[string]string{}
+		beforeFrom = true
+	)
+	for _, child := range res.AST.Children {
+		switch strings.ToLower(child.Value) {
+		case "arg":
+			if !beforeFrom {
+				continue
+			}
+			for n := child.Next; n != nil; n = n.Next {
+				key, value, ok := strings.Cut(n.Value, "=")
+				if override, overridden := buildArgs[key]; overridden {
+					args[key] = override
+					continue
+				}
+				if ok {
+					args[key] = strings.Trim(value, "\"'")
+				}
+			}
+
+		case "from":
+			beforeFrom = false
+			if child.Next == nil {
+				continue
+			}
+			for n := child.Next; n != nil; n = n.Next {
+				if strings.ToLower(n.Value) == "as" && n.Next != nil {
+					stages[n.Next.Value] = struct{}{}
+				}
+			}
+
+			// Skip args that can't be resolved
+			from := resolveArgs(args, child.Next.Value)
+			if _, ok := stages[from]; ok || from == "scratch" || strings.Contains(from, "$") {
+				continue
+			}
+			refs = append(refs, Reference{Image: from, Line: child.StartLine})
+
+		case "copy":
+			for _, flag := range child.Flags {
+				from, ok := strings.CutPrefix(flag, "--from=")
+				if !ok {
+					continue
+				}
+
+				// Stages can be referred to by name or index
+				if _, ok := stages[from]; ok {
+					continue
+				}
+				if _, err := strconv.Atoi(from); err == nil {
+					continue
+				}
+				refs = append(refs, Reference{Image: from, Line: child.StartLine})
+			}
+		}
+	}
+
+	return refs, nil
+}
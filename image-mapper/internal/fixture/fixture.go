@@ -0,0 +1,301 @@
+// Package fixture records the HTTP requests that image-mapper makes, like the
+// catalog queries and the registry calls, to a directory, and replays them
+// later, so that runs can be repeated without the network.
+package fixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// recording is a request and the response to it. Only the parts of the
+// request that identify it are kept, so the credentials in its headers aren't
+// written to the directory.
+type recording struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+
+	// Body is the body of the response when it's text, otherwise it's in
+	// BodyBase64
+	Body       string `json:"body,omitempty"`
+	BodyBase64 string `json:"bodyBase64,omitempty"`
+}
+
+// recordedHeaders are the headers of the responses that are recorded. The
+// others, like Date and Set-Cookie, change from one run to the next or
+// shouldn't be written to the directory.
+var recordedHeaders = []string{
+	"Content-Type",
+	"Docker-Content-Digest",
+	"Link",
+	"Www-Authenticate",
+}
+
+// Recorder is a transport that makes the requests with another transport and
+// writes each of them, with its response, to a file in a directory.
+//
+// A request that's made more than once is recorded once, with its last
+// response, except that an unauthorized response never replaces another
+// response. That way the request that's retried with a token, after the
+// registry asked for one, is replayed with the response to the retry.
+//
+// The requests for registry tokens aren't recorded, because they hold
+// credentials and the requests that use the tokens are replayed without them.
+type Recorder struct {
+	dir  string
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	statuses map[string]int
+}
+
+// NewRecorder returns a transport that records the requests to the directory,
+// which is created if it doesn't exist. The requests are made with the base
+// transport, or http.DefaultTransport when it's nil.
+func NewRecorder(dir string, base http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating fixture directory: %w", err)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Recorder{
+		dir:      dir,
+		base:     base,
+		statuses: map[string]int{},
+	}, nil
+}
+
+// RoundTrip makes the request and records it
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if isTokenRequest(req, body) {
+		return resp, nil
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	rec := recording{
+		Request: recordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   string(body),
+		},
+		Response: recordedResponse{
+			Status: resp.StatusCode,
+			Header: http.Header{},
+		},
+	}
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Values(h); len(v) > 0 {
+			rec.Response.Header[h] = v
+		}
+	}
+	if utf8.Valid(respBody) {
+		rec.Response.Body = string(respBody)
+	} else {
+		rec.Response.BodyBase64 = base64.StdEncoding.EncodeToString(respBody)
+	}
+
+	if err := r.write(rec); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// write writes the recording to its file, unless it would replace a response
+// with an unauthorized one
+func (r *Recorder) write(rec recording) error {
+	name := fileName(rec.Request)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if status, ok := r.statuses[name]; ok && status != http.StatusUnauthorized && rec.Response.Status == http.StatusUnauthorized {
+		return nil
+	}
+	r.statuses[name] = rec.Response.Status
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding recording: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, name), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing recording: %w", err)
+	}
+	slog.Debug("recorded request", "method", rec.Request.Method, "url", rec.Request.URL, "status", rec.Response.Status, "file", name)
+
+	return nil
+}
+
+// Replayer is a transport that answers the requests with the responses that
+// a Recorder wrote to a directory, without making them. A request that
+// wasn't recorded is an error.
+type Replayer struct {
+	dir string
+}
+
+// NewReplayer returns a transport that replays the requests in the directory
+func NewReplayer(dir string) (*Replayer, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixture directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("reading fixture directory: %s isn't a directory", dir)
+	}
+
+	return &Replayer{dir: dir}, nil
+}
+
+// RoundTrip returns the recorded response to the request
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	name := fileName(recordedRequest{Method: req.Method, URL: req.URL.String(), Body: string(body)})
+
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("replaying %s %s: there's no recording of the request in %s", req.Method, req.URL, r.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading recording: %w", err)
+	}
+
+	var rec recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("decoding recording: %s: %w", name, err)
+	}
+
+	respBody := []byte(rec.Response.Body)
+	if rec.Response.BodyBase64 != "" {
+		respBody, err = base64.StdEncoding.DecodeString(rec.Response.BodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("decoding recording: %s: %w", name, err)
+		}
+	}
+
+	header := rec.Response.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", fmt.Sprint(len(respBody)))
+	slog.Debug("replayed request", "method", req.Method, "url", req.URL.String(), "status", rec.Response.Status, "file", name)
+
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Response.Status, http.StatusText(rec.Response.Status)),
+		StatusCode:    rec.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}
+	if req.Method == http.MethodHead {
+		resp.Body = http.NoBody
+	}
+
+	return resp, nil
+}
+
+// readRequestBody reads the body of the request and replaces it, so that it
+// can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading request: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return body, nil
+}
+
+// isTokenRequest returns true if the request is for a registry token, which
+// is a GET with the service and scope in the query, or a POST of a form with
+// a grant type
+func isTokenRequest(req *http.Request, body []byte) bool {
+	q := req.URL.Query()
+	if q.Has("service") && q.Has("scope") {
+		return true
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		return err == nil && form.Has("grant_type")
+	}
+
+	return false
+}
+
+// unsafeChars are the characters that are replaced in the names of the files
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileName returns the name of the file that the request is recorded in. It
+// starts with the method, host and path of the request, so that the
+// directory can be read, and ends with a hash of the whole request.
+func fileName(req recordedRequest) string {
+	h := sha256.Sum256([]byte(req.Method + " " + req.URL + "\n" + req.Body))
+
+	prefix := req.Method
+	if u, err := url.Parse(req.URL); err == nil {
+		prefix += "_" + u.Host + u.Path
+	}
+	prefix = strings.Trim(unsafeChars.ReplaceAllString(prefix, "_"), "_")
+	if len(prefix) > 100 {
+		prefix = prefix[:100]
+	}
+
+	return prefix + "-" + hex.EncodeToString(h[:])[:12] + ".json"
+}
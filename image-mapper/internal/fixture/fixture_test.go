@@ -0,0 +1,189 @@
+package fixture
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/query":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"query": ` + string(body) + `}`))
+		case "/token":
+			_, _ = w.Write([]byte(`{"token": "secret"}`))
+		case "/v2/nginx/tags/list":
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Set-Cookie", "session=secret")
+			_, _ = w.Write([]byte(`{"name": "nginx", "tags": ["1.25"]}`))
+		case "/blob":
+			_, _ = w.Write([]byte{0xff, 0xfe, 0x00})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	type request struct {
+		method string
+		path   string
+		body   string
+		auth   bool
+	}
+	requests := []request{
+		{method: http.MethodPost, path: "/query", body: `"a"`},
+		{method: http.MethodPost, path: "/query", body: `"b"`},
+		{method: http.MethodGet, path: "/token?service=registry&scope=repository:nginx:pull"},
+		{method: http.MethodGet, path: "/v2/nginx/tags/list"},
+		{method: http.MethodGet, path: "/v2/nginx/tags/list", auth: true},
+		{method: http.MethodGet, path: "/v2/nginx/tags/list"},
+		{method: http.MethodGet, path: "/blob"},
+		{method: http.MethodGet, path: "/missing"},
+	}
+	do := func(t *testing.T, client *http.Client, r request) (int, string, http.Header, error) {
+		t.Helper()
+
+		req, err := http.NewRequest(r.method, srv.URL+r.path, strings.NewReader(r.body))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if r.auth {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return resp.StatusCode, string(body), resp.Header, nil
+	}
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	recorded := map[string]string{}
+	for _, r := range requests {
+		status, body, _, err := do(t, &http.Client{Transport: recorder}, r)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		recorded[r.method+" "+r.path+" "+r.body] = body
+		if status == http.StatusOK || status == http.StatusNotFound {
+			continue
+		}
+		if status != http.StatusUnauthorized {
+			t.Errorf("unexpected status %d for %s", status, r.path)
+		}
+	}
+	srv.Close()
+
+	// Credentials aren't written to the directory
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("expected 5 recordings, got %d", len(entries))
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(dir + "/" + e.Name())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("expected %s not to contain credentials:\n%s", e.Name(), data)
+		}
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	client := &http.Client{Transport: replayer}
+
+	testCases := map[string]struct {
+		request    request
+		wantStatus int
+		wantBody   string
+		wantHeader string
+		wantErr    bool
+	}{
+		"post": {
+			request:    requests[1],
+			wantStatus: http.StatusOK,
+			wantBody:   recorded[`POST /query "b"`],
+			wantHeader: "application/json",
+		},
+		"authorized response replaces unauthorized": {
+			request:    requests[3],
+			wantStatus: http.StatusOK,
+			wantBody:   `{"name": "nginx", "tags": ["1.25"]}`,
+			wantHeader: "application/json",
+		},
+		"binary": {
+			request:    requests[6],
+			wantStatus: http.StatusOK,
+			wantBody:   string([]byte{0xff, 0xfe, 0x00}),
+		},
+		"not found": {
+			request:    requests[7],
+			wantStatus: http.StatusNotFound,
+		},
+		"token isn't recorded": {
+			request: requests[2],
+			wantErr: true,
+		},
+		"not recorded": {
+			request: request{method: http.MethodPost, path: "/query", body: `"c"`},
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			status, body, header, err := do(t, client, tc.request)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if status != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, status)
+			}
+			if diff := cmp.Diff(tc.wantBody, body); diff != "" {
+				t.Errorf("unexpected body (-want +got):\n%s", diff)
+			}
+			if got := header.Get("Content-Type"); tc.wantHeader != "" && got != tc.wantHeader {
+				t.Errorf("expected content type %s, got %s", tc.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestNewReplayerMissingDirectory(t *testing.T) {
+	if _, err := NewReplayer(t.TempDir() + "/missing"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,107 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultURL is the address of the GitHub API
+const DefaultURL = "https://api.github.com"
+
+// Client creates pull requests with the GitHub API
+type Client struct {
+	// URL is the address of the API. Defaults to DefaultURL. For GitHub
+	// Enterprise Server, it's https://<host>/api/v3.
+	URL string
+
+	// Token authenticates the requests
+	Token string
+
+	// HTTPClient makes the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// PullRequest is a request to merge the head branch into the base branch
+type PullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// CreatePullRequest opens a pull request in the repository, which is in the
+// form owner/name, and returns its URL
+func (c *Client) CreatePullRequest(ctx context.Context, repo string, pr PullRequest) (string, error) {
+	body, err := json.Marshal(pr)
+	if err != nil {
+		return "", fmt.Errorf("marshalling pull request: %w", err)
+	}
+
+	baseURL := c.URL
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/repos/%s/pulls", strings.TrimSuffix(baseURL, "/"), repo), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "image-mapper")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var r struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("decoding response: unexpected status code: %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		msg := r.Message
+		for _, e := range r.Errors {
+			msg += ": " + e.Message
+		}
+		return "", fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, msg)
+	}
+
+	return r.HTMLURL, nil
+}
+
+// remotePattern matches the owner and name of a repository in the https and
+// ssh forms of a GitHub remote URL
+var remotePattern = regexp.MustCompile(`^(?:https?://[^/]+/|ssh://git@[^/]+/|git@[^:]+:)([^/]+/[^/]+?)(?:\.git)?/?$`)
+
+// ParseRemote returns the repository, in the form owner/name, from the URL of
+// a git remote, i.e https://github.com/owner/name.git or
+// git@github.com:owner/name.git
+func ParseRemote(remote string) (string, error) {
+	match := remotePattern.FindStringSubmatch(strings.TrimSpace(remote))
+	if match == nil {
+		return "", fmt.Errorf("can't find the repository in the remote URL: %s", remote)
+	}
+
+	return match[1], nil
+}
@@ -0,0 +1,90 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCreatePullRequest(t *testing.T) {
+	var got PullRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/example/app/pulls" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("unexpected authorization header: %s", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("unexpected error decoding request: %s", err)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.com/example/app/pull/1"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, Token: "token"}
+	want := PullRequest{
+		Title: "Use Chainguard images",
+		Body:  "body",
+		Head:  "image-mapper",
+		Base:  "main",
+	}
+	url, err := c.CreatePullRequest(t.Context(), "example/app", want)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if url != "https://github.com/example/app/pull/1" {
+		t.Errorf("unexpected url: %s", url)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected pull request (-want +got):\n%s", diff)
+	}
+}
+
+func TestCreatePullRequestError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message": "Validation Failed", "errors": [{"message": "A pull request already exists for example:image-mapper."}]}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	_, err := c.CreatePullRequest(t.Context(), "example/app", PullRequest{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	want := "unexpected status code: 422: Validation Failed: A pull request already exists for example:image-mapper."
+	if err.Error() != want {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	testCases := []struct {
+		remote  string
+		want    string
+		wantErr bool
+	}{
+		{remote: "https://github.com/example/app.git", want: "example/app"},
+		{remote: "https://github.com/example/app", want: "example/app"},
+		{remote: "git@github.com:example/app.git", want: "example/app"},
+		{remote: "ssh://git@github.example.com/example/app.git", want: "example/app"},
+		{remote: "https://github.com/example", wantErr: true},
+		{remote: "/tmp/app", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := ParseRemote(tc.remote)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", tc.remote, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: wanted %s but got %s", tc.remote, tc.want, got)
+		}
+	}
+}
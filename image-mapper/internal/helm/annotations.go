@@ -0,0 +1,150 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// imagesAnnotation is the Artifact Hub annotation that lists the images a
+// chart uses, as a YAML list in a string:
+//
+//	annotations:
+//	  artifacthub.io/images: |
+//	    - name: argocd
+//	      image: quay.io/argoproj/argocd:v2.9.3
+const imagesAnnotation = "artifacthub.io/images"
+
+// MapChartYAML returns the Chart.yaml of the chart with the images in its
+// artifacthub.io/images annotation mapped to Chainguard, followed by the
+// Chart.yaml of each dependency that has the annotation.
+func MapChartYAML(ctx context.Context, chart ChartDescriptor, opts ...mapper.Option) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	chartPath, err := fetchChart(ctx, chart, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return mapChartYAML(m, chartPath)
+}
+
+// mapChartYAML maps the images in the annotations of the chart and its
+// dependencies. Each dependency is a separate document with a comment that
+// records where its Chart.yaml is in the chart.
+func mapChartYAML(m mapper.Mapper, chartPath string) ([]byte, error) {
+	chartDir, err := findChartDir(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	chrt, err := loader.Load(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading chart: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	var walk func(chrt *chart.Chart, dir string, top bool) error
+	walk = func(chrt *chart.Chart, dir string, top bool) error {
+		node, mapped, err := mapChartAnnotations(m, chrt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path.Join(dir, chartutil.ChartfileName), err)
+		}
+		if top || mapped {
+			if !top {
+				node.HeadComment = fmt.Sprintf("Source: %s", path.Join(dir, chartutil.ChartfileName))
+			}
+			if err := enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}); err != nil {
+				return fmt.Errorf("marshalling output document: %w", err)
+			}
+		}
+		for _, dep := range chrt.Dependencies() {
+			if err := walk(dep, path.Join(dir, "charts", dep.Name()), false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(chrt, "", true); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("marshalling output document: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mapChartAnnotations returns the Chart.yaml of the chart with the images in
+// its annotations mapped, and whether it has any images to map
+func mapChartAnnotations(m mapper.Mapper, chrt *chart.Chart) (*yaml.Node, bool, error) {
+	var data []byte
+	for _, f := range chrt.Raw {
+		if f.Name == chartutil.ChartfileName {
+			data = f.Data
+			break
+		}
+	}
+	node, err := parseValues(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	images := yamlhelpers.LookupNode([]string{"annotations", imagesAnnotation}, node)
+	if images == nil || images.Kind != yaml.ScalarNode {
+		return node, false, nil
+	}
+
+	list, err := parseValues([]byte(images.Value))
+	if err != nil {
+		return nil, false, fmt.Errorf("parsing %s annotation: %w", imagesAnnotation, err)
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, false, fmt.Errorf("parsing %s annotation: expected a list", imagesAnnotation)
+	}
+	for _, item := range list.Content {
+		image := yamlhelpers.LookupNode([]string{"image"}, item)
+		if !hasValue(image) || image.Kind != yaml.ScalarNode {
+			continue
+		}
+		mapping, err := mapper.MapImage(m, image.Value)
+		if err != nil {
+			image.LineComment = fmt.Sprintf("Failed to map: %s", err)
+			continue
+		}
+		setValue(image, mapping.String())
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(list); err != nil {
+		return nil, false, fmt.Errorf("marshalling %s annotation: %w", imagesAnnotation, err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, false, fmt.Errorf("marshalling %s annotation: %w", imagesAnnotation, err)
+	}
+	images.Value = buf.String()
+	images.Style = yaml.LiteralStyle
+
+	return node, true, nil
+}
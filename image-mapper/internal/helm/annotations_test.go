@@ -0,0 +1,82 @@
+package helm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMapChartYAML(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"quay.io/argoproj/argocd:v2.9.3": {
+				"cgr.dev/chainguard/argocd:2.9.3",
+			},
+			"ghcr.io/dexidp/dex:v2.37.0": {
+				"cgr.dev/chainguard/dex:2.37.0",
+			},
+			"ghcr.io/oliver006/redis_exporter:v1.62.0": {
+				"cgr.dev/chainguard/prometheus-redis-exporter:1.62.0",
+			},
+		},
+	}
+
+	got, err := mapChartYAML(m, "testdata/annotations-chart")
+	if err != nil {
+		t.Fatalf("unexpected error mapping chart: %s", err)
+	}
+
+	// The images in the annotations are mapped, and the Chart.yaml of each
+	// dependency with images follows the chart's
+	want := `apiVersion: v2
+name: annotations-chart
+description: A chart that lists its images in annotations
+type: application
+version: 0.1.0
+appVersion: v2.9.3
+annotations:
+  artifacthub.io/changes: |
+    - kind: added
+      description: Add the dex image
+  artifacthub.io/images: |
+    - name: argocd
+      image: cgr.dev/chainguard/argocd:2.9.3 # Original: quay.io/argoproj/argocd:v2.9.3
+    - name: dex
+      image: cgr.dev/chainguard/dex:2.37.0 # Original: ghcr.io/dexidp/dex:v2.37.0
+      platforms:
+        - linux/amd64
+    - name: unknown
+      image: example/unknown:1.0 # Failed to map: no results found
+---
+# Source: charts/exporter/Chart.yaml
+apiVersion: v2
+name: exporter
+type: application
+version: 1.0.0
+annotations:
+  artifacthub.io/images: |
+    - name: redis-exporter
+      image: cgr.dev/chainguard/prometheus-redis-exporter:1.62.0 # Original: ghcr.io/oliver006/redis_exporter:v1.62.0
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected Chart.yaml:\n%s", diff)
+	}
+}
+
+func TestMapChartYAMLNoAnnotations(t *testing.T) {
+	got, err := mapChartYAML(&mockMapper{}, "testdata/dependency-chart")
+	if err != nil {
+		t.Fatalf("unexpected error mapping chart: %s", err)
+	}
+
+	// The Chart.yaml of the chart is output as it is, and the
+	// dependencies without images are left out
+	want, err := os.ReadFile("testdata/dependency-chart/Chart.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error reading Chart.yaml: %s", err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("unexpected Chart.yaml:\n%s", diff)
+	}
+}
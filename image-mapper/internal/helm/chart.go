@@ -1,17 +1,22 @@
 package helm
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
-	"path/filepath"
-	"strings"
+	"slices"
 
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
 	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
 )
 
 // ChartDescriptor describes a chart
@@ -21,9 +26,33 @@ type ChartDescriptor struct {
 	Version    string
 }
 
+// ChartOptions configures how a chart is mapped
+type ChartOptions struct {
+	// ValuesFiles are values files that are applied on top of the chart's
+	// default values, like helm install -f
+	ValuesFiles []string
+
+	// Render renders the chart's templates to find images that aren't
+	// set by the values
+	Render bool
+
+	// Keys are additional patterns that match image related keys in
+	// the values
+	Keys Keys
+
+	// GlobalRegistry replaces the registry values of the images with a
+	// single global registry value, like global.imageRegistry, if the
+	// chart has one and all the images are mapped to the same registry
+	GlobalRegistry bool
+
+	// ReleaseName is the name of the release used to render the chart.
+	// It defaults to release-name, like helm template.
+	ReleaseName string
+}
+
 // MapChart extracts image related values from a Helm chart and maps them to
 // Chainguard
-func MapChart(ctx context.Context, chart ChartDescriptor, opts ...mapper.Option) ([]byte, error) {
+func MapChart(ctx context.Context, chart ChartDescriptor, copts ChartOptions, opts ...mapper.Option) ([]byte, error) {
 	// Create a temporary directory where we'll untar the chart
 	dir, err := os.MkdirTemp("", "")
 	if err != nil {
@@ -31,9 +60,10 @@ func MapChart(ctx context.Context, chart ChartDescriptor, opts ...mapper.Option)
 	}
 	defer os.RemoveAll(dir)
 
-	// Pull the helm chart down to the temp dir
-	if err := helmPull(ctx, chart, dir); err != nil {
-		return nil, fmt.Errorf("pulling chart: %w", err)
+	// Fetch the chart, if it isn't already on disk
+	chartPath, err := fetchChart(ctx, chart, dir)
+	if err != nil {
+		return nil, err
 	}
 
 	// Construct a mapper
@@ -43,35 +73,50 @@ func MapChart(ctx context.Context, chart ChartDescriptor, opts ...mapper.Option)
 	}
 
 	// Map the images in the chart
-	return mapChart(m, dir)
+	return mapChart(m, chartPath, copts)
+}
+
+// valuesInput is a values file and the path its values are nested under
+type valuesInput struct {
+	yamlPath []string
+	node     *yaml.Node
+
+	// appVersion is the appVersion of the chart the values belong to
+	appVersion string
 }
 
 // mapChart extracts image related values from the chart and maps them to
 // Chainguard
-func mapChart(m mapper.Mapper, chartPath string) ([]byte, error) {
-	// Collect all the values.yaml files in the Chart and its subcharts
-	var valuesFiles []string
-	if err := filepath.WalkDir(chartPath, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+func mapChart(m mapper.Mapper, chartPath string, opts ChartOptions) ([]byte, error) {
+	chartDir, err := findChartDir(chartPath)
+	if err != nil {
+		return nil, err
+	}
 
-		if !d.IsDir() {
-			return nil
-		}
+	// Loading the chart extracts any subcharts that are packaged as
+	// archives in the charts directory
+	chrt, err := loader.Load(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading chart: %w", err)
+	}
 
-		if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err != nil {
-			return nil
-		}
-		if _, err := os.Stat(filepath.Join(path, "values.yaml")); err != nil {
-			return nil
-		}
+	// Collect the values of the chart and its dependencies. The child
+	// values come before the parents, so that we prefer any overrides
+	// configured in the parent values.
+	inputs, err := chartValues(chrt, []string{})
+	if err != nil {
+		return nil, err
+	}
 
-		valuesFiles = append(valuesFiles, filepath.Join(path, "values.yaml"))
+	// Map the values files provided by the user last, so they take
+	// precedence over the chart's default values
+	for _, path := range opts.ValuesFiles {
+		inputNode, err := readValuesFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading values file: %s: %w", path, err)
+		}
 
-		return nil
-	}); err != nil {
-		return nil, fmt.Errorf("walking chart directory: %w", err)
+		inputs = append(inputs, valuesInput{yamlPath: []string{}, node: inputNode})
 	}
 
 	// We'll write modified nodes to this node
@@ -80,36 +125,47 @@ func mapChart(m mapper.Mapper, chartPath string) ([]byte, error) {
 		Content: []*yaml.Node{},
 	}
 
-	// Iterate backwards over the collected values files so that we map the
-	// child values before the parents, prefering any overrides configured
-	// in the parent values.
-	for i := len(valuesFiles) - 1; i >= 0; i-- {
-		path := valuesFiles[i]
+	for _, input := range inputs {
+		if err := yamlhelpers.WalkNode(input.node, mapNode(m, opts.Keys, input, outputNode)); err != nil {
+			return nil, err
+		}
+	}
 
-		yamlPath := buildPath(strings.TrimPrefix(path, chartPath))
+	if opts.GlobalRegistry {
+		setGlobalRegistry(opts.Keys, inputs, outputNode)
+	}
 
-		inputNode, err := readValuesFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("reading values file: %s: %w", path, err)
-		}
+	docs := []*yaml.Node{outputNode}
 
-		if err := yamlhelpers.WalkNode(inputNode, mapNode(m, yamlPath, outputNode)); err != nil {
-			return nil, err
+	// Render the templates to find the images that the values don't
+	// cover
+	if opts.Render {
+		patches, err := mapTemplates(m, chartDir, opts, inputs, outputNode)
+		if err != nil {
+			return nil, fmt.Errorf("mapping templates: %w", err)
 		}
-
+		docs = append(docs, patches...)
+	} else {
+		warnDynamicImages(chrt)
 	}
 
-	// Marshal the modified nodes to a new document
-	doc := &yaml.Node{
-		Kind:    yaml.DocumentNode,
-		Content: []*yaml.Node{outputNode},
+	// Marshal the modified nodes to new documents
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	for _, node := range docs {
+		doc := &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{node},
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("marshalling output document: %w", err)
+		}
 	}
-	output, err := yaml.Marshal(doc)
-	if err != nil {
+	if err := enc.Close(); err != nil {
 		return nil, fmt.Errorf("marshalling output document: %w", err)
 	}
 
-	return output, nil
+	return buf.Bytes(), nil
 }
 
 // readValuesFile reads a values file from disk and returns it as a *yaml.Node
@@ -119,45 +175,165 @@ func readValuesFile(path string) (*yaml.Node, error) {
 		return nil, fmt.Errorf("reading file: %w", err)
 	}
 
-	var doc yaml.Node
-	if err := yaml.Unmarshal(data, &doc); err != nil {
-		return nil, fmt.Errorf("unmarshalling yaml: %w", err)
-	}
+	return parseValues(data)
+}
 
-	// Return root mapping node (skip document wrapper)
-	if len(doc.Content) > 0 {
-		return doc.Content[0], nil
+// parseValues parses YAML or JSON values and returns them as a *yaml.Node.
+// Like Helm, only the first document is read.
+func parseValues(data []byte) (*yaml.Node, error) {
+	nodes, err := decodeValues(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode}, nil
 	}
 
-	return &yaml.Node{Kind: yaml.MappingNode}, nil
+	return nodes[0], nil
 }
 
-// buildPath infers the appropriate nesting in the yaml structure based on the
-// path to a values file.
+// chartValues returns the values of the chart and its dependencies, nested
+// under the path the chart's values are scoped to in the parent chart.
 //
-// For instance, values in charts/grafana/values.yaml would be nested under
-// "grafana".
+// For instance, the values of a dependency called grafana would be nested
+// under "grafana". If the dependency has an alias, the values are nested under
+// the alias instead.
 //
-// And values in charts/grafana/charts/redis/values.yaml would be nested under
-// "grafana.redis".
-func buildPath(path string) []string {
-	parent := []string{}
-	parts := strings.Split(filepath.Clean(path), string(filepath.Separator))
-	for i, part := range parts {
-		if part != "charts" {
+// The values of dependencies come before the values of the chart.
+func chartValues(chrt *chart.Chart, yamlPath []string) ([]valuesInput, error) {
+	var inputs []valuesInput
+	for _, dep := range dependencyScopes(chrt) {
+		depInputs, err := chartValues(dep.chart, slices.Concat(yamlPath, []string{dep.scope}))
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, depInputs...)
+	}
+
+	for _, f := range chrt.Raw {
+		if f.Name != chartutil.ValuesfileName {
 			continue
 		}
 
-		parent = append(parent, parts[i+1])
+		node, err := parseValues(f.Data)
+		if err != nil {
+			return nil, fmt.Errorf("reading values file: %s: %w", chrt.ChartFullPath(), err)
+		}
+
+		inputs = append(inputs, valuesInput{
+			yamlPath:   yamlPath,
+			node:       node,
+			appVersion: chrt.Metadata.AppVersion,
+		})
 	}
 
-	return parent
+	return inputs, nil
+}
+
+// dependencyScope is a subchart and the key its values are scoped to in the
+// parent chart's values
+type dependencyScope struct {
+	scope string
+	chart *chart.Chart
+}
+
+// dependencyScopes matches the dependencies declared in the chart's metadata
+// to the subcharts in the charts directory. A chart can be declared more than
+// once under different aliases.
+//
+// Subcharts that aren't declared as dependencies are scoped to their name.
+func dependencyScopes(chrt *chart.Chart) []dependencyScope {
+	var (
+		scopes   []dependencyScope
+		declared = map[*chart.Chart]bool{}
+	)
+	for _, dep := range chrt.Metadata.Dependencies {
+		i := slices.IndexFunc(chrt.Dependencies(), func(sub *chart.Chart) bool {
+			return sub.Name() == dep.Name
+		})
+		if i < 0 {
+			slog.Warn("the chart depends on a chart that isn't in its charts directory. Run 'helm dependency build' to include its images.", "chart", chrt.Name(), "dependency", dependencyVersion(chrt, dep))
+			continue
+		}
+		sub := chrt.Dependencies()[i]
+		declared[sub] = true
+
+		scope := dep.Name
+		if dep.Alias != "" {
+			scope = dep.Alias
+		}
+		scopes = append(scopes, dependencyScope{scope: scope, chart: sub})
+	}
+
+	for _, sub := range chrt.Dependencies() {
+		if declared[sub] {
+			continue
+		}
+		scopes = append(scopes, dependencyScope{scope: sub.Name(), chart: sub})
+	}
+
+	return scopes
+}
+
+// dependencyVersion describes a dependency with the version that is locked in
+// Chart.lock, or the version constraint if it isn't locked
+func dependencyVersion(chrt *chart.Chart, dep *chart.Dependency) string {
+	version := dep.Version
+	if chrt.Lock != nil {
+		for _, locked := range chrt.Lock.Dependencies {
+			if locked.Name == dep.Name && locked.Repository == dep.Repository {
+				version = locked.Version
+				break
+			}
+		}
+	}
+	if version == "" {
+		return dep.Name
+	}
+
+	return fmt.Sprintf("%s %s", dep.Name, version)
+}
+
+// fetchChart returns the path to the chart on disk. Local chart directories
+// are used as they are, while packaged charts are extracted and remote charts
+// are pulled into the directory.
+func fetchChart(ctx context.Context, chart ChartDescriptor, dir string) (string, error) {
+	if chart.Repository == "" && !registry.IsOCI(chart.Name) {
+		if fi, err := os.Stat(chart.Name); err == nil {
+			if fi.IsDir() {
+				return chart.Name, nil
+			}
+			if err := chartutil.ExpandFile(dir, chart.Name); err != nil {
+				return "", fmt.Errorf("extracting chart: %w", err)
+			}
+			return dir, nil
+		}
+	}
+
+	// Pull the helm chart down to the directory
+	if err := helmPull(ctx, chart, dir); err != nil {
+		return "", fmt.Errorf("pulling chart: %w", err)
+	}
+
+	return dir, nil
 }
 
 // helmPull pulls a remote chart and extracts it to the specified directory
 func helmPull(ctx context.Context, chart ChartDescriptor, dir string) error {
 	client := action.NewPullWithOpts(action.WithConfig(&action.Configuration{}))
 	client.Settings = cli.New()
+
+	// Authenticate to OCI registries with the credentials from
+	// 'helm registry login'
+	registryClient, err := registry.NewClient(
+		registry.ClientOptCredentialsFile(client.Settings.RegistryConfig),
+		registry.ClientOptEnableCache(true),
+	)
+	if err != nil {
+		return fmt.Errorf("creating registry client: %w", err)
+	}
+	client.SetRegistryClient(registryClient)
+
 	client.DestDir = dir
 	client.Untar = true
 
@@ -168,8 +344,7 @@ func helmPull(ctx context.Context, chart ChartDescriptor, dir string) error {
 		client.RepoURL = chart.Repository
 	}
 
-	_, err := client.Run(chart.Name)
-	if err != nil {
+	if _, err := client.Run(chart.Name); err != nil {
 		return fmt.Errorf("pulling chart: %w", err)
 	}
 
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 )
 
 func TestMapChart(t *testing.T) {
@@ -79,7 +81,7 @@ server:
 		},
 	}
 
-	got, err := mapChart(m, "testdata/test-chart")
+	got, err := mapChart(m, "testdata/test-chart", ChartOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error mapping chart: %s", err)
 	}
@@ -137,7 +139,7 @@ server:
 		t.Fatalf("unexpected error constructing mapper: %s", err)
 	}
 
-	got, err := mapChart(m, "testdata/test-chart")
+	got, err := mapChart(m, "testdata/test-chart", ChartOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error mapping chart: %s", err)
 	}
@@ -146,3 +148,153 @@ server:
 		t.Errorf("unexpected values:\n%s", diff)
 	}
 }
+
+func TestMapChartRender(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+			"docker.io/envoyproxy/envoy:v1.31.0": {
+				"cgr.dev/chainguard/envoy:1.31.0",
+			},
+			"bitnami/kubectl:1.30": {
+				"cgr.dev/chainguard/kubectl:1.30",
+			},
+		},
+	}
+
+	got, err := mapChart(m, "testdata/render-chart", ChartOptions{Render: true})
+	if err != nil {
+		t.Fatalf("unexpected error mapping chart: %s", err)
+	}
+
+	want := []byte(`image:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+sidecarImage: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+proxy:
+    repo: cgr.dev/chainguard/envoy # Original: docker.io/envoyproxy/envoy
+    version: 1.31.0 # Original: v1.31.0
+---
+# The images in Job/release-name-setup can't be set with values, so they must be patched
+apiVersion: batch/v1
+kind: Job
+metadata:
+    name: release-name-setup
+spec:
+    template:
+        spec:
+            containers:
+                - name: kubectl
+                  image: cgr.dev/chainguard/kubectl:1.30 # Original: bitnami/kubectl:1.30
+`)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected values:\n%s", diff)
+	}
+
+	// Without rendering, only the image block is mapped
+	got, err = mapChart(m, "testdata/render-chart", ChartOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error mapping chart: %s", err)
+	}
+
+	want = []byte(`image:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+`)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected values:\n%s", diff)
+	}
+}
+
+func TestMapChartDependencies(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25",
+			},
+			"redis:7.4": {
+				"cgr.dev/chainguard/redis:7.4",
+			},
+			"postgres:16": {
+				"cgr.dev/chainguard/postgres:16",
+			},
+		},
+	}
+
+	got, err := mapChart(m, "testdata/dependency-chart", ChartOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error mapping chart: %s", err)
+	}
+
+	// The values of each dependency are nested under its alias, and the
+	// values of the packaged dependency are included too
+	want := []byte(`cache:
+    image:
+        repository: cgr.dev/chainguard/redis # Original: redis
+queue:
+    image:
+        repository: cgr.dev/chainguard/redis # Original: redis
+postgresql:
+    image:
+        repository: cgr.dev/chainguard/postgres # Original: postgres
+image:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+`)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected values:\n%s", diff)
+	}
+}
+
+func TestFetchChartLocal(t *testing.T) {
+	// A chart directory is used in place
+	got, err := fetchChart(t.Context(), ChartDescriptor{Name: "testdata/render-chart"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error fetching chart: %s", err)
+	}
+	if got != "testdata/render-chart" {
+		t.Errorf("unexpected chart path: %s", got)
+	}
+
+	// A packaged chart is extracted
+	chrt, err := loader.Load("testdata/render-chart")
+	if err != nil {
+		t.Fatalf("unexpected error loading chart: %s", err)
+	}
+	archive, err := chartutil.Save(chrt, t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error packaging chart: %s", err)
+	}
+
+	dir := t.TempDir()
+	got, err = fetchChart(t.Context(), ChartDescriptor{Name: archive}, dir)
+	if err != nil {
+		t.Fatalf("unexpected error fetching chart: %s", err)
+	}
+	if got != dir {
+		t.Errorf("unexpected chart path: %s", got)
+	}
+	if _, err := findChartDir(got); err != nil {
+		t.Errorf("unexpected error finding extracted chart: %s", err)
+	}
+}
+
+func TestChartImages(t *testing.T) {
+	got, err := ChartImages("testdata/render-chart")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"busybox:1.36",
+		"nginx:1.25",
+		"docker.io/envoyproxy/envoy:v1.31.0",
+		"bitnami/kubectl:1.30",
+		"example/unknown:1.0",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
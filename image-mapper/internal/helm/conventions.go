@@ -0,0 +1,112 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// conventionKeys are the keys that well-known charts use for the parts of an
+// image reference, when they aren't named like the default keys. They're
+// matched after the default keys and the user's patterns.
+var conventionKeys = Keys{
+	// The Elastic charts set the tag alongside the image:
+	//
+	//	image: docker.elastic.co/elasticsearch/elasticsearch
+	//	imageTag: 8.5.1
+	Tag: []string{"imageTag"},
+
+	// kube-prometheus-stack, Grafana and the Prometheus community charts
+	// pin images with sha, and ingress-nginx pins its chroot image with
+	// digestChroot:
+	//
+	//	image:
+	//	  repository: prometheus-operator/prometheus-operator
+	//	  sha: ""
+	Digest: []string{"*[iI]mage.sha", "*[iI]mage.digestChroot"},
+}
+
+// containerListKeys are the keys that charts use for extra containers. Many
+// charts, like Keycloak and Grafana, take them as a string that's rendered
+// with tpl, rather than as a list:
+//
+//	extraInitContainers: |
+//	  - name: wait-for-it
+//	    image: busybox:1.36
+var containerListKeys = []string{
+	"initContainers",
+	"extraInitContainers",
+	"extraContainers",
+	"sidecars",
+	"extraSidecars",
+	"sidecarContainers",
+}
+
+// isContainerListString returns true if the value is a string of containers
+// held by one of the container list keys
+func isContainerListString(path []string, value *yaml.Node) bool {
+	if len(path) == 0 || value.Kind != yaml.ScalarNode || value.ShortTag() != "!!str" {
+		return false
+	}
+
+	return slices.Contains(containerListKeys, path[len(path)-1]) && strings.Contains(value.Value, "image:")
+}
+
+// mapContainerListString maps the images of the containers in a string of
+// containers and adds the string to the output values with the mapped images.
+// Templated strings are skipped, because they can't be parsed and encoded
+// again without changing the templates.
+func mapContainerListString(m mapper.Mapper, input valuesInput, path []string, value *yaml.Node, output *yaml.Node) {
+	if strings.Contains(value.Value, "{{") {
+		return
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value.Value), &doc); err != nil {
+		return
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return
+	}
+
+	found := false
+	for _, container := range doc.Content[0].Content {
+		image := yamlhelpers.LookupNode([]string{"image"}, container)
+		if !hasValue(image) || image.Kind != yaml.ScalarNode {
+			continue
+		}
+		found = true
+
+		mapping, err := mapper.MapImage(m, image.Value)
+		if err != nil {
+			image.LineComment = fmt.Sprintf("Failed to map: %s", err)
+			continue
+		}
+		setValue(image, mapping.String())
+	}
+	if !found {
+		return
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc.Content[0]); err != nil {
+		return
+	}
+	if err := enc.Close(); err != nil {
+		return
+	}
+
+	addOutput(input, path, output, &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!str",
+		Style: yaml.LiteralStyle,
+		Value: buf.String(),
+	})
+}
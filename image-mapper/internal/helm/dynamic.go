@@ -0,0 +1,82 @@
+package helm
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+var (
+	// imageLineRegex matches an image field in a template
+	imageLineRegex = regexp.MustCompile(`^\s*(?:-\s*)?image:\s*(.*\{\{.*)$`)
+
+	// actionRegex matches a template action, like {{ .Values.image.tag }}
+	actionRegex = regexp.MustCompile(`\{\{-?(.*?)-?\}\}`)
+
+	// stringFuncRegex matches the template functions that build strings
+	// out of other strings
+	stringFuncRegex = regexp.MustCompile(`(?:^|[\s(|])(tpl|printf|print|cat|replace|trimSuffix|trimPrefix|join|nospace)\s`)
+)
+
+// dynamicImage is an image field in a template that computes the image, rather
+// than joining the values that hold its parts
+type dynamicImage struct {
+	template string
+	line     int
+	text     string
+}
+
+// warnDynamicImages logs a warning for each image in the templates of the
+// chart, and its dependencies, that's computed by the template. Mapping the
+// values may not cover those images.
+func warnDynamicImages(chrt *chart.Chart) {
+	for _, img := range dynamicImages(chrt, "") {
+		slog.Warn("the template computes the image, so the mapped values may not change it. Use --render to check.", "template", img.template, "line", img.line, "image", img.text)
+	}
+}
+
+// dynamicImages returns the image fields in the templates of the chart, and
+// its dependencies, that are computed by the template
+func dynamicImages(chrt *chart.Chart, dir string) []dynamicImage {
+	var images []dynamicImage
+	for _, tmpl := range chrt.Templates {
+		s := bufio.NewScanner(bytes.NewReader(tmpl.Data))
+		for n := 1; s.Scan(); n++ {
+			match := imageLineRegex.FindStringSubmatch(s.Text())
+			if match == nil || !isDynamicImage(match[1]) {
+				continue
+			}
+			images = append(images, dynamicImage{
+				template: path.Join(dir, tmpl.Name),
+				line:     n,
+				text:     strings.TrimSpace(match[1]),
+			})
+		}
+	}
+	for _, dep := range chrt.Dependencies() {
+		images = append(images, dynamicImages(dep, path.Join(dir, "charts", dep.Name()))...)
+	}
+
+	return images
+}
+
+// isDynamicImage returns true if the image is built by string functions, like
+// tpl or printf, or adds text to the values, like a -alpine suffix. Images that
+// join values with :, / or @ are mapped with the values.
+func isDynamicImage(image string) bool {
+	for _, action := range actionRegex.FindAllStringSubmatch(image, -1) {
+		if stringFuncRegex.MatchString(action[1]) {
+			return true
+		}
+	}
+
+	literal := actionRegex.ReplaceAllString(image, "")
+	literal = strings.Trim(literal, ` "'`)
+
+	return strings.Trim(literal, ":/@") != ""
+}
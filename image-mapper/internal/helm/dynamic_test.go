@@ -0,0 +1,87 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+func TestIsDynamicImage(t *testing.T) {
+	testCases := []struct {
+		image string
+		want  bool
+	}{
+		{
+			image: `{{ .Values.image }}`,
+		},
+		{
+			image: `{{ .Values.image.repository }}:{{ .Values.image.tag | default .Chart.AppVersion }}`,
+		},
+		{
+			image: `"{{ .Values.image.registry }}/{{ .Values.image.repository }}@{{ .Values.image.digest }}"`,
+		},
+		{
+			image: `{{ include "common.images.image" (dict "imageRoot" .Values.image "global" .Values.global) }}`,
+		},
+		{
+			image: `{{- .Values.image.repository -}}:{{- .Values.image.tag -}}`,
+		},
+		{
+			image: `"{{ .Values.image.repository }}:{{ .Values.image.tag }}-alpine"`,
+			want:  true,
+		},
+		{
+			image: `docker.io/{{ .Values.image.repository }}`,
+			want:  true,
+		},
+		{
+			image: `{{ tpl .Values.image . }}`,
+			want:  true,
+		},
+		{
+			image: `{{ printf "%s:%s" .Values.image.repository .Values.image.tag }}`,
+			want:  true,
+		},
+		{
+			image: `{{ .Values.image.repository | replace "docker.io/" "" }}`,
+			want:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			if got := isDynamicImage(tc.image); got != tc.want {
+				t.Errorf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDynamicImages(t *testing.T) {
+	chrt, err := loader.Load("testdata/annotations-chart")
+	if err != nil {
+		t.Fatalf("unexpected error loading chart: %s", err)
+	}
+
+	want := []dynamicImage{
+		{
+			template: "templates/deployment.yaml",
+			line:     12,
+			text:     `"{{ .Values.dex.image.repository }}:{{ .Values.dex.image.tag }}-alpine"`,
+		},
+		{
+			template: "templates/deployment.yaml",
+			line:     14,
+			text:     `{{ tpl .Values.configImage . }}`,
+		},
+		{
+			template: "templates/deployment.yaml",
+			line:     16,
+			text:     `{{ printf "%s:%s" .Values.dex.image.repository .Values.dex.image.tag }}`,
+		},
+	}
+	got := dynamicImages(chrt, "")
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(dynamicImage{})); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
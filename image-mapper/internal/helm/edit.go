@@ -0,0 +1,28 @@
+package helm
+
+import (
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// collectEdits compares the mapped values in src to the input values in dst
+// and records the scalars that have changed
+func collectEdits(dst, src *yaml.Node, edits *[]yamlhelpers.Edit) {
+	dst = yamlhelpers.Resolve(dst)
+	switch {
+	case src.Kind == yaml.MappingNode && dst.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			if next := yamlhelpers.LookupNode([]string{src.Content[i].Value}, dst); next != nil {
+				collectEdits(next, src.Content[i+1], edits)
+			}
+		}
+	case src.Kind == yaml.SequenceNode && dst.Kind == yaml.SequenceNode:
+		for i := 0; i < len(src.Content) && i < len(dst.Content); i++ {
+			collectEdits(dst.Content[i], src.Content[i], edits)
+		}
+	case src.Kind == yaml.ScalarNode && dst.Kind == yaml.ScalarNode:
+		if dst.Value != src.Value {
+			*edits = append(*edits, yamlhelpers.Edit{Node: dst, Value: src.Value})
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package helm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats that mapped values can be written in
+const (
+	// FormatValues writes the values as they are
+	FormatValues = "values"
+
+	// FormatFlux writes the values as a patch for a Flux HelmRelease
+	FormatFlux = "flux"
+
+	// FormatArgoCD writes the values as a patch for an Argo CD
+	// Application
+	FormatArgoCD = "argocd"
+
+	// FormatSet writes the values as --set arguments for helm install or
+	// helm upgrade
+	FormatSet = "set"
+)
+
+// ReleaseOptions identify the resource that deploys the release, when the
+// values are written for a GitOps tool
+type ReleaseOptions struct {
+	// Name is the name of the HelmRelease or Application
+	Name string
+
+	// Namespace is the namespace of the HelmRelease or Application
+	Namespace string
+}
+
+// WrapValues wraps the output of MapChart or MapValues in a resource for a
+// GitOps tool, so that it can be used as a patch for the resource that
+// deploys the release:
+//
+//   - flux: a HelmRelease with the values in spec.values. The strategic
+//     merge patches for images that can't be set with values are added to
+//     spec.postRenderers.
+//   - argocd: an Application with the values in
+//     spec.source.helm.valuesObject. Applications can't patch the rendered
+//     chart, so the patches are kept as separate documents.
+//   - set: --set arguments for helm install or helm upgrade, one per line.
+//     The patches can't be passed as arguments, so they're left out.
+//
+// The values format returns the output as it is.
+func WrapValues(output []byte, format string, ropts ReleaseOptions) ([]byte, error) {
+	if format == FormatValues || format == "" {
+		return output, nil
+	}
+	if format != FormatFlux && format != FormatArgoCD && format != FormatSet {
+		return nil, fmt.Errorf("unsupported format: %s (supported: %s, %s, %s, %s)", format, FormatValues, FormatFlux, FormatArgoCD, FormatSet)
+	}
+
+	// The first document is the values and the rest are the patches
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(output))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding output: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		docs = append(docs, doc.Content[0])
+	}
+	values := &yaml.Node{Kind: yaml.MappingNode}
+	if len(docs) > 0 {
+		values = docs[0]
+		docs = docs[1:]
+	}
+	// The head comment of the document would end up above the values key
+	values.HeadComment = ""
+
+	if format == FormatSet {
+		if len(docs) > 0 {
+			slog.Warn("the patches for the images that can't be set with values can't be passed as --set arguments, so they've been left out. Use -o values to see them.")
+		}
+		return setArgs(values), nil
+	}
+
+	resource := &yaml.Node{Kind: yaml.MappingNode}
+	switch format {
+	case FormatFlux:
+		addScalar([]string{"apiVersion"}, resource, "helm.toolkit.fluxcd.io/v2")
+		addScalar([]string{"kind"}, resource, "HelmRelease")
+	case FormatArgoCD:
+		addScalar([]string{"apiVersion"}, resource, "argoproj.io/v1alpha1")
+		addScalar([]string{"kind"}, resource, "Application")
+	}
+	addScalar([]string{"metadata", "name"}, resource, ropts.Name)
+	if ropts.Namespace != "" {
+		addScalar([]string{"metadata", "namespace"}, resource, ropts.Namespace)
+	}
+
+	switch format {
+	case FormatFlux:
+		yamlhelpers.AddNode([]string{"spec", "values"}, resource, values)
+		if len(docs) > 0 {
+			patches, err := fluxPatches(docs)
+			if err != nil {
+				return nil, err
+			}
+			yamlhelpers.AddNode([]string{"spec", "postRenderers"}, resource, &yaml.Node{
+				Kind: yaml.SequenceNode,
+				Content: []*yaml.Node{
+					{
+						Kind: yaml.MappingNode,
+						Content: []*yaml.Node{
+							{Kind: yaml.ScalarNode, Value: "kustomize"},
+							{
+								Kind: yaml.MappingNode,
+								Content: []*yaml.Node{
+									{Kind: yaml.ScalarNode, Value: "patches"},
+									patches,
+								},
+							},
+						},
+					},
+				},
+			})
+			docs = nil
+		}
+	case FormatArgoCD:
+		yamlhelpers.AddNode([]string{"spec", "source", "helm", "valuesObject"}, resource, values)
+		if len(docs) > 0 {
+			slog.Warn("Argo CD can't patch the rendered chart, so the patches for the images that can't be set with values are written as separate documents")
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	for _, node := range append([]*yaml.Node{resource}, docs...) {
+		if err := enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}); err != nil {
+			return nil, fmt.Errorf("marshalling output document: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("marshalling output document: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fluxPatches returns the patches as Flux post renderer patches, which target
+// the resource they patch
+func fluxPatches(docs []*yaml.Node) (*yaml.Node, error) {
+	patches := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, doc := range docs {
+		patch, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling patch: %w", err)
+		}
+
+		target := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range []string{"kind", "name"} {
+			path := []string{key}
+			if key == "name" {
+				path = []string{"metadata", "name"}
+			}
+			if n := yamlhelpers.LookupNode(path, doc); n != nil {
+				addScalar([]string{key}, target, n.Value)
+			}
+		}
+
+		p := &yaml.Node{Kind: yaml.MappingNode}
+		yamlhelpers.AddNode([]string{"target"}, p, target)
+		yamlhelpers.AddNode([]string{"patch"}, p, &yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Style: yaml.LiteralStyle,
+			Value: strings.TrimLeft(string(patch), "\n"),
+		})
+		patches.Content = append(patches.Content, p)
+	}
+
+	return patches, nil
+}
+
+// addScalar adds a string at the path
+func addScalar(path []string, node *yaml.Node, value string) {
+	yamlhelpers.AddNode(path, node, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
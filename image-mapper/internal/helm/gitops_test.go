@@ -0,0 +1,133 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWrapValues(t *testing.T) {
+	output := `image:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+    tag: "1.25"
+---
+# The images in Deployment/example can't be set with values, so they must be patched
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+    name: example
+spec:
+    template:
+        spec:
+            containers:
+                - name: sidecar
+                  image: cgr.dev/chainguard/busybox:latest # Original: busybox
+`
+
+	testCases := []struct {
+		name    string
+		format  string
+		ropts   ReleaseOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "values",
+			format: FormatValues,
+			want:   output,
+		},
+		{
+			name:   "flux",
+			format: FormatFlux,
+			ropts:  ReleaseOptions{Name: "example", Namespace: "apps"},
+			want: `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+    name: example
+    namespace: apps
+spec:
+    values:
+        image:
+            repository: cgr.dev/chainguard/nginx # Original: nginx
+            tag: "1.25"
+    postRenderers:
+        - kustomize:
+            patches:
+                - target:
+                    kind: Deployment
+                    name: example
+                  patch: |
+                    # The images in Deployment/example can't be set with values, so they must be patched
+                    apiVersion: apps/v1
+                    kind: Deployment
+                    metadata:
+                        name: example
+                    spec:
+                        template:
+                            spec:
+                                containers:
+                                    - name: sidecar
+                                      image: cgr.dev/chainguard/busybox:latest # Original: busybox
+`,
+		},
+		{
+			name:   "argocd",
+			format: FormatArgoCD,
+			ropts:  ReleaseOptions{Name: "example", Namespace: "argocd"},
+			want: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+    name: example
+    namespace: argocd
+spec:
+    source:
+        helm:
+            valuesObject:
+                image:
+                    repository: cgr.dev/chainguard/nginx # Original: nginx
+                    tag: "1.25"
+---
+# The images in Deployment/example can't be set with values, so they must be patched
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+    name: example
+spec:
+    template:
+        spec:
+            containers:
+                - name: sidecar
+                  image: cgr.dev/chainguard/busybox:latest # Original: busybox
+`,
+		},
+		{
+			name:   "set",
+			format: FormatSet,
+			want: `--set-string image.repository=cgr.dev/chainguard/nginx
+--set-string image.tag=1.25
+`,
+		},
+		{
+			name:    "unsupported",
+			format:  "spinnaker",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := WrapValues([]byte(output), tc.format, tc.ropts)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package helm
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// globalRegistryPaths are the paths to the values that charts commonly use to
+// override the registry of all of their images
+var globalRegistryPaths = [][]string{
+	{"global", "imageRegistry"},
+	{"global", "image", "registry"},
+}
+
+// setGlobalRegistry replaces the registry values in the output with a single
+// global registry value, if the chart supports one and all the images have
+// been mapped to the same registry.
+//
+// Only the registry values are replaced. Values that include the registry in
+// the repository, like repository: cgr.dev/chainguard/nginx, are left alone.
+func setGlobalRegistry(keys Keys, inputs []valuesInput, output *yaml.Node) {
+	// Find the global registry value in the top level values
+	var (
+		globalPath []string
+		original   *yaml.Node
+	)
+	for _, input := range inputs {
+		if len(input.yamlPath) > 0 {
+			continue
+		}
+		for _, path := range globalRegistryPaths {
+			if node := yamlhelpers.LookupNode(path, input.node); node != nil && node.Kind == yaml.ScalarNode {
+				globalPath, original = path, node
+			}
+		}
+	}
+	if globalPath == nil {
+		slog.Warn(fmt.Sprintf("there's no global registry value, like %s, so the registry has been set for each image", formatPaths(globalRegistryPaths)))
+		return
+	}
+
+	// Find all the registry values in the output
+	var (
+		registries []string
+		paths      [][]string
+	)
+	_ = yamlhelpers.WalkNode(output, func(path []string, node *yaml.Node) error {
+		if len(path) == 0 || node.Kind != yaml.ScalarNode {
+			return nil
+		}
+		if keys.part(path[:len(path)-1], path[len(path)-1]) != keyRegistry {
+			return nil
+		}
+		if !slices.Contains(registries, node.Value) {
+			registries = append(registries, node.Value)
+		}
+		paths = append(paths, slices.Clone(path))
+
+		return nil
+	})
+	if len(registries) == 0 {
+		return
+	}
+	if len(registries) > 1 {
+		slog.Warn("the images have been mapped to different registries, so the registry has been set for each image", "registries", registries)
+		return
+	}
+
+	for _, path := range paths {
+		yamlhelpers.DeleteNode(path, output)
+	}
+
+	node := &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!str",
+		Value: registries[0],
+	}
+	if original.Value != "" && original.Value != node.Value {
+		node.LineComment = fmt.Sprintf("Original: %s", original.Value)
+	}
+	yamlhelpers.AddNode(globalPath, output, node)
+}
+
+// formatPaths formats paths in the values for a log message
+func formatPaths(paths [][]string) string {
+	var formatted []string
+	for _, path := range paths {
+		formatted = append(formatted, strings.Join(path, "."))
+	}
+
+	return strings.Join(formatted, " or ")
+}
@@ -0,0 +1,206 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"gopkg.in/yaml.v3"
+)
+
+// Helmfile is the subset of a helmfile.yaml that describes the charts to
+// install
+type Helmfile struct {
+	Repositories []HelmfileRepository `yaml:"repositories"`
+	Releases     []HelmfileRelease    `yaml:"releases"`
+}
+
+// HelmfileRepository is a chart repository in a helmfile.yaml
+type HelmfileRepository struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	OCI  bool   `yaml:"oci"`
+}
+
+// HelmfileRelease is a release in a helmfile.yaml
+type HelmfileRelease struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+	Chart     string `yaml:"chart"`
+	Version   string `yaml:"version"`
+	Installed *bool  `yaml:"installed"`
+
+	// Values are paths to values files, or inline values
+	Values []any `yaml:"values"`
+}
+
+// ReleaseValues are the mapped values for a release in a helmfile.yaml
+type ReleaseValues struct {
+	Name      string
+	Namespace string
+	Values    []byte
+}
+
+// ReadHelmfile parses a helmfile.yaml. Helmfiles that use templating must be
+// rendered first, with 'helmfile build'.
+func ReadHelmfile(input []byte) (*Helmfile, error) {
+	// 'helmfile build' outputs a document per helmfile, so combine the
+	// releases from all of them
+	hf := &Helmfile{}
+	dec := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var doc Helmfile
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("unmarshalling helmfile: %w", err)
+		}
+		hf.Repositories = append(hf.Repositories, doc.Repositories...)
+		hf.Releases = append(hf.Releases, doc.Releases...)
+	}
+
+	return hf, nil
+}
+
+// MapHelmfile maps the images in the chart of each release in a helmfile,
+// layering the release's values on top of the chart's values like helmfile
+// does, and returns the values that override them for each release.
+//
+// Relative paths to charts and values files are resolved from dir, which
+// should be the directory of the helmfile.yaml.
+func MapHelmfile(ctx context.Context, hf *Helmfile, dir string, copts ChartOptions, opts ...mapper.Option) ([]ReleaseValues, error) {
+	m, err := NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return mapHelmfile(ctx, m, hf, dir, copts), nil
+}
+
+// mapHelmfile maps the images in the chart of each release with the provided
+// mapper. Releases that can't be mapped are skipped.
+func mapHelmfile(ctx context.Context, m mapper.Mapper, hf *Helmfile, dir string, copts ChartOptions) []ReleaseValues {
+	var results []ReleaseValues
+	for _, release := range hf.Releases {
+		if release.Installed != nil && !*release.Installed {
+			continue
+		}
+
+		values, err := mapRelease(ctx, m, hf, release, dir, copts)
+		if err != nil {
+			slog.Warn("skipping release", "release", release.Name, "err", err)
+			continue
+		}
+
+		results = append(results, ReleaseValues{
+			Name:      release.Name,
+			Namespace: release.Namespace,
+			Values:    values,
+		})
+	}
+
+	return results
+}
+
+// mapRelease maps the images in the chart of a release
+func mapRelease(ctx context.Context, m mapper.Mapper, hf *Helmfile, release HelmfileRelease, dir string, copts ChartOptions) ([]byte, error) {
+	tmp, err := os.MkdirTemp("", "")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	chartPath, err := fetchChart(ctx, releaseChart(hf, release, dir), tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	// The release's values are layered on top of any values files the
+	// user has provided
+	valuesFiles, err := releaseValues(release, dir, tmp)
+	if err != nil {
+		return nil, err
+	}
+	copts.ValuesFiles = slices.Concat(copts.ValuesFiles, valuesFiles)
+	if copts.ReleaseName == "" || copts.ReleaseName == "release-name" {
+		copts.ReleaseName = release.Name
+	}
+
+	return mapChart(m, chartPath, copts)
+}
+
+// releaseChart resolves the chart of a release. Charts in the form
+// <repo>/<chart> are resolved against the repositories in the helmfile, so
+// they don't need to be added with 'helm repo add'.
+func releaseChart(hf *Helmfile, release HelmfileRelease, dir string) ChartDescriptor {
+	chart := ChartDescriptor{
+		Name:    release.Chart,
+		Version: release.Version,
+	}
+
+	// Local charts are relative to the helmfile
+	if strings.HasPrefix(release.Chart, ".") {
+		chart.Name = filepath.Join(dir, release.Chart)
+		return chart
+	}
+
+	repoName, chartName, ok := strings.Cut(release.Chart, "/")
+	if !ok {
+		return chart
+	}
+	for _, repo := range hf.Repositories {
+		if repo.Name != repoName {
+			continue
+		}
+		if repo.OCI {
+			chart.Name = fmt.Sprintf("oci://%s/%s", strings.TrimSuffix(repo.URL, "/"), chartName)
+			return chart
+		}
+		chart.Name = chartName
+		chart.Repository = repo.URL
+		return chart
+	}
+
+	return chart
+}
+
+// releaseValues returns the paths to the values files of a release. Inline
+// values are written to files in tmp.
+func releaseValues(release HelmfileRelease, dir, tmp string) ([]string, error) {
+	var paths []string
+	for i, v := range release.Values {
+		switch v := v.(type) {
+		case string:
+			// Templated values can only be rendered by helmfile
+			if strings.HasSuffix(v, ".gotmpl") {
+				slog.Warn("skipping templated values file, run 'helmfile build' or 'helmfile write-values' to render it", "release", release.Name, "path", v)
+				continue
+			}
+			if !filepath.IsAbs(v) {
+				v = filepath.Join(dir, v)
+			}
+			paths = append(paths, v)
+		case map[string]any:
+			data, err := yaml.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("marshalling inline values: %w", err)
+			}
+			path := filepath.Join(tmp, fmt.Sprintf("values-%d.yaml", i))
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				return nil, fmt.Errorf("writing inline values: %w", err)
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
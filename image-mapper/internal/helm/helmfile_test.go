@@ -0,0 +1,87 @@
+package helm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMapHelmfile(t *testing.T) {
+	data, err := os.ReadFile("testdata/helmfile/helmfile.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error reading helmfile: %s", err)
+	}
+	hf, err := ReadHelmfile(data)
+	if err != nil {
+		t.Fatalf("unexpected error parsing helmfile: %s", err)
+	}
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25",
+			},
+			"docker.io/library/nginx:1.27": {
+				"cgr.dev/chainguard/nginx:1.27",
+			},
+			"busybox:1.37": {
+				"cgr.dev/chainguard/busybox:1.37",
+			},
+		},
+	}
+
+	got := mapHelmfile(t.Context(), m, hf, "testdata/helmfile", ChartOptions{Render: true})
+
+	// The release's values take precedence over the chart's
+	want := []ReleaseValues{
+		{
+			Name:      "web",
+			Namespace: "frontend",
+			Values: []byte(`image:
+    repository: cgr.dev/chainguard/nginx # Original: docker.io/library/nginx
+sidecarImage: cgr.dev/chainguard/busybox:1.37 # Original: busybox:1.37
+`),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected values (-want +got):\n%s", diff)
+	}
+}
+
+func TestReleaseChart(t *testing.T) {
+	hf := &Helmfile{
+		Repositories: []HelmfileRepository{
+			{Name: "argo", URL: "https://argoproj.github.io/argo-helm"},
+			{Name: "ghcr", URL: "ghcr.io/example/charts/", OCI: true},
+		},
+	}
+
+	testCases := []struct {
+		chart string
+		want  ChartDescriptor
+	}{
+		{
+			chart: "argo/argo-cd",
+			want:  ChartDescriptor{Name: "argo-cd", Repository: "https://argoproj.github.io/argo-helm", Version: "1.0.0"},
+		},
+		{
+			chart: "ghcr/example",
+			want:  ChartDescriptor{Name: "oci://ghcr.io/example/charts/example", Version: "1.0.0"},
+		},
+		{
+			chart: "./charts/local",
+			want:  ChartDescriptor{Name: "helmfiles/charts/local", Version: "1.0.0"},
+		},
+		{
+			chart: "other/chart",
+			want:  ChartDescriptor{Name: "other/chart", Version: "1.0.0"},
+		},
+	}
+	for _, tc := range testCases {
+		got := releaseChart(hf, HelmfileRelease{Chart: tc.chart, Version: "1.0.0"}, "helmfiles")
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("unexpected chart for %s (-want +got):\n%s", tc.chart, diff)
+		}
+	}
+}
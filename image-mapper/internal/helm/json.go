@@ -0,0 +1,120 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// isJSON returns true if the values are a JSON object. Helm accepts JSON values
+// files, because JSON is mostly YAML, but some JSON, like the \/ escape, isn't.
+func isJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) && json.Valid(data)
+}
+
+// parseJSON parses JSON values into a *yaml.Node. The lines and columns of the
+// nodes are set, so that the values can be edited in place. Objects and arrays
+// are left in the block style, so that the values copied from them are output
+// like the values copied from YAML.
+func parseJSON(data []byte) (*yaml.Node, error) {
+	p := &jsonParser{
+		data: data,
+		dec:  json.NewDecoder(bytes.NewReader(data)),
+	}
+	p.dec.UseNumber()
+
+	return p.parse()
+}
+
+// jsonParser builds nodes from the tokens of a JSON document
+type jsonParser struct {
+	data []byte
+	dec  *json.Decoder
+}
+
+// parse returns the next value in the document as a node
+func (p *jsonParser) parse() (*yaml.Node, error) {
+	line, column := p.position()
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	node := &yaml.Node{Line: line, Column: column}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		node.Kind, node.Tag = yaml.MappingNode, "!!map"
+		if v == '[' {
+			node.Kind, node.Tag = yaml.SequenceNode, "!!seq"
+		}
+		for p.dec.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := p.parse()
+				if err != nil {
+					return nil, err
+				}
+				key.Style = 0
+				node.Content = append(node.Content, key)
+			}
+			value, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		// The closing delimiter
+		if _, err := p.dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		node.Kind, node.Tag, node.Value, node.Style = yaml.ScalarNode, "!!str", v, yaml.DoubleQuotedStyle
+	case json.Number:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!int", v.String()
+		if _, err := v.Int64(); err != nil {
+			node.Tag = "!!float"
+		}
+	case bool:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!bool", fmt.Sprint(v)
+	case nil:
+		node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!null", "null"
+	}
+
+	return node, nil
+}
+
+// position returns the line and column of the next token. The decoder's offset
+// is the end of the previous token, so the whitespace and separators after it
+// are skipped.
+func (p *jsonParser) position() (int, int) {
+	offset := int(p.dec.InputOffset())
+	for offset < len(p.data) && bytes.IndexByte([]byte(" \t\r\n,:"), p.data[offset]) >= 0 {
+		offset++
+	}
+
+	line := bytes.Count(p.data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(p.data[:offset], '\n')
+
+	return line, column
+}
+
+// decodeValues parses YAML or JSON values and returns the root node of each
+// document in them
+func decodeValues(data []byte) ([]*yaml.Node, error) {
+	if isJSON(data) {
+		node, err := parseJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshalling json: %w", err)
+		}
+		return []*yaml.Node{node}, nil
+	}
+
+	docs, err := yamlhelpers.Documents(data)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling yaml: %w", err)
+	}
+
+	return docs, nil
+}
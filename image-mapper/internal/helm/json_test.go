@@ -0,0 +1,117 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMapValuesJSON(t *testing.T) {
+	// The \/ escape is valid JSON, but not valid YAML
+	input := []byte(`{
+	"replicaCount": 1,
+	"url": "https:\/\/example.com",
+	"image": {
+		"repository": "nginx",
+		"tag": "1.25",
+		"digest": "sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a"
+	},
+	"sidecars": [{"name": "shell", "image": "busybox:1.36"}]
+}
+`)
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25.5",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name  string
+		vopts ValuesOptions
+		want  string
+	}{
+		{
+			name: "values",
+			want: `image:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+    tag: 1.25.5 # Original: 1.25
+    digest: "" # Original: sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a
+sidecars:
+    - name: "shell"
+      image: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+`,
+		},
+		{
+			name:  "full",
+			vopts: ValuesOptions{Full: true},
+			want: `{
+	"replicaCount": 1,
+	"url": "https:\/\/example.com",
+	"image": {
+		"repository": "cgr.dev/chainguard/nginx",
+		"tag": "1.25.5",
+		"digest": ""
+	},
+	"sidecars": [{"name": "shell", "image": "cgr.dev/chainguard/busybox:1.36"}]
+}
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mapValues(m, input, tc.vopts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("unexpected output:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	node, err := parseJSON([]byte("{\n  \"a\": [true, null, 1.5],\n  \"b\": {\"c\": \"d\"}\n}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	type scalar struct {
+		Tag    string
+		Value  string
+		Line   int
+		Column int
+	}
+	var got []scalar
+	for _, n := range []*yaml.Node{
+		node.Content[0],
+		node.Content[1].Content[0],
+		node.Content[1].Content[1],
+		node.Content[1].Content[2],
+		node.Content[2],
+		node.Content[3].Content[0],
+		node.Content[3].Content[1],
+	} {
+		got = append(got, scalar{Tag: n.ShortTag(), Value: n.Value, Line: n.Line, Column: n.Column})
+	}
+
+	want := []scalar{
+		{Tag: "!!str", Value: "a", Line: 2, Column: 3},
+		{Tag: "!!bool", Value: "true", Line: 2, Column: 9},
+		{Tag: "!!null", Value: "null", Line: 2, Column: 15},
+		{Tag: "!!float", Value: "1.5", Line: 2, Column: 21},
+		{Tag: "!!str", Value: "b", Line: 3, Column: 3},
+		{Tag: "!!str", Value: "c", Line: 3, Column: 9},
+		{Tag: "!!str", Value: "d", Line: 3, Column: 14},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected nodes (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,160 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The parts of an image reference that a key in a values map can hold
+const (
+	keyImage      = "image"
+	keyName       = "name"
+	keyRepository = "repository"
+	keyRegistry   = "registry"
+	keyTag        = "tag"
+	keyDigest     = "digest"
+)
+
+// Keys are additional patterns that match keys in the values that hold parts
+// of an image reference, on top of the default keys (image, name, repository,
+// registry, tag and digest).
+//
+// A pattern without dots matches the name of a key anywhere in the values and
+// can include wildcards, i.e '*Image' matches 'controllerImage'.
+//
+// A pattern with dots matches the end of the path to a key, i.e
+// 'sidecar.image.repositoryOverride'. Each part of the path can include
+// wildcards.
+type Keys struct {
+	Image      []string `yaml:"image"`
+	Name       []string `yaml:"name"`
+	Repository []string `yaml:"repository"`
+	Registry   []string `yaml:"registry"`
+	Tag        []string `yaml:"tag"`
+	Digest     []string `yaml:"digest"`
+}
+
+// Add adds a pattern for a part of the image reference, which is one of
+// image, name, repository, registry, tag or digest
+func (k *Keys) Add(part, pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern: %s: %w", pattern, err)
+	}
+
+	switch part {
+	case keyImage:
+		k.Image = append(k.Image, pattern)
+	case keyName:
+		k.Name = append(k.Name, pattern)
+	case keyRepository:
+		k.Repository = append(k.Repository, pattern)
+	case keyRegistry:
+		k.Registry = append(k.Registry, pattern)
+	case keyTag:
+		k.Tag = append(k.Tag, pattern)
+	case keyDigest:
+		k.Digest = append(k.Digest, pattern)
+	default:
+		return fmt.Errorf("unknown key %q, must be one of image, name, repository, registry, tag or digest", part)
+	}
+
+	return nil
+}
+
+// ReadKeysFile reads key patterns from a YAML file with a list of patterns for
+// each part of the image reference, i.e:
+//
+//	image:
+//	  - imageName
+//	  - '*Image'
+//	repository:
+//	  - sidecar.image.repositoryOverride
+func ReadKeysFile(path string) (Keys, error) {
+	var keys Keys
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return keys, fmt.Errorf("reading file: %w", err)
+	}
+
+	var patterns map[string][]string
+	if err := yaml.Unmarshal(data, &patterns); err != nil {
+		return keys, fmt.Errorf("unmarshalling yaml: %w", err)
+	}
+	for part, ps := range patterns {
+		for _, p := range ps {
+			if err := keys.Add(part, p); err != nil {
+				return keys, err
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// part returns the part of the image reference that the key at the path
+// holds, or an empty string if it isn't an image related key.
+//
+// The default keys take precedence over the patterns, and the patterns take
+// precedence over the conventions of well-known charts. The patterns are
+// checked in the order digest, registry, repository, tag, name and then image, so
+// that the more specific parts win when patterns overlap.
+func (k Keys) part(yamlPath []string, key string) string {
+	switch key {
+	case keyImage, keyName, keyRepository, keyRegistry, keyTag, keyDigest:
+		return key
+	}
+
+	if part := k.match(yamlPath, key); part != "" {
+		return part
+	}
+
+	return conventionKeys.match(yamlPath, key)
+}
+
+// match returns the part of the image reference of the first pattern that
+// matches the key at the path
+func (k Keys) match(yamlPath []string, key string) string {
+	for _, candidate := range []struct {
+		part     string
+		patterns []string
+	}{
+		{keyDigest, k.Digest},
+		{keyRegistry, k.Registry},
+		{keyRepository, k.Repository},
+		{keyTag, k.Tag},
+		{keyName, k.Name},
+		{keyImage, k.Image},
+	} {
+		for _, pattern := range candidate.patterns {
+			if matchKey(pattern, yamlPath, key) {
+				return candidate.part
+			}
+		}
+	}
+
+	return ""
+}
+
+// matchKey matches a pattern against the key at the path
+func matchKey(pattern string, yamlPath []string, key string) bool {
+	segments := strings.Split(pattern, ".")
+	full := slices.Concat(yamlPath, []string{key})
+	if len(segments) > len(full) {
+		return false
+	}
+
+	full = full[len(full)-len(segments):]
+	for i, segment := range segments {
+		if ok, _ := path.Match(segment, full[i]); !ok {
+			return false
+		}
+	}
+
+	return true
+}
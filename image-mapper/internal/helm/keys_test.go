@@ -0,0 +1,105 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestKeysPart(t *testing.T) {
+	keys := Keys{
+		Image:      []string{"*Image", "imageName"},
+		Repository: []string{"sidecar.image.repositoryOverride"},
+		Registry:   []string{"*.imageRegistry"},
+		Tag:        []string{"imageTag"},
+	}
+
+	testCases := []struct {
+		path []string
+		key  string
+		want string
+	}{
+		{key: "image", want: keyImage},
+		{key: "repository", want: keyRepository},
+		{key: "controllerImage", want: keyImage},
+		{path: []string{"foo"}, key: "imageName", want: keyImage},
+		{key: "imageTag", want: keyTag},
+		{path: []string{"sidecar", "image"}, key: "repositoryOverride", want: keyRepository},
+		{path: []string{"parent", "sidecar", "image"}, key: "repositoryOverride", want: keyRepository},
+		{path: []string{"other", "image"}, key: "repositoryOverride", want: ""},
+		{path: []string{"global"}, key: "imageRegistry", want: keyRegistry},
+		{key: "imageRegistry", want: ""},
+		{key: "replicas", want: ""},
+
+		// The conventions of well-known charts are matched without
+		// patterns
+		{path: []string{"image"}, key: "sha", want: keyDigest},
+		{path: []string{"prometheusOperator", "thanosImage"}, key: "sha", want: keyDigest},
+		{path: []string{"controller", "image"}, key: "digestChroot", want: keyDigest},
+		{path: []string{"git"}, key: "sha", want: ""},
+	}
+	for _, tc := range testCases {
+		if got := keys.part(tc.path, tc.key); got != tc.want {
+			t.Errorf("unexpected part for %v %s: wanted %q but got %q", tc.path, tc.key, tc.want, got)
+		}
+	}
+}
+
+func TestKeysAdd(t *testing.T) {
+	var keys Keys
+	if err := keys.Add("image", "*Image"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := keys.Add("tag", "imageTag"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := keys.Add("version", "imageVersion"); err == nil {
+		t.Errorf("expected error for unknown part")
+	}
+	if err := keys.Add("image", "[Image"); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+
+	want := Keys{
+		Image: []string{"*Image"},
+		Tag:   []string{"imageTag"},
+	}
+	if diff := cmp.Diff(want, keys); diff != "" {
+		t.Errorf("unexpected keys (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(path, []byte(`
+image:
+  - imageName
+  - '*Image'
+repository:
+  - sidecar.image.repositoryOverride
+`), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	got, err := ReadKeysFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := Keys{
+		Image:      []string{"imageName", "*Image"},
+		Repository: []string{"sidecar.image.repositoryOverride"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected keys (-want +got):\n%s", diff)
+	}
+
+	if err := os.WriteFile(path, []byte("version: [imageVersion]\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	if _, err := ReadKeysFile(path); err == nil {
+		t.Errorf("expected error for unknown part")
+	}
+}
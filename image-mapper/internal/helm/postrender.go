@@ -0,0 +1,46 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+)
+
+// PostRender maps the images in the manifests rendered by Helm to Chainguard
+// and returns the rewritten manifests, so that it can be used as a Helm
+// post-renderer
+func PostRender(ctx context.Context, input []byte, opts ...mapper.Option) ([]byte, error) {
+	m, err := NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return postRender(m, input)
+}
+
+// postRender rewrites the images in the manifests with the provided mapper.
+// Images that can't be mapped are left as they are.
+func postRender(m mapper.Mapper, input []byte) ([]byte, error) {
+	// The same image is often used by several resources, so only map
+	// each one once
+	mapped := map[string]string{}
+
+	return manifest.RewriteImages(input, func(img manifest.Image) (string, bool) {
+		if ref, ok := mapped[img.Image]; ok {
+			return ref, ref != ""
+		}
+
+		ref, err := mapper.MapImage(m, img.Image)
+		if err != nil {
+			slog.Warn("error mapping image", "image", img.Image, "err", err)
+			mapped[img.Image] = ""
+			return "", false
+		}
+		mapped[img.Image] = ref.String()
+
+		return ref.String(), true
+	})
+}
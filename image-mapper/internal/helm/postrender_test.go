@@ -0,0 +1,80 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPostRender(t *testing.T) {
+	input := []byte(`---
+# Source: example/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: release-name-example
+spec:
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: "nginx:1.25"
+        - name: unknown
+          image: example/unknown:1.0
+---
+# Source: example/templates/job.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: release-name-setup
+spec:
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:1.25
+`)
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25",
+			},
+		},
+	}
+
+	got, err := postRender(m, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `# Source: example/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: release-name-example
+spec:
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: "cgr.dev/chainguard/nginx:1.25"
+        - name: unknown
+          image: example/unknown:1.0
+---
+# Source: example/templates/job.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: release-name-setup
+spec:
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: cgr.dev/chainguard/nginx:1.25
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,329 @@
+package helm
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+// mapTemplates renders the chart's templates with the mapped values and looks
+// for images that haven't changed, which means the values don't cover them.
+//
+// For each of those images, it looks for values that contain the image and
+// adds them to the output values. If it can't find any, it returns a strategic
+// merge patch that replaces the image in the rendered resource instead.
+func mapTemplates(m mapper.Mapper, chartDir string, opts ChartOptions, inputs []valuesInput, output *yaml.Node) ([]*yaml.Node, error) {
+	before, err := renderImages(chartDir, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := renderImages(chartDir, opts, output)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look for values that set the images that weren't covered and
+	// render again to see if they did the job
+	for _, img := range unchangedImages(m, before, after) {
+		suggestValues(inputs, img.image.Image, img.mapped, output)
+	}
+	after, err = renderImages(chartDir, opts, output)
+	if err != nil {
+		return nil, err
+	}
+
+	return patchImages(unchangedImages(m, before, after)), nil
+}
+
+// findChartDir returns the directory of the chart in the path. Pulled charts
+// are extracted into a subdirectory.
+func findChartDir(path string) (string, error) {
+	if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err == nil {
+		return path, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", fmt.Errorf("reading directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(path, entry.Name(), "Chart.yaml")); err == nil {
+			return filepath.Join(path, entry.Name()), nil
+		}
+	}
+
+	return "", fmt.Errorf("no chart found in %s", path)
+}
+
+// ChartImages renders the templates of the chart in the directory with its
+// default values and returns the images in the resulting resources, in the
+// order they appear
+func ChartImages(chartDir string) ([]string, error) {
+	imgs, err := renderImages(chartDir, ChartOptions{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]string, 0, len(imgs))
+	for _, img := range imgs {
+		images = append(images, img.Image)
+	}
+
+	return images, nil
+}
+
+// renderImages renders the chart's templates and returns the images in the
+// resulting resources. If overrides are provided, they're applied on top of
+// the values.
+func renderImages(chartDir string, opts ChartOptions, overrides *yaml.Node) ([]manifest.Image, error) {
+	// Processing the dependencies modifies the chart, so load a fresh
+	// copy for each render
+	chrt, err := loader.Load(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading chart: %w", err)
+	}
+
+	vals, err := (&values.Options{ValueFiles: opts.ValuesFiles}).MergeValues(getter.Providers{})
+	if err != nil {
+		return nil, fmt.Errorf("reading values: %w", err)
+	}
+
+	if overrides != nil {
+		data, err := yaml.Marshal(overrides)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling values: %w", err)
+		}
+		overrideVals, err := chartutil.ReadValues(data)
+		if err != nil {
+			return nil, fmt.Errorf("reading values: %w", err)
+		}
+		vals = chartutil.CoalesceTables(overrideVals, vals)
+	}
+
+	// Drop the dependencies that are disabled by their conditions or
+	// tags and apply the aliases of the others
+	if err := chartutil.ProcessDependenciesWithMerge(chrt, vals); err != nil {
+		return nil, fmt.Errorf("processing dependencies: %w", err)
+	}
+
+	releaseName := opts.ReleaseName
+	if releaseName == "" {
+		releaseName = "release-name"
+	}
+	renderVals, err := chartutil.ToRenderValues(chrt, vals, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: "default",
+		Revision:  1,
+		IsInstall: true,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("composing values: %w", err)
+	}
+
+	rendered, err := engine.Render(chrt, renderVals)
+	if err != nil {
+		return nil, fmt.Errorf("rendering templates: %w", err)
+	}
+
+	// Sort the templates so the results are consistent
+	var templates []string
+	for name := range rendered {
+		templates = append(templates, name)
+	}
+	slices.Sort(templates)
+
+	var images []manifest.Image
+	for _, name := range templates {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			continue
+		}
+
+		imgs, err := manifest.Images([]byte(rendered[name]))
+		if err != nil {
+			slog.Warn("skipping template", "template", name, "err", err)
+			continue
+		}
+		images = append(images, imgs...)
+	}
+
+	return images, nil
+}
+
+// unchangedImage is an image that should have been mapped, but wasn't
+type unchangedImage struct {
+	image  manifest.Image
+	mapped string
+}
+
+// unchangedImages returns the images that are the same before and after the
+// values are mapped, but that could be mapped to Chainguard
+func unchangedImages(m mapper.Mapper, before, after []manifest.Image) []unchangedImage {
+	var unchanged []unchangedImage
+	for _, a := range after {
+		i := slices.IndexFunc(before, func(b manifest.Image) bool {
+			return b.Kind == a.Kind && b.Name == a.Name && b.Container == a.Container && slices.Equal(b.Path, a.Path)
+		})
+		if i < 0 || before[i].Image != a.Image {
+			continue
+		}
+
+		mapped, err := mapper.MapImage(m, a.Image)
+		if err != nil {
+			slog.Warn("error mapping image", "image", a.Image, "err", err)
+			continue
+		}
+		if mapped.String() == a.Image {
+			continue
+		}
+
+		unchanged = append(unchanged, unchangedImage{image: a, mapped: mapped.String()})
+	}
+
+	return unchanged
+}
+
+// suggestValues looks for string values that contain the image, or its
+// repository, and adds them to the output with the mapped image
+func suggestValues(inputs []valuesInput, img, mapped string, output *yaml.Node) {
+	repo, tag := splitImage(img)
+	mappedRepo, mappedTag := splitImage(mapped)
+
+	for _, input := range inputs {
+		_ = yamlhelpers.WalkNode(input.node, func(path []string, node *yaml.Node) error {
+			if node.Kind != yaml.MappingNode {
+				return nil
+			}
+
+			// Look for the image, or the repository and tag, in
+			// the keys of the map
+			var repoKey, tagKey *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if value.Kind != yaml.ScalarNode {
+					continue
+				}
+
+				switch value.Value {
+				case img:
+					addValue(input, slices.Concat(path, []string{key.Value}), output, value.Value, mapped)
+				case repo:
+					repoKey = key
+				case tag:
+					tagKey = key
+				}
+			}
+			if repoKey == nil {
+				return nil
+			}
+
+			addValue(input, slices.Concat(path, []string{repoKey.Value}), output, repo, mappedRepo)
+			if tagKey != nil && tag != "" && mappedTag != "" {
+				addValue(input, slices.Concat(path, []string{tagKey.Value}), output, tag, mappedTag)
+			}
+
+			return nil
+		})
+	}
+}
+
+// addValue adds a value to the output values at the path of the input values,
+// with a comment recording the original value
+func addValue(input valuesInput, path []string, output *yaml.Node, original, value string) {
+	node := &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!str",
+		Value: value,
+	}
+	if original != value {
+		node.LineComment = fmt.Sprintf("Original: %s", original)
+	}
+
+	addOutput(input, path, output, node)
+}
+
+// splitImage splits an image reference into the repository and tag. The digest
+// is dropped.
+func splitImage(img string) (string, string) {
+	img, _, _ = strings.Cut(img, "@")
+
+	// A colon after the last slash separates the tag. Anything
+	// before that is part of the registry host.
+	if i := strings.LastIndex(img, ":"); i > strings.LastIndex(img, "/") {
+		return img[:i], img[i+1:]
+	}
+
+	return img, ""
+}
+
+// patchImages returns strategic merge patches that replace the images in the
+// resources. Only containers in a list, that are merged by name, can be
+// patched.
+func patchImages(images []unchangedImage) []*yaml.Node {
+	var (
+		patches []*yaml.Node
+		keys    []string
+	)
+	byResource := map[string]*yaml.Node{}
+	for _, u := range images {
+		img := u.image
+		key := fmt.Sprintf("%s/%s", img.Kind, img.Name)
+		if img.Container == "" || slices.ContainsFunc(img.Path, func(element string) bool {
+			_, ok := yamlhelpers.ParseIndex(element)
+			return ok
+		}) {
+			slog.Warn("can't patch the image, it must be set with values", "image", img.Image, "resource", key)
+			continue
+		}
+
+		patch, ok := byResource[key]
+		if !ok {
+			patch = &yaml.Node{
+				Kind:        yaml.MappingNode,
+				HeadComment: fmt.Sprintf("The images in %s can't be set with values, so they must be patched", key),
+			}
+			yamlhelpers.AddNode([]string{"apiVersion"}, patch, &yaml.Node{Kind: yaml.ScalarNode, Value: img.APIVersion})
+			yamlhelpers.AddNode([]string{"kind"}, patch, &yaml.Node{Kind: yaml.ScalarNode, Value: img.Kind})
+			yamlhelpers.AddNode([]string{"metadata", "name"}, patch, &yaml.Node{Kind: yaml.ScalarNode, Value: img.Name})
+			byResource[key] = patch
+			keys = append(keys, key)
+		}
+
+		containers := yamlhelpers.LookupNode(img.Path, patch)
+		if containers == nil {
+			containers = &yaml.Node{Kind: yaml.SequenceNode}
+			yamlhelpers.AddNode(img.Path, patch, containers)
+		}
+		containers.Content = append(containers.Content, &yaml.Node{
+			Kind: yaml.MappingNode,
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: "name"},
+				{Kind: yaml.ScalarNode, Value: img.Container},
+				{Kind: yaml.ScalarNode, Value: "image"},
+				{Kind: yaml.ScalarNode, Value: u.mapped, LineComment: fmt.Sprintf("Original: %s", img.Image)},
+			},
+		})
+	}
+
+	for _, key := range keys {
+		patches = append(patches, byResource[key])
+	}
+
+	return patches
+}
@@ -0,0 +1,69 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setArgs returns the values as --set arguments, one per line. Strings are set
+// with --set-string, so that tags like 1.25 aren't read as numbers, and empty
+// maps and lists with --set-json.
+func setArgs(values *yaml.Node) []byte {
+	var buf bytes.Buffer
+	var walk func(key string, node *yaml.Node)
+	walk = func(key string, node *yaml.Node) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			if len(node.Content) == 0 && key != "" {
+				fmt.Fprintf(&buf, "--set-json %s\n", shellQuote(key+"={}"))
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				k := setKeyEscaper.Replace(node.Content[i].Value)
+				if key != "" {
+					k = key + "." + k
+				}
+				walk(k, node.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			if len(node.Content) == 0 {
+				fmt.Fprintf(&buf, "--set-json %s\n", shellQuote(key+"=[]"))
+			}
+			for i, item := range node.Content {
+				walk(fmt.Sprintf("%s[%d]", key, i), item)
+			}
+		case yaml.AliasNode:
+			walk(key, node.Alias)
+		case yaml.ScalarNode:
+			flag := "--set"
+			if node.ShortTag() == "!!str" {
+				flag = "--set-string"
+			}
+			fmt.Fprintf(&buf, "%s %s\n", flag, shellQuote(key+"="+setValueEscaper.Replace(node.Value)))
+		}
+	}
+	walk("", values)
+
+	return buf.Bytes()
+}
+
+var (
+	// setKeyEscaper escapes the characters that separate the parts of a
+	// key in a --set argument
+	setKeyEscaper = strings.NewReplacer(`\`, `\\`, `.`, `\.`, `,`, `\,`, `=`, `\=`, `[`, `\[`)
+
+	// setValueEscaper escapes the characters that separate the values in a
+	// --set argument
+	setValueEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`)
+)
+
+// shellQuote quotes the argument for a shell, if it needs to be
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@=+%") == "" {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
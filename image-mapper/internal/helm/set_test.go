@@ -0,0 +1,46 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSetArgs(t *testing.T) {
+	values, err := parseValues([]byte(`image:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+    tag: "8"
+    digest: ""
+podAnnotations:
+    example.com/image: cgr.dev/chainguard/nginx:1.25
+extraContainers:
+    - name: proxy
+      image: cgr.dev/chainguard/envoy:1.31.0
+      args: ["--log-level", "info,debug"]
+      env: []
+      ports:
+        - 8080
+      resources: {}
+      tty: true
+`))
+	if err != nil {
+		t.Fatalf("unexpected error parsing values: %s", err)
+	}
+
+	want := `--set-string image.repository=cgr.dev/chainguard/nginx
+--set-string image.tag=8
+--set-string image.digest=
+--set-string 'podAnnotations.example\.com/image=cgr.dev/chainguard/nginx:1.25'
+--set-string 'extraContainers[0].name=proxy'
+--set-string 'extraContainers[0].image=cgr.dev/chainguard/envoy:1.31.0'
+--set-string 'extraContainers[0].args[0]=--log-level'
+--set-string 'extraContainers[0].args[1]=info\,debug'
+--set-json 'extraContainers[0].env=[]'
+--set 'extraContainers[0].ports[0]=8080'
+--set-json 'extraContainers[0].resources={}'
+--set 'extraContainers[0].tty=true'
+`
+	if diff := cmp.Diff(want, string(setArgs(values))); diff != "" {
+		t.Errorf("unexpected arguments (-want +got):\n%s", diff)
+	}
+}
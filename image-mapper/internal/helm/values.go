@@ -1,60 +1,120 @@
 package helm
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
 	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
 	"gopkg.in/yaml.v3"
 )
 
+// ValuesOptions configures how values are mapped
+type ValuesOptions struct {
+	// Keys are additional patterns that match image related keys
+	Keys Keys
+
+	// AppVersion is the appVersion of the chart the values belong to.
+	// Charts typically use it as the tag when the tag in the values is
+	// empty.
+	AppVersion string
+
+	// GlobalRegistry replaces the registry values of the images with a
+	// single global registry value, like global.imageRegistry, if the
+	// values have one and all the images are mapped to the same registry
+	GlobalRegistry bool
+
+	// Full returns all of the input values with the mapped images
+	// edited in, rather than just the image related values. Everything
+	// else in the input, like comments, anchors, quoting and key order,
+	// is preserved.
+	Full bool
+}
+
 // MapValues extracts the image related values from a values file and maps them
 // to Chainguard.
-func MapValues(ctx context.Context, input []byte, opts ...mapper.Option) ([]byte, error) {
+func MapValues(ctx context.Context, input []byte, vopts ValuesOptions, opts ...mapper.Option) ([]byte, error) {
 	m, err := NewMapper(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("constructing the new mapper: %w", err)
 	}
 
-	return mapValues(m, input)
+	return mapValues(m, input, vopts)
+}
+
+// MapValuesWith maps the image related values in a values file with the
+// provided mapper, so that one mapper can be shared by many files
+func MapValuesWith(m mapper.Mapper, input []byte, vopts ValuesOptions) ([]byte, error) {
+	return mapValues(m, input, vopts)
 }
 
 // mapValues extracts the image related values from a values file and maps them
-// to Chainguard with the provided mapper
-func mapValues(m mapper.Mapper, input []byte) ([]byte, error) {
-	var inputDoc yaml.Node
-	if err := yaml.Unmarshal(input, &inputDoc); err != nil {
-		return nil, fmt.Errorf("unmarshalling yaml: %w", err)
+// to Chainguard with the provided mapper. Each document in the values is
+// mapped to a document in the output.
+func mapValues(m mapper.Mapper, input []byte, vopts ValuesOptions) ([]byte, error) {
+	inputNodes, err := decodeValues(input)
+	if err != nil {
+		return nil, err
 	}
-	if len(inputDoc.Content) == 0 {
+	if len(inputNodes) == 0 {
 		return nil, fmt.Errorf("provided input document is empty")
 	}
-	inputNode := inputDoc.Content[0]
 
-	// We'll write modified nodes to this node
-	outputNode := &yaml.Node{
-		Kind:    yaml.MappingNode,
-		Content: []*yaml.Node{},
-	}
+	var (
+		edits []yamlhelpers.Edit
+		buf   bytes.Buffer
+		enc   = yaml.NewEncoder(&buf)
+	)
+	for _, inputNode := range inputNodes {
+		// We'll write modified nodes to this node
+		outputNode := &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Content: []*yaml.Node{},
+		}
 
-	// Walk the document recursively, adding image related fields to the
-	// output node and mapping them to Chainguard images
-	if err := yamlhelpers.WalkNode(inputNode, mapNode(m, []string{}, outputNode)); err != nil {
-		return nil, fmt.Errorf("walking nodes: %w", err)
+		// Walk the document recursively, adding image related fields
+		// to the output node and mapping them to Chainguard images
+		values := valuesInput{
+			yamlPath:   []string{},
+			node:       inputNode,
+			appVersion: vopts.AppVersion,
+		}
+		if err := yamlhelpers.WalkNode(inputNode, mapNode(m, vopts.Keys, values, outputNode)); err != nil {
+			return nil, fmt.Errorf("walking nodes: %w", err)
+		}
+
+		if vopts.GlobalRegistry {
+			setGlobalRegistry(vopts.Keys, []valuesInput{values}, outputNode)
+		}
+
+		if vopts.Full {
+			collectEdits(inputNode, outputNode, &edits)
+			continue
+		}
+
+		// Marshal the modified nodes to a new document
+		doc := &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{outputNode},
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("marshalling output document: %w", err)
+		}
 	}
 
-	// Marshal the modified nodes to a new document
-	doc := &yaml.Node{
-		Kind:    yaml.DocumentNode,
-		Content: []*yaml.Node{outputNode},
+	// Edit the mapped values into the input, so that everything else
+	// in the values is left exactly as it was
+	if vopts.Full {
+		return yamlhelpers.EditScalars(input, edits), nil
 	}
-	output, err := yaml.Marshal(doc)
-	if err != nil {
+
+	if err := enc.Close(); err != nil {
 		return nil, fmt.Errorf("marshalling output document: %w", err)
 	}
 
-	return output, nil
+	return buf.Bytes(), nil
 }
 
 // mapNode returns a function that extracts image related fields from the input
@@ -83,59 +143,80 @@ func mapValues(m mapper.Mapper, input []byte) ([]byte, error) {
 //	OR
 //
 //	image: ghcr.io/foo/bar:v0.0.1
-func mapNode(m mapper.Mapper, yamlPath []string, output *yaml.Node) yamlhelpers.WalkNodeFn {
+//
+// Any digest alongside the tag is cleared, because it refers to the original
+// image.
+//
+// The keys can be extended with additional patterns.
+func mapNode(m mapper.Mapper, keys Keys, input valuesInput, output *yaml.Node) yamlhelpers.WalkNodeFn {
 	return func(path []string, value *yaml.Node) error {
+		// Extra containers that are passed as a string, to be
+		// rendered with tpl, are parsed and mapped as containers
+		if isContainerListString(path, value) {
+			mapContainerListString(m, input, path, value, output)
+			return nil
+		}
+
+		// Lists of images, like images: [foo:v1, bar:v2], are mapped
+		// item by item
+		if value.Kind == yaml.SequenceNode && isImageKey(keys, path) {
+			var items []field
+			for i, item := range value.Content {
+				item = yamlhelpers.Resolve(item)
+				items = append(items, field{
+					key: yamlhelpers.Index(i),
+					node: &yaml.Node{
+						Kind:  item.Kind,
+						Tag:   item.Tag,
+						Value: item.Value,
+					},
+				})
+			}
+			mapImages(m, items, input, path, output)
+			return nil
+		}
+
 		if value.Kind != yaml.MappingNode {
 			return nil
 		}
 
 		// Extract all the keys from the map that are typically
 		// associated with an image
-		var (
-			image      *yaml.Node
-			name       *yaml.Node
-			repository *yaml.Node
-			registry   *yaml.Node
-			tag        *yaml.Node
-		)
-		for i := 0; i < len(value.Content); i += 2 {
-			key := value.Content[i].Value
-			value := value.Content[i+1]
-
-			switch key {
-			case "image":
-				image = &yaml.Node{
-					Kind:  value.Kind,
-					Tag:   value.Tag,
-					Value: value.Value,
-				}
-			case "name":
-				name = &yaml.Node{
-					Kind:  value.Kind,
-					Tag:   value.Tag,
-					Value: value.Value,
-				}
-			case "repository":
-				repository = &yaml.Node{
-					Kind:  value.Kind,
-					Tag:   value.Tag,
-					Value: value.Value,
-				}
-			case "registry":
-				registry = &yaml.Node{
-					Kind:  value.Kind,
-					Tag:   value.Tag,
-					Value: value.Value,
-				}
-			case "tag":
-				tag = &yaml.Node{
+		fields := map[string][]field{}
+		pairs := yamlhelpers.Fields(value)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			key := pairs[i].Value
+			value := pairs[i+1]
+
+			part := keys.part(path, key)
+			if part == "" {
+				continue
+			}
+			fields[part] = append(fields[part], field{
+				key: key,
+				node: &yaml.Node{
 					Kind:  value.Kind,
 					Tag:   value.Tag,
 					Value: value.Value,
-				}
-			}
+				},
+			})
 		}
 
+		// If more than one key holds an image, i.e controllerImage and
+		// sidecarImage, then we can't tell which image the other keys
+		// belong to, so map each of them as a full image reference.
+		if len(fields[keyImage]) > 1 {
+			mapImages(m, fields[keyImage], input, path, output)
+			return nil
+		}
+
+		image, imageKey := firstField(fields, keyImage)
+		name, nameKey := firstField(fields, keyName)
+		repository, repositoryKey := firstField(fields, keyRepository)
+		registry, registryKey := firstField(fields, keyRegistry)
+		tag, tagKey := firstField(fields, keyTag)
+		digest, digestKey := firstField(fields, keyDigest)
+
 		// If we don't have one of repository, name or image then we
 		// have no chance of figuring out the image mapping and we'll
 		// skip over it.
@@ -147,10 +228,15 @@ func mapNode(m mapper.Mapper, yamlPath []string, output *yaml.Node) yamlhelpers.
 		// an image, so ignore maps with keys called 'name' unless
 		// there are other signals that this is an image reference.
 		//
-		// For instance, if the map key is 'image', or we have a
-		// registry/tag alongside the name.
-		if hasValue(name) && !(path[len(path)-1] == "image" || registry != nil || tag != nil) {
-			return nil
+		// For instance, if the map key holds an image, or we have a
+		// registry/tag alongside the name. If there's an image or
+		// repository alongside it, like in a list of containers, then
+		// the name is just the name of the thing that uses the image.
+		if hasValue(name) && !(isImageKey(keys, path) || registry != nil || tag != nil) {
+			if !(hasValue(image) || hasValue(repository)) {
+				return nil
+			}
+			name = nil
 		}
 
 		// Construct the image reference based on the fields
@@ -175,6 +261,19 @@ func mapNode(m mapper.Mapper, yamlPath []string, output *yaml.Node) yamlhelpers.
 		// Map the constructed image reference to the equivalent
 		// Chainguard image
 		mapping, err := mapper.MapImage(m, img)
+
+		// Charts typically fall back to the appVersion when the tag is
+		// empty, so map that version instead. If it can't be mapped,
+		// then stick with the mapping of the repository.
+		defaultTag := tag != nil && !hasValue(tag) && input.appVersion != ""
+		if defaultTag {
+			if versioned, verr := mapper.MapImage(m, fmt.Sprintf("%s:%s", img, input.appVersion)); verr == nil {
+				mapping, err = versioned, nil
+			} else {
+				defaultTag = false
+			}
+		}
+
 		if err == nil {
 			// Modify the values to follow the mapped image. This
 			// will ignore nodes that are nil.
@@ -206,6 +305,20 @@ func mapNode(m mapper.Mapper, yamlPath []string, output *yaml.Node) yamlhelpers.
 			if hasValue(tag) && tag.Value != mapping.Identifier() {
 				setValue(tag, mapping.Identifier())
 			}
+
+			// The same goes for the appVersion, when the tag
+			// defaults to it
+			if defaultTag && input.appVersion != mapping.Identifier() {
+				setValue(tag, mapping.Identifier())
+				tag.LineComment = fmt.Sprintf("Original: %s (appVersion)", input.appVersion)
+			}
+
+			// The digest of the original image won't match the
+			// Chainguard image, so it has to be cleared for the
+			// tag to take effect
+			if hasValue(digest) {
+				setValue(digest, "")
+			}
 		}
 
 		// Create a new node and add all the modified values to it
@@ -216,24 +329,116 @@ func mapNode(m mapper.Mapper, yamlPath []string, output *yaml.Node) yamlhelpers.
 		if err != nil {
 			node.HeadComment = fmt.Sprintf("Failed to map: %s: %s", img, err)
 		}
-		yamlhelpers.AddNode([]string{"registry"}, node, registry)
-		yamlhelpers.AddNode([]string{"image"}, node, image)
-		yamlhelpers.AddNode([]string{"name"}, node, name)
-		yamlhelpers.AddNode([]string{"repository"}, node, repository)
+		yamlhelpers.AddNode([]string{registryKey}, node, registry)
+		yamlhelpers.AddNode([]string{imageKey}, node, image)
+		yamlhelpers.AddNode([]string{nameKey}, node, name)
+		yamlhelpers.AddNode([]string{repositoryKey}, node, repository)
 
 		// Only include the tag if we modified it
 		if tag != nil && tag.LineComment != "" {
-			yamlhelpers.AddNode([]string{"tag"}, node, tag)
+			yamlhelpers.AddNode([]string{tagKey}, node, tag)
+		}
+		if digest != nil && digest.LineComment != "" {
+			yamlhelpers.AddNode([]string{digestKey}, node, digest)
 		}
 
 		// Add the new node to the output values at the same path as the
 		// input
-		yamlhelpers.AddNode(append(yamlPath, path...), output, node)
+		addOutput(input, path, output, node)
 
 		return nil
 	}
 }
 
+// field is an image related key in a values map
+type field struct {
+	key  string
+	node *yaml.Node
+}
+
+// firstField returns the node and key of the first field that holds the part
+// of the image reference, or a nil node if there isn't one
+func firstField(fields map[string][]field, part string) (*yaml.Node, string) {
+	if len(fields[part]) == 0 {
+		return nil, part
+	}
+
+	return fields[part][0].node, fields[part][0].key
+}
+
+// isImageKey tells us whether the map at the path is held by a key that holds
+// an image, like 'image'
+func isImageKey(keys Keys, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	return keys.part(path[:len(path)-1], path[len(path)-1]) == keyImage
+}
+
+// mapImages maps each of the fields as a full image reference and adds them to
+// the output values under the path
+func mapImages(m mapper.Mapper, fields []field, input valuesInput, path []string, output *yaml.Node) {
+	for _, f := range fields {
+		if !hasValue(f.node) || f.node.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		mapping, err := mapper.MapImage(m, f.node.Value)
+		if err != nil {
+			f.node.LineComment = fmt.Sprintf("Failed to map: %s", err)
+		} else {
+			setValue(f.node, mapping.String())
+		}
+
+		addOutput(input, slices.Concat(path, []string{f.key}), output, f.node)
+	}
+}
+
+// addOutput adds a node to the output values at the path of the input values
+// it was mapped from.
+//
+// Helm replaces lists in the values rather than merging them, so if the path
+// is inside a list, the whole list is copied from the input to the output and
+// the node is merged into the item in the copy.
+func addOutput(input valuesInput, path []string, output *yaml.Node, node *yaml.Node) {
+	i := slices.IndexFunc(path, func(element string) bool {
+		_, ok := yamlhelpers.ParseIndex(element)
+		return ok
+	})
+	if i < 0 {
+		// Images at the root of the values, like image and imageTag
+		// in the Elastic charts, are added to the root of the output
+		if len(input.yamlPath)+len(path) == 0 && node.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				yamlhelpers.AddNode([]string{node.Content[j].Value}, output, node.Content[j+1])
+			}
+			if node.HeadComment != "" {
+				output.HeadComment = node.HeadComment
+			}
+			return
+		}
+		yamlhelpers.AddNode(slices.Concat(input.yamlPath, path), output, node)
+		return
+	}
+
+	listPath := slices.Concat(input.yamlPath, path[:i])
+	if list := yamlhelpers.LookupNode(listPath, output); list == nil || list.Kind != yaml.SequenceNode {
+		yamlhelpers.AddNode(listPath, output, yamlhelpers.CopyNode(yamlhelpers.LookupNode(path[:i], input.node)))
+	}
+
+	if node.Kind != yaml.MappingNode {
+		yamlhelpers.AddNode(slices.Concat(input.yamlPath, path), output, node)
+		return
+	}
+	for j := 0; j+1 < len(node.Content); j += 2 {
+		yamlhelpers.AddNode(slices.Concat(input.yamlPath, path, []string{node.Content[j].Value}), output, node.Content[j+1])
+	}
+	if item := yamlhelpers.LookupNode(slices.Concat(input.yamlPath, path), output); item != nil && node.HeadComment != "" {
+		item.HeadComment = node.HeadComment
+	}
+}
+
 // setValue sets the value of a scalar node
 func setValue(node *yaml.Node, value string) {
 	if node == nil {
@@ -129,7 +129,7 @@ prometheus-example:
 		},
 	}
 
-	got, err := mapValues(m, input)
+	got, err := mapValues(m, input, ValuesOptions{})
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -137,3 +137,459 @@ prometheus-example:
 		t.Errorf("unexpected output:\n%s", diff)
 	}
 }
+
+func TestMapValuesKeys(t *testing.T) {
+	input := []byte(`
+controllerImage: registry.k8s.io/ingress-nginx/controller:v1.12.0
+webhookImage: registry.k8s.io/ingress-nginx/kube-webhook-certgen:v1.5.0
+operator:
+  imageName: quay.io/prometheus-operator/prometheus-operator
+  imageTag: v0.80.0
+sidecar:
+  image:
+    repositoryOverride: busybox
+    tag: "1.36"
+`)
+
+	want := []byte(`controllerImage: cgr.dev/chainguard/ingress-nginx-controller:1.12.0 # Original: registry.k8s.io/ingress-nginx/controller:v1.12.0
+webhookImage: cgr.dev/chainguard/kube-webhook-certgen:1.5.0 # Original: registry.k8s.io/ingress-nginx/kube-webhook-certgen:v1.5.0
+operator:
+    imageName: cgr.dev/chainguard/prometheus-operator # Original: quay.io/prometheus-operator/prometheus-operator
+    imageTag: 0.80.0 # Original: v0.80.0
+sidecar:
+    image:
+        repositoryOverride: cgr.dev/chainguard/busybox # Original: busybox
+`)
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"registry.k8s.io/ingress-nginx/controller:v1.12.0": {
+				"cgr.dev/chainguard/ingress-nginx-controller:1.12.0",
+			},
+			"registry.k8s.io/ingress-nginx/kube-webhook-certgen:v1.5.0": {
+				"cgr.dev/chainguard/kube-webhook-certgen:1.5.0",
+			},
+			"quay.io/prometheus-operator/prometheus-operator:v0.80.0": {
+				"cgr.dev/chainguard/prometheus-operator:0.80.0",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+		},
+	}
+
+	keys := Keys{
+		Image:      []string{"*Image", "imageName"},
+		Repository: []string{"sidecar.image.repositoryOverride"},
+		Tag:        []string{"imageTag"},
+	}
+	got, err := mapValues(m, input, ValuesOptions{Keys: keys})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+
+	// Without the keys, none of the images are found
+	got, err = mapValues(m, input, ValuesOptions{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff("{}\n", string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestMapValuesTagDigest(t *testing.T) {
+	input := []byte(`
+app:
+  image:
+    repository: example/app
+    tag: ""
+other:
+  image:
+    repository: example/other
+    tag: ""
+pinned:
+  image:
+    registry: docker.io
+    repository: library/nginx
+    tag: "1.25"
+    digest: sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a
+`)
+
+	want := `app:
+    image:
+        repository: cgr.dev/chainguard/app # Original: example/app
+        tag: 2.0.1 # Original: v2.0.1 (appVersion)
+other:
+    image:
+        repository: cgr.dev/chainguard/other # Original: example/other
+pinned:
+    image:
+        registry: cgr.dev # Original: docker.io
+        repository: chainguard/nginx # Original: library/nginx
+        digest: "" # Original: sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a
+`
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"example/app:v2.0.1": {
+				"cgr.dev/chainguard/app:2.0.1",
+			},
+			"example/other": {
+				"cgr.dev/chainguard/other:latest",
+			},
+			"docker.io/library/nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25",
+			},
+		},
+	}
+
+	got, err := mapValues(m, input, ValuesOptions{AppVersion: "v2.0.1"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestMapValuesLists(t *testing.T) {
+	input := []byte(`
+extraContainers:
+  - name: proxy
+    image: envoyproxy/envoy:v1.31.0
+    args: ["--log-level", "info"]
+  - name: logger
+    image:
+      repository: fluent/fluent-bit
+      tag: "3.1"
+controller:
+  sidecars:
+    - name: unknown
+      image: example/unknown:1.0
+extraImages:
+  - busybox:1.36
+  - example/unknown:1.0
+`)
+
+	want := `extraContainers:
+    - name: proxy
+      image: cgr.dev/chainguard/envoy:1.31.0 # Original: envoyproxy/envoy:v1.31.0
+      args: ["--log-level", "info"]
+    - name: logger
+      image:
+        repository: cgr.dev/chainguard/fluent-bit # Original: fluent/fluent-bit
+        tag: "3.1"
+controller:
+    sidecars:
+        # Failed to map: example/unknown:1.0: no results found
+        - name: unknown
+          image: example/unknown:1.0
+extraImages:
+    - cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+    - example/unknown:1.0 # Failed to map: no results found
+`
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"envoyproxy/envoy:v1.31.0": {
+				"cgr.dev/chainguard/envoy:1.31.0",
+			},
+			"fluent/fluent-bit:3.1": {
+				"cgr.dev/chainguard/fluent-bit:3.1",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+		},
+	}
+
+	got, err := mapValues(m, input, ValuesOptions{Keys: Keys{Image: []string{"extraImages"}}})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestMapValuesConventions(t *testing.T) {
+	input := []byte(`
+image: docker.elastic.co/elasticsearch/elasticsearch
+imageTag: 8.5.1
+prometheusOperator:
+  thanosImage:
+    registry: quay.io
+    repository: thanos/thanos
+    tag: v0.37.2
+    sha: 1b7d7b8d6b1c2a9f1c7c1b0f7e0a8d2c4b0f6e2d9a7c3b5e1f0d8c6a4b2e0f1d
+extraInitContainers: |
+  - name: wait-for-it
+    image: busybox:1.36
+    command: ["sh", "-c", "until nc -z db 5432; do sleep 1; done"]
+extraContainers: |
+  - name: templated
+    image: "{{ .Values.sidecar.image }}"
+`)
+
+	want := `image: cgr.dev/chainguard/elasticsearch # Original: docker.elastic.co/elasticsearch/elasticsearch
+prometheusOperator:
+    thanosImage:
+        registry: cgr.dev # Original: quay.io
+        repository: chainguard/thanos # Original: thanos/thanos
+        tag: 0.37.2 # Original: v0.37.2
+        sha: "" # Original: 1b7d7b8d6b1c2a9f1c7c1b0f7e0a8d2c4b0f6e2d9a7c3b5e1f0d8c6a4b2e0f1d
+extraInitContainers: |
+    - name: wait-for-it
+      image: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+      command: ["sh", "-c", "until nc -z db 5432; do sleep 1; done"]
+`
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"docker.elastic.co/elasticsearch/elasticsearch:8.5.1": {
+				"cgr.dev/chainguard/elasticsearch:8.5.1",
+			},
+			"quay.io/thanos/thanos:v0.37.2": {
+				"cgr.dev/chainguard/thanos:0.37.2",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+		},
+	}
+
+	got, err := mapValues(m, input, ValuesOptions{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestMapValuesFull(t *testing.T) {
+	input := []byte(`# Default values for example.
+replicaCount: 1
+
+defaults: &defaults
+  pullPolicy: IfNotPresent
+
+image:
+  <<: *defaults
+  # The image to run
+  repository: 'nginx'
+  tag: "1.25" # pinned
+  digest: sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a
+
+sidecar:
+  image: busybox:1.36
+  resources: {}
+`)
+
+	want := `# Default values for example.
+replicaCount: 1
+
+defaults: &defaults
+  pullPolicy: IfNotPresent
+
+image:
+  <<: *defaults
+  # The image to run
+  repository: 'cgr.dev/chainguard/nginx'
+  tag: "1.25.5" # pinned
+  digest: ""
+
+sidecar:
+  image: cgr.dev/chainguard/busybox:1.36
+  resources: {}
+`
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25.5",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+		},
+	}
+
+	got, err := mapValues(m, input, ValuesOptions{Full: true})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestMapValuesGlobalRegistry(t *testing.T) {
+	input := []byte(`
+global:
+  imageRegistry: ""
+image:
+  registry: docker.io
+  repository: bitnami/redis
+  tag: "7.4"
+metrics:
+  image:
+    registry: docker.io
+    repository: bitnami/redis-exporter
+    tag: "1.67"
+sidecar:
+  image: busybox:1.36
+`)
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"docker.io/bitnami/redis:7.4": {
+				"cgr.dev/chainguard/redis:7.4",
+			},
+			"docker.io/bitnami/redis-exporter:1.67": {
+				"cgr.dev/chainguard/prometheus-redis-exporter:1.67",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+		},
+	}
+
+	want := `image:
+    repository: chainguard/redis # Original: bitnami/redis
+metrics:
+    image:
+        repository: chainguard/prometheus-redis-exporter # Original: bitnami/redis-exporter
+sidecar:
+    image: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+global:
+    imageRegistry: cgr.dev
+`
+	got, err := mapValues(m, input, ValuesOptions{GlobalRegistry: true})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+
+	// When the images are mapped to different registries, the registry
+	// is set for each image
+	m.mappings["docker.io/bitnami/redis-exporter:1.67"] = []string{"registry.internal/chainguard/prometheus-redis-exporter:1.67"}
+	want = `image:
+    registry: cgr.dev # Original: docker.io
+    repository: chainguard/redis # Original: bitnami/redis
+metrics:
+    image:
+        registry: registry.internal # Original: docker.io
+        repository: chainguard/prometheus-redis-exporter # Original: bitnami/redis-exporter
+sidecar:
+    image: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+`
+	got, err = mapValues(m, input, ValuesOptions{GlobalRegistry: true})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output:\n%s", diff)
+	}
+}
+
+func TestMapValuesAnchors(t *testing.T) {
+	input := []byte(`defaults: &defaults
+  repository: nginx
+  tag: "1.25"
+image:
+  <<: *defaults
+  pullPolicy: Always
+worker:
+  image: *defaults
+sidecar:
+  image: &busybox busybox:1.36
+init:
+  image: *busybox
+---
+jobs:
+  image: busybox:1.36
+`)
+
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25.5",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:1.36",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name  string
+		vopts ValuesOptions
+		want  string
+	}{
+		{
+			// Helm resolves the aliases before it merges the
+			// values, so the mapped values are output at each
+			// path that refers to the anchor, and each document is
+			// output as a separate document
+			name: "values",
+			want: `defaults:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+    tag: 1.25.5 # Original: 1.25
+image:
+    repository: cgr.dev/chainguard/nginx # Original: nginx
+    tag: 1.25.5 # Original: 1.25
+worker:
+    image:
+        repository: cgr.dev/chainguard/nginx # Original: nginx
+        tag: 1.25.5 # Original: 1.25
+sidecar:
+    image: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+init:
+    image: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+---
+jobs:
+    image: cgr.dev/chainguard/busybox:1.36 # Original: busybox:1.36
+`,
+		},
+		{
+			// The anchored values are edited once, so the aliases
+			// are left as they are
+			name:  "full",
+			vopts: ValuesOptions{Full: true},
+			want: `defaults: &defaults
+  repository: cgr.dev/chainguard/nginx
+  tag: "1.25.5"
+image:
+  <<: *defaults
+  pullPolicy: Always
+worker:
+  image: *defaults
+sidecar:
+  image: &busybox cgr.dev/chainguard/busybox:1.36
+init:
+  image: *busybox
+---
+jobs:
+  image: cgr.dev/chainguard/busybox:1.36
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mapValues(m, input, tc.vopts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("unexpected output:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry summarises how many of the images in a run were mapped, so that the
+// coverage of a migration can be charted from one run to the next
+type Entry struct {
+	// Time is when the run finished
+	Time time.Time `json:"time"`
+
+	// Label identifies what was mapped, like the repository that was
+	// scanned, so that the entries of different targets can be told apart
+	Label string `json:"label,omitempty"`
+
+	// Command is the command that was run, i.e scan
+	Command string `json:"command"`
+
+	// Images is the number of distinct images in the run
+	Images int `json:"images"`
+
+	// Mapped is the number of images that were mapped to a Chainguard
+	// image
+	Mapped int `json:"mapped"`
+
+	// Unmapped is the number of images that weren't
+	Unmapped int `json:"unmapped"`
+
+	// Coverage is the percentage of the images that were mapped
+	Coverage float64 `json:"coverage"`
+}
+
+// NewEntry returns an entry for a run of the command that mapped the
+// images, unmapped of which couldn't be mapped
+func NewEntry(command, label string, images, unmapped int) Entry {
+	entry := Entry{
+		Time:     time.Now().UTC(),
+		Label:    label,
+		Command:  command,
+		Images:   images,
+		Mapped:   images - unmapped,
+		Unmapped: unmapped,
+	}
+	if images > 0 {
+		entry.Coverage = float64(entry.Mapped) / float64(images) * 100
+	}
+
+	return entry
+}
+
+// Append adds the entry to the history file at path, as a line of JSON. The
+// file, and its directory, are created if they don't exist.
+func Append(path string, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshalling entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening history file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing history file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Read returns the entries in the history file at path, oldest first. A file
+// that doesn't exist has no entries.
+func Read(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading history file: %w", err)
+	}
+
+	var entries []Entry
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(s.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("parsing history file: line %d: %w", n, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading history file: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Post sends the entry to an endpoint as a JSON object, for teams that
+// collect the history of their runs centrally
+func Post(ctx context.Context, url string, entry Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshalling entry: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "image-mapper")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
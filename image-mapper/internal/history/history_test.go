@@ -0,0 +1,93 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewEntry(t *testing.T) {
+	got := NewEntry("scan", "example/app", 8, 2)
+	if got.Time.IsZero() {
+		t.Errorf("expected the time to be set")
+	}
+	got.Time = time.Time{}
+
+	want := Entry{
+		Label:    "example/app",
+		Command:  "scan",
+		Images:   8,
+		Mapped:   6,
+		Unmapped: 2,
+		Coverage: 75,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected entry (-want +got):\n%s", diff)
+	}
+
+	if got := NewEntry("map", "", 0, 0); got.Coverage != 0 {
+		t.Errorf("unexpected coverage without images: %f", got.Coverage)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "coverage.jsonl")
+
+	entries := []Entry{
+		{Time: time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), Command: "scan", Images: 10, Mapped: 4, Unmapped: 6, Coverage: 40},
+		{Time: time.Date(2025, 1, 13, 9, 0, 0, 0, time.UTC), Command: "scan", Images: 10, Mapped: 7, Unmapped: 3, Coverage: 70},
+	}
+	for _, entry := range entries {
+		if err := Append(path, entry); err != nil {
+			t.Fatalf("unexpected error appending entry: %s", err)
+		}
+	}
+
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading history: %s", err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Errorf("unexpected entries (-want +got):\n%s", diff)
+	}
+
+	got, err = Read(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil || got != nil {
+		t.Errorf("expected no entries for a missing file, got %v: %v", got, err)
+	}
+}
+
+func TestPost(t *testing.T) {
+	entry := Entry{Time: time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), Label: "app", Command: "map", Images: 2, Mapped: 1, Unmapped: 1, Coverage: 50}
+
+	var got Entry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("unexpected error decoding body: %s", err)
+		}
+		if r.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	if err := Post(context.Background(), srv.URL+"/runs", entry); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(entry, got); diff != "" {
+		t.Errorf("unexpected entry (-want +got):\n%s", diff)
+	}
+
+	if err := Post(context.Background(), srv.URL+"/fail", entry); err == nil {
+		t.Errorf("expected an error for a failed request")
+	}
+}
@@ -0,0 +1,121 @@
+package iac
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IsBazel returns true if the file name is a Bazel file that can declare
+// images, i.e WORKSPACE, MODULE.bazel or a .bzl file of repository macros
+func IsBazel(name string) bool {
+	switch filepath.Base(name) {
+	case "WORKSPACE", "WORKSPACE.bazel", "WORKSPACE.bzlmod", "MODULE.bazel":
+		return true
+	}
+
+	return strings.HasSuffix(name, ".bzl")
+}
+
+// bazelRulePattern matches the start of the repository rules that pull
+// images: oci_pull and the oci.pull module extension from rules_oci, and
+// container_pull from rules_docker
+var bazelRulePattern = regexp.MustCompile(`\b(oci_pull|oci\.pull|container_pull)\s*\(`)
+
+// bazelAttributePattern matches an attribute with a string value in the
+// arguments of a rule, like image = "gcr.io/distroless/base"
+var bazelAttributePattern = regexp.MustCompile(`\b([a-z_]+)\s*=\s*"([^"]*)"`)
+
+// bazelReferences returns the image references in a Bazel file. The image is
+// put together from the attributes of each rule that pulls one:
+//
+//   - oci_pull and oci.pull: image, tag and digest
+//   - container_pull: registry, repository, tag and digest
+//
+// The reference is on the line of the image, or repository, attribute.
+func bazelReferences(path string, input []byte) []Reference {
+	src := string(input)
+
+	var refs []Reference
+	for _, loc := range bazelRulePattern.FindAllStringSubmatchIndex(src, -1) {
+		// Skip the rules that are commented out
+		lineStart := strings.LastIndex(src[:loc[0]], "\n") + 1
+		if strings.Contains(src[lineStart:loc[0]], "#") {
+			continue
+		}
+
+		start := loc[1]
+		end := closingParen(src, start)
+		if end < 0 {
+			continue
+		}
+
+		attrs := map[string]string{}
+		lines := map[string]int{}
+		for _, match := range bazelAttributePattern.FindAllStringSubmatchIndex(src[start:end], -1) {
+			key := src[start+match[2] : start+match[3]]
+			attrs[key] = src[start+match[4] : start+match[5]]
+			lines[key] = strings.Count(src[:start+match[2]], "\n") + 1
+		}
+
+		image, key := attrs["image"], "image"
+		if src[loc[2]:loc[3]] == "container_pull" {
+			image, key = attrs["repository"], "repository"
+			if attrs["registry"] != "" && image != "" {
+				image = fmt.Sprintf("%s/%s", attrs["registry"], image)
+			}
+		}
+		if image == "" {
+			continue
+		}
+		if tag := attrs["tag"]; tag != "" {
+			image = fmt.Sprintf("%s:%s", image, tag)
+		}
+		if digest := attrs["digest"]; digest != "" {
+			image = fmt.Sprintf("%s@%s", image, digest)
+		}
+		if !isImage(image) {
+			continue
+		}
+
+		refs = append(refs, Reference{
+			Path:  path,
+			Line:  lines[key],
+			Image: image,
+		})
+	}
+
+	return refs
+}
+
+// closingParen returns the index of the parenthesis that closes the call whose
+// arguments start at the index, skipping over strings and comments. It returns
+// -1 if the call isn't closed.
+func closingParen(src string, start int) int {
+	depth := 1
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '"', '\'':
+			quote := src[i]
+			for i++; i < len(src) && src[i] != quote; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
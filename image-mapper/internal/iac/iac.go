@@ -0,0 +1,291 @@
+package iac
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+// Reference is an image reference in a template
+type Reference struct {
+	Path  string `json:"path"`
+	Line  int    `json:"line"`
+	Image string `json:"image"`
+}
+
+// Replacement is the Chainguard image that should replace an image reference
+// in a template
+type Replacement struct {
+	Reference
+	Mapped string `json:"mapped"`
+}
+
+// Map finds the image references in the Terraform and CloudFormation
+// templates, Bazel files and Nomad jobs at the provided paths and maps them to
+// Chainguard. Directories are searched for templates.
+func Map(ctx context.Context, paths []string, opts ...mapper.Option) ([]Replacement, error) {
+	refs, err := FindReferences(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return mapReferences(m, refs), nil
+}
+
+// mapReferences maps the image references to Chainguard. References that can't
+// be mapped, or that are already Chainguard images, are skipped.
+func mapReferences(m mapper.Mapper, refs []Reference) []Replacement {
+	var replacements []Replacement
+	for _, ref := range refs {
+		mapped, err := mapper.MapImage(m, ref.Image)
+		if err != nil {
+			slog.Warn("error mapping image", "image", ref.Image, "path", ref.Path, "line", ref.Line, "err", err)
+			continue
+		}
+		if mapped.String() == ref.Image {
+			continue
+		}
+
+		replacements = append(replacements, Replacement{
+			Reference: ref,
+			Mapped:    mapped.String(),
+		})
+	}
+
+	return replacements
+}
+
+// skipDirs are directories that won't contain templates we're interested in
+var skipDirs = map[string]struct{}{
+	".git":         {},
+	".terraform":   {},
+	"node_modules": {},
+}
+
+// FindReferences returns the image references in the Terraform and
+// CloudFormation templates, Bazel files and Nomad jobs at the provided paths.
+// Directories are searched for templates. Files that can't be parsed are
+// skipped with a warning.
+func FindReferences(paths []string) ([]Reference, error) {
+	var refs []Reference
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading file: %s: %w", path, err)
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			files, err = findTemplates(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			input, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("reading file: %s: %w", file, err)
+			}
+
+			found, err := references(file, input)
+			if err != nil {
+				slog.Warn("skipping file", "path", file, "err", err)
+				continue
+			}
+			refs = append(refs, found...)
+		}
+	}
+
+	return refs, nil
+}
+
+// findTemplates returns the paths of the files in the directory and its
+// subdirectories that could be templates
+func findTemplates(dir string) ([]string, error) {
+	var paths []string
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if _, ok := skipDirs[d.Name()]; ok && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if IsBazel(d.Name()) || IsNomad(d.Name()) {
+			paths = append(paths, path)
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(d.Name())) {
+		case ".tf", ".json", ".yaml", ".yml", ".template":
+			paths = append(paths, path)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking directory: %s: %w", dir, err)
+	}
+
+	return paths, nil
+}
+
+// references returns the image references in a template. The type of template
+// is inferred from the file name and content. Files that aren't templates
+// return no references.
+func references(path string, input []byte) ([]Reference, error) {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case IsBazel(filepath.Base(path)):
+		return bazelReferences(path, input), nil
+	case strings.HasSuffix(name, ".tf"), IsNomad(name):
+		return terraformReferences(path, input), nil
+	case strings.HasSuffix(name, ".tf.json"):
+		return documentReferences(path, input, false)
+	default:
+		return documentReferences(path, input, true)
+	}
+}
+
+// IsNomad returns true if the file name is a Nomad job file, i.e web.nomad or
+// web.nomad.hcl
+func IsNomad(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".nomad") || strings.HasSuffix(name, ".nomad.hcl")
+}
+
+// attributePattern matches an attribute with a string value on a single line,
+// in HCL (image = "nginx") or in JSON embedded in HCL ("image": "nginx")
+var attributePattern = regexp.MustCompile(`^\s*"?([A-Za-z_][A-Za-z0-9_-]*)"?\s*[=:]\s*"([^"]*)"`)
+
+// terraformReferences returns the image references in a Terraform file, or
+// another HCL file like a Nomad job. The file is scanned line by line for
+// image attributes, which finds them in resources like kubernetes_deployment,
+// in jsonencode() calls, in the heredocs that ECS container definitions are
+// often written in and in the config of Nomad tasks.
+func terraformReferences(path string, input []byte) []Reference {
+	var refs []Reference
+	for i, line := range strings.Split(string(input), "\n") {
+		match := attributePattern.FindStringSubmatch(line)
+		if match == nil || !isImageKey(match[1]) || !isImage(match[2]) {
+			continue
+		}
+
+		refs = append(refs, Reference{
+			Path:  path,
+			Line:  i + 1,
+			Image: match[2],
+		})
+	}
+
+	return refs
+}
+
+// documentReferences returns the image references in a JSON or YAML stream,
+// which can have more than one document. If cloudFormation is true, documents
+// that aren't CloudFormation templates return no references.
+func documentReferences(path string, input []byte, cloudFormation bool) ([]Reference, error) {
+	docs, err := yamlhelpers.Documents(input)
+	if err != nil {
+		// Plenty of JSON and YAML files that aren't templates can't
+		// be parsed, like Helm templates or JSON with comments
+		if cloudFormation {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("decoding template: %w", err)
+	}
+
+	var refs []Reference
+	for _, root := range docs {
+		if root.Kind != yaml.MappingNode {
+			continue
+		}
+		if cloudFormation && !isCloudFormation(root) {
+			continue
+		}
+
+		// A value with an anchor is found at the path of each alias
+		// that refers to it, but it's only one reference
+		seen := map[*yaml.Node]bool{}
+		_ = yamlhelpers.WalkNode(root, func(p []string, node *yaml.Node) error {
+			if len(p) == 0 || node.Kind != yaml.ScalarNode || seen[node] {
+				return nil
+			}
+			if !isImageKey(p[len(p)-1]) || isIntrinsic(node) || !isImage(node.Value) {
+				return nil
+			}
+			seen[node] = true
+			refs = append(refs, Reference{
+				Path:  path,
+				Line:  node.Line,
+				Image: node.Value,
+			})
+			return nil
+		})
+	}
+
+	return refs, nil
+}
+
+// isCloudFormation returns true if the document looks like a CloudFormation
+// template, including the ones that are synthesized by the CDK
+func isCloudFormation(root *yaml.Node) bool {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		switch root.Content[i].Value {
+		case "AWSTemplateFormatVersion", "Resources":
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIntrinsic returns true if the value is a CloudFormation intrinsic
+// function in the short form, like !Sub or !Ref
+func isIntrinsic(node *yaml.Node) bool {
+	return strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!")
+}
+
+// isImageKey returns true if the key holds an image reference, i.e image,
+// Image, ImageUri, container_image or sidecar_image
+func isImageKey(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "image", "imageuri", "image_uri":
+		return true
+	}
+
+	return strings.HasSuffix(key, "_image")
+}
+
+// isImage returns true if the value is a literal image reference. Values with
+// interpolation are skipped, because they can't be resolved.
+func isImage(value string) bool {
+	if value == "" || strings.Contains(value, "${") || strings.Contains(value, "{{") {
+		return false
+	}
+	if _, err := name.ParseReference(value); err != nil {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,76 @@
+package iac
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockMapper struct {
+	mappings map[string][]string
+}
+
+func (m *mockMapper) Map(img string) (*mapper.Mapping, error) {
+	return &mapper.Mapping{
+		Image:   img,
+		Results: m.mappings[img],
+	}, nil
+}
+
+func TestFindReferences(t *testing.T) {
+	got, err := FindReferences([]string{"testdata"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []Reference{
+		{Path: filepath.Join("testdata", "bazel", "MODULE.bazel"), Line: 6, Image: "gcr.io/distroless/base:nonroot"},
+		{Path: filepath.Join("testdata", "bazel", "WORKSPACE"), Line: 6, Image: "index.docker.io/library/python@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a"},
+		{Path: filepath.Join("testdata", "bazel", "WORKSPACE"), Line: 14, Image: "index.docker.io/library/nginx:1.27"},
+		{Path: filepath.Join("testdata", "cdk.out", "Stack.template.json"), Line: 9, Image: "node:22"},
+		{Path: filepath.Join("testdata", "cloudformation", "service.yaml"), Line: 8, Image: "redis:7"},
+		{Path: filepath.Join("testdata", "cloudformation", "service.yaml"), Line: 16, Image: "public.ecr.aws/lambda/python:3.12"},
+		{Path: filepath.Join("testdata", "nomad", "web.nomad.hcl"), Line: 9, Image: "nginx:1.27"},
+		{Path: filepath.Join("testdata", "terraform", "main.tf"), Line: 11, Image: "nginx:1.27"},
+		{Path: filepath.Join("testdata", "terraform", "main.tf"), Line: 27, Image: "python:3.12"},
+		{Path: filepath.Join("testdata", "terraform", "main.tf"), Line: 38, Image: "envoyproxy/envoy:v1.31.0"},
+		{Path: filepath.Join("testdata", "terraform", "main.tf"), Line: 46, Image: "busybox:1.37"},
+		{Path: filepath.Join("testdata", "terraform", "main.tf.json"), Line: 10, Image: "alpine:3.20"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected references (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindReferencesMissing(t *testing.T) {
+	if _, err := FindReferences([]string{"testdata/missing.tf"}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestMapReferences(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.27":                 {"cgr.dev/chainguard/nginx:1.27"},
+			"cgr.dev/chainguard/redis:7": {"cgr.dev/chainguard/redis:7"},
+		},
+	}
+
+	refs := []Reference{
+		{Path: "main.tf", Line: 11, Image: "nginx:1.27"},
+		{Path: "main.tf", Line: 27, Image: "unknown:1.0"},
+		{Path: "main.tf", Line: 30, Image: "cgr.dev/chainguard/redis:7"},
+	}
+
+	want := []Replacement{
+		{
+			Reference: Reference{Path: "main.tf", Line: 11, Image: "nginx:1.27"},
+			Mapped:    "cgr.dev/chainguard/nginx:1.27",
+		},
+	}
+	if diff := cmp.Diff(want, mapReferences(m, refs)); diff != "" {
+		t.Errorf("unexpected replacements (-want +got):\n%s", diff)
+	}
+}
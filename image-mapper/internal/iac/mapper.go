@@ -0,0 +1,31 @@
+package iac
+
+import (
+	"context"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+)
+
+// NewMapper returns a mapper.Mapper configured specifically for mapping images
+// in infrastructure as code templates
+func NewMapper(ctx context.Context, opts ...mapper.Option) (mapper.Mapper, error) {
+	defaultOpts := []mapper.Option{
+		// Templates usually pin specific versions. We include
+		// inactive tags here so we can match to the closest version.
+		mapper.WithInactiveTags(true),
+		mapper.WithIgnoreFns(
+			// Iamguarded images are only designed to be
+			// used with our Helm charts.
+			mapper.IgnoreIamguarded(),
+			// TODO: make it possible select only
+			// FIPS images
+			mapper.IgnoreTiers([]string{"FIPS"}),
+		),
+		// These are the images that are deployed, so they
+		// shouldn't need the shell and package manager in the
+		// -dev tags
+		mapper.WithTagFilters(mapper.TagFilterExcludeDev),
+	}
+
+	return mapper.NewMapper(ctx, append(defaultOpts, opts...)...)
+}
@@ -0,0 +1,3 @@
+resource "kubernetes_pod" "x" {
+  image = "ignored:latest"
+}
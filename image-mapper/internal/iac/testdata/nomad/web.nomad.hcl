@@ -0,0 +1,22 @@
+job "web" {
+  datacenters = ["dc1"]
+
+  group "web" {
+    task "nginx" {
+      driver = "docker"
+
+      config {
+        image = "nginx:1.27"
+        ports = ["http"]
+      }
+    }
+
+    task "app" {
+      driver = "docker"
+
+      config {
+        image = "${NOMAD_META_registry}/app:1.0"
+      }
+    }
+  }
+}
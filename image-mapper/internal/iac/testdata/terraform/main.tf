@@ -0,0 +1,47 @@
+# image = "commented:out"
+resource "kubernetes_deployment" "web" {
+  metadata {
+    name = "web"
+  }
+  spec {
+    template {
+      spec {
+        container {
+          name  = "nginx"
+          image = "nginx:1.27"
+        }
+        container {
+          name  = "app"
+          image = "${var.registry}/app:${var.tag}"
+        }
+      }
+    }
+  }
+}
+
+resource "aws_ecs_task_definition" "worker" {
+  family = "worker"
+  container_definitions = jsonencode([
+    {
+      name  = "worker"
+      image = "python:3.12"
+    }
+  ])
+}
+
+resource "aws_ecs_task_definition" "proxy" {
+  family                = "proxy"
+  container_definitions = <<DEFINITION
+[
+  {
+    "name": "envoy",
+    "image": "envoyproxy/envoy:v1.31.0"
+  }
+]
+DEFINITION
+}
+
+module "app" {
+  source        = "./modules/app"
+  sidecar_image = "busybox:1.37"
+}
@@ -0,0 +1,140 @@
+package kustomize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// Image is an entry in the images transformer of a kustomization
+type Image struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+}
+
+// Map builds the kustomization in the directory, maps the images in the
+// resulting resources to Chainguard and returns an images transformer that
+// can be added to a kustomization.yaml
+func Map(ctx context.Context, dir string, opts ...mapper.Option) ([]byte, error) {
+	images, err := buildImages(dir)
+	if err != nil {
+		return nil, fmt.Errorf("building kustomization: %w", err)
+	}
+
+	m, err := NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return marshalImages(mapImages(m, images))
+}
+
+// buildImages builds the kustomization in the directory and returns the
+// images in the resources
+func buildImages(dir string) ([]string, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := resMap.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("encoding resources: %w", err)
+	}
+
+	images, err := manifest.Images(output)
+	if err != nil {
+		return nil, fmt.Errorf("extracting images: %w", err)
+	}
+
+	return manifest.UniqueImages(images), nil
+}
+
+// mapImages maps the images to Chainguard and returns the images transformer
+// entries that replace them. Images that can't be mapped are skipped.
+func mapImages(m mapper.Mapper, images []string) []Image {
+	entries := map[string]Image{}
+	for _, img := range images {
+		mapped, err := mapper.MapImage(m, img)
+		if err != nil {
+			slog.Warn("error mapping image", "image", img, "err", err)
+			continue
+		}
+
+		// Nothing to do if the image is already the Chainguard
+		// image
+		if img == mapped.String() {
+			continue
+		}
+
+		// The transformer matches images by name, without the
+		// tag or digest
+		entry := Image{
+			Name:    imageName(img),
+			NewName: mapped.Context().Name(),
+			NewTag:  mapped.Identifier(),
+		}
+
+		// There can only be one entry per name, so if the same
+		// image is used with different tags, we can't set a tag
+		// that suits them all
+		if existing, ok := entries[entry.Name]; ok && existing.NewTag != entry.NewTag {
+			if existing.NewTag != "" {
+				slog.Warn("the image is used with tags that map to different Chainguard tags, so newTag has been omitted", "image", entry.Name)
+			}
+			entry.NewTag = ""
+		}
+
+		entries[entry.Name] = entry
+	}
+
+	var result []Image
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	slices.SortFunc(result, func(a, b Image) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result
+}
+
+// imageName strips the tag and digest from an image reference
+func imageName(img string) string {
+	img, _, _ = strings.Cut(img, "@")
+
+	// A colon after the last slash separates the tag. Anything
+	// before that is part of the registry host.
+	if i := strings.LastIndex(img, ":"); i > strings.LastIndex(img, "/") {
+		img = img[:i]
+	}
+
+	return img
+}
+
+// marshalImages returns the images as an images transformer
+func marshalImages(images []Image) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(struct {
+		Images []Image `yaml:"images"`
+	}{
+		Images: images,
+	}); err != nil {
+		return nil, fmt.Errorf("encoding images: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,176 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockMapper struct {
+	mappings map[string][]string
+}
+
+func (m *mockMapper) Map(img string) (*mapper.Mapping, error) {
+	return &mapper.Mapping{
+		Image:   img,
+		Results: m.mappings[img],
+	}, nil
+}
+
+const testDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.36
+      containers:
+        - name: nginx
+          image: nginx:1.25
+          env:
+            - name: image
+              value: not-an-image
+`
+
+const testCronJob = `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: example
+spec:
+  schedule: "@daily"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: job
+              image: python:3.13
+`
+
+const testKustomization = `
+resources:
+  - deployment.yaml
+  - cronjob.yaml
+images:
+  - name: nginx
+    newTag: "1.27"
+`
+
+func TestBuildImages(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"deployment.yaml":    testDeployment,
+		"cronjob.yaml":       testCronJob,
+		"kustomization.yaml": testKustomization,
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+	}
+
+	got, err := buildImages(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"nginx:1.27",
+		"busybox:1.36",
+		"python:3.13",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+
+	if _, err := buildImages(t.TempDir()); err == nil {
+		t.Errorf("expected error for directory without a kustomization")
+	}
+}
+
+func TestMapImages(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string][]string{
+			"nginx:1.25": {
+				"cgr.dev/chainguard/nginx:1.25",
+			},
+			"nginx:1.27": {
+				"cgr.dev/chainguard/nginx:1.27",
+			},
+			"localhost:5000/python:3.13@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a": {
+				"cgr.dev/chainguard/python:3.13",
+			},
+			"busybox:1.36": {
+				"cgr.dev/chainguard/busybox:latest",
+			},
+			"cgr.dev/chainguard/redis:7": {
+				"cgr.dev/chainguard/redis:7",
+			},
+		},
+	}
+
+	got := mapImages(m, []string{
+		"nginx:1.25",
+		"localhost:5000/python:3.13@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+		"busybox:1.36",
+		"nginx:1.27",
+		"cgr.dev/chainguard/redis:7",
+		"example/unknown:1.0",
+	})
+
+	want := []Image{
+		{
+			Name:    "busybox",
+			NewName: "cgr.dev/chainguard/busybox",
+			NewTag:  "latest",
+		},
+		{
+			Name:    "localhost:5000/python",
+			NewName: "cgr.dev/chainguard/python",
+			NewTag:  "3.13",
+		},
+		{
+			Name:    "nginx",
+			NewName: "cgr.dev/chainguard/nginx",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestMarshalImages(t *testing.T) {
+	got, err := marshalImages([]Image{
+		{
+			Name:    "nginx",
+			NewName: "cgr.dev/chainguard/nginx",
+			NewTag:  "1.25",
+		},
+		{
+			Name:    "redis",
+			NewName: "cgr.dev/chainguard/redis",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `images:
+  - name: nginx
+    newName: cgr.dev/chainguard/nginx
+    newTag: "1.25"
+  - name: redis
+    newName: cgr.dev/chainguard/redis
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,142 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// CustomResource is a kind of resource, usually a custom resource that an
+// operator turns into pods, and the paths to the fields in it that hold
+// images, like spec.image in a Strimzi Kafka resource
+type CustomResource struct {
+	// APIVersion is the group and version of the resource, like
+	// kafka.strimzi.io/v1beta2. A group without a version matches every
+	// version of the group. It matches every group when it's empty.
+	APIVersion string `yaml:"apiVersion"`
+
+	// Kind is the kind of the resource, like Kafka
+	Kind string `yaml:"kind"`
+
+	// Paths are the JSONPaths of the fields that hold images, like
+	// spec.kafka.image. Lists are matched with [*], or an index, like
+	// spec.components[*].image.
+	Paths []string `yaml:"paths"`
+}
+
+// matches returns true if the custom resource describes the resource with the
+// apiVersion and kind
+func (c CustomResource) matches(apiVersion, kind string) bool {
+	if c.Kind != kind {
+		return false
+	}
+	if c.APIVersion == "" || c.APIVersion == apiVersion {
+		return true
+	}
+
+	group, _, _ := strings.Cut(apiVersion, "/")
+	return !strings.Contains(c.APIVersion, "/") && c.APIVersion == group
+}
+
+// ReadCustomResources reads the custom resources, and the paths of their
+// image fields, from a YAML file with a list of them
+func ReadCustomResources(path string) ([]CustomResource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading custom resources file: %w", err)
+	}
+
+	var crs []CustomResource
+	if err := yaml.Unmarshal(data, &crs); err != nil {
+		return nil, fmt.Errorf("decoding custom resources file: %s: %w", path, err)
+	}
+	for _, cr := range crs {
+		if cr.Kind == "" {
+			return nil, fmt.Errorf("custom resource without a kind: %s", path)
+		}
+		if len(cr.Paths) == 0 {
+			return nil, fmt.Errorf("custom resource without any paths: %s", cr.Kind)
+		}
+		for _, p := range cr.Paths {
+			if _, err := parseJSONPath(p); err != nil {
+				return nil, fmt.Errorf("custom resource %s: %w", cr.Kind, err)
+			}
+		}
+	}
+
+	return crs, nil
+}
+
+// jsonPathSegmentPattern matches a segment of a JSONPath, which is a field
+// name followed by any number of list selectors, like containers[*] or
+// items[0]
+var jsonPathSegmentPattern = regexp.MustCompile(`^([^\[\]]*)((?:\[(?:\*|\d+)\])*)$`)
+
+// parseJSONPath splits a JSONPath, like {.spec.components[*].image}, into the
+// names of the fields and list selectors it descends through, like spec,
+// components, [*] and image. The braces and the leading $ or . are optional.
+func parseJSONPath(p string) ([]string, error) {
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(p), "{"), "}")
+	trimmed = strings.TrimPrefix(strings.TrimPrefix(trimmed, "$"), ".")
+	if trimmed == "" {
+		return nil, fmt.Errorf("invalid path: %q", p)
+	}
+
+	var segments []string
+	for _, part := range strings.Split(trimmed, ".") {
+		match := jsonPathSegmentPattern.FindStringSubmatch(part)
+		if match == nil || (match[1] == "" && match[2] == "") {
+			return nil, fmt.Errorf("invalid path: %q", p)
+		}
+		if match[1] != "" {
+			segments = append(segments, match[1])
+		}
+		for _, selector := range strings.SplitAfter(match[2], "]") {
+			if selector != "" {
+				segments = append(segments, selector)
+			}
+		}
+	}
+
+	return segments, nil
+}
+
+// lookupJSONPath returns the nodes that the segments of a JSONPath select in
+// the node, along with their paths, where [*] is replaced by the index of each
+// item
+func lookupJSONPath(node *yaml.Node, segments []string, path []string, fn func(path []string, node *yaml.Node)) {
+	node = yamlhelpers.Resolve(node)
+	if node == nil {
+		return
+	}
+	if len(segments) == 0 {
+		fn(path, node)
+		return
+	}
+
+	segment, rest := segments[0], segments[1:]
+	switch {
+	case segment == "[*]":
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			lookupJSONPath(item, rest, append(path, yamlhelpers.Index(i)), fn)
+		}
+	case strings.HasPrefix(segment, "["):
+		i, ok := yamlhelpers.ParseIndex(segment)
+		if !ok || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+			return
+		}
+		lookupJSONPath(node.Content[i], rest, append(path, segment), fn)
+	default:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		lookupJSONPath(value(node, segment), rest, append(path, segment), fn)
+	}
+}
@@ -0,0 +1,302 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/yamlhelpers"
+	"gopkg.in/yaml.v3"
+)
+
+// Image is an image used by a container in a Kubernetes resource
+type Image struct {
+	APIVersion string
+	Kind       string
+	Name       string
+
+	// Path is the path to the list of containers in the resource, i.e
+	// spec.template.spec.containers, or to the container itself when the
+	// field holds a single container, i.e spec.templates.[0].container.
+	// For the image fields of custom resources, it's the path to the
+	// field, i.e spec.kafka.image.
+	Path []string
+
+	// Container is the name of the container, which is empty for fields
+	// that hold a single container
+	Container string
+
+	// Image is the image reference
+	Image string
+
+	// Field is the path to the field that holds the image reference, i.e
+	// spec.template.spec.containers.[0].image
+	Field []string
+
+	// Line is the line of the image reference in the input
+	Line int
+}
+
+// containerFields are the fields that hold lists of containers. As well as the
+// containers in a pod spec, this includes the steps and sidecars of Tekton
+// tasks and the init containers and sidecars of Argo Workflows templates.
+var containerFields = []string{"containers", "initContainers", "ephemeralContainers", "steps", "sidecars"}
+
+// containerObjectFields are the fields that hold a single container, like the
+// container and script of an Argo Workflows template or the step template of a
+// Tekton task
+var containerObjectFields = []string{"container", "script", "stepTemplate"}
+
+// Option configures how images are found in resources
+type Option func(*options)
+
+type options struct {
+	customResources []CustomResource
+}
+
+// WithCustomResources finds the images in the fields of custom resources, as
+// well as in their containers
+func WithCustomResources(crs ...CustomResource) Option {
+	return func(o *options) {
+		o.customResources = append(o.customResources, crs...)
+	}
+}
+
+func makeOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// Images returns the images of the containers in the resources in a
+// multi-document YAML stream, in the order they appear
+func Images(input []byte, opts ...Option) ([]Image, error) {
+	o := makeOptions(opts)
+
+	var images []Image
+
+	docs, err := yamlhelpers.Documents(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range docs {
+		if root.Kind != yaml.MappingNode {
+			continue
+		}
+
+		if err := walkContainers(root, o, func(image Image, _ *yaml.Node) {
+			images = append(images, image)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return images, nil
+}
+
+// RewriteFn returns the image that should replace an image in a resource, and
+// whether it should be replaced at all
+type RewriteFn func(img Image) (string, bool)
+
+// RewriteImages replaces the images of the containers in the resources in a
+// multi-document YAML stream with the images returned by fn. The resources are
+// re-encoded, but their comments and key order are preserved.
+func RewriteImages(input []byte, fn RewriteFn, opts ...Option) ([]byte, error) {
+	o := makeOptions(opts)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	dec := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding yaml: %w", err)
+		}
+		// Drop empty documents
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+
+		yamlhelpers.ClearMergeTags(&doc)
+		if root := doc.Content[0]; root.Kind == yaml.MappingNode {
+			// An image with an anchor is rewritten once, rather
+			// than again through each alias that refers to it
+			rewritten := map[*yaml.Node]bool{}
+			if err := walkContainers(root, o, func(image Image, node *yaml.Node) {
+				if rewritten[node] {
+					return
+				}
+				rewritten[node] = true
+				if rewritten, ok := fn(image); ok {
+					node.Value = rewritten
+					node.Tag = "!!str"
+				}
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := enc.Encode(&doc); err != nil {
+			return nil, fmt.Errorf("encoding yaml: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding yaml: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EditImages replaces the images of the containers in the resources in a
+// multi-document YAML stream with the images returned by fn, like
+// RewriteImages. Rather than re-encoding the resources, it edits the images
+// into the input, so that everything else is left exactly as it was.
+func EditImages(input []byte, fn RewriteFn, opts ...Option) ([]byte, error) {
+	o := makeOptions(opts)
+	var edits []yamlhelpers.Edit
+
+	docs, err := yamlhelpers.Documents(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range docs {
+		if root.Kind != yaml.MappingNode {
+			continue
+		}
+
+		if err := walkContainers(root, o, func(image Image, node *yaml.Node) {
+			if rewritten, ok := fn(image); ok && rewritten != node.Value {
+				edits = append(edits, yamlhelpers.Edit{Node: node, Value: rewritten})
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return yamlhelpers.EditScalars(input, edits), nil
+}
+
+// walkContainers calls fn with each container image in a resource and the node
+// that holds it, followed by the images in the fields of the resource, if it's
+// one of the custom resources in the options. The fields of custom resources
+// that are also container images are only visited as container images.
+func walkContainers(root *yaml.Node, o options, fn func(image Image, node *yaml.Node)) error {
+	resource := Image{
+		APIVersion: scalar(root, "apiVersion"),
+		Kind:       scalar(root, "kind"),
+	}
+	if metadata := value(root, "metadata"); metadata != nil {
+		resource.Name = scalar(metadata, "name")
+	}
+
+	seen := map[*yaml.Node]bool{}
+
+	visit := func(path, containerPath []string, container *yaml.Node) {
+		container = yamlhelpers.Resolve(container)
+		if container.Kind != yaml.MappingNode {
+			return
+		}
+		img := value(container, "image")
+		if img == nil || img.Kind != yaml.ScalarNode || img.Value == "" {
+			return
+		}
+
+		seen[img] = true
+
+		image := resource
+		image.Path = slices.Clone(path)
+		image.Container = scalar(container, "name")
+		image.Image = img.Value
+		image.Field = slices.Concat(containerPath, []string{"image"})
+		image.Line = img.Line
+		fn(image, img)
+	}
+
+	if err := yamlhelpers.WalkNode(root, func(path []string, node *yaml.Node) error {
+		if len(path) == 0 {
+			return nil
+		}
+		field := path[len(path)-1]
+
+		switch {
+		case node.Kind == yaml.SequenceNode && slices.Contains(containerFields, field):
+			for i, container := range node.Content {
+				visit(path, slices.Concat(path, []string{yamlhelpers.Index(i)}), container)
+			}
+		case node.Kind == yaml.MappingNode && slices.Contains(containerObjectFields, field):
+			visit(path, path, node)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, cr := range o.customResources {
+		if !cr.matches(resource.APIVersion, resource.Kind) {
+			continue
+		}
+		for _, p := range cr.Paths {
+			segments, err := parseJSONPath(p)
+			if err != nil {
+				return err
+			}
+			lookupJSONPath(root, segments, nil, func(path []string, node *yaml.Node) {
+				if node.Kind != yaml.ScalarNode || node.Value == "" || seen[node] {
+					return
+				}
+				seen[node] = true
+
+				image := resource
+				image.Path = slices.Clone(path)
+				image.Image = node.Value
+				image.Field = slices.Clone(path)
+				image.Line = node.Line
+				fn(image, node)
+			})
+		}
+	}
+
+	return nil
+}
+
+// UniqueImages returns the unique image references in the images, in the order
+// they first appear
+func UniqueImages(images []Image) []string {
+	var refs []string
+	for _, img := range images {
+		if slices.Contains(refs, img.Image) {
+			continue
+		}
+		refs = append(refs, img.Image)
+	}
+
+	return refs
+}
+
+// value returns the value of a key in a mapping node, following aliases and
+// merge keys
+func value(node *yaml.Node, key string) *yaml.Node {
+	return yamlhelpers.LookupNode([]string{key}, node)
+}
+
+// scalar returns the value of a scalar key in a mapping node, or an empty
+// string
+func scalar(node *yaml.Node, key string) string {
+	v := value(node, key)
+	if v == nil || v.Kind != yaml.ScalarNode {
+		return ""
+	}
+
+	return v.Value
+}
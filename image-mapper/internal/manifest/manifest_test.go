@@ -0,0 +1,623 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testManifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.36
+      containers:
+        - name: nginx
+          image: nginx:1.25
+          env:
+            - name: image
+              value: not-an-image
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: example
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: job
+              image: nginx:1.25
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - name: main
+  ephemeralContainers:
+    - name: debugger
+      image: busybox:1.36
+---
+# Just a comment
+---
+- not
+- a
+- resource
+`
+
+func TestImages(t *testing.T) {
+	got, err := Images([]byte(testManifests))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []Image{
+		{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "example",
+			Path:       []string{"spec", "template", "spec", "initContainers"},
+			Container:  "init",
+			Image:      "busybox:1.36",
+			Field:      []string{"spec", "template", "spec", "initContainers", "[0]", "image"},
+			Line:       11,
+		},
+		{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "example",
+			Path:       []string{"spec", "template", "spec", "containers"},
+			Container:  "nginx",
+			Image:      "nginx:1.25",
+			Field:      []string{"spec", "template", "spec", "containers", "[0]", "image"},
+			Line:       14,
+		},
+		{
+			APIVersion: "batch/v1",
+			Kind:       "CronJob",
+			Name:       "example",
+			Path:       []string{"spec", "jobTemplate", "spec", "template", "spec", "containers"},
+			Container:  "job",
+			Image:      "nginx:1.25",
+			Field:      []string{"spec", "jobTemplate", "spec", "template", "spec", "containers", "[0]", "image"},
+			Line:       30,
+		},
+		{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       "debug",
+			Path:       []string{"spec", "ephemeralContainers"},
+			Container:  "debugger",
+			Image:      "busybox:1.36",
+			Field:      []string{"spec", "ephemeralContainers", "[0]", "image"},
+			Line:       41,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{"busybox:1.36", "nginx:1.25"}, UniqueImages(got)); diff != "" {
+		t.Errorf("unexpected unique images (-want +got):\n%s", diff)
+	}
+
+	if _, err := Images([]byte("foo: [")); err == nil {
+		t.Errorf("expected error for invalid yaml")
+	}
+}
+
+func TestImagesPipelines(t *testing.T) {
+	input := `
+apiVersion: tekton.dev/v1
+kind: Task
+metadata:
+  name: build
+spec:
+  stepTemplate:
+    image: alpine:3.20
+  steps:
+    - name: test
+      image: golang:1.23
+      script: go test ./...
+  sidecars:
+    - name: registry
+      image: registry:2
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: ci
+spec:
+  templates:
+    - name: main
+      steps:
+        - - name: lint
+            template: lint
+    - name: lint
+      container:
+        image: python:3.12
+    - name: test
+      script:
+        image: node:22
+        source: npm test
+`
+	got, err := Images([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []Image{
+		{
+			APIVersion: "tekton.dev/v1",
+			Kind:       "Task",
+			Name:       "build",
+			Path:       []string{"spec", "stepTemplate"},
+			Image:      "alpine:3.20",
+			Field:      []string{"spec", "stepTemplate", "image"},
+			Line:       8,
+		},
+		{
+			APIVersion: "tekton.dev/v1",
+			Kind:       "Task",
+			Name:       "build",
+			Path:       []string{"spec", "steps"},
+			Container:  "test",
+			Image:      "golang:1.23",
+			Field:      []string{"spec", "steps", "[0]", "image"},
+			Line:       11,
+		},
+		{
+			APIVersion: "tekton.dev/v1",
+			Kind:       "Task",
+			Name:       "build",
+			Path:       []string{"spec", "sidecars"},
+			Container:  "registry",
+			Image:      "registry:2",
+			Field:      []string{"spec", "sidecars", "[0]", "image"},
+			Line:       15,
+		},
+		{
+			APIVersion: "argoproj.io/v1alpha1",
+			Kind:       "Workflow",
+			Name:       "ci",
+			Path:       []string{"spec", "templates", "[1]", "container"},
+			Image:      "python:3.12",
+			Field:      []string{"spec", "templates", "[1]", "container", "image"},
+			Line:       29,
+		},
+		{
+			APIVersion: "argoproj.io/v1alpha1",
+			Kind:       "Workflow",
+			Name:       "ci",
+			Path:       []string{"spec", "templates", "[2]", "script"},
+			Image:      "node:22",
+			Field:      []string{"spec", "templates", "[2]", "script", "image"},
+			Line:       32,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestRewriteImages(t *testing.T) {
+	input := `# Source: example/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.36
+      containers:
+        - name: nginx
+          image: "nginx:1.25" # the web server
+          ports:
+            - containerPort: 80
+---
+---
+# Source: example/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: example
+`
+
+	want := `# Source: example/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.36
+      containers:
+        - name: nginx
+          image: "cgr.dev/chainguard/nginx:1.25" # the web server
+          ports:
+            - containerPort: 80
+---
+# Source: example/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: example
+`
+
+	var seen []string
+	got, err := RewriteImages([]byte(input), func(img Image) (string, bool) {
+		seen = append(seen, img.Kind+"/"+img.Name+"/"+img.Container)
+		if img.Image != "nginx:1.25" {
+			return "", false
+		}
+		return "cgr.dev/chainguard/nginx:1.25", true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+
+	wantSeen := []string{"Deployment/example/init", "Deployment/example/nginx"}
+	if diff := cmp.Diff(wantSeen, seen); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestEditImages(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+    name: example
+spec:
+    template:
+        spec:
+            containers:
+                - name: nginx
+                  image: "nginx:1.25" # the web server
+                - {name: sidecar, image: busybox:1.36}
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+    name: example
+spec:
+    template:
+        spec:
+            containers:
+                - name: job
+                  image: nginx:1.25
+`
+
+	want := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+    name: example
+spec:
+    template:
+        spec:
+            containers:
+                - name: nginx
+                  image: "cgr.dev/chainguard/nginx:1.25" # the web server
+                - {name: sidecar, image: busybox:1.36}
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+    name: example
+spec:
+    template:
+        spec:
+            containers:
+                - name: job
+                  image: cgr.dev/chainguard/nginx:1.25
+`
+
+	got, err := EditImages([]byte(input), func(img Image) (string, bool) {
+		if img.Image != "nginx:1.25" {
+			return "", false
+		}
+		return "cgr.dev/chainguard/nginx:1.25", true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestRewriteImagesAnchors(t *testing.T) {
+	input := `apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: example
+spec:
+  templates:
+    - name: build
+      container: &container
+        image: &image golang:1.23
+        command: [go, build]
+    - name: test
+      container:
+        <<: *container
+        command: [go, test]
+    - name: lint
+      script:
+        image: *image
+`
+
+	want := `apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: example
+spec:
+  templates:
+    - name: build
+      container: &container
+        image: &image cgr.dev/chainguard/go:1.23
+        command: [go, build]
+    - name: test
+      container:
+        <<: *container
+        command: [go, test]
+    - name: lint
+      script:
+        image: *image
+`
+
+	var seen []string
+	got, err := RewriteImages([]byte(input), func(img Image) (string, bool) {
+		seen = append(seen, strings.Join(img.Path, ".")+"="+img.Image)
+		return "cgr.dev/chainguard/go:1.23", true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+
+	// The anchored image is rewritten once
+	wantSeen := []string{"spec.templates.[0].container=golang:1.23"}
+	if diff := cmp.Diff(wantSeen, seen); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+
+	// But it's found through the merge key and the alias, as well as
+	// where it's anchored
+	images, err := Images([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var found []string
+	for _, img := range images {
+		found = append(found, strings.Join(img.Path, ".")+"="+img.Image)
+	}
+	wantFound := []string{
+		"spec.templates.[0].container=golang:1.23",
+		"spec.templates.[1].container=golang:1.23",
+		"spec.templates.[2].script=golang:1.23",
+	}
+	if diff := cmp.Diff(wantFound, found); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+const testCustomResources = `
+apiVersion: kafka.strimzi.io/v1beta2
+kind: Kafka
+metadata:
+  name: cluster
+spec:
+  kafka:
+    image: quay.io/strimzi/kafka:0.45.0-kafka-3.9.0
+    replicas: 3
+  zookeeper:
+    image: quay.io/strimzi/kafka:0.45.0-kafka-3.9.0
+---
+apiVersion: acid.zalan.do/v1
+kind: postgresql
+metadata:
+  name: db
+spec:
+  dockerImage: ghcr.io/zalando/spilo-16:3.3-p1
+  sidecars:
+    - name: exporter
+      image: quay.io/prometheuscommunity/postgres-exporter:v0.15.0
+---
+apiVersion: example.com/v1
+kind: Pipeline
+metadata:
+  name: build
+spec:
+  images:
+    - golang:1.23
+    - alpine:3.20
+`
+
+func TestImagesCustomResources(t *testing.T) {
+	crs := []CustomResource{
+		{
+			APIVersion: "kafka.strimzi.io",
+			Kind:       "Kafka",
+			Paths:      []string{"spec.kafka.image", "{.spec.zookeeper.image}"},
+		},
+		{
+			APIVersion: "acid.zalan.do/v1",
+			Kind:       "postgresql",
+			// The sidecar images are containers already, so they
+			// aren't found twice
+			Paths: []string{"spec.dockerImage", "spec.sidecars[*].image"},
+		},
+		{
+			Kind:  "Pipeline",
+			Paths: []string{"$.spec.images[1]"},
+		},
+		{
+			APIVersion: "example.com/v2",
+			Kind:       "Pipeline",
+			Paths:      []string{"spec.images[*]"},
+		},
+	}
+
+	images, err := Images([]byte(testCustomResources), WithCustomResources(crs...))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var got []string
+	for _, img := range images {
+		got = append(got, img.Kind+"/"+img.Name+":"+strings.Join(img.Path, ".")+"="+img.Image)
+	}
+	want := []string{
+		"Kafka/cluster:spec.kafka.image=quay.io/strimzi/kafka:0.45.0-kafka-3.9.0",
+		"Kafka/cluster:spec.zookeeper.image=quay.io/strimzi/kafka:0.45.0-kafka-3.9.0",
+		"postgresql/db:spec.sidecars=quay.io/prometheuscommunity/postgres-exporter:v0.15.0",
+		"postgresql/db:spec.dockerImage=ghcr.io/zalando/spilo-16:3.3-p1",
+		"Pipeline/build:spec.images.[1]=alpine:3.20",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+
+	// Without the custom resources, only the containers are found
+	images, err = Images([]byte(testCustomResources))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(images) != 1 {
+		t.Errorf("expected 1 image, but got %d", len(images))
+	}
+}
+
+func TestEditImagesCustomResources(t *testing.T) {
+	input := `apiVersion: acid.zalan.do/v1
+kind: postgresql
+metadata:
+  name: db
+spec:
+  dockerImage: ghcr.io/zalando/spilo-16:3.3-p1 # pinned
+  numberOfInstances: 2
+`
+	want := `apiVersion: acid.zalan.do/v1
+kind: postgresql
+metadata:
+  name: db
+spec:
+  dockerImage: cgr.dev/chainguard/spilo-16:latest # pinned
+  numberOfInstances: 2
+`
+
+	got, err := EditImages([]byte(input), func(img Image) (string, bool) {
+		return "cgr.dev/chainguard/spilo-16:latest", true
+	}, WithCustomResources(CustomResource{Kind: "postgresql", Paths: []string{"spec.dockerImage"}}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	testCases := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{path: "spec.image", want: []string{"spec", "image"}},
+		{path: ".spec.image", want: []string{"spec", "image"}},
+		{path: "$.spec.image", want: []string{"spec", "image"}},
+		{path: "{.spec.components[*].image}", want: []string{"spec", "components", "[*]", "image"}},
+		{path: "spec.matrix[0][*]", want: []string{"spec", "matrix", "[0]", "[*]"}},
+		{path: "", wantErr: true},
+		{path: "spec..image", wantErr: true},
+		{path: "spec.images[?(@.name=='app')]", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseJSONPath(tc.path)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("expected an error for %q", tc.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tc.path, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("unexpected segments for %q (-want +got):\n%s", tc.path, diff)
+		}
+	}
+}
+
+func TestReadCustomResources(t *testing.T) {
+	testCases := map[string]struct {
+		input   string
+		want    []CustomResource
+		wantErr bool
+	}{
+		"valid": {
+			input: "- apiVersion: kafka.strimzi.io/v1beta2\n  kind: Kafka\n  paths: [spec.kafka.image]\n",
+			want: []CustomResource{
+				{APIVersion: "kafka.strimzi.io/v1beta2", Kind: "Kafka", Paths: []string{"spec.kafka.image"}},
+			},
+		},
+		"no kind": {
+			input:   "- paths: [spec.image]\n",
+			wantErr: true,
+		},
+		"no paths": {
+			input:   "- kind: Kafka\n",
+			wantErr: true,
+		},
+		"invalid path": {
+			input:   "- kind: Kafka\n  paths: ['spec..image']\n",
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "crs.yaml")
+			if err := os.WriteFile(path, []byte(tc.input), 0o644); err != nil {
+				t.Fatalf("unexpected error writing file: %s", err)
+			}
+
+			got, err := ReadCustomResources(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected custom resources (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AliasOverrides maps the name of a Chainguard repository to the aliases that
+// should replace the aliases in the catalog data.
+//
+// It's the same shape as the hardcoded fixes in aliasesFixes, which means that
+// corrections can be shipped as a YAML file like this, without cutting a new
+// release:
+//
+//	argocd-repo-server: []
+//	vault-k8s:
+//	  - hashicorp/vault-k8s
+type AliasOverrides map[string][]string
+
+// LoadAliasOverrides loads alias overrides from a YAML file on disk, or from a
+// http(s) URL.
+func LoadAliasOverrides(ctx context.Context, src string) (AliasOverrides, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if u, uerr := url.Parse(src); uerr == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		data, err = fetchAliasOverrides(ctx, src)
+	} else {
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading alias overrides: %s: %w", src, err)
+	}
+
+	overrides := AliasOverrides{}
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("unmarshalling alias overrides: %s: %w", src, err)
+	}
+
+	return overrides, nil
+}
+
+func fetchAliasOverrides(ctx context.Context, src string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("constructing request: %w", err)
+	}
+	req.Header.Add("User-Agent", "image-mapper")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// overrideAliases replaces the aliases of the repos with the provided
+// overrides. Overrides for repositories that don't exist in the catalog are
+// ignored.
+func overrideAliases(repos []Repo, overrides ...AliasOverrides) []Repo {
+	for _, o := range overrides {
+		for i, repo := range repos {
+			aliases, ok := o[repo.Name]
+			if !ok {
+				continue
+			}
+			slog.Debug("overriding aliases", "repository", repo.Name, "aliases", aliases)
+			repos[i].Aliases = aliases
+		}
+	}
+
+	return repos
+}
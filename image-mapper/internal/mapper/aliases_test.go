@@ -0,0 +1,126 @@
+package mapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testAliasOverrides = `
+argocd-repo-server: []
+vault-k8s:
+  - hashicorp/vault-k8s
+`
+
+func TestLoadAliasOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := os.WriteFile(path, []byte(testAliasOverrides), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	got, err := LoadAliasOverrides(t.Context(), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := AliasOverrides{
+		"argocd-repo-server": {},
+		"vault-k8s":          {"hashicorp/vault-k8s"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected overrides (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadAliasOverridesURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/aliases.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(testAliasOverrides))
+	}))
+	defer srv.Close()
+
+	got, err := LoadAliasOverrides(t.Context(), srv.URL+"/aliases.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := AliasOverrides{
+		"argocd-repo-server": {},
+		"vault-k8s":          {"hashicorp/vault-k8s"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected overrides (-want +got):\n%s", diff)
+	}
+
+	if _, err := LoadAliasOverrides(t.Context(), srv.URL+"/missing.yaml"); err == nil {
+		t.Errorf("expected error for missing URL")
+	}
+}
+
+func TestLoadAliasOverridesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := os.WriteFile(path, []byte("- not\n- a\n- map\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	if _, err := LoadAliasOverrides(t.Context(), path); err == nil {
+		t.Errorf("expected error for invalid overrides")
+	}
+
+	if _, err := LoadAliasOverrides(t.Context(), filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestOverrideAliases(t *testing.T) {
+	repos := []Repo{
+		{
+			Name:    "argocd-repo-server",
+			Aliases: []string{"quay.io/argoproj/argocd"},
+		},
+		{
+			Name:    "nginx",
+			Aliases: []string{"nginx"},
+		},
+		{
+			Name:    "vault-k8s",
+			Aliases: []string{"hashicorp/vault"},
+		},
+	}
+
+	got := overrideAliases(repos,
+		AliasOverrides{
+			"argocd-repo-server": {},
+			"vault-k8s":          {"hashicorp/vault"},
+			"does-not-exist":     {"foo/bar"},
+		},
+		AliasOverrides{
+			"vault-k8s": {"hashicorp/vault-k8s"},
+		},
+	)
+
+	want := []Repo{
+		{
+			Name:    "argocd-repo-server",
+			Aliases: []string{},
+		},
+		{
+			Name:    "nginx",
+			Aliases: []string{"nginx"},
+		},
+		{
+			Name:    "vault-k8s",
+			Aliases: []string{"hashicorp/vault-k8s"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,230 @@
+package mapper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cachedRepoClient caches the repositories returned by another RepoClient in a
+// file on disk
+type cachedRepoClient struct {
+	client   RepoClient
+	path     string
+	duration time.Duration
+
+	// refresh fetches the repositories and caches them, even when the
+	// cache hasn't expired
+	refresh bool
+
+	// allowStale returns the cached repositories, even when the cache has
+	// expired, if they can't be fetched
+	allowStale bool
+}
+
+func newCachedRepoClient(client RepoClient, dir, file string, duration time.Duration) (*cachedRepoClient, error) {
+	dir, err := cacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedRepoClient{
+		client:   client,
+		path:     filepath.Join(dir, file),
+		duration: duration,
+	}, nil
+}
+
+// cacheDir returns dir or, when it's empty, the image-mapper directory in the
+// user's cache directory
+func cacheDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("finding user cache directory: %w", err)
+	}
+
+	return filepath.Join(userCacheDir, "image-mapper"), nil
+}
+
+// ListRepos returns the cached repositories if the cache hasn't expired.
+// Otherwise, it lists them with the underlying client and caches the result.
+func (c *cachedRepoClient) ListRepos(ctx context.Context) ([]Repo, error) {
+	if !c.refresh {
+		repos, ok, err := c.read()
+		if err != nil {
+			return nil, fmt.Errorf("reading cache: %w", err)
+		}
+		if ok {
+			return repos, nil
+		}
+	}
+	slog.Debug("catalog cache miss", "path", c.path, "refresh", c.refresh)
+
+	// Only one process refreshes the cache at a time. The others wait
+	// for it and then read what it wrote, rather than fetching the
+	// catalog themselves.
+	unlock, err := lockFile(ctx, c.path+".lock")
+	if err != nil {
+		return nil, fmt.Errorf("locking cache: %w", err)
+	}
+	defer unlock()
+
+	if !c.refresh {
+		repos, ok, err := c.read()
+		if err != nil {
+			return nil, fmt.Errorf("reading cache: %w", err)
+		}
+		if ok {
+			return repos, nil
+		}
+	}
+
+	repos, err := c.client.ListRepos(ctx)
+	if err != nil {
+		if !c.allowStale {
+			return nil, err
+		}
+		stale, age, ok, readErr := c.readAny()
+		if readErr != nil || !ok {
+			return nil, err
+		}
+		slog.Warn("couldn't fetch the catalog, so the expired cache is being used instead. Mappings may be missing recent images and tags.", "age", age.Round(time.Second), "err", err)
+		return stale, nil
+	}
+
+	if err := c.write(repos); err != nil {
+		return nil, fmt.Errorf("writing cache: %w", err)
+	}
+
+	return repos, nil
+}
+
+// cacheData is the format of the cache file. The checksum is the sha256 of
+// the repositories, so that a file that has been truncated or modified is
+// detected.
+type cacheData struct {
+	Checksum string          `json:"checksum"`
+	Repos    json.RawMessage `json:"repos"`
+}
+
+// read returns the repositories in the cache file. It returns false if the
+// file doesn't exist or it has expired. A file that's corrupt is also
+// treated as a miss, with a warning, so that the catalog is fetched again.
+func (c *cachedRepoClient) read() ([]Repo, bool, error) {
+	info, err := os.Stat(c.path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	age := time.Since(info.ModTime())
+	if age > c.duration {
+		slog.Debug("catalog cache expired", "path", c.path, "age", age.Round(time.Second))
+		return nil, false, nil
+	}
+
+	repos, _, ok, err := c.readAny()
+	if ok {
+		slog.Debug("catalog cache hit", "path", c.path, "age", age.Round(time.Second), "repos", len(repos))
+	}
+
+	return repos, ok, err
+}
+
+// readAny returns the repositories in the cache file and its age, whether or
+// not it has expired. It returns false if the file doesn't exist or it's
+// corrupt.
+func (c *cachedRepoClient) readAny() ([]Repo, time.Duration, bool, error) {
+	info, err := os.Stat(c.path)
+	if os.IsNotExist(err) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	repos, err := decodeCache(data)
+	if err != nil {
+		slog.Warn("ignoring corrupt cache", "path", c.path, "err", err)
+		return nil, 0, false, nil
+	}
+
+	return repos, time.Since(info.ModTime()), true, nil
+}
+
+// decodeCache decodes the cache file and verifies its checksum
+func decodeCache(data []byte) ([]Repo, error) {
+	var cd cacheData
+	if err := json.Unmarshal(data, &cd); err != nil {
+		return nil, fmt.Errorf("unmarshalling cache: %w", err)
+	}
+	if got := checksum(cd.Repos); got != cd.Checksum {
+		return nil, fmt.Errorf("checksum mismatch: expected %q, got %q", cd.Checksum, got)
+	}
+
+	var repos []Repo
+	if err := json.Unmarshal(cd.Repos, &repos); err != nil {
+		return nil, fmt.Errorf("unmarshalling repos: %w", err)
+	}
+
+	return repos, nil
+}
+
+// write writes the repositories to a temporary file and renames it over the
+// cache file, so that other processes never read a partly written file
+func (c *cachedRepoClient) write(repos []Repo) error {
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(repos)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cacheData{
+		Checksum: checksum(raw),
+		Repos:    raw,
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return renameFile(f.Name(), c.path)
+}
+
+func checksum(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
@@ -0,0 +1,253 @@
+package mapper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockRepoClient struct {
+	repos []Repo
+	err   error
+	calls int
+}
+
+func (c *mockRepoClient) ListRepos(ctx context.Context) ([]Repo, error) {
+	c.calls++
+	return c.repos, c.err
+}
+
+func TestCachedRepoClient(t *testing.T) {
+	dir := t.TempDir()
+	repos := []Repo{
+		{
+			Name:        "nginx",
+			CatalogTier: "APPLICATION",
+			Aliases:     []string{"nginx"},
+			ActiveTags:  []string{"latest", "latest-dev"},
+		},
+	}
+	client := &mockRepoClient{repos: repos}
+
+	c, err := newCachedRepoClient(client, dir, "repos.json", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error constructing client: %s", err)
+	}
+
+	// The first call should populate the cache
+	got, err := c.ListRepos(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(repos, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repos.json")); err != nil {
+		t.Errorf("expected cache file to exist: %s", err)
+	}
+
+	// The second call should be served from the cache
+	got, err = c.ListRepos(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(repos, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+	if client.calls != 1 {
+		t.Errorf("expected 1 call to the underlying client, got %d", client.calls)
+	}
+
+	// An expired cache should be refreshed
+	expired := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "repos.json"), expired, expired); err != nil {
+		t.Fatalf("unexpected error modifying cache file: %s", err)
+	}
+	if _, err := c.ListRepos(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if client.calls != 2 {
+		t.Errorf("expected 2 calls to the underlying client, got %d", client.calls)
+	}
+
+	// A refresh should bypass the cache, even though it hasn't expired
+	c.refresh = true
+	if _, err := c.ListRepos(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if client.calls != 3 {
+		t.Errorf("expected 3 calls to the underlying client, got %d", client.calls)
+	}
+}
+
+func TestCachedRepoClientWaitsForLock(t *testing.T) {
+	dir := t.TempDir()
+	repos := []Repo{
+		{
+			Name:        "nginx",
+			CatalogTier: "APPLICATION",
+			Aliases:     []string{"nginx"},
+			ActiveTags:  []string{"latest"},
+		},
+	}
+	client := &mockRepoClient{repos: []Repo{{Name: "unexpected"}}}
+	c, err := newCachedRepoClient(client, dir, "repos.json", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error constructing client: %s", err)
+	}
+
+	// Pretend that another process is refreshing the cache
+	unlock, err := lockFile(t.Context(), filepath.Join(dir, "repos.json.lock"))
+	if err != nil {
+		t.Fatalf("unexpected error locking cache: %s", err)
+	}
+
+	type result struct {
+		repos []Repo
+		err   error
+	}
+	done := make(chan result)
+	go func() {
+		repos, err := c.ListRepos(t.Context())
+		done <- result{repos: repos, err: err}
+	}()
+
+	// The client should use what the other process wrote, rather than
+	// fetching the catalog itself
+	other := &cachedRepoClient{path: filepath.Join(dir, "repos.json")}
+	if err := other.write(repos); err != nil {
+		t.Fatalf("unexpected error writing cache: %s", err)
+	}
+	unlock()
+
+	got := <-done
+	if got.err != nil {
+		t.Fatalf("unexpected error: %s", got.err)
+	}
+	if diff := cmp.Diff(repos, got.repos); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+	if client.calls != 0 {
+		t.Errorf("expected no calls to the underlying client, got %d", client.calls)
+	}
+}
+
+func TestCachedRepoClientError(t *testing.T) {
+	client := &mockRepoClient{err: errors.New("catalog unavailable")}
+
+	c, err := newCachedRepoClient(client, t.TempDir(), "repos.json", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error constructing client: %s", err)
+	}
+
+	if _, err := c.ListRepos(t.Context()); err == nil {
+		t.Errorf("expected error from underlying client")
+	}
+}
+
+func TestCachedRepoClientStale(t *testing.T) {
+	dir := t.TempDir()
+	repos := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}}
+
+	// Cache the catalog and then let it expire
+	c, err := newCachedRepoClient(&mockRepoClient{repos: repos}, dir, "repos.json", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error constructing client: %s", err)
+	}
+	if _, err := c.ListRepos(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expired := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "repos.json"), expired, expired); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	client := &mockRepoClient{err: errors.New("catalog unavailable")}
+	c, err = newCachedRepoClient(client, dir, "repos.json", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error constructing client: %s", err)
+	}
+
+	// The expired cache isn't used unless it's allowed
+	if _, err := c.ListRepos(t.Context()); err == nil {
+		t.Errorf("expected error from underlying client")
+	}
+
+	c.allowStale = true
+	got, err := c.ListRepos(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(repos, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+	if client.calls != 2 {
+		t.Errorf("expected the catalog to be fetched each time, got %d calls", client.calls)
+	}
+
+	// Without a cache, the error is returned
+	c, err = newCachedRepoClient(client, t.TempDir(), "repos.json", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error constructing client: %s", err)
+	}
+	c.allowStale = true
+	if _, err := c.ListRepos(t.Context()); err == nil {
+		t.Errorf("expected error from underlying client without a cache")
+	}
+}
+
+func TestCachedRepoClientCorrupt(t *testing.T) {
+	testCases := map[string]string{
+		"invalid json":      `{not json`,
+		"truncated":         `{"checksum":"sha256:0000","repos":[{"name":"ngi`,
+		"checksum mismatch": `{"checksum":"sha256:0000","repos":[{"name":"nginx"}]}`,
+		"previous format":   `[{"name":"nginx"}]`,
+	}
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "repos.json"), []byte(data), 0o644); err != nil {
+				t.Fatalf("unexpected error writing cache file: %s", err)
+			}
+
+			repos := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}}
+			client := &mockRepoClient{repos: repos}
+			c, err := newCachedRepoClient(client, dir, "repos.json", time.Hour)
+			if err != nil {
+				t.Fatalf("unexpected error constructing client: %s", err)
+			}
+
+			// A corrupt cache file is a miss, so the catalog is
+			// fetched again and the cache is repaired
+			got, err := c.ListRepos(t.Context())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(repos, got); diff != "" {
+				t.Errorf("unexpected repos (-want +got):\n%s", diff)
+			}
+			if client.calls != 1 {
+				t.Errorf("expected 1 call to the underlying client, got %d", client.calls)
+			}
+			if _, ok, err := c.read(); !ok || err != nil {
+				t.Errorf("expected the cache to be repaired, got %t, %v", ok, err)
+			}
+
+			// Only the cache file is left behind
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, e := range entries {
+				if e.Name() != "repos.json" && e.Name() != "repos.json.lock" {
+					t.Errorf("unexpected file in cache directory: %s", e.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package mapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// catalogBackend constructs the client for a catalog URL
+type catalogBackend func(u *url.URL, o *options) (RepoClient, error)
+
+// catalogBackends are the backends for each URL scheme
+var catalogBackends = map[string]catalogBackend{
+	"http":       newGraphQLRepoClient,
+	"https":      newGraphQLRepoClient,
+	"file":       newFileRepoClient,
+	"chainguard": newPlatformRepoClient,
+}
+
+// newRepoClient returns the client for the catalog. A client configured with
+// WithRepoClient is used as it is, without caching. Otherwise, the scheme of the catalog URL
+// selects the backend:
+//
+//   - http, https: the GraphQL API, like DefaultCatalogURL
+//   - file: a JSON file of repositories, like the output of catalog list
+//     --format=json
+//   - chainguard: the registry API of the Chainguard platform, read as the
+//     identity configured by WithIdentity, like
+//     chainguard://console-api.enforce.dev/<group>
+//
+// The second return value reports whether the repositories should be cached
+// on disk. That isn't worth it for a local file and the cache files are named
+// after the catalog URL, which doesn't identify a client configured with
+// WithRepoClient.
+func newRepoClient(o *options) (RepoClient, bool, error) {
+	if o.repoClient != nil {
+		return o.repoClient, false, nil
+	}
+
+	u, err := url.Parse(o.catalogURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("parsing catalog URL: %w", err)
+	}
+	backend, ok := catalogBackends[u.Scheme]
+	if !ok {
+		return nil, false, fmt.Errorf("unsupported catalog URL scheme: %q", u.Scheme)
+	}
+	client, err := backend(u, o)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return client, u.Scheme != "file", nil
+}
+
+func newGraphQLRepoClient(u *url.URL, o *options) (RepoClient, error) {
+	return &repoClient{
+		url:          u.String(),
+		inactiveTags: o.inactiveTags,
+	}, nil
+}
+
+// fileRepoClient reads the repositories from a file
+type fileRepoClient struct {
+	path string
+}
+
+func newFileRepoClient(u *url.URL, o *options) (RepoClient, error) {
+	path := fileURLPath(u)
+	if path == "" {
+		return nil, fmt.Errorf("catalog URL %q has no path", u.String())
+	}
+
+	return &fileRepoClient{path: path}, nil
+}
+
+// driveLetterPathRegex matches the path of a file URL for a Windows path,
+// like /C:/Users/me/catalog.json in file:///C:/Users/me/catalog.json
+var driveLetterPathRegex = regexp.MustCompile(`^/[A-Za-z]:/`)
+
+// fileURLPath returns the path of the file that a file URL refers to. Relative
+// paths, like file://catalog.json, are accepted as well as absolute ones, like
+// file:///tmp/catalog.json or, on Windows, file:///C:/Users/me/catalog.json.
+func fileURLPath(u *url.URL) string {
+	path := u.Host + u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	if driveLetterPathRegex.MatchString(path) {
+		path = path[1:]
+	}
+
+	return filepath.FromSlash(path)
+}
+
+// ListRepos reads the repositories from the file. The file is either a list
+// of repositories or a response from the GraphQL API, with the list under
+// data.repos.
+func (c *fileRepoClient) ListRepos(ctx context.Context) ([]Repo, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog: %w", err)
+	}
+
+	var repos []Repo
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		var resp catalogResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("unmarshalling catalog: %s: %w", c.path, err)
+		}
+		repos = resp.Data.Repos
+	} else if err := json.Unmarshal(data, &repos); err != nil {
+		return nil, fmt.Errorf("unmarshalling catalog: %s: %w", c.path, err)
+	}
+
+	return fixAliases(repos), nil
+}
@@ -0,0 +1,135 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	common "chainguard.dev/sdk/proto/platform/common/v1"
+	registry "chainguard.dev/sdk/proto/platform/registry/v1"
+	"chainguard.dev/sdk/sts"
+)
+
+// PublicCatalogGroup is the group of the repositories in the public catalog,
+// which DefaultCatalogURL lists
+const PublicCatalogGroup = "ce2d1984a010471142503340d670612d63ffb9f6"
+
+// TokenSource provides Chainguard tokens for an audience, like the client of
+// the identity set with --identity
+type TokenSource interface {
+	Token(ctx context.Context, audience string) (*sts.TokenPair, error)
+}
+
+// platformRepoClient lists the repositories of a group with the registry API
+// of the Chainguard platform, like the image copiers do, rather than with the
+// GraphQL API. The identity needs to be able to list the repositories of the
+// group.
+type platformRepoClient struct {
+	endpoint     string
+	group        string
+	inactiveTags bool
+	identity     TokenSource
+
+	// newClients is registry.NewClients, except in the tests
+	newClients func(ctx context.Context, addr, token string) (registry.Clients, error)
+}
+
+// newPlatformRepoClient returns the client for a chainguard:// URL, like
+// chainguard://console-api.enforce.dev/<group>. The host is the API endpoint
+// and the path is the group whose repositories are listed, which defaults to
+// the public catalog.
+func newPlatformRepoClient(u *url.URL, o *options) (RepoClient, error) {
+	if o.identity == nil {
+		return nil, fmt.Errorf("catalog URL %q needs a Chainguard identity to read the platform API as", u.String())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("catalog URL %q has no API endpoint", u.String())
+	}
+	group := strings.Trim(u.Path, "/")
+	if group == "" {
+		group = PublicCatalogGroup
+	}
+
+	return &platformRepoClient{
+		endpoint:     "https://" + u.Host,
+		group:        group,
+		inactiveTags: o.inactiveTags,
+		identity:     o.identity,
+		newClients:   registry.NewClients,
+	}, nil
+}
+
+// ListRepos lists the repositories of the group, with the tags of each one
+// when the inactive tags are included
+func (c *platformRepoClient) ListRepos(ctx context.Context) ([]Repo, error) {
+	start := time.Now()
+
+	tok, err := c.identity.Token(ctx, c.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("getting token for %s: %w", c.endpoint, err)
+	}
+	clients, err := c.newClients(ctx, c.endpoint, tok.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("creating registry clients: %w", err)
+	}
+	defer clients.Close()
+
+	list, err := clients.Registry().ListRepos(ctx, &registry.RepoFilter{
+		Uidp: &common.UIDPFilter{ChildrenOf: c.group},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories of %s: %w", c.group, err)
+	}
+
+	var tags map[string][]Tag
+	if c.inactiveTags {
+		tags, err = c.listTags(ctx, clients.Registry())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repos := make([]Repo, 0, len(list.GetItems()))
+	for _, item := range list.GetItems() {
+		repo := Repo{
+			Name:       item.GetName(),
+			Aliases:    item.GetAliases(),
+			ActiveTags: item.GetActiveTags(),
+			Tags:       tags[item.GetId()],
+		}
+		if tier := item.GetCatalogTier(); tier != registry.CatalogTier_UNKNOWN {
+			repo.CatalogTier = tier.String()
+		}
+		repos = append(repos, repo)
+	}
+	slog.Debug("fetched catalog", "endpoint", c.endpoint, "group", c.group, "repos", len(repos), "duration", time.Since(start).Round(time.Millisecond))
+
+	return fixAliases(repos), nil
+}
+
+// listTags lists the tags of every repository of the group in one request,
+// keyed by the ID of their repository, without the tags that the GraphQL
+// query leaves out either
+func (c *platformRepoClient) listTags(ctx context.Context, client registry.RegistryClient) (map[string][]Tag, error) {
+	list, err := client.ListTags(ctx, &registry.TagFilter{
+		Uidp:             &common.UIDPFilter{DescendantsOf: c.group},
+		ExcludeReferrers: true,
+		ExcludeDates:     true,
+		ExcludeEpochs:    true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing tags of %s: %w", c.group, err)
+	}
+
+	tags := map[string][]Tag{}
+	for _, tag := range list.GetItems() {
+		repoID := path.Dir(tag.GetId())
+		tags[repoID] = append(tags[repoID], Tag{Name: tag.GetName()})
+	}
+
+	return tags, nil
+}
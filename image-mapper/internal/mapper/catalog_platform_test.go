@@ -0,0 +1,201 @@
+package mapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	common "chainguard.dev/sdk/proto/platform/common/v1"
+	registry "chainguard.dev/sdk/proto/platform/registry/v1"
+	registrytest "chainguard.dev/sdk/proto/platform/registry/v1/test"
+	"chainguard.dev/sdk/sts"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeTokenSource returns a token that names the audience
+type fakeTokenSource struct {
+	err error
+}
+
+func (f fakeTokenSource) Token(_ context.Context, audience string) (*sts.TokenPair, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &sts.TokenPair{AccessToken: "token-for-" + audience}, nil
+}
+
+func TestNewPlatformRepoClient(t *testing.T) {
+	testCases := map[string]struct {
+		url          string
+		identity     TokenSource
+		wantEndpoint string
+		wantGroup    string
+		wantErr      bool
+	}{
+		"group": {
+			url:          "chainguard://console-api.enforce.dev/0123456789abcdef",
+			identity:     fakeTokenSource{},
+			wantEndpoint: "https://console-api.enforce.dev",
+			wantGroup:    "0123456789abcdef",
+		},
+		"public catalog": {
+			url:          "chainguard://console-api.enforce.dev",
+			identity:     fakeTokenSource{},
+			wantEndpoint: "https://console-api.enforce.dev",
+			wantGroup:    PublicCatalogGroup,
+		},
+		"no identity": {
+			url:     "chainguard://console-api.enforce.dev/0123456789abcdef",
+			wantErr: true,
+		},
+		"no endpoint": {
+			url:      "chainguard:///0123456789abcdef",
+			identity: fakeTokenSource{},
+			wantErr:  true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client, cacheable, err := newRepoClient(newOptions(WithCatalogURL(tc.url), WithIdentity(tc.identity)))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !cacheable {
+				t.Errorf("expected the repositories to be cached")
+			}
+			c, ok := client.(*platformRepoClient)
+			if !ok {
+				t.Fatalf("expected a platform client, got %T", client)
+			}
+			if c.endpoint != tc.wantEndpoint {
+				t.Errorf("expected endpoint %s, got %s", tc.wantEndpoint, c.endpoint)
+			}
+			if c.group != tc.wantGroup {
+				t.Errorf("expected group %s, got %s", tc.wantGroup, c.group)
+			}
+		})
+	}
+}
+
+func TestPlatformRepoClient(t *testing.T) {
+	const group = "0123456789abcdef"
+	repos := &registry.RepoList{
+		Items: []*registry.Repo{
+			{
+				Id:          group + "/nginx",
+				Name:        "nginx",
+				CatalogTier: registry.CatalogTier_APPLICATION,
+				Aliases:     []string{"nginx"},
+				ActiveTags:  []string{"1.25", "latest"},
+			},
+			{
+				Id:   group + "/custom",
+				Name: "custom",
+			},
+		},
+	}
+	tags := &registry.TagList{
+		Items: []*registry.Tag{
+			{Id: group + "/nginx/t1", Name: "1.25"},
+			{Id: group + "/nginx/t2", Name: "1.24"},
+			{Id: group + "/custom/t3", Name: "latest"},
+		},
+	}
+	mock := registrytest.MockRegistryClients{
+		RegistryClient: registrytest.MockRegistryClient{
+			OnListRepos: []registrytest.ReposOnList{{
+				Given: &registry.RepoFilter{Uidp: &common.UIDPFilter{ChildrenOf: group}},
+				List:  repos,
+			}},
+			OnListTags: []registrytest.TagsOnList{{
+				Given: &registry.TagFilter{
+					Uidp:             &common.UIDPFilter{DescendantsOf: group},
+					ExcludeReferrers: true,
+					ExcludeDates:     true,
+					ExcludeEpochs:    true,
+				},
+				List: tags,
+			}},
+		},
+	}
+
+	testCases := map[string]struct {
+		inactiveTags bool
+		identity     TokenSource
+		want         []Repo
+		wantErr      bool
+	}{
+		"repositories": {
+			identity: fakeTokenSource{},
+			want: []Repo{
+				{Name: "nginx", CatalogTier: "APPLICATION", Aliases: []string{"nginx"}, ActiveTags: []string{"1.25", "latest"}},
+				{Name: "custom"},
+			},
+		},
+		"inactive tags": {
+			inactiveTags: true,
+			identity:     fakeTokenSource{},
+			want: []Repo{
+				{Name: "nginx", CatalogTier: "APPLICATION", Aliases: []string{"nginx"}, ActiveTags: []string{"1.25", "latest"}, Tags: []Tag{{Name: "1.25"}, {Name: "1.24"}}},
+				{Name: "custom", Tags: []Tag{{Name: "latest"}}},
+			},
+		},
+		"token error": {
+			identity: fakeTokenSource{err: errors.New("permission denied")},
+			wantErr:  true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var gotEndpoint, gotToken string
+			c := &platformRepoClient{
+				endpoint:     "https://console-api.example",
+				group:        group,
+				inactiveTags: tc.inactiveTags,
+				identity:     tc.identity,
+				newClients: func(_ context.Context, addr, token string) (registry.Clients, error) {
+					gotEndpoint, gotToken = addr, token
+					return mock, nil
+				},
+			}
+
+			got, err := c.ListRepos(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected repos (-want +got):\n%s", diff)
+			}
+			if gotEndpoint != "https://console-api.example" || gotToken != "token-for-https://console-api.example" {
+				t.Errorf("expected a client for the endpoint with a token for it, got %s with %s", gotEndpoint, gotToken)
+			}
+		})
+	}
+}
+
+func TestPlatformRepoClientListError(t *testing.T) {
+	c := &platformRepoClient{
+		endpoint: "https://console-api.example",
+		group:    "0123456789abcdef",
+		identity: fakeTokenSource{},
+		newClients: func(context.Context, string, string) (registry.Clients, error) {
+			return registrytest.MockRegistryClients{}, nil
+		},
+	}
+	if _, err := c.ListRepos(context.Background()); err == nil {
+		t.Errorf("expected an error")
+	}
+}
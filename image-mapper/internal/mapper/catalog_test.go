@@ -0,0 +1,127 @@
+package mapper
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileRepoClient(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"list.json":     `[{"name":"nginx","catalogTier":"APPLICATION","aliases":["nginx"],"activeTags":["latest"]}]`,
+		"response.json": `{"data":{"repos":[{"name":"nginx","catalogTier":"APPLICATION","aliases":["nginx"],"activeTags":["latest"]}]}}`,
+		"invalid.json":  `{"data":`,
+	}
+	for file, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0o644); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+	}
+	want := []Repo{
+		{
+			Name:        "nginx",
+			CatalogTier: "APPLICATION",
+			Aliases:     []string{"nginx"},
+			ActiveTags:  []string{"latest"},
+		},
+	}
+
+	testCases := map[string]struct {
+		url     string
+		wantErr bool
+	}{
+		"list": {
+			url: "file://" + filepath.Join(dir, "list.json"),
+		},
+		"graphql response": {
+			url: "file://" + filepath.Join(dir, "response.json"),
+		},
+		"invalid": {
+			url:     "file://" + filepath.Join(dir, "invalid.json"),
+			wantErr: true,
+		},
+		"missing": {
+			url:     "file://" + filepath.Join(dir, "missing.json"),
+			wantErr: true,
+		},
+		"unsupported scheme": {
+			url:     "ftp://example.com/catalog.json",
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ListRepos(t.Context(), WithCatalogURL(tc.url), WithCacheDir(t.TempDir()), WithCacheDuration(0))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected repos (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFileRepoClientRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "catalog.json"), []byte(`[{"name":"nginx"}]`), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	t.Chdir(dir)
+
+	got, err := ListRepos(t.Context(), WithCatalogURL("file://catalog.json"), WithCacheDuration(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff([]Repo{{Name: "nginx"}}, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+}
+
+func TestFileURLPath(t *testing.T) {
+	for rawURL, want := range map[string]string{
+		"file://catalog.json":                "catalog.json",
+		"file://data/catalog.json":           filepath.FromSlash("data/catalog.json"),
+		"file:///tmp/catalog.json":           filepath.FromSlash("/tmp/catalog.json"),
+		"file:catalog.json":                  "catalog.json",
+		"file:///C:/Users/me/catalog.json":   filepath.FromSlash("C:/Users/me/catalog.json"),
+		"file://C:/Users/me/catalog.json":    filepath.FromSlash("C:/Users/me/catalog.json"),
+		"file://d:/catalogs/chainguard.json": filepath.FromSlash("d:/catalogs/chainguard.json"),
+	} {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %s", rawURL, err)
+		}
+		if got := fileURLPath(u); got != want {
+			t.Errorf("fileURLPath(%s) = %q, want %q", rawURL, got, want)
+		}
+	}
+}
+
+func TestWithRepoClient(t *testing.T) {
+	dir := t.TempDir()
+	repos := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}}
+	client := &mockRepoClient{repos: repos}
+
+	got, err := ListRepos(context.Background(), WithRepoClient(client), WithCacheDir(dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(repos, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+	if client.calls != 1 {
+		t.Errorf("expected 1 call to the client, got %d", client.calls)
+	}
+}
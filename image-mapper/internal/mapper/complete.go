@@ -0,0 +1,54 @@
+package mapper
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// CachedRepos returns the repositories in the catalog data that earlier runs
+// cached on disk, even when the cache has expired. It never fetches the
+// catalog, so it's fast enough for shell completion. Alias overrides aren't
+// applied. It returns no repositories when nothing has been cached.
+func CachedRepos(opts ...Option) ([]Repo, error) {
+	o := newOptions(opts...)
+
+	for _, inactiveTags := range []bool{false, true} {
+		c, err := newCachedRepoClient(nil, o.cacheDir, cacheFile(o.catalogURL, inactiveTags), math.MaxInt64)
+		if err != nil {
+			return nil, err
+		}
+		repos, ok, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return repos, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CompleteImages returns the names and aliases of the repositories that start
+// with the prefix, for completing the images given to the mapper
+func CompleteImages(repos []Repo, prefix string) []string {
+	prefix = strings.ToLower(prefix)
+
+	var completions []string
+	for _, repo := range repos {
+		// Repos without a tier aren't accessible in the catalog
+		if repo.CatalogTier == "" {
+			continue
+		}
+
+		for _, s := range append([]string{repo.Name}, repo.Aliases...) {
+			if strings.HasPrefix(strings.ToLower(s), prefix) {
+				completions = append(completions, s)
+			}
+		}
+	}
+	slices.Sort(completions)
+
+	return slices.Compact(completions)
+}
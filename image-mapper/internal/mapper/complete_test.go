@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompleteImages(t *testing.T) {
+	repos := []Repo{
+		{Name: "nginx", CatalogTier: "APPLICATION", Aliases: []string{"nginx", "docker.io/library/nginx", "bitnami/nginx"}},
+		{Name: "nginx-fips", CatalogTier: "FIPS", Aliases: []string{"nginx"}},
+		{Name: "node", CatalogTier: "BASE", Aliases: []string{"node"}},
+		{Name: "nginx-internal", Aliases: []string{"nginx"}},
+		{Name: "redis", CatalogTier: "APPLICATION", Aliases: []string{"bitnami/redis", "Redis"}},
+	}
+
+	testCases := map[string][]string{
+		"":        {"Redis", "bitnami/nginx", "bitnami/redis", "docker.io/library/nginx", "nginx", "nginx-fips", "node", "redis"},
+		"ngi":     {"nginx", "nginx-fips"},
+		"n":       {"nginx", "nginx-fips", "node"},
+		"bitnami": {"bitnami/nginx", "bitnami/redis"},
+		"RED":     {"Redis", "redis"},
+		"missing": nil,
+	}
+	for prefix, want := range testCases {
+		t.Run(prefix, func(t *testing.T) {
+			got := CompleteImages(repos, prefix)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected completions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCachedRepos(t *testing.T) {
+	dir := t.TempDir()
+
+	got, err := CachedRepos(WithCacheDir(dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) > 0 {
+		t.Errorf("expected no repos before anything is cached, got: %v", got)
+	}
+
+	want := []Repo{{Name: "nginx", CatalogTier: "APPLICATION", Aliases: []string{"nginx"}}}
+	c := &cachedRepoClient{path: filepath.Join(dir, "repos-with-tags.json")}
+	if err := c.write(want); err != nil {
+		t.Fatalf("unexpected error writing cache: %s", err)
+	}
+
+	got, err = CachedRepos(WithCacheDir(dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+}
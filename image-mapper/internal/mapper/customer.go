@@ -0,0 +1,113 @@
+package mapper
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+// actionUnmapped is the action needed for an image that doesn't map to any
+// Chainguard image
+const actionUnmapped = "No Chainguard image matches. Request the image from Chainguard, or find an alternative."
+
+// Describe sets the catalog details of the first result of each mapping: the
+// tier of its repository and whether the repository has the tag of the
+// upstream image. Mappings without results, or with results that aren't in
+// the catalog, are left without details.
+func (m *mapper) Describe(mappings ...*Mapping) {
+	for _, mapping := range mappings {
+		if len(mapping.Results) == 0 {
+			continue
+		}
+		repo, ok := m.resultRepo(mapping.Results[0])
+		if !ok {
+			continue
+		}
+
+		repoTag, _, _ := strings.Cut(mapping.Image, "@")
+		ref, err := name.NewTag(repoTag)
+		if err != nil {
+			continue
+		}
+		tags := append(slices.Clone(repo.ActiveTags), flattenTags(repo.Tags)...)
+
+		mapping.Catalog = &CatalogDetails{
+			Tier:      repo.CatalogTier,
+			TagExists: slices.Contains(tags, ref.TagStr()),
+		}
+	}
+}
+
+// resultRepo returns the catalog repository of a result
+func (m *mapper) resultRepo(result string) (Repo, bool) {
+	resultName, _ := splitImage(result)
+	for _, repo := range m.repos {
+		if repo.CatalogTier == "" {
+			continue
+		}
+		if m.repository(repo)+"/"+repo.Name == resultName {
+			return repo, true
+		}
+	}
+
+	return Repo{}, false
+}
+
+// customerYAML is the report of the mappings that's shared with customers
+type customerYAML struct {
+	Images []customerImage `yaml:"images"`
+}
+
+type customerImage struct {
+	Image        string   `yaml:"image"`
+	Occurrences  int      `yaml:"occurrences,omitempty"`
+	Chainguard   string   `yaml:"chainguard,omitempty"`
+	Alternatives []string `yaml:"alternatives,omitempty"`
+	Tier         string   `yaml:"tier,omitempty"`
+	TagExists    *bool    `yaml:"tagExists,omitempty"`
+	Entitled     *bool    `yaml:"entitled,omitempty"`
+	EOL          *EOL     `yaml:"eol,omitempty"`
+	Warnings     []string `yaml:"warnings,omitempty"`
+	ActionNeeded string   `yaml:"actionNeeded,omitempty"`
+}
+
+// outputCustomerYAML writes a YAML report of the mappings for customers. The
+// first result is the recommended Chainguard image and the others are
+// alternatives. The tier and whether the tag exists are included when the
+// mappings have been described, and images that don't map to anything say
+// what action is needed.
+func outputCustomerYAML(w io.Writer, mappings []*Mapping) error {
+	report := customerYAML{Images: []customerImage{}}
+	for _, m := range mappings {
+		image := customerImage{
+			Image:       m.Image,
+			Occurrences: m.Occurrences,
+			EOL:         m.EOL,
+			Warnings:    m.Warnings,
+		}
+		if len(m.Results) == 0 {
+			image.ActionNeeded = actionUnmapped
+		} else {
+			image.Chainguard = m.Results[0]
+			image.Alternatives = m.Results[1:]
+		}
+		if c := m.Catalog; c != nil {
+			image.Tier = c.Tier
+			image.TagExists = &c.TagExists
+			image.Entitled = c.Entitled
+		}
+		report.Images = append(report.Images, image)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("encoding YAML: %w", err)
+	}
+
+	return enc.Close()
+}
@@ -0,0 +1,150 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDescribe(t *testing.T) {
+	m := &mapper{
+		repos: []Repo{
+			{
+				Name:        "nginx",
+				CatalogTier: "APPLICATION",
+				ActiveTags:  []string{"1.25", "1.25.3", "latest"},
+			},
+			{
+				Name:        "nginx-fips",
+				CatalogTier: "FIPS",
+				ActiveTags:  []string{"1.25"},
+				Tags:        []Tag{{Name: "1.24"}},
+			},
+			{
+				Name:       "hidden",
+				ActiveTags: []string{"latest"},
+			},
+		},
+		repoName: "cgr.dev/chainguard",
+		repoRules: []repositoryRule{
+			{pattern: "FIPS", repo: "registry.internal/fips"},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		mapping *Mapping
+		want    *CatalogDetails
+	}{
+		{
+			name: "tag exists",
+			mapping: &Mapping{
+				Image:   "nginx:1.25.3",
+				Results: []string{"cgr.dev/chainguard/nginx:1.25.3"},
+			},
+			want: &CatalogDetails{Tier: "APPLICATION", TagExists: true},
+		},
+		{
+			name: "tag doesn't exist",
+			mapping: &Mapping{
+				Image:   "nginx:1.25.1",
+				Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+			},
+			want: &CatalogDetails{Tier: "APPLICATION"},
+		},
+		{
+			name: "default tag",
+			mapping: &Mapping{
+				Image:   "docker.io/library/nginx",
+				Results: []string{"cgr.dev/chainguard/nginx:latest"},
+			},
+			want: &CatalogDetails{Tier: "APPLICATION", TagExists: true},
+		},
+		{
+			name: "inactive tag and digest",
+			mapping: &Mapping{
+				Image:   "nginx:1.24@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				Results: []string{"registry.internal/fips/nginx-fips:1.25"},
+			},
+			want: &CatalogDetails{Tier: "FIPS", TagExists: true},
+		},
+		{
+			name: "wrong repository",
+			mapping: &Mapping{
+				Image:   "nginx:1.25",
+				Results: []string{"cgr.dev/chainguard/nginx-fips:1.25"},
+			},
+		},
+		{
+			name: "no catalog tier",
+			mapping: &Mapping{
+				Image:   "hidden:latest",
+				Results: []string{"cgr.dev/chainguard/hidden:latest"},
+			},
+		},
+		{
+			name: "no results",
+			mapping: &Mapping{
+				Image:   "nonexistent",
+				Results: []string{},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m.Describe(tc.mapping)
+			if diff := cmp.Diff(tc.want, tc.mapping.Catalog); diff != "" {
+				t.Errorf("unexpected details (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutputCustomerYAML(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:       "nginx:1.25.1",
+			Results:     []string{"cgr.dev/chainguard/nginx:1.25", "cgr.dev/chainguard/nginx-fips:1.25"},
+			Occurrences: 2,
+			Warnings:    []string{"cgr.dev/chainguard/nginx:1.25: changes the version"},
+			Catalog:     &CatalogDetails{Tier: "APPLICATION"},
+		},
+		{
+			Image:       "ghcr.io/stakater/reloader:v1.4.1",
+			Results:     []string{"cgr.dev/chainguard/stakater-reloader:v1.4.1"},
+			Occurrences: 1,
+		},
+		{
+			Image:       "nonexistent",
+			Results:     []string{},
+			Occurrences: 1,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := outputCustomerYAML(&buf, mappings); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `images:
+  - image: nginx:1.25.1
+    occurrences: 2
+    chainguard: cgr.dev/chainguard/nginx:1.25
+    alternatives:
+      - cgr.dev/chainguard/nginx-fips:1.25
+    tier: APPLICATION
+    tagExists: false
+    warnings:
+      - 'cgr.dev/chainguard/nginx:1.25: changes the version'
+  - image: ghcr.io/stakater/reloader:v1.4.1
+    occurrences: 1
+    chainguard: cgr.dev/chainguard/stakater-reloader:v1.4.1
+  - image: nonexistent
+    occurrences: 1
+    actionNeeded: No Chainguard image matches. Request the image from Chainguard, or find an alternative.
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,153 @@
+package mapper
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+)
+
+// Entitlement is a Chainguard repository that's needed to cover the images,
+// with the images that map to it
+type Entitlement struct {
+	// Repository is the repository of the recommended result, i.e
+	// cgr.dev/chainguard/nginx
+	Repository string
+
+	// Tier is the catalog tier of the repository, when the mappings have
+	// been described
+	Tier string
+
+	// Images are the upstream images that map to the repository
+	Images []string
+
+	// Entitled is whether the organization is entitled to the repository,
+	// when it's been checked
+	Entitled *bool
+}
+
+// Entitlements returns the distinct repositories of the first result of each
+// mapping, which is the recommended Chainguard image, sorted by name. Mappings
+// without results aren't included.
+func Entitlements(mappings ...*Mapping) []Entitlement {
+	entitlements := map[string]*Entitlement{}
+	for _, m := range mappings {
+		if len(m.Results) == 0 {
+			continue
+		}
+		repo := resultRepository(m.Results[0])
+
+		e, ok := entitlements[repo]
+		if !ok {
+			e = &Entitlement{Repository: repo}
+			entitlements[repo] = e
+		}
+		if c := m.Catalog; c != nil {
+			if c.Tier != "" {
+				e.Tier = c.Tier
+			}
+			if c.Entitled != nil {
+				e.Entitled = c.Entitled
+			}
+		}
+		if !slices.Contains(e.Images, m.Image) {
+			e.Images = append(e.Images, m.Image)
+		}
+	}
+
+	var sorted []Entitlement
+	for _, repo := range slices.Sorted(maps.Keys(entitlements)) {
+		sorted = append(sorted, *entitlements[repo])
+	}
+
+	return sorted
+}
+
+// SetEntitled records whether the repository of the first result of each
+// mapping is one of the entitled repositories, i.e the repositories that are
+// listed in the organization's registry
+func SetEntitled(entitled []string, mappings ...*Mapping) {
+	for _, m := range mappings {
+		if len(m.Results) == 0 {
+			continue
+		}
+		if m.Catalog == nil {
+			m.Catalog = &CatalogDetails{}
+		}
+		ok := slices.Contains(entitled, resultRepository(m.Results[0]))
+		m.Catalog.Entitled = &ok
+	}
+}
+
+// outputEntitlements writes the list of Chainguard repositories that are
+// needed to cover the images, with their tiers and how many images map to
+// each of them. Whether each repository is entitled is included when it's
+// been checked.
+func outputEntitlements(w io.Writer, mappings []*Mapping) error {
+	entitlements := Entitlements(mappings...)
+
+	checked := slices.ContainsFunc(entitlements, func(e Entitlement) bool {
+		return e.Entitled != nil
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if checked {
+		fmt.Fprintln(tw, "REPOSITORY\tTIER\tIMAGES\tENTITLED")
+	} else {
+		fmt.Fprintln(tw, "REPOSITORY\tTIER\tIMAGES")
+	}
+	missing := 0
+	for _, e := range entitlements {
+		tier := e.Tier
+		if tier == "" {
+			tier = "-"
+		}
+		if !checked {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", e.Repository, tier, len(e.Images))
+			continue
+		}
+
+		entitled := "-"
+		if e.Entitled != nil {
+			entitled = "no"
+			if *e.Entitled {
+				entitled = "yes"
+			} else {
+				missing++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", e.Repository, tier, len(e.Images), entitled)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing entitlements: %w", err)
+	}
+
+	mapped, unmapped := countImages(mappings)
+	fmt.Fprintf(w, "\n%d repos cover %d images", len(entitlements), mapped)
+	if checked {
+		fmt.Fprintf(w, ", %d of the repos aren't entitled", missing)
+	}
+	if unmapped > 0 {
+		fmt.Fprintf(w, ", %d images don't map to a Chainguard repo", unmapped)
+	}
+	fmt.Fprintln(w)
+
+	return nil
+}
+
+// countImages returns the number of distinct images that map to a Chainguard
+// image and the number that don't. An image can be in the mappings more than
+// once, like when it's found in several files.
+func countImages(mappings []*Mapping) (int, int) {
+	mapped, unmapped := map[string]bool{}, map[string]bool{}
+	for _, m := range mappings {
+		if len(m.Results) == 0 {
+			unmapped[m.Image] = true
+		} else {
+			mapped[m.Image] = true
+		}
+	}
+
+	return len(mapped), len(unmapped)
+}
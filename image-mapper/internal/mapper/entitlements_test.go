@@ -0,0 +1,66 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEntitlements(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:   "nginx:1.25",
+			Results: []string{"cgr.dev/example/nginx:1.25"},
+			Catalog: &CatalogDetails{Tier: "APPLICATION"},
+		},
+		{
+			Image:   "python:3.12",
+			Results: []string{"cgr.dev/example/python:3.12", "cgr.dev/example/python-fips:3.12"},
+			Catalog: &CatalogDetails{Tier: "BASE"},
+		},
+		{
+			Image:   "bitnami/nginx:1.27",
+			Results: []string{"cgr.dev/example/nginx:1.27"},
+			Catalog: &CatalogDetails{Tier: "APPLICATION"},
+		},
+		{
+			Image:   "nginx:1.25",
+			Results: []string{"cgr.dev/example/nginx:1.25"},
+			Catalog: &CatalogDetails{Tier: "APPLICATION"},
+		},
+		{
+			Image: "example/unknown:1.0",
+		},
+	}
+
+	want := `REPOSITORY              TIER         IMAGES
+cgr.dev/example/nginx   APPLICATION  2
+cgr.dev/example/python  BASE         1
+
+2 repos cover 3 images, 1 images don't map to a Chainguard repo
+`
+	var buf bytes.Buffer
+	if err := outputEntitlements(&buf, mappings); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+
+	SetEntitled([]string{"cgr.dev/example/nginx", "cgr.dev/example/python-fips"}, mappings...)
+
+	want = `REPOSITORY              TIER         IMAGES  ENTITLED
+cgr.dev/example/nginx   APPLICATION  2       yes
+cgr.dev/example/python  BASE         1       no
+
+2 repos cover 3 images, 1 of the repos aren't entitled, 1 images don't map to a Chainguard repo
+`
+	buf.Reset()
+	if err := outputEntitlements(&buf, mappings); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,78 @@
+package mapper
+
+import (
+	"fmt"
+)
+
+// EOL describes an upstream version that has no supported equivalent in the
+// Chainguard catalog, because the version has reached end of life or was
+// never built
+type EOL struct {
+	// Version is the upstream version, i.e 1.24
+	Version string `json:"version" yaml:"version"`
+
+	// Recommended is the nearest supported version, preferring the next
+	// version up, i.e 1.25. It's empty when the repository has no
+	// supported versions to recommend.
+	Recommended string `json:"recommended,omitempty" yaml:"recommended,omitempty"`
+
+	// Date is the date the version reached end of life, when the catalog
+	// includes it
+	Date string `json:"date,omitempty" yaml:"date,omitempty"`
+}
+
+// String describes the EOL version and the version to move to
+func (e *EOL) String() string {
+	s := fmt.Sprintf("%s has no supported version", e.Version)
+	if e.Date != "" {
+		s = fmt.Sprintf("%s reached end of life on %s", e.Version, e.Date)
+	}
+	if e.Recommended != "" {
+		s = fmt.Sprintf("%s, the nearest supported version is %s", s, e.Recommended)
+	}
+
+	return s
+}
+
+// versionEOL returns the EOL details of the tag when none of the active tags
+// of the repository are the same version. Patch versions are considered the
+// same version as their minor version, like they are by TagWarning. It
+// returns nil for tags that aren't versions, or when the repository has no
+// active tags to compare with.
+func versionEOL(repo Repo, tag string, filters ...TagFilter) *EOL {
+	parsedTag := parseTag(tag)
+	if parsedTag == nil {
+		return nil
+	}
+
+	active := repo.ActiveTags
+	for _, filter := range filters {
+		active = filter(active)
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	series := *parsedTag
+	if series.specificity == "PATCH" {
+		series.specificity = "MINOR"
+	}
+	for _, t := range active {
+		if parsedT := parseTag(t); parsedT != nil && parsedT.Within(&series) {
+			return nil
+		}
+	}
+
+	eol := &EOL{Version: tag}
+	eol.Recommended = matchClosestSemanticVersionTag(active, tag)
+	if eol.Recommended == "" {
+		eol.Recommended = nearestLowerTag(active, parsedTag)
+	}
+	for _, t := range repo.Tags {
+		if t.Name == tag {
+			eol.Date = t.EOLDate
+		}
+	}
+
+	return eol
+}
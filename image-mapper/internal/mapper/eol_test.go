@@ -0,0 +1,147 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVersionEOL(t *testing.T) {
+	repo := Repo{
+		Name:       "nginx",
+		ActiveTags: []string{"latest", "1.25", "1.25.5", "1.27", "1.27.1", "1.27-dev"},
+		Tags: []Tag{
+			{Name: "1.23", EOLDate: "2024-06-18"},
+			{Name: "1.25"},
+			{Name: "1.27"},
+		},
+	}
+
+	testCases := []struct {
+		tag  string
+		want *EOL
+	}{
+		{tag: "1.25"},
+		{tag: "1.25.1"},
+		{tag: "1.27-alpine"},
+		{tag: "latest"},
+		{tag: "mainline"},
+		{tag: "1"},
+		{
+			tag:  "1.26",
+			want: &EOL{Version: "1.26", Recommended: "1.27"},
+		},
+		{
+			tag:  "1.24.3",
+			want: &EOL{Version: "1.24.3", Recommended: "1.25.5"},
+		},
+		{
+			tag:  "1.23",
+			want: &EOL{Version: "1.23", Recommended: "1.25", Date: "2024-06-18"},
+		},
+		{
+			tag:  "1.28",
+			want: &EOL{Version: "1.28", Recommended: "1.27"},
+		},
+		{
+			tag:  "2.0.1",
+			want: &EOL{Version: "2.0.1", Recommended: "1.27.1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.tag, func(t *testing.T) {
+			got := versionEOL(repo, tc.tag, TagFilterExcludeDev)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected EOL (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVersionEOLNoActiveTags(t *testing.T) {
+	if got := versionEOL(Repo{Name: "nginx"}, "1.26"); got != nil {
+		t.Errorf("expected no EOL without active tags, got %s", got)
+	}
+}
+
+func TestMapperMapEOLInactiveTag(t *testing.T) {
+	m := &mapper{
+		repos: []Repo{
+			{
+				Name:        "nginx",
+				CatalogTier: "APPLICATION",
+				ActiveTags:  []string{"latest", "1.27", "1.27.1"},
+				Tags: []Tag{
+					{Name: "latest"},
+					{Name: "1.23", EOLDate: "2024-06-18"},
+					{Name: "1.27"},
+					{Name: "1.27.1"},
+				},
+			},
+		},
+		repoName: "cgr.dev/chainguard",
+	}
+
+	got, err := m.Map("nginx:1.23")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The inactive tag matches, so the only warning is that it's EOL
+	want := &Mapping{
+		Image:    "nginx:1.23",
+		Results:  []string{"cgr.dev/chainguard/nginx:1.23"},
+		EOL:      &EOL{Version: "1.23", Recommended: "1.27", Date: "2024-06-18"},
+		Warnings: []string{"cgr.dev/chainguard/nginx:1.23: 1.23 reached end of life on 2024-06-18, the nearest supported version is 1.27"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected mapping (-want +got):\n%s", diff)
+	}
+}
+
+func TestMapperMapEOLOneResult(t *testing.T) {
+	m := &mapper{
+		repos: []Repo{
+			{
+				Name:        "nginx",
+				CatalogTier: "APPLICATION",
+				ActiveTags:  []string{"latest", "1.25", "1.27"},
+			},
+			{
+				Name:        "nginx-fips",
+				CatalogTier: "FIPS",
+				Aliases:     []string{"nginx"},
+				ActiveTags:  []string{"latest", "1.27"},
+			},
+		},
+		repoName: "cgr.dev/chainguard",
+	}
+
+	got, err := m.Map("nginx:1.25")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// nginx supports 1.25, so the version isn't EOL, even though
+	// nginx-fips, which comes first, doesn't support it
+	if got.EOL != nil {
+		t.Errorf("expected no EOL, got %s", got.EOL)
+	}
+	if diff := cmp.Diff([]string{"cgr.dev/chainguard/nginx-fips:1.27", "cgr.dev/chainguard/nginx:1.25"}, got.Results); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+
+	got, err = m.Map("nginx:1.23")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Neither of them supports 1.23
+	if got.EOL == nil {
+		t.Fatalf("expected an EOL")
+	}
+	if diff := cmp.Diff("1.23", got.EOL.Version); diff != "" {
+		t.Errorf("unexpected EOL version (-want +got):\n%s", diff)
+	}
+}
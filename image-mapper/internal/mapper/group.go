@@ -0,0 +1,144 @@
+package mapper
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// The ways that mappings can be grouped in the output
+const (
+	// GroupByNone lists each mapping in the order it was mapped
+	GroupByNone = ""
+
+	// GroupByTarget groups the images under the Chainguard repository
+	// they map to
+	GroupByTarget = "target"
+)
+
+// groupedOutputs are the output formats that can be grouped, by name
+var groupedOutputs = map[string]Output{
+	"markdown": outputMarkdownGrouped,
+	"text":     outputTextGrouped,
+}
+
+// targetGroup is a Chainguard repository and the images that map to it
+type targetGroup struct {
+	target   string
+	mappings []*Mapping
+}
+
+// groupByTarget groups the mappings by the repositories of their results, in
+// alphabetical order. An image that maps to several repositories is in each
+// of their groups. The images that don't map to anything are grouped last,
+// with an empty target.
+func groupByTarget(mappings []*Mapping) []targetGroup {
+	var (
+		groups   = map[string]*targetGroup{}
+		unmapped []*Mapping
+	)
+	for _, m := range mappings {
+		if len(m.Results) == 0 {
+			unmapped = append(unmapped, m)
+			continue
+		}
+
+		var seen []string
+		for _, result := range m.Results {
+			target := resultRepository(result)
+			if slices.Contains(seen, target) {
+				continue
+			}
+			seen = append(seen, target)
+
+			g, ok := groups[target]
+			if !ok {
+				g = &targetGroup{target: target}
+				groups[target] = g
+			}
+			g.mappings = append(g.mappings, m)
+		}
+	}
+
+	var sorted []targetGroup
+	for _, target := range slices.Sorted(maps.Keys(groups)) {
+		sorted = append(sorted, *groups[target])
+	}
+	if len(unmapped) > 0 {
+		sorted = append(sorted, targetGroup{mappings: unmapped})
+	}
+
+	return sorted
+}
+
+// resultRepository returns the result without its tag, i.e
+// cgr.dev/chainguard/nginx for cgr.dev/chainguard/nginx:1.25
+func resultRepository(result string) string {
+	if i := strings.LastIndex(result, ":"); i > strings.LastIndex(result, "/") {
+		return result[:i]
+	}
+
+	return result
+}
+
+// outputTextGrouped writes the mappings like the text output, under the
+// Chainguard repositories they map to
+func outputTextGrouped(w io.Writer, mappings []*Mapping) error {
+	for i, g := range groupByTarget(mappings) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if g.target == "" {
+			fmt.Fprintln(w, "(unmapped)")
+		} else {
+			fmt.Fprintf(w, "%s (%d)\n", g.target, len(g.mappings))
+		}
+
+		for _, m := range g.mappings {
+			var results []string
+			for _, result := range m.Results {
+				if resultRepository(result) == g.target {
+					results = append(results, result)
+				}
+			}
+			if len(results) == 0 {
+				fmt.Fprintf(w, "  %s ->\n", m.Image)
+			} else {
+				fmt.Fprintf(w, "  %s -> %s\n", m.Image, strings.Join(results, ", "))
+			}
+			writeTextDetails(w, m, "    ")
+		}
+	}
+	return nil
+}
+
+// outputMarkdownGrouped writes a section for each Chainguard repository, with
+// a list of the images that map to it
+func outputMarkdownGrouped(w io.Writer, mappings []*Mapping) error {
+	for i, g := range groupByTarget(mappings) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if g.target == "" {
+			fmt.Fprintf(w, "## Unmapped (%d)\n\n", len(g.mappings))
+			for _, m := range g.mappings {
+				fmt.Fprintf(w, "- `%s`\n", m.Image)
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "## `%s` (%d)\n\n", g.target, len(g.mappings))
+		for _, m := range g.mappings {
+			var results []string
+			for _, result := range m.Results {
+				if resultRepository(result) == g.target {
+					results = append(results, "`"+result+"`")
+				}
+			}
+			fmt.Fprintf(w, "- `%s` -> %s\n", m.Image, strings.Join(results, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOutputGroupByTarget(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:   "nginx:1.25",
+			Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+		},
+		{
+			Image:   "python:3.12",
+			Results: []string{"cgr.dev/chainguard/python:3.12"},
+		},
+		{
+			Image:   "bitnami/nginx:1.27",
+			Results: []string{"cgr.dev/chainguard/nginx:1.27", "cgr.dev/chainguard/nginx-fips:1.27"},
+		},
+		{
+			Image: "example/unknown:1.0",
+		},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "text",
+			want: `cgr.dev/chainguard/nginx (2)
+  nginx:1.25 -> cgr.dev/chainguard/nginx:1.25
+  bitnami/nginx:1.27 -> cgr.dev/chainguard/nginx:1.27
+
+cgr.dev/chainguard/nginx-fips (1)
+  bitnami/nginx:1.27 -> cgr.dev/chainguard/nginx-fips:1.27
+
+cgr.dev/chainguard/python (1)
+  python:3.12 -> cgr.dev/chainguard/python:3.12
+
+(unmapped)
+  example/unknown:1.0 ->
+`,
+		},
+		{
+			format: "markdown",
+			want: "## `cgr.dev/chainguard/nginx` (2)\n\n" +
+				"- `nginx:1.25` -> `cgr.dev/chainguard/nginx:1.25`\n" +
+				"- `bitnami/nginx:1.27` -> `cgr.dev/chainguard/nginx:1.27`\n\n" +
+				"## `cgr.dev/chainguard/nginx-fips` (1)\n\n" +
+				"- `bitnami/nginx:1.27` -> `cgr.dev/chainguard/nginx-fips:1.27`\n\n" +
+				"## `cgr.dev/chainguard/python` (1)\n\n" +
+				"- `python:3.12` -> `cgr.dev/chainguard/python:3.12`\n\n" +
+				"## Unmapped (1)\n\n" +
+				"- `example/unknown:1.0`\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output, err := NewOutput(tc.format, WithGroupBy(GroupByTarget))
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewOutputGroupByErrors(t *testing.T) {
+	if _, err := NewOutput("json", WithGroupBy(GroupByTarget)); err == nil {
+		t.Errorf("expected an error grouping json")
+	}
+	if _, err := NewOutput("text", WithGroupBy("tier")); err == nil {
+		t.Errorf("expected an error for an unsupported group")
+	}
+}
+
+func TestResultRepository(t *testing.T) {
+	testCases := map[string]string{
+		"cgr.dev/chainguard/nginx:1.25":       "cgr.dev/chainguard/nginx",
+		"cgr.dev/chainguard/nginx":            "cgr.dev/chainguard/nginx",
+		"registry.local:5000/cg/nginx":        "registry.local:5000/cg/nginx",
+		"registry.local:5000/cg/nginx:1.25.5": "registry.local:5000/cg/nginx",
+	}
+	for result, want := range testCases {
+		if got := resultRepository(result); got != want {
+			t.Errorf("resultRepository(%s): expected %s, got %s", result, want, got)
+		}
+	}
+}
@@ -25,3 +25,30 @@ func IgnoreIamguarded() IgnoreFn {
 		return strings.HasSuffix(repo.Name, "iamguarded") || strings.HasSuffix(repo.Name, "iamguarded-fips")
 	}
 }
+
+// OnlyTiers ignores repos that aren't in the provided tiers
+func OnlyTiers(tiers []string) IgnoreFn {
+	ignore := IgnoreTiers(tiers)
+	return func(repo Repo) bool {
+		return !ignore(repo)
+	}
+}
+
+// FilterRepos returns the repos that the mapper would consider with the
+// provided IgnoreFns. Repos without a catalog tier are always excluded.
+func FilterRepos(repos []Repo, ignoreFns ...IgnoreFn) []Repo {
+	m := &mapper{ignoreFns: ignoreFns}
+
+	var filtered []Repo
+	for _, repo := range repos {
+		if repo.CatalogTier == "" {
+			continue
+		}
+		if m.ignoreRepo(repo) {
+			continue
+		}
+		filtered = append(filtered, repo)
+	}
+
+	return filtered
+}
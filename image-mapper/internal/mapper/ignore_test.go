@@ -227,3 +227,98 @@ func TestIgnoreIamguarded(t *testing.T) {
 		})
 	}
 }
+
+func TestOnlyTiers(t *testing.T) {
+	tests := []struct {
+		name       string
+		tiers      []string
+		repo       Repo
+		wantIgnore bool
+	}{
+		{
+			name:  "in tier",
+			tiers: []string{"APPLICATION"},
+			repo: Repo{
+				Name:        "test-repo",
+				CatalogTier: "APPLICATION",
+			},
+			wantIgnore: false,
+		},
+		{
+			name:  "in one of multiple tiers - case insensitive",
+			tiers: []string{"base", "application"},
+			repo: Repo{
+				Name:        "test-repo",
+				CatalogTier: "APPLICATION",
+			},
+			wantIgnore: false,
+		},
+		{
+			name:  "not in tier",
+			tiers: []string{"APPLICATION"},
+			repo: Repo{
+				Name:        "test-repo",
+				CatalogTier: "FIPS",
+			},
+			wantIgnore: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignoreFn := OnlyTiers(tt.tiers)
+			got := ignoreFn(tt.repo)
+			if got != tt.wantIgnore {
+				t.Errorf("OnlyTiers() = %v, want %v", got, tt.wantIgnore)
+			}
+		})
+	}
+}
+
+func TestFilterRepos(t *testing.T) {
+	repos := []Repo{
+		{Name: "nginx", CatalogTier: "APPLICATION"},
+		{Name: "nginx-fips", CatalogTier: "FIPS"},
+		{Name: "nginx-iamguarded", CatalogTier: "APPLICATION"},
+		{Name: "nginx-internal", CatalogTier: ""},
+	}
+
+	var got []string
+	for _, repo := range FilterRepos(repos, OnlyTiers([]string{"APPLICATION"}), IgnoreIamguarded()) {
+		got = append(got, repo.Name)
+	}
+	if len(got) != 1 || got[0] != "nginx" {
+		t.Errorf("FilterRepos() = %v, want [nginx]", got)
+	}
+
+	got = nil
+	for _, repo := range FilterRepos(repos) {
+		got = append(got, repo.Name)
+	}
+	if len(got) != 3 {
+		t.Errorf("FilterRepos() = %v, want 3 repos with tiers", got)
+	}
+}
+
+func TestWithIgnoreFns(t *testing.T) {
+	// The options of the dockerfile and helm mappers ignore some repos
+	// by default, and the ignore functions of the flags are added to
+	// them
+	o := newOptions(
+		WithIgnoreFns(IgnoreTiers([]string{"FIPS"})),
+		WithIgnoreFns(IgnoreIamguarded()),
+	)
+	repos := []Repo{
+		{Name: "nginx", CatalogTier: "APPLICATION"},
+		{Name: "nginx-fips", CatalogTier: "FIPS"},
+		{Name: "nginx-iamguarded", CatalogTier: "APPLICATION"},
+	}
+
+	var got []string
+	for _, repo := range FilterRepos(repos, o.ignoreFns...) {
+		got = append(got, repo.Name)
+	}
+	if len(got) != 1 || got[0] != "nginx" {
+		t.Errorf("FilterRepos() = %v, want [nginx]", got)
+	}
+}
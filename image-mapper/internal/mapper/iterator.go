@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"io"
+	"strings"
 )
 
 // ErrIteratorDone indicates when an iterator is finished
@@ -58,6 +59,11 @@ func NewArgsIterator(args []string) Iterator {
 	}
 }
 
+// Len returns the number of images
+func (it *argsIterator) Len() int {
+	return len(it.args)
+}
+
 // Next returns the next image
 func (it *argsIterator) Next() (string, error) {
 	if it.index >= len(it.args) {
@@ -69,3 +75,67 @@ func (it *argsIterator) Next() (string, error) {
 
 	return arg, nil
 }
+
+type listIterator struct {
+	scanner *bufio.Scanner
+	fields  []string
+}
+
+// NewListIterator iterates over a list of images in the given reader, like
+// a file exported from another tool. Images can be separated by any
+// whitespace. Blank lines are skipped and anything following a '#' is treated
+// as a comment.
+func NewListIterator(r io.Reader) Iterator {
+	return &listIterator{
+		scanner: bufio.NewScanner(r),
+	}
+}
+
+// Next returns the next image in the list
+func (it *listIterator) Next() (string, error) {
+	for len(it.fields) == 0 {
+		if !it.scanner.Scan() {
+			if it.scanner.Err() != nil {
+				return "", it.scanner.Err()
+			}
+
+			return "", ErrIteratorDone
+		}
+
+		line, _, _ := strings.Cut(it.scanner.Text(), "#")
+		it.fields = strings.Fields(line)
+	}
+
+	image := it.fields[0]
+	it.fields = it.fields[1:]
+
+	return image, nil
+}
+
+type multiIterator struct {
+	its []Iterator
+}
+
+// NewMultiIterator iterates over the images returned by each of the provided
+// iterators in turn
+func NewMultiIterator(its ...Iterator) Iterator {
+	return &multiIterator{
+		its: its,
+	}
+}
+
+// Next returns the next image from the current iterator, moving on to the next
+// iterator when it's done
+func (it *multiIterator) Next() (string, error) {
+	for len(it.its) > 0 {
+		image, err := it.its[0].Next()
+		if err == ErrIteratorDone {
+			it.its = it.its[1:]
+			continue
+		}
+
+		return image, err
+	}
+
+	return "", ErrIteratorDone
+}
@@ -171,3 +171,97 @@ type errorReader struct {
 func (r *errorReader) Read(p []byte) (n int, err error) {
 	return 0, r.err
 }
+
+func TestListIterator(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "empty input",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:     "newline delimited",
+			input:    "nginx\nredis\npostgres\n",
+			expected: []string{"nginx", "redis", "postgres"},
+		},
+		{
+			name:     "whitespace delimited",
+			input:    "nginx redis\tpostgres\n  busybox  ",
+			expected: []string{"nginx", "redis", "postgres", "busybox"},
+		},
+		{
+			name:     "comments and blank lines",
+			input:    "# exported from cluster scan\n\nnginx # the web server\n   \n#redis\npostgres",
+			expected: []string{"nginx", "postgres"},
+		},
+		{
+			name:     "only comments",
+			input:    "# nothing to see here\n# or here",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			iterator := NewListIterator(strings.NewReader(tc.input))
+
+			var results []string
+			for {
+				image, err := iterator.Next()
+				if err == ErrIteratorDone {
+					break
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				results = append(results, image)
+			}
+
+			if diff := cmp.Diff(tc.expected, results); diff != "" {
+				t.Errorf("results mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListIteratorError(t *testing.T) {
+	iterator := NewListIterator(&errorReader{err: errors.New("test error")})
+
+	if _, err := iterator.Next(); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestMultiIterator(t *testing.T) {
+	iterator := NewMultiIterator(
+		NewArgsIterator([]string{"nginx"}),
+		NewArgsIterator(nil),
+		NewListIterator(strings.NewReader("redis postgres")),
+	)
+
+	var results []string
+	for {
+		image, err := iterator.Next()
+		if err == ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results = append(results, image)
+	}
+
+	if diff := cmp.Diff([]string{"nginx", "redis", "postgres"}, results); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+
+	// Errors from the underlying iterators should be returned
+	iterator = NewMultiIterator(&errorIterator{err: errors.New("test error")})
+	if _, err := iterator.Next(); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
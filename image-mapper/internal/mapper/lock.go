@@ -0,0 +1,49 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockRetryInterval is how often we try to take a lock that's held by
+// another process
+const lockRetryInterval = 100 * time.Millisecond
+
+// lockFile takes an exclusive lock on the file at path, which is created if it
+// doesn't exist, waiting until the lock is released by other processes or the
+// context is cancelled. The returned function releases the lock.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	return func() {
+		_ = unlock(f)
+		f.Close()
+	}, nil
+}
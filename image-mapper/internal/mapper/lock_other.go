@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package mapper
+
+import "os"
+
+// tryLock is a no-op on platforms without file locks, where concurrent
+// processes may each fetch the catalog
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
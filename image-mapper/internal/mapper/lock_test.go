@@ -0,0 +1,35 @@
+package mapper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repos.json.lock")
+
+	unlock, err := lockFile(t.Context(), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The lock is held, so we give up when the context is done
+	ctx, cancel := context.WithTimeout(t.Context(), 3*lockRetryInterval)
+	defer cancel()
+	if _, err := lockFile(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	// Once it's released, it can be taken again
+	unlock()
+	ctx, cancel = context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	unlock, err = lockFile(ctx, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	unlock()
+}
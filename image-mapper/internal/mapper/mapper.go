@@ -3,9 +3,11 @@ package mapper
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"slices"
 	"strings"
 
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/progress"
 	"github.com/google/go-containerregistry/pkg/name"
 )
 
@@ -13,6 +15,123 @@ import (
 type Mapping struct {
 	Image   string   `json:"image"`
 	Results []string `json:"results,omitempty"`
+
+	// Occurrences is the number of times the image appeared in the input
+	Occurrences int `json:"occurrences,omitempty"`
+
+	// Digest is the digest the image was pinned to, when it was pinned to
+	// one. It doesn't apply to the results.
+	Digest string `json:"digest,omitempty"`
+
+	// EOL is set when the version of the image has no supported
+	// equivalent in any of the results, with the nearest supported
+	// version of the first result
+	EOL *EOL `json:"eol,omitempty"`
+
+	// ResolvedTag is the tag of the upstream image that the digest was
+	// resolved to, when the image was pinned to a digest without a tag.
+	// The results are chosen by this tag.
+	ResolvedTag string `json:"resolvedTag,omitempty"`
+
+	// Origin is the repository the image was originally pulled from, with
+	// its digest, when the image was pulled through a proxy with a path
+	// that doesn't match anything in the catalog. The image is mapped as
+	// this repository.
+	Origin string `json:"origin,omitempty"`
+
+	// Warnings highlight potential problems with the results, like a
+	// result that isn't the same version as the input
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Vulnerabilities compares the vulnerabilities in the image with
+	// those in the first result, when they've been scanned
+	Vulnerabilities *Vulnerabilities `json:"vulnerabilities,omitempty"`
+
+	// Sizes compares the size of the image with the size of the first
+	// result, when they've been measured
+	Sizes *Sizes `json:"sizes,omitempty"`
+
+	// Locations are where the image appears in the files it was found in,
+	// when it was found in files
+	Locations []Location `json:"locations,omitempty"`
+
+	// Catalog describes the first result in the catalog, when it's been
+	// looked up for the customer-yaml or entitlements outputs. It isn't
+	// part of the JSON output.
+	Catalog *CatalogDetails `json:"-"`
+}
+
+// Location is where an image reference appears in a file
+type Location struct {
+	Path string `json:"path"`
+
+	// Line is the line the reference is on, or zero if it isn't known
+	Line int `json:"line,omitempty"`
+
+	// YAMLPath is the path to the field that holds the reference in a
+	// YAML file, i.e spec.template.spec.containers.[0].image
+	YAMLPath string `json:"yamlPath,omitempty"`
+}
+
+// String returns the location like an editor or compiler would, i.e
+// deploy/app.yaml:12, followed by the YAML path if there is one
+func (l Location) String() string {
+	s := l.Path
+	if l.Line > 0 {
+		s = fmt.Sprintf("%s:%d", s, l.Line)
+	}
+	if l.YAMLPath != "" {
+		s = fmt.Sprintf("%s (%s)", s, l.YAMLPath)
+	}
+
+	return s
+}
+
+// CatalogDetails describes the repository of a result in the catalog
+type CatalogDetails struct {
+	// Tier is the catalog tier of the repository, i.e APPLICATION
+	Tier string
+
+	// TagExists is true when the tag of the upstream image is one of the
+	// tags of the repository, so the result doesn't change the version
+	TagExists bool
+
+	// Entitled is whether the organization is entitled to the
+	// repository, when it's been checked
+	Entitled *bool
+}
+
+// Sizes compares the size of an image with the size of the image it maps to
+type Sizes struct {
+	Image  ImageSize `json:"image"`
+	Result ImageSize `json:"result"`
+}
+
+// ImageSize is the compressed size of an image and the number of layers it
+// has
+type ImageSize struct {
+	Bytes  int64 `json:"bytes"`
+	Layers int   `json:"layers"`
+}
+
+// Vulnerabilities compares the vulnerabilities in an image with those in the
+// image it maps to
+type Vulnerabilities struct {
+	Image  VulnerabilityCounts `json:"image"`
+	Result VulnerabilityCounts `json:"result"`
+}
+
+// VulnerabilityCounts are the number of vulnerabilities in an image, by
+// severity
+type VulnerabilityCounts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+
+	// Other are the vulnerabilities with a negligible or unknown severity
+	Other int `json:"other"`
+	Total int `json:"total"`
 }
 
 // Mapper maps image references to images in our catalog
@@ -25,40 +144,100 @@ type mapper struct {
 	ignoreFns  []IgnoreFn
 	tagFilters []TagFilter
 	repoName   string
+	repoRules  []repositoryRule
+
+	// tagStrategy is how the tags of the results are chosen. The default
+	// is TagStrategyMapSemver.
+	tagStrategy string
+
+	// tierOrder is the order of the tiers the results are sorted by, in
+	// lower case
+	tierOrder []string
+
+	// results caches the mappings on disk, when it's enabled
+	results *resultCache
+
+	// digestResolver resolves the digests of images that are pinned to a
+	// digest without a tag, when it's configured
+	digestResolver DigestResolver
+
+	// originResolver finds the original repositories of images that are
+	// pinned to a digest and don't match anything, when it's configured
+	originResolver OriginResolver
+}
+
+// DigestResolver finds the tag of an image that's pinned to a digest, like
+// nginx@sha256:..., so that the image can be mapped by its version rather
+// than as latest
+type DigestResolver interface {
+	// ResolveDigest returns the tag of the repository that has the
+	// digest of the image
+	ResolveDigest(image string) (string, error)
+}
+
+// OriginResolver finds the repository that an image that's pinned to a
+// digest was originally pulled from, like docker.io/bitnami/nginx, when it was
+// pulled through a proxy or pull-through cache with a path that doesn't match
+// anything, like artifactory.internal/docker-remote/team-a/nginx@sha256:...
+type OriginResolver interface {
+	// ResolveOrigin returns the original repository of the image, with
+	// the digest, i.e docker.io/bitnami/nginx@sha256:...
+	ResolveOrigin(image string) (string, error)
 }
 
 // NewMapper creates a new mapper
 func NewMapper(ctx context.Context, opts ...Option) (*mapper, error) {
-	o := &options{
-		repo: "cgr.dev/chainguard",
-	}
-	for _, opt := range opts {
-		opt(o)
-	}
+	o := newOptions(opts...)
 
 	repoName, err := parseRepo(o.repo)
 	if err != nil {
 		return nil, fmt.Errorf("parsing repository: %w", err)
 	}
+	repoRules, err := parseRepositoryMap(o.repoMap)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository map: %w", err)
+	}
+	if err := validateTagStrategy(o.tagStrategy); err != nil {
+		return nil, err
+	}
 
-	repos, err := listRepos(ctx, o.inactiveTags)
+	repos, err := listRepos(ctx, o)
 	if err != nil {
 		return nil, fmt.Errorf("listing repos: %w", err)
 	}
 
 	m := &mapper{
-		repos:      repos,
-		ignoreFns:  o.ignoreFns,
-		tagFilters: o.tagFilters,
-		repoName:   repoName,
+		repos:       repos,
+		ignoreFns:   o.ignoreFns,
+		tagFilters:  o.tagFilters,
+		repoName:    repoName,
+		repoRules:   repoRules,
+		tagStrategy: o.tagStrategy,
+		tierOrder:   normalizeTiers(o.tierOrder),
+
+		digestResolver: o.digestResolver,
+		originResolver: o.originResolver,
+	}
+
+	if o.resultCache {
+		results, err := newResultCache(o.cacheDir, m, o.repoMap)
+		if err != nil {
+			return nil, fmt.Errorf("constructing result cache: %w", err)
+		}
+		m.results = results
 	}
 
 	return m, nil
 }
 
-// MapAll returns mappings for all the images returned by the iterator
+// MapAll returns mappings for all the images returned by the iterator. Each
+// unique image is only mapped once, with the number of times it appeared in the
+// input recorded in the mapping.
 func (m *mapper) MapAll(it Iterator) ([]*Mapping, error) {
-	mapped := make(map[string]struct{})
+	p := startProgress(it)
+	defer p.Done()
+
+	mapped := make(map[string]*Mapping)
 	mappings := []*Mapping{}
 	for {
 		image, err := it.Next()
@@ -68,8 +247,10 @@ func (m *mapper) MapAll(it Iterator) ([]*Mapping, error) {
 		if err != nil {
 			return nil, fmt.Errorf("iterating over images: %w", err)
 		}
+		p.Add(1)
 
-		if _, ok := mapped[image]; ok {
+		if mapping, ok := mapped[image]; ok {
+			mapping.Occurrences++
 			continue
 		}
 
@@ -78,22 +259,220 @@ func (m *mapper) MapAll(it Iterator) ([]*Mapping, error) {
 			return nil, fmt.Errorf("mapping image %s: %w", image, err)
 		}
 
+		mapping.Occurrences = 1
+
 		mappings = append(mappings, mapping)
-		mapped[image] = struct{}{}
+		mapped[image] = mapping
 	}
 
 	return mappings, nil
 }
 
+// startProgress reports the progress of mapping the images, when the number
+// of images is known up front, like with a list of images from a cluster or
+// a registry
+func startProgress(it Iterator) *progress.Tracker {
+	l, ok := it.(interface{ Len() int })
+	if !ok {
+		return nil
+	}
+
+	return progress.Start("mapping images", l.Len())
+}
+
+// MapEach maps the images returned by the iterator, calling fn with each
+// mapping as soon as it's produced. Each unique image is only mapped once.
+//
+// Unlike MapAll, it doesn't hold on to the mappings, which means it can handle
+// very large inputs. The trade off is that the mappings don't include the
+// number of occurrences, because that isn't known until the end.
+func (m *mapper) MapEach(it Iterator, fn func(*Mapping) error) error {
+	p := startProgress(it)
+	defer p.Done()
+
+	mapped := make(map[string]struct{})
+	for {
+		image, err := it.Next()
+		if err == ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("iterating over images: %w", err)
+		}
+		p.Add(1)
+
+		if _, ok := mapped[image]; ok {
+			continue
+		}
+		mapped[image] = struct{}{}
+
+		mapping, err := m.Map(image)
+		if err != nil {
+			return fmt.Errorf("mapping image %s: %w", image, err)
+		}
+
+		if err := fn(mapping); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Map an upstream image to the corresponding images in chainguard-private
 func (m *mapper) Map(image string) (*Mapping, error) {
-	ref, err := name.NewTag(strings.Split(image, "@")[0])
+	if m.results == nil {
+		return m.mapImage(image)
+	}
+
+	if mapping, ok := m.results.get(image); ok {
+		slog.Debug("cached", "image", image, "results", len(mapping.Results))
+		return mapping, nil
+	}
+	mapping, err := m.mapImage(image)
+	if err != nil {
+		return nil, err
+	}
+	m.results.put(mapping)
+
+	return mapping, nil
+}
+
+func (m *mapper) mapImage(image string) (*Mapping, error) {
+	// The digest of the upstream image won't apply to the Chainguard
+	// image, so we map the repository and tag and drop the digest
+	repoTag, digest, hasDigest := strings.Cut(image, "@")
+	ref, err := name.NewTag(repoTag)
 	if err != nil {
 		return nil, fmt.Errorf("parsing %s: %w", image, err)
 	}
 
+	ref, resolvedTag, resolveWarning, err := m.resolveDigest(image, ref, hasDigest)
+	if err != nil {
+		return nil, err
+	}
+
 	// Identify repositories in the Chainguard catalog that match the
 	// provided image
+	matches := m.match(image, ref)
+
+	// Images pulled through a proxy may have a path that doesn't match
+	// anything, so look for the repository they came from and map that
+	// instead
+	var origin string
+	if len(matches) == 0 && hasDigest && !hasTag(repoTag) && m.originResolver != nil {
+		o, err := m.originResolver.ResolveOrigin(image)
+		if err != nil {
+			slog.Debug("couldn't find the origin of the image", "image", image, "err", err)
+		} else {
+			slog.Debug("found origin", "image", image, "origin", o)
+			origin = o
+			originRepo, _, _ := strings.Cut(o, "@")
+			ref, err = name.NewTag(originRepo)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s: %w", o, err)
+			}
+			ref, resolvedTag, resolveWarning, err = m.resolveDigest(o, ref, true)
+			if err != nil {
+				return nil, err
+			}
+			matches = m.match(image, ref)
+		}
+	}
+
+	// Format the matches into the results we'll include in the mappings
+	results := []string{}
+	warnings := []string{}
+	eols := map[string]*EOL{}
+	tiers := map[string]string{}
+	for _, cgrrepo := range matches {
+		// Append the repository name to the rest of the reference
+		result := fmt.Sprintf("%s/%s", m.repository(cgrrepo), cgrrepo.Name)
+
+		// Filter the tags based on the configured filters
+		tags := filterTags(cgrrepo, m.tagFilters...)
+
+		// Choose the tag with the configured strategy, which by
+		// default matches the provided tag to one of the tags
+		tag, warning := chooseTag(m.tagStrategy, tags, ref.TagStr())
+		if tag != "" {
+			result = fmt.Sprintf("%s:%s", result, tag)
+		}
+		slog.Debug("matched tag", "image", image, "repository", cgrrepo.Name, "tag", ref.TagStr(), "match", tag, "strategy", m.tagStrategy, "candidates", len(tags))
+		results = append(results, result)
+		tiers[result] = cgrrepo.CatalogTier
+
+		// Warn when the tag isn't equivalent to the input, so that
+		// upgrades and downgrades don't happen silently
+		if warning != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", result, warning))
+		}
+
+		// Flag versions that aren't supported any more, so that the
+		// upgrade is planned, even when an inactive tag matches
+		if eol := versionEOL(cgrrepo, ref.TagStr(), m.tagFilters...); eol != nil {
+			eols[result] = eol
+			if warning == "" {
+				warnings = append(warnings, fmt.Sprintf("%s: %s", result, eol))
+			}
+		}
+
+		// Warn when we drop the digest, so that it's clear that the
+		// result needs to be pinned again
+		if hasDigest {
+			warnings = append(warnings, fmt.Sprintf("%s: dropped digest %s, which doesn't apply to the Chainguard image", result, digest))
+		}
+	}
+	m.sortResults(results, tiers)
+	slices.Sort(warnings)
+
+	mapping := &Mapping{
+		Image:       image,
+		Results:     results,
+		Digest:      digest,
+		ResolvedTag: resolvedTag,
+		Origin:      origin,
+	}
+	// The version is only EOL when none of the results support it. The
+	// results that don't are flagged in the warnings.
+	if len(results) > 0 && len(eols) == len(results) {
+		mapping.EOL = eols[results[0]]
+	}
+	if resolveWarning != "" {
+		warnings = append(warnings, resolveWarning)
+	}
+	if len(warnings) > 0 {
+		mapping.Warnings = warnings
+	}
+
+	return mapping, nil
+}
+
+// resolveDigest finds the tag that the digest of an image that's only pinned
+// to a digest belongs to, so that it isn't mapped like latest. It returns the
+// reference with the tag, the tag and a warning if it couldn't be resolved.
+func (m *mapper) resolveDigest(image string, ref name.Tag, hasDigest bool) (name.Tag, string, string, error) {
+	repoTag, digest, _ := strings.Cut(image, "@")
+	if !hasDigest || hasTag(repoTag) || m.digestResolver == nil {
+		return ref, "", "", nil
+	}
+
+	tag, err := m.digestResolver.ResolveDigest(image)
+	if err != nil {
+		return ref, "", fmt.Sprintf("couldn't resolve digest %s to a tag, so the image was mapped like latest: %s", digest, err), nil
+	}
+	resolved, err := name.NewTag(repoTag + ":" + tag)
+	if err != nil {
+		return ref, "", "", fmt.Errorf("parsing %s: %w", image, err)
+	}
+	slog.Debug("resolved digest", "image", image, "tag", tag)
+
+	return resolved, tag, "", nil
+}
+
+// match returns the repositories in the catalog that match the reference,
+// keyed by their names
+func (m *mapper) match(image string, ref name.Tag) map[string]Repo {
 	matches := map[string]Repo{}
 	for _, cgrrepo := range m.repos {
 		// There are some images that may appear in the results but are
@@ -104,37 +483,50 @@ func (m *mapper) Map(image string) (*Mapping, error) {
 		}
 
 		if m.ignoreRepo(cgrrepo) {
+			// Only trace the repositories that would have
+			// matched, otherwise every ignored repository would
+			// be logged for every image
+			if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+				if rule, ok := matchRule(ref, cgrrepo); ok {
+					slog.Debug("ignored", "image", image, "repository", cgrrepo.Name, "tier", cgrrepo.CatalogTier, "match", rule)
+				}
+			}
 			continue
 		}
 
-		if !Match(ref, cgrrepo) {
+		rule, ok := matchRule(ref, cgrrepo)
+		if !ok {
 			continue
 		}
+		slog.Debug("matched", "image", image, "repository", cgrrepo.Name, "tier", cgrrepo.CatalogTier, "match", rule)
 		matches[cgrrepo.Name] = cgrrepo
 	}
+	if len(matches) == 0 {
+		slog.Debug("no matches", "image", image)
+	}
 
-	// Format the matches into the results we'll include in the mappings
-	results := []string{}
-	for _, cgrrepo := range matches {
-		// Append the repository name to the rest of the reference
-		result := fmt.Sprintf("%s/%s", m.repoName, cgrrepo.Name)
+	return matches
+}
 
-		// Filter the tags based on the configured filters
-		tags := filterTags(cgrrepo, m.tagFilters...)
+// hasTag returns true if the reference, without a digest, has a tag, like
+// nginx:1.25 or registry.local:5000/nginx:1.25, rather than relying on the
+// default tag
+func hasTag(repoTag string) bool {
+	i := strings.LastIndex(repoTag, ":")
 
-		// Try and match the provided tag to one of the tags
-		tag := MatchTag(tags, ref.TagStr())
-		if tag != "" {
-			result = fmt.Sprintf("%s:%s", result, tag)
+	return i > strings.LastIndex(repoTag, "/")
+}
+
+// repository returns the repository prefix of the results for the Chainguard
+// repository
+func (m *mapper) repository(repo Repo) string {
+	for _, rule := range m.repoRules {
+		if rule.matches(repo) {
+			return rule.repo
 		}
-		results = append(results, result)
 	}
-	slices.Sort(results)
 
-	return &Mapping{
-		Image:   image,
-		Results: results,
-	}, nil
+	return m.repoName
 }
 
 func (m *mapper) ignoreRepo(repo Repo) bool {
@@ -151,19 +543,40 @@ func (m *mapper) ignoreRepo(repo Repo) bool {
 // MapImage maps the provided image to its Chainguard equivalent. It returns the
 // first result it finds.
 func MapImage(m Mapper, img string) (name.Reference, error) {
+	mapped, warnings, err := MapImageWithWarnings(m, img)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		slog.Warn(warning, "image", img)
+	}
+
+	return mapped, nil
+}
+
+// MapImageWithWarnings is like MapImage, but it returns the warnings about the
+// result, rather than logging them.
+func MapImageWithWarnings(m Mapper, img string) (name.Reference, []string, error) {
 	mapping, err := m.Map(img)
 	if err != nil {
-		return nil, fmt.Errorf("mapping image: %s: %w", img, err)
+		return nil, nil, fmt.Errorf("mapping image: %s: %w", img, err)
 	}
 	if len(mapping.Results) == 0 {
-		return nil, fmt.Errorf("no results found")
+		return nil, nil, fmt.Errorf("no results found")
 	}
 	result := mapping.Results[0]
 
+	var warnings []string
+	for _, warning := range mapping.Warnings {
+		if strings.HasPrefix(warning, result+": ") {
+			warnings = append(warnings, warning)
+		}
+	}
+
 	mapped, err := name.NewTag(result)
 	if err != nil {
-		return nil, fmt.Errorf("parsing mapped image: %w", err)
+		return nil, nil, fmt.Errorf("parsing mapped image: %w", err)
 	}
 
-	return mapped, nil
+	return mapped, warnings, nil
 }
@@ -2,6 +2,7 @@ package mapper
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -87,6 +88,53 @@ func TestMapperMap(t *testing.T) {
 				Results: []string{"cgr.dev/chainguard/nginx"},
 			},
 		},
+		{
+			name:  "registry with a port",
+			image: "registry.local:5000/team/nginx",
+			repos: []Repo{
+				{
+					Name:        "nginx",
+					CatalogTier: "APPLICATION",
+					Aliases:     []string{},
+				},
+			},
+			expected: &Mapping{
+				Image:   "registry.local:5000/team/nginx",
+				Results: []string{"cgr.dev/chainguard/nginx"},
+			},
+		},
+		{
+			name:  "registry with a port and a tag",
+			image: "localhost:5000/nginx:1.25",
+			repos: []Repo{
+				{
+					Name:        "nginx",
+					CatalogTier: "APPLICATION",
+					Aliases:     []string{},
+					ActiveTags:  []string{"1.25"},
+				},
+			},
+			expected: &Mapping{
+				Image:   "localhost:5000/nginx:1.25",
+				Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+			},
+		},
+		{
+			name:  "registry with a port matches an alias",
+			image: "10.0.0.1:5000/bitnami/nginx:latest",
+			repos: []Repo{
+				{
+					Name:        "nginx-bitnami",
+					CatalogTier: "APPLICATION",
+					Aliases:     []string{"bitnami/nginx"},
+					ActiveTags:  []string{"latest"},
+				},
+			},
+			expected: &Mapping{
+				Image:   "10.0.0.1:5000/bitnami/nginx:latest",
+				Results: []string{"cgr.dev/chainguard/nginx-bitnami:latest"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -114,6 +162,239 @@ func TestMapperMap(t *testing.T) {
 	}
 }
 
+func TestMapperMapWarnings(t *testing.T) {
+	m := &mapper{
+		repos: []Repo{
+			{
+				Name:        "nginx",
+				CatalogTier: "APPLICATION",
+				ActiveTags:  []string{"latest", "1.25", "1.25.5", "1.27", "1.27.1"},
+			},
+		},
+		repoName: "cgr.dev/chainguard",
+	}
+
+	testCases := []struct {
+		image    string
+		expected *Mapping
+	}{
+		{
+			image: "nginx:1.25.1",
+			expected: &Mapping{
+				Image:   "nginx:1.25.1",
+				Results: []string{"cgr.dev/chainguard/nginx:1.25.5"},
+			},
+		},
+		{
+			image: "nginx:1.26",
+			expected: &Mapping{
+				Image:    "nginx:1.26",
+				Results:  []string{"cgr.dev/chainguard/nginx:1.27"},
+				EOL:      &EOL{Version: "1.26", Recommended: "1.27"},
+				Warnings: []string{"cgr.dev/chainguard/nginx:1.27: no tag equivalent to 1.26, using the nearest available version 1.27"},
+			},
+		},
+		{
+			image: "nginx:1.28",
+			expected: &Mapping{
+				Image:    "nginx:1.28",
+				Results:  []string{"cgr.dev/chainguard/nginx"},
+				EOL:      &EOL{Version: "1.28", Recommended: "1.27"},
+				Warnings: []string{"cgr.dev/chainguard/nginx: no tag equivalent to 1.28, the nearest available version is 1.27"},
+			},
+		},
+		{
+			image: "nginx:1.25@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+			expected: &Mapping{
+				Image:    "nginx:1.25@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+				Digest:   "sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+				Results:  []string{"cgr.dev/chainguard/nginx:1.25"},
+				Warnings: []string{"cgr.dev/chainguard/nginx:1.25: dropped digest sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a, which doesn't apply to the Chainguard image"},
+			},
+		},
+		{
+			image: "nginx@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+			expected: &Mapping{
+				Image:    "nginx@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+				Digest:   "sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+				Results:  []string{"cgr.dev/chainguard/nginx:latest"},
+				Warnings: []string{"cgr.dev/chainguard/nginx:latest: dropped digest sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a, which doesn't apply to the Chainguard image"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			result, err := m.Map(tc.image)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, result); diff != "" {
+				t.Errorf("mapping mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeDigestResolver resolves digests from a map of images to tags
+type fakeDigestResolver map[string]string
+
+func (r fakeDigestResolver) ResolveDigest(image string) (string, error) {
+	tag, ok := r[image]
+	if !ok {
+		return "", fmt.Errorf("no tag has the digest")
+	}
+
+	return tag, nil
+}
+
+func TestMapperMapDigestResolver(t *testing.T) {
+	const (
+		digest   = "sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a"
+		unknown  = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		resolved = "1.25.1"
+	)
+
+	m := &mapper{
+		repos: []Repo{
+			{
+				Name:        "nginx",
+				CatalogTier: "APPLICATION",
+				ActiveTags:  []string{"latest", "1.25", "1.25.5", "1.27", "1.27.1"},
+			},
+		},
+		repoName: "cgr.dev/chainguard",
+		digestResolver: fakeDigestResolver{
+			"nginx@" + digest: resolved,
+		},
+	}
+
+	testCases := []struct {
+		image    string
+		expected *Mapping
+	}{
+		{
+			image: "nginx@" + digest,
+			expected: &Mapping{
+				Image:       "nginx@" + digest,
+				Results:     []string{"cgr.dev/chainguard/nginx:1.25.5"},
+				Digest:      digest,
+				ResolvedTag: resolved,
+				Warnings:    []string{"cgr.dev/chainguard/nginx:1.25.5: dropped digest " + digest + ", which doesn't apply to the Chainguard image"},
+			},
+		},
+		{
+			image: "nginx:1.27@" + digest,
+			expected: &Mapping{
+				Image:    "nginx:1.27@" + digest,
+				Results:  []string{"cgr.dev/chainguard/nginx:1.27"},
+				Digest:   digest,
+				Warnings: []string{"cgr.dev/chainguard/nginx:1.27: dropped digest " + digest + ", which doesn't apply to the Chainguard image"},
+			},
+		},
+		{
+			image: "nginx@" + unknown,
+			expected: &Mapping{
+				Image:   "nginx@" + unknown,
+				Results: []string{"cgr.dev/chainguard/nginx:latest"},
+				Digest:  unknown,
+				Warnings: []string{
+					"cgr.dev/chainguard/nginx:latest: dropped digest " + unknown + ", which doesn't apply to the Chainguard image",
+					"couldn't resolve digest " + unknown + " to a tag, so the image was mapped like latest: no tag has the digest",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			result, err := m.Map(tc.image)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, result); diff != "" {
+				t.Errorf("mapping mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeOriginResolver resolves origins from a map of images to origins
+type fakeOriginResolver map[string]string
+
+func (r fakeOriginResolver) ResolveOrigin(image string) (string, error) {
+	origin, ok := r[image]
+	if !ok {
+		return "", fmt.Errorf("no repository has the digest")
+	}
+
+	return origin, nil
+}
+
+func TestMapperMapOriginResolver(t *testing.T) {
+	const digest = "sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a"
+
+	m := &mapper{
+		repos: []Repo{
+			{
+				Name:        "nginx",
+				CatalogTier: "APPLICATION",
+				ActiveTags:  []string{"latest", "1.25", "1.25.5", "1.27", "1.27.1"},
+			},
+		},
+		repoName: "cgr.dev/chainguard",
+		originResolver: fakeOriginResolver{
+			"artifactory.internal/docker-remote/web@" + digest: "docker.io/library/nginx@" + digest,
+		},
+		digestResolver: fakeDigestResolver{
+			"docker.io/library/nginx@" + digest: "1.25.1",
+		},
+	}
+
+	testCases := []struct {
+		image    string
+		expected *Mapping
+	}{
+		{
+			image: "artifactory.internal/docker-remote/web@" + digest,
+			expected: &Mapping{
+				Image:       "artifactory.internal/docker-remote/web@" + digest,
+				Results:     []string{"cgr.dev/chainguard/nginx:1.25.5"},
+				Digest:      digest,
+				ResolvedTag: "1.25.1",
+				Origin:      "docker.io/library/nginx@" + digest,
+				Warnings:    []string{"cgr.dev/chainguard/nginx:1.25.5: dropped digest " + digest + ", which doesn't apply to the Chainguard image"},
+			},
+		},
+		{
+			image: "artifactory.internal/docker-remote/unknown@" + digest,
+			expected: &Mapping{
+				Image:   "artifactory.internal/docker-remote/unknown@" + digest,
+				Results: []string{},
+				Digest:  digest,
+				Warnings: []string{
+					"couldn't resolve digest " + digest + " to a tag, so the image was mapped like latest: no tag has the digest",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			result, err := m.Map(tc.image)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, result); diff != "" {
+				t.Errorf("mapping mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestMapperMapInvalidImage(t *testing.T) {
 	m := &mapper{
 		repos: []Repo{},
@@ -154,16 +435,19 @@ func TestMapperMapAll(t *testing.T) {
 
 	expected := []*Mapping{
 		{
-			Image:   "nginx",
-			Results: []string{"cgr.dev/chainguard/nginx"},
+			Image:       "nginx",
+			Results:     []string{"cgr.dev/chainguard/nginx"},
+			Occurrences: 1,
 		},
 		{
-			Image:   "redis",
-			Results: []string{"cgr.dev/chainguard/redis"},
+			Image:       "redis",
+			Results:     []string{"cgr.dev/chainguard/redis"},
+			Occurrences: 1,
 		},
 		{
-			Image:   "postgres",
-			Results: []string{},
+			Image:       "postgres",
+			Results:     []string{},
+			Occurrences: 1,
 		},
 	}
 
@@ -200,15 +484,18 @@ func TestMapperMapAllDuplicates(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Should only have unique results
+	// Should only have unique results, with the number of times each
+	// image appeared
 	expected := []*Mapping{
 		{
-			Image:   "nginx",
-			Results: []string{"cgr.dev/chainguard/nginx"},
+			Image:       "nginx",
+			Results:     []string{"cgr.dev/chainguard/nginx"},
+			Occurrences: 2,
 		},
 		{
-			Image:   "redis",
-			Results: []string{},
+			Image:       "redis",
+			Results:     []string{},
+			Occurrences: 1,
 		},
 	}
 
@@ -226,6 +513,58 @@ func TestMapperMapAllDuplicates(t *testing.T) {
 	}
 }
 
+func TestMapperMapEach(t *testing.T) {
+	m := &mapper{
+		repos: []Repo{
+			{
+				Name:        "nginx",
+				CatalogTier: "APPLICATION",
+				Aliases:     []string{},
+			},
+		},
+		repoName: "cgr.dev/chainguard",
+	}
+
+	iterator := NewArgsIterator([]string{"nginx", "redis", "nginx"})
+
+	var results []*Mapping
+	if err := m.MapEach(iterator, func(mapping *Mapping) error {
+		results = append(results, mapping)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Duplicates should be skipped and occurrences aren't counted
+	expected := []*Mapping{
+		{
+			Image:   "nginx",
+			Results: []string{"cgr.dev/chainguard/nginx"},
+		},
+		{
+			Image:   "redis",
+			Results: []string{},
+		},
+	}
+	if diff := cmp.Diff(expected, results); diff != "" {
+		t.Errorf("mapping results mismatch (-want +got):\n%s", diff)
+	}
+
+	// Errors returned by the callback should stop the iteration
+	expectedErr := errors.New("write error")
+	calls := 0
+	err := m.MapEach(NewArgsIterator([]string{"nginx", "redis"}), func(mapping *Mapping) error {
+		calls++
+		return expectedErr
+	})
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("expected callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
 func TestMapperMapAllIteratorError(t *testing.T) {
 	m := &mapper{
 		repos: []Repo{},
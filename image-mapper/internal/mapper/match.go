@@ -11,25 +11,39 @@ import (
 // Match returns true if the container image described by the reference
 // matches the provided Chainguard repostory
 func Match(ref name.Reference, repo Repo) bool {
-	for _, fn := range matchFns {
-		if !fn(ref, repo) {
+	_, ok := matchRule(ref, repo)
+
+	return ok
+}
+
+// matchRule returns the name of the rule that matched the reference to the
+// repository, for tracing why an image was mapped
+func matchRule(ref name.Reference, repo Repo) (string, bool) {
+	for _, rule := range matchFns {
+		if !rule.fn(ref, repo) {
 			continue
 		}
+		if rule.name == "alias" {
+			return fmt.Sprintf("alias %s", matchingAlias(ref, repo)), true
+		}
 
-		return true
+		return rule.name, true
 	}
 
-	return false
+	return "", false
 }
 
 // MatchFn checks whether a given reference corresponds to a Chainguard repo
 type MatchFn func(ref name.Reference, repo Repo) bool
 
-var matchFns = []MatchFn{
-	matchBasename,
-	matchDashname,
-	matchIamguarded,
-	matchAliases,
+var matchFns = []struct {
+	name string
+	fn   MatchFn
+}{
+	{name: "basename", fn: matchBasename},
+	{name: "dashname", fn: matchDashname},
+	{name: "iamguarded", fn: matchIamguarded},
+	{name: "alias", fn: matchAliases},
 }
 
 // matchBasename matches Chainguard images that match the basename of the
@@ -78,6 +92,12 @@ func matchIamguarded(ref name.Reference, repo Repo) bool {
 // matchAliases uses the Chainguard repository's aliases to match against the
 // upstream reference
 func matchAliases(ref name.Reference, repo Repo) bool {
+	return matchingAlias(ref, repo) != ""
+}
+
+// matchingAlias returns the first of the Chainguard repository's aliases that
+// matches the upstream reference, or an empty string if none of them do
+func matchingAlias(ref name.Reference, repo Repo) string {
 	urepo := ref.Context().String()
 	urepoStr := ref.Context().RepositoryStr()
 
@@ -93,14 +113,14 @@ func matchAliases(ref name.Reference, repo Repo) bool {
 		// Match if the full repository (ghcr.io/foo/bar) matches the
 		// alias.
 		if urepo == arepo {
-			return true
+			return alias
 		}
 
 		// Match if the repository name (foo/bar) matches the repository
 		// name of the alias. This might be the case if the customer is
 		// mirroring an upstream image into another registry.
 		if urepoStr == arepoStr {
-			return true
+			return alias
 		}
 
 		// Match if upstream repository name (foo-bar) matches the
@@ -108,10 +128,10 @@ func matchAliases(ref name.Reference, repo Repo) bool {
 		// /. This could happen if a customer is copying an image to a
 		// mirror and flattening the name.
 		if urepoStr == arepoDashStr {
-			return true
+			return alias
 		}
 
 	}
 
-	return false
+	return ""
 }
@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
 )
@@ -25,6 +26,7 @@ type MatchTagFn func(tags []string, tag string) string
 
 var matchTagFns = []MatchTagFn{
 	matchEqualTag,
+	matchEquivalentTag,
 	matchClosestSemanticVersionTag,
 }
 
@@ -41,6 +43,126 @@ func matchEqualTag(tags []string, tag string) string {
 	return ""
 }
 
+// matchEquivalentTag finds the highest tag that is more specific than the input
+// tag, but equivalent to it. This handles repos that only publish more specific
+// tags than the input.
+//
+// For instance:
+//
+//	1.25 -> 1.25.5
+//	3 -> 3.14.2
+func matchEquivalentTag(tags []string, tag string) string {
+	parsedTag := parseTag(tag)
+	if parsedTag == nil {
+		return ""
+	}
+
+	var (
+		bestMatch    *tagVersion
+		bestMatchStr string
+	)
+	for _, t := range tags {
+		parsedT := parseTag(t)
+		if parsedT == nil {
+			continue
+		}
+
+		// If there's a tag for the same version with the same
+		// specificity (i.e 3.14 for 3.14-alpine), then that's a
+		// better match.
+		if parsedT.specificity == parsedTag.specificity && parsedT.Equals(parsedTag) {
+			return ""
+		}
+
+		if specificityRank(parsedT.specificity) <= specificityRank(parsedTag.specificity) {
+			continue
+		}
+
+		if !parsedT.Within(parsedTag) {
+			continue
+		}
+
+		if bestMatch != nil {
+			if parsedT.LessThan(bestMatch) {
+				continue
+			}
+			if parsedT.Equals(bestMatch) && specificityRank(parsedT.specificity) > specificityRank(bestMatch.specificity) {
+				continue
+			}
+			if parsedT.Equals(bestMatch) && parsedT.hasV != parsedTag.hasV {
+				continue
+			}
+		}
+
+		bestMatch = parsedT
+		bestMatchStr = t
+	}
+
+	return bestMatchStr
+}
+
+// TagWarning returns a warning when the matched tag isn't equivalent to the
+// input tag, so that upgrades and downgrades don't happen silently. It returns
+// an empty string if the tags are equivalent, or if the input tag isn't a
+// version.
+//
+// Patch upgrades (i.e 1.25.1 -> 1.25.5) are considered equivalent because
+// moving to the latest patch is exactly what we want to encourage.
+func TagWarning(tags []string, tag, match string) string {
+	parsedTag := parseTag(tag)
+	if parsedTag == nil {
+		return ""
+	}
+
+	if match == "" {
+		if nearest := nearestLowerTag(tags, parsedTag); nearest != "" {
+			return fmt.Sprintf("no tag equivalent to %s, the nearest available version is %s", tag, nearest)
+		}
+		return fmt.Sprintf("no tag equivalent to %s", tag)
+	}
+
+	series := *parsedTag
+	if series.specificity == "PATCH" {
+		series.specificity = "MINOR"
+	}
+
+	parsedMatch := parseTag(match)
+	if parsedMatch == nil || parsedMatch.Within(&series) {
+		return ""
+	}
+
+	return fmt.Sprintf("no tag equivalent to %s, using the nearest available version %s", tag, match)
+}
+
+// nearestLowerTag returns the highest tag that is lower than the provided
+// version, with the same specificity
+func nearestLowerTag(tags []string, tv *tagVersion) string {
+	var (
+		bestMatch    *tagVersion
+		bestMatchStr string
+	)
+	for _, t := range tags {
+		parsedT := parseTag(t)
+		if parsedT == nil {
+			continue
+		}
+		if parsedT.specificity != tv.specificity {
+			continue
+		}
+		if !parsedT.LessThan(tv) {
+			continue
+		}
+		if bestMatch != nil && !parsedT.GreaterThan(bestMatch) {
+			continue
+		}
+
+		bestMatch = parsedT
+		bestMatchStr = t
+	}
+
+	return bestMatchStr
+}
+
 // matchClosestSemanticVersionTag finds the closest match to the input tag in
 // the active tags.
 //
@@ -170,6 +292,37 @@ func (tv *tagVersion) GreaterThan(other *tagVersion) bool {
 	return tv.compare(other) > 0
 }
 
+// Within tests whether this tag falls within the provided tag. For instance,
+// 1.25.3 is within 1.25 and 1, but not 1.24.
+func (tv *tagVersion) Within(other *tagVersion) bool {
+	if tv.major != other.major {
+		return false
+	}
+	if specificityRank(other.specificity) >= specificityRank("MINOR") && tv.minor != other.minor {
+		return false
+	}
+	if specificityRank(other.specificity) >= specificityRank("PATCH") && tv.patch != other.patch {
+		return false
+	}
+
+	return true
+}
+
+// specificityRank orders the specificity of tags, from least to most
+// specific
+func specificityRank(specificity string) int {
+	switch specificity {
+	case "MAJOR":
+		return 1
+	case "MINOR":
+		return 2
+	case "PATCH":
+		return 3
+	default:
+		return 0
+	}
+}
+
 // compare returns -1 if tv < other, 0 if equal, 1 if tv > other
 func (tv *tagVersion) compare(other *tagVersion) int {
 	if tv.major != other.major {
@@ -247,3 +247,129 @@ func TestMatchTag(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchTagEquivalent(t *testing.T) {
+	tags := []string{
+		"latest",
+		"1.25.3",
+		"1.25.5",
+		"1.27.1",
+		"v2.1.0",
+		"2.1.0",
+	}
+
+	tests := []struct {
+		name     string
+		tag      string
+		expected string
+	}{
+		{
+			name:     "minor to highest patch",
+			tag:      "1.25",
+			expected: "1.25.5",
+		},
+		{
+			name:     "major to highest patch",
+			tag:      "1",
+			expected: "1.27.1",
+		},
+		{
+			name:     "minor with suffix to highest patch",
+			tag:      "1.25-alpine",
+			expected: "1.25.5",
+		},
+		{
+			name:     "prefers same prefix",
+			tag:      "v2.1",
+			expected: "v2.1.0",
+		},
+		{
+			name:     "no equivalent minor",
+			tag:      "1.26",
+			expected: "",
+		},
+		{
+			name:     "exact patch match",
+			tag:      "1.25.3",
+			expected: "1.25.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MatchTag(tags, tt.tag)
+			if result != tt.expected {
+				t.Errorf("MatchTag(%q) = %q, expected %q", tt.tag, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTagWarning(t *testing.T) {
+	tags := []string{
+		"latest",
+		"1.24",
+		"1.25",
+		"1.25.5",
+		"1.27",
+	}
+
+	tests := []struct {
+		name     string
+		tag      string
+		match    string
+		expected string
+	}{
+		{
+			name:     "exact match",
+			tag:      "1.25",
+			match:    "1.25",
+			expected: "",
+		},
+		{
+			name:     "equivalent match",
+			tag:      "1.25",
+			match:    "1.25.5",
+			expected: "",
+		},
+		{
+			name:     "upgrade",
+			tag:      "1.26",
+			match:    "1.27",
+			expected: "no tag equivalent to 1.26, using the nearest available version 1.27",
+		},
+		{
+			name:     "no match with lower version available",
+			tag:      "1.28",
+			match:    "",
+			expected: "no tag equivalent to 1.28, the nearest available version is 1.27",
+		},
+		{
+			name:     "no match and no lower version",
+			tag:      "1.23",
+			match:    "",
+			expected: "no tag equivalent to 1.23",
+		},
+		{
+			name:     "not a version",
+			tag:      "alpine",
+			match:    "",
+			expected: "",
+		},
+		{
+			name:     "no tag",
+			tag:      "",
+			match:    "",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TagWarning(tags, tt.tag, tt.match)
+			if result != tt.expected {
+				t.Errorf("TagWarning(%q, %q) = %q, expected %q", tt.tag, tt.match, result, tt.expected)
+			}
+		})
+	}
+}
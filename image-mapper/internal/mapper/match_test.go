@@ -263,3 +263,57 @@ func TestMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchRule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		refStr   string
+		repo     Repo
+		expected string
+	}{
+		{
+			name:     "basename",
+			refStr:   "bitnami/nginx",
+			repo:     Repo{Name: "nginx"},
+			expected: "basename",
+		},
+		{
+			name:     "dashname",
+			refStr:   "ghcr.io/stakater/reloader",
+			repo:     Repo{Name: "stakater-reloader"},
+			expected: "dashname",
+		},
+		{
+			name:     "iamguarded",
+			refStr:   "bitnami/redis",
+			repo:     Repo{Name: "redis-iamguarded"},
+			expected: "iamguarded",
+		},
+		{
+			name:     "alias",
+			refStr:   "quay.io/argoproj/argocli",
+			repo:     Repo{Name: "argo-cli", Aliases: []string{"argoproj/workflow-controller", "quay.io/argoproj/argocli"}},
+			expected: "alias quay.io/argoproj/argocli",
+		},
+		{
+			name:     "no match",
+			refStr:   "redis",
+			repo:     Repo{Name: "nginx", Aliases: []string{"nginx"}},
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := name.ParseReference(tc.refStr)
+			if err != nil {
+				t.Fatalf("failed to parse reference %s: %v", tc.refStr, err)
+			}
+
+			rule, ok := matchRule(ref, tc.repo)
+			if rule != tc.expected || ok != (tc.expected != "") {
+				t.Errorf("matchRule(%s, %+v) = %q, %v, want %q", tc.refStr, tc.repo, rule, ok, tc.expected)
+			}
+		})
+	}
+}
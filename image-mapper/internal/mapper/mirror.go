@@ -0,0 +1,216 @@
+package mapper
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// mirror is an upstream repository and the repository that it's mirrored
+// from
+type mirror struct {
+	// registry and repository of the upstream image, i.e docker.io and
+	// library/nginx
+	registry   string
+	repository string
+
+	// location is the mapped repository, i.e cgr.dev/chainguard/nginx
+	location string
+
+	// images are the mappings that use the mirror, which are included
+	// in comments when the mapped tag doesn't match
+	images []*Mapping
+}
+
+// prefix returns the fully qualified name of the upstream repository
+func (m mirror) prefix() string {
+	return m.registry + "/" + m.repository
+}
+
+// mirrors returns a mirror for each upstream repository in the mappings,
+// using the first result of each mapping. When different tags of the same
+// repository map to different repositories, the first one wins, because the
+// mirror configuration can only redirect whole repositories.
+func mirrors(mappings []*Mapping) []*mirror {
+	var mirrors []*mirror
+	for _, m := range mappings {
+		if len(m.Results) == 0 {
+			continue
+		}
+		ref, err := name.ParseReference(m.Image)
+		if err != nil {
+			slog.Warn("parsing image", "image", m.Image, "err", err)
+			continue
+		}
+		mapped, err := name.ParseReference(m.Results[0])
+		if err != nil {
+			slog.Warn("parsing mapped image", "image", m.Results[0], "err", err)
+			continue
+		}
+
+		registry := ref.Context().RegistryStr()
+		if registry == name.DefaultRegistry {
+			registry = "docker.io"
+		}
+		repository := ref.Context().RepositoryStr()
+		location := mapped.Context().Name()
+
+		idx := slices.IndexFunc(mirrors, func(mi *mirror) bool {
+			return mi.registry == registry && mi.repository == repository
+		})
+		if idx < 0 {
+			mirrors = append(mirrors, &mirror{
+				registry:   registry,
+				repository: repository,
+				location:   location,
+			})
+			idx = len(mirrors) - 1
+		}
+		mi := mirrors[idx]
+		if mi.location != location {
+			slog.Warn(fmt.Sprintf("maps to %s, but %s is already mirrored from %s", location, mi.prefix(), mi.location), "image", m.Image)
+			continue
+		}
+		mi.images = append(mi.images, m)
+	}
+
+	return mirrors
+}
+
+// tagComments returns comments for the images where the mapped tag is
+// different from the tag of the image, because mirrors are pulled with the
+// original tag
+func tagComments(mi *mirror) []string {
+	var comments []string
+	for _, m := range mi.images {
+		ref, err := name.ParseReference(m.Image)
+		if err != nil {
+			continue
+		}
+		mapped, err := name.ParseReference(m.Results[0])
+		if err != nil {
+			continue
+		}
+		if ref.Identifier() == mapped.Identifier() {
+			continue
+		}
+		comments = append(comments, fmt.Sprintf("# %s maps to %s, but the mirror is pulled with the tag %s", m.Image, m.Results[0], ref.Identifier()))
+	}
+
+	return comments
+}
+
+// outputRegistriesConf writes containers-registries.conf(5) entries, used by
+// CRI-O and Podman, that pull each upstream repository from the repository it
+// maps to. The upstream repository is used when the image isn't in the
+// mirror.
+func outputRegistriesConf(w io.Writer, mappings []*Mapping) error {
+	for i, mi := range mirrors(mappings) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		for _, comment := range tagComments(mi) {
+			fmt.Fprintln(w, comment)
+		}
+		fmt.Fprintln(w, "[[registry]]")
+		fmt.Fprintf(w, "prefix = %q\n", mi.prefix())
+		fmt.Fprintf(w, "location = %q\n", mi.prefix())
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "[[registry.mirror]]")
+		fmt.Fprintf(w, "location = %q\n", mi.location)
+	}
+
+	return nil
+}
+
+// containerdHost is a mirror of a registry in a containerd hosts.toml
+type containerdHost struct {
+	registry string
+	host     string
+	path     string
+}
+
+// outputContainerd writes a containerd hosts.toml for each upstream registry.
+//
+// Unlike CRI-O, containerd mirrors whole registries and keeps the name of the
+// repository, so only images that map to repositories with the same name
+// under one path can be mirrored, like an internal mirror that copies images
+// under their upstream names. Images that aren't in the mirror are pulled
+// from the upstream registry. The repositories that can't be mirrored are
+// listed in a comment.
+func outputContainerd(w io.Writer, mappings []*Mapping) error {
+	var (
+		hosts    []containerdHost
+		skipped  []string
+		failures = map[string]bool{}
+	)
+	for _, mi := range mirrors(mappings) {
+		mapped, err := name.NewRepository(mi.location)
+		if err != nil {
+			continue
+		}
+		path, ok := strings.CutSuffix(mapped.RepositoryStr(), "/"+mi.repository)
+		if !ok && mapped.RepositoryStr() == mi.repository {
+			path, ok = "", true
+		}
+
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("%s -> %s", mi.prefix(), mi.location))
+			continue
+		}
+
+		idx := slices.IndexFunc(hosts, func(h containerdHost) bool { return h.registry == mi.registry })
+		switch {
+		case idx < 0:
+			hosts = append(hosts, containerdHost{
+				registry: mi.registry,
+				host:     mapped.RegistryStr(),
+				path:     path,
+			})
+		case hosts[idx].host != mapped.RegistryStr() || hosts[idx].path != path:
+			failures[mi.registry] = true
+		}
+	}
+
+	if len(skipped) > 0 {
+		fmt.Fprintln(w, "# These repositories can't be mirrored by containerd, because the mapped")
+		fmt.Fprintln(w, "# repository has a different name:")
+		for _, s := range skipped {
+			fmt.Fprintf(w, "#   %s\n", s)
+		}
+	}
+
+	first := len(skipped) == 0
+	for _, h := range hosts {
+		if failures[h.registry] {
+			slog.Warn("the images map to different registries or paths, so it can't be mirrored by containerd", "registry", h.registry)
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+
+		server := "https://" + h.registry
+		if h.registry == "docker.io" {
+			server = "https://registry-1.docker.io"
+		}
+		fmt.Fprintf(w, "# /etc/containerd/certs.d/%s/hosts.toml\n", h.registry)
+		fmt.Fprintf(w, "server = %q\n", server)
+		fmt.Fprintln(w)
+		if h.path == "" {
+			fmt.Fprintf(w, "[host.%q]\n", "https://"+h.host)
+			fmt.Fprintln(w, `  capabilities = ["pull", "resolve"]`)
+			continue
+		}
+		fmt.Fprintf(w, "[host.%q]\n", fmt.Sprintf("https://%s/v2/%s", h.host, h.path))
+		fmt.Fprintln(w, `  capabilities = ["pull", "resolve"]`)
+		fmt.Fprintln(w, "  override_path = true")
+	}
+
+	return nil
+}
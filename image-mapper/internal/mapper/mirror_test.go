@@ -0,0 +1,96 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMirrorOutput(t *testing.T) {
+	testCases := []struct {
+		name     string
+		format   string
+		mappings []*Mapping
+		want     string
+	}{
+		{
+			name:   "registries.conf",
+			format: "registries-conf",
+			mappings: []*Mapping{
+				{Image: "nginx:1.25", Results: []string{"cgr.dev/chainguard/nginx:1.25"}},
+				{Image: "docker.io/library/nginx:1.26", Results: []string{"cgr.dev/chainguard/nginx:1.27"}},
+				{Image: "ghcr.io/stakater/reloader:v1.4.1", Results: []string{"cgr.dev/chainguard/stakater-reloader:v1.4.1"}},
+				{Image: "nonexistent"},
+			},
+			want: `# docker.io/library/nginx:1.26 maps to cgr.dev/chainguard/nginx:1.27, but the mirror is pulled with the tag 1.26
+[[registry]]
+prefix = "docker.io/library/nginx"
+location = "docker.io/library/nginx"
+
+[[registry.mirror]]
+location = "cgr.dev/chainguard/nginx"
+
+[[registry]]
+prefix = "ghcr.io/stakater/reloader"
+location = "ghcr.io/stakater/reloader"
+
+[[registry.mirror]]
+location = "cgr.dev/chainguard/stakater-reloader"
+`,
+		},
+		{
+			name:   "containerd",
+			format: "containerd",
+			mappings: []*Mapping{
+				{Image: "library/nginx:1.25", Results: []string{"registry.internal.dev/mirror/library/nginx:1.25"}},
+				{Image: "ghcr.io/stakater/reloader:v1.4.1", Results: []string{"registry.internal.dev/mirror/stakater-reloader:v1.4.1"}},
+				{Image: "quay.io/prometheus/prometheus:v3.0.0", Results: []string{"registry.internal.dev/prometheus/prometheus:v3.0.0"}},
+			},
+			want: `# These repositories can't be mirrored by containerd, because the mapped
+# repository has a different name:
+#   ghcr.io/stakater/reloader -> registry.internal.dev/mirror/stakater-reloader
+
+# /etc/containerd/certs.d/docker.io/hosts.toml
+server = "https://registry-1.docker.io"
+
+[host."https://registry.internal.dev/v2/mirror"]
+  capabilities = ["pull", "resolve"]
+  override_path = true
+
+# /etc/containerd/certs.d/quay.io/hosts.toml
+server = "https://quay.io"
+
+[host."https://registry.internal.dev"]
+  capabilities = ["pull", "resolve"]
+`,
+		},
+		{
+			name:   "containerd conflicting paths",
+			format: "containerd",
+			mappings: []*Mapping{
+				{Image: "nginx:1.25", Results: []string{"registry.internal.dev/a/library/nginx:1.25"}},
+				{Image: "library/redis:7", Results: []string{"registry.internal.dev/b/library/redis:7"}},
+			},
+			want: ``,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := NewOutput(tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, tc.mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,20 +1,53 @@
 package mapper
 
+import "time"
+
 // Option configures a Mapper
 type Option func(*options)
 
 type options struct {
-	ignoreFns    []IgnoreFn
-	repo         string
-	inactiveTags bool
-	tagFilters   []TagFilter
+	ignoreFns     []IgnoreFn
+	repo          string
+	repoMap       []string
+	inactiveTags  bool
+	tagFilters    []TagFilter
+	tagStrategy   string
+	tierOrder     []string
+	aliases       []string
+	cacheDir      string
+	cacheDuration time.Duration
+	refresh       bool
+	allowStale    bool
+	catalogURL    string
+	resultCache   bool
+	repoClient    RepoClient
+	identity      TokenSource
+	cache         Cache
+	staleWindow   time.Duration
+
+	digestResolver DigestResolver
+	originResolver OriginResolver
 }
 
-// WithIgnoreFns is a functional option that configures the IgnoreFns used by
-// the mapper
+func newOptions(opts ...Option) *options {
+	o := &options{
+		repo:        "cgr.dev/chainguard",
+		catalogURL:  DefaultCatalogURL,
+		tagStrategy: TagStrategyMapSemver,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithIgnoreFns is a functional option that adds IgnoreFns to those used by
+// the mapper, so that they're applied on top of the defaults of mappers like
+// the dockerfile and helm ones
 func WithIgnoreFns(ignoreFns ...IgnoreFn) Option {
 	return func(o *options) {
-		o.ignoreFns = ignoreFns
+		o.ignoreFns = append(o.ignoreFns, ignoreFns...)
 	}
 }
 
@@ -26,6 +59,18 @@ func WithRepository(repo string) Option {
 	}
 }
 
+// WithRepositoryMap is a functional option that configures the repository
+// prefix of the results for particular Chainguard repositories, with rules in
+// the form PATTERN=REPOSITORY. The pattern is a catalog tier, like FIPS, or a
+// glob that matches the name of the repository, like *-fips. The first rule
+// that matches is used. The prefix configured by WithRepository is used for
+// the repositories that don't match any of the rules.
+func WithRepositoryMap(rules ...string) Option {
+	return func(o *options) {
+		o.repoMap = rules
+	}
+}
+
 // WithTagFilters is a functional option that configures tag filters to apply to
 // matches
 func WithTagFilters(tagFilters ...TagFilter) Option {
@@ -34,6 +79,27 @@ func WithTagFilters(tagFilters ...TagFilter) Option {
 	}
 }
 
+// WithTagStrategy is a functional option that configures how the tags of the
+// results are chosen: map-semver, keep-upstream, latest or latest-dev. An
+// empty strategy keeps the default, map-semver.
+func WithTagStrategy(strategy string) Option {
+	return func(o *options) {
+		if strategy != "" {
+			o.tagStrategy = strategy
+		}
+	}
+}
+
+// WithTierOrder is a functional option that configures the order of the
+// results when repositories in more than one tier match an image. The results
+// in the first tier come first, and the results in tiers that aren't listed
+// come last. The results are otherwise in alphabetical order.
+func WithTierOrder(tiers ...string) Option {
+	return func(o *options) {
+		o.tierOrder = tiers
+	}
+}
+
 // WithInactiveTags is a functional option that configures the mapper to include
 // inactive tags in its matching
 func WithInactiveTags(inactiveTags bool) Option {
@@ -41,3 +107,127 @@ func WithInactiveTags(inactiveTags bool) Option {
 		o.inactiveTags = inactiveTags
 	}
 }
+
+// WithAliasOverrides is a functional option that configures files or URLs to
+// load alias overrides from. The overrides are applied over the catalog data in
+// the order they are provided.
+func WithAliasOverrides(sources ...string) Option {
+	return func(o *options) {
+		o.aliases = sources
+	}
+}
+
+// WithCacheDuration is a functional option that configures how long the catalog
+// data is cached on disk. A duration of 0 disables the cache.
+func WithCacheDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.cacheDuration = d
+	}
+}
+
+// WithRefresh is a functional option that configures the mapper to fetch the
+// catalog data, and cache it again, even when the cache hasn't expired
+func WithRefresh(refresh bool) Option {
+	return func(o *options) {
+		o.refresh = refresh
+	}
+}
+
+// WithDigestResolver is a functional option that configures how the digests
+// of images that are pinned to a digest without a tag, like nginx@sha256:...,
+// are resolved to a tag. Without a resolver, those images are mapped like
+// latest.
+func WithDigestResolver(r DigestResolver) Option {
+	return func(o *options) {
+		o.digestResolver = r
+	}
+}
+
+// WithOriginResolver is a functional option that configures how the original
+// repositories of images that are pinned to a digest are found, when they
+// were pulled through a proxy with a path that doesn't match anything.
+// Without a resolver, those images aren't mapped.
+func WithOriginResolver(r OriginResolver) Option {
+	return func(o *options) {
+		o.originResolver = r
+	}
+}
+
+// WithAllowStale is a functional option that configures the mapper to use the
+// cached catalog data, even when it has expired, if the catalog can't be
+// fetched. A warning is logged with the age of the data that's used.
+func WithAllowStale(allowStale bool) Option {
+	return func(o *options) {
+		o.allowStale = allowStale
+	}
+}
+
+// WithCacheDir is a functional option that configures the directory the catalog
+// data is cached in. It defaults to an image-mapper directory in the user's
+// cache directory.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithCatalogURL is a functional option that configures where the catalog
+// data is read from. It defaults to DefaultCatalogURL. An http or https URL is
+// a GraphQL endpoint and a file URL, like file:///tmp/catalog.json, is a JSON
+// file of repositories.
+func WithCatalogURL(url string) Option {
+	return func(o *options) {
+		if url != "" {
+			o.catalogURL = url
+		}
+	}
+}
+
+// WithResultCache is a functional option that configures the mapper to cache
+// its mappings on disk, in the directory configured by WithCacheDir. The
+// cache is keyed by the normalized image reference and a hash of the catalog
+// data and the configuration of the mapper, so repeated runs only map the
+// images they haven't seen before.
+func WithResultCache(enabled bool) Option {
+	return func(o *options) {
+		o.resultCache = enabled
+	}
+}
+
+// WithRepoClient is a functional option that configures the client the
+// catalog data is fetched with, in place of the one selected by
+// WithCatalogURL, i.e for a catalog that isn't one of the built in backends.
+// The data isn't cached on disk.
+func WithRepoClient(client RepoClient) Option {
+	return func(o *options) {
+		o.repoClient = client
+	}
+}
+
+// WithIdentity is a functional option that configures the Chainguard identity
+// that a chainguard:// catalog URL is read as
+func WithIdentity(identity TokenSource) Option {
+	return func(o *options) {
+		o.identity = identity
+	}
+}
+
+// WithCache is a functional option that configures the mapper to cache the
+// catalog data in a cache that's shared with other processes, like the
+// replicas of the server, rather than on disk. The data is cached for the
+// duration configured by WithCacheDuration.
+func WithCache(cache Cache) Option {
+	return func(o *options) {
+		o.cache = cache
+	}
+}
+
+// WithStaleWhileRevalidate is a functional option that configures how long
+// after the catalog data in the cache configured by WithCache has expired
+// it's still used, while it's fetched again in the background, so that
+// mapping isn't held up by fetching the catalog
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(o *options) {
+		o.staleWindow = d
+	}
+}
@@ -5,23 +5,124 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // Output writes mappings in a particular format
 type Output func(w io.Writer, mappings []*Mapping) error
 
+var (
+	outputsMu sync.RWMutex
+
+	// outputs are the output formats, by name
+	outputs = map[string]Output{
+		"containerd":      outputContainerd,
+		"csv":             outputCSV,
+		"customer-yaml":   outputCustomerYAML,
+		"entitlements":    outputEntitlements,
+		"gatekeeper":      outputGatekeeper,
+		"json":            outputJSON,
+		"jsonl":           outputJSONL,
+		"kyverno":         outputKyverno,
+		"markdown":        outputMarkdown,
+		"registries-conf": outputRegistriesConf,
+		"renovate":        outputRenovate,
+		"text":            outputText,
+	}
+)
+
+// RegisterOutput adds an output format that NewOutput can return, so that
+// programs that use this package can write mappings in their own formats.
+// Names are case insensitive and can't replace a format that's already
+// registered.
+func RegisterOutput(name string, output Output) error {
+	name = strings.ToLower(name)
+	if name == "" {
+		return fmt.Errorf("registering output: name is empty")
+	}
+	if output == nil {
+		return fmt.Errorf("registering output: %s: output is nil", name)
+	}
+
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+
+	if _, ok := outputs[name]; ok {
+		return fmt.Errorf("registering output: %s: format is already registered", name)
+	}
+	outputs[name] = output
+
+	return nil
+}
+
+// OutputFormats returns the names of the registered output formats, in
+// alphabetical order
+func OutputFormats() []string {
+	outputsMu.RLock()
+	defer outputsMu.RUnlock()
+
+	return slices.Sorted(maps.Keys(outputs))
+}
+
+// OutputOption configures an output
+type OutputOption func(*outputOptions)
+
+type outputOptions struct {
+	groupBy string
+}
+
+// WithGroupBy is a functional option that groups the mappings in the output.
+// GroupByTarget groups the images under the Chainguard repository they map
+// to. Only the text and markdown formats can be grouped.
+func WithGroupBy(groupBy string) OutputOption {
+	return func(o *outputOptions) {
+		o.groupBy = groupBy
+	}
+}
+
 // NewOutput returns an output in the requested format
-func NewOutput(format string) (Output, error) {
+func NewOutput(format string, opts ...OutputOption) (Output, error) {
+	o := &outputOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	outputsMu.RLock()
+	output, ok := outputs[strings.ToLower(format)]
+	outputsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format: %s (supported: %s)", format, strings.Join(OutputFormats(), ", "))
+	}
+
+	switch strings.ToLower(o.groupBy) {
+	case GroupByNone:
+		return output, nil
+	case GroupByTarget:
+		grouped, ok := groupedOutputs[strings.ToLower(format)]
+		if !ok {
+			return nil, fmt.Errorf("the %s output format can't be grouped (supported: %s)", format, strings.Join(slices.Sorted(maps.Keys(groupedOutputs)), ", "))
+		}
+		return grouped, nil
+	default:
+		return nil, fmt.Errorf("unsupported group: %s (supported: %s)", o.groupBy, GroupByTarget)
+	}
+}
+
+// StreamOutput writes mappings one at a time, as they're produced
+type StreamOutput func(w io.Writer, mapping *Mapping) error
+
+// NewStreamOutput returns a streaming output in the requested format. It
+// returns false if the format doesn't support streaming.
+func NewStreamOutput(format string) (StreamOutput, bool) {
 	switch strings.ToLower(format) {
-	case "csv":
-		return outputCSV, nil
-	case "json":
-		return outputJSON, nil
-	case "text":
-		return outputText, nil
+	case "jsonl":
+		return streamJSONL, true
 	default:
-		return nil, fmt.Errorf("unsupported output format: %s (supported: csv, json, text)", format)
+		return nil, false
 	}
 }
 
@@ -30,7 +131,26 @@ func outputCSV(w io.Writer, mappings []*Mapping) error {
 	defer writer.Flush()
 
 	for _, m := range mappings {
-		if err := writer.Write([]string{m.Image, fmt.Sprintf("%s", m.Results)}); err != nil {
+		record := []string{m.Image, fmt.Sprintf("%s", m.Results), strconv.Itoa(m.Occurrences)}
+		if v := m.Vulnerabilities; v != nil {
+			record = append(record, strconv.Itoa(v.Image.Total), strconv.Itoa(v.Result.Total))
+		}
+		if sz := m.Sizes; sz != nil {
+			record = append(record,
+				strconv.FormatInt(sz.Image.Bytes, 10),
+				strconv.FormatInt(sz.Result.Bytes, 10),
+				strconv.Itoa(sz.Image.Layers),
+				strconv.Itoa(sz.Result.Layers),
+			)
+		}
+		if len(m.Locations) > 0 {
+			locations := make([]string, len(m.Locations))
+			for i, l := range m.Locations {
+				locations[i] = l.String()
+			}
+			record = append(record, strings.Join(locations, ";"))
+		}
+		if err := writer.Write(record); err != nil {
 			return fmt.Errorf("writing CSV record: %w", err)
 		}
 	}
@@ -39,7 +159,25 @@ func outputCSV(w io.Writer, mappings []*Mapping) error {
 }
 
 func outputJSON(w io.Writer, mappings []*Mapping) error {
-	return json.NewEncoder(w).Encode(mappings)
+	versioned := make([]versionedMapping, len(mappings))
+	for i, m := range mappings {
+		versioned[i] = versionedMapping{SchemaVersion: SchemaVersion, Mapping: m}
+	}
+
+	return json.NewEncoder(w).Encode(versioned)
+}
+
+func outputJSONL(w io.Writer, mappings []*Mapping) error {
+	for _, m := range mappings {
+		if err := streamJSONL(w, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func streamJSONL(w io.Writer, mapping *Mapping) error {
+	return json.NewEncoder(w).Encode(versionedMapping{SchemaVersion: SchemaVersion, Mapping: mapping})
 }
 
 func outputText(w io.Writer, mappings []*Mapping) error {
@@ -50,6 +188,90 @@ func outputText(w io.Writer, mappings []*Mapping) error {
 		if len(m.Results) == 0 {
 			fmt.Fprintf(w, "%s ->\n", m.Image)
 		}
+		writeTextDetails(w, m, "  ")
+	}
+	return nil
+}
+
+// writeTextDetails writes the details of the mapping that follow its results
+// in the text output, with the indent before each line
+func writeTextDetails(w io.Writer, m *Mapping, indent string) {
+	if m.EOL != nil {
+		fmt.Fprintf(w, "%seol: %s\n", indent, m.EOL)
+	}
+	if m.Origin != "" {
+		fmt.Fprintf(w, "%sorigin: %s\n", indent, m.Origin)
+	}
+	if m.ResolvedTag != "" {
+		fmt.Fprintf(w, "%sdigest: %s (tag %s)\n", indent, m.Digest, m.ResolvedTag)
+	}
+	if v := m.Vulnerabilities; v != nil {
+		fmt.Fprintf(w, "%svulnerabilities: %s -> %s\n", indent, formatCounts(v.Image), formatCounts(v.Result))
+	}
+	if sz := m.Sizes; sz != nil {
+		fmt.Fprintf(w, "%ssize: %s -> %s\n", indent, formatSize(sz.Image), formatSize(sz.Result))
+	}
+	for _, l := range m.Locations {
+		fmt.Fprintf(w, "%sat: %s\n", indent, l)
+	}
+}
+
+// outputMarkdown writes the mappings as a markdown table, with each image and
+// the images it maps to
+func outputMarkdown(w io.Writer, mappings []*Mapping) error {
+	fmt.Fprintln(w, "| Image | Chainguard Image |")
+	fmt.Fprintln(w, "| --- | --- |")
+	for _, m := range mappings {
+		results := make([]string, len(m.Results))
+		for i, result := range m.Results {
+			results[i] = "`" + result + "`"
+		}
+		fmt.Fprintf(w, "| `%s` | %s |\n", m.Image, strings.Join(results, "<br>"))
+	}
+	return nil
+}
+
+// formatSize summarises the size of an image, i.e 67.2 MB, 7 layers
+func formatSize(s ImageSize) string {
+	return fmt.Sprintf("%.1f MB, %d layers", float64(s.Bytes)/1e6, s.Layers)
+}
+
+// formatCounts summarises vulnerability counts, i.e 42 (3 critical, 10 high)
+func formatCounts(c VulnerabilityCounts) string {
+	return fmt.Sprintf("%d (%d critical, %d high)", c.Total, c.Critical, c.High)
+}
+
+// RepoOutput writes catalog repositories in a particular format
+type RepoOutput func(w io.Writer, repos []Repo) error
+
+// NewRepoOutput returns a repository output in the requested format
+func NewRepoOutput(format string) (RepoOutput, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		return outputReposJSON, nil
+	case "text":
+		return outputReposText, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s (supported: json, text)", format)
+	}
+}
+
+func outputReposJSON(w io.Writer, repos []Repo) error {
+	if repos == nil {
+		repos = []Repo{}
+	}
+	return json.NewEncoder(w).Encode(repos)
+}
+
+func outputReposText(w io.Writer, repos []Repo) error {
+	for _, repo := range repos {
+		fmt.Fprintf(w, "%s (%s)\n", repo.Name, repo.CatalogTier)
+		if len(repo.Aliases) > 0 {
+			fmt.Fprintf(w, "  aliases: %s\n", strings.Join(repo.Aliases, ", "))
+		}
+		if len(repo.ActiveTags) > 0 {
+			fmt.Fprintf(w, "  active tags: %s\n", strings.Join(repo.ActiveTags, ", "))
+		}
 	}
 	return nil
 }
@@ -0,0 +1,421 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOutput(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:       "ghcr.io/stakater/reloader:v1.4.1",
+			Results:     []string{"cgr.dev/chainguard/stakater-reloader:v1.4.12"},
+			Occurrences: 3,
+		},
+		{
+			Image:       "nonexistent",
+			Results:     []string{},
+			Occurrences: 1,
+		},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "csv",
+			want: `ghcr.io/stakater/reloader:v1.4.1,[cgr.dev/chainguard/stakater-reloader:v1.4.12],3
+nonexistent,[],1
+`,
+		},
+		{
+			format: "json",
+			want: `[{"schemaVersion":"1","image":"ghcr.io/stakater/reloader:v1.4.1","results":["cgr.dev/chainguard/stakater-reloader:v1.4.12"],"occurrences":3},{"schemaVersion":"1","image":"nonexistent","occurrences":1}]
+`,
+		},
+		{
+			format: "jsonl",
+			want: `{"schemaVersion":"1","image":"ghcr.io/stakater/reloader:v1.4.1","results":["cgr.dev/chainguard/stakater-reloader:v1.4.12"],"occurrences":3}
+{"schemaVersion":"1","image":"nonexistent","occurrences":1}
+`,
+		},
+		{
+			format: "text",
+			want: `ghcr.io/stakater/reloader:v1.4.1 -> cgr.dev/chainguard/stakater-reloader:v1.4.12
+nonexistent ->
+`,
+		},
+		{
+			format: "markdown",
+			want: "| Image | Chainguard Image |\n" +
+				"| --- | --- |\n" +
+				"| `ghcr.io/stakater/reloader:v1.4.1` | `cgr.dev/chainguard/stakater-reloader:v1.4.12` |\n" +
+				"| `nonexistent` |  |\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output, err := NewOutput(tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewStreamOutput(t *testing.T) {
+	stream, ok := NewStreamOutput("JSONL")
+	if !ok {
+		t.Fatalf("expected jsonl to support streaming")
+	}
+
+	var buf bytes.Buffer
+	if err := stream(&buf, &Mapping{Image: "nginx", Results: []string{"cgr.dev/chainguard/nginx"}}); err != nil {
+		t.Fatalf("unexpected error writing output: %s", err)
+	}
+	if err := stream(&buf, &Mapping{Image: "redis"}); err != nil {
+		t.Fatalf("unexpected error writing output: %s", err)
+	}
+
+	want := `{"schemaVersion":"1","image":"nginx","results":["cgr.dev/chainguard/nginx"]}
+{"schemaVersion":"1","image":"redis"}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+
+	if _, ok := NewStreamOutput("json"); ok {
+		t.Errorf("expected json not to support streaming")
+	}
+}
+
+func TestNewOutputUnsupported(t *testing.T) {
+	if _, err := NewOutput("xml"); err == nil {
+		t.Errorf("expected error for unsupported format")
+	}
+}
+
+func TestRegisterOutput(t *testing.T) {
+	t.Cleanup(func() {
+		outputsMu.Lock()
+		defer outputsMu.Unlock()
+		delete(outputs, "images")
+	})
+
+	images := func(w io.Writer, mappings []*Mapping) error {
+		for _, m := range mappings {
+			fmt.Fprintln(w, m.Image)
+		}
+		return nil
+	}
+	if err := RegisterOutput("Images", images); err != nil {
+		t.Fatalf("unexpected error registering output: %s", err)
+	}
+
+	output, err := NewOutput("images")
+	if err != nil {
+		t.Fatalf("unexpected error constructing output: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := output(&buf, []*Mapping{{Image: "nginx"}, {Image: "redis"}}); err != nil {
+		t.Fatalf("unexpected error writing output: %s", err)
+	}
+	if diff := cmp.Diff("nginx\nredis\n", buf.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+
+	if !slices.Contains(OutputFormats(), "images") {
+		t.Errorf("expected images in the output formats: %v", OutputFormats())
+	}
+
+	for _, name := range []string{"images", "JSON", ""} {
+		if err := RegisterOutput(name, images); err == nil {
+			t.Errorf("expected an error registering %q", name)
+		}
+	}
+	if err := RegisterOutput("nil", nil); err == nil {
+		t.Errorf("expected an error registering a nil output")
+	}
+}
+
+func TestOutputVulnerabilities(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:       "nginx:1.25",
+			Results:     []string{"cgr.dev/chainguard/nginx:1.25"},
+			Occurrences: 1,
+			Vulnerabilities: &Vulnerabilities{
+				Image:  VulnerabilityCounts{Critical: 1, High: 4, Medium: 10, Total: 15},
+				Result: VulnerabilityCounts{},
+			},
+		},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "csv",
+			want: `nginx:1.25,[cgr.dev/chainguard/nginx:1.25],1,15,0
+`,
+		},
+		{
+			format: "json",
+			want: `[{"schemaVersion":"1","image":"nginx:1.25","results":["cgr.dev/chainguard/nginx:1.25"],"occurrences":1,"vulnerabilities":{"image":{"critical":1,"high":4,"medium":10,"low":0,"other":0,"total":15},"result":{"critical":0,"high":0,"medium":0,"low":0,"other":0,"total":0}}}]
+`,
+		},
+		{
+			format: "text",
+			want: `nginx:1.25 -> cgr.dev/chainguard/nginx:1.25
+  vulnerabilities: 15 (1 critical, 4 high) -> 0 (0 critical, 0 high)
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output, err := NewOutput(tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutputSizes(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:       "nginx:1.25",
+			Results:     []string{"cgr.dev/chainguard/nginx:1.25"},
+			Occurrences: 1,
+			Sizes: &Sizes{
+				Image:  ImageSize{Bytes: 67240000, Layers: 7},
+				Result: ImageSize{Bytes: 12100000, Layers: 3},
+			},
+		},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "csv",
+			want: `nginx:1.25,[cgr.dev/chainguard/nginx:1.25],1,67240000,12100000,7,3
+`,
+		},
+		{
+			format: "json",
+			want: `[{"schemaVersion":"1","image":"nginx:1.25","results":["cgr.dev/chainguard/nginx:1.25"],"occurrences":1,"sizes":{"image":{"bytes":67240000,"layers":7},"result":{"bytes":12100000,"layers":3}}}]
+`,
+		},
+		{
+			format: "text",
+			want: `nginx:1.25 -> cgr.dev/chainguard/nginx:1.25
+  size: 67.2 MB, 7 layers -> 12.1 MB, 3 layers
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output, err := NewOutput(tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutputLocations(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:       "nginx:1.25",
+			Results:     []string{"cgr.dev/chainguard/nginx:1.25"},
+			Occurrences: 2,
+			Locations: []Location{
+				{Path: "deploy/app.yaml", Line: 10, YAMLPath: "spec.template.spec.containers.[0].image"},
+				{Path: "Dockerfile", Line: 1},
+			},
+		},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "csv",
+			want: `nginx:1.25,[cgr.dev/chainguard/nginx:1.25],2,deploy/app.yaml:10 (spec.template.spec.containers.[0].image);Dockerfile:1
+`,
+		},
+		{
+			format: "json",
+			want: `[{"schemaVersion":"1","image":"nginx:1.25","results":["cgr.dev/chainguard/nginx:1.25"],"occurrences":2,"locations":[{"path":"deploy/app.yaml","line":10,"yamlPath":"spec.template.spec.containers.[0].image"},{"path":"Dockerfile","line":1}]}]
+`,
+		},
+		{
+			format: "text",
+			want: `nginx:1.25 -> cgr.dev/chainguard/nginx:1.25
+  at: deploy/app.yaml:10 (spec.template.spec.containers.[0].image)
+  at: Dockerfile:1
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output, err := NewOutput(tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutputDigest(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:       "nginx@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+			Results:     []string{"cgr.dev/chainguard/nginx:1.25.5"},
+			Digest:      "sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a",
+			ResolvedTag: "1.25.3",
+		},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "json",
+			want: `[{"schemaVersion":"1","image":"nginx@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a","results":["cgr.dev/chainguard/nginx:1.25.5"],"digest":"sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a","resolvedTag":"1.25.3"}]
+`,
+		},
+		{
+			format: "text",
+			want: `nginx@sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a -> cgr.dev/chainguard/nginx:1.25.5
+  digest: sha256:1e42bbe2508154c9126d48c2b8a75420c3544343bf86fd041fb7527e017a4b4a (tag 1.25.3)
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output, err := NewOutput(tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Required   []string                  `json:"required"`
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("unexpected error parsing schema: %s", err)
+	}
+
+	// Every field in the output must be described by the schema
+	mapping := schema.Defs["mapping"]
+	if got := mapping.Properties["schemaVersion"]["const"]; got != SchemaVersion {
+		t.Errorf("unexpected schema version: got %v, want %s", got, SchemaVersion)
+	}
+	testCases := map[string]any{
+		"mapping":             versionedMapping{Mapping: &Mapping{}},
+		"vulnerabilityCounts": VulnerabilityCounts{},
+		"imageSize":           ImageSize{},
+		"location":            Location{},
+		"eol":                 EOL{},
+	}
+	for def, v := range testCases {
+		var fields []string
+		collectJSONFields(reflect.TypeOf(v), &fields)
+		for _, field := range fields {
+			if _, ok := schema.Defs[def].Properties[field]; !ok {
+				t.Errorf("%s: field %q isn't in the schema", def, field)
+			}
+		}
+	}
+}
+
+// collectJSONFields appends the JSON names of the fields of the struct,
+// including the fields of embedded structs
+func collectJSONFields(typ reflect.Type, fields *[]string) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		if f.Anonymous {
+			collectJSONFields(f.Type, fields)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		*fields = append(*fields, name)
+	}
+}
@@ -0,0 +1,278 @@
+package mapper
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// policyName is the name of the generated Kyverno policy and the prefix of
+// the names of the generated Gatekeeper mutations
+const policyName = "chainguard-images"
+
+// containerLists are the lists of containers in a pod spec that the policies
+// mutate
+var containerLists = []string{"containers", "initContainers"}
+
+// policyReplacement is an image that a policy replaces, and what it's replaced
+// with
+type policyReplacement struct {
+	name  string
+	image string
+	with  string
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// policyReplacements returns a replacement for each mapping with a result.
+// The first result is used, so any tiers that shouldn't be used should be
+// ignored by the mapper. Each replacement has a unique name, derived from
+// the image, that's a valid Kubernetes name and short enough to be suffixed
+// with the container list.
+func policyReplacements(mappings []*Mapping) []policyReplacement {
+	var replacements []policyReplacement
+	names := map[string]int{}
+	for _, m := range mappings {
+		if len(m.Results) == 0 {
+			continue
+		}
+
+		name := invalidNameChars.ReplaceAllString(strings.ToLower(m.Image), "-")
+		name = strings.Trim(name[:min(len(name), 40)], "-")
+		names[name]++
+		if n := names[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+
+		replacements = append(replacements, policyReplacement{
+			name:  name,
+			image: m.Image,
+			with:  m.Results[0],
+		})
+	}
+
+	return replacements
+}
+
+type kyvernoPolicy struct {
+	APIVersion string         `yaml:"apiVersion"`
+	Kind       string         `yaml:"kind"`
+	Metadata   policyMetadata `yaml:"metadata"`
+	Spec       struct {
+		Rules []kyvernoRule `yaml:"rules"`
+	} `yaml:"spec"`
+}
+
+type policyMetadata struct {
+	Name        string            `yaml:"name"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type kyvernoRule struct {
+	Name  string `yaml:"name"`
+	Match struct {
+		Any []kyvernoResourceFilter `yaml:"any"`
+	} `yaml:"match"`
+	Preconditions *kyvernoConditions `yaml:"preconditions,omitempty"`
+	Mutate        struct {
+		Foreach []kyvernoForeach `yaml:"foreach"`
+	} `yaml:"mutate"`
+}
+
+type kyvernoResourceFilter struct {
+	Resources struct {
+		Kinds []string `yaml:"kinds"`
+	} `yaml:"resources"`
+}
+
+type kyvernoConditions struct {
+	All []kyvernoCondition `yaml:"all"`
+}
+
+type kyvernoCondition struct {
+	Key      string `yaml:"key"`
+	Operator string `yaml:"operator"`
+	Value    any    `yaml:"value"`
+}
+
+type kyvernoForeach struct {
+	List                string             `yaml:"list"`
+	Preconditions       *kyvernoConditions `yaml:"preconditions,omitempty"`
+	PatchStrategicMerge map[string]any     `yaml:"patchStrategicMerge"`
+}
+
+type kyvernoContainer struct {
+	Name  string `yaml:"name"`
+	Image string `yaml:"image"`
+}
+
+// outputKyverno writes a Kyverno ClusterPolicy that replaces the images in
+// pods with their mappings. Kyverno generates equivalent rules for the
+// resources that create pods, like Deployments.
+func outputKyverno(w io.Writer, mappings []*Mapping) error {
+	replacements := policyReplacements(mappings)
+	if len(replacements) == 0 {
+		return nil
+	}
+
+	policy := kyvernoPolicy{
+		APIVersion: "kyverno.io/v1",
+		Kind:       "ClusterPolicy",
+		Metadata: policyMetadata{
+			Name: policyName,
+			Annotations: map[string]string{
+				"policies.kyverno.io/title":       "Chainguard Images",
+				"policies.kyverno.io/description": "Replaces upstream images with their Chainguard equivalents.",
+			},
+		},
+	}
+	for _, list := range containerLists {
+		for _, r := range replacements {
+			rule := kyvernoRule{
+				Name: r.name,
+			}
+			if list != "containers" {
+				rule.Name = fmt.Sprintf("%s-%s", r.name, strings.ToLower(list))
+
+				// Kyverno fails to evaluate the foreach when the
+				// list doesn't exist
+				rule.Preconditions = &kyvernoConditions{
+					All: []kyvernoCondition{
+						{
+							Key:      fmt.Sprintf("{{ request.object.spec.%s[] || `[]` | length(@) }}", list),
+							Operator: "GreaterThanOrEquals",
+							Value:    1,
+						},
+					},
+				}
+			}
+
+			var filter kyvernoResourceFilter
+			filter.Resources.Kinds = []string{"Pod"}
+			rule.Match.Any = []kyvernoResourceFilter{filter}
+
+			rule.Mutate.Foreach = []kyvernoForeach{
+				{
+					List: fmt.Sprintf("request.object.spec.%s", list),
+					Preconditions: &kyvernoConditions{
+						All: []kyvernoCondition{
+							{
+								Key:      "{{ element.image }}",
+								Operator: "Equals",
+								Value:    r.image,
+							},
+						},
+					},
+					PatchStrategicMerge: map[string]any{
+						"spec": map[string]any{
+							list: []kyvernoContainer{
+								{
+									Name:  "{{ element.name }}",
+									Image: r.with,
+								},
+							},
+						},
+					},
+				},
+			}
+
+			policy.Spec.Rules = append(policy.Spec.Rules, rule)
+		}
+	}
+
+	return encodeYAML(w, policy)
+}
+
+type gatekeeperAssign struct {
+	APIVersion string         `yaml:"apiVersion"`
+	Kind       string         `yaml:"kind"`
+	Metadata   policyMetadata `yaml:"metadata"`
+	Spec       struct {
+		ApplyTo []gatekeeperApplyTo `yaml:"applyTo"`
+		Match   struct {
+			Scope string            `yaml:"scope"`
+			Kinds []gatekeeperKinds `yaml:"kinds"`
+		} `yaml:"match"`
+		Location   string `yaml:"location"`
+		Parameters struct {
+			AssignIf struct {
+				In []string `yaml:"in"`
+			} `yaml:"assignIf"`
+			Assign struct {
+				Value string `yaml:"value"`
+			} `yaml:"assign"`
+		} `yaml:"parameters"`
+	} `yaml:"spec"`
+}
+
+type gatekeeperApplyTo struct {
+	Groups   []string `yaml:"groups"`
+	Kinds    []string `yaml:"kinds"`
+	Versions []string `yaml:"versions"`
+}
+
+type gatekeeperKinds struct {
+	APIGroups []string `yaml:"apiGroups"`
+	Kinds     []string `yaml:"kinds"`
+}
+
+// outputGatekeeper writes Gatekeeper Assign mutations that replace the images
+// in pods with their mappings
+func outputGatekeeper(w io.Writer, mappings []*Mapping) error {
+	replacements := policyReplacements(mappings)
+
+	var docs []any
+	for _, list := range containerLists {
+		for _, r := range replacements {
+			var assign gatekeeperAssign
+			assign.APIVersion = "mutations.gatekeeper.sh/v1"
+			assign.Kind = "Assign"
+			assign.Metadata.Name = fmt.Sprintf("%s-%s", policyName, r.name)
+			if list != "containers" {
+				assign.Metadata.Name = fmt.Sprintf("%s-%s", assign.Metadata.Name, strings.ToLower(list))
+			}
+
+			assign.Spec.ApplyTo = []gatekeeperApplyTo{
+				{
+					Groups:   []string{""},
+					Kinds:    []string{"Pod"},
+					Versions: []string{"v1"},
+				},
+			}
+			assign.Spec.Match.Scope = "Namespaced"
+			assign.Spec.Match.Kinds = []gatekeeperKinds{
+				{
+					APIGroups: []string{"*"},
+					Kinds:     []string{"Pod"},
+				},
+			}
+			assign.Spec.Location = fmt.Sprintf("spec.%s[name:*].image", list)
+			assign.Spec.Parameters.AssignIf.In = []string{r.image}
+			assign.Spec.Parameters.Assign.Value = r.with
+
+			docs = append(docs, assign)
+		}
+	}
+
+	return encodeYAML(w, docs...)
+}
+
+// encodeYAML writes the documents as a multi-document YAML stream
+func encodeYAML(w io.Writer, docs ...any) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("encoding yaml: %w", err)
+		}
+	}
+
+	return enc.Close()
+}
@@ -0,0 +1,193 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPolicyReplacements(t *testing.T) {
+	mappings := []*Mapping{
+		{Image: "nginx:1.25", Results: []string{"cgr.dev/chainguard/nginx:1.25"}},
+		{Image: "nginx_1.25", Results: []string{"cgr.dev/chainguard/nginx:1.25"}},
+		{Image: "nonexistent"},
+		{Image: "registry.example.com/team/very-long-image-name-for-an-application:v1.2.3", Results: []string{"cgr.dev/chainguard/app:1.2.3"}},
+	}
+	want := []policyReplacement{
+		{name: "nginx-1-25", image: "nginx:1.25", with: "cgr.dev/chainguard/nginx:1.25"},
+		{name: "nginx-1-25-2", image: "nginx_1.25", with: "cgr.dev/chainguard/nginx:1.25"},
+		{name: "registry-example-com-team-very-long-imag", image: "registry.example.com/team/very-long-image-name-for-an-application:v1.2.3", with: "cgr.dev/chainguard/app:1.2.3"},
+	}
+
+	got := policyReplacements(mappings)
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(policyReplacement{})); diff != "" {
+		t.Errorf("unexpected replacements (-want +got):\n%s", diff)
+	}
+}
+
+func TestPolicyOutput(t *testing.T) {
+	mappings := []*Mapping{
+		{
+			Image:   "ghcr.io/stakater/reloader:v1.4.1",
+			Results: []string{"cgr.dev/chainguard/stakater-reloader:v1.4.12"},
+		},
+		{
+			Image: "nonexistent",
+		},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "kyverno",
+			want: `apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: chainguard-images
+  annotations:
+    policies.kyverno.io/description: Replaces upstream images with their Chainguard equivalents.
+    policies.kyverno.io/title: Chainguard Images
+spec:
+  rules:
+    - name: ghcr-io-stakater-reloader-v1-4-1
+      match:
+        any:
+          - resources:
+              kinds:
+                - Pod
+      mutate:
+        foreach:
+          - list: request.object.spec.containers
+            preconditions:
+              all:
+                - key: '{{ element.image }}'
+                  operator: Equals
+                  value: ghcr.io/stakater/reloader:v1.4.1
+            patchStrategicMerge:
+              spec:
+                containers:
+                  - name: '{{ element.name }}'
+                    image: cgr.dev/chainguard/stakater-reloader:v1.4.12
+    - name: ghcr-io-stakater-reloader-v1-4-1-initcontainers
+      match:
+        any:
+          - resources:
+              kinds:
+                - Pod
+      preconditions:
+        all:
+          - key: '{{ request.object.spec.initContainers[] || ` + "`[]`" + ` | length(@) }}'
+            operator: GreaterThanOrEquals
+            value: 1
+      mutate:
+        foreach:
+          - list: request.object.spec.initContainers
+            preconditions:
+              all:
+                - key: '{{ element.image }}'
+                  operator: Equals
+                  value: ghcr.io/stakater/reloader:v1.4.1
+            patchStrategicMerge:
+              spec:
+                initContainers:
+                  - name: '{{ element.name }}'
+                    image: cgr.dev/chainguard/stakater-reloader:v1.4.12
+`,
+		},
+		{
+			format: "gatekeeper",
+			want: `apiVersion: mutations.gatekeeper.sh/v1
+kind: Assign
+metadata:
+  name: chainguard-images-ghcr-io-stakater-reloader-v1-4-1
+spec:
+  applyTo:
+    - groups:
+        - ""
+      kinds:
+        - Pod
+      versions:
+        - v1
+  match:
+    scope: Namespaced
+    kinds:
+      - apiGroups:
+          - '*'
+        kinds:
+          - Pod
+  location: spec.containers[name:*].image
+  parameters:
+    assignIf:
+      in:
+        - ghcr.io/stakater/reloader:v1.4.1
+    assign:
+      value: cgr.dev/chainguard/stakater-reloader:v1.4.12
+---
+apiVersion: mutations.gatekeeper.sh/v1
+kind: Assign
+metadata:
+  name: chainguard-images-ghcr-io-stakater-reloader-v1-4-1-initcontainers
+spec:
+  applyTo:
+    - groups:
+        - ""
+      kinds:
+        - Pod
+      versions:
+        - v1
+  match:
+    scope: Namespaced
+    kinds:
+      - apiGroups:
+          - '*'
+        kinds:
+          - Pod
+  location: spec.initContainers[name:*].image
+  parameters:
+    assignIf:
+      in:
+        - ghcr.io/stakater/reloader:v1.4.1
+    assign:
+      value: cgr.dev/chainguard/stakater-reloader:v1.4.12
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			output, err := NewOutput(tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("no mappings", func(t *testing.T) {
+		for _, format := range []string{"kyverno", "gatekeeper"} {
+			output, err := NewOutput(format)
+			if err != nil {
+				t.Fatalf("unexpected error constructing output: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := output(&buf, mappings[1:]); err != nil {
+				t.Fatalf("unexpected error writing output: %s", err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("expected no %s output, got:\n%s", format, buf.String())
+			}
+		}
+	})
+}
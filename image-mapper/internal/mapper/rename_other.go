@@ -0,0 +1,10 @@
+//go:build !windows
+
+package mapper
+
+import "os"
+
+// renameFile renames oldpath over newpath
+func renameFile(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
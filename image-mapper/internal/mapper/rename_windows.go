@@ -0,0 +1,31 @@
+//go:build windows
+
+package mapper
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// renameRetries is how many times a rename is retried while another process
+// has the file open
+const renameRetries = 10
+
+// renameFile renames oldpath over newpath. On Windows, a file can't be
+// replaced while another process has it open, like when it's reading the
+// cache, so the rename is retried for a short while.
+func renameFile(oldpath, newpath string) error {
+	var err error
+	for range renameRetries {
+		err = os.Rename(oldpath, newpath)
+		if !errors.Is(err, windows.ERROR_ACCESS_DENIED) && !errors.Is(err, windows.ERROR_SHARING_VIOLATION) {
+			return err
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	return err
+}
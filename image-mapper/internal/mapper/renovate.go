@@ -0,0 +1,88 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// renovateConfig is a Renovate configuration with package rules
+type renovateConfig struct {
+	PackageRules []renovatePackageRule `json:"packageRules"`
+}
+
+type renovatePackageRule struct {
+	Description        string   `json:"description,omitempty"`
+	MatchDatasources   []string `json:"matchDatasources"`
+	MatchPackageNames  []string `json:"matchPackageNames"`
+	MatchCurrentValue  string   `json:"matchCurrentValue,omitempty"`
+	ReplacementName    string   `json:"replacementName,omitempty"`
+	ReplacementVersion string   `json:"replacementVersion,omitempty"`
+	PinDigests         *bool    `json:"pinDigests,omitempty"`
+}
+
+// splitImage splits an image reference, as it was written, into the name and
+// the tag. The digest is dropped.
+func splitImage(img string) (string, string) {
+	img, _, _ = strings.Cut(img, "@")
+	if i := strings.LastIndex(img, ":"); i > strings.LastIndex(img, "/") {
+		return img[:i], img[i+1:]
+	}
+
+	return img, ""
+}
+
+// outputRenovate writes Renovate package rules that replace each image with
+// the first result it maps to. Renovate opens pull requests with the
+// replacements and, because the digests of the replacements are pinned, keeps
+// the new images updated afterwards.
+func outputRenovate(w io.Writer, mappings []*Mapping) error {
+	config := renovateConfig{PackageRules: []renovatePackageRule{}}
+
+	var replacements []string
+	for _, m := range mappings {
+		if len(m.Results) == 0 {
+			continue
+		}
+		mapped, err := name.NewTag(m.Results[0])
+		if err != nil {
+			return fmt.Errorf("parsing mapped image: %s: %w", m.Results[0], err)
+		}
+
+		// Renovate matches the name of the image as it's written in
+		// the file, so we use the original image rather than a
+		// normalized reference
+		packageName, tag := splitImage(m.Image)
+		config.PackageRules = append(config.PackageRules, renovatePackageRule{
+			Description:        fmt.Sprintf("Replace %s with %s", m.Image, m.Results[0]),
+			MatchDatasources:   []string{"docker"},
+			MatchPackageNames:  []string{packageName},
+			MatchCurrentValue:  tag,
+			ReplacementName:    mapped.Context().Name(),
+			ReplacementVersion: mapped.TagStr(),
+		})
+
+		if !slices.Contains(replacements, mapped.Context().Name()) {
+			replacements = append(replacements, mapped.Context().Name())
+		}
+	}
+
+	if len(replacements) > 0 {
+		pin := true
+		config.PackageRules = append(config.PackageRules, renovatePackageRule{
+			Description:       "Pin the digests of the Chainguard images, so that updates to their tags are proposed",
+			MatchDatasources:  []string{"docker"},
+			MatchPackageNames: replacements,
+			PinDigests:        &pin,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(config)
+}
@@ -0,0 +1,106 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitImage(t *testing.T) {
+	testCases := []struct {
+		img      string
+		wantName string
+		wantTag  string
+	}{
+		{img: "nginx", wantName: "nginx"},
+		{img: "nginx:1.25", wantName: "nginx", wantTag: "1.25"},
+		{img: "localhost:5000/app", wantName: "localhost:5000/app"},
+		{img: "localhost:5000/app:v1", wantName: "localhost:5000/app", wantTag: "v1"},
+		{img: "nginx:1.25@sha256:0000000000000000000000000000000000000000000000000000000000000000", wantName: "nginx", wantTag: "1.25"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.img, func(t *testing.T) {
+			name, tag := splitImage(tc.img)
+			if name != tc.wantName || tag != tc.wantTag {
+				t.Errorf("unexpected result: got %s, %s, want %s, %s", name, tag, tc.wantName, tc.wantTag)
+			}
+		})
+	}
+}
+
+func TestOutputRenovate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mappings []*Mapping
+		want     string
+	}{
+		{
+			name: "mappings",
+			mappings: []*Mapping{
+				{Image: "nginx:1.25", Results: []string{"cgr.dev/chainguard/nginx:1.25"}},
+				{Image: "docker.io/library/nginx", Results: []string{"cgr.dev/chainguard/nginx:latest"}},
+				{Image: "nonexistent"},
+			},
+			want: `{
+  "packageRules": [
+    {
+      "description": "Replace nginx:1.25 with cgr.dev/chainguard/nginx:1.25",
+      "matchDatasources": [
+        "docker"
+      ],
+      "matchPackageNames": [
+        "nginx"
+      ],
+      "matchCurrentValue": "1.25",
+      "replacementName": "cgr.dev/chainguard/nginx",
+      "replacementVersion": "1.25"
+    },
+    {
+      "description": "Replace docker.io/library/nginx with cgr.dev/chainguard/nginx:latest",
+      "matchDatasources": [
+        "docker"
+      ],
+      "matchPackageNames": [
+        "docker.io/library/nginx"
+      ],
+      "replacementName": "cgr.dev/chainguard/nginx",
+      "replacementVersion": "latest"
+    },
+    {
+      "description": "Pin the digests of the Chainguard images, so that updates to their tags are proposed",
+      "matchDatasources": [
+        "docker"
+      ],
+      "matchPackageNames": [
+        "cgr.dev/chainguard/nginx"
+      ],
+      "pinDigests": true
+    }
+  ]
+}
+`,
+		},
+		{
+			name: "no mappings",
+			mappings: []*Mapping{
+				{Image: "nonexistent"},
+			},
+			want: `{
+  "packageRules": []
+}
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := outputRenovate(&buf, tc.mappings); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
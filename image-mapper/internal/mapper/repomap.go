@@ -0,0 +1,51 @@
+package mapper
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// repositoryRule sets the repository prefix of the results for the
+// Chainguard repositories that match a pattern
+type repositoryRule struct {
+	pattern string
+	repo    string
+}
+
+// parseRepositoryMap parses rules in the form PATTERN=REPOSITORY. The pattern
+// is a catalog tier, like FIPS, or a glob that matches the name of the
+// Chainguard repository, like *-fips. The pattern * matches every repository.
+func parseRepositoryMap(rules []string) ([]repositoryRule, error) {
+	var parsed []repositoryRule
+	for _, rule := range rules {
+		pattern, repo, ok := strings.Cut(rule, "=")
+		pattern = strings.TrimSpace(pattern)
+		repo = strings.TrimSpace(repo)
+		if !ok || pattern == "" || repo == "" {
+			return nil, fmt.Errorf("invalid repository rule: %q, expected PATTERN=REPOSITORY", rule)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid repository rule: %q: %w", rule, err)
+		}
+
+		repoName, err := parseRepo(repo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository rule: %q: %w", rule, err)
+		}
+		parsed = append(parsed, repositoryRule{pattern: pattern, repo: repoName})
+	}
+
+	return parsed, nil
+}
+
+// matches returns true if the rule applies to the Chainguard repository. Tiers
+// are matched regardless of case, like they are when they're ignored.
+func (r repositoryRule) matches(repo Repo) bool {
+	if repo.CatalogTier != "" && strings.EqualFold(r.pattern, repo.CatalogTier) {
+		return true
+	}
+	ok, _ := path.Match(r.pattern, repo.Name)
+
+	return ok
+}
@@ -0,0 +1,97 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMapperMapRepositoryMap(t *testing.T) {
+	repos := []Repo{
+		{Name: "nginx", CatalogTier: "APPLICATION", Aliases: []string{"nginx"}},
+		{Name: "nginx-fips", CatalogTier: "FIPS", Aliases: []string{"nginx"}},
+		{Name: "nginx-iamguarded", CatalogTier: "APPLICATION", Aliases: []string{"nginx"}},
+	}
+
+	testCases := map[string]struct {
+		rules []string
+		want  []string
+	}{
+		"no rules": {
+			want: []string{
+				"cgr.dev/chainguard/nginx",
+				"cgr.dev/chainguard/nginx-fips",
+				"cgr.dev/chainguard/nginx-iamguarded",
+			},
+		},
+		"tier": {
+			rules: []string{"fips=registry.internal/cg-fips"},
+			want: []string{
+				"cgr.dev/chainguard/nginx",
+				"cgr.dev/chainguard/nginx-iamguarded",
+				"registry.internal/cg-fips/nginx-fips",
+			},
+		},
+		"tier and default": {
+			rules: []string{"FIPS=registry.internal/cg-fips", "*=registry.internal/cg"},
+			want: []string{
+				"registry.internal/cg-fips/nginx-fips",
+				"registry.internal/cg/nginx",
+				"registry.internal/cg/nginx-iamguarded",
+			},
+		},
+		"glob": {
+			rules: []string{"*-iamguarded=registry.internal/iamguarded"},
+			want: []string{
+				"cgr.dev/chainguard/nginx",
+				"cgr.dev/chainguard/nginx-fips",
+				"registry.internal/iamguarded/nginx-iamguarded",
+			},
+		},
+		"first rule wins": {
+			rules: []string{"*=registry.internal/cg", "FIPS=registry.internal/cg-fips"},
+			want: []string{
+				"registry.internal/cg/nginx",
+				"registry.internal/cg/nginx-fips",
+				"registry.internal/cg/nginx-iamguarded",
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rules, err := parseRepositoryMap(tc.rules)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			m := &mapper{
+				repos:     repos,
+				repoName:  "cgr.dev/chainguard",
+				repoRules: rules,
+			}
+
+			got, err := m.Map("nginx")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Results); diff != "" {
+				t.Errorf("unexpected results (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRepositoryMapInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"FIPS",
+		"=registry.internal/cg",
+		"FIPS=",
+		"[=registry.internal/cg",
+		"FIPS=Not A Repository",
+	} {
+		t.Run(rule, func(t *testing.T) {
+			if _, err := parseRepositoryMap([]string{rule}); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -3,11 +3,17 @@ package mapper
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/sync/singleflight"
 )
 
 // Repo describes a repo in the catalog
@@ -22,6 +28,10 @@ type Repo struct {
 // Tag is a tag in a repository
 type Tag struct {
 	Name string `json:"name"`
+
+	// EOLDate is the date the tag's version reached end of life, when
+	// the catalog includes it
+	EOLDate string `json:"eolDate,omitempty"`
 }
 
 func flattenTags(tags []Tag) []string {
@@ -47,6 +57,9 @@ func parseRepo(repo string) (string, error) {
 	return "", fmt.Errorf("can't parse repository: %s", repo)
 }
 
+// DefaultCatalogURL is the public endpoint the catalog data is fetched from
+const DefaultCatalogURL = "https://data.chainguard.dev/query"
+
 var (
 	repoQuery = `
 query ChainguardPrivateImageCatalog {
@@ -74,7 +87,121 @@ query ChainguardPrivateImageCatalog {
 `
 )
 
-func listRepos(ctx context.Context, inactiveTags bool) ([]Repo, error) {
+// ListRepos returns the repositories in the catalog with any configured alias
+// overrides applied. These are the repositories the mapper matches against.
+func ListRepos(ctx context.Context, opts ...Option) ([]Repo, error) {
+	return listRepos(ctx, newOptions(opts...))
+}
+
+func listRepos(ctx context.Context, o *options) ([]Repo, error) {
+	client, cacheable, err := newRepoClient(o)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable && o.cacheDuration > 0 && o.cache != nil {
+		c := newSharedRepoClient(client, o.cache, cacheFile(o.catalogURL, o.inactiveTags), o.cacheDuration, o.staleWindow)
+		c.refresh = o.refresh
+		c.allowStale = o.allowStale
+		client = c
+	} else if cacheable && o.cacheDuration > 0 {
+		c, err := newCachedRepoClient(client, o.cacheDir, cacheFile(o.catalogURL, o.inactiveTags), o.cacheDuration)
+		if err != nil {
+			return nil, fmt.Errorf("constructing cache: %w", err)
+		}
+		c.refresh = o.refresh
+		c.allowStale = o.allowStale
+		client = c
+	}
+
+	// Concurrent calls for the same catalog, like the mappers that serve
+	// constructs, share one fetch
+	key := fmt.Sprintf("%s %t %t", o.catalogURL, o.inactiveTags, o.repoClient != nil)
+	v, err, _ := fetchGroup.Do(key, func() (any, error) {
+		return client.ListRepos(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The repositories are shared with the other callers, so they're copied
+	// before the aliases are overridden
+	repos := slices.Clone(v.([]Repo))
+
+	for _, src := range o.aliases {
+		overrides, err := LoadAliasOverrides(ctx, src)
+		if err != nil {
+			return nil, fmt.Errorf("loading alias overrides: %w", err)
+		}
+		repos = overrideAliases(repos, overrides)
+	}
+
+	return repos, nil
+}
+
+// fetchGroup deduplicates concurrent fetches of the catalog
+var fetchGroup singleflight.Group
+
+// cacheFile returns the name of the file the catalog data is cached in
+func cacheFile(catalogURL string, inactiveTags bool) string {
+	file := "repos.json"
+	if inactiveTags {
+		file = "repos-with-tags.json"
+	}
+
+	// Keep the data from other catalogs apart from the data from the
+	// default catalog
+	if catalogURL != DefaultCatalogURL {
+		sum := sha256.Sum256([]byte(catalogURL))
+		file = fmt.Sprintf("%x-%s", sum[:6], file)
+	}
+
+	return file
+}
+
+// RepoClient lists the repositories in the catalog
+type RepoClient interface {
+	ListRepos(ctx context.Context) ([]Repo, error)
+}
+
+// repoClient fetches repositories from the public catalog endpoint
+type repoClient struct {
+	url          string
+	inactiveTags bool
+}
+
+// catalogResponse is the response to the catalog query
+type catalogResponse struct {
+	Data struct {
+		Repos []Repo `json:"repos"`
+	} `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+// graphQLError is an error in a GraphQL response, which is returned with a
+// 200 status code and, possibly, some of the data
+type graphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+func (e graphQLError) String() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+
+	return fmt.Sprintf("%s: %s", strings.Join(path, "."), e.Message)
+}
+
+// ListRepos queries the catalog for repositories.
+//
+// GraphQL errors are returned as errors, even when the response has some of
+// the data, because mapping against part of the catalog would silently miss
+// images.
+func (rc *repoClient) ListRepos(ctx context.Context) ([]Repo, error) {
 	c := &http.Client{}
 
 	body := struct {
@@ -82,7 +209,7 @@ func listRepos(ctx context.Context, inactiveTags bool) ([]Repo, error) {
 	}{
 		Query: repoQuery,
 	}
-	if inactiveTags {
+	if rc.inactiveTags {
 		body.Query = repoQueryWithTags
 	}
 
@@ -91,13 +218,14 @@ func listRepos(ctx context.Context, inactiveTags bool) ([]Repo, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://data.chainguard.dev/query", &buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rc.url, &buf)
 	if err != nil {
 		return nil, fmt.Errorf("constructing request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("User-Agent", "image-mapper")
 
+	start := time.Now()
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("making request: %w", err)
@@ -105,17 +233,22 @@ func listRepos(ctx context.Context, inactiveTags bool) ([]Repo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	var data struct {
-		Data struct {
-			Repos []Repo `json:"repos"`
-		} `json:"data"`
-	}
+	var data catalogResponse
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
 		return nil, fmt.Errorf("unmarshaling body: %w", err)
 	}
+	if len(data.Errors) > 0 {
+		msgs := make([]string, len(data.Errors))
+		for i, e := range data.Errors {
+			msgs[i] = e.String()
+		}
+		return nil, fmt.Errorf("catalog returned errors with %d repos: %s", len(data.Data.Repos), strings.Join(msgs, "; "))
+	}
+
+	slog.Debug("fetched catalog", "url", rc.url, "repos", len(data.Data.Repos), "duration", time.Since(start).Round(time.Millisecond))
 
 	return fixAliases(data.Data.Repos), nil
 }
@@ -0,0 +1,110 @@
+package mapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListReposCatalogURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"repos":[{"name":"nginx","catalogTier":"APPLICATION","aliases":["nginx"],"activeTags":["latest"]}]}}`))
+	}))
+	defer srv.Close()
+
+	got, err := ListRepos(t.Context(), WithCatalogURL(srv.URL+"/query"), WithCacheDuration(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []Repo{
+		{
+			Name:        "nginx",
+			CatalogTier: "APPLICATION",
+			Aliases:     []string{"nginx"},
+			ActiveTags:  []string{"latest"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+}
+
+func TestListReposConcurrent(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Write([]byte(`{"data":{"repos":[{"name":"nginx","catalogTier":"APPLICATION","aliases":["nginx"],"activeTags":["latest"]}]}}`))
+	}))
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ListRepos(t.Context(), WithCatalogURL(srv.URL+"/concurrent"), WithCacheDuration(0))
+			errs <- err
+		}()
+	}
+
+	// Give the other calls time to join the one that's in flight
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected 1 request to the catalog, got %d", got)
+	}
+}
+
+func TestRepoClientErrors(t *testing.T) {
+	testCases := map[string]struct {
+		response string
+		wantErr  string
+	}{
+		"errors without data": {
+			response: `{"errors":[{"message":"not authorized"}]}`,
+			wantErr:  "catalog returned errors with 0 repos: not authorized",
+		},
+		"errors with partial data": {
+			response: `{"data":{"repos":[{"name":"nginx"}]},"errors":[{"message":"timeout","path":["repos",1,"activeTags"]},{"message":"timeout","path":["repos",2]}]}`,
+			wantErr:  "catalog returned errors with 1 repos: repos.1.activeTags: timeout; repos.2: timeout",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tc.response))
+			}))
+			defer srv.Close()
+
+			rc := &repoClient{url: srv.URL}
+			_, err := rc.ListRepos(t.Context())
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if err.Error() != tc.wantErr {
+				t.Errorf("unexpected error: got %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
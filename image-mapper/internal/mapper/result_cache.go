@@ -0,0 +1,262 @@
+package mapper
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// resultCacheMaxAge is how long a result cache that hasn't been written to
+// is kept before it's removed
+const resultCacheMaxAge = 7 * 24 * time.Hour
+
+// resultCache caches mappings on disk, so that repeated runs over the same
+// images only map the images they haven't seen before.
+//
+// The mappings are appended to a file named after a hash of the catalog data
+// and the configuration of the mapper, so a new snapshot of the catalog, or a
+// different configuration, starts a new cache rather than returning stale
+// results.
+type resultCache struct {
+	mu       sync.Mutex
+	path     string
+	mappings map[string]cachedMapping
+}
+
+// cachedMapping is a line in the result cache file
+type cachedMapping struct {
+	Key         string   `json:"key"`
+	Results     []string `json:"results,omitempty"`
+	ResolvedTag string   `json:"resolvedTag,omitempty"`
+	Origin      string   `json:"origin,omitempty"`
+	EOL         *EOL     `json:"eol,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// snapshot is the data that a mapping depends on, which the name of the
+// result cache file is a hash of
+type snapshot struct {
+	Repos         []snapshotRepo `json:"repos"`
+	Repository    string         `json:"repository"`
+	RepositoryMap []string       `json:"repositoryMap,omitempty"`
+	TagStrategy   string         `json:"tagStrategy"`
+	TierOrder     []string       `json:"tierOrder,omitempty"`
+
+	// ResolveDigests is set when the digests of images that are pinned
+	// to a digest without a tag are resolved, which changes their results
+	ResolveDigests bool `json:"resolveDigests,omitempty"`
+
+	// ResolveOrigins is set when the origins of images that are pinned to
+	// a digest and don't match anything are found, which changes their
+	// results
+	ResolveOrigins bool `json:"resolveOrigins,omitempty"`
+}
+
+type snapshotRepo struct {
+	Name        string   `json:"name"`
+	CatalogTier string   `json:"catalogTier"`
+	Aliases     []string `json:"aliases"`
+	Tags        []string `json:"tags"`
+}
+
+// newResultCache loads the result cache for the mapper from dir. The ignore
+// functions and tag filters can't be hashed, so the snapshot records the
+// repositories and tags that are left after applying them instead.
+func newResultCache(dir string, m *mapper, repoMap []string) (*resultCache, error) {
+	dir, err := cacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := snapshot{
+		Repository:    m.repoName,
+		RepositoryMap: repoMap,
+		TagStrategy:   m.tagStrategy,
+		TierOrder:     m.tierOrder,
+
+		ResolveDigests: m.digestResolver != nil,
+		ResolveOrigins: m.originResolver != nil,
+	}
+	for _, repo := range m.repos {
+		if repo.CatalogTier == "" || m.ignoreRepo(repo) {
+			continue
+		}
+		s.Repos = append(s.Repos, snapshotRepo{
+			Name:        repo.Name,
+			CatalogTier: repo.CatalogTier,
+			Aliases:     repo.Aliases,
+			Tags:        filterTags(repo, m.tagFilters...),
+		})
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling snapshot: %w", err)
+	}
+	sum := sha256.Sum256(data)
+
+	c := &resultCache{
+		path:     filepath.Join(dir, fmt.Sprintf("mappings-%x.jsonl", sum[:8])),
+		mappings: map[string]cachedMapping{},
+	}
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("reading result cache: %w", err)
+	}
+	slog.Debug("loaded result cache", "path", c.path, "mappings", len(c.mappings))
+	removeStaleResultCaches(dir, c.path)
+
+	return c, nil
+}
+
+// load reads the mappings in the cache file. Lines that can't be decoded,
+// like a line that was only partly written, are skipped.
+func (c *resultCache) load() error {
+	f, err := os.Open(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var m cachedMapping
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil || m.Key == "" {
+			slog.Debug("skipping invalid line in result cache", "path", c.path)
+			continue
+		}
+		c.mappings[m.Key] = m
+	}
+
+	return scanner.Err()
+}
+
+// get returns the cached mapping for the image
+func (c *resultCache) get(image string) (*Mapping, bool) {
+	key, err := resultKey(image)
+	if err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.mappings[key]
+	if !ok {
+		return nil, false
+	}
+
+	mapping := &Mapping{
+		Image:       image,
+		Results:     append([]string{}, m.Results...),
+		ResolvedTag: m.ResolvedTag,
+		Origin:      m.Origin,
+		EOL:         m.EOL,
+		Warnings:    append([]string(nil), m.Warnings...),
+	}
+	if _, digest, ok := strings.Cut(image, "@"); ok {
+		mapping.Digest = digest
+	}
+
+	return mapping, true
+}
+
+// put adds the mapping to the cache. Failing to write the cache doesn't fail
+// the mapping, so it's logged as a warning instead.
+func (c *resultCache) put(mapping *Mapping) {
+	key, err := resultKey(mapping.Image)
+	if err != nil {
+		return
+	}
+	m := cachedMapping{
+		Key:         key,
+		Results:     mapping.Results,
+		ResolvedTag: mapping.ResolvedTag,
+		Origin:      mapping.Origin,
+		EOL:         mapping.EOL,
+		Warnings:    mapping.Warnings,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.mappings[key]; ok {
+		return
+	}
+	c.mappings[key] = m
+
+	if err := c.append(m); err != nil {
+		slog.Warn("writing result cache", "path", c.path, "err", err)
+	}
+}
+
+func (c *resultCache) append(m cachedMapping) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// resultKey normalizes the image, so that references to the same image, like
+// nginx and docker.io/library/nginx:latest, share an entry in the cache
+func resultKey(image string) (string, error) {
+	repoTag, digest, hasDigest := strings.Cut(image, "@")
+	ref, err := name.NewTag(repoTag)
+	if err != nil {
+		return "", err
+	}
+	if hasDigest {
+		return ref.Name() + "@" + digest, nil
+	}
+
+	return ref.Name(), nil
+}
+
+// removeStaleResultCaches removes the result caches in dir, other than the
+// current one, that haven't been written to for resultCacheMaxAge. They were
+// made with an older snapshot of the catalog, so they won't be read again.
+func removeStaleResultCaches(dir, current string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "mappings-*.jsonl"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		if path == current {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < resultCacheMaxAge {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			slog.Debug("removing stale result cache", "path", path, "err", err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResultCache(t *testing.T) {
+	dir := t.TempDir()
+	newMapper := func(repos ...Repo) *mapper {
+		t.Helper()
+		m := &mapper{
+			repos:       repos,
+			repoName:    "cgr.dev/chainguard",
+			tagStrategy: TagStrategyMapSemver,
+		}
+		results, err := newResultCache(dir, m, nil)
+		if err != nil {
+			t.Fatalf("unexpected error constructing cache: %s", err)
+		}
+		m.results = results
+		return m
+	}
+	nginx := Repo{
+		Name:        "nginx",
+		CatalogTier: "APPLICATION",
+		Aliases:     []string{"nginx"},
+		ActiveTags:  []string{"1.25", "1.25.3", "latest"},
+	}
+
+	m := newMapper(nginx)
+	for _, image := range []string{"nginx:1.25", "redis:7"} {
+		if _, err := m.Map(image); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// A mapper with the same catalog data reads the mappings from the
+	// cache, which we can tell because its repositories are hidden from
+	// the mapping itself
+	m = newMapper(nginx)
+	m.repos = nil
+	testCases := map[string]*Mapping{
+		"nginx:1.25": {
+			Image:   "nginx:1.25",
+			Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+		},
+		"docker.io/library/nginx:1.25": {
+			Image:   "docker.io/library/nginx:1.25",
+			Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+		},
+		"redis:7": {
+			Image:   "redis:7",
+			Results: []string{},
+		},
+	}
+	for image, want := range testCases {
+		t.Run(image, func(t *testing.T) {
+			got, err := m.Map(image)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected mapping (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// New catalog data starts a new cache
+	nginx.ActiveTags = append(nginx.ActiveTags, "1.26")
+	m = newMapper(nginx)
+	m.repos = nil
+	got, err := m.Map("nginx:1.25")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(&Mapping{Image: "nginx:1.25", Results: []string{}}, got); diff != "" {
+		t.Errorf("unexpected mapping (-want +got):\n%s", diff)
+	}
+}
+
+func TestResultCacheSkipsInvalidLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mappings.jsonl")
+	data := `{"key":"index.docker.io/library/nginx:1.25","results":["cgr.dev/chainguard/nginx:1.25"]}
+{"key":"index.docker.io/library/redis:7","res
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("unexpected error writing cache: %s", err)
+	}
+
+	c := &resultCache{path: path, mappings: map[string]cachedMapping{}}
+	if err := c.load(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[string]cachedMapping{
+		"index.docker.io/library/nginx:1.25": {
+			Key:     "index.docker.io/library/nginx:1.25",
+			Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+		},
+	}
+	if diff := cmp.Diff(want, c.mappings); diff != "" {
+		t.Errorf("unexpected mappings (-want +got):\n%s", diff)
+	}
+}
+
+func TestRemoveStaleResultCaches(t *testing.T) {
+	dir := t.TempDir()
+	stale := time.Now().Add(-2 * resultCacheMaxAge)
+	files := map[string]time.Time{
+		"mappings-current.jsonl": stale,
+		"mappings-old.jsonl":     stale,
+		"mappings-recent.jsonl":  time.Now(),
+		"repos.json":             stale,
+	}
+	for file, modTime := range files {
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("unexpected error modifying file: %s", err)
+		}
+	}
+
+	removeStaleResultCaches(dir, filepath.Join(dir, "mappings-current.jsonl"))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	want := []string{"mappings-current.jsonl", "mappings-recent.jsonl", "repos.json"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,112 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules decide which repositories the mapper considers, with allow and deny
+// lists:
+//
+//	include:
+//	  - tier: APPLICATION
+//	exclude:
+//	  - name: "*-iamguarded*"
+//
+// Include rules are applied first: when there are any, a repository must
+// match at least one of them. Exclude rules are applied next and ignore the
+// repositories they match, even when they were included. The example only
+// considers APPLICATION repositories, except the iamguarded ones.
+type Rules struct {
+	Include []Rule `yaml:"include,omitempty"`
+	Exclude []Rule `yaml:"exclude,omitempty"`
+}
+
+// Rule matches repositories by tier, by name, or by both. The tier is
+// matched case insensitively and the name is a glob, like *-fips. A rule
+// with both only matches the repositories that match both.
+type Rule struct {
+	Tier string `yaml:"tier,omitempty"`
+	Name string `yaml:"name,omitempty"`
+}
+
+// LoadRules reads rules from a YAML file
+func LoadRules(path string) (Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, fmt.Errorf("reading rules: %w", err)
+	}
+
+	return ParseRules(data)
+}
+
+// ParseRules parses rules from YAML
+func ParseRules(data []byte) (Rules, error) {
+	var rules Rules
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return Rules{}, fmt.Errorf("parsing rules: %w", err)
+	}
+	if err := rules.validate(); err != nil {
+		return Rules{}, err
+	}
+
+	return rules, nil
+}
+
+func (r Rules) validate() error {
+	for _, rule := range append(append([]Rule{}, r.Include...), r.Exclude...) {
+		if rule.Tier == "" && rule.Name == "" {
+			return fmt.Errorf("invalid rule: a rule must have a tier or a name")
+		}
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return fmt.Errorf("invalid rule: name %q: %w", rule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// IgnoreFn returns an IgnoreFn that ignores the repositories that aren't
+// included, or that are excluded, by the rules. It can be combined with
+// other IgnoreFns, which ignore a repository when any of them do.
+func (r Rules) IgnoreFn() IgnoreFn {
+	return func(repo Repo) bool {
+		if len(r.Include) > 0 && !matchesAny(r.Include, repo) {
+			return true
+		}
+
+		return matchesAny(r.Exclude, repo)
+	}
+}
+
+// Matches reports whether the rule matches the repository
+func (r Rule) Matches(repo Repo) bool {
+	if r.Tier != "" && !strings.EqualFold(r.Tier, repo.CatalogTier) {
+		return false
+	}
+	if r.Name != "" {
+		if ok, _ := path.Match(r.Name, repo.Name); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesAny(rules []Rule, repo Repo) bool {
+	for _, rule := range rules {
+		if rule.Matches(repo) {
+			return true
+		}
+	}
+
+	return false
+}
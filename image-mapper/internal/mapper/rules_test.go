@@ -0,0 +1,100 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRulesIgnoreFn(t *testing.T) {
+	repos := []Repo{
+		{Name: "nginx", CatalogTier: "APPLICATION"},
+		{Name: "nginx-fips", CatalogTier: "FIPS"},
+		{Name: "nginx-iamguarded", CatalogTier: "APPLICATION"},
+		{Name: "python", CatalogTier: "BASE"},
+		{Name: "python-fips", CatalogTier: "FIPS"},
+	}
+
+	testCases := map[string]struct {
+		rules string
+		want  []string
+	}{
+		"no rules": {
+			rules: `{}`,
+			want:  []string{"nginx", "nginx-fips", "nginx-iamguarded", "python", "python-fips"},
+		},
+		"include tier": {
+			rules: `
+include:
+  - tier: application
+`,
+			want: []string{"nginx", "nginx-iamguarded"},
+		},
+		"exclude name": {
+			rules: `
+exclude:
+  - name: "*-fips"
+`,
+			want: []string{"nginx", "nginx-iamguarded", "python"},
+		},
+		"include tier except iamguarded": {
+			rules: `
+include:
+  - tier: APPLICATION
+exclude:
+  - name: "*-iamguarded*"
+`,
+			want: []string{"nginx"},
+		},
+		"include any of the rules": {
+			rules: `
+include:
+  - tier: BASE
+  - name: nginx*
+exclude:
+  - tier: FIPS
+`,
+			want: []string{"nginx", "nginx-iamguarded", "python"},
+		},
+		"rule with tier and name": {
+			rules: `
+exclude:
+  - tier: FIPS
+    name: python*
+`,
+			want: []string{"nginx", "nginx-fips", "nginx-iamguarded", "python"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rules, err := ParseRules([]byte(tc.rules))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var got []string
+			for _, repo := range FilterRepos(repos, rules.IgnoreFn()) {
+				got = append(got, repo.Name)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected repos (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	testCases := map[string]string{
+		"empty rule":    "include:\n  - {}\n",
+		"bad glob":      "exclude:\n  - name: \"[\"\n",
+		"unknown field": "include:\n  - repo: nginx\n",
+		"not a map":     "- tier: FIPS\n",
+	}
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseRules([]byte(data)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package mapper
+
+import _ "embed"
+
+// SchemaVersion is the version of the schema of the json and jsonl outputs.
+// It changes when a field is removed or changes meaning, but not when one is
+// added.
+const SchemaVersion = "1"
+
+// Schema is the JSON schema of the json output. Each line of the jsonl
+// output is an item of the array it describes.
+//
+//go:embed schema/mappings.json
+var Schema []byte
+
+// versionedMapping is a mapping with the version of the schema, as it's
+// written in the json and jsonl outputs
+type versionedMapping struct {
+	SchemaVersion string `json:"schemaVersion"`
+	*Mapping
+}
@@ -0,0 +1,52 @@
+package mapper
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// SearchRepos returns the repositories whose name or aliases contain the search
+// term. If the term is a valid image reference, it also returns the
+// repositories the mapper would match it to.
+func SearchRepos(repos []Repo, term string) []Repo {
+	term = strings.ToLower(strings.TrimSpace(term))
+
+	var ref name.Reference
+	if tag, err := name.NewTag(strings.Split(term, "@")[0]); err == nil {
+		ref = tag
+	}
+
+	var results []Repo
+	for _, repo := range repos {
+		// Repos without a tier aren't accessible in the catalog, so
+		// there's no point returning them.
+		if repo.CatalogTier == "" {
+			continue
+		}
+
+		if searchRepo(repo, term) || (ref != nil && Match(ref, repo)) {
+			results = append(results, repo)
+		}
+	}
+
+	slices.SortFunc(results, func(a, b Repo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return results
+}
+
+func searchRepo(repo Repo, term string) bool {
+	if strings.Contains(strings.ToLower(repo.Name), term) {
+		return true
+	}
+	for _, alias := range repo.Aliases {
+		if strings.Contains(strings.ToLower(alias), term) {
+			return true
+		}
+	}
+
+	return false
+}
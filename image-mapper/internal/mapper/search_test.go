@@ -0,0 +1,84 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSearchRepos(t *testing.T) {
+	repos := []Repo{
+		{
+			Name:        "stakater-reloader",
+			CatalogTier: "APPLICATION",
+		},
+		{
+			Name:        "nginx",
+			CatalogTier: "APPLICATION",
+			Aliases:     []string{"docker.io/library/nginx"},
+		},
+		{
+			Name:        "nginx-fips",
+			CatalogTier: "FIPS",
+		},
+		{
+			Name:        "ingress-nginx-controller",
+			CatalogTier: "APPLICATION",
+			Aliases:     []string{"registry.k8s.io/ingress-nginx/controller"},
+		},
+		{
+			Name:        "nginx-internal",
+			CatalogTier: "",
+		},
+		{
+			Name:        "vault-k8s",
+			CatalogTier: "APPLICATION",
+			Aliases:     []string{"hashicorp/vault-k8s"},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		term     string
+		expected []string
+	}{
+		{
+			name:     "name substring",
+			term:     "nginx",
+			expected: []string{"ingress-nginx-controller", "nginx", "nginx-fips"},
+		},
+		{
+			name:     "case insensitive",
+			term:     "NGINX-FIPS",
+			expected: []string{"nginx-fips"},
+		},
+		{
+			name:     "alias substring",
+			term:     "hashicorp",
+			expected: []string{"vault-k8s"},
+		},
+		{
+			name:     "image reference",
+			term:     "ghcr.io/stakater/reloader:v1.4.1",
+			expected: []string{"stakater-reloader"},
+		},
+		{
+			name:     "no results",
+			term:     "does-not-exist",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, repo := range SearchRepos(repos, tc.term) {
+				got = append(got, repo.Name)
+			}
+
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("unexpected results (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package mapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores the catalog data outside of the process, like in Redis or
+// memcached, so that it's shared by the replicas of the server rather than
+// each of them fetching the catalog
+type Cache interface {
+	// Get returns the value of the key. It returns false if the key isn't
+	// set or it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set sets the value of the key, which expires after the TTL. A TTL of
+	// 0 never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Add sets the value of the key only if it isn't already set. It
+	// returns false if it was.
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+
+	// Delete removes the key
+	Delete(ctx context.Context, key string) error
+}
+
+// sharedCacheLockTTL is how long a replica can hold the lock on fetching the
+// catalog, so that a replica that dies while it's fetching doesn't stop the
+// others from fetching it
+const sharedCacheLockTTL = 5 * time.Minute
+
+// sharedRepoClient caches the repositories returned by another RepoClient in
+// a Cache that's shared by many processes.
+//
+// Only one of the processes fetches the catalog at a time, which it marks
+// with a lock key. The others wait for it to cache the catalog and use that,
+// rather than fetching it too.
+type sharedRepoClient struct {
+	client   RepoClient
+	cache    Cache
+	key      string
+	duration time.Duration
+
+	// stale is how long after the cache has expired the cached
+	// repositories are still returned, while they're fetched again in
+	// the background
+	stale time.Duration
+
+	// refresh fetches the repositories and caches them, even when the
+	// cache hasn't expired
+	refresh bool
+
+	// allowStale returns the cached repositories, even when the cache has
+	// expired, if they can't be fetched
+	allowStale bool
+
+	// revalidating is set while the repositories are being fetched in the
+	// background
+	revalidating atomic.Bool
+}
+
+// sharedCacheEntry is the value of the cache key. The checksum is the sha256
+// of the repositories, like it is in the cache file.
+type sharedCacheEntry struct {
+	Fetched  time.Time       `json:"fetched"`
+	Checksum string          `json:"checksum"`
+	Repos    json.RawMessage `json:"repos"`
+}
+
+func newSharedRepoClient(client RepoClient, cache Cache, file string, duration, stale time.Duration) *sharedRepoClient {
+	return &sharedRepoClient{
+		client:   client,
+		cache:    cache,
+		key:      "image-mapper:" + file,
+		duration: duration,
+		stale:    stale,
+	}
+}
+
+// ListRepos returns the cached repositories if the cache hasn't expired. When
+// it has expired, but it's within the stale window, they're returned and
+// fetched again in the background. Otherwise, they're fetched and cached
+// before they're returned.
+func (c *sharedRepoClient) ListRepos(ctx context.Context) ([]Repo, error) {
+	start := time.Now()
+
+	repos, fetched, ok, err := c.read(ctx)
+	if err != nil {
+		return c.fetchUncached(ctx, fmt.Errorf("reading cache: %w", err))
+	}
+	if ok && !c.refresh {
+		age := time.Since(fetched)
+		if age <= c.duration {
+			slog.Debug("catalog cache hit", "key", c.key, "age", age.Round(time.Second), "repos", len(repos))
+			return repos, nil
+		}
+		if age <= c.duration+c.stale {
+			slog.Debug("catalog cache stale, revalidating", "key", c.key, "age", age.Round(time.Second))
+			go c.revalidate(context.WithoutCancel(ctx))
+			return repos, nil
+		}
+	}
+	slog.Debug("catalog cache miss", "key", c.key, "refresh", c.refresh)
+
+	for {
+		locked, err := c.lock(ctx)
+		if err != nil {
+			return c.fetchUncached(ctx, fmt.Errorf("locking cache: %w", err))
+		}
+		if locked {
+			break
+		}
+
+		// Another process is fetching the catalog, so wait for it to
+		// cache it
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+		latest, fetched, ok, err := c.read(ctx)
+		if err != nil {
+			return c.fetchUncached(ctx, fmt.Errorf("reading cache: %w", err))
+		}
+		if ok && fetched.After(start) {
+			return latest, nil
+		}
+	}
+	defer c.unlock(ctx)
+
+	// The process that held the lock may have cached the catalog between
+	// reading it and taking the lock
+	latest, latestFetched, latestOK, err := c.read(ctx)
+	if err != nil {
+		slog.Warn("reading cache", "key", c.key, "err", err)
+	}
+	if latestOK && latestFetched.After(start) {
+		return latest, nil
+	}
+
+	latest, err = c.fetch(ctx)
+	if err != nil {
+		if !c.allowStale || !ok {
+			return nil, err
+		}
+		slog.Warn("couldn't fetch the catalog, so the expired cache is being used instead. Mappings may be missing recent images and tags.", "age", time.Since(fetched).Round(time.Second), "err", err)
+		return repos, nil
+	}
+
+	return latest, nil
+}
+
+// revalidate fetches the repositories and caches them, unless another process
+// is already fetching them
+func (c *sharedRepoClient) revalidate(ctx context.Context) {
+	if !c.revalidating.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.revalidating.Store(false)
+
+	locked, err := c.lock(ctx)
+	if err != nil {
+		slog.Warn("revalidating the catalog cache", "err", err)
+		return
+	}
+	if !locked {
+		return
+	}
+	defer c.unlock(ctx)
+
+	if _, err := c.fetch(ctx); err != nil {
+		slog.Warn("revalidating the catalog cache", "err", err)
+	}
+}
+
+// fetch fetches the repositories with the underlying client and caches them.
+// The repositories are still returned if they can't be cached.
+func (c *sharedRepoClient) fetch(ctx context.Context) ([]Repo, error) {
+	repos, err := c.client.ListRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.write(ctx, repos); err != nil {
+		slog.Warn("couldn't cache the catalog, so the other processes will fetch it too", "key", c.key, "err", err)
+	}
+
+	return repos, nil
+}
+
+// fetchUncached fetches the repositories with the underlying client, without
+// the cache, when the cache can't be used. The cache being unavailable
+// shouldn't stop the catalog from being fetched.
+func (c *sharedRepoClient) fetchUncached(ctx context.Context, err error) ([]Repo, error) {
+	slog.Warn("couldn't use the catalog cache, so the catalog is being fetched without it", "key", c.key, "err", err)
+
+	return c.client.ListRepos(ctx)
+}
+
+// read returns the cached repositories and when they were fetched, whether
+// or not they've expired. It returns false if they aren't cached or the
+// entry is corrupt.
+func (c *sharedRepoClient) read(ctx context.Context) ([]Repo, time.Time, bool, error) {
+	data, ok, err := c.cache.Get(ctx, c.key)
+	if err != nil || !ok {
+		return nil, time.Time{}, false, err
+	}
+
+	var e sharedCacheEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		slog.Warn("ignoring corrupt cache", "key", c.key, "err", err)
+		return nil, time.Time{}, false, nil
+	}
+	if got := checksum(e.Repos); got != e.Checksum {
+		slog.Warn("ignoring corrupt cache", "key", c.key, "err", fmt.Errorf("checksum mismatch: expected %q, got %q", e.Checksum, got))
+		return nil, time.Time{}, false, nil
+	}
+
+	var repos []Repo
+	if err := json.Unmarshal(e.Repos, &repos); err != nil {
+		slog.Warn("ignoring corrupt cache", "key", c.key, "err", err)
+		return nil, time.Time{}, false, nil
+	}
+
+	return repos, e.Fetched, true, nil
+}
+
+// write caches the repositories. They're kept until the stale window has
+// passed or, when stale repositories are allowed if the catalog can't be
+// fetched, forever.
+func (c *sharedRepoClient) write(ctx context.Context, repos []Repo) error {
+	raw, err := json.Marshal(repos)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sharedCacheEntry{
+		Fetched:  time.Now(),
+		Checksum: checksum(raw),
+		Repos:    raw,
+	})
+	if err != nil {
+		return err
+	}
+
+	ttl := c.duration + c.stale
+	if c.allowStale {
+		ttl = 0
+	}
+
+	return c.cache.Set(ctx, c.key, data, ttl)
+}
+
+// lock takes the lock on fetching the catalog. It returns false if another
+// process holds it.
+func (c *sharedRepoClient) lock(ctx context.Context) (bool, error) {
+	return c.cache.Add(ctx, c.key+".lock", []byte(time.Now().Format(time.RFC3339)), sharedCacheLockTTL)
+}
+
+func (c *sharedRepoClient) unlock(ctx context.Context) {
+	if err := c.cache.Delete(context.WithoutCancel(ctx), c.key+".lock"); err != nil {
+		slog.Warn("unlocking cache", "key", c.key, "err", err)
+	}
+}
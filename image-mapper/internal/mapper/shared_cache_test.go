@@ -0,0 +1,223 @@
+package mapper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// memoryCache is a Cache in memory. Keys don't expire.
+type memoryCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{values: map[string][]byte{}}
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	return v, ok, nil
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	return nil
+}
+
+func (c *memoryCache) Add(_ context.Context, key string, value []byte, _ time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[key]; ok {
+		return false, nil
+	}
+	c.values[key] = value
+	return true, nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	return nil
+}
+
+// setEntry caches the repositories as if they were fetched at the time
+func setEntry(t *testing.T, c *memoryCache, key string, repos []Repo, fetched time.Time) {
+	t.Helper()
+
+	raw, err := json.Marshal(repos)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, err := json.Marshal(sharedCacheEntry{Fetched: fetched, Checksum: checksum(raw), Repos: raw})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_ = c.Set(t.Context(), key, data, 0)
+}
+
+func TestSharedRepoClient(t *testing.T) {
+	cached := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}}
+	fetched := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}, {Name: "redis", CatalogTier: "APPLICATION"}}
+
+	testCases := map[string]struct {
+		age        time.Duration
+		corrupt    bool
+		err        error
+		allowStale bool
+		refresh    bool
+		want       []Repo
+		wantErr    bool
+		wantCalls  int
+	}{
+		"miss": {
+			want:      fetched,
+			wantCalls: 1,
+		},
+		"hit": {
+			age:       time.Minute,
+			want:      cached,
+			wantCalls: 0,
+		},
+		"refresh": {
+			age:       time.Minute,
+			refresh:   true,
+			want:      fetched,
+			wantCalls: 1,
+		},
+		"expired": {
+			age:       3 * time.Hour,
+			want:      fetched,
+			wantCalls: 1,
+		},
+		"corrupt": {
+			age:       time.Minute,
+			corrupt:   true,
+			want:      fetched,
+			wantCalls: 1,
+		},
+		"expired and allow stale": {
+			age:        3 * time.Hour,
+			err:        errors.New("catalog is down"),
+			allowStale: true,
+			want:       cached,
+			wantCalls:  1,
+		},
+		"expired and not allow stale": {
+			age:       3 * time.Hour,
+			err:       errors.New("catalog is down"),
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cache := newMemoryCache()
+			client := &mockRepoClient{repos: fetched, err: tc.err}
+			c := newSharedRepoClient(client, cache, "repos.json", time.Hour, 0)
+			c.refresh = tc.refresh
+			c.allowStale = tc.allowStale
+
+			if tc.age > 0 {
+				setEntry(t, cache, c.key, cached, time.Now().Add(-tc.age))
+			}
+			if tc.corrupt {
+				_ = cache.Set(t.Context(), c.key, []byte(`{"checksum": "sha256:bad", "repos": []}`), 0)
+			}
+
+			got, err := c.ListRepos(t.Context())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Errorf("unexpected repos (-want +got):\n%s", diff)
+				}
+			}
+			if client.calls != tc.wantCalls {
+				t.Errorf("expected %d calls to the catalog, got %d", tc.wantCalls, client.calls)
+			}
+			if _, locked, _ := cache.Get(t.Context(), c.key+".lock"); locked {
+				t.Errorf("expected the lock to be released")
+			}
+		})
+	}
+}
+
+func TestSharedRepoClientStaleWhileRevalidate(t *testing.T) {
+	cached := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}}
+	fetched := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}, {Name: "redis", CatalogTier: "APPLICATION"}}
+
+	cache := newMemoryCache()
+	c := newSharedRepoClient(&mockRepoClient{repos: fetched}, cache, "repos.json", time.Hour, time.Hour)
+	setEntry(t, cache, c.key, cached, time.Now().Add(-90*time.Minute))
+
+	// The stale repositories are returned straight away
+	got, err := c.ListRepos(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(cached, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+
+	// And they're fetched again in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		repos, _, ok, err := c.read(t.Context())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if ok && cmp.Equal(fetched, repos) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the cache to be revalidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSharedRepoClientWaitsForLock(t *testing.T) {
+	fetched := []Repo{{Name: "nginx", CatalogTier: "APPLICATION"}}
+
+	cache := newMemoryCache()
+	client := &mockRepoClient{repos: []Repo{{Name: "unexpected"}}}
+	c := newSharedRepoClient(client, cache, "repos.json", time.Hour, 0)
+
+	// Another process is fetching the catalog, and caches it shortly
+	if ok, _ := c.lock(t.Context()); !ok {
+		t.Fatalf("expected to take the lock")
+	}
+	go func() {
+		time.Sleep(3 * lockRetryInterval)
+		setEntry(t, cache, c.key, fetched, time.Now())
+		c.unlock(context.Background())
+	}()
+
+	got, err := c.ListRepos(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(fetched, got); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+	if client.calls != 0 {
+		t.Errorf("expected the catalog not to be fetched, got %d calls", client.calls)
+	}
+}
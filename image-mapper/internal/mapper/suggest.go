@@ -0,0 +1,188 @@
+package mapper
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+// namespacePatterns are the ways that the names of the images in a namespace
+// can correspond to the names of Chainguard repositories. {name} is the
+// basename of the image and {namespace} is the last part of its namespace,
+// i.e bitnami for docker.io/bitnami/nginx.
+var namespacePatterns = []string{
+	"{name}",
+	"{name}-{namespace}",
+	"{namespace}-{name}",
+	"{name}-iamguarded",
+}
+
+// AliasSuggestion is a namespace level mapping rule, like bitnami/* ->
+// {name}-bitnami, that's followed by many of the images in the namespace,
+// along with the alias overrides that apply it to the images the mapper
+// doesn't already map that way.
+type AliasSuggestion struct {
+	// Namespace is the repository path the images share, without the
+	// registry, i.e bitnami
+	Namespace string `json:"namespace"`
+
+	// Pattern is the name of the Chainguard repository for an image in
+	// the namespace, i.e {name}-bitnami
+	Pattern string `json:"pattern"`
+
+	// Images is the number of unique repositories in the namespace
+	Images int `json:"images"`
+
+	// Matched is the number of those repositories that have a Chainguard
+	// repository named by the pattern
+	Matched int `json:"matched"`
+
+	// Overrides add the images that aren't already mapped to the
+	// Chainguard repository named by the pattern to its aliases
+	Overrides AliasOverrides `json:"overrides"`
+}
+
+// SuggestAliases groups the images by namespace and, for each namespace with
+// at least minImages repositories, finds the pattern that names the most
+// Chainguard repositories. A suggestion is returned for each namespace where
+// that pattern would map images that don't already map to those
+// repositories.
+func SuggestAliases(repos []Repo, images []string, minImages int) []AliasSuggestion {
+	byName := map[string]Repo{}
+	for _, repo := range repos {
+		if repo.CatalogTier == "" {
+			continue
+		}
+		byName[repo.Name] = repo
+	}
+
+	namespaces := map[string][]name.Reference{}
+	seen := map[string]struct{}{}
+	for _, image := range images {
+		repoTag, _, _ := strings.Cut(image, "@")
+		ref, err := name.NewTag(repoTag)
+		if err != nil {
+			continue
+		}
+		repository := ref.Context().RepositoryStr()
+		ns := path.Dir(repository)
+		if ns == "." || ns == "library" {
+			continue
+		}
+		if _, ok := seen[repository]; ok {
+			continue
+		}
+		seen[repository] = struct{}{}
+		namespaces[ns] = append(namespaces[ns], ref)
+	}
+
+	var suggestions []AliasSuggestion
+	for ns, refs := range namespaces {
+		if len(refs) < minImages {
+			continue
+		}
+
+		var best AliasSuggestion
+		for _, pattern := range namespacePatterns {
+			s := suggestNamespace(byName, ns, pattern, refs)
+			if s.Matched > best.Matched {
+				best = s
+			}
+		}
+		if len(best.Overrides) == 0 {
+			continue
+		}
+		suggestions = append(suggestions, best)
+	}
+	slices.SortFunc(suggestions, func(a, b AliasSuggestion) int {
+		return strings.Compare(a.Namespace, b.Namespace)
+	})
+
+	return suggestions
+}
+
+// suggestNamespace applies the pattern to the images in the namespace
+func suggestNamespace(repos map[string]Repo, ns, pattern string, refs []name.Reference) AliasSuggestion {
+	s := AliasSuggestion{
+		Namespace: ns,
+		Pattern:   strings.ReplaceAll(pattern, "{namespace}", path.Base(ns)),
+		Images:    len(refs),
+		Overrides: AliasOverrides{},
+	}
+	for _, ref := range refs {
+		repo, ok := repos[strings.ReplaceAll(s.Pattern, "{name}", path.Base(ref.Context().RepositoryStr()))]
+		if !ok {
+			continue
+		}
+		s.Matched++
+
+		if Match(ref, repo) {
+			continue
+		}
+		aliases, ok := s.Overrides[repo.Name]
+		if !ok {
+			aliases = slices.Clone(repo.Aliases)
+		}
+		s.Overrides[repo.Name] = append(aliases, ref.Context().RepositoryStr())
+	}
+
+	return s
+}
+
+// WriteAliasSuggestions writes the overrides of the suggestions as an alias
+// overrides file for --aliases. Each namespace is introduced by a comment
+// that describes its rule. When more than one namespace adds aliases to the
+// same repository, the aliases are merged into the first entry.
+func WriteAliasSuggestions(w io.Writer, suggestions []AliasSuggestion) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	entries := map[string]*yaml.Node{}
+	for _, s := range suggestions {
+		comment := fmt.Sprintf("%s/*: %s matches %d of %d images", s.Namespace, s.Pattern, s.Matched, s.Images)
+
+		names := make([]string, 0, len(s.Overrides))
+		for repo := range s.Overrides {
+			names = append(names, repo)
+		}
+		slices.Sort(names)
+
+		commented := false
+		for _, repo := range names {
+			if seq, ok := entries[repo]; ok {
+				for _, alias := range s.Overrides[repo] {
+					if !slices.ContainsFunc(seq.Content, func(n *yaml.Node) bool { return n.Value == alias }) {
+						seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: alias})
+					}
+				}
+				continue
+			}
+
+			key := &yaml.Node{Kind: yaml.ScalarNode, Value: repo}
+			if !commented {
+				key.HeadComment = comment
+				commented = true
+			}
+			seq := &yaml.Node{Kind: yaml.SequenceNode}
+			for _, alias := range s.Overrides[repo] {
+				seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: alias})
+			}
+			entries[repo] = seq
+			doc.Content = append(doc.Content, key, seq)
+		}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding alias overrides: %w", err)
+	}
+
+	return enc.Close()
+}
@@ -0,0 +1,134 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSuggestAliases(t *testing.T) {
+	repos := []Repo{
+		{Name: "nginx-bitnami", CatalogTier: "APPLICATION", Aliases: []string{"nginx"}},
+		{Name: "redis-bitnami", CatalogTier: "APPLICATION"},
+		{Name: "postgresql-bitnami", CatalogTier: "APPLICATION"},
+		{Name: "kafka-bitnami", CatalogTier: "APPLICATION", Aliases: []string{"bitnami/kafka"}},
+		{Name: "mongodb-bitnami"},
+		{Name: "kafka", CatalogTier: "APPLICATION"},
+		{Name: "redis", CatalogTier: "APPLICATION"},
+		{Name: "reloader", CatalogTier: "APPLICATION"},
+		{Name: "vault", CatalogTier: "APPLICATION"},
+	}
+
+	testCases := []struct {
+		name      string
+		images    []string
+		minImages int
+		want      []AliasSuggestion
+	}{
+		{
+			name: "namespace rule",
+			images: []string{
+				"docker.io/bitnami/nginx:1.25",
+				"bitnami/redis",
+				"registry.internal/bitnami/redis:7",
+				"bitnami/postgresql:16@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				"bitnami/kafka",
+				"bitnami/mongodb",
+			},
+			minImages: 3,
+			want: []AliasSuggestion{
+				{
+					Namespace: "bitnami",
+					Pattern:   "{name}-bitnami",
+					Images:    5,
+					Matched:   4,
+					Overrides: AliasOverrides{
+						"nginx-bitnami":      {"nginx", "bitnami/nginx"},
+						"postgresql-bitnami": {"bitnami/postgresql"},
+						"redis-bitnami":      {"bitnami/redis"},
+					},
+				},
+			},
+		},
+		{
+			name: "too few images",
+			images: []string{
+				"bitnami/nginx",
+				"bitnami/redis",
+			},
+			minImages: 3,
+		},
+		{
+			name: "already mapped",
+			images: []string{
+				"ghcr.io/stakater/reloader",
+				"hashicorp/vault",
+				"library/redis",
+				"redis",
+			},
+			minImages: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SuggestAliases(repos, tc.images, tc.minImages)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected suggestions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteAliasSuggestions(t *testing.T) {
+	suggestions := []AliasSuggestion{
+		{
+			Namespace: "bitnami",
+			Pattern:   "{name}-bitnami",
+			Images:    5,
+			Matched:   4,
+			Overrides: AliasOverrides{
+				"redis-bitnami": {"bitnami/redis"},
+				"nginx-bitnami": {"nginx", "bitnami/nginx"},
+			},
+		},
+		{
+			Namespace: "team/mirror/bitnami",
+			Pattern:   "{name}-bitnami",
+			Images:    3,
+			Matched:   3,
+			Overrides: AliasOverrides{
+				"nginx-bitnami": {"nginx", "team/mirror/bitnami/nginx"},
+				"kafka-bitnami": {"team/mirror/bitnami/kafka"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAliasSuggestions(&buf, suggestions); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `# bitnami/*: {name}-bitnami matches 4 of 5 images
+nginx-bitnami:
+  - nginx
+  - bitnami/nginx
+  - team/mirror/bitnami/nginx
+redis-bitnami:
+  - bitnami/redis
+# team/mirror/bitnami/*: {name}-bitnami matches 3 of 3 images
+kafka-bitnami:
+  - team/mirror/bitnami/kafka
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteAliasSuggestions(&buf, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
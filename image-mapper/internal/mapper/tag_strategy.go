@@ -0,0 +1,62 @@
+package mapper
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Strategies for choosing the tag of the results
+const (
+	// TagStrategyMapSemver maps the tag to the equivalent, or closest,
+	// version of the Chainguard image. This is the default.
+	TagStrategyMapSemver = "map-semver"
+
+	// TagStrategyKeepUpstream keeps the tag of the upstream image, even
+	// when the Chainguard image doesn't have it
+	TagStrategyKeepUpstream = "keep-upstream"
+
+	// TagStrategyLatest uses the latest tag, or latest-dev when the tag
+	// filters only allow -dev tags
+	TagStrategyLatest = "latest"
+
+	// TagStrategyLatestDev uses the latest-dev tag
+	TagStrategyLatestDev = "latest-dev"
+)
+
+var tagStrategies = []string{
+	TagStrategyMapSemver,
+	TagStrategyKeepUpstream,
+	TagStrategyLatest,
+	TagStrategyLatestDev,
+}
+
+func validateTagStrategy(strategy string) error {
+	if !slices.Contains(tagStrategies, strategy) {
+		return fmt.Errorf("unsupported tag strategy: %s (supported: %s, %s, %s, %s)", strategy, TagStrategyMapSemver, TagStrategyKeepUpstream, TagStrategyLatest, TagStrategyLatestDev)
+	}
+
+	return nil
+}
+
+// chooseTag returns the tag of the result for the input tag, from the tags of
+// the Chainguard repository, and a warning about the tag if there is one.
+// An empty tag means the result doesn't have one.
+func chooseTag(strategy string, tags []string, tag string) (string, string) {
+	switch strategy {
+	case TagStrategyKeepUpstream:
+		if len(tags) > 0 && !slices.Contains(tags, tag) {
+			return tag, fmt.Sprintf("kept the upstream tag %s, which isn't an active tag of the Chainguard image", tag)
+		}
+		return tag, ""
+	case TagStrategyLatest:
+		if !slices.Contains(tags, "latest") && slices.Contains(tags, "latest-dev") {
+			return "latest-dev", ""
+		}
+		return "latest", ""
+	case TagStrategyLatestDev:
+		return "latest-dev", ""
+	default:
+		match := MatchTag(tags, tag)
+		return match, TagWarning(tags, tag, match)
+	}
+}
@@ -0,0 +1,121 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChooseTag(t *testing.T) {
+	tags := []string{"latest", "latest-dev", "1.25", "1.25-dev", "1.27", "1.27-dev"}
+	devTags := []string{"latest-dev", "1.25-dev"}
+
+	testCases := []struct {
+		name        string
+		strategy    string
+		tags        []string
+		tag         string
+		wantTag     string
+		wantWarning string
+	}{
+		{
+			name:     "map-semver",
+			strategy: TagStrategyMapSemver,
+			tags:     tags,
+			tag:      "1.25",
+			wantTag:  "1.25",
+		},
+		{
+			name:        "map-semver nearest",
+			strategy:    TagStrategyMapSemver,
+			tags:        tags,
+			tag:         "1.26",
+			wantTag:     "1.27",
+			wantWarning: "no tag equivalent to 1.26, using the nearest available version 1.27",
+		},
+		{
+			name:     "keep-upstream",
+			strategy: TagStrategyKeepUpstream,
+			tags:     tags,
+			tag:      "1.25",
+			wantTag:  "1.25",
+		},
+		{
+			name:        "keep-upstream inactive",
+			strategy:    TagStrategyKeepUpstream,
+			tags:        tags,
+			tag:         "1.26",
+			wantTag:     "1.26",
+			wantWarning: "kept the upstream tag 1.26, which isn't an active tag of the Chainguard image",
+		},
+		{
+			name:     "keep-upstream without tags",
+			strategy: TagStrategyKeepUpstream,
+			tag:      "1.26",
+			wantTag:  "1.26",
+		},
+		{
+			name:     "latest",
+			strategy: TagStrategyLatest,
+			tags:     tags,
+			tag:      "1.25",
+			wantTag:  "latest",
+		},
+		{
+			name:     "latest dev tags",
+			strategy: TagStrategyLatest,
+			tags:     devTags,
+			tag:      "1.25",
+			wantTag:  "latest-dev",
+		},
+		{
+			name:     "latest-dev",
+			strategy: TagStrategyLatestDev,
+			tags:     tags,
+			tag:      "1.25",
+			wantTag:  "latest-dev",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotTag, gotWarning := chooseTag(tc.strategy, tc.tags, tc.tag)
+			if diff := cmp.Diff(tc.wantTag, gotTag); diff != "" {
+				t.Errorf("unexpected tag (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantWarning, gotWarning); diff != "" {
+				t.Errorf("unexpected warning (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMapperMapTagStrategy(t *testing.T) {
+	m := &mapper{
+		repos: []Repo{
+			{Name: "nginx", CatalogTier: "APPLICATION", ActiveTags: []string{"latest", "1.25", "1.27"}},
+		},
+		repoName:    "cgr.dev/chainguard",
+		tagStrategy: TagStrategyKeepUpstream,
+	}
+
+	got, err := m.Map("nginx:1.26")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := &Mapping{
+		Image:    "nginx:1.26",
+		Results:  []string{"cgr.dev/chainguard/nginx:1.26"},
+		EOL:      &EOL{Version: "1.26", Recommended: "1.27"},
+		Warnings: []string{"cgr.dev/chainguard/nginx:1.26: kept the upstream tag 1.26, which isn't an active tag of the Chainguard image"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected mapping (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateTagStrategy(t *testing.T) {
+	if err := validateTagStrategy("newest"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,40 @@
+package mapper
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// normalizeTiers returns the tiers in lower case, so that they're matched
+// regardless of case, like they are by IgnoreTiers
+func normalizeTiers(tiers []string) []string {
+	var normalized []string
+	for _, tier := range tiers {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(tier)))
+	}
+
+	return normalized
+}
+
+// sortResults sorts the results by the position of their tier in the tier
+// order, then alphabetically. Without a tier order, they're only sorted
+// alphabetically.
+func (m *mapper) sortResults(results []string, tiers map[string]string) {
+	slices.SortFunc(results, func(a, b string) int {
+		return cmp.Or(
+			cmp.Compare(m.tierRank(tiers[a]), m.tierRank(tiers[b])),
+			strings.Compare(a, b),
+		)
+	})
+}
+
+// tierRank returns the position of the tier in the tier order. Tiers that
+// aren't in the order come after all of those that are.
+func (m *mapper) tierRank(tier string) int {
+	if i := slices.Index(m.tierOrder, strings.ToLower(tier)); i >= 0 {
+		return i
+	}
+
+	return len(m.tierOrder)
+}
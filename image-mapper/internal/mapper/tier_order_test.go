@@ -0,0 +1,80 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMapperMapTierOrder(t *testing.T) {
+	repos := []Repo{
+		{
+			Name:        "nginx",
+			CatalogTier: "APPLICATION",
+		},
+		{
+			Name:        "nginx-fips",
+			CatalogTier: "FIPS",
+			Aliases:     []string{"nginx"},
+		},
+		{
+			Name:        "nginx-base",
+			CatalogTier: "BASE",
+			Aliases:     []string{"nginx"},
+		},
+		{
+			Name:        "nginx-ai",
+			CatalogTier: "AI",
+			Aliases:     []string{"nginx"},
+		},
+	}
+
+	testCases := map[string]struct {
+		tierOrder []string
+		want      []string
+	}{
+		"no order": {
+			want: []string{
+				"cgr.dev/chainguard/nginx",
+				"cgr.dev/chainguard/nginx-ai",
+				"cgr.dev/chainguard/nginx-base",
+				"cgr.dev/chainguard/nginx-fips",
+			},
+		},
+		"fips first": {
+			tierOrder: []string{"FIPS", "APPLICATION", "BASE", "AI"},
+			want: []string{
+				"cgr.dev/chainguard/nginx-fips",
+				"cgr.dev/chainguard/nginx",
+				"cgr.dev/chainguard/nginx-base",
+				"cgr.dev/chainguard/nginx-ai",
+			},
+		},
+		"unlisted tiers last in alphabetical order": {
+			tierOrder: []string{"base"},
+			want: []string{
+				"cgr.dev/chainguard/nginx-base",
+				"cgr.dev/chainguard/nginx",
+				"cgr.dev/chainguard/nginx-ai",
+				"cgr.dev/chainguard/nginx-fips",
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			m := &mapper{
+				repos:     repos,
+				repoName:  "cgr.dev/chainguard",
+				tierOrder: normalizeTiers(tc.tierOrder),
+			}
+
+			got, err := m.Map("nginx")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Results); diff != "" {
+				t.Errorf("unexpected results (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServe(t *testing.T) {
+	echo := Tool{
+		Name:        "echo",
+		Description: "Echoes the message.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]Schema{
+				"message": {Type: "string"},
+			},
+			Required: []string{"message"},
+		},
+		Call: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a struct {
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(args, &a); err != nil {
+				return "", err
+			}
+			if a.Message == "" {
+				return "", fmt.Errorf("no message")
+			}
+			return a.Message, nil
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{
+			name:    "initialize",
+			request: `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+			want:    `{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"tools":{}},"protocolVersion":"2025-03-26","serverInfo":{"name":"test","version":"v1"}}}`,
+		},
+		{
+			name:    "initialize unknown version",
+			request: `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2000-01-01"}}`,
+			want:    `{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"tools":{}},"protocolVersion":"2025-06-18","serverInfo":{"name":"test","version":"v1"}}}`,
+		},
+		{
+			name:    "notification",
+			request: `{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			want:    ``,
+		},
+		{
+			name:    "ping",
+			request: `{"jsonrpc":"2.0","id":"a","method":"ping"}`,
+			want:    `{"jsonrpc":"2.0","id":"a","result":{}}`,
+		},
+		{
+			name:    "list tools",
+			request: `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+			want:    `{"jsonrpc":"2.0","id":2,"result":{"tools":[{"name":"echo","description":"Echoes the message.","inputSchema":{"type":"object","properties":{"message":{"type":"string"}},"required":["message"]}}]}}`,
+		},
+		{
+			name:    "call tool",
+			request: `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"message":"hello"}}}`,
+			want:    `{"jsonrpc":"2.0","id":3,"result":{"content":[{"type":"text","text":"hello"}]}}`,
+		},
+		{
+			name:    "call tool error",
+			request: `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo"}}`,
+			want:    `{"jsonrpc":"2.0","id":4,"result":{"content":[{"type":"text","text":"no message"}],"isError":true}}`,
+		},
+		{
+			name:    "unknown tool",
+			request: `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"foo"}}`,
+			want:    `{"jsonrpc":"2.0","id":5,"error":{"code":-32602,"message":"unknown tool: foo"}}`,
+		},
+		{
+			name:    "unknown method",
+			request: `{"jsonrpc":"2.0","id":6,"method":"resources/list"}`,
+			want:    `{"jsonrpc":"2.0","id":6,"error":{"code":-32601,"message":"method not found: resources/list"}}`,
+		},
+		{
+			name:    "invalid json",
+			request: `{"jsonrpc":`,
+			want:    `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parsing message: unexpected end of JSON input"}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer("test", "v1", echo)
+
+			var out bytes.Buffer
+			if err := s.Serve(context.Background(), strings.NewReader(tc.request+"\n"), &out); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.want, strings.TrimSpace(out.String())); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
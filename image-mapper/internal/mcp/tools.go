@@ -0,0 +1,178 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/dockerfile"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+)
+
+// Tool is a tool that the server exposes
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema Schema
+
+	// Call calls the tool with the arguments, which match the input
+	// schema, and returns the text result
+	Call func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Schema is the subset of JSON Schema we need to describe tool inputs
+type Schema struct {
+	Type                 string            `json:"type"`
+	Description          string            `json:"description,omitempty"`
+	Properties           map[string]Schema `json:"properties,omitempty"`
+	Items                *Schema           `json:"items,omitempty"`
+	AdditionalProperties *Schema           `json:"additionalProperties,omitempty"`
+	Required             []string          `json:"required,omitempty"`
+}
+
+// MapImageTool returns the map_image tool, which maps one or more image
+// references with the mapper
+func MapImageTool(m mapper.Mapper) Tool {
+	return Tool{
+		Name:        "map_image",
+		Description: "Map upstream container image references, like nginx:1.25 or ghcr.io/example/app, to the equivalent Chainguard images. Returns the matching images and any warnings about the matches, like a version that isn't available.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]Schema{
+				"image": {
+					Type:        "string",
+					Description: "An image reference to map.",
+				},
+				"images": {
+					Type:        "array",
+					Description: "Image references to map.",
+					Items:       &Schema{Type: "string"},
+				},
+			},
+		},
+		Call: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a struct {
+				Image  string   `json:"image"`
+				Images []string `json:"images"`
+			}
+			if err := json.Unmarshal(args, &a); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			images := a.Images
+			if a.Image != "" {
+				images = append([]string{a.Image}, images...)
+			}
+			if len(images) == 0 {
+				return "", fmt.Errorf("provide an image or images to map")
+			}
+
+			mappings := []*mapper.Mapping{}
+			for _, img := range images {
+				mapping, err := m.Map(img)
+				if err != nil {
+					return "", fmt.Errorf("mapping %s: %w", img, err)
+				}
+				mappings = append(mappings, mapping)
+			}
+
+			return marshal(mappings)
+		},
+	}
+}
+
+// SearchCatalogTool returns the search_catalog tool, which searches the repos
+// for a term
+func SearchCatalogTool(repos []mapper.Repo) Tool {
+	return Tool{
+		Name:        "search_catalog",
+		Description: "Search the Chainguard catalog for repositories whose name or aliases match a term, like redis or bitnami/postgresql. Returns the name, tier, aliases and active tags of each repository.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]Schema{
+				"query": {
+					Type:        "string",
+					Description: "The term to search for. It can be part of a name or an upstream image reference.",
+				},
+			},
+			Required: []string{"query"},
+		},
+		Call: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a struct {
+				Query string `json:"query"`
+			}
+			if err := json.Unmarshal(args, &a); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			if a.Query == "" {
+				return "", fmt.Errorf("provide a query to search for")
+			}
+
+			results := mapper.SearchRepos(repos, a.Query)
+			if results == nil {
+				results = []mapper.Repo{}
+			}
+
+			return marshal(results)
+		},
+	}
+}
+
+// MapDockerfileTool returns the map_dockerfile tool, which maps the images in
+// a Dockerfile with the mapper
+func MapDockerfileTool(m mapper.Mapper) Tool {
+	return Tool{
+		Name:        "map_dockerfile",
+		Description: "Map the images in the FROM instructions of a Dockerfile to Chainguard images. Returns the Dockerfile with the images replaced.",
+		InputSchema: Schema{
+			Type: "object",
+			Properties: map[string]Schema{
+				"dockerfile": {
+					Type:        "string",
+					Description: "The contents of the Dockerfile.",
+				},
+				"build_args": {
+					Type:                 "object",
+					Description:          "Values for the ARG instructions, like --build-arg for docker build.",
+					AdditionalProperties: &Schema{Type: "string"},
+				},
+				"annotate": {
+					Type:        "boolean",
+					Description: "Add comments above the images that couldn't be mapped or have warnings.",
+				},
+			},
+			Required: []string{"dockerfile"},
+		},
+		Call: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a struct {
+				Dockerfile string            `json:"dockerfile"`
+				BuildArgs  map[string]string `json:"build_args"`
+				Annotate   bool              `json:"annotate"`
+			}
+			if err := json.Unmarshal(args, &a); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			if a.Dockerfile == "" {
+				return "", fmt.Errorf("provide a dockerfile to map")
+			}
+
+			output, err := dockerfile.MapWith(m, []byte(a.Dockerfile), dockerfile.Options{
+				BuildArgs: a.BuildArgs,
+				Annotate:  a.Annotate,
+			})
+			if err != nil {
+				return "", err
+			}
+
+			return string(output), nil
+		},
+	}
+}
+
+func marshal(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding result: %w", err)
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockMapper struct {
+	mappings map[string]string
+}
+
+func (m *mockMapper) Map(img string) (*mapper.Mapping, error) {
+	mapping := &mapper.Mapping{Image: img}
+	if result, ok := m.mappings[img]; ok {
+		mapping.Results = []string{result}
+	}
+	return mapping, nil
+}
+
+func TestTools(t *testing.T) {
+	m := &mockMapper{mappings: map[string]string{
+		"nginx":       "cgr.dev/chainguard/nginx:latest",
+		"python:3.12": "cgr.dev/chainguard/python:3.12-dev",
+	}}
+	repos := []mapper.Repo{
+		{Name: "nginx", CatalogTier: "APPLICATION", Aliases: []string{"nginx:latest"}, ActiveTags: []string{"latest"}},
+		{Name: "redis", CatalogTier: "APPLICATION", ActiveTags: []string{"latest"}},
+	}
+
+	testCases := []struct {
+		name    string
+		tool    Tool
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "map image",
+			tool: MapImageTool(m),
+			args: `{"image": "nginx"}`,
+			want: `[
+  {
+    "image": "nginx",
+    "results": [
+      "cgr.dev/chainguard/nginx:latest"
+    ]
+  }
+]`,
+		},
+		{
+			name: "map images",
+			tool: MapImageTool(m),
+			args: `{"images": ["python:3.12", "unknown"]}`,
+			want: `[
+  {
+    "image": "python:3.12",
+    "results": [
+      "cgr.dev/chainguard/python:3.12-dev"
+    ]
+  },
+  {
+    "image": "unknown"
+  }
+]`,
+		},
+		{
+			name:    "map no images",
+			tool:    MapImageTool(m),
+			args:    `{}`,
+			wantErr: true,
+		},
+		{
+			name: "search catalog",
+			tool: SearchCatalogTool(repos),
+			args: `{"query": "redis"}`,
+			want: `[
+  {
+    "name": "redis",
+    "catalogTier": "APPLICATION",
+    "aliases": null,
+    "activeTags": [
+      "latest"
+    ],
+    "tags": null
+  }
+]`,
+		},
+		{
+			name: "search catalog no results",
+			tool: SearchCatalogTool(repos),
+			args: `{"query": "postgres"}`,
+			want: `[]`,
+		},
+		{
+			name: "map dockerfile",
+			tool: MapDockerfileTool(m),
+			args: `{"dockerfile": "ARG VERSION=3.11\nFROM python:${VERSION}\n", "build_args": {"VERSION": "3.12"}}`,
+			want: "ARG VERSION=3.11\nFROM cgr.dev/chainguard/python:3.12-dev\n",
+		},
+		{
+			name:    "map dockerfile empty",
+			tool:    MapDockerfileTool(m),
+			args:    `{"dockerfile": ""}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.tool.Call(context.Background(), json.RawMessage(tc.args))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+)
+
+// groups are the API groups of the pipeline resources, i.e Tekton's Task,
+// Pipeline and PipelineRun and Argo Workflows' Workflow, WorkflowTemplate and
+// CronWorkflow
+var groups = []string{"tekton.dev", "argoproj.io"}
+
+// ListImages returns the images used by the steps, containers and templates
+// of the Tekton and Argo Workflows resources in the files at the provided
+// paths. Directories are searched for YAML files. An image is returned once
+// for each container that uses it.
+//
+// Files that can't be parsed, like templates, are skipped with a warning.
+func ListImages(paths []string) ([]string, error) {
+	var images []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading file: %s: %w", path, err)
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			files, err = FindFiles(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			input, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("reading file: %s: %w", file, err)
+			}
+
+			imgs, err := Images(input)
+			if err != nil {
+				slog.Warn("skipping file", "path", file, "err", err)
+				continue
+			}
+			images = append(images, imgs...)
+		}
+	}
+
+	return images, nil
+}
+
+// Images returns the images used by the Tekton and Argo Workflows resources in
+// a multi-document YAML stream. Other resources are ignored.
+func Images(input []byte) ([]string, error) {
+	imgs, err := manifest.Images(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	for _, img := range imgs {
+		if !isPipeline(img.APIVersion) {
+			continue
+		}
+		images = append(images, img.Image)
+	}
+
+	return images, nil
+}
+
+// isPipeline returns true if the API version belongs to one of the pipeline
+// API groups
+func isPipeline(apiVersion string) bool {
+	group, _, ok := strings.Cut(apiVersion, "/")
+	if !ok {
+		return false
+	}
+
+	return slices.Contains(groups, group)
+}
+
+// skipDirs are directories that won't contain pipelines we're interested in
+var skipDirs = map[string]struct{}{
+	".git":         {},
+	"node_modules": {},
+}
+
+// FindFiles returns the paths of the YAML files in the directory and its
+// subdirectories
+func FindFiles(dir string) ([]string, error) {
+	var paths []string
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if _, ok := skipDirs[d.Name()]; ok && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(d.Name())) {
+		case ".yaml", ".yml":
+			paths = append(paths, path)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking directory: %s: %w", dir, err)
+	}
+
+	return paths, nil
+}
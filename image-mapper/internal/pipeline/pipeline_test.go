@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListImages(t *testing.T) {
+	got, err := ListImages([]string{"testdata/pipelines"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The Deployment isn't a pipeline, the templated file can't be
+	// parsed and node_modules is skipped
+	want := []string{
+		"python:3.12",
+		"node:22",
+		"postgres:16",
+		"bitnami/kubectl:1.31",
+		"curlimages/curl:8.10.1",
+		"alpine:3.20",
+		"golang:1.23",
+		"gcr.io/kaniko-project/executor:v1.23.2",
+		"registry:2",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestListImagesFile(t *testing.T) {
+	got, err := ListImages([]string{"testdata/pipelines/tekton/pipeline.yaml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{"curlimages/curl:8.10.1"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+}
+
+func TestListImagesMissing(t *testing.T) {
+	if _, err := ListImages([]string{"testdata/missing.yaml"}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
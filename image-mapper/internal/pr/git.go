@@ -0,0 +1,53 @@
+package pr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in the directory and returns its output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// CurrentBranch returns the branch that is checked out in the repository
+func CurrentBranch(ctx context.Context, dir string) (string, error) {
+	return git(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD")
+}
+
+// RemoteURL returns the URL of the origin remote of the repository
+func RemoteURL(ctx context.Context, dir string) (string, error) {
+	return git(ctx, dir, "remote", "get-url", "origin")
+}
+
+// Commit creates the branch in the repository and commits the files to it
+func Commit(ctx context.Context, dir, branch, message string, files []string) error {
+	if _, err := git(ctx, dir, "checkout", "-b", branch); err != nil {
+		return err
+	}
+	if _, err := git(ctx, dir, append([]string{"add", "--"}, files...)...); err != nil {
+		return err
+	}
+	if _, err := git(ctx, dir, "commit", "-m", message); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Push pushes the branch to the origin remote
+func Push(ctx context.Context, dir, branch string) error {
+	_, err := git(ctx, dir, "push", "--set-upstream", "origin", branch)
+	return err
+}
@@ -0,0 +1,262 @@
+package pr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/dockerfile"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/helm"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/scanner"
+)
+
+// Change is an image in a file and the Chainguard image it was mapped to. The
+// mapped image is empty if there isn't a Chainguard equivalent.
+type Change struct {
+	Path   string
+	Image  string
+	Mapped string
+}
+
+// Summary describes the images that were found in a repository
+type Summary struct {
+	Changes []Change
+
+	// Files are the paths of the files that were changed, relative to the
+	// repository
+	Files []string
+}
+
+// Mapped returns the images that were mapped
+func (s *Summary) Mapped() []Change {
+	return slices.DeleteFunc(slices.Clone(s.Changes), func(c Change) bool {
+		return c.Mapped == ""
+	})
+}
+
+// Unmapped returns the images that don't have a Chainguard equivalent
+func (s *Summary) Unmapped() []Change {
+	return slices.DeleteFunc(slices.Clone(s.Changes), func(c Change) bool {
+		return c.Mapped != ""
+	})
+}
+
+// Markdown returns a description of the changes for a pull request
+func (s *Summary) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("This pull request replaces the images in this repository with their Chainguard equivalents. It was created by image-mapper.\n")
+
+	if mapped := s.Mapped(); len(mapped) > 0 {
+		fmt.Fprintf(&sb, "\n## Mapped (%d)\n\n", len(mapped))
+		sb.WriteString("| File | Image | Chainguard Image |\n")
+		sb.WriteString("|------|-------|------------------|\n")
+		for _, c := range mapped {
+			fmt.Fprintf(&sb, "| `%s` | `%s` | `%s` |\n", c.Path, c.Image, c.Mapped)
+		}
+	}
+
+	if unmapped := s.Unmapped(); len(unmapped) > 0 {
+		fmt.Fprintf(&sb, "\n## Unmapped (%d)\n\n", len(unmapped))
+		sb.WriteString("These images don't have a Chainguard equivalent, so they haven't been changed.\n\n")
+		sb.WriteString("| File | Image |\n")
+		sb.WriteString("|------|-------|\n")
+		for _, c := range unmapped {
+			fmt.Fprintf(&sb, "| `%s` | `%s` |\n", c.Path, c.Image)
+		}
+	}
+
+	return sb.String()
+}
+
+// Apply maps the images in the Dockerfiles and Kubernetes manifests in the
+// directory, and the files that the scanners read, and writes the changes to
+// the files. Dockerfiles are mapped to -dev tags, like the dockerfile
+// subcommand, and manifests and the scanners' files are mapped like the Helm
+// post-renderer.
+func Apply(ctx context.Context, dir string, scanners []scanner.Scanner, opts ...mapper.Option) (*Summary, error) {
+	dm, err := dockerfile.NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+	km, err := helm.NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing mapper: %w", err)
+	}
+
+	return apply(dm, km, dir, scanners)
+}
+
+// skipDirs are directories that won't contain files we want to change
+var skipDirs = map[string]struct{}{
+	".git":         {},
+	"node_modules": {},
+	"vendor":       {},
+}
+
+// apply maps the images in the Dockerfiles with dm and the images in the
+// manifests, and the scanners' files, with km
+func apply(dm, km mapper.Mapper, dir string, scanners []scanner.Scanner) (*Summary, error) {
+	summary := &Summary{}
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if _, ok := skipDirs[d.Name()]; ok && path != dir {
+				return filepath.SkipDir
+			}
+
+			// The templates in a chart aren't valid manifests
+			// and the images in its values are best changed
+			// with the helm-chart subcommand
+			if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err == nil {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		var (
+			m       mapper.Mapper
+			rewrite func(m mapper.Mapper, input []byte) ([]byte, error)
+
+			// Plenty of YAML files aren't manifests, so only
+			// warn about Dockerfiles that can't be parsed
+			warn bool
+		)
+		s := scanner.Find(scanners, filepath.ToSlash(rel))
+		switch ext := strings.ToLower(filepath.Ext(path)); {
+		case s != nil:
+			m, warn = km, true
+			rewrite = func(m mapper.Mapper, input []byte) ([]byte, error) {
+				return rewriteScanner(s, filepath.ToSlash(rel), m, input)
+			}
+		case dockerfile.IsDockerfile(d.Name()):
+			m, warn = dm, true
+			rewrite = func(m mapper.Mapper, input []byte) ([]byte, error) {
+				return dockerfile.MapWith(m, input, dockerfile.Options{})
+			}
+		case ext == ".yaml" || ext == ".yml":
+			m = km
+			rewrite = rewriteManifests
+		default:
+			return nil
+		}
+
+		input, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading file: %s: %w", rel, err)
+		}
+
+		r := &recorder{Mapper: m}
+		output, err := rewrite(r, input)
+		if err != nil {
+			if warn {
+				slog.Warn("skipping file", "path", rel, "err", err)
+			}
+			return nil
+		}
+		summary.Changes = append(summary.Changes, r.changes(filepath.ToSlash(rel))...)
+
+		if bytes.Equal(output, input) {
+			return nil
+		}
+		if err := os.WriteFile(path, output, 0o644); err != nil {
+			return fmt.Errorf("writing file: %s: %w", rel, err)
+		}
+		summary.Files = append(summary.Files, rel)
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking directory: %s: %w", dir, err)
+	}
+
+	return summary, nil
+}
+
+// rewriteManifests maps the images in Kubernetes manifests, leaving the rest of
+// the file as it is
+func rewriteManifests(m mapper.Mapper, input []byte) ([]byte, error) {
+	return manifest.EditImages(input, func(img manifest.Image) (string, bool) {
+		ref, err := mapper.MapImage(m, img.Image)
+		if err != nil {
+			return "", false
+		}
+		return ref.String(), true
+	})
+}
+
+// rewriteScanner maps the images that the scanner finds in the file and has the
+// scanner replace them
+func rewriteScanner(s scanner.Scanner, path string, m mapper.Mapper, input []byte) ([]byte, error) {
+	images, err := s.Extract(path, input)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped := map[string]string{}
+	for _, img := range images {
+		if _, ok := mapped[img.Image]; ok {
+			continue
+		}
+		ref, err := mapper.MapImage(m, img.Image)
+		if err != nil {
+			continue
+		}
+		mapped[img.Image] = ref.String()
+	}
+	if len(mapped) == 0 {
+		return input, nil
+	}
+
+	return s.Rewrite(path, input, mapped)
+}
+
+// recorder is a mapper that records the mappings it returns, so that we can
+// report on the images in each file
+type recorder struct {
+	mapper.Mapper
+	mappings []*mapper.Mapping
+}
+
+// Map maps the image with the underlying mapper and records the result
+func (r *recorder) Map(img string) (*mapper.Mapping, error) {
+	mapping, err := r.Mapper.Map(img)
+	if err != nil {
+		return nil, err
+	}
+	r.mappings = append(r.mappings, mapping)
+
+	return mapping, nil
+}
+
+// changes returns the unique images that were mapped in the file
+func (r *recorder) changes(path string) []Change {
+	var changes []Change
+	for _, mapping := range r.mappings {
+		change := Change{Path: path, Image: mapping.Image}
+		if len(mapping.Results) > 0 {
+			change.Mapped = mapping.Results[0]
+		}
+		if slices.Contains(changes, change) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
@@ -0,0 +1,195 @@
+package pr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/scanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockMapper struct {
+	mappings map[string]string
+}
+
+func (m *mockMapper) Map(img string) (*mapper.Mapping, error) {
+	mapping := &mapper.Mapping{Image: img}
+	if result, ok := m.mappings[img]; ok {
+		mapping.Results = []string{result}
+	}
+	return mapping, nil
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS("testdata/repo")); err != nil {
+		t.Fatalf("unexpected error copying testdata: %s", err)
+	}
+
+	dm := &mockMapper{mappings: map[string]string{
+		"python:3.13": "cgr.dev/chainguard/python:3.13-dev",
+	}}
+	km := &mockMapper{mappings: map[string]string{
+		"nginx:1.25": "cgr.dev/chainguard/nginx:1.25",
+	}}
+	summary, err := apply(dm, km, dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantChanges := []Change{
+		{Path: "Dockerfile", Image: "python:3.13", Mapped: "cgr.dev/chainguard/python:3.13-dev"},
+		{Path: "Dockerfile", Image: "example/unknown:1.0"},
+		{Path: "deploy/app.yaml", Image: "nginx:1.25", Mapped: "cgr.dev/chainguard/nginx:1.25"},
+	}
+	if diff := cmp.Diff(wantChanges, summary.Changes); diff != "" {
+		t.Errorf("unexpected changes (-want +got):\n%s", diff)
+	}
+	wantFiles := []string{"Dockerfile", filepath.Join("deploy", "app.yaml")}
+	if diff := cmp.Diff(wantFiles, summary.Files); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+
+	for path, want := range map[string]string{
+		"Dockerfile": `# The app
+FROM cgr.dev/chainguard/python:3.13-dev AS build
+RUN pip install .
+
+FROM example/unknown:1.0
+COPY --from=build /app /app
+`,
+		"deploy/app.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+    name: app
+spec:
+    template:
+        spec:
+            containers:
+                - name: app
+                  image: cgr.dev/chainguard/nginx:1.25 # pinned
+                - name: proxy
+                  image: cgr.dev/chainguard/nginx:1.25
+`,
+		// Files that aren't manifests and charts are left alone
+		"deploy/config.yaml": "image: nginx:1.25\n",
+		"chart/templates/pod.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  containers:
+    - name: main
+      image: nginx:1.25
+`,
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatalf("unexpected error reading file: %s", err)
+		}
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("unexpected contents of %s (-want +got):\n%s", path, diff)
+		}
+	}
+}
+
+// paasScanner is a scanner for .paas files, where the images are the values of
+// the image lines
+type paasScanner struct{}
+
+func (paasScanner) Name() string { return "paas" }
+
+func (paasScanner) Detect(path string) bool { return strings.HasSuffix(path, ".paas") }
+
+func (paasScanner) Extract(_ string, input []byte) ([]scanner.Image, error) {
+	var images []scanner.Image
+	for line := range strings.SplitSeq(string(input), "\n") {
+		if img, ok := strings.CutPrefix(line, "image: "); ok {
+			images = append(images, scanner.Image{Image: img})
+		}
+	}
+	return images, nil
+}
+
+func (paasScanner) Rewrite(_ string, input []byte, images map[string]string) ([]byte, error) {
+	lines := strings.Split(string(input), "\n")
+	for i, line := range lines {
+		if img, ok := strings.CutPrefix(line, "image: "); ok {
+			if mapped, ok := images[img]; ok {
+				lines[i] = "image: " + mapped
+			}
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func TestApplyScanners(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "services"), 0o755); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	input := "name: app\nimage: nginx:1.25\nimage: example/unknown:1.0\n"
+	if err := os.WriteFile(filepath.Join(dir, "services", "app.paas"), []byte(input), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	km := &mockMapper{mappings: map[string]string{
+		"nginx:1.25": "cgr.dev/chainguard/nginx:1.25",
+	}}
+	summary, err := apply(&mockMapper{}, km, dir, []scanner.Scanner{paasScanner{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantChanges := []Change{
+		{Path: "services/app.paas", Image: "nginx:1.25", Mapped: "cgr.dev/chainguard/nginx:1.25"},
+		{Path: "services/app.paas", Image: "example/unknown:1.0"},
+	}
+	if diff := cmp.Diff(wantChanges, summary.Changes); diff != "" {
+		t.Errorf("unexpected changes (-want +got):\n%s", diff)
+	}
+	wantFiles := []string{filepath.Join("services", "app.paas")}
+	if diff := cmp.Diff(wantFiles, summary.Files); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "services", "app.paas"))
+	if err != nil {
+		t.Fatalf("unexpected error reading file: %s", err)
+	}
+	want := "name: app\nimage: cgr.dev/chainguard/nginx:1.25\nimage: example/unknown:1.0\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected contents (-want +got):\n%s", diff)
+	}
+}
+
+func TestSummaryMarkdown(t *testing.T) {
+	summary := &Summary{
+		Changes: []Change{
+			{Path: "Dockerfile", Image: "python:3.13", Mapped: "cgr.dev/chainguard/python:3.13-dev"},
+			{Path: "Dockerfile", Image: "example/unknown:1.0"},
+		},
+	}
+
+	want := "This pull request replaces the images in this repository with their Chainguard equivalents. It was created by image-mapper.\n" + `
+## Mapped (1)
+
+| File | Image | Chainguard Image |
+|------|-------|------------------|
+| ` + "`Dockerfile` | `python:3.13` | `cgr.dev/chainguard/python:3.13-dev`" + ` |
+
+## Unmapped (1)
+
+These images don't have a Chainguard equivalent, so they haven't been changed.
+
+| File | Image |
+|------|-------|
+| ` + "`Dockerfile` | `example/unknown:1.0`" + ` |
+`
+	if diff := cmp.Diff(want, summary.Markdown()); diff != "" {
+		t.Errorf("unexpected markdown (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,159 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// MinTotal is the smallest number of items that progress is reported for.
+// Smaller tasks finish quickly enough that the progress would only be noise.
+const MinTotal = 100
+
+const (
+	// drawInterval is how often the progress line is redrawn on a
+	// terminal
+	drawInterval = 200 * time.Millisecond
+
+	// logInterval is how often progress is logged when stderr isn't a
+	// terminal
+	logInterval = 10 * time.Second
+)
+
+// Tracker reports the progress of a long running task on stderr: N of M
+// items done, the time elapsed and the estimated time remaining.
+//
+// When stderr is a terminal, the progress is drawn on a single line that's
+// redrawn as the task progresses. Otherwise, it's logged periodically, so it
+// doesn't flood CI logs.
+//
+// A nil tracker does nothing, so callers don't have to check whether progress
+// is being reported.
+type Tracker struct {
+	mu    sync.Mutex
+	task  string
+	total int
+	done  int
+	start time.Time
+	last  time.Time
+
+	// w is the terminal the progress is drawn on. When it's nil, the
+	// progress is logged instead.
+	w   io.Writer
+	now func() time.Time
+}
+
+// Start returns a tracker for a task with total items. It returns nil when
+// there are fewer than MinTotal items, or when info messages aren't logged,
+// like with --quiet.
+func Start(task string, total int) *Tracker {
+	if total < MinTotal || !slog.Default().Enabled(context.Background(), slog.LevelInfo) {
+		return nil
+	}
+
+	var w io.Writer
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		w = os.Stderr
+	}
+
+	return newTracker(task, total, w, time.Now)
+}
+
+func newTracker(task string, total int, w io.Writer, now func() time.Time) *Tracker {
+	start := now()
+
+	return &Tracker{
+		task:  task,
+		total: total,
+		start: start,
+		last:  start,
+		w:     w,
+		now:   now,
+	}
+}
+
+// Add marks n more items as done
+func (t *Tracker) Add(n int) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done = min(t.done+n, t.total)
+
+	now := t.now()
+	interval := logInterval
+	if t.w != nil {
+		interval = drawInterval
+	}
+	if now.Sub(t.last) < interval && t.done < t.total {
+		return
+	}
+	t.last = now
+
+	t.report(now)
+}
+
+// Done finishes the task. On a terminal, it ends the progress line so that
+// the output that follows starts on a new line.
+func (t *Tracker) Done() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.w == nil {
+		return
+	}
+	if t.done < t.total {
+		t.report(t.now())
+	}
+	fmt.Fprintln(t.w)
+}
+
+func (t *Tracker) report(now time.Time) {
+	elapsed := now.Sub(t.start)
+	eta := estimate(t.done, t.total, elapsed)
+
+	if t.w == nil {
+		slog.Info(t.task, "done", t.done, "total", t.total, "elapsed", elapsed.Round(time.Second).String(), "eta", eta.Round(time.Second).String())
+		return
+	}
+
+	// \033[K clears what's left of the previous line
+	fmt.Fprintf(t.w, "\r%s\033[K", line(t.task, t.done, t.total, elapsed, eta))
+}
+
+// line formats the progress, i.e:
+//
+//	mapping images: 120 of 500 (24%), elapsed 12s, eta 38s
+func line(task string, done, total int, elapsed, eta time.Duration) string {
+	return fmt.Sprintf("%s: %d of %d (%d%%), elapsed %s, eta %s",
+		task,
+		done,
+		total,
+		done*100/total,
+		elapsed.Round(time.Second),
+		eta.Round(time.Second),
+	)
+}
+
+// estimate returns the time remaining, assuming the rest of the items take
+// as long as the ones that are done on average
+func estimate(done, total int, elapsed time.Duration) time.Duration {
+	if done == 0 {
+		return 0
+	}
+
+	return elapsed / time.Duration(done) * time.Duration(total-done)
+}
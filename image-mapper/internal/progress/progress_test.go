@@ -0,0 +1,134 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLine(t *testing.T) {
+	testCases := []struct {
+		name    string
+		done    int
+		total   int
+		elapsed time.Duration
+		eta     time.Duration
+		want    string
+	}{
+		{
+			name:    "started",
+			done:    1,
+			total:   500,
+			elapsed: 100 * time.Millisecond,
+			eta:     49900 * time.Millisecond,
+			want:    "mapping images: 1 of 500 (0%), elapsed 0s, eta 50s",
+		},
+		{
+			name:    "part way",
+			done:    120,
+			total:   500,
+			elapsed: 12 * time.Second,
+			eta:     38 * time.Second,
+			want:    "mapping images: 120 of 500 (24%), elapsed 12s, eta 38s",
+		},
+		{
+			name:    "finished",
+			done:    500,
+			total:   500,
+			elapsed: 2 * time.Minute,
+			want:    "mapping images: 500 of 500 (100%), elapsed 2m0s, eta 0s",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := line("mapping images", tc.done, tc.total, tc.elapsed, tc.eta)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected line (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		done    int
+		total   int
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{
+			name:    "nothing done",
+			total:   100,
+			elapsed: time.Second,
+			want:    0,
+		},
+		{
+			name:    "quarter done",
+			done:    25,
+			total:   100,
+			elapsed: 10 * time.Second,
+			want:    30 * time.Second,
+		},
+		{
+			name:    "all done",
+			done:    100,
+			total:   100,
+			elapsed: 10 * time.Second,
+			want:    0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := estimate(tc.done, tc.total, tc.elapsed)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected estimate (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTrackerDraws(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	var buf bytes.Buffer
+	tr := newTracker("mapping images", 4, &buf, clock)
+
+	// Updates within the draw interval are skipped
+	now = now.Add(time.Second)
+	tr.Add(1)
+	now = now.Add(time.Millisecond)
+	tr.Add(1)
+	now = now.Add(time.Second)
+	tr.Add(1)
+
+	// The last item is always drawn
+	tr.Add(1)
+	tr.Done()
+
+	want := []string{
+		"",
+		"mapping images: 1 of 4 (25%), elapsed 1s, eta 3s\033[K",
+		"mapping images: 3 of 4 (75%), elapsed 2s, eta 1s\033[K",
+		"mapping images: 4 of 4 (100%), elapsed 2s, eta 0s\033[K\n",
+	}
+	if diff := cmp.Diff(want, strings.Split(buf.String(), "\r")); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestStartSkipsSmallTasks(t *testing.T) {
+	if tr := Start("mapping images", MinTotal-1); tr != nil {
+		t.Errorf("expected no tracker for a small task")
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tr *Tracker
+	tr.Add(1)
+	tr.Done()
+}
@@ -0,0 +1,112 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultQuery returns a series for every container in the cluster, with
+// the container's image in the image label. The metrics are exported by
+// kube-state-metrics.
+const DefaultQuery = "kube_pod_container_info or kube_pod_init_container_info"
+
+// Options configures how to query Prometheus
+type Options struct {
+	// URL is the address of the Prometheus server, i.e
+	// http://prometheus.monitoring:9090
+	URL string
+
+	// Query is the PromQL query that returns the containers. If it's
+	// empty, DefaultQuery is used.
+	Query string
+
+	// Label is the label of the series that holds the image. If it's
+	// empty, the image label is used.
+	Label string
+
+	// BearerToken is sent in the Authorization header, if it's set
+	BearerToken string
+}
+
+// response is the subset of a response from the Prometheus query API that we
+// need
+type response struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// ListImages returns the images in the series returned by the query. An image
+// is returned once for each series that has it, which is once per container
+// with the default query.
+func ListImages(ctx context.Context, opts Options) ([]string, error) {
+	return listImages(ctx, http.DefaultClient, opts)
+}
+
+func listImages(ctx context.Context, client *http.Client, opts Options) ([]string, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("a Prometheus URL is required")
+	}
+	query := opts.Query
+	if query == "" {
+		query = DefaultQuery
+	}
+	label := opts.Label
+	if label == "" {
+		label = "image"
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(opts.URL, "/") + "/api/v1/query")
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %w", err)
+	}
+	u.RawQuery = url.Values{"query": []string{query}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", "image-mapper")
+	if opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Errors in the query are returned as JSON with a 4xx status code, so
+	// try to decode the body before checking the status code
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decoding response: unexpected status code: %d: %w", resp.StatusCode, err)
+	}
+	if r.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", r.Error)
+	}
+	if r.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("unsupported result type: %s: the query must return an instant vector", r.Data.ResultType)
+	}
+
+	var images []string
+	for _, result := range r.Data.Result {
+		image := result.Metric[label]
+		if image == "" {
+			continue
+		}
+		images = append(images, image)
+	}
+
+	return images, nil
+}
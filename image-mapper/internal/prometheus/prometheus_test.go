@@ -0,0 +1,109 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListImages(t *testing.T) {
+	var gotQuery, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prometheus/api/v1/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotQuery = r.URL.Query().Get("query")
+		gotAuth = r.Header.Get("Authorization")
+
+		_, _ = w.Write([]byte(`{
+  "status": "success",
+  "data": {
+    "resultType": "vector",
+    "result": [
+      {"metric": {"__name__": "kube_pod_container_info", "container": "nginx", "image": "nginx:1.25", "image_spec": "docker.io/library/nginx:1.25"}, "value": [1700000000, "1"]},
+      {"metric": {"__name__": "kube_pod_container_info", "container": "nginx", "image": "nginx:1.25", "image_spec": "docker.io/library/nginx:1.25"}, "value": [1700000000, "1"]},
+      {"metric": {"__name__": "kube_pod_init_container_info", "container": "init", "image_spec": "busybox:1.36"}, "value": [1700000000, "1"]}
+    ]
+  }
+}`))
+	}))
+	defer srv.Close()
+
+	testCases := map[string]struct {
+		opts      Options
+		wantQuery string
+		wantAuth  string
+		want      []string
+	}{
+		"defaults": {
+			opts:      Options{URL: srv.URL + "/prometheus/"},
+			wantQuery: DefaultQuery,
+			want:      []string{"nginx:1.25", "nginx:1.25"},
+		},
+		"query and label": {
+			opts: Options{
+				URL:         srv.URL + "/prometheus",
+				Query:       `kube_pod_container_info{namespace="default"}`,
+				Label:       "image_spec",
+				BearerToken: "token",
+			},
+			wantQuery: `kube_pod_container_info{namespace="default"}`,
+			wantAuth:  "Bearer token",
+			want:      []string{"docker.io/library/nginx:1.25", "docker.io/library/nginx:1.25", "busybox:1.36"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := listImages(context.Background(), srv.Client(), tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected images (-want +got):\n%s", diff)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Errorf("unexpected query: %s, wanted %s", gotQuery, tc.wantQuery)
+			}
+			if gotAuth != tc.wantAuth {
+				t.Errorf("unexpected authorization header: %s, wanted %s", gotAuth, tc.wantAuth)
+			}
+		})
+	}
+}
+
+func TestListImagesError(t *testing.T) {
+	testCases := map[string]struct {
+		status int
+		body   string
+	}{
+		"query error": {
+			status: http.StatusBadRequest,
+			body:   `{"status": "error", "errorType": "bad_data", "error": "parse error"}`,
+		},
+		"matrix": {
+			status: http.StatusOK,
+			body:   `{"status": "success", "data": {"resultType": "matrix", "result": []}}`,
+		},
+		"not json": {
+			status: http.StatusBadGateway,
+			body:   `<html>Bad Gateway</html>`,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			if _, err := listImages(context.Background(), srv.Client(), Options{URL: srv.URL}); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
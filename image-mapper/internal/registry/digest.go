@@ -0,0 +1,227 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// defaultMaxTags is the most tags of a repository that are resolved when
+// looking for a digest
+const defaultMaxTags = 500
+
+// DigestOptions configures how digests are resolved to tags
+type DigestOptions struct {
+	// MaxTags is the most tags of a repository that are resolved when
+	// looking for a digest. Defaults to 500.
+	MaxTags int
+
+	// PlainHTTP accesses the registries over HTTP, rather than HTTPS
+	PlainHTTP bool
+
+	// InsecureRegistries are the registries, including the port if
+	// there is one, i.e registry.local:5000, that are accessed over HTTP,
+	// rather than HTTPS
+	InsecureRegistries []string
+
+	// Keychains are the cloud keychains, ecr, google or azure, that are
+	// used for the registries that there are no credentials for in the
+	// Docker config
+	Keychains []string
+
+	// Identity is the Chainguard identity that pulls from cgr.dev when
+	// there are no credentials for it in the Docker config
+	Identity *cgauth.Client
+}
+
+// DigestResolver finds the tags of images that are pinned to a digest, like
+// nginx@sha256:..., by resolving the tags of the repository and matching their
+// digests. The tags of each repository are only resolved once.
+type DigestResolver struct {
+	ctx    context.Context
+	client remote.Client
+	opts   DigestOptions
+
+	group singleflight.Group
+
+	mu    sync.Mutex
+	repos map[string]map[string][]string
+}
+
+// NewDigestResolver returns a resolver that reads the tags with the
+// credentials in the Docker config and the keychains
+func NewDigestResolver(ctx context.Context, opts DigestOptions) (*DigestResolver, error) {
+	client, err := newClient(opts.Keychains, opts.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDigestResolver(ctx, client, opts), nil
+}
+
+func newDigestResolver(ctx context.Context, client remote.Client, opts DigestOptions) *DigestResolver {
+	if opts.MaxTags <= 0 {
+		opts.MaxTags = defaultMaxTags
+	}
+
+	return &DigestResolver{
+		ctx:    ctx,
+		client: client,
+		opts:   opts,
+		repos:  map[string]map[string][]string{},
+	}
+}
+
+// ResolveDigest returns the tag of the image's repository that points at the
+// image's digest. When several tags do, the most specific version is
+// returned, i.e 1.25.3 rather than 1.25 or latest.
+func (r *DigestResolver) ResolveDigest(image string) (string, error) {
+	ref, err := name.NewDigest(image)
+	if err != nil {
+		return "", fmt.Errorf("parsing image: %w", err)
+	}
+
+	digests, err := r.digests(ref.Context())
+	if err != nil {
+		return "", err
+	}
+
+	tags := digests[ref.DigestStr()]
+	if len(tags) == 0 {
+		return "", fmt.Errorf("no tag of %s has the digest", ref.Context())
+	}
+
+	return mostSpecificTag(tags), nil
+}
+
+// digests returns the tags of the repository, keyed by their digests
+func (r *DigestResolver) digests(repository name.Repository) (map[string][]string, error) {
+	key := repository.String()
+
+	r.mu.Lock()
+	digests, ok := r.repos[key]
+	r.mu.Unlock()
+	if ok {
+		return digests, nil
+	}
+
+	v, err, _ := r.group.Do(key, func() (any, error) {
+		digests, err := r.resolveTags(repository)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.repos[key] = digests
+		r.mu.Unlock()
+
+		return digests, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string][]string), nil
+}
+
+// resolveTags lists the tags of the repository and resolves each of them to
+// a digest
+func (r *DigestResolver) resolveTags(repository name.Repository) (map[string][]string, error) {
+	// Docker Hub is served from a different host than the one in the
+	// image references
+	registry := repository.RegistryStr()
+	if registry == name.DefaultRegistry {
+		registry = "registry-1.docker.io"
+	}
+	repo, err := remote.NewRepository(registry + "/" + repository.RepositoryStr())
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository: %w", err)
+	}
+	repo.Client = r.client
+	repo.PlainHTTP = plainHTTP(registry, r.opts.PlainHTTP, r.opts.InsecureRegistries)
+
+	var tags []string
+	if err := repo.Tags(r.ctx, "", func(page []string) error {
+		for _, tag := range page {
+			if isCosignTag(tag) {
+				continue
+			}
+			tags = append(tags, tag)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+	if len(tags) > r.opts.MaxTags {
+		slog.Warn("the repository has too many tags to resolve them all, so the digest may not be found", "repository", repository.String(), "tags", len(tags), "resolved", r.opts.MaxTags)
+		tags = tags[len(tags)-r.opts.MaxTags:]
+	}
+
+	resolved := make([]string, len(tags))
+	g, ctx := errgroup.WithContext(r.ctx)
+	g.SetLimit(8)
+	for i, tag := range tags {
+		g.Go(func() error {
+			desc, err := repo.Resolve(ctx, tag)
+			if err != nil {
+				slog.Debug("resolving tag", "repository", repository.String(), "tag", tag, "err", err)
+				return nil
+			}
+			resolved[i] = desc.Digest.String()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	digests := map[string][]string{}
+	for i, tag := range tags {
+		if resolved[i] == "" {
+			continue
+		}
+		digests[resolved[i]] = append(digests[resolved[i]], tag)
+	}
+
+	return digests, nil
+}
+
+// mostSpecificTag returns the tag with the most numeric version parts, i.e
+// 1.25.3 rather than 1.25, 1 or latest. Ties go to the shortest tag, which has
+// the fewest suffixes, and then to the first alphabetically.
+func mostSpecificTag(tags []string) string {
+	return slices.MinFunc(tags, func(a, b string) int {
+		if d := versionParts(b) - versionParts(a); d != 0 {
+			return d
+		}
+		if d := len(a) - len(b); d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// versionParts returns the number of leading dot separated parts of the tag
+// that are numeric, ignoring a v prefix, i.e 3 for v1.25.3-alpine
+func versionParts(tag string) int {
+	tag, _, _ = strings.Cut(strings.TrimPrefix(tag, "v"), "-")
+
+	n := 0
+	for _, part := range strings.Split(tag, ".") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			break
+		}
+		n++
+	}
+
+	return n
+}
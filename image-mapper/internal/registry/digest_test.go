@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestResolveDigest(t *testing.T) {
+	current := imageManifest(t, 100)
+	old := imageManifest(t, 200)
+	host := newManifestRegistry(t, map[string]map[string]testManifest{
+		"library/nginx": {
+			"latest":          current,
+			"1":               current,
+			"1.25":            current,
+			"1.25.3":          current,
+			"1.25.3-bookworm": current,
+			"1.24.0":          old,
+			"sha256-abc.sig":  old,
+		},
+	})
+	untagged := digest.FromString("untagged").String()
+
+	testCases := map[string]struct {
+		image   string
+		want    string
+		wantErr bool
+	}{
+		"most specific": {
+			image: host + "/library/nginx@" + digest.FromBytes(current.body).String(),
+			want:  "1.25.3",
+		},
+		"single tag": {
+			image: host + "/library/nginx@" + digest.FromBytes(old.body).String(),
+			want:  "1.24.0",
+		},
+		"untagged": {
+			image:   host + "/library/nginx@" + untagged,
+			wantErr: true,
+		},
+		"missing repository": {
+			image:   host + "/library/missing@" + untagged,
+			wantErr: true,
+		},
+	}
+
+	r := newDigestResolver(context.Background(), http.DefaultClient, DigestOptions{PlainHTTP: true})
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := r.ResolveDigest(tc.image)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMostSpecificTag(t *testing.T) {
+	testCases := []struct {
+		tags []string
+		want string
+	}{
+		{tags: []string{"latest", "1", "1.25", "1.25.3"}, want: "1.25.3"},
+		{tags: []string{"1.25.3-bookworm", "1.25.3", "mainline"}, want: "1.25.3"},
+		{tags: []string{"v2.1", "stable", "2"}, want: "v2.1"},
+		{tags: []string{"stable", "latest"}, want: "latest"},
+	}
+
+	for _, tc := range testCases {
+		if got := mostSpecificTag(tc.tags); got != tc.want {
+			t.Errorf("mostSpecificTag(%v): expected %s, got %s", tc.tags, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	ecr "github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// Keychains are the names of the cloud keychains that can be used for the
+// registries of their cloud, in addition to the Docker config
+var Keychains = []string{"ecr", "google", "azure"}
+
+// newCredential returns the credentials in the Docker config for a registry
+// and, when there aren't any, a token for the Chainguard identity, for
+// cgr.dev, or the credentials from the first of the named keychains that has
+// some
+func newCredential(store credentials.Store, keychains []string, identity *cgauth.Client) (auth.CredentialFunc, error) {
+	funcs := []auth.CredentialFunc{credentials.Credential(store)}
+	if identity != nil {
+		// The identity's keychain is anonymous for the other
+		// registries, so it only exchanges tokens for cgr.dev
+		funcs = append(funcs, keychainCredential(identity.Keychain()))
+	}
+	for _, k := range keychains {
+		switch k {
+		case "ecr":
+			// The ECR helper reads the AWS credentials from the
+			// environment, like the ECR copier does
+			funcs = append(funcs, keychainCredential(authn.NewKeychainFromHelper(ecr.NewECRHelper(ecr.WithLogger(io.Discard)))))
+		case "google":
+			// The Google keychain reads the application default
+			// credentials, or gcloud's, like the GCP copier does
+			funcs = append(funcs, keychainCredential(google.Keychain))
+		case "azure":
+			// The ACR credential helper, docker-credential-acr-env, reads
+			// the Azure credentials from the environment. It's run
+			// rather than linked, to keep the Azure SDK out of the
+			// binary.
+			funcs = append(funcs, hostCredential(".azurecr.io", credentials.Credential(credentials.NewNativeStore("acr-env"))))
+		default:
+			return nil, fmt.Errorf("unknown keychain %q, must be one of %s", k, strings.Join(Keychains, ", "))
+		}
+	}
+
+	return firstCredential(funcs...), nil
+}
+
+// firstCredential returns the first credentials that the funcs return for a
+// registry
+func firstCredential(funcs ...auth.CredentialFunc) auth.CredentialFunc {
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		for i, f := range funcs {
+			cred, err := f(ctx, hostport)
+			if err != nil {
+				// The first is the Docker config, which is always
+				// used, so its errors are returned. A keychain that
+				// fails is skipped, like one that has no credentials.
+				if i == 0 {
+					return auth.EmptyCredential, err
+				}
+				slog.Debug("getting keychain credentials", "registry", hostport, "err", err)
+				continue
+			}
+			if cred != auth.EmptyCredential {
+				return cred, nil
+			}
+		}
+
+		return auth.EmptyCredential, nil
+	}
+}
+
+// keychainCredential adapts a go-containerregistry keychain, which the copy
+// tools use, to the credentials of an oras client
+func keychainCredential(keychain authn.Keychain) auth.CredentialFunc {
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		reg, err := name.NewRegistry(hostport)
+		if err != nil {
+			return auth.EmptyCredential, fmt.Errorf("parsing registry: %w", err)
+		}
+		authenticator, err := authn.Resolve(ctx, keychain, reg)
+		if err != nil {
+			return auth.EmptyCredential, err
+		}
+		if authenticator == authn.Anonymous {
+			return auth.EmptyCredential, nil
+		}
+		cfg, err := authn.Authorization(ctx, authenticator)
+		if err != nil {
+			return auth.EmptyCredential, err
+		}
+
+		return auth.Credential{
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			RefreshToken: cfg.IdentityToken,
+			AccessToken:  cfg.RegistryToken,
+		}, nil
+	}
+}
+
+// hostCredential only gets credentials for the registries with the suffix, so
+// that a credential helper isn't run for every registry
+func hostCredential(suffix string, f auth.CredentialFunc) auth.CredentialFunc {
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		host, _, _ := strings.Cut(hostport, ":")
+		if !strings.HasSuffix(host, suffix) {
+			return auth.EmptyCredential, nil
+		}
+
+		return f(ctx, hostport)
+	}
+}
@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// fakeKeychain has credentials for the registries in it
+type fakeKeychain map[string]authn.AuthConfig
+
+func (k fakeKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	cfg, ok := k[r.RegistryStr()]
+	if !ok {
+		return authn.Anonymous, nil
+	}
+
+	return authn.FromConfig(cfg), nil
+}
+
+func TestFirstCredential(t *testing.T) {
+	ctx := context.Background()
+
+	store := credentials.NewMemoryStore()
+	if err := store.Put(ctx, "docker.example.com", auth.Credential{Username: "docker", Password: "config"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	keychain := fakeKeychain{
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com": {Username: "AWS", Password: "ecr"},
+		"docker.example.com":                           {Username: "keychain", Password: "ignored"},
+		"token.example.com":                            {IdentityToken: "refresh", RegistryToken: "access"},
+	}
+
+	cred := firstCredential(credentials.Credential(store), keychainCredential(keychain))
+
+	testCases := map[string]struct {
+		hostport string
+		want     auth.Credential
+	}{
+		"docker config first": {
+			hostport: "docker.example.com",
+			want:     auth.Credential{Username: "docker", Password: "config"},
+		},
+		"keychain": {
+			hostport: "123456789012.dkr.ecr.us-east-1.amazonaws.com",
+			want:     auth.Credential{Username: "AWS", Password: "ecr"},
+		},
+		"tokens": {
+			hostport: "token.example.com",
+			want:     auth.Credential{RefreshToken: "refresh", AccessToken: "access"},
+		},
+		"anonymous": {
+			hostport: "registry.example.com",
+			want:     auth.EmptyCredential,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := cred(ctx, tc.hostport)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected credential (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHostCredential(t *testing.T) {
+	f := hostCredential(".azurecr.io", func(context.Context, string) (auth.Credential, error) {
+		return auth.Credential{Username: "acr"}, nil
+	})
+
+	testCases := map[string]struct {
+		hostport string
+		want     auth.Credential
+	}{
+		"matching": {
+			hostport: "example.azurecr.io",
+			want:     auth.Credential{Username: "acr"},
+		},
+		"matching with port": {
+			hostport: "example.azurecr.io:443",
+			want:     auth.Credential{Username: "acr"},
+		},
+		"other registry": {
+			hostport: "azurecr.io.example.com",
+			want:     auth.EmptyCredential,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := f(context.Background(), tc.hostport)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected credential (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewCredentialUnknownKeychain(t *testing.T) {
+	if _, err := newCredential(credentials.NewMemoryStore(), []string{"ecr", "oci"}, nil); err == nil {
+		t.Fatalf("expected an error for an unknown keychain")
+	}
+}
@@ -0,0 +1,194 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/sync/singleflight"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// DefaultOriginRegistries are the registries that the origins of images are
+// looked for in
+var DefaultOriginRegistries = []string{"docker.io", "ghcr.io"}
+
+// OriginOptions configures how the origins of images are found
+type OriginOptions struct {
+	// Registries are the registries that the origins are looked for in,
+	// in order. Defaults to DefaultOriginRegistries.
+	Registries []string
+
+	// PlainHTTP accesses the registries over HTTP, rather than HTTPS
+	PlainHTTP bool
+
+	// InsecureRegistries are the registries, including the port if
+	// there is one, i.e registry.local:5000, that are accessed over HTTP,
+	// rather than HTTPS
+	InsecureRegistries []string
+
+	// Keychains are the cloud keychains, ecr, google or azure, that are
+	// used for the registries that there are no credentials for in the
+	// Docker config
+	Keychains []string
+
+	// Identity is the Chainguard identity that pulls from cgr.dev when
+	// there are no credentials for it in the Docker config
+	Identity *cgauth.Client
+}
+
+// OriginResolver finds the repository that an image pinned to a digest was
+// originally pulled from, when it was pulled through a proxy or pull-through
+// cache, like artifactory.internal/docker-remote/bitnami/nginx@sha256:...
+//
+// The registries can't be searched by digest, so the repository is guessed
+// from the end of the image's path, i.e bitnami/nginx and then nginx, and the
+// guess is checked by looking for the digest in the repository. The manifest
+// is only checked for, with a HEAD request, which doesn't count towards
+// Docker Hub's pull rate limit.
+type OriginResolver struct {
+	ctx    context.Context
+	client remote.Client
+	opts   OriginOptions
+
+	group singleflight.Group
+
+	mu      sync.Mutex
+	origins map[string]string
+}
+
+// NewOriginResolver returns a resolver that looks for the digests with the
+// credentials in the Docker config and the keychains
+func NewOriginResolver(ctx context.Context, opts OriginOptions) (*OriginResolver, error) {
+	client, err := newClient(opts.Keychains, opts.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOriginResolver(ctx, client, opts), nil
+}
+
+func newOriginResolver(ctx context.Context, client remote.Client, opts OriginOptions) *OriginResolver {
+	if len(opts.Registries) == 0 {
+		opts.Registries = DefaultOriginRegistries
+	}
+
+	return &OriginResolver{
+		ctx:     ctx,
+		client:  client,
+		opts:    opts,
+		origins: map[string]string{},
+	}
+}
+
+// ResolveOrigin returns the first repository, in the order of the registries
+// and then from the longest guess to the shortest, that has the image's
+// digest, i.e docker.io/bitnami/nginx@sha256:...
+func (r *OriginResolver) ResolveOrigin(image string) (string, error) {
+	ref, err := name.NewDigest(image)
+	if err != nil {
+		return "", fmt.Errorf("parsing image: %w", err)
+	}
+	key := ref.String()
+
+	r.mu.Lock()
+	origin, ok := r.origins[key]
+	r.mu.Unlock()
+	if ok {
+		return origin, nil
+	}
+
+	v, err, _ := r.group.Do(key, func() (any, error) {
+		origin, err := r.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.origins[key] = origin
+		r.mu.Unlock()
+
+		return origin, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+func (r *OriginResolver) resolve(ref name.Digest) (string, error) {
+	for _, registry := range r.opts.Registries {
+		// docker.io is index.docker.io once it's parsed
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			return "", fmt.Errorf("parsing registry: %w", err)
+		}
+		if reg.RegistryStr() == ref.Context().RegistryStr() {
+			continue
+		}
+		for _, path := range originCandidates(reg.RegistryStr(), ref.Context().RepositoryStr()) {
+			ok, err := r.hasDigest(reg.RegistryStr(), path, ref.DigestStr())
+			if err != nil {
+				slog.Debug("looking for origin", "image", ref.String(), "repository", registry+"/"+path, "err", err)
+				continue
+			}
+			if ok {
+				return registry + "/" + path + "@" + ref.DigestStr(), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no repository in %s has the digest", strings.Join(r.opts.Registries, ", "))
+}
+
+// hasDigest returns true if the repository has a manifest with the digest
+func (r *OriginResolver) hasDigest(registry, path, digest string) (bool, error) {
+	host := registry
+	if host == name.DefaultRegistry {
+		host = "registry-1.docker.io"
+	}
+	repo, err := remote.NewRepository(host + "/" + path)
+	if err != nil {
+		return false, fmt.Errorf("parsing repository: %w", err)
+	}
+	repo.Client = r.client
+	repo.PlainHTTP = plainHTTP(registry, r.opts.PlainHTTP, r.opts.InsecureRegistries)
+
+	// Registries answer with an error, rather than a 404, for
+	// repositories that don't exist or can't be read, so any error is
+	// treated as the digest not being there
+	if _, err := repo.Resolve(r.ctx, digest); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// originCandidates returns the repositories that an image with the path may
+// have been pulled from, longest first, i.e docker-remote/bitnami/nginx,
+// bitnami/nginx and nginx. Docker Hub repositories have at most two parts, and
+// the official images are in library.
+func originCandidates(registry, path string) []string {
+	parts := strings.Split(path, "/")
+
+	var candidates []string
+	for i := range parts {
+		candidate := parts[i:]
+		if registry == name.DefaultRegistry {
+			if len(candidate) > 2 {
+				continue
+			}
+			if len(candidate) == 1 {
+				candidate = []string{"library", candidate[0]}
+			}
+		}
+		candidates = append(candidates, strings.Join(candidate, "/"))
+	}
+
+	return candidates
+}
@@ -0,0 +1,126 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestOriginCandidates(t *testing.T) {
+	testCases := map[string]struct {
+		registry string
+		path     string
+		want     []string
+	}{
+		"docker hub": {
+			registry: "index.docker.io",
+			path:     "docker-remote/bitnami/nginx",
+			want:     []string{"bitnami/nginx", "library/nginx"},
+		},
+		"docker hub official image": {
+			registry: "index.docker.io",
+			path:     "nginx",
+			want:     []string{"library/nginx"},
+		},
+		"other registry": {
+			registry: "ghcr.io",
+			path:     "ghcr-remote/fluxcd/flux-cli",
+			want:     []string{"ghcr-remote/fluxcd/flux-cli", "fluxcd/flux-cli", "flux-cli"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := originCandidates(tc.registry, tc.path)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected candidates (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolveOrigin(t *testing.T) {
+	dgst := digest.FromString("nginx").String()
+
+	// The registry only has the digest in bitnami/nginx
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path != "/v2/bitnami/nginx/manifests/"+dgst {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Header().Set("Docker-Content-Digest", dgst)
+		w.Header().Set("Content-Length", "2")
+	}))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	testCases := map[string]struct {
+		image   string
+		want    string
+		wantErr bool
+	}{
+		"proxied": {
+			image: "proxy.local/docker-remote/bitnami/nginx@" + dgst,
+			want:  host + "/bitnami/nginx@" + dgst,
+		},
+		"not found": {
+			image:   "proxy.local/docker-remote/bitnami/redis@" + dgst,
+			wantErr: true,
+		},
+		"same registry": {
+			image:   host + "/bitnami/nginx@" + dgst,
+			wantErr: true,
+		},
+		"tag": {
+			image:   "proxy.local/docker-remote/bitnami/nginx:latest",
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := newOriginResolver(context.Background(), http.DefaultClient, OriginOptions{
+				Registries: []string{host},
+				PlainHTTP:  true,
+			})
+			got, err := r.ResolveOrigin(tc.image)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	// Origins are only looked for once
+	r := newOriginResolver(context.Background(), http.DefaultClient, OriginOptions{
+		Registries: []string{host},
+		PlainHTTP:  true,
+	})
+	requests = nil
+	for range 2 {
+		if _, err := r.ResolveOrigin("proxy.local/docker-remote/bitnami/nginx@" + dgst); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	want := []string{
+		"HEAD /v2/docker-remote/bitnami/nginx/manifests/" + dgst,
+		"HEAD /v2/bitnami/nginx/manifests/" + dgst,
+	}
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("unexpected requests (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,254 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+var (
+	tokenCachesMu sync.Mutex
+	tokenCaches   = map[string]auth.Cache{}
+)
+
+// tokenCache returns the cache of registry tokens for the clients with the
+// keychains and, or without, a Chainguard identity. The clients share it, so
+// that a token, like the anonymous token Docker Hub hands out for each
+// repository, is fetched once per run rather than once for each of the sizer,
+// the digest resolver and so on.
+func tokenCache(keychains []string, identity bool) auth.Cache {
+	key := strings.Join(keychains, ",")
+	if identity {
+		key += "+identity"
+	}
+
+	tokenCachesMu.Lock()
+	defer tokenCachesMu.Unlock()
+
+	cache, ok := tokenCaches[key]
+	if !ok {
+		cache = auth.NewCache()
+		tokenCaches[key] = cache
+	}
+
+	return cache
+}
+
+// rateLimitedClient is the HTTP client of the registry clients. It's shared,
+// so that a registry that's rate limiting one of them is waited for by all of
+// them.
+var rateLimitedClient = &http.Client{
+	Transport: &retry.Transport{
+		Base:   newRateLimitTransport(nil),
+		Policy: func() retry.Policy { return retryPolicy },
+	},
+}
+
+// retryPolicy is the default retry policy, except that it doesn't retry
+// responses that are rate limited, because the rateLimitTransport already
+// has, for longer than the default policy would wait
+var retryPolicy = &retry.GenericPolicy{
+	Retryable: func(resp *http.Response, err error) (bool, error) {
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			return false, nil
+		}
+		return retry.DefaultPredicate(resp, err)
+	},
+	Backoff:  retry.DefaultBackoff,
+	MinWait:  200 * time.Millisecond,
+	MaxWait:  3 * time.Second,
+	MaxRetry: 5,
+}
+
+const (
+	// rateLimitMinWait is how long a registry that's rate limiting the
+	// requests is waited for the first time, when it doesn't say how
+	// long to wait. It doubles with each retry, up to rateLimitMaxWait.
+	rateLimitMinWait = 5 * time.Second
+
+	// rateLimitMaxWait is the longest a registry is waited for. Docker
+	// Hub's anonymous limit is 100 pulls in 6 hours, so a pull is
+	// allowed again every few minutes.
+	rateLimitMaxWait = 5 * time.Minute
+
+	// rateLimitMaxRetries is how many times a request that's rate limited
+	// is retried before the response is returned
+	rateLimitMaxRetries = 8
+)
+
+// rateLimitTransport makes the requests to registries in a way that keeps
+// large runs going when a registry, like Docker Hub, rate limits them:
+//
+//   - Identical GET and HEAD requests that are made at the same time, like
+//     the sizer and the digest resolver looking up the same manifest, are
+//     coalesced into one request.
+//   - When a registry responds with 429 Too Many Requests, every request to
+//     it waits for as long as its Retry-After header says, or an exponential
+//     backoff when it doesn't say, and then the request is retried.
+type rateLimitTransport struct {
+	// base makes the requests. When it's nil, http.DefaultTransport is
+	// used at the time of the request.
+	base http.RoundTripper
+
+	minWait    time.Duration
+	maxWait    time.Duration
+	maxRetries int
+
+	group singleflight.Group
+
+	mu sync.Mutex
+	// until is when each host, that's rate limiting the requests, can be
+	// sent requests again
+	until map[string]time.Time
+}
+
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:       base,
+		minWait:    rateLimitMinWait,
+		maxWait:    rateLimitMaxWait,
+		maxRetries: rateLimitMaxRetries,
+		until:      map[string]time.Time{},
+	}
+}
+
+// RoundTrip makes the request, waiting for the host and retrying the request
+// while it's rate limited
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.roundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			req.Body = body
+		}
+
+		wait := t.backoff(attempt, resp)
+		t.pause(req.URL.Host, wait)
+		slog.Warn("rate limited by the registry, waiting before retrying", "host", req.URL.Host, "wait", wait, "attempt", attempt+1, "remaining", resp.Header.Get("RateLimit-Remaining"))
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// wait waits until the host can be sent requests again
+func (t *rateLimitTransport) wait(ctx context.Context, host string) error {
+	t.mu.Lock()
+	until := t.until[host]
+	t.mu.Unlock()
+
+	d := time.Until(until)
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pause stops requests being sent to the host for the duration, unless
+// they've already been stopped for longer
+func (t *rateLimitTransport) pause(host string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(t.until[host]) {
+		t.until[host] = until
+	}
+}
+
+// backoff returns how long to wait before retrying the request, which is the
+// Retry-After of the response, or an exponential backoff, up to the maximum
+func (t *rateLimitTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	d := t.minWait << attempt
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			d = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(v); err == nil {
+			d = time.Until(date)
+		}
+	}
+	if d <= 0 || d > t.maxWait {
+		d = t.maxWait
+	}
+
+	return d
+}
+
+// coalescedResponse is a response that's shared by the requests that were
+// coalesced
+type coalescedResponse struct {
+	resp *http.Response
+	body []byte
+}
+
+// roundTrip makes the request, or, for a GET or HEAD request without a body,
+// waits for an identical request that's already being made and returns a
+// copy of its response
+func (t *rateLimitTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || (req.Body != nil && req.Body != http.NoBody) {
+		return base.RoundTrip(req)
+	}
+
+	// Requests with different credentials may get different responses
+	key := fmt.Sprintf("%s %s\n%s", req.Method, req.URL, req.Header.Get("Authorization"))
+	v, err, _ := t.group.Do(key, func() (any, error) {
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading response: %w", err)
+		}
+
+		return &coalescedResponse{resp: resp, body: body}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	shared := v.(*coalescedResponse)
+
+	resp := *shared.resp
+	resp.Header = shared.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(shared.body))
+	resp.Request = req
+
+	return &resp, nil
+}
@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransportRetries(t *testing.T) {
+	testCases := map[string]struct {
+		limited      int32
+		wantStatus   int
+		wantRequests int32
+	}{
+		"not limited": {
+			wantStatus:   http.StatusOK,
+			wantRequests: 1,
+		},
+		"limited then allowed": {
+			limited:      2,
+			wantStatus:   http.StatusOK,
+			wantRequests: 3,
+		},
+		"limited for longer than the retries": {
+			limited:      10,
+			wantStatus:   http.StatusTooManyRequests,
+			wantRequests: 4,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tc.limited {
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			}))
+			t.Cleanup(srv.Close)
+
+			transport := newRateLimitTransport(nil)
+			transport.minWait = time.Millisecond
+			transport.maxRetries = 3
+
+			resp, err := (&http.Client{Transport: transport}).Get(srv.URL + "/v2/")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, resp.StatusCode)
+			}
+			if got := requests.Load(); got != tc.wantRequests {
+				t.Errorf("expected %d requests, got %d", tc.wantRequests, got)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportCoalesces(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Header().Set("Docker-Content-Digest", "sha256:abc")
+		_, _ = w.Write([]byte("manifest"))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{Transport: newRateLimitTransport(nil)}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(srv.URL + "/v2/library/nginx/manifests/latest")
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			defer resp.Body.Close()
+			buf := make([]byte, 64)
+			n, _ := resp.Body.Read(buf)
+			bodies[i] = string(buf[:n]) + " " + resp.Header.Get("Docker-Content-Digest")
+		}()
+	}
+
+	// Give the requests time to be coalesced before the first one is
+	// answered
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+	for i, body := range bodies {
+		if body != "manifest sha256:abc" {
+			t.Errorf("unexpected response %d: %q", i, body)
+		}
+	}
+}
+
+func TestRateLimitTransportBackoff(t *testing.T) {
+	transport := newRateLimitTransport(nil)
+
+	testCases := map[string]struct {
+		attempt    int
+		retryAfter string
+		want       time.Duration
+	}{
+		"first attempt": {
+			attempt: 0,
+			want:    5 * time.Second,
+		},
+		"third attempt": {
+			attempt: 2,
+			want:    20 * time.Second,
+		},
+		"longer than the maximum": {
+			attempt: 10,
+			want:    5 * time.Minute,
+		},
+		"retry after": {
+			attempt:    2,
+			retryAfter: "60",
+			want:       time.Minute,
+		},
+		"invalid retry after": {
+			attempt:    0,
+			retryAfter: "soon",
+			want:       5 * time.Second,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+			if tc.retryAfter != "" {
+				resp.Header.Set("Retry-After", tc.retryAfter)
+			}
+			if got := transport.backoff(tc.attempt, resp); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/progress"
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// Options configures how the registry is crawled
+type Options struct {
+	// ReposOnly lists the repositories without their tags, so that each
+	// repository is returned once, without a tag
+	ReposOnly bool
+
+	// PlainHTTP accesses the registry over HTTP, rather than HTTPS
+	PlainHTTP bool
+
+	// InsecureRegistries are the registries, including the port if
+	// there is one, i.e registry.local:5000, that are accessed over HTTP,
+	// rather than HTTPS
+	InsecureRegistries []string
+
+	// Keychains are the cloud keychains, ecr, google or azure, that are
+	// used for the registries that there are no credentials for in the
+	// Docker config
+	Keychains []string
+
+	// Identity is the Chainguard identity that pulls from cgr.dev when
+	// there are no credentials for it in the Docker config
+	Identity *cgauth.Client
+}
+
+// ListImages returns the images in a registry, or in a single repository. The
+// target is a registry host, i.e registry.internal, or a repository, i.e
+// registry.internal/team/app. Credentials are read from the Docker config,
+// including any credential helpers, like they are for docker pull.
+//
+// Every tag of every repository is returned, except for the signatures,
+// attestations and SBOMs that are attached to images by cosign.
+func ListImages(ctx context.Context, target string, opts Options) ([]string, error) {
+	client, err := newClient(opts.Keychains, opts.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return listImages(ctx, client, target, opts)
+}
+
+// ListRepositories returns the repositories in the registry under the
+// prefix, i.e cgr.dev/example/nginx for cgr.dev/example. These are the
+// repositories that the credentials in the Docker config can see, so for a
+// Chainguard organization they're the repositories it's entitled to.
+func ListRepositories(ctx context.Context, prefix string, opts Options) ([]string, error) {
+	client, err := newClient(opts.Keychains, opts.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return listRepositories(ctx, client, prefix, opts)
+}
+
+func listRepositories(ctx context.Context, client remote.Client, prefix string, opts Options) ([]string, error) {
+	host, path, _ := strings.Cut(strings.TrimSuffix(prefix, "/"), "/")
+	reg, err := remote.NewRegistry(host)
+	if err != nil {
+		return nil, fmt.Errorf("parsing registry: %w", err)
+	}
+	reg.Client = client
+	reg.PlainHTTP = plainHTTP(reg.Reference.Registry, opts.PlainHTTP, opts.InsecureRegistries)
+
+	var repos []string
+	if err := reg.Repositories(ctx, "", func(names []string) error {
+		for _, name := range names {
+			if path != "" && !strings.HasPrefix(name, path+"/") {
+				continue
+			}
+			repos = append(repos, host+"/"+name)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	return repos, nil
+}
+
+// newClient returns a client that authenticates with the credentials in the
+// Docker config, then with the Chainguard identity, when there is one, and the
+// keychains. The clients share their tokens and wait for registries that are
+// rate limiting them.
+func newClient(keychains []string, identity *cgauth.Client) (*auth.Client, error) {
+	store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("loading docker config: %w", err)
+	}
+
+	cred, err := newCredential(store, keychains, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &auth.Client{
+		Client:     rateLimitedClient,
+		Cache:      tokenCache(keychains, identity != nil),
+		Credential: cred,
+	}
+	client.SetUserAgent("image-mapper")
+
+	return client, nil
+}
+
+func listImages(ctx context.Context, client remote.Client, target string, opts Options) ([]string, error) {
+	target = strings.TrimSuffix(target, "/")
+
+	// A target without a path is a registry, otherwise it's a
+	// repository
+	if !strings.Contains(target, "/") {
+		reg, err := remote.NewRegistry(target)
+		if err != nil {
+			return nil, fmt.Errorf("parsing registry: %w", err)
+		}
+		reg.Client = client
+		reg.PlainHTTP = plainHTTP(reg.Reference.Registry, opts.PlainHTTP, opts.InsecureRegistries)
+
+		return listRegistry(ctx, reg, opts)
+	}
+
+	repo, err := remote.NewRepository(target)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository: %w", err)
+	}
+	repo.Client = client
+	repo.PlainHTTP = plainHTTP(repo.Reference.Registry, opts.PlainHTTP, opts.InsecureRegistries)
+
+	return listRepository(ctx, repo, opts)
+}
+
+// plainHTTP returns true if the registry should be accessed over HTTP, either
+// because every registry is, or because it's one of the insecure registries
+func plainHTTP(registry string, all bool, insecure []string) bool {
+	return all || slices.Contains(insecure, registry)
+}
+
+// listRegistry returns the images in every repository in the registry
+func listRegistry(ctx context.Context, reg *remote.Registry, opts Options) ([]string, error) {
+	var names []string
+	if err := reg.Repositories(ctx, "", func(repos []string) error {
+		names = append(names, repos...)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	p := progress.Start("listing repositories", len(names))
+	defer p.Done()
+
+	var images []string
+	for _, name := range names {
+		images = append(images, listRegistryRepository(ctx, reg, name, opts)...)
+		p.Add(1)
+	}
+
+	return images, nil
+}
+
+// listRegistryRepository returns the images in a repository in the registry.
+// Repositories that can't be listed are logged as warnings and skipped.
+func listRegistryRepository(ctx context.Context, reg *remote.Registry, name string, opts Options) []string {
+	r, err := reg.Repository(ctx, name)
+	if err != nil {
+		slog.Warn("skipping repository", "repository", reg.Reference.Registry+"/"+name, "err", err)
+		return nil
+	}
+	repo, ok := r.(*remote.Repository)
+	if !ok {
+		return nil
+	}
+
+	images, err := listRepository(ctx, repo, opts)
+	if err != nil {
+		slog.Warn("skipping repository", "repository", repo.Reference.String(), "err", err)
+		return nil
+	}
+
+	return images
+}
+
+// listRepository returns the images in the repository
+func listRepository(ctx context.Context, repo *remote.Repository, opts Options) ([]string, error) {
+	if opts.ReposOnly {
+		return []string{repo.Reference.String()}, nil
+	}
+
+	var images []string
+	if err := repo.Tags(ctx, "", func(tags []string) error {
+		for _, tag := range tags {
+			if isCosignTag(tag) {
+				continue
+			}
+			images = append(images, repo.Reference.String()+":"+tag)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+
+	return images, nil
+}
+
+// isCosignTag returns true if the tag holds a signature, attestation or SBOM
+// attached to an image by cosign, i.e sha256-<digest>.sig
+func isCosignTag(tag string) bool {
+	if !strings.HasPrefix(tag, "sha256-") {
+		return false
+	}
+	for _, suffix := range []string{".sig", ".att", ".sbom"} {
+		if strings.HasSuffix(tag, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
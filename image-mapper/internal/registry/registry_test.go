@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newTestRegistry returns a registry that serves the catalog and tags of the
+// repositories. The catalog is split into pages of one repository.
+func newTestRegistry(t *testing.T, repos map[string][]string, order []string) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/_catalog":
+			i := 0
+			if last := r.URL.Query().Get("last"); last != "" {
+				for j, name := range order {
+					if name == last {
+						i = j + 1
+					}
+				}
+			}
+			if i+1 < len(order) {
+				w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?last=%s&n=1>; rel="next"`, order[i]))
+			}
+			_ = json.NewEncoder(w).Encode(map[string][]string{"repositories": order[i : i+1]})
+		case strings.HasSuffix(r.URL.Path, "/tags/list"):
+			name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v2/"), "/tags/list")
+			tags, ok := repos[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": name, "tags": tags})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestListImages(t *testing.T) {
+	host := newTestRegistry(t, map[string][]string{
+		"library/nginx": {"1.25", "1.27", "sha256-abc.sig", "sha256-abc.att"},
+		"team/app":      {"v1.0.0"},
+	}, []string{"library/nginx", "missing", "team/app"})
+
+	testCases := map[string]struct {
+		target string
+		opts   Options
+		want   []string
+	}{
+		"registry": {
+			target: host,
+			want: []string{
+				host + "/library/nginx:1.25",
+				host + "/library/nginx:1.27",
+				host + "/team/app:v1.0.0",
+			},
+		},
+		"registry repos only": {
+			target: host + "/",
+			opts:   Options{ReposOnly: true},
+			want: []string{
+				host + "/library/nginx",
+				host + "/missing",
+				host + "/team/app",
+			},
+		},
+		"repository": {
+			target: host + "/team/app",
+			want: []string{
+				host + "/team/app:v1.0.0",
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.opts.PlainHTTP = true
+			got, err := listImages(context.Background(), http.DefaultClient, tc.target, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected images (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListImagesMissingRepository(t *testing.T) {
+	host := newTestRegistry(t, map[string][]string{}, []string{})
+
+	if _, err := listImages(context.Background(), http.DefaultClient, host+"/missing", Options{PlainHTTP: true}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestListImagesInsecureRegistries(t *testing.T) {
+	host := newTestRegistry(t, map[string][]string{
+		"team/app": {"v1.0.0"},
+	}, []string{"team/app"})
+
+	// The test registry has a port and is only served over HTTP, so it
+	// can only be listed when it's one of the insecure registries
+	opts := Options{InsecureRegistries: []string{"registry.local:5000", host}}
+	got, err := listImages(context.Background(), http.DefaultClient, host, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff([]string{host + "/team/app:v1.0.0"}, got); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+
+	opts = Options{InsecureRegistries: []string{"registry.local:5000"}}
+	if _, err := listImages(context.Background(), http.DefaultClient, host+"/team/app", opts); err == nil {
+		t.Errorf("expected an error accessing the registry over HTTPS")
+	}
+}
+
+func TestListRepositories(t *testing.T) {
+	host := newTestRegistry(t, map[string][]string{}, []string{"example/nginx", "example/python", "other/nginx"})
+
+	testCases := map[string]struct {
+		prefix string
+		want   []string
+	}{
+		"organization": {
+			prefix: host + "/example",
+			want:   []string{host + "/example/nginx", host + "/example/python"},
+		},
+		"trailing slash": {
+			prefix: host + "/example/",
+			want:   []string{host + "/example/nginx", host + "/example/python"},
+		},
+		"registry": {
+			prefix: host,
+			want:   []string{host + "/example/nginx", host + "/example/python", host + "/other/nginx"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := listRepositories(context.Background(), http.DefaultClient, tc.prefix, Options{PlainHTTP: true})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected repositories (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/progress"
+	cgauth "github.com/chainguard-dev/platform-examples/pkg/auth"
+	"github.com/google/go-containerregistry/pkg/name"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// maxManifestSize is the largest manifest we'll read
+const maxManifestSize = 4 << 20
+
+// Media types of Docker manifests, which are handled like their OCI
+// equivalents
+const (
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// SizeOptions configures how the sizes of images are compared
+type SizeOptions struct {
+	// Platform is the platform of the image that's measured when the
+	// image is an index of images for many platforms. Defaults to
+	// linux/amd64.
+	Platform ocispec.Platform
+
+	// PlainHTTP accesses the registries over HTTP, rather than HTTPS
+	PlainHTTP bool
+
+	// InsecureRegistries are the registries, including the port if
+	// there is one, i.e registry.local:5000, that are accessed over HTTP,
+	// rather than HTTPS
+	InsecureRegistries []string
+
+	// Keychains are the cloud keychains, ecr, google or azure, that are
+	// used for the registries that there are no credentials for in the
+	// Docker config
+	Keychains []string
+
+	// Identity is the Chainguard identity that pulls from cgr.dev when
+	// there are no credentials for it in the Docker config
+	Identity *cgauth.Client
+}
+
+// Sizer adds the sizes of the images to mappings. Each image is only
+// measured once.
+type Sizer struct {
+	client remote.Client
+	opts   SizeOptions
+	cache  map[string]sizeResult
+}
+
+type sizeResult struct {
+	size mapper.ImageSize
+	err  error
+}
+
+// NewSizer returns a sizer that reads manifests with the credentials in the
+// Docker config and the keychains
+func NewSizer(opts SizeOptions) (*Sizer, error) {
+	client, err := newClient(opts.Keychains, opts.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSizer(client, opts), nil
+}
+
+func newSizer(client remote.Client, opts SizeOptions) *Sizer {
+	if opts.Platform.OS == "" {
+		opts.Platform = ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	}
+
+	return &Sizer{
+		client: client,
+		opts:   opts,
+		cache:  map[string]sizeResult{},
+	}
+}
+
+// Annotate fetches the manifests of the image and the first result of each
+// mapping and sets the sizes of the mapping. Mappings without results are
+// skipped. Images that can't be measured are logged as warnings and the
+// mapping is left without sizes.
+func (s *Sizer) Annotate(ctx context.Context, mappings ...*mapper.Mapping) {
+	p := progress.Start("fetching sizes", len(mappings))
+	defer p.Done()
+
+	for _, m := range mappings {
+		s.annotate(ctx, m)
+		p.Add(1)
+	}
+}
+
+func (s *Sizer) annotate(ctx context.Context, m *mapper.Mapping) {
+	if len(m.Results) == 0 {
+		return
+	}
+
+	image, err := s.size(ctx, m.Image)
+	if err != nil {
+		slog.Warn("fetching size", "image", m.Image, "err", err)
+		return
+	}
+	result, err := s.size(ctx, m.Results[0])
+	if err != nil {
+		slog.Warn("fetching size", "image", m.Results[0], "err", err)
+		return
+	}
+
+	m.Sizes = &mapper.Sizes{
+		Image:  image,
+		Result: result,
+	}
+}
+
+func (s *Sizer) size(ctx context.Context, image string) (mapper.ImageSize, error) {
+	if r, ok := s.cache[image]; ok {
+		return r.size, r.err
+	}
+
+	size, err := imageSize(ctx, s.client, image, s.opts)
+	s.cache[image] = sizeResult{size: size, err: err}
+
+	return size, err
+}
+
+// imageSize returns the compressed size and the number of layers of the
+// image. For an index, the image for the platform is measured.
+func imageSize(ctx context.Context, client remote.Client, image string, opts SizeOptions) (mapper.ImageSize, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return mapper.ImageSize{}, fmt.Errorf("parsing image: %w", err)
+	}
+
+	// Docker Hub is served from a different host than the one in the
+	// image references
+	registry := ref.Context().RegistryStr()
+	if registry == name.DefaultRegistry {
+		registry = "registry-1.docker.io"
+	}
+	repo, err := remote.NewRepository(registry + "/" + ref.Context().RepositoryStr())
+	if err != nil {
+		return mapper.ImageSize{}, fmt.Errorf("parsing repository: %w", err)
+	}
+	repo.Client = client
+	repo.PlainHTTP = plainHTTP(registry, opts.PlainHTTP, opts.InsecureRegistries)
+
+	mediaType, body, err := fetchManifest(ctx, repo, ref.Identifier())
+	if err != nil {
+		return mapper.ImageSize{}, err
+	}
+
+	if mediaType == ocispec.MediaTypeImageIndex || mediaType == mediaTypeDockerManifestList {
+		var index ocispec.Index
+		if err := json.Unmarshal(body, &index); err != nil {
+			return mapper.ImageSize{}, fmt.Errorf("decoding index: %w", err)
+		}
+		desc, ok := platformManifest(index, opts.Platform)
+		if !ok {
+			return mapper.ImageSize{}, fmt.Errorf("no image for %s/%s", opts.Platform.OS, opts.Platform.Architecture)
+		}
+		mediaType, body, err = fetchManifest(ctx, repo, desc.Digest.String())
+		if err != nil {
+			return mapper.ImageSize{}, err
+		}
+	}
+
+	if mediaType != ocispec.MediaTypeImageManifest && mediaType != mediaTypeDockerManifest {
+		return mapper.ImageSize{}, fmt.Errorf("unsupported media type: %s", mediaType)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return mapper.ImageSize{}, fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	size := mapper.ImageSize{Layers: len(manifest.Layers)}
+	for _, layer := range manifest.Layers {
+		size.Bytes += layer.Size
+	}
+
+	return size, nil
+}
+
+// fetchManifest returns the media type and the body of the manifest
+func fetchManifest(ctx context.Context, repo *remote.Repository, reference string) (string, []byte, error) {
+	desc, rc, err := repo.FetchReference(ctx, reference)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetching manifest: %w", err)
+	}
+	defer rc.Close()
+
+	body, err := io.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return "", nil, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	return desc.MediaType, body, nil
+}
+
+// platformManifest returns the manifest in the index for the platform
+func platformManifest(index ocispec.Index, platform ocispec.Platform) (ocispec.Descriptor, bool) {
+	for _, desc := range index.Manifests {
+		if desc.Platform == nil {
+			continue
+		}
+		if desc.Platform.OS == platform.OS && desc.Platform.Architecture == platform.Architecture {
+			return desc, true
+		}
+	}
+
+	return ocispec.Descriptor{}, false
+}
@@ -0,0 +1,239 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type testManifest struct {
+	mediaType string
+	body      []byte
+}
+
+// newManifestRegistry returns a registry that serves the manifests, which are
+// keyed by repository and then by tag, and the tags of the repositories. The manifests and the untagged
+// manifests, like the manifests in an index, can also be fetched by their
+// digest.
+func newManifestRegistry(t *testing.T, manifests map[string]map[string]testManifest, untagged ...testManifest) string {
+	t.Helper()
+
+	byDigest := map[string]testManifest{}
+	for _, m := range untagged {
+		byDigest[digest.FromBytes(m.body).String()] = m
+	}
+	for _, tags := range manifests {
+		for _, m := range tags {
+			byDigest[digest.FromBytes(m.body).String()] = m
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/v2/"), "/tags/list"); ok {
+			tags, ok := manifests[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": name, "tags": slices.Sorted(maps.Keys(tags))})
+			return
+		}
+
+		repo, ref, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m, ok := manifests[repo][ref]
+		if !ok {
+			m, ok = byDigest[ref]
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(m.body).String())
+		w.Header().Set("Content-Length", fmt.Sprint(len(m.body)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write(m.body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func imageManifest(t *testing.T, sizes ...int64) testManifest {
+	t.Helper()
+
+	manifest := ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest}
+	manifest.SchemaVersion = 2
+	for i, size := range sizes {
+		manifest.Layers = append(manifest.Layers, ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageLayerGzip,
+			Digest:    digest.FromString(fmt.Sprint(i)),
+			Size:      size,
+		})
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("marshalling manifest: %s", err)
+	}
+
+	return testManifest{mediaType: ocispec.MediaTypeImageManifest, body: body}
+}
+
+func imageIndex(t *testing.T, manifests map[string]testManifest) testManifest {
+	t.Helper()
+
+	index := ocispec.Index{MediaType: ocispec.MediaTypeImageIndex}
+	index.SchemaVersion = 2
+	for platform, m := range manifests {
+		os, arch, _ := strings.Cut(platform, "/")
+		index.Manifests = append(index.Manifests, ocispec.Descriptor{
+			MediaType: m.mediaType,
+			Digest:    digest.FromBytes(m.body),
+			Size:      int64(len(m.body)),
+			Platform:  &ocispec.Platform{OS: os, Architecture: arch},
+		})
+	}
+	body, err := json.Marshal(index)
+	if err != nil {
+		t.Fatalf("marshalling index: %s", err)
+	}
+
+	return testManifest{mediaType: ocispec.MediaTypeImageIndex, body: body}
+}
+
+func TestImageSize(t *testing.T) {
+	amd64 := imageManifest(t, 100, 200, 300)
+	arm64 := imageManifest(t, 50)
+	host := newManifestRegistry(t, map[string]map[string]testManifest{
+		"library/nginx": {
+			"1.25": imageManifest(t, 1000, 2000),
+		},
+		"chainguard/nginx": {
+			"latest": imageIndex(t, map[string]testManifest{
+				"linux/amd64": amd64,
+				"linux/arm64": arm64,
+			}),
+		},
+	}, amd64, arm64)
+
+	testCases := map[string]struct {
+		image    string
+		platform ocispec.Platform
+		want     mapper.ImageSize
+		wantErr  bool
+	}{
+		"manifest": {
+			image: host + "/library/nginx:1.25",
+			want:  mapper.ImageSize{Bytes: 3000, Layers: 2},
+		},
+		"index": {
+			image: host + "/chainguard/nginx:latest",
+			want:  mapper.ImageSize{Bytes: 600, Layers: 3},
+		},
+		"index with platform": {
+			image:    host + "/chainguard/nginx:latest",
+			platform: ocispec.Platform{OS: "linux", Architecture: "arm64"},
+			want:     mapper.ImageSize{Bytes: 50, Layers: 1},
+		},
+		"index without platform": {
+			image:    host + "/chainguard/nginx:latest",
+			platform: ocispec.Platform{OS: "windows", Architecture: "amd64"},
+			wantErr:  true,
+		},
+		"missing": {
+			image:   host + "/chainguard/nginx:missing",
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := newSizer(http.DefaultClient, SizeOptions{Platform: tc.platform, PlainHTTP: true})
+			got, err := imageSize(context.Background(), s.client, tc.image, s.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected size (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSizerAnnotate(t *testing.T) {
+	host := newManifestRegistry(t, map[string]map[string]testManifest{
+		"library/nginx": {
+			"1.25": imageManifest(t, 1000, 2000),
+		},
+		"chainguard/nginx": {
+			"1.25": imageManifest(t, 500),
+		},
+	})
+	mappings := []*mapper.Mapping{
+		{Image: host + "/library/nginx:1.25", Results: []string{host + "/chainguard/nginx:1.25"}},
+		{Image: host + "/library/nginx:missing", Results: []string{host + "/chainguard/nginx:1.25"}},
+		{Image: host + "/library/redis:7"},
+	}
+
+	newSizer(http.DefaultClient, SizeOptions{PlainHTTP: true}).Annotate(context.Background(), mappings...)
+
+	want := []*mapper.Mapping{
+		{
+			Image:   host + "/library/nginx:1.25",
+			Results: []string{host + "/chainguard/nginx:1.25"},
+			Sizes: &mapper.Sizes{
+				Image:  mapper.ImageSize{Bytes: 3000, Layers: 2},
+				Result: mapper.ImageSize{Bytes: 500, Layers: 1},
+			},
+		},
+		{
+			Image:   host + "/library/nginx:missing",
+			Results: []string{host + "/chainguard/nginx:1.25"},
+		},
+		{
+			Image: host + "/library/redis:7",
+		},
+	}
+	if diff := cmp.Diff(want, mappings); diff != "" {
+		t.Errorf("unexpected mappings (-want +got):\n%s", diff)
+	}
+}
+
+func TestImageSizeInsecureRegistries(t *testing.T) {
+	host := newManifestRegistry(t, map[string]map[string]testManifest{
+		"library/nginx": {
+			"1.25": imageManifest(t, 1000),
+		},
+	})
+
+	opts := SizeOptions{InsecureRegistries: []string{host}}
+	got, err := imageSize(context.Background(), http.DefaultClient, host+"/library/nginx:1.25", newSizer(http.DefaultClient, opts).opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(mapper.ImageSize{Bytes: 1000, Layers: 1}, got); diff != "" {
+		t.Errorf("unexpected size (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,188 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// document is the subset of an SPDX or CycloneDX JSON document that describes
+// the packages or components in it
+type document struct {
+	// SPDX
+	SPDXVersion string        `json:"spdxVersion"`
+	Packages    []spdxPackage `json:"packages"`
+
+	// CycloneDX
+	BOMFormat  string               `json:"bomFormat"`
+	Metadata   *cycloneDXMetadata   `json:"metadata"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type spdxPackage struct {
+	Name                  string            `json:"name"`
+	VersionInfo           string            `json:"versionInfo"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxExternalRef struct {
+	ReferenceType    string `json:"referenceType"`
+	ReferenceLocator string `json:"referenceLocator"`
+}
+
+type cycloneDXMetadata struct {
+	Component *cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXComponent struct {
+	Type       string               `json:"type"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version"`
+	PURL       string               `json:"purl"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+// Images returns the container images in an SPDX or CycloneDX JSON document.
+// Images are found in the docker and oci package URLs of the packages or
+// components, or in the name and version of the ones that are containers. An
+// image is returned once for each package that refers to it.
+func Images(input []byte) ([]string, error) {
+	var doc document
+	if err := json.Unmarshal(input, &doc); err != nil {
+		return nil, fmt.Errorf("decoding json: %w", err)
+	}
+
+	switch {
+	case doc.SPDXVersion != "":
+		return spdxImages(doc.Packages), nil
+	case strings.EqualFold(doc.BOMFormat, "CycloneDX"):
+		components := doc.Components
+		if doc.Metadata != nil && doc.Metadata.Component != nil {
+			components = append([]cycloneDXComponent{*doc.Metadata.Component}, components...)
+		}
+		return cycloneDXImages(components), nil
+	default:
+		return nil, fmt.Errorf("unsupported document: must be an SPDX or CycloneDX JSON document")
+	}
+}
+
+// spdxImages returns the images of the SPDX packages
+func spdxImages(packages []spdxPackage) []string {
+	var images []string
+	for _, pkg := range packages {
+		var purl string
+		for _, ref := range pkg.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				purl = ref.ReferenceLocator
+				break
+			}
+		}
+
+		if img, ok := image(purl, pkg.PrimaryPackagePurpose == "CONTAINER", pkg.Name, pkg.VersionInfo); ok {
+			images = append(images, img)
+		}
+	}
+
+	return images
+}
+
+// cycloneDXImages returns the images of the CycloneDX components and the
+// components nested in them
+func cycloneDXImages(components []cycloneDXComponent) []string {
+	var images []string
+	for _, c := range components {
+		if img, ok := image(c.PURL, c.Type == "container", c.Name, c.Version); ok {
+			images = append(images, img)
+		}
+		images = append(images, cycloneDXImages(c.Components)...)
+	}
+
+	return images
+}
+
+// image returns the image of a package. The package URL is preferred, but
+// containers without one fall back to the name and version.
+func image(purl string, container bool, name, version string) (string, bool) {
+	if purl != "" {
+		if img, ok := purlImage(purl); ok {
+			return img, true
+		}
+	}
+	if !container || name == "" {
+		return "", false
+	}
+
+	// The version of a container is often the ID of the image, rather
+	// than a tag, which doesn't help us
+	if version == "" || strings.HasPrefix(version, "sha256:") || strings.Contains(name, ":") {
+		return name, true
+	}
+
+	return name + ":" + version, true
+}
+
+// purlImage returns the image reference of a docker or oci package URL, i.e
+//
+//	pkg:docker/library/nginx@1.25?repository_url=docker.io
+//	pkg:oci/nginx@sha256:...?repository_url=ghcr.io/example/nginx&tag=1.25
+//
+// Package URLs of other types aren't images.
+func purlImage(purl string) (string, bool) {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return "", false
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	qualifiers, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", false
+	}
+	typ, rest, ok := strings.Cut(rest, "/")
+	if !ok {
+		return "", false
+	}
+	path, version, _ := strings.Cut(rest, "@")
+	path, err = url.PathUnescape(path)
+	if err != nil {
+		return "", false
+	}
+	version, err = url.PathUnescape(version)
+	if err != nil {
+		return "", false
+	}
+
+	var repo string
+	switch strings.ToLower(typ) {
+	case "docker":
+		// The namespace and name are the repository in the
+		// registry
+		repo = path
+		if registry := qualifiers.Get("repository_url"); registry != "" {
+			repo = strings.TrimSuffix(registry, "/") + "/" + path
+		}
+	case "oci":
+		// The repository URL is the full repository, including
+		// the name
+		repo = path
+		if repository := qualifiers.Get("repository_url"); repository != "" {
+			repo = strings.TrimSuffix(repository, "/")
+		}
+		if tag := qualifiers.Get("tag"); tag != "" {
+			return repo + ":" + tag, true
+		}
+	default:
+		return "", false
+	}
+
+	switch {
+	case version == "":
+		return repo, true
+	case strings.HasPrefix(version, "sha256:"):
+		return repo + "@" + version, true
+	default:
+		return repo + ":" + version, true
+	}
+}
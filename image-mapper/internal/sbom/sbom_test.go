@@ -0,0 +1,105 @@
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestImages(t *testing.T) {
+	testCases := map[string][]string{
+		"cyclonedx.json": {
+			"nginx",
+			"ghcr.io/example/app:v1.2.0",
+			"docker.io/library/redis:7.4",
+			"docker.io/library/postgres:16",
+		},
+		"spdx.json": {
+			"quay.io/example/nginx@sha256:3b25b682ea82b2db3cc4fd48db818be788ee3f902ac7378090cf2624ec2442df",
+			"busybox:1.37",
+			"bitnami/kubectl:1.31",
+		},
+	}
+	for file, want := range testCases {
+		t.Run(file, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatalf("unexpected error reading file: %s", err)
+			}
+
+			got, err := Images(input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected images (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImagesUnsupported(t *testing.T) {
+	for name, input := range map[string]string{
+		"not json":    "SPDXVersion: SPDX-2.3",
+		"not an sbom": `{"kind": "Deployment"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Images([]byte(input)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestPURLImage(t *testing.T) {
+	testCases := map[string]struct {
+		purl string
+		want string
+		ok   bool
+	}{
+		"docker": {
+			purl: "pkg:docker/library/nginx@1.25",
+			want: "library/nginx:1.25",
+			ok:   true,
+		},
+		"docker with registry": {
+			purl: "pkg:docker/example/app@sha256%3Aabc?repository_url=gcr.io",
+			want: "gcr.io/example/app@sha256:abc",
+			ok:   true,
+		},
+		"docker without a version": {
+			purl: "pkg:docker/nginx",
+			want: "nginx",
+			ok:   true,
+		},
+		"oci with tag": {
+			purl: "pkg:oci/debian@sha256%3Aabc?repository_url=docker.io/library/debian&arch=amd64&tag=bookworm",
+			want: "docker.io/library/debian:bookworm",
+			ok:   true,
+		},
+		"oci without repository url": {
+			purl: "pkg:oci/debian@sha256%3Aabc",
+			want: "debian@sha256:abc",
+			ok:   true,
+		},
+		"other type": {
+			purl: "pkg:npm/left-pad@1.3.0",
+		},
+		"not a purl": {
+			purl: "nginx:1.25",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := purlImage(tc.purl)
+			if ok != tc.ok {
+				t.Fatalf("unexpected ok: %t", ok)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected image: %q, wanted %q", got, tc.want)
+			}
+		})
+	}
+}
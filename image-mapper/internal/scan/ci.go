@@ -0,0 +1,190 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isCI returns true if the path is a CI config that we know how to read, i.e
+// a GitHub Actions workflow, .gitlab-ci.yml or a CircleCI config
+func isCI(path string) bool {
+	path = filepath.ToSlash(path)
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	dir := filepath.Base(filepath.Dir(path))
+
+	switch {
+	case name == ".gitlab-ci.yml":
+		return true
+	case dir == ".circleci" && (name == "config.yml" || name == "config.yaml"):
+		return true
+	case strings.HasSuffix(filepath.Dir(path), ".github/workflows") && (ext == ".yml" || ext == ".yaml"):
+		return true
+	}
+
+	return false
+}
+
+// CIImages returns the images in a CI config, ordered by job:
+//
+//   - GitHub Actions: job containers and services, and steps that use
+//     docker:// actions
+//   - GitLab CI: the default and job images and services
+//   - CircleCI: the docker images of jobs and executors
+//
+// Images with variables are skipped, because they can't be resolved.
+func CIImages(path string) ([]string, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	var config map[string]any
+	if err := yaml.Unmarshal(input, &config); err != nil {
+		return nil, fmt.Errorf("decoding CI config: %w", err)
+	}
+
+	var images []string
+	switch name := filepath.Base(path); {
+	case name == ".gitlab-ci.yml":
+		images = gitlabImages(config)
+	case filepath.Base(filepath.Dir(path)) == ".circleci":
+		images = circleCIImages(config)
+	default:
+		images = githubImages(config)
+	}
+
+	return slices.DeleteFunc(images, func(img string) bool {
+		return img == "" || strings.Contains(img, "$")
+	}), nil
+}
+
+// githubImages returns the images in a GitHub Actions workflow
+func githubImages(config map[string]any) []string {
+	var images []string
+	jobs, _ := config["jobs"].(map[string]any)
+	for _, name := range sortedKeys(jobs) {
+		job, _ := jobs[name].(map[string]any)
+
+		// The container is an image, or a map with the image
+		switch container := job["container"].(type) {
+		case string:
+			images = append(images, container)
+		case map[string]any:
+			images = append(images, stringValue(container, "image"))
+		}
+
+		services, _ := job["services"].(map[string]any)
+		for _, name := range sortedKeys(services) {
+			service, _ := services[name].(map[string]any)
+			images = append(images, stringValue(service, "image"))
+		}
+
+		steps, _ := job["steps"].([]any)
+		for _, step := range steps {
+			step, _ := step.(map[string]any)
+			if img, ok := strings.CutPrefix(stringValue(step, "uses"), "docker://"); ok {
+				images = append(images, img)
+			}
+		}
+	}
+
+	return images
+}
+
+// gitlabKeywords are the top level keys in .gitlab-ci.yml that aren't jobs
+var gitlabKeywords = map[string]struct{}{
+	"default":   {},
+	"include":   {},
+	"stages":    {},
+	"variables": {},
+	"workflow":  {},
+}
+
+// gitlabImages returns the images in a .gitlab-ci.yml
+func gitlabImages(config map[string]any) []string {
+	images := gitlabJobImages(config)
+	if def, ok := config["default"].(map[string]any); ok {
+		images = append(images, gitlabJobImages(def)...)
+	}
+
+	for _, name := range sortedKeys(config) {
+		if _, ok := gitlabKeywords[name]; ok {
+			continue
+		}
+		job, ok := config[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		images = append(images, gitlabJobImages(job)...)
+	}
+
+	return images
+}
+
+// gitlabJobImages returns the image and services of a GitLab job. They can
+// either be an image, or a map with the image in the name.
+func gitlabJobImages(job map[string]any) []string {
+	image := func(v any) string {
+		switch v := v.(type) {
+		case string:
+			return v
+		case map[string]any:
+			return stringValue(v, "name")
+		}
+		return ""
+	}
+
+	var images []string
+	if img := image(job["image"]); img != "" {
+		images = append(images, img)
+	}
+	services, _ := job["services"].([]any)
+	for _, service := range services {
+		images = append(images, image(service))
+	}
+
+	return images
+}
+
+// circleCIImages returns the images in a CircleCI config
+func circleCIImages(config map[string]any) []string {
+	var images []string
+	for _, section := range []string{"executors", "jobs"} {
+		entries, _ := config[section].(map[string]any)
+		for _, name := range sortedKeys(entries) {
+			entry, _ := entries[name].(map[string]any)
+			docker, _ := entry["docker"].([]any)
+			for _, d := range docker {
+				d, _ := d.(map[string]any)
+				images = append(images, stringValue(d, "image"))
+			}
+		}
+	}
+
+	return images
+}
+
+// sortedKeys returns the keys of a map in order, so that the images are found
+// in a consistent order
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// stringValue returns the value of the key in the map if it's a string
+func stringValue(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	gitignore "github.com/monochromegane/go-gitignore"
+)
+
+// IgnoreFile is the name of the file that lists the paths that image-mapper
+// should skip, in the same format as a .gitignore file
+const IgnoreFile = ".imagemapperignore"
+
+// ignoreFiles are the files in a directory that list the paths to skip. The
+// patterns in them apply to the directory and its subdirectories.
+var ignoreFiles = []string{".gitignore", IgnoreFile}
+
+// ignorer decides which paths are skipped while walking a directory, with the
+// ignore files in each directory it has walked and the patterns provided by
+// the user
+type ignorer struct {
+	root     string
+	patterns gitignore.IgnoreMatcher
+	dirs     map[string][]gitignore.IgnoreMatcher
+}
+
+// newIgnorer returns an ignorer for the directory. The patterns are relative
+// to the directory, like the patterns in an ignore file at its root.
+func newIgnorer(root string, patterns []string) *ignorer {
+	i := &ignorer{
+		root: root,
+		dirs: map[string][]gitignore.IgnoreMatcher{},
+	}
+	if len(patterns) > 0 {
+		i.patterns = gitignore.NewGitIgnoreFromReader(root, strings.NewReader(strings.Join(patterns, "\n")))
+	}
+
+	return i
+}
+
+// load reads the ignore files in a directory, so that they apply to the paths
+// in it. It must be called before the paths in the directory are checked.
+func (i *ignorer) load(dir string) error {
+	for _, name := range ignoreFiles {
+		m, err := gitignore.NewGitIgnore(filepath.Join(dir, name), dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("reading ignore file: %w", err)
+		}
+		i.dirs[dir] = append(i.dirs[dir], m)
+	}
+
+	return nil
+}
+
+// ignored returns true if the path matches the patterns provided by the user
+// or the ignore files in any of the directories above it
+func (i *ignorer) ignored(path string, isDir bool) bool {
+	if path == i.root {
+		return false
+	}
+	if i.patterns != nil && i.patterns.Match(path, isDir) {
+		return true
+	}
+
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		for _, m := range i.dirs[dir] {
+			if m.Match(path, isDir) {
+				return true
+			}
+		}
+		if dir == i.root || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/devconfig"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/dockerfile"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/helm"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/iac"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/scanner"
+	"golang.org/x/sync/errgroup"
+)
+
+// Type is the kind of file that images were found in. The files that are read
+// by a scanner plugin have the name of the scanner.
+type Type string
+
+const (
+	TypeDockerfile Type = "dockerfile"
+	TypeCompose    Type = "compose"
+	TypeHelm       Type = "helm"
+	TypeManifest   Type = "manifest"
+	TypeCI         Type = "ci"
+	TypeBazel      Type = "bazel"
+	TypeNomad      Type = "nomad"
+	TypeEarthly    Type = "earthly"
+	TypeBake       Type = "bake"
+)
+
+// File is a file in a repository that refers to images. For Helm charts, the
+// path is the chart directory.
+type File struct {
+	Path   string
+	Type   Type
+	Images []string
+
+	// Locations are where each of the images appears in the file, in the
+	// same order as the images, for the types of file that record them
+	Locations []mapper.Location
+}
+
+// Result is the mappings for the images in a file
+type Result struct {
+	Path     string            `json:"path"`
+	Type     Type              `json:"type"`
+	Mappings []*mapper.Mapping `json:"mappings"`
+}
+
+// Map maps the images in each file. Each unique image in a file is mapped
+// once, with the number of times it appears in the file, and where, recorded
+// in the mapping.
+func Map(m mapper.Mapper, files []File) ([]Result, error) {
+	var results []Result
+	for _, file := range files {
+		mapped := map[string]*mapper.Mapping{}
+		result := Result{
+			Path:     file.Path,
+			Type:     file.Type,
+			Mappings: []*mapper.Mapping{},
+		}
+		for i, img := range file.Images {
+			if mapping, ok := mapped[img]; ok {
+				mapping.Occurrences++
+				mapping.Locations = appendLocation(mapping.Locations, file, i)
+				continue
+			}
+
+			mapping, err := m.Map(img)
+			if err != nil {
+				return nil, fmt.Errorf("mapping image %s: %w", img, err)
+			}
+			mapping.Occurrences = 1
+			mapping.Locations = appendLocation(mapping.Locations, file, i)
+
+			result.Mappings = append(result.Mappings, mapping)
+			mapped[img] = mapping
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// appendLocation appends the location of the i-th image in the file, if the
+// file records where its images are
+func appendLocation(locations []mapper.Location, file File, i int) []mapper.Location {
+	if i >= len(file.Locations) {
+		return locations
+	}
+
+	return append(locations, file.Locations[i])
+}
+
+// IsRemote returns true if the target looks like the URL of a git repository,
+// rather than a local path
+func IsRemote(target string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "git@"} {
+		if strings.HasPrefix(target, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clone makes a shallow clone of the git repository into dir. It uses the
+// git binary, so that the user's credential helpers and SSH config apply.
+func Clone(ctx context.Context, url, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", "--depth=1", url, dir)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cloning repository: %s: %w", url, err)
+	}
+
+	return nil
+}
+
+// skipDirs are directories that won't contain files we're interested in
+var skipDirs = map[string]struct{}{
+	".git":         {},
+	".terraform":   {},
+	"node_modules": {},
+	"vendor":       {},
+}
+
+// composePattern matches the default names of Docker Compose files, as well
+// as overrides like docker-compose.prod.yml
+var composePattern = regexp.MustCompile(`^(docker-)?compose(\..+)?\.ya?ml$`)
+
+// bakePattern matches the default names of Docker Bake files, as well as
+// overrides like docker-bake.override.hcl
+var bakePattern = regexp.MustCompile(`^docker-bake(\..+)?\.(hcl|json)$`)
+
+// Option configures how a directory is scanned
+type Option func(*options)
+
+type options struct {
+	manifestOpts []manifest.Option
+	workers      int
+	ignore       []string
+	scanners     []scanner.Scanner
+}
+
+// WithManifestOptions configures how images are found in Kubernetes
+// manifests, like the custom resources to look for images in
+func WithManifestOptions(opts ...manifest.Option) Option {
+	return func(o *options) {
+		o.manifestOpts = append(o.manifestOpts, opts...)
+	}
+}
+
+// WithWorkers sets the number of files that are read at once. It defaults
+// to the number of CPUs.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithIgnorePatterns skips the paths that match the patterns, which are in
+// the format of a .gitignore file and relative to the directory that's
+// scanned, like build/ or examples/**/*.yaml
+func WithIgnorePatterns(patterns ...string) Option {
+	return func(o *options) {
+		o.ignore = append(o.ignore, patterns...)
+	}
+}
+
+// WithScanners finds the images in the files that the scanners read, like the
+// formats of config files that aren't built in. The scanners are tried in
+// order, before the built in formats.
+func WithScanners(scanners ...scanner.Scanner) Option {
+	return func(o *options) {
+		o.scanners = append(o.scanners, scanners...)
+	}
+}
+
+func makeOptions(opts []Option) options {
+	o := options{workers: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+
+	return o
+}
+
+// Find walks the directory and returns the files that refer to images, with
+// the images they refer to. Paths are relative to the directory.
+//
+// The files are read concurrently, but they're returned in the order they're
+// found in the directory, so the results are the same from one run to the
+// next.
+//
+// Paths that match the .gitignore and .imagemapperignore files in the
+// directory, or its subdirectories, are skipped, like the paths that match
+// the patterns in WithIgnorePatterns.
+//
+// Helm charts are rendered with their default values, and the templates
+// aren't treated as manifests. YAML files that can't be parsed are assumed
+// not to be manifests and are ignored. Other files that can't be parsed are
+// skipped with a warning.
+func Find(dir string, opts ...Option) ([]File, error) {
+	o := makeOptions(opts)
+
+	// Collect the files and charts first, so that the slow part, reading
+	// them, can be done concurrently
+	var (
+		found []File
+		ign   = newIgnorer(dir, o.ignore)
+	)
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if ign.ignored(path, d.IsDir()) {
+			slog.Debug("ignoring path", "path", rel)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if _, ok := skipDirs[d.Name()]; ok && path != dir {
+				return filepath.SkipDir
+			}
+			if err := ign.load(path); err != nil {
+				return err
+			}
+
+			if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err == nil {
+				found = append(found, File{Path: rel, Type: TypeHelm})
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		found = append(found, File{Path: rel})
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walking directory: %s: %w", dir, err)
+	}
+
+	var g errgroup.Group
+	g.SetLimit(o.workers)
+	for i := range found {
+		g.Go(func() error {
+			found[i] = readFile(dir, found[i], o)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, file := range found {
+		files = appendFile(files, file)
+	}
+
+	return files, nil
+}
+
+// readFile finds the images in a file, or chart, that Find found in the
+// directory. Files that can't be read are returned without any images.
+func readFile(dir string, file File, o options) File {
+	path := filepath.Join(dir, file.Path)
+
+	if file.Type == TypeHelm {
+		images, err := helm.ChartImages(path)
+		if err != nil {
+			slog.Warn("skipping chart", "path", file.Path, "err", err)
+			return file
+		}
+		file.Images = images
+		return file
+	}
+
+	if s := scanner.Find(o.scanners, filepath.ToSlash(file.Path)); s != nil {
+		found, err := scannerImages(s, path, filepath.ToSlash(file.Path))
+		if err != nil {
+			slog.Warn("skipping file", "path", file.Path, "scanner", s.Name(), "err", err)
+			return file
+		}
+		found.Path = file.Path
+		return found
+	}
+
+	found, err := findImages(path, o.manifestOpts)
+	if err != nil {
+		slog.Warn("skipping file", "path", file.Path, "err", err)
+		return file
+	}
+	found.Path = file.Path
+	// The locations are reported with forward slashes on every platform,
+	// like the paths in a git repository
+	for i := range found.Locations {
+		found.Locations[i].Path = filepath.ToSlash(file.Path)
+	}
+
+	return found
+}
+
+// appendFile appends the file if it refers to any images
+func appendFile(files []File, file File) []File {
+	if len(file.Images) == 0 {
+		return files
+	}
+
+	return append(files, file)
+}
+
+// findImages detects the type of the file from its path and returns the
+// images it refers to. Files that aren't recognised are returned without any
+// images.
+func findImages(path string, opts []manifest.Option) (File, error) {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case dockerfile.IsDockerfile(name):
+		input, err := os.ReadFile(path)
+		if err != nil {
+			return File{}, fmt.Errorf("reading file: %w", err)
+		}
+		refs, err := dockerfile.References(input, nil)
+		file := File{Type: TypeDockerfile}
+		for _, ref := range refs {
+			file.Images = append(file.Images, ref.Image)
+			file.Locations = append(file.Locations, mapper.Location{Line: ref.Line})
+		}
+		return file, err
+	case name == "earthfile":
+		images, err := devconfig.EarthfileImages(path)
+		return File{Type: TypeEarthly, Images: images}, err
+	case bakePattern.MatchString(name):
+		images, err := devconfig.BakeImages(path)
+		return File{Type: TypeBake, Images: images}, err
+	case composePattern.MatchString(name):
+		images, err := devconfig.ComposeImages(path)
+		return File{Type: TypeCompose, Images: images}, err
+	case isCI(path):
+		images, err := CIImages(path)
+		return File{Type: TypeCI, Images: images}, err
+	case iac.IsBazel(filepath.Base(path)):
+		file, err := iacImages(path)
+		file.Type = TypeBazel
+		return file, err
+	case iac.IsNomad(name):
+		file, err := iacImages(path)
+		file.Type = TypeNomad
+		return file, err
+	case strings.HasSuffix(name, ".yaml"), strings.HasSuffix(name, ".yml"):
+		input, err := os.ReadFile(path)
+		if err != nil {
+			return File{}, fmt.Errorf("reading file: %w", err)
+		}
+		imgs, err := manifest.Images(input, opts...)
+		if err != nil {
+			return File{}, nil
+		}
+		file := File{Type: TypeManifest}
+		for _, img := range imgs {
+			file.Images = append(file.Images, img.Image)
+			file.Locations = append(file.Locations, mapper.Location{
+				Line:     img.Line,
+				YAMLPath: strings.Join(img.Field, "."),
+			})
+		}
+		return file, nil
+	}
+
+	return File{}, nil
+}
+
+// scannerImages returns the images that the scanner finds in a file, and the
+// lines they're on when the scanner knows them
+func scannerImages(s scanner.Scanner, path, rel string) (File, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, fmt.Errorf("reading file: %w", err)
+	}
+	images, err := s.Extract(rel, input)
+	if err != nil {
+		return File{}, err
+	}
+
+	file := File{Type: Type(s.Name())}
+	for _, img := range images {
+		file.Images = append(file.Images, img.Image)
+		file.Locations = append(file.Locations, mapper.Location{Path: rel, Line: img.Line})
+	}
+
+	return file, nil
+}
+
+// iacImages returns the images in a file that the iac package understands,
+// like a Bazel file or a Nomad job, and the lines they're on
+func iacImages(path string) (File, error) {
+	refs, err := iac.FindReferences([]string{path})
+	if err != nil {
+		return File{}, err
+	}
+
+	var file File
+	for _, ref := range refs {
+		file.Images = append(file.Images, ref.Image)
+		file.Locations = append(file.Locations, mapper.Location{Line: ref.Line})
+	}
+
+	return file, nil
+}
@@ -0,0 +1,321 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/manifest"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/scanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockMapper struct {
+	mappings map[string]string
+}
+
+func (m *mockMapper) Map(img string) (*mapper.Mapping, error) {
+	mapping := &mapper.Mapping{Image: img}
+	if result, ok := m.mappings[img]; ok {
+		mapping.Results = []string{result}
+	}
+	return mapping, nil
+}
+
+func TestFind(t *testing.T) {
+	got, err := Find("testdata/repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []File{
+		{
+			Path:   filepath.Join(".circleci", "config.yml"),
+			Type:   TypeCI,
+			Images: []string{"cimg/node:22.0", "cimg/go:1.23", "redis:7"},
+		},
+		{
+			Path:   filepath.Join(".github", "workflows", "ci.yaml"),
+			Type:   TypeCI,
+			Images: []string{"python:3.12", "node:22", "postgres:16", "alpine:3.20"},
+		},
+		{
+			Path:   ".gitlab-ci.yml",
+			Type:   TypeCI,
+			Images: []string{"alpine:3.20", "golang:1.23", "docker:27-dind", "postgres:16"},
+		},
+		{
+			Path:   "Dockerfile",
+			Type:   TypeDockerfile,
+			Images: []string{"golang:1.23", "gcr.io/distroless/static"},
+			Locations: []mapper.Location{
+				{Path: "Dockerfile", Line: 1},
+				{Path: "Dockerfile", Line: 5},
+			},
+		},
+		{
+			Path:   "Earthfile",
+			Type:   TypeEarthly,
+			Images: []string{"golang:1.23"},
+		},
+		{
+			Path:      "MODULE.bazel",
+			Type:      TypeBazel,
+			Images:    []string{"gcr.io/distroless/base:nonroot"},
+			Locations: []mapper.Location{{Path: "MODULE.bazel", Line: 4}},
+		},
+		{
+			Path:   filepath.Join("charts", "web"),
+			Type:   TypeHelm,
+			Images: []string{"httpd:2.4"},
+		},
+		{
+			Path:   filepath.Join("deploy", "app.yaml"),
+			Type:   TypeManifest,
+			Images: []string{"nginx:1.25", "nginx:1.25"},
+			Locations: []mapper.Location{
+				{
+					Path:     "deploy/app.yaml",
+					Line:     10,
+					YAMLPath: "spec.template.spec.containers.[0].image",
+				},
+				{
+					Path:     "deploy/app.yaml",
+					Line:     12,
+					YAMLPath: "spec.template.spec.containers.[1].image",
+				},
+			},
+		},
+		{
+			Path:      filepath.Join("deploy", "worker.nomad"),
+			Type:      TypeNomad,
+			Images:    []string{"python:3.12"},
+			Locations: []mapper.Location{{Path: "deploy/worker.nomad", Line: 7}},
+		},
+		{
+			Path:   "docker-bake.hcl",
+			Type:   TypeBake,
+			Images: []string{"alpine:3.20"},
+		},
+		{
+			Path:   "docker-compose.yml",
+			Type:   TypeCompose,
+			Images: []string{"redis:7", "postgres:16"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+
+	// The files are read concurrently, but they're always returned in the
+	// same order
+	for _, workers := range []int{1, 16} {
+		got, err := Find("testdata/repo", WithWorkers(workers))
+		if err != nil {
+			t.Fatalf("unexpected error with %d workers: %s", workers, err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected files with %d workers (-want +got):\n%s", workers, diff)
+		}
+	}
+}
+
+func TestFindIgnore(t *testing.T) {
+	// The ignore files are written by the test, so that git doesn't apply
+	// them to the testdata
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":                      "build/\n*.generated.yaml\n",
+		".imagemapperignore":              "examples/\n",
+		"Dockerfile":                      "FROM golang:1.23\n",
+		"build/Dockerfile":                "FROM alpine:3.20\n",
+		"deploy/app.generated.yaml":       "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n    - image: nginx:1.25\n",
+		"examples/Dockerfile":             "FROM node:22\n",
+		"services/api/.imagemapperignore": "Dockerfile.dev\n",
+		"services/api/Dockerfile":         "FROM python:3.12\n",
+		"services/api/Dockerfile.dev":     "FROM python:3.12-slim\n",
+		"third_party/Dockerfile":          "FROM redis:7\n",
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("unexpected error creating directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+	}
+
+	got, err := Find(dir, WithIgnorePatterns("third_party"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var paths []string
+	for _, file := range got {
+		paths = append(paths, file.Path)
+	}
+	want := []string{"Dockerfile", filepath.Join("services", "api", "Dockerfile")}
+	if diff := cmp.Diff(want, paths); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindCustomResources(t *testing.T) {
+	got, err := Find("testdata/crs", WithManifestOptions(manifest.WithCustomResources(manifest.CustomResource{
+		APIVersion: "kafka.strimzi.io/v1beta2",
+		Kind:       "Kafka",
+		Paths:      []string{"spec.kafka.image"},
+	})))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []File{
+		{
+			Path:   "kafka.yaml",
+			Type:   TypeManifest,
+			Images: []string{"quay.io/strimzi/kafka:0.45.0-kafka-3.9.0"},
+			Locations: []mapper.Location{
+				{Path: "kafka.yaml", Line: 7, YAMLPath: "spec.kafka.image"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+}
+
+// paasScanner is a scanner for .paas files, where the images are the values of
+// the image and sidecar lines
+type paasScanner struct{}
+
+func (paasScanner) Name() string { return "paas" }
+
+func (paasScanner) Detect(path string) bool { return strings.HasSuffix(path, ".paas") }
+
+func (paasScanner) Extract(_ string, input []byte) ([]scanner.Image, error) {
+	var images []scanner.Image
+	for i, line := range strings.Split(string(input), "\n") {
+		for _, key := range []string{"image: ", "sidecar: "} {
+			if img, ok := strings.CutPrefix(line, key); ok {
+				images = append(images, scanner.Image{Image: img, Line: i + 1})
+			}
+		}
+	}
+	return images, nil
+}
+
+func (paasScanner) Rewrite(_ string, input []byte, _ map[string]string) ([]byte, error) {
+	return input, nil
+}
+
+func TestFindScanners(t *testing.T) {
+	got, err := Find("testdata/scanners", WithScanners(paasScanner{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []File{
+		{
+			Path:   "app.paas",
+			Type:   "paas",
+			Images: []string{"nginx:1.25", "redis:7"},
+			Locations: []mapper.Location{
+				{Path: "app.paas", Line: 2},
+				{Path: "app.paas", Line: 4},
+			},
+		},
+		{
+			Path:   "pod.yaml",
+			Type:   TypeManifest,
+			Images: []string{"python:3.12"},
+			Locations: []mapper.Location{
+				{Path: "pod.yaml", Line: 8, YAMLPath: "spec.containers.[0].image"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+}
+
+func TestMap(t *testing.T) {
+	m := &mockMapper{
+		mappings: map[string]string{
+			"nginx:1.25": "cgr.dev/chainguard/nginx:1.25",
+			"redis:7":    "cgr.dev/chainguard/redis:7",
+		},
+	}
+
+	got, err := Map(m, []File{
+		{
+			Path:   "deploy/app.yaml",
+			Type:   TypeManifest,
+			Images: []string{"nginx:1.25", "example/unknown:1.0", "nginx:1.25"},
+			Locations: []mapper.Location{
+				{Path: "deploy/app.yaml", Line: 10},
+				{Path: "deploy/app.yaml", Line: 12},
+				{Path: "deploy/app.yaml", Line: 14},
+			},
+		},
+		{
+			Path:   "docker-compose.yml",
+			Type:   TypeCompose,
+			Images: []string{"redis:7"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []Result{
+		{
+			Path: "deploy/app.yaml",
+			Type: TypeManifest,
+			Mappings: []*mapper.Mapping{
+				{
+					Image:       "nginx:1.25",
+					Results:     []string{"cgr.dev/chainguard/nginx:1.25"},
+					Occurrences: 2,
+					Locations: []mapper.Location{
+						{Path: "deploy/app.yaml", Line: 10},
+						{Path: "deploy/app.yaml", Line: 14},
+					},
+				},
+				{
+					Image:       "example/unknown:1.0",
+					Occurrences: 1,
+					Locations:   []mapper.Location{{Path: "deploy/app.yaml", Line: 12}},
+				},
+			},
+		},
+		{
+			Path: "docker-compose.yml",
+			Type: TypeCompose,
+			Mappings: []*mapper.Mapping{
+				{Image: "redis:7", Results: []string{"cgr.dev/chainguard/redis:7"}, Occurrences: 1},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+}
+
+func TestIsRemote(t *testing.T) {
+	for target, want := range map[string]bool{
+		"https://github.com/chainguard-dev/example": true,
+		"git@github.com:chainguard-dev/example.git": true,
+		"ssh://git@example.com/repo.git":            true,
+		".":                                         false,
+		"./repo":                                    false,
+		"/tmp/repo":                                 false,
+	} {
+		if got := IsRemote(target); got != want {
+			t.Errorf("IsRemote(%q) = %t, want %t", target, got, want)
+		}
+	}
+}
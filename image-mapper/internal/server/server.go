@@ -0,0 +1,300 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/dockerfile"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/helm"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+)
+
+// maxBodySize is the largest request body we'll read
+const maxBodySize = 10 << 20
+
+// Mappers are the mappers that serve each endpoint. They hold the catalog in
+// memory, so requests don't fetch it.
+type Mappers struct {
+	// Images maps the references posted to /map
+	Images mapper.Mapper
+
+	// Dockerfile maps the images in Dockerfiles posted to /map/dockerfile
+	Dockerfile mapper.Mapper
+
+	// HelmValues maps the images in values posted to /map/helm-values
+	HelmValues mapper.Mapper
+}
+
+// NewMappers fetches the catalog and constructs the mappers for each
+// endpoint. The Dockerfile and Helm mappers are configured the same way as
+// the map dockerfile and map helm-values commands.
+func NewMappers(ctx context.Context, opts ...mapper.Option) (*Mappers, error) {
+	m, err := mapper.NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating mapper: %w", err)
+	}
+	dm, err := dockerfile.NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating dockerfile mapper: %w", err)
+	}
+	hm, err := helm.NewMapper(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating helm mapper: %w", err)
+	}
+
+	return &Mappers{
+		Images:     m,
+		Dockerfile: dm,
+		HelmValues: hm,
+	}, nil
+}
+
+// Server serves the mappers over HTTP
+type Server struct {
+	mu      sync.RWMutex
+	mappers *Mappers
+}
+
+// New returns a server that maps images with the provided mappers
+func New(mappers *Mappers) *Server {
+	return &Server{mappers: mappers}
+}
+
+// SetMappers replaces the mappers, i.e when the catalog has been refreshed.
+// Requests that are in progress finish with the previous mappers.
+func (s *Server) SetMappers(mappers *Mappers) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mappers = mappers
+}
+
+// Refresh fetches the catalog and replaces the mappers at every interval
+// until the context is cancelled. If a refresh fails, the server keeps
+// using the mappers it has. The options are the ones the mappers are
+// constructed with, so include mapper.WithRefresh to bypass a cache that may
+// not have expired yet.
+func (s *Server) Refresh(ctx context.Context, interval time.Duration, opts ...mapper.Option) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		mappers, err := NewMappers(ctx, opts...)
+		if err != nil {
+			slog.Warn("refreshing the catalog", "err", err)
+			continue
+		}
+		s.SetMappers(mappers)
+		slog.Info("refreshed the catalog")
+	}
+}
+
+func (s *Server) getMappers() *Mappers {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.mappers
+}
+
+// Handler returns the handler that serves the API:
+//
+//   - POST /map maps a single image, {"image": "..."}, or a batch of images,
+//     {"images": ["...", "..."]}, and responds with the mappings as JSON
+//   - POST /map/dockerfile maps the Dockerfile in the body and responds with
+//     the mapped Dockerfile
+//   - POST /map/helm-values maps the values in the body and responds with the
+//     mapped values
+//   - GET /healthz responds with 200 OK
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /map", s.handleMap)
+	mux.HandleFunc("POST /map/dockerfile", s.handleDockerfile)
+	mux.HandleFunc("POST /map/helm-values", s.handleHelmValues)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+
+	return mux
+}
+
+// MapRequest is the body of a request to /map. Either Image or Images must
+// be set.
+type MapRequest struct {
+	Image  string   `json:"image,omitempty"`
+	Images []string `json:"images,omitempty"`
+}
+
+// MapResponse is the body of a response from /map
+type MapResponse struct {
+	Mappings []*mapper.Mapping `json:"mappings"`
+}
+
+func (s *Server) handleMap(w http.ResponseWriter, r *http.Request) {
+	var req MapRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		httpError(w, fmt.Errorf("decoding request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	images := req.Images
+	if req.Image != "" {
+		images = append([]string{req.Image}, images...)
+	}
+	if len(images) == 0 {
+		httpError(w, fmt.Errorf("no images in request"), http.StatusBadRequest)
+		return
+	}
+
+	m := s.getMappers().Images
+	resp := MapResponse{Mappings: []*mapper.Mapping{}}
+	for _, img := range images {
+		mapping, err := m.Map(img)
+		if err != nil {
+			httpError(w, fmt.Errorf("mapping %s: %w", img, err), http.StatusBadRequest)
+			return
+		}
+		resp.Mappings = append(resp.Mappings, mapping)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Warn("writing response", "err", err)
+	}
+}
+
+// handleDockerfile maps the Dockerfile in the body. Build args can be
+// provided with build-arg=KEY=VALUE query parameters and annotate=true adds
+// comments to the unmapped images.
+func (s *Server) handleDockerfile(w http.ResponseWriter, r *http.Request) {
+	input, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	dopts := dockerfile.Options{
+		BuildArgs: map[string]string{},
+	}
+	for _, arg := range query["build-arg"] {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			httpError(w, fmt.Errorf("invalid build-arg %q, must be KEY=VALUE", arg), http.StatusBadRequest)
+			return
+		}
+		dopts.BuildArgs[k] = v
+	}
+	annotate, err := boolParam(query.Get("annotate"))
+	if err != nil {
+		httpError(w, fmt.Errorf("invalid annotate: %w", err), http.StatusBadRequest)
+		return
+	}
+	dopts.Annotate = annotate
+
+	output, err := dockerfile.MapWith(s.getMappers().Dockerfile, input, dopts)
+	if err != nil {
+		httpError(w, fmt.Errorf("mapping dockerfile: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(output)
+}
+
+// handleHelmValues maps the values in the body. The app-version, full and
+// global-registry query parameters set the equivalent values options and
+// key=PART=PATTERN adds patterns that match image related keys.
+func (s *Server) handleHelmValues(w http.ResponseWriter, r *http.Request) {
+	input, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	vopts := helm.ValuesOptions{
+		AppVersion: query.Get("app-version"),
+	}
+	for _, key := range query["key"] {
+		part, pattern, ok := strings.Cut(key, "=")
+		if !ok {
+			httpError(w, fmt.Errorf("invalid key %q, must be PART=PATTERN", key), http.StatusBadRequest)
+			return
+		}
+		if err := vopts.Keys.Add(part, pattern); err != nil {
+			httpError(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+	var err error
+	if vopts.Full, err = boolParam(query.Get("full")); err != nil {
+		httpError(w, fmt.Errorf("invalid full: %w", err), http.StatusBadRequest)
+		return
+	}
+	if vopts.GlobalRegistry, err = boolParam(query.Get("global-registry")); err != nil {
+		httpError(w, fmt.Errorf("invalid global-registry: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	output, err := helm.MapValuesWith(s.getMappers().HelmValues, input, vopts)
+	if err != nil {
+		httpError(w, fmt.Errorf("mapping values: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(output)
+}
+
+// readBody reads the request body. If it can't, it writes an error response
+// and returns false.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	input, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		httpError(w, fmt.Errorf("reading request: %w", err), status)
+		return nil, false
+	}
+	if len(input) == 0 {
+		httpError(w, fmt.Errorf("request body is empty"), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return input, true
+}
+
+// boolParam parses an optional boolean query parameter
+func boolParam(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(v)
+}
+
+// ErrorResponse is the body of a response when a request fails
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func httpError(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+}
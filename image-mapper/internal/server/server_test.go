@@ -0,0 +1,165 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockMapper struct {
+	mappings map[string]string
+}
+
+func (m *mockMapper) Map(img string) (*mapper.Mapping, error) {
+	mapping := &mapper.Mapping{Image: img}
+	if result, ok := m.mappings[img]; ok {
+		mapping.Results = []string{result}
+	}
+	return mapping, nil
+}
+
+func TestHandler(t *testing.T) {
+	m := &mockMapper{mappings: map[string]string{
+		"nginx":         "cgr.dev/chainguard/nginx:latest",
+		"python:3.12":   "cgr.dev/chainguard/python:3.12-dev",
+		"bitnami/redis": "cgr.dev/chainguard/redis:latest",
+	}}
+	srv := New(&Mappers{Images: m, Dockerfile: m, HelmValues: m})
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "single image",
+			method:     http.MethodPost,
+			path:       "/map",
+			body:       `{"image": "nginx"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"mappings":[{"image":"nginx","results":["cgr.dev/chainguard/nginx:latest"]}]}` + "\n",
+		},
+		{
+			name:       "batch",
+			method:     http.MethodPost,
+			path:       "/map",
+			body:       `{"images": ["nginx", "unknown"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"mappings":[{"image":"nginx","results":["cgr.dev/chainguard/nginx:latest"]},{"image":"unknown"}]}` + "\n",
+		},
+		{
+			name:       "no images",
+			method:     http.MethodPost,
+			path:       "/map",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"no images in request"}` + "\n",
+		},
+		{
+			name:       "invalid json",
+			method:     http.MethodPost,
+			path:       "/map",
+			body:       `nginx`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"decoding request: invalid character 'g' in literal null (expecting 'u')"}` + "\n",
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			path:       "/map",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   "Method Not Allowed\n",
+		},
+		{
+			name:       "dockerfile",
+			method:     http.MethodPost,
+			path:       "/map/dockerfile?build-arg=VERSION=3.12",
+			body:       "ARG VERSION=3.11\nFROM python:${VERSION}\n",
+			wantStatus: http.StatusOK,
+			wantBody:   "ARG VERSION=3.11\nFROM cgr.dev/chainguard/python:3.12-dev\n",
+		},
+		{
+			name:       "dockerfile invalid build arg",
+			method:     http.MethodPost,
+			path:       "/map/dockerfile?build-arg=VERSION",
+			body:       "FROM python:3.12\n",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid build-arg \"VERSION\", must be KEY=VALUE"}` + "\n",
+		},
+		{
+			name:       "dockerfile empty",
+			method:     http.MethodPost,
+			path:       "/map/dockerfile",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"request body is empty"}` + "\n",
+		},
+		{
+			name:       "helm values",
+			method:     http.MethodPost,
+			path:       "/map/helm-values",
+			body:       "image:\n  repository: bitnami/redis\n  tag: \"\"\n",
+			wantStatus: http.StatusOK,
+			wantBody:   "image:\n    repository: cgr.dev/chainguard/redis # Original: bitnami/redis\n",
+		},
+		{
+			name:       "helm values full",
+			method:     http.MethodPost,
+			path:       "/map/helm-values?full=true",
+			body:       "# The image\nimage:\n  repository: bitnami/redis\n  tag: \"\"\nreplicas: 1\n",
+			wantStatus: http.StatusOK,
+			wantBody:   "# The image\nimage:\n  repository: cgr.dev/chainguard/redis\n  tag: \"\"\nreplicas: 1\n",
+		},
+		{
+			name:       "helm values invalid full",
+			method:     http.MethodPost,
+			path:       "/map/helm-values?full=maybe",
+			body:       "image: nginx\n",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid full: strconv.ParseBool: parsing \"maybe\": invalid syntax"}` + "\n",
+		},
+		{
+			name:       "healthz",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("unexpected status: got %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if diff := cmp.Diff(tc.wantBody, rec.Body.String()); diff != "" {
+				t.Errorf("unexpected body (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetMappers(t *testing.T) {
+	srv := New(&Mappers{Images: &mockMapper{}})
+	srv.SetMappers(&Mappers{Images: &mockMapper{mappings: map[string]string{
+		"nginx": "cgr.dev/chainguard/nginx:latest",
+	}}})
+
+	req := httptest.NewRequest(http.MethodPost, "/map", strings.NewReader(`{"image": "nginx"}`))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+
+	want := `{"mappings":[{"image":"nginx","results":["cgr.dev/chainguard/nginx:latest"]}]}` + "\n"
+	if diff := cmp.Diff(want, rec.Body.String()); diff != "" {
+		t.Errorf("unexpected body (-want +got):\n%s", diff)
+	}
+}
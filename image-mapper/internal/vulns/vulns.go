@@ -0,0 +1,181 @@
+package vulns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/progress"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Scanner counts the vulnerabilities in an image
+type Scanner interface {
+	Scan(ctx context.Context, image string) (mapper.VulnerabilityCounts, error)
+}
+
+// Grype scans images with grype, which must be installed
+type Grype struct {
+	// Path is the path to the grype binary. Defaults to grype on the
+	// PATH.
+	Path string
+
+	// InsecureRegistries are the registries, including the port if
+	// there is one, i.e registry.local:5000, that images are pulled from
+	// over HTTP, rather than HTTPS
+	InsecureRegistries []string
+}
+
+// Scan scans the image with grype. The image is pulled from the registry,
+// rather than the local Docker daemon.
+func (g *Grype) Scan(ctx context.Context, image string) (mapper.VulnerabilityCounts, error) {
+	path := g.Path
+	if path == "" {
+		path = "grype"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "--quiet", "--output=json", "registry:"+image)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if g.insecure(image) {
+		cmd.Env = append(os.Environ(), "GRYPE_REGISTRY_INSECURE_USE_HTTP=true")
+	}
+	if err := cmd.Run(); err != nil {
+		return mapper.VulnerabilityCounts{}, fmt.Errorf("running grype: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseGrype(&stdout)
+}
+
+// insecure returns true if the image is in one of the insecure registries
+func (g *Grype) insecure(image string) bool {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return false
+	}
+
+	return slices.Contains(g.InsecureRegistries, ref.Context().RegistryStr())
+}
+
+// grypeOutput is the part of grype's JSON output that we need
+type grypeOutput struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+		} `json:"vulnerability"`
+	} `json:"matches"`
+}
+
+// parseGrype counts the vulnerabilities in grype's JSON output. A
+// vulnerability that matches more than one package is only counted once.
+func parseGrype(r io.Reader) (mapper.VulnerabilityCounts, error) {
+	var out grypeOutput
+	if err := json.NewDecoder(r).Decode(&out); err != nil {
+		return mapper.VulnerabilityCounts{}, fmt.Errorf("decoding grype output: %w", err)
+	}
+
+	var counts mapper.VulnerabilityCounts
+	seen := map[string]struct{}{}
+	for _, match := range out.Matches {
+		v := match.Vulnerability
+		if _, ok := seen[v.ID]; ok {
+			continue
+		}
+		seen[v.ID] = struct{}{}
+
+		switch strings.ToLower(v.Severity) {
+		case "critical":
+			counts.Critical++
+		case "high":
+			counts.High++
+		case "medium":
+			counts.Medium++
+		case "low":
+			counts.Low++
+		default:
+			counts.Other++
+		}
+		counts.Total++
+	}
+
+	return counts, nil
+}
+
+// Annotator adds vulnerability counts to mappings. Each image is only
+// scanned once, so images that appear in many mappings, like a common
+// result, aren't scanned again.
+type Annotator struct {
+	scanner Scanner
+	cache   map[string]scanResult
+}
+
+type scanResult struct {
+	counts mapper.VulnerabilityCounts
+	err    error
+}
+
+// NewAnnotator returns an annotator that scans images with the scanner
+func NewAnnotator(scanner Scanner) *Annotator {
+	return &Annotator{
+		scanner: scanner,
+		cache:   map[string]scanResult{},
+	}
+}
+
+// Annotate scans the image and the first result of each mapping and sets
+// the vulnerabilities of the mapping. Mappings without results are skipped.
+// Images that can't be scanned are logged as warnings and the mapping is
+// left without vulnerabilities, so that one image doesn't stop the report.
+func (a *Annotator) Annotate(ctx context.Context, mappings ...*mapper.Mapping) {
+	p := progress.Start("scanning images", len(mappings))
+	defer p.Done()
+
+	for _, m := range mappings {
+		a.annotate(ctx, m)
+		p.Add(1)
+	}
+}
+
+func (a *Annotator) annotate(ctx context.Context, m *mapper.Mapping) {
+	if len(m.Results) == 0 {
+		return
+	}
+
+	image, err := a.scan(ctx, m.Image)
+	if err != nil {
+		slog.Warn("scanning for vulnerabilities", "image", m.Image, "err", err)
+		return
+	}
+	result, err := a.scan(ctx, m.Results[0])
+	if err != nil {
+		slog.Warn("scanning for vulnerabilities", "image", m.Results[0], "err", err)
+		return
+	}
+
+	m.Vulnerabilities = &mapper.Vulnerabilities{
+		Image:  image,
+		Result: result,
+	}
+}
+
+func (a *Annotator) scan(ctx context.Context, image string) (mapper.VulnerabilityCounts, error) {
+	if r, ok := a.cache[image]; ok {
+		return r.counts, r.err
+	}
+
+	slog.Info("scanning for vulnerabilities", "image", image)
+	counts, err := a.scanner.Scan(ctx, image)
+	a.cache[image] = scanResult{counts: counts, err: err}
+
+	return counts, err
+}
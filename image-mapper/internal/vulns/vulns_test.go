@@ -0,0 +1,133 @@
+package vulns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/mapper"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGrype(t *testing.T) {
+	input := `{
+  "matches": [
+    {"vulnerability": {"id": "CVE-2024-0001", "severity": "Critical"}},
+    {"vulnerability": {"id": "CVE-2024-0002", "severity": "High"}},
+    {"vulnerability": {"id": "CVE-2024-0002", "severity": "High"}},
+    {"vulnerability": {"id": "CVE-2024-0003", "severity": "Medium"}},
+    {"vulnerability": {"id": "CVE-2024-0004", "severity": "Low"}},
+    {"vulnerability": {"id": "CVE-2024-0005", "severity": "Negligible"}},
+    {"vulnerability": {"id": "CVE-2024-0006", "severity": "Unknown"}}
+  ]
+}`
+	want := mapper.VulnerabilityCounts{
+		Critical: 1,
+		High:     1,
+		Medium:   1,
+		Low:      1,
+		Other:    2,
+		Total:    6,
+	}
+
+	got, err := parseGrype(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected counts (-want +got):\n%s", diff)
+	}
+}
+
+type mockScanner struct {
+	counts map[string]mapper.VulnerabilityCounts
+	scans  []string
+}
+
+func (s *mockScanner) Scan(ctx context.Context, image string) (mapper.VulnerabilityCounts, error) {
+	s.scans = append(s.scans, image)
+	counts, ok := s.counts[image]
+	if !ok {
+		return mapper.VulnerabilityCounts{}, fmt.Errorf("image not found")
+	}
+	return counts, nil
+}
+
+func TestAnnotate(t *testing.T) {
+	scanner := &mockScanner{counts: map[string]mapper.VulnerabilityCounts{
+		"nginx:1.25":                    {High: 2, Medium: 3, Total: 5},
+		"nginx:1.26":                    {High: 1, Total: 1},
+		"cgr.dev/chainguard/nginx:1.25": {},
+		"cgr.dev/chainguard/nginx:1.26": {Low: 1, Total: 1},
+	}}
+	mappings := []*mapper.Mapping{
+		{Image: "nginx:1.25", Results: []string{"cgr.dev/chainguard/nginx:1.25"}},
+		{Image: "docker.io/nginx:1.25", Results: []string{"cgr.dev/chainguard/nginx:1.25"}},
+		{Image: "nginx:1.26", Results: []string{"cgr.dev/chainguard/nginx:1.26", "cgr.dev/chainguard/nginx-fips:1.26"}},
+		{Image: "nonexistent"},
+	}
+
+	NewAnnotator(scanner).Annotate(context.Background(), mappings...)
+
+	want := []*mapper.Mapping{
+		{
+			Image:   "nginx:1.25",
+			Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+			Vulnerabilities: &mapper.Vulnerabilities{
+				Image:  mapper.VulnerabilityCounts{High: 2, Medium: 3, Total: 5},
+				Result: mapper.VulnerabilityCounts{},
+			},
+		},
+		{
+			Image:   "docker.io/nginx:1.25",
+			Results: []string{"cgr.dev/chainguard/nginx:1.25"},
+		},
+		{
+			Image:   "nginx:1.26",
+			Results: []string{"cgr.dev/chainguard/nginx:1.26", "cgr.dev/chainguard/nginx-fips:1.26"},
+			Vulnerabilities: &mapper.Vulnerabilities{
+				Image:  mapper.VulnerabilityCounts{High: 1, Total: 1},
+				Result: mapper.VulnerabilityCounts{Low: 1, Total: 1},
+			},
+		},
+		{
+			Image: "nonexistent",
+		},
+	}
+	if diff := cmp.Diff(want, mappings); diff != "" {
+		t.Errorf("unexpected mappings (-want +got):\n%s", diff)
+	}
+
+	// Each image is only scanned once
+	wantScans := []string{
+		"nginx:1.25",
+		"cgr.dev/chainguard/nginx:1.25",
+		"docker.io/nginx:1.25",
+		"nginx:1.26",
+		"cgr.dev/chainguard/nginx:1.26",
+	}
+	if diff := cmp.Diff(wantScans, scanner.scans); diff != "" {
+		t.Errorf("unexpected scans (-want +got):\n%s", diff)
+	}
+}
+
+func TestGrypeInsecure(t *testing.T) {
+	g := &Grype{InsecureRegistries: []string{"registry.local:5000", "localhost:5000"}}
+	testCases := map[string]bool{
+		"registry.local:5000/team/app:v1":  true,
+		"localhost:5000/nginx":             true,
+		"registry.local/team/app:v1":       false,
+		"registry.local:5001/team/app:v1":  false,
+		"nginx:1.25":                       false,
+		"cgr.dev/chainguard/nginx:latest":  false,
+		"registry.local:5000/INVALID:v1.0": false,
+	}
+	for image, want := range testCases {
+		t.Run(image, func(t *testing.T) {
+			if got := g.insecure(image); got != want {
+				t.Errorf("unexpected result: got %t, want %t", got, want)
+			}
+		})
+	}
+}
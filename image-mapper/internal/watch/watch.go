@@ -0,0 +1,138 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"time"
+
+	"github.com/chainguard-dev/customer-success/scripts/image-mapper/internal/diff"
+)
+
+// DefaultInterval is how often the files are checked for changes by default
+const DefaultInterval = time.Second
+
+// Options configures how files are watched
+type Options struct {
+	// Interval is how often the files are checked for changes. Defaults
+	// to DefaultInterval.
+	Interval time.Duration
+}
+
+// Func produces the output that is compared between runs
+type Func func(ctx context.Context) ([]byte, error)
+
+// Run calls fn and writes its output to w. Then, each time the files at the
+// paths change, it calls fn again and writes a diff of the new output against
+// the previous output. Directories are watched recursively. It runs until the
+// context is cancelled.
+//
+// Errors returned by fn are logged, rather than ending the watch, so that they
+// can be fixed while it's running.
+func Run(ctx context.Context, w io.Writer, paths []string, opts Options, fn Func) error {
+	interval := opts.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+
+	state, err := snapshot(paths)
+	if err != nil {
+		return err
+	}
+
+	previous, err := fn(ctx)
+	if err != nil {
+		slog.Warn(err.Error())
+	}
+	if _, err := w.Write(previous); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	slog.Info("watching for changes", "paths", paths)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		next, err := snapshot(paths)
+		if err != nil {
+			slog.Warn(err.Error())
+			continue
+		}
+		if maps.Equal(state, next) {
+			continue
+		}
+		state = next
+
+		output, err := fn(ctx)
+		if err != nil {
+			slog.Warn(err.Error())
+			continue
+		}
+
+		changes, err := diff.Unified("previous", "current", previous, output)
+		if err != nil {
+			return err
+		}
+		if changes == "" {
+			slog.Info("files changed, but the output didn't")
+			continue
+		}
+		if _, err := io.WriteString(w, changes); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+		previous = output
+	}
+}
+
+// fileState is what we compare to decide if a file has changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// skipDirs are directories that we don't watch
+var skipDirs = map[string]struct{}{
+	".git":         {},
+	"node_modules": {},
+}
+
+// snapshot returns the state of the files at the paths. Directories are
+// walked recursively.
+func snapshot(paths []string) (map[string]fileState, error) {
+	state := map[string]fileState{}
+	for _, path := range paths {
+		if err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if _, ok := skipDirs[d.Name()]; ok && p != path {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			state[p] = fileState{modTime: info.ModTime(), size: info.Size()}
+
+			return nil
+		}); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("checking files: %s: %w", path, err)
+		}
+	}
+
+	return state, nil
+}
@@ -0,0 +1,91 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// syncBuffer is a buffer that can be written to and read from different
+// goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Dockerfile")
+	if err := os.WriteFile(path, []byte("FROM nginx:1.25\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	out := &syncBuffer{}
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, out, []string{dir}, Options{Interval: 10 * time.Millisecond}, func(ctx context.Context) ([]byte, error) {
+			input, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.ReplaceAll(input, []byte("FROM "), []byte("FROM cgr.dev/chainguard/")), nil
+		})
+	}()
+
+	waitFor := func(s string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(out.String(), s) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %q in output:\n%s", s, out.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor("FROM cgr.dev/chainguard/nginx:1.25\n")
+
+	if err := os.WriteFile(path, []byte("FROM nginx:1.27\nRUN true\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	waitFor("+RUN true\n")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `FROM cgr.dev/chainguard/nginx:1.25
+--- previous
++++ current
+@@ -1 +1,2 @@
+-FROM cgr.dev/chainguard/nginx:1.25
++FROM cgr.dev/chainguard/nginx:1.27
++RUN true
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
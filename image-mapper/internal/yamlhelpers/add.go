@@ -2,7 +2,11 @@ package yamlhelpers
 
 import "gopkg.in/yaml.v3"
 
-// AddNode adds a node at the specified path
+// AddNode adds a node at the specified path.
+//
+// Mappings are created along the path if they don't exist. Items in a
+// sequence can be referred to by their index, i.e items.[0].name, but only if
+// they already exist.
 func AddNode(path []string, node *yaml.Node, add *yaml.Node) {
 	if add == nil {
 		return
@@ -11,6 +15,20 @@ func AddNode(path []string, node *yaml.Node, add *yaml.Node) {
 	current := node
 
 	for i, key := range path {
+		// Items in sequences can be replaced or descended into, but
+		// not created
+		if index, ok := ParseIndex(key); ok && current.Kind == yaml.SequenceNode {
+			if index >= len(current.Content) {
+				return
+			}
+			if i == len(path)-1 {
+				current.Content[index] = add
+				return
+			}
+			current = current.Content[index]
+			continue
+		}
+
 		// If this is the last element of the path then add the node or
 		// replace the existing node
 		if i == len(path)-1 {
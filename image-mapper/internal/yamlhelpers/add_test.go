@@ -112,6 +112,47 @@ key3: value3
 key2: value2
 key3: value3
 key4: value4
+`,
+		},
+		{
+			name: "replace in sequence item",
+			initial: `items:
+    - name: one
+      image: old
+    - name: two
+      image: old
+`,
+			path:     []string{"items", "[1]", "image"},
+			addValue: "new",
+			expected: `items:
+    - name: one
+      image: old
+    - name: two
+      image: new
+`,
+		},
+		{
+			name: "replace sequence item",
+			initial: `items:
+    - one
+    - two
+`,
+			path:     []string{"items", "[0]"},
+			addValue: "new",
+			expected: `items:
+    - new
+    - two
+`,
+		},
+		{
+			name: "ignore missing sequence item",
+			initial: `items:
+    - one
+`,
+			path:     []string{"items", "[1]", "image"},
+			addValue: "new",
+			expected: `items:
+    - one
 `,
 		},
 	}
@@ -0,0 +1,28 @@
+package yamlhelpers
+
+import "gopkg.in/yaml.v3"
+
+// DeleteNode deletes the key at the specified path from its mapping. Any
+// mappings along the path that are left empty are deleted too.
+func DeleteNode(path []string, node *yaml.Node) {
+	if len(path) == 0 {
+		return
+	}
+
+	parent := LookupNode(path[:len(path)-1], node)
+	if parent == nil || parent.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value != path[len(path)-1] {
+			continue
+		}
+		parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+		break
+	}
+
+	if len(parent.Content) == 0 {
+		DeleteNode(path[:len(path)-1], node)
+	}
+}
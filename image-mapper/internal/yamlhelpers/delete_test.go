@@ -0,0 +1,68 @@
+package yamlhelpers
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDeleteNode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		initial  string
+		path     []string
+		expected string
+	}{
+		{
+			name: "delete key",
+			initial: `image:
+    registry: cgr.dev
+    repository: chainguard/nginx
+`,
+			path: []string{"image", "registry"},
+			expected: `image:
+    repository: chainguard/nginx
+`,
+		},
+		{
+			name: "delete empty parents",
+			initial: `other: value
+parent:
+    image:
+        registry: cgr.dev
+`,
+			path: []string{"parent", "image", "registry"},
+			expected: `other: value
+`,
+		},
+		{
+			name: "ignore missing key",
+			initial: `image:
+    repository: chainguard/nginx
+`,
+			path: []string{"image", "registry"},
+			expected: `image:
+    repository: chainguard/nginx
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tc.initial), &node); err != nil {
+				t.Fatalf("failed to unmarshal initial yaml: %v", err)
+			}
+
+			DeleteNode(tc.path, node.Content[0])
+
+			out, err := yaml.Marshal(&node)
+			if err != nil {
+				t.Fatalf("failed to marshal result: %v", err)
+			}
+			if string(out) != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package yamlhelpers
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Edit is a change to the value of a scalar node
+type Edit struct {
+	Node  *yaml.Node
+	Value string
+}
+
+// EditScalars applies the edits to the input the nodes were decoded from,
+// replacing each scalar where it appears in the text so that the rest of the
+// input, like comments and formatting, is left as it is. Block scalars can't
+// be edited, so they're skipped with a warning.
+func EditScalars(input []byte, edits []Edit) []byte {
+	lines := strings.Split(string(input), "\n")
+
+	// Apply the edits from the end, so that the columns of the earlier
+	// edits on the same line are still correct
+	slices.SortFunc(edits, func(a, b Edit) int {
+		if a.Node.Line != b.Node.Line {
+			return b.Node.Line - a.Node.Line
+		}
+		return b.Node.Column - a.Node.Column
+	})
+	// A node that's referred to by aliases can be edited through each of
+	// them, but it appears once in the input
+	edited := map[*yaml.Node]bool{}
+	for _, edit := range edits {
+		if edited[edit.Node] {
+			continue
+		}
+		edited[edit.Node] = true
+
+		line, col := edit.Node.Line-1, edit.Node.Column-1
+		if line < 0 || line >= len(lines) || col < 0 || col > len(lines[line]) {
+			slog.Warn("can't find the value in the input, skipping", "value", edit.Node.Value)
+			continue
+		}
+
+		// The position of a scalar with an anchor is the position of
+		// the anchor, i.e &image nginx:1.25
+		if anchor := "&" + edit.Node.Anchor; edit.Node.Anchor != "" && strings.HasPrefix(lines[line][col:], anchor) {
+			col += len(anchor)
+			col += len(lines[line][col:]) - len(strings.TrimLeft(lines[line][col:], " \t"))
+		}
+
+		rest := lines[line][col:]
+		raw, ok := scalarToken(rest, edit.Node)
+		if !ok {
+			slog.Warn("can't edit the value, skipping", "value", edit.Node.Value, "line", edit.Node.Line)
+			continue
+		}
+
+		lines[line] = lines[line][:col] + formatScalar(edit.Value, edit.Node.Style) + rest[len(raw):]
+	}
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// scalarToken returns the text of the scalar at the start of the line
+func scalarToken(line string, node *yaml.Node) (string, bool) {
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return line[:i+1], true
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(line); i++ {
+			if line[i] != '\'' {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			return line[:i+1], true
+		}
+	case 0:
+		if node.Value != "" && strings.HasPrefix(line, node.Value) {
+			return node.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// formatScalar formats a value in the quoting style of the original scalar. A
+// plain value is quoted if it would otherwise be read as something other than
+// a string, like a number.
+func formatScalar(value string, style yaml.Style) string {
+	switch style {
+	case yaml.DoubleQuotedStyle:
+		return strconv.Quote(value)
+	case yaml.SingleQuotedStyle:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+
+	var v any
+	if err := yaml.Unmarshal([]byte(value), &v); err != nil || value == "" {
+		return strconv.Quote(value)
+	}
+	if _, ok := v.(string); !ok {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
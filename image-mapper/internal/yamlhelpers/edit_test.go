@@ -0,0 +1,87 @@
+package yamlhelpers
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFormatScalar(t *testing.T) {
+	testCases := []struct {
+		value string
+		style yaml.Style
+		want  string
+	}{
+		{value: "cgr.dev/chainguard/nginx", want: "cgr.dev/chainguard/nginx"},
+		{value: "1.30", want: `"1.30"`},
+		{value: "true", want: `"true"`},
+		{value: "", want: `""`},
+		{value: "1.25.5", want: "1.25.5"},
+		{value: "1.30", style: yaml.DoubleQuotedStyle, want: `"1.30"`},
+		{value: "it's", style: yaml.SingleQuotedStyle, want: `'it''s'`},
+	}
+	for _, tc := range testCases {
+		if got := formatScalar(tc.value, tc.style); got != tc.want {
+			t.Errorf("unexpected value for %q: wanted %s but got %s", tc.value, tc.want, got)
+		}
+	}
+}
+
+func TestEditScalars(t *testing.T) {
+	input := []byte(`a: {repository: "nginx", tag: '1.25'}
+b: busybox # comment
+c: >-
+  folded
+`)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(input, &doc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	root := doc.Content[0]
+
+	got := EditScalars(input, []Edit{
+		{Node: root.Content[1].Content[1], Value: "cgr.dev/chainguard/nginx"},
+		{Node: root.Content[1].Content[3], Value: "1.25.5"},
+		{Node: root.Content[3], Value: "cgr.dev/chainguard/busybox"},
+		// Block scalars can't be edited, so they're left alone
+		{Node: root.Content[5], Value: "unfolded"},
+	})
+
+	want := `a: {repository: "cgr.dev/chainguard/nginx", tag: '1.25.5'}
+b: cgr.dev/chainguard/busybox # comment
+c: >-
+  folded
+`
+	if string(got) != want {
+		t.Errorf("unexpected output:\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestEditScalarsAnchors(t *testing.T) {
+	input := []byte(`image: &image nginx:1.25
+sidecar:
+  image: *image
+`)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(input, &doc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	root := doc.Content[0]
+
+	// The anchored value is edited once, whichever path it's edited
+	// through
+	got := EditScalars(input, []Edit{
+		{Node: LookupNode([]string{"image"}, root), Value: "cgr.dev/chainguard/nginx:1.25"},
+		{Node: LookupNode([]string{"sidecar", "image"}, root), Value: "cgr.dev/chainguard/nginx:1.25"},
+	})
+
+	want := `image: &image cgr.dev/chainguard/nginx:1.25
+sidecar:
+  image: *image
+`
+	if string(got) != want {
+		t.Errorf("unexpected output:\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
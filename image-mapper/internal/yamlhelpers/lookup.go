@@ -0,0 +1,59 @@
+package yamlhelpers
+
+import "gopkg.in/yaml.v3"
+
+// LookupNode returns the node at the specified path, or nil if there isn't
+// one. Items in a sequence are referred to by their index, i.e items.[0].name.
+//
+// Aliases along the path are resolved and keys merged into mappings with <<
+// are found too.
+func LookupNode(path []string, node *yaml.Node) *yaml.Node {
+	current := Resolve(node)
+	for _, key := range path {
+		if current == nil {
+			return nil
+		}
+
+		switch current.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			fields := Fields(current)
+			for i := 0; i+1 < len(fields); i += 2 {
+				if fields[i].Value == key {
+					next = fields[i+1]
+					break
+				}
+			}
+			current = next
+		case yaml.SequenceNode:
+			index, ok := ParseIndex(key)
+			if !ok || index >= len(current.Content) {
+				return nil
+			}
+			current = Resolve(current.Content[index])
+		default:
+			return nil
+		}
+	}
+
+	return current
+}
+
+// CopyNode returns a deep copy of a node. Aliases are replaced by copies of
+// the nodes they refer to and anchors are dropped, so that the copy can be
+// encoded on its own.
+func CopyNode(node *yaml.Node) *yaml.Node {
+	node = Resolve(node)
+	if node == nil {
+		return nil
+	}
+
+	copied := *node
+	copied.Anchor = ""
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = CopyNode(child)
+	}
+
+	return &copied
+}
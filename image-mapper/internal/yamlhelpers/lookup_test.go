@@ -0,0 +1,102 @@
+package yamlhelpers
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLookupNode(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(`
+parent:
+  child: value
+  items:
+    - name: one
+    - name: two
+defaults: &defaults
+  pullPolicy: Always
+list: &items [three]
+aliased:
+  <<: *defaults
+  items: *items
+`), &doc); err != nil {
+		t.Fatalf("failed to unmarshal yaml: %v", err)
+	}
+	root := doc.Content[0]
+
+	testCases := []struct {
+		path []string
+		want string
+	}{
+		{path: []string{"parent", "child"}, want: "value"},
+		{path: []string{"parent", "items", "[1]", "name"}, want: "two"},
+		{path: []string{"parent", "missing"}},
+		{path: []string{"parent", "items", "[2]", "name"}},
+		{path: []string{"parent", "items", "name"}},
+		{path: []string{"parent", "child", "grandchild"}},
+		{path: []string{"aliased", "pullPolicy"}, want: "Always"},
+		{path: []string{"aliased", "items", "[0]"}, want: "three"},
+	}
+	for _, tc := range testCases {
+		got := LookupNode(tc.path, root)
+		switch {
+		case tc.want == "" && got != nil:
+			t.Errorf("%v: expected no node, got %q", tc.path, got.Value)
+		case tc.want != "" && got == nil:
+			t.Errorf("%v: expected %q, got no node", tc.path, tc.want)
+		case tc.want != "" && got.Value != tc.want:
+			t.Errorf("%v: expected %q, got %q", tc.path, tc.want, got.Value)
+		}
+	}
+}
+
+func TestCopyNode(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(`items: [one, two]`), &doc); err != nil {
+		t.Fatalf("failed to unmarshal yaml: %v", err)
+	}
+
+	copied := CopyNode(&doc)
+	LookupNode([]string{"items", "[0]"}, copied.Content[0]).Value = "changed"
+
+	if got := LookupNode([]string{"items", "[0]"}, doc.Content[0]).Value; got != "one" {
+		t.Errorf("expected original to be unchanged, got %q", got)
+	}
+}
+
+func TestCopyNodeAlias(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("a: &a [one]\nb: *a\n"), &doc); err != nil {
+		t.Fatalf("failed to unmarshal yaml: %v", err)
+	}
+
+	// The copy of an alias can be encoded without the anchor
+	out, err := yaml.Marshal(CopyNode(LookupNode([]string{"b"}, doc.Content[0])))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "[one]\n"; string(out) != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	testCases := []struct {
+		element string
+		want    int
+		ok      bool
+	}{
+		{element: Index(3), want: 3, ok: true},
+		{element: "[0]", want: 0, ok: true},
+		{element: "[-1]"},
+		{element: "[a]"},
+		{element: "items"},
+	}
+	for _, tc := range testCases {
+		got, ok := ParseIndex(tc.element)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: expected %d %t, got %d %t", tc.element, tc.want, tc.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package yamlhelpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resolve returns the node that an alias refers to, i.e the node anchored by
+// &defaults for *defaults. Other nodes are returned as they are.
+func Resolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	return node
+}
+
+// Fields returns the keys and values of a mapping node as pairs, like the
+// Content of the node, with aliases resolved and the keys of merge keys (<<)
+// included in place of the merge key.
+//
+// Keys in the mapping take precedence over merged keys and, when several
+// mappings are merged, the earlier mappings take precedence over the later
+// ones, like they do when the YAML is decoded.
+func Fields(node *yaml.Node) []*yaml.Node {
+	node = Resolve(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isMergeKey(node.Content[i]) {
+			seen[node.Content[i].Value] = true
+		}
+	}
+
+	var fields []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], Resolve(node.Content[i+1])
+		if !isMergeKey(key) {
+			fields = append(fields, key, value)
+			continue
+		}
+
+		sources := []*yaml.Node{value}
+		if value != nil && value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			merged := Fields(source)
+			for j := 0; j+1 < len(merged); j += 2 {
+				if seen[merged[j].Value] {
+					continue
+				}
+				seen[merged[j].Value] = true
+				fields = append(fields, merged[j], merged[j+1])
+			}
+		}
+	}
+
+	return fields
+}
+
+// isMergeKey returns true if the key merges other mappings into its mapping.
+// That's a plain << key, whether it has the merge tag or its tag was cleared
+// by ClearMergeTags.
+func isMergeKey(key *yaml.Node) bool {
+	if key.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	return key.Tag == "!!merge" || (key.Tag == "" && key.Style == 0 && key.Value == "<<")
+}
+
+// ClearMergeTags clears the tags of the merge keys in the node, so that they're
+// encoded as <<, rather than !!merge <<. The keys are still merge keys when
+// the output is decoded again.
+func ClearMergeTags(node *yaml.Node) {
+	if node == nil || node.Kind == yaml.AliasNode {
+		return
+	}
+	if isMergeKey(node) {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		ClearMergeTags(child)
+	}
+}
+
+// Documents decodes each document in a multi-document YAML stream and returns
+// their root nodes, skipping empty documents. The merge keys in the documents
+// are cleared of their tags, like ClearMergeTags.
+func Documents(input []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+
+	dec := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding yaml: %w", err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].ShortTag() == "!!null" {
+			continue
+		}
+		ClearMergeTags(doc.Content[0])
+		docs = append(docs, doc.Content[0])
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,86 @@
+package yamlhelpers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFields(t *testing.T) {
+	input := `
+base: &base
+  repository: nginx
+  tag: "1.25"
+  pullPolicy: IfNotPresent
+extra: &extra
+  tag: "1.27"
+  registry: docker.io
+image:
+  <<: [*base, *extra]
+  pullPolicy: Always
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(input), &doc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The keys of the mapping take precedence over the merged keys, and
+	// the first merged mapping takes precedence over the second
+	want := map[string]string{
+		"repository": "nginx",
+		"tag":        "1.25",
+		"pullPolicy": "Always",
+		"registry":   "docker.io",
+	}
+	fields := Fields(LookupNode([]string{"image"}, doc.Content[0]))
+	got := map[string]string{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		got[fields[i].Value] = fields[i+1].Value
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected fields (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("a: &a nginx\nb: *a\n"), &doc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	root := doc.Content[0]
+
+	if got := Resolve(root.Content[3]); got != root.Content[1] {
+		t.Errorf("expected the alias to resolve to the anchored node, got %v", got)
+	}
+	if got := Resolve(root.Content[1]); got != root.Content[1] {
+		t.Errorf("expected the node itself, got %v", got)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	input := `---
+a: 1
+---
+---
+# Just a comment
+---
+- b
+`
+	docs, err := Documents([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var got []yaml.Kind
+	for _, doc := range docs {
+		got = append(got, doc.Kind)
+	}
+	if diff := cmp.Diff([]yaml.Kind{yaml.MappingNode, yaml.SequenceNode}, got); diff != "" {
+		t.Errorf("unexpected documents (-want +got):\n%s", diff)
+	}
+
+	if _, err := Documents([]byte("a: [")); err == nil {
+		t.Errorf("expected an error for invalid yaml")
+	}
+}
@@ -1,33 +1,53 @@
 package yamlhelpers
 
-import "gopkg.in/yaml.v3"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
 
 // WalkNodeFn is called for each node by WalkNode
 type WalkNodeFn func(path []string, node *yaml.Node) error
 
 // WalkNode walks recursively through a yaml.Node, calling fn for each node.
+//
+// The items in a sequence are identified by their index in the path, i.e
+// items.[0].name.
+//
+// Aliases are resolved, so fn is called with the anchored node at each path
+// that refers to it, and the keys merged into a mapping with << are walked as
+// if they were keys of the mapping.
 func WalkNode(node *yaml.Node, fn WalkNodeFn) error {
-	return walkNode([]string{}, node, fn)
+	return walkNode([]string{}, node, fn, map[*yaml.Node]bool{})
 }
 
-func walkNode(path []string, node *yaml.Node, fn WalkNodeFn) error {
+func walkNode(path []string, node *yaml.Node, fn WalkNodeFn, parents map[*yaml.Node]bool) error {
+	node = Resolve(node)
+
+	// An alias can refer to a node that contains it
+	if node == nil || parents[node] {
+		return nil
+	}
+	parents[node] = true
+	defer delete(parents, node)
+
 	if err := fn(path, node); err != nil {
 		return err
 	}
 
 	switch node.Kind {
 	case yaml.MappingNode:
-		for i := 0; i < len(node.Content); i += 2 {
-			key := node.Content[i]
-			value := node.Content[i+1]
-
-			if err := walkNode(append(path, key.Value), value, fn); err != nil {
+		fields := Fields(node)
+		for i := 0; i+1 < len(fields); i += 2 {
+			if err := walkNode(append(path, fields[i].Value), fields[i+1], fn, parents); err != nil {
 				return err
 			}
 		}
 	case yaml.SequenceNode:
-		for _, child := range node.Content {
-			if err := walkNode(path, child, fn); err != nil {
+		for i, child := range node.Content {
+			if err := walkNode(append(path, Index(i)), child, fn, parents); err != nil {
 				return err
 			}
 		}
@@ -35,3 +55,23 @@ func walkNode(path []string, node *yaml.Node, fn WalkNodeFn) error {
 
 	return nil
 }
+
+// Index returns the path element for an index in a sequence
+func Index(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+// ParseIndex returns the index in a sequence that the path element refers to,
+// if it refers to one
+func ParseIndex(element string) (int, bool) {
+	if !strings.HasPrefix(element, "[") || !strings.HasSuffix(element, "]") {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(element[1 : len(element)-1])
+	if err != nil || i < 0 {
+		return 0, false
+	}
+
+	return i, true
+}
@@ -62,9 +62,9 @@ items:
 			expectedPaths: []string{
 				"",
 				"items",
-				"items",
-				"items",
-				"items",
+				"items.[0]",
+				"items.[1]",
+				"items.[2]",
 			},
 			expectedKinds: []yaml.Kind{
 				yaml.MappingNode,
@@ -98,12 +98,12 @@ servers:
 				"database.credentials.username",
 				"database.credentials.password",
 				"servers",
-				"servers",
-				"servers.name",
-				"servers.ip",
-				"servers",
-				"servers.name",
-				"servers.ip",
+				"servers.[0]",
+				"servers.[0].name",
+				"servers.[0].ip",
+				"servers.[1]",
+				"servers.[1].name",
+				"servers.[1].ip",
 			},
 			expectedKinds: []yaml.Kind{
 				yaml.MappingNode,
@@ -122,6 +122,37 @@ servers:
 				yaml.ScalarNode,
 			},
 		},
+		{
+			name: "aliases and merge keys",
+			yaml: `
+defaults: &defaults
+  tag: latest
+image:
+  <<: *defaults
+  repository: nginx
+sidecar: *defaults
+`,
+			expectedPaths: []string{
+				"",
+				"defaults",
+				"defaults.tag",
+				"image",
+				"image.tag",
+				"image.repository",
+				"sidecar",
+				"sidecar.tag",
+			},
+			expectedKinds: []yaml.Kind{
+				yaml.MappingNode,
+				yaml.MappingNode,
+				yaml.ScalarNode,
+				yaml.MappingNode,
+				yaml.ScalarNode,
+				yaml.ScalarNode,
+				yaml.MappingNode,
+				yaml.ScalarNode,
+			},
+		},
 		{
 			name: "scalar only",
 			yaml: `simple value`,
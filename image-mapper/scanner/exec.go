@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// Exec is a scanner that runs an executable, so that it can be written in any
+// language and added without rebuilding image-mapper.
+//
+// The executable is run with one of these arguments, and reads a JSON request
+// from stdin and writes a JSON response to stdout:
+//
+//   - describe: the response is the name of the scanner and the patterns of
+//     the files it reads, like {"name": "paas", "patterns": ["*.paas.yaml"]}.
+//     It's run once, when the scanner is loaded.
+//   - extract: the request is the path and content of a file, like
+//     {"path": "deploy/app.paas.yaml", "content": "..."}, and the response is
+//     the images in it, like {"images": [{"image": "nginx:1.25", "line": 3}]}.
+//   - rewrite: the request is like the one for extract, with the images that
+//     were mapped, like {"path": "...", "content": "...", "images":
+//     {"nginx:1.25": "cgr.dev/chainguard/nginx:1.25"}}, and the response is
+//     the new content of the file, like {"content": "..."}.
+//
+// A non-zero exit code is an error, and anything the executable writes to
+// stderr is included in it.
+type Exec struct {
+	ctx      context.Context
+	command  string
+	name     string
+	patterns []string
+}
+
+// execDescription is the response to describe
+type execDescription struct {
+	Name     string   `json:"name"`
+	Patterns []string `json:"patterns"`
+}
+
+// execRequest is the request for extract and rewrite
+type execRequest struct {
+	Path    string            `json:"path"`
+	Content string            `json:"content"`
+	Images  map[string]string `json:"images,omitempty"`
+}
+
+// execResponse is the response to extract and rewrite
+type execResponse struct {
+	Images  []Image `json:"images,omitempty"`
+	Content string  `json:"content,omitempty"`
+}
+
+// LoadExec runs the executable to find out its name and the files it reads
+func LoadExec(ctx context.Context, command string) (*Exec, error) {
+	e := &Exec{ctx: ctx, command: command}
+
+	var desc execDescription
+	if err := e.run("describe", nil, &desc); err != nil {
+		return nil, err
+	}
+	if desc.Name == "" {
+		return nil, fmt.Errorf("loading scanner plugin: %s: describe didn't return a name", command)
+	}
+	for _, p := range desc.Patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("loading scanner plugin: %s: invalid pattern %q: %w", command, p, err)
+		}
+	}
+	e.name = desc.Name
+	e.patterns = desc.Patterns
+
+	return e, nil
+}
+
+// Name returns the name that the executable described itself with
+func (e *Exec) Name() string {
+	return e.name
+}
+
+// Detect returns true if the path, or the name of the file, matches one of
+// the patterns that the executable described. Patterns with a slash are
+// matched against the path, like deploy/*.yaml, and the others against the
+// name of the file.
+func (e *Exec) Detect(p string) bool {
+	for _, pattern := range e.patterns {
+		target := path.Base(p)
+		if strings.Contains(pattern, "/") {
+			target = p
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Extract runs the executable with extract
+func (e *Exec) Extract(p string, input []byte) ([]Image, error) {
+	var resp execResponse
+	if err := e.run("extract", execRequest{Path: p, Content: string(input)}, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Images, nil
+}
+
+// Rewrite runs the executable with rewrite
+func (e *Exec) Rewrite(p string, input []byte, images map[string]string) ([]byte, error) {
+	var resp execResponse
+	if err := e.run("rewrite", execRequest{Path: p, Content: string(input), Images: images}, &resp); err != nil {
+		return nil, err
+	}
+
+	return []byte(resp.Content), nil
+}
+
+// run runs the executable with the action, writing the request to its stdin
+// and decoding its stdout into the response
+func (e *Exec) run(action string, req any, resp any) error {
+	var stdin bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&stdin).Encode(req); err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(e.ctx, e.command, action)
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("running scanner plugin: %s %s: %w: %s", e.command, action, err, msg)
+		}
+		return fmt.Errorf("running scanner plugin: %s %s: %w", e.command, action, err)
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return fmt.Errorf("decoding response of scanner plugin: %s %s: %w", e.command, action, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestMain runs the test binary as a scanner plugin when it's run by the tests
+// of Exec, for files with lines like image: nginx:1.25
+func TestMain(m *testing.M) {
+	if os.Getenv("SCANNER_TEST_PLUGIN") != "1" {
+		os.Exit(m.Run())
+	}
+
+	var req execRequest
+	if os.Args[1] != "describe" {
+		if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	var resp any
+	switch os.Args[1] {
+	case "describe":
+		resp = execDescription{Name: "paas", Patterns: []string{"*.paas", "deploy/*.conf"}}
+	case "extract":
+		var images []Image
+		for i, line := range strings.Split(req.Content, "\n") {
+			if img, ok := strings.CutPrefix(line, "image: "); ok {
+				images = append(images, Image{Image: img, Line: i + 1})
+			}
+		}
+		resp = execResponse{Images: images}
+	case "rewrite":
+		lines := strings.Split(req.Content, "\n")
+		for i, line := range lines {
+			if img, ok := strings.CutPrefix(line, "image: "); ok {
+				if mapped, ok := req.Images[img]; ok {
+					lines[i] = "image: " + mapped
+				}
+			}
+		}
+		resp = execResponse{Content: strings.Join(lines, "\n")}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown action %s\n", os.Args[1])
+		os.Exit(1)
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(resp)
+	os.Exit(0)
+}
+
+func TestExec(t *testing.T) {
+	t.Setenv("SCANNER_TEST_PLUGIN", "1")
+
+	e, err := LoadExec(context.Background(), os.Args[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e.Name() != "paas" {
+		t.Errorf("expected the name paas, got %s", e.Name())
+	}
+
+	for path, want := range map[string]bool{
+		"app.paas":             true,
+		"services/app.paas":    true,
+		"deploy/app.conf":      true,
+		"services/app.conf":    false,
+		"deploy/app.paas.yaml": false,
+	} {
+		if got := e.Detect(path); got != want {
+			t.Errorf("expected Detect(%s) to be %t, got %t", path, want, got)
+		}
+	}
+
+	input := []byte("name: app\nimage: nginx:1.25\nimage: example/unknown:1.0\n")
+
+	images, err := e.Extract("app.paas", input)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wantImages := []Image{
+		{Image: "nginx:1.25", Line: 2},
+		{Image: "example/unknown:1.0", Line: 3},
+	}
+	if diff := cmp.Diff(wantImages, images); diff != "" {
+		t.Errorf("unexpected images (-want +got):\n%s", diff)
+	}
+
+	output, err := e.Rewrite("app.paas", input, map[string]string{"nginx:1.25": "cgr.dev/chainguard/nginx:1.25"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "name: app\nimage: cgr.dev/chainguard/nginx:1.25\nimage: example/unknown:1.0\n"
+	if diff := cmp.Diff(want, string(output)); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadExecError(t *testing.T) {
+	if _, err := LoadExec(context.Background(), "/does/not/exist"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
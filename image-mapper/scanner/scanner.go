@@ -0,0 +1,89 @@
+// Package scanner lets teams add the formats of their own config files, like
+// the manifests of an internal platform, to the files that the scan and pr
+// commands find images in, without forking image-mapper.
+//
+// A scanner can be compiled in, by registering it in the init function of a
+// package that's imported by a main package that runs cmd.Execute:
+//
+//	func init() {
+//		scanner.Register(paasScanner{})
+//	}
+//
+// Or it can be an executable that's loaded with LoadExec, which is what the
+// --scanner-plugin flag does.
+package scanner
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Image is an image that a scanner found in a file
+type Image struct {
+	Image string `json:"image"`
+
+	// Line is the line that the image is on, starting at 1, if it's known
+	Line int `json:"line,omitempty"`
+}
+
+// Scanner finds, and changes, the images in a format of file that isn't built
+// in to image-mapper
+type Scanner interface {
+	// Name is the type of the files that the scanner reads, like paas,
+	// which is reported with the images that are found in them
+	Name() string
+
+	// Detect returns true if the scanner reads the file. The path is
+	// relative to the directory that's being scanned, with forward
+	// slashes.
+	Detect(path string) bool
+
+	// Extract returns the images in the file, in the order they appear
+	Extract(path string, input []byte) ([]Image, error)
+
+	// Rewrite returns the file with the images replaced by the images
+	// they're mapped to, which are keyed by the images that Extract
+	// returned. Images that aren't in the map are left as they are.
+	Rewrite(path string, input []byte, images map[string]string) ([]byte, error)
+}
+
+var (
+	mu       sync.Mutex
+	scanners []Scanner
+)
+
+// Register adds a scanner to the ones that are used by default. It panics if
+// a scanner with the same name has already been registered, like
+// database/sql does for drivers.
+func Register(s Scanner) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, registered := range scanners {
+		if registered.Name() == s.Name() {
+			panic(fmt.Sprintf("scanner: Register called twice for %s", s.Name()))
+		}
+	}
+	scanners = append(scanners, s)
+}
+
+// Registered returns the scanners that have been registered, in the order
+// they were registered
+func Registered() []Scanner {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]Scanner(nil), scanners...)
+}
+
+// Find returns the first of the scanners that reads the file, or nil if none
+// of them do
+func Find(scanners []Scanner, path string) Scanner {
+	for _, s := range scanners {
+		if s.Detect(path) {
+			return s
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+module github.com/chainguard-dev/platform-examples/internal/hook
+
+go 1.25.0
+
+require github.com/google/go-cmp v0.7.0
@@ -0,0 +1,133 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package hook notifies other systems when an image has been copied, so that
+// they can act on it, like warming a cache, deploying the image or scanning it.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Event describes an image that has been copied. It's the JSON payload that's
+// sent to the hooks.
+type Event struct {
+	// Source is the image that was copied, i.e
+	// cgr.dev/your.org/nginx:latest
+	Source string `json:"source"`
+
+	// Destination is the image it was copied to
+	Destination string `json:"destination"`
+
+	// Digest is the digest of the destination image, when it's known
+	Digest string `json:"digest,omitempty"`
+}
+
+// Hook runs after an image has been copied
+type Hook interface {
+	Run(ctx context.Context, event Event) error
+}
+
+// Hooks runs each of the hooks in turn
+type Hooks []Hook
+
+// New returns the hooks for the URL and the command. Either of them can be
+// empty, in which case there's no hook for it.
+func New(url, command string) Hooks {
+	var hooks Hooks
+	if url != "" {
+		hooks = append(hooks, URL(url))
+	}
+	if command != "" {
+		hooks = append(hooks, Command(command))
+	}
+
+	return hooks
+}
+
+// Run runs every hook, even when some of them fail, and returns their errors
+// joined together
+func (h Hooks) Run(ctx context.Context, event Event) error {
+	var errs []error
+	for _, hook := range h {
+		if err := hook.Run(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// URL POSTs the event to the URL as JSON
+type URL string
+
+// Run POSTs the event and expects a 2xx response
+func (u URL) Run(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshalling event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, string(u), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to hook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting to hook: unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Command runs a command with the event as JSON on stdin. The fields of the
+// event are also set in the environment as HOOK_SOURCE, HOOK_DESTINATION and
+// HOOK_DIGEST.
+//
+// The command is run by sh -c, like the HOOK_COMMAND of the cronjob, so it can
+// use quotes, pipes and the variables. The image the tool is built into needs
+// to have sh, and anything the command runs, in it.
+type Command string
+
+// Run runs the command and expects it to exit with 0
+func (c Command) Run(ctx context.Context, event Event) error {
+	if c == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshalling event: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", string(c))
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"HOOK_SOURCE="+event.Source,
+		"HOOK_DESTINATION="+event.Destination,
+		"HOOK_DIGEST="+event.Digest,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running hook %q: %w: %s", string(c), err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var event = Event{
+	Source:      "cgr.dev/your.org/nginx:latest",
+	Destination: "registry.example.com/mirror/nginx:latest",
+	Digest:      "sha256:abc",
+}
+
+func TestCommand(t *testing.T) {
+	testCases := map[string]struct {
+		command string
+		want    string
+		wantErr bool
+	}{
+		"empty": {
+			command: "",
+		},
+		"stdin": {
+			command: `cat > "$OUT"`,
+			want:    `{"source":"cgr.dev/your.org/nginx:latest","destination":"registry.example.com/mirror/nginx:latest","digest":"sha256:abc"}`,
+		},
+		"environment": {
+			command: `echo "$HOOK_SOURCE $HOOK_DESTINATION $HOOK_DIGEST" > "$OUT"`,
+			want:    "cgr.dev/your.org/nginx:latest registry.example.com/mirror/nginx:latest sha256:abc\n",
+		},
+		"quotes and pipes": {
+			command: `echo 'copied to' "$HOOK_DESTINATION" | tr a-z A-Z > "$OUT"`,
+			want:    "COPIED TO REGISTRY.EXAMPLE.COM/MIRROR/NGINX:LATEST\n",
+		},
+		"fails": {
+			command: "exit 1",
+			wantErr: true,
+		},
+		"missing command": {
+			command: "does-not-exist",
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			t.Setenv("OUT", out)
+
+			err := Command(tc.command).Run(context.Background(), event)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tc.want == "" {
+				return
+			}
+
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	testCases := map[string]struct {
+		status  int
+		wantErr bool
+	}{
+		"ok":        {status: http.StatusOK},
+		"accepted":  {status: http.StatusAccepted},
+		"failed":    {status: http.StatusInternalServerError, wantErr: true},
+		"not found": {status: http.StatusNotFound, wantErr: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var got Event
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("unexpected content type: %s", ct)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				w.WriteHeader(tc.status)
+			}))
+			t.Cleanup(srv.Close)
+
+			err := URL(srv.URL).Run(context.Background(), event)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(event, got); diff != "" {
+				t.Errorf("unexpected event (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	t.Setenv("OUT", out)
+
+	// Every hook runs, even when one before it fails
+	hooks := New("http://127.0.0.1:0", `echo ran > "$OUT"`)
+	if len(hooks) != 2 {
+		t.Fatalf("expected 2 hooks, got %d", len(hooks))
+	}
+	if err := hooks.Run(context.Background(), event); err == nil {
+		t.Errorf("expected an error")
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("expected the command to run: %s", err)
+	}
+
+	if hooks := New("", ""); len(hooks) != 0 {
+		t.Errorf("expected no hooks, got %d", len(hooks))
+	}
+}
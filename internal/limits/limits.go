@@ -0,0 +1,177 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package limits checks images against size and layer count limits before
+// they're copied, so that an image that's too big to copy in time is reported,
+// rather than timing out the copy.
+package limits
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Limits are the largest images that are copied. A zero limit isn't checked.
+type Limits struct {
+	// MaxSize is the most bytes of compressed layers and configs in the
+	// image, across all of its platforms
+	MaxSize int64
+
+	// MaxLayers is the most layers in any one platform of the image
+	MaxLayers int
+}
+
+// Enabled returns true if any of the limits are set
+func (l Limits) Enabled() bool {
+	return l.MaxSize > 0 || l.MaxLayers > 0
+}
+
+// Violation is returned when an image exceeds the limits
+type Violation struct {
+	Image  string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s exceeds the limits: %s", v.Image, v.Reason)
+}
+
+// Check reads the manifests of the image and returns a *Violation if it
+// exceeds the limits. Only the manifests are read, not the layers.
+func (l Limits) Check(image string, opts ...remote.Option) error {
+	if !l.Enabled() {
+		return nil
+	}
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return fmt.Errorf("parsing image: %w", err)
+	}
+
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return fmt.Errorf("getting descriptor: %w", err)
+	}
+
+	var manifests []*v1.Manifest
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return fmt.Errorf("reading index: %w", err)
+		}
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return fmt.Errorf("reading index manifest: %w", err)
+		}
+		for _, d := range im.Manifests {
+			if !d.MediaType.IsImage() {
+				continue
+			}
+			img, err := idx.Image(d.Digest)
+			if err != nil {
+				return fmt.Errorf("reading image %s: %w", d.Digest, err)
+			}
+			m, err := img.Manifest()
+			if err != nil {
+				return fmt.Errorf("reading manifest %s: %w", d.Digest, err)
+			}
+			manifests = append(manifests, m)
+		}
+	} else {
+		img, err := desc.Image()
+		if err != nil {
+			return fmt.Errorf("reading image: %w", err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			return fmt.Errorf("reading manifest: %w", err)
+		}
+		manifests = append(manifests, m)
+	}
+
+	var size int64
+	layers := 0
+	for _, m := range manifests {
+		size += m.Config.Size
+		for _, layer := range m.Layers {
+			size += layer.Size
+		}
+		layers = max(layers, len(m.Layers))
+	}
+
+	if l.MaxSize > 0 && size > l.MaxSize {
+		return &Violation{
+			Image:  ref.String(),
+			Reason: fmt.Sprintf("it's %s, which is more than %s", FormatSize(size), FormatSize(l.MaxSize)),
+		}
+	}
+	if l.MaxLayers > 0 && layers > l.MaxLayers {
+		return &Violation{
+			Image:  ref.String(),
+			Reason: fmt.Sprintf("it has %d layers, which is more than %d", layers, l.MaxLayers),
+		}
+	}
+
+	return nil
+}
+
+// units are the multipliers of the size suffixes, longest first so that GiB
+// is matched before B
+var units = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"TB", 1e12},
+	{"GB", 1e9},
+	{"MB", 1e6},
+	{"KB", 1e3},
+	{"B", 1},
+}
+
+// ParseSize parses a size like 5GB, 512MiB or 1000000. A size without a unit
+// is in bytes. An empty size is 0, which means no limit.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(strings.ToUpper(s), strings.ToUpper(u.suffix)); ok {
+			s, multiplier = strings.TrimSpace(n), u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatSize formats a number of bytes with the largest decimal unit that
+// fits, i.e 1.5GB
+func FormatSize(bytes int64) string {
+	for _, u := range units[4:] {
+		if bytes >= u.bytes {
+			n := strconv.FormatFloat(float64(bytes)/float64(u.bytes), 'f', 1, 64)
+			return strings.TrimSuffix(n, ".0") + u.suffix
+		}
+	}
+
+	return "0B"
+}
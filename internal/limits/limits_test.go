@@ -0,0 +1,218 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestParseSize(t *testing.T) {
+	testCases := map[string]struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		"empty":             {size: "", want: 0},
+		"bytes":             {size: "1000000", want: 1000000},
+		"bytes with unit":   {size: "512B", want: 512},
+		"kilobytes":         {size: "2KB", want: 2000},
+		"kibibytes":         {size: "2KiB", want: 2048},
+		"megabytes":         {size: "512MB", want: 512_000_000},
+		"mebibytes":         {size: "512MiB", want: 512 << 20},
+		"gigabytes":         {size: "5GB", want: 5_000_000_000},
+		"gibibytes":         {size: "5GiB", want: 5 << 30},
+		"terabytes":         {size: "1TB", want: 1_000_000_000_000},
+		"tebibytes":         {size: "1TiB", want: 1 << 40},
+		"decimal":           {size: "1.5GB", want: 1_500_000_000},
+		"decimal binary":    {size: "0.5GiB", want: 1 << 29},
+		"lowercase":         {size: "5gb", want: 5_000_000_000},
+		"whitespace":        {size: " 5 GB ", want: 5_000_000_000},
+		"zero":              {size: "0", want: 0},
+		"unknown unit":      {size: "5XB", wantErr: true},
+		"unit without size": {size: "GB", wantErr: true},
+		"not a number":      {size: "five", wantErr: true},
+		"negative":          {size: "-1GB", wantErr: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseSize(tc.size)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected size (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := map[string]struct {
+		bytes int64
+		want  string
+	}{
+		"zero":      {bytes: 0, want: "0B"},
+		"bytes":     {bytes: 512, want: "512B"},
+		"kilobytes": {bytes: 2000, want: "2KB"},
+		"megabytes": {bytes: 512_000_000, want: "512MB"},
+		"gigabytes": {bytes: 5_000_000_000, want: "5GB"},
+		"decimal":   {bytes: 1_500_000_000, want: "1.5GB"},
+		"terabytes": {bytes: 2_000_000_000_000, want: "2TB"},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := FormatSize(tc.bytes)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected size (-want +got):\n%s", diff)
+			}
+
+			// The formatted size parses back to the same number of
+			// bytes
+			parsed, err := ParseSize(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.bytes, parsed); diff != "" {
+				t.Errorf("unexpected round trip (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	push := func(repo string, write func(name.Reference) error) string {
+		t.Helper()
+
+		ref, err := name.ParseReference(host + "/" + repo)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := write(ref); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return ref.String()
+	}
+	pushImage := func(repo string, size int64, layers int) string {
+		t.Helper()
+
+		img, err := random.Image(size, int64(layers))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return push(repo, func(ref name.Reference) error { return remote.Write(ref, img) })
+	}
+
+	// The layers are tarballs of random bytes, so each one is a little
+	// larger than its size
+	small := pushImage("small", 1024, 2)
+	manyLayers := pushImage("many-layers", 16, 20)
+	large := pushImage("large", 1<<20, 2)
+
+	idx, err := random.Index(1024, 2, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	index := push("index", func(ref name.Reference) error { return remote.WriteIndex(ref, idx) })
+
+	testCases := map[string]struct {
+		limits        Limits
+		image         string
+		wantViolation string
+		wantErr       bool
+	}{
+		"disabled": {
+			image: large,
+		},
+		"within the limits": {
+			limits: Limits{MaxSize: 1e6, MaxLayers: 5},
+			image:  small,
+		},
+		"over the layer limit": {
+			limits:        Limits{MaxSize: 1e6, MaxLayers: 5},
+			image:         manyLayers,
+			wantViolation: "it has 20 layers, which is more than 5",
+		},
+		"over the size limit": {
+			limits:        Limits{MaxSize: 1e6, MaxLayers: 5},
+			image:         large,
+			wantViolation: "it's 2.1MB, which is more than 1MB",
+		},
+		"index within the layer limit": {
+			limits: Limits{MaxLayers: 2},
+			image:  index,
+		},
+		"index over the size limit across platforms": {
+			limits:        Limits{MaxSize: 6000},
+			image:         index,
+			wantViolation: "it's 8.9KB, which is more than 6KB",
+		},
+		"missing image": {
+			limits:  Limits{MaxLayers: 5},
+			image:   host + "/missing",
+			wantErr: true,
+		},
+		"invalid image": {
+			limits:  Limits{MaxLayers: 5},
+			image:   "INVALID",
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.limits.Check(tc.image)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				var violation *Violation
+				if errors.As(err, &violation) {
+					t.Errorf("unexpected violation: %s", err)
+				}
+				return
+			}
+			if tc.wantViolation == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			var violation *Violation
+			if !errors.As(err, &violation) {
+				t.Fatalf("expected a violation, got: %v", err)
+			}
+			if diff := cmp.Diff(tc.image, violation.Image); diff != "" {
+				t.Errorf("unexpected image (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantViolation, violation.Reason); diff != "" {
+				t.Errorf("unexpected reason (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
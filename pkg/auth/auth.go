@@ -0,0 +1,108 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package auth exchanges a cloud identity for a Chainguard token, so that the
+// examples can pull from cgr.dev and call the Chainguard API as an assumable
+// Chainguard identity.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"chainguard.dev/sdk/sts"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// Registry is the registry that the keychain provides Chainguard tokens for
+const Registry = "cgr.dev"
+
+// expiryMargin is how long before it expires that a token stops being reused
+const expiryMargin = time.Minute
+
+// IdentitySource provides the token of a cloud identity that the Chainguard
+// identity has been set up to be assumed by
+type IdentitySource interface {
+	// Token returns a token for the Chainguard issuer
+	Token(ctx context.Context, issuer, identity string) (string, error)
+}
+
+// Client exchanges the tokens of an identity source for Chainguard tokens
+type Client struct {
+	issuer   string
+	identity string
+	source   IdentitySource
+
+	// newExchanger is sts.New, except in the tests
+	newExchanger func(issuer, audience string, opts ...sts.ExchangerOption) sts.Exchanger
+
+	mu     sync.Mutex
+	tokens map[string]sts.TokenPair
+}
+
+// New returns a client that assumes the Chainguard identity with the tokens of
+// the identity source
+func New(issuer, identity string, source IdentitySource) *Client {
+	return &Client{
+		issuer:       issuer,
+		identity:     identity,
+		source:       source,
+		newExchanger: sts.New,
+		tokens:       map[string]sts.TokenPair{},
+	}
+}
+
+// Token returns a Chainguard token for the audience, i.e the API endpoint or
+// cgr.dev. The token is reused until it's about to expire.
+func (c *Client) Token(ctx context.Context, audience string) (*sts.TokenPair, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tok, ok := c.tokens[audience]; ok && time.Until(tok.Expiry) > expiryMargin {
+		return &tok, nil
+	}
+
+	tok, err := c.source.Token(ctx, c.issuer, c.identity)
+	if err != nil {
+		return nil, fmt.Errorf("getting identity token: %w", err)
+	}
+
+	exch := c.newExchanger(c.issuer, audience, sts.WithIdentity(c.identity))
+	cgTok, err := exch.Exchange(ctx, tok)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging token: %w", err)
+	}
+	c.tokens[audience] = cgTok
+
+	return &cgTok, nil
+}
+
+// Keychain returns an authn.Keychain that provides a Chainguard token capable
+// of pulling from cgr.dev. Other registries are accessed anonymously.
+func (c *Client) Keychain() authn.Keychain {
+	return keychain{client: c}
+}
+
+type keychain struct {
+	client *Client
+}
+
+func (k keychain) Resolve(res authn.Resource) (authn.Authenticator, error) {
+	if res.RegistryStr() != Registry {
+		return authn.Anonymous, nil
+	}
+
+	tok, err := k.client.Token(context.Background(), res.RegistryStr())
+	if err != nil {
+		return nil, fmt.Errorf("getting token: %w", err)
+	}
+
+	return &authn.Basic{
+		Username: "_token",
+		Password: tok.AccessToken,
+	}, nil
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chainguard.dev/sdk/sts"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// fakeSource returns the token, or the error, and counts the calls
+type fakeSource struct {
+	token string
+	err   error
+	calls int
+}
+
+func (f *fakeSource) Token(_ context.Context, issuer, identity string) (string, error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+
+	return f.token + "@" + issuer + "/" + identity, nil
+}
+
+// fakeExchanger exchanges a token for one that names the audience, the
+// identity and the token it was exchanged for
+type fakeExchanger struct {
+	sts.Exchanger
+
+	audience string
+	identity string
+	expiry   time.Time
+	err      error
+}
+
+func (f fakeExchanger) Exchange(_ context.Context, token string, _ ...sts.ExchangerOption) (sts.TokenPair, error) {
+	if f.err != nil {
+		return sts.TokenPair{}, f.err
+	}
+
+	return sts.TokenPair{AccessToken: f.audience + ":" + f.identity + ":" + token, Expiry: f.expiry}, nil
+}
+
+// newTestClient returns a client with the source that exchanges its tokens
+// with a fakeExchanger
+func newTestClient(source IdentitySource, expiry time.Time, exchErr error) *Client {
+	c := New("https://issuer.example", "identity-id", source)
+	c.newExchanger = func(_, audience string, _ ...sts.ExchangerOption) sts.Exchanger {
+		return fakeExchanger{audience: audience, identity: c.identity, expiry: expiry, err: exchErr}
+	}
+
+	return c
+}
+
+func TestClientToken(t *testing.T) {
+	testCases := map[string]struct {
+		source       *fakeSource
+		expiry       time.Time
+		exchErr      error
+		wantToken    string
+		wantErr      bool
+		wantCalls    int
+		requestTwice bool
+	}{
+		"exchanges the source token": {
+			source:    &fakeSource{token: "cloud"},
+			expiry:    time.Now().Add(time.Hour),
+			wantToken: "https://console-api.example:identity-id:cloud@https://issuer.example/identity-id",
+			wantCalls: 1,
+		},
+		"reuses a token that hasn't expired": {
+			source:       &fakeSource{token: "cloud"},
+			expiry:       time.Now().Add(time.Hour),
+			wantToken:    "https://console-api.example:identity-id:cloud@https://issuer.example/identity-id",
+			wantCalls:    1,
+			requestTwice: true,
+		},
+		"exchanges again when the token is about to expire": {
+			source:       &fakeSource{token: "cloud"},
+			expiry:       time.Now().Add(30 * time.Second),
+			wantToken:    "https://console-api.example:identity-id:cloud@https://issuer.example/identity-id",
+			wantCalls:    2,
+			requestTwice: true,
+		},
+		"source error": {
+			source:    &fakeSource{err: errors.New("no credentials")},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		"exchange error": {
+			source:    &fakeSource{token: "cloud"},
+			exchErr:   errors.New("permission denied"),
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := newTestClient(tc.source, tc.expiry, tc.exchErr)
+
+			tok, err := c.Token(context.Background(), "https://console-api.example")
+			if tc.requestTwice && err == nil {
+				tok, err = c.Token(context.Background(), "https://console-api.example")
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if diff := cmp.Diff(tc.wantToken, tok.AccessToken); diff != "" {
+					t.Errorf("unexpected token (-want +got):\n%s", diff)
+				}
+			}
+			if tc.source.calls != tc.wantCalls {
+				t.Errorf("expected %d calls to the source, got %d", tc.wantCalls, tc.source.calls)
+			}
+		})
+	}
+}
+
+func TestClientTokenAudiences(t *testing.T) {
+	source := &fakeSource{token: "cloud"}
+	c := newTestClient(source, time.Now().Add(time.Hour), nil)
+
+	for _, audience := range []string{"cgr.dev", "https://console-api.example", "cgr.dev"} {
+		tok, err := c.Token(context.Background(), audience)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := audience + ":identity-id:cloud@https://issuer.example/identity-id"
+		if diff := cmp.Diff(want, tok.AccessToken); diff != "" {
+			t.Errorf("unexpected token (-want +got):\n%s", diff)
+		}
+	}
+	if source.calls != 2 {
+		t.Errorf("expected a token to be exchanged for each audience, got %d exchanges", source.calls)
+	}
+}
+
+func TestKeychainResolve(t *testing.T) {
+	testCases := map[string]struct {
+		image   string
+		source  IdentitySource
+		want    authn.AuthConfig
+		wantErr bool
+	}{
+		"cgr.dev": {
+			image:  "cgr.dev/example.com/nginx:latest",
+			source: &fakeSource{token: "cloud"},
+			want: authn.AuthConfig{
+				Username: "_token",
+				Password: "cgr.dev:identity-id:cloud@https://issuer.example/identity-id",
+			},
+		},
+		"other registries are anonymous": {
+			image:  "docker.io/library/nginx:latest",
+			source: &fakeSource{err: errors.New("should not be called")},
+			want:   authn.AuthConfig{},
+		},
+		"static token": {
+			image:  "cgr.dev/example.com/nginx:latest",
+			source: Static("ci"),
+			want: authn.AuthConfig{
+				Username: "_token",
+				Password: "cgr.dev:identity-id:ci",
+			},
+		},
+		"empty static token": {
+			image:   "cgr.dev/example.com/nginx:latest",
+			source:  Static(""),
+			wantErr: true,
+		},
+		"source error": {
+			image:   "cgr.dev/example.com/nginx:latest",
+			source:  &fakeSource{err: errors.New("no credentials")},
+			wantErr: true,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			ref, err := name.ParseReference(tc.image)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			c := newTestClient(tc.source, time.Now().Add(time.Hour), nil)
+
+			authenticator, err := c.Keychain().Resolve(ref.Context())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got, err := authenticator.Authorization()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, *got); diff != "" {
+				t.Errorf("unexpected credentials (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
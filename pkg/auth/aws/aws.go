@@ -0,0 +1,41 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package aws is the identity source for the AWS credentials of the
+// environment. It's a package of its own, so that only the examples that run
+// on AWS link the AWS SDK.
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	cgaws "chainguard.dev/sdk/auth/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Source generates tokens with the AWS credentials of the environment, like
+// the role of a Lambda function
+type Source struct{}
+
+// Token generates a token signed with the AWS credentials
+func (Source) Token(ctx context.Context, issuer, identity string) (string, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load configuration, %w", err)
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve credentials, %w", err)
+	}
+
+	tok, err := cgaws.GenerateToken(ctx, creds, issuer, identity)
+	if err != nil {
+		return "", fmt.Errorf("generating AWS token: %w", err)
+	}
+
+	return tok, nil
+}
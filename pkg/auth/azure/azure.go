@@ -0,0 +1,85 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package azure is the identity source for the Azure managed identity of the
+// environment. It calls the Instance Metadata Service directly, so it doesn't
+// need the Azure SDK.
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultEndpoint is the token endpoint of the Azure Instance Metadata Service
+const DefaultEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// DefaultResource is the audience of the tokens, when the source doesn't set
+// one
+const DefaultResource = "api://AzureADTokenExchange"
+
+// Source gets tokens for the managed identity of the environment, like the
+// identity of a Container App or Function, from the Instance Metadata Service
+type Source struct {
+	// ClientID selects a user-assigned managed identity. The system-assigned
+	// identity is used when it's empty.
+	ClientID string
+
+	// Resource is the audience of the token. Defaults to DefaultResource.
+	Resource string
+
+	// Endpoint is the token endpoint. Defaults to DefaultEndpoint.
+	Endpoint string
+}
+
+// Token returns an access token for the managed identity
+func (s Source) Token(ctx context.Context, _, _ string) (string, error) {
+	resource := s.Resource
+	if resource == "" {
+		resource = DefaultResource
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	q := url.Values{}
+	q.Set("api-version", "2018-02-01")
+	q.Set("resource", resource)
+	if s.ClientID != "" {
+		q.Set("client_id", s.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting managed identity token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting managed identity token: unexpected status: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding managed identity token: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("decoding managed identity token: no access_token in the response")
+	}
+
+	return body.AccessToken, nil
+}
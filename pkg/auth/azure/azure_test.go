@@ -0,0 +1,84 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSourceToken(t *testing.T) {
+	testCases := map[string]struct {
+		source    Source
+		status    int
+		body      string
+		wantQuery string
+		wantToken string
+		wantErr   bool
+	}{
+		"system-assigned identity": {
+			status:    http.StatusOK,
+			body:      `{"access_token": "azure-token"}`,
+			wantQuery: "api-version=2018-02-01&resource=api%3A%2F%2FAzureADTokenExchange",
+			wantToken: "azure-token",
+		},
+		"user-assigned identity": {
+			source:    Source{ClientID: "client-id", Resource: "api://example"},
+			status:    http.StatusOK,
+			body:      `{"access_token": "azure-token"}`,
+			wantQuery: "api-version=2018-02-01&client_id=client-id&resource=api%3A%2F%2Fexample",
+			wantToken: "azure-token",
+		},
+		"error status": {
+			status:  http.StatusBadRequest,
+			body:    `{"error": "invalid_request"}`,
+			wantErr: true,
+		},
+		"no token": {
+			status:  http.StatusOK,
+			body:    `{}`,
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var query string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Metadata") != "true" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				query = r.URL.RawQuery
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			t.Cleanup(srv.Close)
+
+			source := tc.source
+			source.Endpoint = srv.URL
+			tok, err := source.Token(context.Background(), "https://issuer.example", "identity-id")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.wantToken, tok); diff != "" {
+				t.Errorf("unexpected token (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantQuery, query); diff != "" {
+				t.Errorf("unexpected query (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
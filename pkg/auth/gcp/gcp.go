@@ -0,0 +1,35 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package gcp is the identity source for the Google service account of the
+// environment. It's a package of its own, so that only the examples that run
+// on Google Cloud link the Google API client.
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/idtoken"
+)
+
+// Source gets ID tokens for the Google service account of the environment,
+// like the service account of a Cloud Run service
+type Source struct{}
+
+// Token returns an ID token with the issuer as the audience
+func (Source) Token(ctx context.Context, issuer, _ string) (string, error) {
+	ts, err := idtoken.NewTokenSource(ctx, issuer)
+	if err != nil {
+		return "", fmt.Errorf("getting token source: %w", err)
+	}
+
+	tok, err := ts.Token()
+	if err != nil {
+		return "", fmt.Errorf("getting token: %w", err)
+	}
+
+	return tok.AccessToken, nil
+}
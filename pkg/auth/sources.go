@@ -0,0 +1,26 @@
+/*
+Copyright 2025 Chainguard, Inc.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// Static returns the same token every time, like a token that's been mounted
+// from a secret or issued by a CI provider. The sources for the cloud
+// identities are in the aws, gcp and azure packages, so that an example only
+// links the SDK of the cloud it runs on.
+type Static string
+
+// Token returns the token
+func (s Static) Token(context.Context, string, string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("no token")
+	}
+
+	return string(s), nil
+}